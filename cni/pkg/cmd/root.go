@@ -79,7 +79,7 @@ var rootCmd = &cobra.Command{
 		log.Infof("CNI race repair configuration: \n%+v", cfg.RepairConfig)
 
 		// Start metrics server
-		monitoring.SetupMonitoring(cfg.InstallConfig.MonitoringPort, "/metrics", ctx.Done())
+		monitoringMux := monitoring.SetupMonitoring(cfg.InstallConfig.MonitoringPort, "/metrics", ctx.Done())
 
 		// Start UDS log server
 		udsLogger := udsLog.NewUDSLogger(log.GetOutputLevel())
@@ -95,6 +95,8 @@ var rootCmd = &cobra.Command{
 
 		installer := install.NewInstaller(&cfg.InstallConfig, installDaemonReady)
 
+		// The repair controller detects and repairs the ambient pods not enrolled by the node agent, if enabled.
+		var ambientEnrollment repair.AmbientEnrollment
 		if cfg.InstallConfig.AmbientEnabled {
 			// Start ambient controller
 
@@ -121,10 +123,17 @@ var rootCmd = &cobra.Command{
 					DNSCapture:                 cfg.InstallConfig.AmbientDNSCapture,
 					EnableIPv6:                 cfg.InstallConfig.AmbientIPv6,
 					ReconcilePodRulesOnStartup: cfg.InstallConfig.AmbientReconcilePodRulesOnStartup,
+					NativeNftables:             cfg.InstallConfig.NativeNftables,
+					PodRulesCheckInterval:      nodeagent.PodRulesCheckInterval,
 				})
 			if err != nil {
 				return fmt.Errorf("failed to create ambient nodeagent service: %v", err)
 			}
+			ambientEnrollment = ambientAgent
+			// The debug endpoints of the node agent are served along the metrics, rather than on a listener of their own.
+			if monitoringMux != nil {
+				monitoringMux.Handle(constants.PodRulesDebugEndpoint, ambientAgent.PodRulesHandler())
+			}
 
 			// Ambient watch server IS enabled - on shutdown
 			// we need to check and see if this is an upgrade.
@@ -172,7 +181,7 @@ var rootCmd = &cobra.Command{
 		}
 		// TODO Note that during an "upgrade shutdown" in ambient mode,
 		// repair will (necessarily) be unavailable.
-		repair.StartRepair(ctx, cfg.RepairConfig)
+		repair.StartRepair(ctx, cfg.RepairConfig, ambientEnrollment)
 
 		// Note that even though we "install" the CNI plugin here *after* we start the node agent,
 		// it will block ambient-enabled pods from starting until `watchServerReady` == true
@@ -227,7 +236,7 @@ func init() {
 	registerIntegerParameter(constants.KubeconfigMode, constants.DefaultKubeconfigMode, "File mode of the kubeconfig file")
 	registerStringParameter(constants.KubeCAFile, "", "CA file for kubeconfig. Defaults to the same as install-cni pod")
 	registerBooleanParameter(constants.SkipTLSVerify, false, "Whether to use insecure TLS in kubeconfig file")
-	registerIntegerParameter(constants.MonitoringPort, 15014, "HTTP port to serve prometheus metrics")
+	registerIntegerParameter(constants.MonitoringPort, constants.DefaultMonitoringPort, "HTTP port to serve prometheus metrics")
 	registerStringParameter(constants.ZtunnelUDSAddress, "/var/run/ztunnel/ztunnel.sock", "The UDS server address which ztunnel will connect to")
 	registerBooleanParameter(constants.AmbientEnabled, false, "Whether ambient controller is enabled")
 	// Repair
@@ -252,6 +261,8 @@ func init() {
 		"A set of label selectors in label=value format that will be added to the pod list filters")
 	registerStringParameter(constants.RepairFieldSelectors, "",
 		"A set of field selectors in label=value format that will be added to the pod list filters")
+	registerBooleanParameter(constants.RepairReportOnly, false,
+		"Controller will only report the broken pods in a per-node ConfigMap, instead of deleting, labeling or repairing them")
 }
 
 func registerStringParameter(name, value, usage string) {
@@ -353,6 +364,8 @@ func constructConfig() (*config.Config, error) {
 		LabelSelectors:     viper.GetString(constants.RepairLabelSelectors),
 		FieldSelectors:     viper.GetString(constants.RepairFieldSelectors),
 		NativeNftables:     viper.GetBool(constants.NativeNftables),
+		ReportOnly:         viper.GetBool(constants.RepairReportOnly),
+		ReportNamespace:    installCfg.PodNamespace,
 	}
 
 	return &config.Config{InstallConfig: installCfg, RepairConfig: repairCfg}, nil
@@ -134,6 +134,11 @@ type RepairConfig struct {
 
 	// Whether to repair pods by running nftables rules
 	NativeNftables bool
+
+	// Whether to only report the broken pods in a per-node ConfigMap, instead of acting on them
+	ReportOnly bool
+	// Namespace of the per-node report ConfigMaps, which is the namespace of the CNI DaemonSet
+	ReportNamespace string
 }
 
 func (c InstallConfig) String() string {
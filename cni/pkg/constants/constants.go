@@ -63,6 +63,7 @@ const (
 	RepairInitExitCode       = "repair-init-container-exit-code"
 	RepairLabelSelectors     = "repair-label-selectors"
 	RepairFieldSelectors     = "repair-field-selectors"
+	RepairReportOnly         = "repair-report-only"
 )
 
 // Internal constants
@@ -84,6 +85,9 @@ const (
 	ServiceAccountPath                 = "/var/run/secrets/kubernetes.io/serviceaccount"
 	SelfNetNSPath                      = "/proc/self/ns/net"
 	DefaultIstioOwnedCNIConfigFilename = "02-istio-cni.conflist"
+	// Debug endpoints of the node agent, served on the monitoring port
+	PodRulesDebugEndpoint = "/debug/podrules"
+	DefaultMonitoringPort = 15014
 )
 
 // Exposed for testing "constants"
@@ -0,0 +1,168 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"sigs.k8s.io/knftables"
+)
+
+// NftDeps returns dependencies which back an IPSet with nftables sets instead of ipsets, for nodes
+// where ipset is not available. The sets are created in the table `nft` was created for, so that they
+// can be matched by the nftables rules of that table.
+func NftDeps(nft knftables.Interface) NetlinkIpsetDeps {
+	return &nftDeps{nft: nft}
+}
+
+type nftDeps struct {
+	nft knftables.Interface
+}
+
+func (m *nftDeps) run(tx *knftables.Transaction) error {
+	return m.nft.Run(context.TODO(), tx)
+}
+
+func (m *nftDeps) ipsetIPHashCreate(name string, v6 bool) error {
+	setType := "ipv4_addr"
+	if v6 {
+		setType = "ipv6_addr"
+	}
+	tx := m.nft.NewTransaction()
+	tx.Add(&knftables.Table{})
+	// Adding an existing set is a no-op, its elements are kept.
+	tx.Add(&knftables.Set{Name: name, Type: setType})
+	return m.run(tx)
+}
+
+func (m *nftDeps) destroySet(name string) error {
+	tx := m.nft.NewTransaction()
+	tx.Delete(&knftables.Set{Name: name})
+	return m.run(tx)
+}
+
+func (m *nftDeps) addIP(name string, ip netip.Addr, _ uint8, comment string, replace bool) error {
+	element := &knftables.Element{Set: name, Key: []string{ip.String()}, Comment: &comment}
+	tx := m.nft.NewTransaction()
+	if replace {
+		// Adding an existing element does not update its comment: make sure it is replaced.
+		tx.Add(element)
+		tx.Delete(&knftables.Element{Set: name, Key: element.Key})
+	}
+	tx.Add(element)
+	if err := m.run(tx); err != nil {
+		return fmt.Errorf("failed to add IP %s to nft set %s: %w", ip, name, err)
+	}
+	return nil
+}
+
+func (m *nftDeps) deleteIP(name string, ip netip.Addr, _ uint8) error {
+	tx := m.nft.NewTransaction()
+	tx.Delete(&knftables.Element{Set: name, Key: []string{ip.String()}})
+	if err := m.run(tx); err != nil {
+		return fmt.Errorf("failed to delete IP %s from nft set %s: %w", ip, name, err)
+	}
+	return nil
+}
+
+func (m *nftDeps) flush(name string) error {
+	tx := m.nft.NewTransaction()
+	tx.Flush(&knftables.Set{Name: name})
+	if err := m.run(tx); err != nil {
+		return fmt.Errorf("failed to flush nft set %s: %w", name, err)
+	}
+	return nil
+}
+
+// list returns the IPs of the set, with the comment of each element.
+func (m *nftDeps) list(name string) (map[netip.Addr]string, error) {
+	elements, err := m.nft.ListElements(context.TODO(), "set", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nft set %s: %w", name, err)
+	}
+	entries := make(map[netip.Addr]string, len(elements))
+	for _, element := range elements {
+		if len(element.Key) != 1 {
+			continue
+		}
+		ip, err := netip.ParseAddr(element.Key[0])
+		if err != nil {
+			continue
+		}
+		comment := ""
+		if element.Comment != nil {
+			comment = *element.Comment
+		}
+		entries[ip] = comment
+	}
+	return entries, nil
+}
+
+func (m *nftDeps) clearEntriesWithComment(name, comment string) error {
+	entries, err := m.list(name)
+	if err != nil {
+		return err
+	}
+	var delErrs []error
+	for ip, c := range entries {
+		if c == comment {
+			delErrs = append(delErrs, m.deleteIP(name, ip, 0))
+		}
+	}
+	return errors.Join(delErrs...)
+}
+
+// clearEntriesWithIPAndComment only removes the entry of the IP if its comment matches. If the IP matches but
+// the comment does not, returns the actual comment found to the caller, and does not remove the entry.
+func (m *nftDeps) clearEntriesWithIPAndComment(name string, ip netip.Addr, comment string) (string, error) {
+	entries, err := m.list(name)
+	if err != nil {
+		return "", err
+	}
+	c, ok := entries[ip]
+	if !ok {
+		return "", nil
+	}
+	if c != comment {
+		return c, nil
+	}
+	return "", m.deleteIP(name, ip, 0)
+}
+
+func (m *nftDeps) clearEntriesWithIP(name string, ip netip.Addr) error {
+	entries, err := m.list(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[ip]; !ok {
+		return nil
+	}
+	return m.deleteIP(name, ip, 0)
+}
+
+func (m *nftDeps) listEntriesByIP(name string) ([]netip.Addr, error) {
+	entries, err := m.list(name)
+	if err != nil {
+		return nil, err
+	}
+	ipList := make([]netip.Addr, 0, len(entries))
+	for ip := range entries {
+		ipList = append(ipList, ip)
+	}
+	return ipList, nil
+}
@@ -14,6 +14,8 @@
 
 package iptables
 
+import "net/netip"
+
 func GetCommonInPodTestCases() []struct {
 	name         string
 	config       func(cfg *IptablesConfig)
@@ -68,6 +70,15 @@ func GetCommonInPodTestCases() []struct {
 			},
 			podOverrides: PodLevelOverrides{DNSProxy: PodDNSDisabled},
 		},
+		{
+			name:   "exclusions",
+			config: func(cfg *IptablesConfig) {},
+			podOverrides: PodLevelOverrides{
+				ExcludeInboundPorts:     []uint16{5432, 6379},
+				ExcludeOutboundPorts:    []uint16{3306},
+				ExcludeOutboundIPRanges: []netip.Prefix{netip.MustParsePrefix("10.10.0.0/16"), netip.MustParsePrefix("fd00:10::/64")},
+			},
+		},
 	}
 }
 
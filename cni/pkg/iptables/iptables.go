@@ -72,6 +72,10 @@ type PodLevelOverrides struct {
 	VirtualInterfaces []string
 	IngressMode       bool
 	DNSProxy          PodDNSOverride
+	// Traffic excluded from the redirection to ztunnel, e.g. to reach a database on its raw port.
+	ExcludeInboundPorts     []uint16
+	ExcludeOutboundPorts    []uint16
+	ExcludeOutboundIPRanges []netip.Prefix
 }
 
 type PodDNSOverride int
@@ -238,6 +242,29 @@ func (cfg *IptablesConfigurator) CreateInpodRules(log *istiolog.Scope, podOverri
 	return nil
 }
 
+// InpodRulesDrifted returns true if the in-pod rules differ from the expected ones, e.g. if they were flushed by
+// another agent after they were created.
+// NOTE that this expects to be run from within the pod network namespace!
+func (cfg *IptablesConfigurator) InpodRulesDrifted(log *istiolog.Scope, podOverrides PodLevelOverrides) (bool, error) {
+	iptablesBuilder := cfg.AppendInpodRules(podOverrides)
+	ipt6V := &cfg.ipt6V
+	if !cfg.cfg.EnableIPv6 {
+		ipt6V = nil
+	}
+	_, deltaExists := iptablescapture.VerifyIptablesState(log, cfg.ext, iptablesBuilder, &cfg.iptV, ipt6V)
+	return deltaExists, nil
+}
+
+// ReconcileInpodRules replaces the in-pod rules with the expected ones, cleaning up the existing rules
+// regardless of the reconcile mode of the configurator.
+// NOTE that this expects to be run from within the pod network namespace!
+func (cfg *IptablesConfigurator) ReconcileInpodRules(log *istiolog.Scope, podOverrides PodLevelOverrides) error {
+	reconciler := ptr.Of(*cfg)
+	reconciler.cfg = ptr.Of(*cfg.cfg)
+	reconciler.cfg.Reconcile = true
+	return reconciler.CreateInpodRules(log, podOverrides)
+}
+
 func (cfg *IptablesConfigurator) AppendInpodRules(podOverrides PodLevelOverrides) *builder.IptablesRuleBuilder {
 	var redirectDNS bool
 
@@ -353,6 +380,17 @@ func (cfg *IptablesConfigurator) AppendInpodRules(podOverrides PodLevelOverrides
 			"-m", "tcp",
 			"-j", "ACCEPT",
 		)
+
+		for _, port := range podOverrides.ExcludeInboundPorts {
+			// CLI: -t nat -A ISTIO_PRERT -p tcp --dport 5432 -j RETURN
+			//
+			// DESC: Inbound ports excluded by the pod annotations are not redirected to ztunnel.
+			iptablesBuilder.AppendRule(ChainInpodPrerouting, "nat",
+				"-p", "tcp",
+				"--dport", fmt.Sprint(port),
+				"-j", "RETURN",
+			)
+		}
 	}
 
 	// CLI: -t NAT -A ISTIO_OUTPUT -d 169.254.7.127 -p tcp -m tcp -j ACCEPT
@@ -471,6 +509,27 @@ func (cfg *IptablesConfigurator) AppendInpodRules(podOverrides PodLevelOverrides
 		"-o", "lo",
 		"-j", "ACCEPT",
 	)
+	for _, port := range podOverrides.ExcludeOutboundPorts {
+		// CLI: -t nat -A ISTIO_OUTPUT -p tcp --dport 5432 -j RETURN
+		//
+		// DESC: Outbound ports excluded by the pod annotations are not redirected to ztunnel.
+		iptablesBuilder.AppendRule(ChainInpodOutput, "nat",
+			"-p", "tcp",
+			"--dport", fmt.Sprint(port),
+			"-j", "RETURN",
+		)
+	}
+	for _, cidr := range podOverrides.ExcludeOutboundIPRanges {
+		// CLI: -t nat -A ISTIO_OUTPUT -d 10.10.0.0/16 -j RETURN
+		//
+		// DESC: Outbound destinations excluded by the pod annotations are not redirected to ztunnel.
+		if cidr.Addr().Is4() {
+			iptablesBuilder.AppendRuleV4(ChainInpodOutput, "nat", "-d", cidr.String(), "-j", "RETURN")
+		} else {
+			iptablesBuilder.AppendRuleV6(ChainInpodOutput, "nat", "-d", cidr.String(), "-j", "RETURN")
+		}
+	}
+
 	// CLI: -A ISTIO_OUTPUT ! -d 127.0.0.1/32 -p tcp -m mark ! --mark 0x539/0xfff -j REDIRECT --to-ports <OUTPORT>
 	//
 	// DESC: If this is outbound, not bound for localhost, and does not have our packet mark, redirect to ztunnel proxy <OUTPORT>
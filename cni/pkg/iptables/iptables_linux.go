@@ -25,7 +25,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	"istio.io/istio/pkg/ptr"
-	"istio.io/istio/tools/common/config"
+	"istio.io/istio/tools/common/linkutil"
 )
 
 func AddInpodMarkIPRule(cfg *IptablesConfig) error {
@@ -93,7 +93,7 @@ func ReadSysctl(key string) (string, error) {
 }
 
 func forEachLoopbackRoute(cfg *IptablesConfig, operation string, f func(*netlink.Route) error) error {
-	loopbackLink, err := config.LinkByNameWithRetries("lo")
+	loopbackLink, err := linkutil.ByNameWithRetries("lo")
 	if err != nil {
 		return fmt.Errorf("failed to find 'lo' link: %v", err)
 	}
@@ -140,3 +140,24 @@ func constructTestConfig() *IptablesConfig {
 		HostProbeV6SNATAddress: probeSNATipv6,
 	}
 }
+
+func TestInpodRulesDrifted(t *testing.T) {
+	for _, ipv6 := range []bool{false, true} {
+		t.Run(ipstr(ipv6), func(t *testing.T) {
+			cfg := constructTestConfig()
+			cfg.EnableIPv6 = ipv6
+			ext := &dep.DependenciesStub{}
+			_, iptConfigurator, _ := NewIptablesConfigurator(cfg, cfg, ext, ext, EmptyNlDeps())
+
+			// The stub has no rules: they are reported as drifted, and only listed.
+			drifted, err := iptConfigurator.InpodRulesDrifted(scopes.CNIAgent, PodLevelOverrides{})
+			assert.NoError(t, err)
+			assert.Equal(t, drifted, true)
+			expected := []string{"iptables-save"}
+			if ipv6 {
+				expected = append(expected, "ip6tables-save")
+			}
+			assert.Equal(t, ext.ExecutedAll, expected)
+		})
+	}
+}
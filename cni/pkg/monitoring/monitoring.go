@@ -24,21 +24,23 @@ import (
 	"istio.io/istio/pkg/network"
 )
 
-func SetupMonitoring(port int, path string, stop <-chan struct{}) {
+// SetupMonitoring serves the metrics on path of the monitoring port, until stop is closed. It returns the mux of the
+// monitoring server, for the debug endpoints to be served along the metrics, or nil if the server is not running.
+func SetupMonitoring(port int, path string, stop <-chan struct{}) *http.ServeMux {
 	if port <= 0 {
-		return
+		return nil
 	}
 	mux := http.NewServeMux()
 	var listener net.Listener
 	var err error
 	if listener, err = net.Listen("tcp", fmt.Sprintf(":%d", port)); err != nil {
 		log.Errorf("unable to listen on socket: %v", err)
-		return
+		return nil
 	}
 	exporter, err := monitoring.RegisterPrometheusExporter(nil, nil)
 	if err != nil {
 		log.Errorf("could not set up prometheus exporter: %v", err)
-		return
+		return nil
 	}
 	mux.Handle(path, exporter)
 	monitoringServer := &http.Server{
@@ -54,4 +56,5 @@ func SetupMonitoring(port int, path string, stop <-chan struct{}) {
 		err := monitoringServer.Close()
 		log.Debugf("monitoring server terminated: %v", err)
 	}()
+	return mux
 }
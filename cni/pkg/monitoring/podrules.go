@@ -0,0 +1,55 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"istio.io/istio/pkg/monitoring"
+)
+
+const (
+	// PodRulesIntact is the result of a check finding the in-pod rules as expected.
+	PodRulesIntact = "intact"
+	// PodRulesDrifted is the result of a check finding the in-pod rules drifted from the expected ones.
+	PodRulesDrifted = "drifted"
+	// PodRulesCheckFailed is the result of a check which could not list the in-pod rules.
+	PodRulesCheckFailed = "error"
+
+	RepairSuccess = "success"
+	RepairFail    = "fail"
+)
+
+var (
+	resultLabel = monitoring.CreateLabel("result")
+
+	podRulesChecks = monitoring.NewSum(
+		"istio_cni_pod_rules_checks_total",
+		"Total number of checks of the in-pod traffic redirection rules of the pods captured in ambient mode, by result.",
+	)
+
+	podRulesRepairs = monitoring.NewSum(
+		"istio_cni_pod_rules_repairs_total",
+		"Total number of times the in-pod traffic redirection rules were re-applied after drifting, by result.",
+	)
+)
+
+// RecordPodRulesCheck records the result of a check of the in-pod rules of a pod.
+func RecordPodRulesCheck(result string) {
+	podRulesChecks.With(resultLabel.Value(result)).Increment()
+}
+
+// RecordPodRulesRepair records the result of re-applying the drifted in-pod rules of a pod.
+func RecordPodRulesRepair(result string) {
+	podRulesRepairs.With(resultLabel.Value(result)).Increment()
+}
@@ -0,0 +1,449 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nftables programs the ambient in-pod and host traffic redirection rules with native nftables,
+// as an alternative to the iptables rules of the cni/pkg/iptables package for nodes without iptables.
+// The rules are equivalent: see cni/pkg/iptables for the rationale of each of them.
+package nftables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"sigs.k8s.io/knftables"
+
+	"istio.io/istio/cni/pkg/ipset"
+	"istio.io/istio/cni/pkg/iptables"
+	"istio.io/istio/cni/pkg/scopes"
+	"istio.io/istio/cni/pkg/util"
+	istiolog "istio.io/istio/pkg/log"
+	"istio.io/istio/tools/common/config"
+	"istio.io/istio/tools/istio-nftables/pkg/builder"
+	"istio.io/istio/tools/istio-nftables/pkg/constants"
+)
+
+var log = scopes.CNIAgent
+
+const (
+	NatTable    = constants.IstioAmbientNatTable
+	MangleTable = constants.IstioAmbientMangleTable
+	RawTable    = constants.IstioAmbientRawTable
+)
+
+// inpodTables are the tables of the in-pod rules.
+var inpodTables = []string{NatTable, MangleTable, RawTable}
+
+var (
+	// inpodMark is the nft expression matching the packet mark set by ztunnel.
+	inpodMark = fmt.Sprintf("meta mark & 0x%x", iptables.InpodMask)
+	// inpodTproxyMark is the nft expression matching the packet mark of the connections marked by inpodMark.
+	inpodTproxyMark = fmt.Sprintf("meta mark & 0x%x", iptables.InpodTProxyMask)
+)
+
+// NftProviderFunc returns the nftables interface for the given family and table.
+type NftProviderFunc func(family knftables.Family, table string) (knftables.Interface, error)
+
+// NftablesConfigurator programs the ambient rules in nftables tables owned by Istio. Unlike iptables, each
+// table is replaced atomically in a single transaction, so that programming the rules is idempotent.
+type NftablesConfigurator struct {
+	nft knftables.Interface
+	// tables lists the rules of the in-pod tables, as listing requires an interface bound to a table.
+	tables map[string]knftables.Interface
+	nlDeps iptables.NetlinkDependencies
+	cfg    *iptables.IptablesConfig
+}
+
+// NewNftablesConfigurator returns the configurators of the host and in-pod rules. If nftProvider is nil,
+// the nft binary of the node is used.
+func NewNftablesConfigurator(
+	hostCfg *iptables.IptablesConfig,
+	podCfg *iptables.IptablesConfig,
+	nftProvider NftProviderFunc,
+	nlDeps iptables.NetlinkDependencies,
+) (*NftablesConfigurator, *NftablesConfigurator, error) {
+	if hostCfg == nil {
+		hostCfg = &iptables.IptablesConfig{}
+	}
+	if podCfg == nil {
+		podCfg = &iptables.IptablesConfig{}
+	}
+	if nftProvider == nil {
+		nftProvider = func(family knftables.Family, table string) (knftables.Interface, error) {
+			return knftables.New(family, table)
+		}
+	}
+
+	var hostNft, podNft knftables.Interface
+	podTables := map[string]knftables.Interface{}
+	err := util.RunAsHost(func() error {
+		var err error
+		// The host rules and the probe sets live in a single table.
+		if hostNft, err = nftProvider(knftables.InetFamily, NatTable); err != nil {
+			return err
+		}
+		if podNft, err = nftProvider("", ""); err != nil {
+			return err
+		}
+		for _, table := range inpodTables {
+			if podTables[table], err = nftProvider(knftables.InetFamily, table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize nftables: %w", err)
+	}
+
+	return &NftablesConfigurator{nft: hostNft, nlDeps: nlDeps, cfg: hostCfg},
+		&NftablesConfigurator{nft: podNft, tables: podTables, nlDeps: nlDeps, cfg: podCfg},
+		nil
+}
+
+// ProbeSetDeps returns the dependencies of the host probe set, as nft sets of the host table.
+func (cfg *NftablesConfigurator) ProbeSetDeps() ipset.NetlinkIpsetDeps {
+	return ipset.NftDeps(cfg.nft)
+}
+
+// ReconcileModeEnabled returns true if the rules of existing pods are to be reconciled on startup.
+func (cfg *NftablesConfigurator) ReconcileModeEnabled() bool {
+	return cfg.cfg.Reconcile
+}
+
+// appendVersionedRule appends the rule prefixed with the match of the v4 address, and the rule prefixed with the
+// match of the v6 address if IPv6 is enabled.
+func appendVersionedRule(b *builder.NftablesRuleBuilder, chain, table, v4Address, v6Address, match string, params ...string) {
+	b.AppendRule(chain, table, append([]string{"ip", match, v4Address}, params...)...)
+	b.AppendV6RuleIfSupported(chain, table, append([]string{"ip6", match, v6Address}, params...)...)
+}
+
+// inpodChains returns the base chains of the in-pod table.
+func inpodChains(table string) []*knftables.Chain {
+	switch table {
+	case NatTable:
+		return []*knftables.Chain{
+			baseChain(constants.PreroutingChain, knftables.NATType, knftables.PreroutingHook, knftables.DNATPriority),
+			baseChain(constants.OutputChain, knftables.NATType, knftables.OutputHook, knftables.DNATPriority),
+		}
+	case MangleTable:
+		return []*knftables.Chain{
+			baseChain(constants.PreroutingChain, knftables.FilterType, knftables.PreroutingHook, knftables.ManglePriority),
+			baseChain(constants.OutputChain, knftables.RouteType, knftables.OutputHook, knftables.ManglePriority),
+		}
+	case RawTable:
+		return []*knftables.Chain{
+			baseChain(constants.PreroutingChain, knftables.FilterType, knftables.PreroutingHook, knftables.RawPriority),
+			baseChain(constants.OutputChain, knftables.FilterType, knftables.OutputHook, knftables.RawPriority),
+		}
+	}
+	return nil
+}
+
+// addTable replaces the in-pod table with its base chains and the given rules. The table is deleted if it has
+// no rules.
+func addTable(tx *knftables.Transaction, table string, rules []knftables.Rule) {
+	t := &knftables.Table{Family: knftables.InetFamily, Name: table}
+	tx.Add(t)
+	if len(rules) == 0 {
+		tx.Delete(t)
+		return
+	}
+	tx.Flush(t)
+	for _, chain := range inpodChains(table) {
+		chain.Family = knftables.InetFamily
+		chain.Table = table
+		tx.Add(chain)
+	}
+	for _, rule := range rules {
+		tx.Add(&rule)
+	}
+}
+
+func baseChain(name string, chainType knftables.BaseChainType, hook knftables.BaseChainHook,
+	priority knftables.BaseChainPriority,
+) *knftables.Chain {
+	return &knftables.Chain{
+		Name:     name,
+		Type:     knftables.PtrTo(chainType),
+		Hook:     knftables.PtrTo(hook),
+		Priority: knftables.PtrTo(priority),
+	}
+}
+
+// CreateInpodRules programs the in-pod rules, replacing any existing ones.
+// NOTE that this expects to be run from within the pod network namespace!
+func (cfg *NftablesConfigurator) CreateInpodRules(log *istiolog.Scope, podOverrides iptables.PodLevelOverrides) error {
+	tx := cfg.nft.NewTransaction()
+	cfg.AppendInpodRules(tx, podOverrides)
+
+	if err := cfg.nlDeps.AddLoopbackRoutes(cfg.cfg); err != nil {
+		return err
+	}
+
+	if err := cfg.nlDeps.AddInpodMarkIPRule(cfg.cfg); err != nil {
+		return err
+	}
+
+	log.Debugf("Adding nftables rules:\n%s", tx)
+	if err := cfg.nft.Run(context.TODO(), tx); err != nil {
+		log.Errorf("failed to program nftables rules: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ReconcileInpodRules replaces the in-pod rules with the expected ones. As the tables are replaced atomically,
+// this is the same as CreateInpodRules.
+// NOTE that this expects to be run from within the pod network namespace!
+func (cfg *NftablesConfigurator) ReconcileInpodRules(log *istiolog.Scope, podOverrides iptables.PodLevelOverrides) error {
+	return cfg.CreateInpodRules(log, podOverrides)
+}
+
+// InpodRulesDrifted returns true if the in-pod rules differ from the expected ones. The rules listed by nft are
+// not parsed back into expressions, so the number of rules of each chain is compared: this detects the rules,
+// chains or tables flushed or deleted behind our back.
+// NOTE that this expects to be run from within the pod network namespace!
+func (cfg *NftablesConfigurator) InpodRulesDrifted(log *istiolog.Scope, podOverrides iptables.PodLevelOverrides) (bool, error) {
+	b := cfg.BuildInpodRules(podOverrides)
+	for _, table := range inpodTables {
+		expected := map[string]int{}
+		for _, rule := range b.Rules[table] {
+			expected[rule.Chain]++
+		}
+		rules, err := cfg.tables[table].ListRules(context.TODO(), "")
+		if knftables.IsNotFound(err) {
+			if len(expected) > 0 {
+				log.Debugf("nftables table %s not found", table)
+				return true, nil
+			}
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to list the rules of nftables table %s: %w", table, err)
+		}
+		current := map[string]int{}
+		for _, rule := range rules {
+			current[rule.Chain]++
+		}
+		if !maps.Equal(current, expected) {
+			log.Debugf("rules of nftables table %s drifted: expected %v rules by chain, found %v", table, expected, current)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AppendInpodRules appends the operations programming the in-pod rules to the transaction.
+func (cfg *NftablesConfigurator) AppendInpodRules(tx *knftables.Transaction, podOverrides iptables.PodLevelOverrides) {
+	b := cfg.BuildInpodRules(podOverrides)
+	for _, table := range inpodTables {
+		addTable(tx, table, b.Rules[table])
+	}
+}
+
+// BuildInpodRules returns the expected in-pod rules, by table.
+func (cfg *NftablesConfigurator) BuildInpodRules(podOverrides iptables.PodLevelOverrides) *builder.NftablesRuleBuilder {
+	var redirectDNS bool
+
+	switch podOverrides.DNSProxy {
+	case iptables.PodDNSUnset:
+		redirectDNS = cfg.cfg.RedirectDNS
+	case iptables.PodDNSEnabled:
+		redirectDNS = true
+	case iptables.PodDNSDisabled:
+		redirectDNS = false
+	}
+
+	mark := fmt.Sprintf("0x%x", iptables.InpodMark)
+	tproxyMark := fmt.Sprintf("0x%x", iptables.InpodTProxyMark)
+	probeV4 := cfg.cfg.HostProbeSNATAddress.String()
+	probeV6 := cfg.cfg.HostProbeV6SNATAddress.String()
+
+	b := builder.NewNftablesRuleBuilder(&config.Config{EnableIPv6: cfg.cfg.EnableIPv6})
+
+	// The first rules should be short-circuits, like virtual interface redirects. The redirect statement is
+	// terminal, so unlike with iptables there is no need for a return rule.
+	for _, virtInterface := range podOverrides.VirtualInterfaces {
+		b.AppendRule(constants.PreroutingChain, NatTable,
+			"iifname", virtInterface, "meta l4proto tcp", constants.Counter, "redirect to", fmt.Sprintf(":%d", iptables.ZtunnelOutboundPort))
+	}
+
+	if !podOverrides.IngressMode {
+		// If we have a packet mark, set a connmark.
+		b.AppendRule(constants.PreroutingChain, MangleTable,
+			inpodMark, "==", mark,
+			constants.Counter, "ct mark set ct mark &", fmt.Sprintf("0x%x", ^uint32(iptables.InpodTProxyMask)), "|", tproxyMark)
+
+		// Short-circuit the healthcheck probes SNAT-ed in the host netns.
+		appendVersionedRule(b, constants.PreroutingChain, NatTable, probeV4, probeV6,
+			"saddr", "meta l4proto tcp", constants.Counter, "accept")
+
+		// Inbound ports excluded by the pod annotations are not redirected to ztunnel.
+		for _, port := range podOverrides.ExcludeInboundPorts {
+			b.AppendRule(constants.PreroutingChain, NatTable,
+				"meta l4proto tcp", "tcp dport", fmt.Sprint(port), constants.Counter, "accept")
+		}
+	}
+
+	// Short-circuit anything coming back from the healthcheck probes.
+	appendVersionedRule(b, constants.OutputChain, NatTable, probeV4, probeV6,
+		"daddr", "meta l4proto tcp", constants.Counter, "accept")
+
+	if !podOverrides.IngressMode {
+		// Redirect anything not bound for localhost and without the mark to ztunnel inbound plaintext port.
+		// Skip 15008, which will go direct without redirect needed.
+		appendVersionedRule(b, constants.PreroutingChain, NatTable, "127.0.0.1/32", "::1/128",
+			"daddr !=", "meta l4proto tcp", "tcp dport !=", fmt.Sprint(iptables.ZtunnelInboundPort),
+			inpodMark, "!=", mark,
+			constants.Counter, "redirect to", fmt.Sprintf(":%d", iptables.ZtunnelInboundPlaintextPort))
+	}
+
+	// Propagate/restore the connmark (if we had one) for outbound.
+	b.AppendRule(constants.OutputChain, MangleTable,
+		"ct mark &", fmt.Sprintf("0x%x", iptables.InpodTProxyMask), "==", tproxyMark,
+		constants.Counter, "meta mark set ct mark")
+
+	if redirectDNS {
+		// Send UDP DNS requests to a non-localhost resolver to the ztunnel DNS proxy.
+		b.AppendRule(constants.OutputChain, NatTable,
+			"oifname != lo", "meta l4proto udp", inpodMark, "!=", mark,
+			"udp dport 53", constants.Counter, "redirect to", fmt.Sprintf(":%d", iptables.DNSCapturePort))
+		// Same as above for TCP.
+		appendVersionedRule(b, constants.OutputChain, NatTable, "127.0.0.1/32", "::1/128",
+			"daddr !=", "meta l4proto tcp", "tcp dport 53", inpodMark, "!=", mark,
+			constants.Counter, "redirect to", fmt.Sprintf(":%d", iptables.DNSCapturePort))
+
+		// Assign packets between the proxy and upstream DNS servers to their own conntrack zone to avoid port
+		// collisions. See https://github.com/istio/istio/issues/33469
+		// Proxy --> Upstream
+		b.AppendRule(constants.OutputChain, RawTable,
+			"meta l4proto udp", inpodMark, "==", mark, "udp dport 53", constants.Counter, "ct zone set 1")
+		// Upstream --> Proxy return packets
+		b.AppendRule(constants.PreroutingChain, RawTable,
+			"meta l4proto udp", inpodMark, "!=", mark, "udp sport 53", constants.Counter, "ct zone set 1")
+	}
+
+	// If this is outbound and has our mark, let it go.
+	b.AppendRule(constants.OutputChain, NatTable,
+		"meta l4proto tcp", inpodTproxyMark, "==", tproxyMark, constants.Counter, "accept")
+
+	// Do not redirect app calls to back itself via ztunnel when using the endpoint address.
+	appendVersionedRule(b, constants.OutputChain, NatTable, "127.0.0.1/32", "::1/128",
+		"daddr !=", "oifname lo", constants.Counter, "accept")
+
+	// Outbound ports and destinations excluded by the pod annotations are not redirected to ztunnel.
+	for _, port := range podOverrides.ExcludeOutboundPorts {
+		b.AppendRule(constants.OutputChain, NatTable,
+			"meta l4proto tcp", "tcp dport", fmt.Sprint(port), constants.Counter, "accept")
+	}
+	for _, cidr := range podOverrides.ExcludeOutboundIPRanges {
+		if cidr.Addr().Is4() {
+			b.AppendRule(constants.OutputChain, NatTable, "ip daddr", cidr.String(), constants.Counter, "accept")
+		} else {
+			b.AppendV6RuleIfSupported(constants.OutputChain, NatTable, "ip6 daddr", cidr.String(), constants.Counter, "accept")
+		}
+	}
+
+	// Redirect anything outbound not bound for localhost and without our mark to the ztunnel outbound port.
+	appendVersionedRule(b, constants.OutputChain, NatTable, "127.0.0.1/32", "::1/128",
+		"daddr !=", "meta l4proto tcp", inpodMark, "!=", mark,
+		constants.Counter, "redirect to", fmt.Sprintf(":%d", iptables.ZtunnelOutboundPort))
+
+	return b
+}
+
+// DeleteInpodRules deletes the in-pod rules.
+// NOTE that this expects to be run from within the pod network namespace!
+func (cfg *NftablesConfigurator) DeleteInpodRules(log *istiolog.Scope) error {
+	log.Debug("deleting nftables rules")
+	tx := cfg.nft.NewTransaction()
+	for _, table := range inpodTables {
+		t := &knftables.Table{Family: knftables.InetFamily, Name: table}
+		// Adding the table first makes the deletion succeed if the table does not exist.
+		tx.Add(t)
+		tx.Delete(t)
+	}
+	return errors.Join(
+		cfg.nft.Run(context.TODO(), tx),
+		cfg.nlDeps.DelInpodMarkIPRule(cfg.cfg),
+		cfg.nlDeps.DelLoopbackRoutes(cfg.cfg),
+	)
+}
+
+// CreateHostRulesForHealthChecks programs the host rules SNAT-ing the healthcheck probes of the node to the
+// captured pods, whose IPs are in the probe sets.
+// NOTE that this expects to be run from within the HOST network namespace!
+func (cfg *NftablesConfigurator) CreateHostRulesForHealthChecks() error {
+	log.Info("configuring host-level nftables rules (healthchecks, etc)")
+	tx := cfg.nft.NewTransaction()
+	cfg.AppendHostRules(tx)
+
+	return util.RunAsHost(func() error {
+		if err := cfg.nft.Run(context.TODO(), tx); err != nil {
+			log.Errorf("failed to add host netnamespace nftables rules: %v", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// AppendHostRules appends the operations programming the host rules to the transaction. The probe sets are
+// kept if they exist, as they are managed separately.
+func (cfg *NftablesConfigurator) AppendHostRules(tx *knftables.Transaction) {
+	tx.Add(&knftables.Table{})
+	tx.Add(&knftables.Set{Name: fmt.Sprintf(ipset.V4Name, iptables.ProbeIPSet), Type: "ipv4_addr"})
+	if cfg.cfg.EnableIPv6 {
+		tx.Add(&knftables.Set{Name: fmt.Sprintf(ipset.V6Name, iptables.ProbeIPSet), Type: "ipv6_addr"})
+	}
+	chain := baseChain(constants.PostroutingChain, knftables.NATType, knftables.PostroutingHook, knftables.SNATPriority)
+	tx.Add(chain)
+	tx.Flush(chain)
+
+	// Matching the socket owner is the equivalent of the iptables `-m owner --socket-exists`: the match
+	// fails for forwarded packets, which have no local socket.
+	tx.Add(&knftables.Rule{
+		Chain: constants.PostroutingChain,
+		Rule: knftables.Concat(
+			"meta skuid >= 0", "meta l4proto tcp",
+			"ip daddr", "@", fmt.Sprintf(ipset.V4Name, iptables.ProbeIPSet),
+			constants.Counter, "snat ip to", cfg.cfg.HostProbeSNATAddress.String()),
+	})
+	if cfg.cfg.EnableIPv6 {
+		tx.Add(&knftables.Rule{
+			Chain: constants.PostroutingChain,
+			Rule: knftables.Concat(
+				"meta skuid >= 0", "meta l4proto tcp",
+				"ip6 daddr", "@", fmt.Sprintf(ipset.V6Name, iptables.ProbeIPSet),
+				constants.Counter, "snat ip6 to", cfg.cfg.HostProbeV6SNATAddress.String()),
+		})
+	}
+}
+
+// DeleteHostRules deletes the host rules, keeping the probe sets.
+func (cfg *NftablesConfigurator) DeleteHostRules() {
+	log.Debug("Attempting to delete hostside nftables rules (if they exist)")
+	err := util.RunAsHost(func() error {
+		tx := cfg.nft.NewTransaction()
+		tx.Add(&knftables.Table{})
+		chain := &knftables.Chain{Name: constants.PostroutingChain}
+		tx.Add(chain)
+		tx.Delete(chain)
+		return cfg.nft.Run(context.TODO(), tx)
+	})
+	if err != nil {
+		log.Warnf("failed to delete host netnamespace nftables rules: %v", err)
+	}
+}
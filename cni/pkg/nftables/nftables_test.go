@@ -0,0 +1,314 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nftables
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/knftables"
+
+	"istio.io/istio/cni/pkg/ipset"
+	"istio.io/istio/cni/pkg/iptables"
+	"istio.io/istio/cni/pkg/scopes"
+	testutil "istio.io/istio/pilot/test/util"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/tools/istio-nftables/pkg/constants"
+)
+
+// recordingNft is a fake nftables recording the transactions it runs.
+type recordingNft struct {
+	*knftables.Fake
+	transactions []string
+}
+
+func (r *recordingNft) Run(ctx context.Context, tx *knftables.Transaction) error {
+	r.transactions = append(r.transactions, tx.String())
+	return r.Fake.Run(ctx, tx)
+}
+
+// tableView lists the rules of a table of a fake which is not bound to a table, as the in-pod fake.
+type tableView struct {
+	*knftables.Fake
+	table string
+}
+
+func (v *tableView) ListRules(ctx context.Context, chain string) ([]*knftables.Rule, error) {
+	v.RLock()
+	table := v.Tables[knftables.InetFamily][v.table]
+	v.RUnlock()
+	if table == nil {
+		// Let an empty fake bound to the table return the not found error.
+		return knftables.NewFake(knftables.InetFamily, v.table).ListRules(ctx, chain)
+	}
+	view := knftables.NewFake(knftables.InetFamily, v.table)
+	view.Table = table
+	return view.ListRules(ctx, chain)
+}
+
+func newConfigurators(t *testing.T, cfg *iptables.IptablesConfig) (*NftablesConfigurator, *recordingNft, *NftablesConfigurator, *recordingNft) {
+	t.Helper()
+	var host *recordingNft
+	pod := &recordingNft{Fake: knftables.NewFake("", "")}
+	provider := func(family knftables.Family, table string) (knftables.Interface, error) {
+		switch {
+		case table == "":
+			return pod, nil
+		case host == nil:
+			// The host table is created first.
+			host = &recordingNft{Fake: knftables.NewFake(family, table)}
+			return host, nil
+		default:
+			return &tableView{Fake: pod.Fake, table: table}, nil
+		}
+	}
+	hostCfg, podCfg, err := NewNftablesConfigurator(cfg, cfg, provider, iptables.EmptyNlDeps())
+	assert.NoError(t, err)
+	return hostCfg, host, podCfg, pod
+}
+
+func getCommonInPodTestCases() []struct {
+	name         string
+	config       func(cfg *iptables.IptablesConfig)
+	podOverrides iptables.PodLevelOverrides
+} {
+	return []struct {
+		name         string
+		config       func(cfg *iptables.IptablesConfig)
+		podOverrides iptables.PodLevelOverrides
+	}{
+		{
+			name: "default",
+			config: func(cfg *iptables.IptablesConfig) {
+				cfg.RedirectDNS = true
+			},
+		},
+		{
+			name:         "ingress",
+			config:       func(cfg *iptables.IptablesConfig) {},
+			podOverrides: iptables.PodLevelOverrides{IngressMode: true},
+		},
+		{
+			name:   "virtual_interfaces",
+			config: func(cfg *iptables.IptablesConfig) {},
+			podOverrides: iptables.PodLevelOverrides{
+				VirtualInterfaces: []string{"fake1s0f0", "fake1s0f1"},
+			},
+		},
+		{
+			name:   "ingress_and_virtual_interfaces",
+			config: func(cfg *iptables.IptablesConfig) {},
+			podOverrides: iptables.PodLevelOverrides{
+				IngressMode:       true,
+				VirtualInterfaces: []string{"fake1s0f0", "fake1s0f1"},
+			},
+		},
+		{
+			name: "dns_pod_enabled_and_off_globally",
+			config: func(cfg *iptables.IptablesConfig) {
+				cfg.RedirectDNS = false
+			},
+			podOverrides: iptables.PodLevelOverrides{DNSProxy: iptables.PodDNSEnabled},
+		},
+		{
+			name: "dns_pod_disabled_and_on_globally",
+			config: func(cfg *iptables.IptablesConfig) {
+				cfg.RedirectDNS = true
+			},
+			podOverrides: iptables.PodLevelOverrides{DNSProxy: iptables.PodDNSDisabled},
+		},
+		{
+			name:   "exclusions",
+			config: func(cfg *iptables.IptablesConfig) {},
+			podOverrides: iptables.PodLevelOverrides{
+				ExcludeInboundPorts:     []uint16{5432, 6379},
+				ExcludeOutboundPorts:    []uint16{3306},
+				ExcludeOutboundIPRanges: []netip.Prefix{netip.MustParsePrefix("10.10.0.0/16"), netip.MustParsePrefix("fd00:10::/64")},
+			},
+		},
+	}
+}
+
+func TestNftablesPodOverrides(t *testing.T) {
+	for _, tt := range getCommonInPodTestCases() {
+		for _, ipv6 := range []bool{false, true} {
+			t.Run(tt.name+"_"+ipstr(ipv6), func(t *testing.T) {
+				cfg := constructTestConfig()
+				cfg.EnableIPv6 = ipv6
+				tt.config(cfg)
+				_, _, pod, nft := newConfigurators(t, cfg)
+				assert.NoError(t, pod.CreateInpodRules(scopes.CNIAgent, tt.podOverrides))
+
+				compareToGolden(t, ipv6, tt.name, nft.transactions)
+			})
+		}
+	}
+}
+
+func TestNftablesHostRules(t *testing.T) {
+	for _, ipv6 := range []bool{false, true} {
+		t.Run(ipstr(ipv6), func(t *testing.T) {
+			cfg := constructTestConfig()
+			cfg.EnableIPv6 = ipv6
+			cfg.RedirectDNS = true
+			host, nft, _, _ := newConfigurators(t, cfg)
+			assert.NoError(t, host.CreateHostRulesForHealthChecks())
+
+			compareToGolden(t, ipv6, "hostprobe", nft.transactions)
+		})
+	}
+}
+
+func TestInvokedTwiceIsIdempotent(t *testing.T) {
+	for _, tt := range getCommonInPodTestCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := constructTestConfig()
+			tt.config(cfg)
+			_, _, pod, nft := newConfigurators(t, cfg)
+			assert.NoError(t, pod.CreateInpodRules(scopes.CNIAgent, tt.podOverrides))
+			state := nft.Dump()
+
+			// run another time to make sure we are idempotent
+			assert.NoError(t, pod.CreateInpodRules(scopes.CNIAgent, tt.podOverrides))
+			assert.Equal(t, nft.Dump(), state)
+		})
+	}
+}
+
+func TestDeleteInpodRules(t *testing.T) {
+	cfg := constructTestConfig()
+	cfg.RedirectDNS = true
+	_, _, pod, nft := newConfigurators(t, cfg)
+
+	// Deleting rules which do not exist is not an error.
+	assert.NoError(t, pod.DeleteInpodRules(scopes.CNIAgent))
+	assert.NoError(t, pod.CreateInpodRules(scopes.CNIAgent, iptables.PodLevelOverrides{}))
+	assert.NoError(t, pod.DeleteInpodRules(scopes.CNIAgent))
+	assert.Equal(t, nft.Dump(), "")
+}
+
+func TestHostRulesKeepProbeSet(t *testing.T) {
+	cfg := constructTestConfig()
+	cfg.EnableIPv6 = true
+	host, nft, _, _ := newConfigurators(t, cfg)
+
+	set, err := ipset.NewIPSet(iptables.ProbeIPSet, true, host.ProbeSetDeps())
+	assert.NoError(t, err)
+	pod := netip.MustParseAddr("10.0.0.1")
+	assert.NoError(t, set.AddIP(pod, 6, "uid-1", true))
+	assert.NoError(t, set.AddIP(netip.MustParseAddr("fd00::1"), 6, "uid-2", true))
+
+	// The rules are replaced on startup, and removed on shutdown, without touching the probe set entries.
+	host.DeleteHostRules()
+	assert.NoError(t, host.CreateHostRulesForHealthChecks())
+	host.DeleteHostRules()
+	entries, err := set.ListEntriesByIP()
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), 2)
+
+	// An entry is only removed by the pod that added it.
+	mismatch, err := set.ClearEntriesWithIPAndComment(pod, "uid-other")
+	assert.NoError(t, err)
+	assert.Equal(t, mismatch, "uid-1")
+	assert.NoError(t, set.AddIP(pod, 6, "uid-other", true))
+	mismatch, err = set.ClearEntriesWithIPAndComment(pod, "uid-other")
+	assert.NoError(t, err)
+	assert.Equal(t, mismatch, "")
+
+	assert.NoError(t, set.Flush())
+	assert.NoError(t, set.DestroySet())
+	assert.Equal(t, strings.TrimSpace(nft.Dump()), "add table inet "+NatTable)
+}
+
+func TestInpodRulesDrifted(t *testing.T) {
+	cfg := constructTestConfig()
+	cfg.EnableIPv6 = true
+	cfg.RedirectDNS = true
+	_, _, pod, nft := newConfigurators(t, cfg)
+	overrides := iptables.PodLevelOverrides{VirtualInterfaces: []string{"fake1s0f0"}}
+
+	drifted, err := pod.InpodRulesDrifted(scopes.CNIAgent, overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, drifted, true)
+
+	assert.NoError(t, pod.CreateInpodRules(scopes.CNIAgent, overrides))
+	state := nft.Dump()
+	drifted, err = pod.InpodRulesDrifted(scopes.CNIAgent, overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, drifted, false)
+
+	// The rules differ from the expected rules of other overrides.
+	drifted, err = pod.InpodRulesDrifted(scopes.CNIAgent, iptables.PodLevelOverrides{IngressMode: true})
+	assert.NoError(t, err)
+	assert.Equal(t, drifted, true)
+
+	for _, flush := range []knftables.Object{
+		&knftables.Chain{Family: knftables.InetFamily, Table: NatTable, Name: constants.OutputChain},
+		&knftables.Table{Family: knftables.InetFamily, Name: MangleTable},
+	} {
+		tx := nft.NewTransaction()
+		tx.Flush(flush)
+		assert.NoError(t, nft.Run(context.Background(), tx))
+		drifted, err = pod.InpodRulesDrifted(scopes.CNIAgent, overrides)
+		assert.NoError(t, err)
+		assert.Equal(t, drifted, true)
+
+		assert.NoError(t, pod.ReconcileInpodRules(scopes.CNIAgent, overrides))
+		assert.Equal(t, nft.Dump(), state)
+	}
+
+	tx := nft.NewTransaction()
+	tx.Delete(&knftables.Table{Family: knftables.InetFamily, Name: RawTable})
+	assert.NoError(t, nft.Run(context.Background(), tx))
+	drifted, err = pod.InpodRulesDrifted(scopes.CNIAgent, overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, drifted, true)
+
+	// The raw table is not expected without DNS capture.
+	overrides.DNSProxy = iptables.PodDNSDisabled
+	assert.NoError(t, pod.ReconcileInpodRules(scopes.CNIAgent, overrides))
+	drifted, err = pod.InpodRulesDrifted(scopes.CNIAgent, overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, drifted, false)
+}
+
+func ipstr(ipv6 bool) string {
+	if ipv6 {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+func compareToGolden(t *testing.T, ipv6 bool, name string, actual []string) {
+	t.Helper()
+	gotBytes := []byte(strings.Join(actual, "\n"))
+	goldenFile := filepath.Join("testdata", name+".golden")
+	if ipv6 {
+		goldenFile = filepath.Join("testdata", name+"_ipv6.golden")
+	}
+	testutil.CompareContent(t, gotBytes, goldenFile)
+}
+
+func constructTestConfig() *iptables.IptablesConfig {
+	probeSNATipv4 := netip.MustParseAddr("169.254.7.127")
+	probeSNATipv6 := netip.MustParseAddr("e9ac:1e77:90ca:399f:4d6d:ece2:2f9b:3164")
+	return &iptables.IptablesConfig{
+		HostProbeSNATAddress:   probeSNATipv4,
+		HostProbeV6SNATAddress: probeSNATipv6,
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeagent
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PodRules are the traffic redirection rules found in the network namespace of a pod, as dumped by each backend.
+type PodRules struct {
+	Iptables  string `json:"iptables,omitempty"`
+	IP6tables string `json:"ip6tables,omitempty"`
+	Nftables  string `json:"nftables,omitempty"`
+	// Errors are the failures to dump the rules of a backend, e.g. because it is not installed.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// PodRulesHandler serves the traffic redirection rules of the pods of the node, on the monitoring port of the node
+// agent. It is meant to be reached with a port-forward, e.g. by `istioctl x capture explain`.
+func (s *Server) PodRulesHandler() http.Handler {
+	return podRulesHandler(s.dataplane)
+}
+
+// podRulesHandler dumps the traffic redirection rules of the pod with the given uid.
+func podRulesHandler(dataplane MeshDataplane) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		uid := query.Get("uid")
+		if uid == "" {
+			http.Error(w, "the uid of the pod is required", http.StatusBadRequest)
+			return
+		}
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      query.Get("name"),
+			Namespace: query.Get("namespace"),
+			UID:       types.UID(uid),
+		}}
+		rules, err := dataplane.DumpPodRules(pod)
+		if errors.Is(err, ErrPodNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rules)
+	}
+}
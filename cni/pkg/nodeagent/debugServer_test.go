@@ -0,0 +1,58 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeagent
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/cni/pkg/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestPodRulesHandler(t *testing.T) {
+	podWithUID := func(uid string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: types.UID("uid-" + uid)}}
+	}
+	rules := &PodRules{Iptables: "*nat\n-A OUTPUT -j ISTIO_OUTPUT\nCOMMIT\n"}
+	dataplane := &fakeServer{}
+	dataplane.On("DumpPodRules", podWithUID("found")).Return(rules, nil)
+	dataplane.On("DumpPodRules", podWithUID("unknown")).Return(nil, ErrPodNotFound)
+	dataplane.On("DumpPodRules", podWithUID("failed")).Return(nil, errors.New("setns failed"))
+
+	router := http.NewServeMux()
+	router.HandleFunc(constants.PodRulesDebugEndpoint, podRulesHandler(dataplane))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	makeReq(t, server.URL, constants.PodRulesDebugEndpoint, http.StatusBadRequest)
+	makeReq(t, server.URL, constants.PodRulesDebugEndpoint+"?name=foo&namespace=bar&uid=uid-unknown", http.StatusNotFound)
+	makeReq(t, server.URL, constants.PodRulesDebugEndpoint+"?name=foo&namespace=bar&uid=uid-failed", http.StatusInternalServerError)
+
+	res, err := http.Get(server.URL + constants.PodRulesDebugEndpoint + "?name=foo&namespace=bar&uid=uid-found")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	got := &PodRules{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(got))
+	assert.Equal(t, got, rules)
+}
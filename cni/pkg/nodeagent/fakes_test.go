@@ -51,6 +51,10 @@ func (f *fakeZtunnel) PodAdded(ctx context.Context, pod *corev1.Pod, netns Netns
 	return f.addError
 }
 
+func (f *fakeZtunnel) PodAcked(uid string) bool {
+	return f.addError == nil
+}
+
 func (f *fakeZtunnel) Close() error {
 	return nil
 }
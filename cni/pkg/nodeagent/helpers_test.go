@@ -56,6 +56,25 @@ func (f *fakeServer) RemovePodFromMesh(ctx context.Context, pod *corev1.Pod, isD
 	return args.Error(0)
 }
 
+func (f *fakeServer) CheckPodRules(pod *corev1.Pod) (bool, error) {
+	if f.testWG != nil {
+		defer f.testWG.Done()
+	}
+	args := f.Called(pod)
+	return args.Bool(0), args.Error(1)
+}
+
+func (f *fakeServer) DumpPodRules(pod *corev1.Pod) (*PodRules, error) {
+	args := f.Called(pod)
+	rules, _ := args.Get(0).(*PodRules)
+	return rules, args.Error(1)
+}
+
+func (f *fakeServer) IsPodEnrolled(pod *corev1.Pod) bool {
+	args := f.Called(pod)
+	return args.Bool(0)
+}
+
 func (f *fakeServer) Start(ctx context.Context) {
 }
 
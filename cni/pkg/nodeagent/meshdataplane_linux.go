@@ -28,10 +28,16 @@ import (
 	"istio.io/istio/pkg/util/sets"
 )
 
+// hostRuleConfigurator programs the host rules for the healthchecks of the captured pods, with iptables or nftables.
+type hostRuleConfigurator interface {
+	CreateHostRulesForHealthChecks() error
+	DeleteHostRules()
+}
+
 type meshDataplane struct {
 	kubeClient         kubernetes.Interface
 	netServer          MeshDataplane
-	hostIptables       *iptables.IptablesConfigurator
+	hostRules          hostRuleConfigurator
 	hostsideProbeIPSet ipset.IPSet
 }
 
@@ -61,8 +67,8 @@ func (s *meshDataplane) Stop(skipCleanup bool) {
 	if !skipCleanup {
 		log.Info("CNI ambient server terminating, cleaning up node net rules")
 
-		log.Debug("removing host iptables rules")
-		s.hostIptables.DeleteHostRules()
+		log.Debug("removing host rules")
+		s.hostRules.DeleteHostRules()
 		_ = util.RunAsHost(func() error {
 			log.Debug("destroying host ipset")
 			s.hostsideProbeIPSet.Flush()
@@ -144,6 +150,22 @@ func (s *meshDataplane) AddPodToMesh(ctx context.Context, pod *corev1.Pod, podIP
 	return nil
 }
 
+// CheckPodRules checks the in-pod rules of an enrolled pod against the expected rules, and re-applies them if
+// they drifted.
+func (s *meshDataplane) CheckPodRules(pod *corev1.Pod) (bool, error) {
+	return s.netServer.CheckPodRules(pod)
+}
+
+// DumpPodRules returns the traffic redirection rules found in the network namespace of a pod.
+func (s *meshDataplane) DumpPodRules(pod *corev1.Pod) (*PodRules, error) {
+	return s.netServer.DumpPodRules(pod)
+}
+
+// IsPodEnrolled returns whether the netns of the pod was sent to ztunnel and accepted by it.
+func (s *meshDataplane) IsPodEnrolled(pod *corev1.Pod) bool {
+	return s.netServer.IsPodEnrolled(pod)
+}
+
 // RemovePodFromMesh attempts to remove iptables rules from the pod (if it is not already terminating),
 // and sends the pod remove to ztunnel.
 //
@@ -270,16 +292,15 @@ func (s *meshDataplane) addPodToHostNSIpset(pod *corev1.Pod, podIPs []netip.Addr
 	return addedIps, errors.Join(ipsetAddrErrs...)
 }
 
-// createHostsideProbeIpset creates an ipset. This is designed to be called from the host netns.
-// Note that if the ipset already exist by name, Create will not return an error.
+// createHostsideProbeIpset creates an ipset, or an nft set depending on deps. This is designed to be called
+// from the host netns. Note that if the set already exist by name, Create will not return an error.
 //
 // We will unconditionally flush our set before use here, so it shouldn't matter.
-func createHostsideProbeIpset(isV6 bool) (ipset.IPSet, error) {
+func createHostsideProbeIpset(isV6 bool, deps ipset.NetlinkIpsetDeps) (ipset.IPSet, error) {
 	var probeSet ipset.IPSet
 	runErr := util.RunAsHost(func() error {
 		var err error
-		linDeps := ipset.RealNlDeps()
-		probeSet, err = ipset.NewIPSet(iptables.ProbeIPSet, isV6, linDeps)
+		probeSet, err = ipset.NewIPSet(iptables.ProbeIPSet, isV6, deps)
 		if err != nil {
 			return err
 		}
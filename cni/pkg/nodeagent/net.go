@@ -15,6 +15,8 @@
 package nodeagent
 
 import (
+	"net/netip"
+	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -22,6 +24,8 @@ import (
 	"istio.io/api/annotation"
 	"istio.io/istio/cni/pkg/iptables"
 	"istio.io/istio/cni/pkg/util"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/zdsapi"
 )
 
 func getPodLevelTrafficOverrides(pod *corev1.Pod) iptables.PodLevelOverrides {
@@ -54,5 +58,77 @@ func getPodLevelTrafficOverrides(pod *corev1.Pod) iptables.PodLevelOverrides {
 		}
 	}
 
+	podCfg.ExcludeInboundPorts = getPortsAnnotation(pod, constants.AmbientExcludeInboundPorts)
+	podCfg.ExcludeOutboundPorts = getPortsAnnotation(pod, constants.AmbientExcludeOutboundPorts)
+	for _, v := range splitAnnotation(pod, constants.AmbientExcludeOutboundIPRanges) {
+		cidr, err := netip.ParsePrefix(v)
+		if err != nil {
+			log.Warnf("ignoring invalid CIDR %q in annotation %s of pod %s/%s: %v", v, constants.AmbientExcludeOutboundIPRanges,
+				pod.Namespace, pod.Name, err)
+			continue
+		}
+		podCfg.ExcludeOutboundIPRanges = append(podCfg.ExcludeOutboundIPRanges, cidr.Masked())
+	}
+
 	return podCfg
 }
+
+// getPortsAnnotation returns the ports listed in the annotation of the pod, ignoring the invalid ones.
+func getPortsAnnotation(pod *corev1.Pod, name string) []uint16 {
+	var ports []uint16
+	for _, v := range splitAnnotation(pod, name) {
+		port, err := strconv.ParseUint(v, 10, 16)
+		if err != nil || port == 0 {
+			log.Warnf("ignoring invalid port %q in annotation %s of pod %s/%s", v, name, pod.Namespace, pod.Name)
+			continue
+		}
+		ports = append(ports, uint16(port))
+	}
+	return ports
+}
+
+// splitAnnotation returns the non-empty values of the comma separated annotation of the pod.
+func splitAnnotation(pod *corev1.Pod, name string) []string {
+	var values []string
+	for _, v := range strings.Split(pod.Annotations[name], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getCaptureExclusions returns the traffic of the pod excluded from the capture, as sent to ztunnel,
+// or nil if nothing is excluded.
+func getCaptureExclusions(podCfg iptables.PodLevelOverrides) *zdsapi.CaptureExclusions {
+	if len(podCfg.ExcludeInboundPorts) == 0 && len(podCfg.ExcludeOutboundPorts) == 0 && len(podCfg.ExcludeOutboundIPRanges) == 0 {
+		return nil
+	}
+	exclusions := &zdsapi.CaptureExclusions{}
+	for _, port := range podCfg.ExcludeInboundPorts {
+		exclusions.InboundPorts = append(exclusions.InboundPorts, uint32(port))
+	}
+	for _, port := range podCfg.ExcludeOutboundPorts {
+		exclusions.OutboundPorts = append(exclusions.OutboundPorts, uint32(port))
+	}
+	for _, cidr := range podCfg.ExcludeOutboundIPRanges {
+		exclusions.OutboundIpRanges = append(exclusions.OutboundIpRanges, cidr.String())
+	}
+	return exclusions
+}
+
+// setCaptureExclusions replaces the traffic of the pod excluded from the capture with the one sent to ztunnel.
+func setCaptureExclusions(podCfg *iptables.PodLevelOverrides, exclusions *zdsapi.CaptureExclusions) {
+	podCfg.ExcludeInboundPorts, podCfg.ExcludeOutboundPorts, podCfg.ExcludeOutboundIPRanges = nil, nil, nil
+	for _, port := range exclusions.GetInboundPorts() {
+		podCfg.ExcludeInboundPorts = append(podCfg.ExcludeInboundPorts, uint16(port))
+	}
+	for _, port := range exclusions.GetOutboundPorts() {
+		podCfg.ExcludeOutboundPorts = append(podCfg.ExcludeOutboundPorts, uint16(port))
+	}
+	for _, v := range exclusions.GetOutboundIpRanges() {
+		if cidr, err := netip.ParsePrefix(v); err == nil {
+			podCfg.ExcludeOutboundIPRanges = append(podCfg.ExcludeOutboundIPRanges, cidr)
+		}
+	}
+}
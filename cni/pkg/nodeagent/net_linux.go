@@ -19,23 +19,39 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"os/exec"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"istio.io/istio/cni/pkg/iptables"
+	istiolog "istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/slices"
+	iptablesconstants "istio.io/istio/tools/istio-iptables/pkg/constants"
 	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
 )
 
+// podRuleConfigurator programs the in-pod traffic redirection rules, with iptables or nftables.
+type podRuleConfigurator interface {
+	ReconcileModeEnabled() bool
+	CreateInpodRules(log *istiolog.Scope, podOverrides iptables.PodLevelOverrides) error
+	DeleteInpodRules(log *istiolog.Scope) error
+	InpodRulesDrifted(log *istiolog.Scope, podOverrides iptables.PodLevelOverrides) (bool, error)
+	ReconcileInpodRules(log *istiolog.Scope, podOverrides iptables.PodLevelOverrides) error
+}
+
 // Adapts CNI to ztunnel server. decoupled from k8s for easier integration testing.
 type NetServer struct {
 	ztunnelServer      ZtunnelServer
 	currentPodSnapshot *podNetnsCache
-	podIptables        *iptables.IptablesConfigurator
-	podNs              PodNetnsFinder
+	podRules           podRuleConfigurator
+	// podRulesMu prevents the checks of the in-pod rules from racing with their creation and deletion.
+	podRulesMu sync.Mutex
+	podNs      PodNetnsFinder
 	// allow overriding for tests
 	netnsRunner func(fdable NetnsFd, toRun func() error) error
+	dumpRules   func() *PodRules
 }
 
 var _ MeshDataplane = &NetServer{}
@@ -60,7 +76,7 @@ func (s *NetServer) ConstructInitialSnapshot(existingAmbientPods []*corev1.Pod)
 		consErr = append(consErr, err)
 	}
 
-	if s.podIptables.ReconcileModeEnabled() {
+	if s.podRules.ReconcileModeEnabled() {
 		log.Info("inpod reconcile mode enabled")
 		for _, pod := range existingAmbientPods {
 			log := log.WithLabels("ns", pod.Namespace, "name", pod.Name)
@@ -108,27 +124,33 @@ func (s *NetServer) Stop(_ bool) {
 func (s *NetServer) AddPodToMesh(ctx context.Context, pod *corev1.Pod, podIPs []netip.Addr, netNs string) error {
 	log := log.WithLabels("ns", pod.Namespace, "name", pod.Name)
 	log.Info("adding pod to the mesh")
+	s.podRulesMu.Lock()
 	// make sure the cache is aware of the pod, even if we don't have the netns yet.
 	s.currentPodSnapshot.Ensure(string(pod.UID))
 	openNetns, err := s.getOrOpenNetns(pod, netNs)
 	if err != nil {
 		// if we fail, we should not leave a dangling UID in the snapshot.
 		s.currentPodSnapshot.Take(string(pod.UID))
+		s.podRulesMu.Unlock()
 		return NewErrNonRetryableAdd(err)
 	}
 
 	podCfg := getPodLevelTrafficOverrides(pod)
+	// The workload sent to ztunnel below is the snapshot of the capture exclusions the rules are checked against.
+	s.currentPodSnapshot.UpdateWorkload(string(pod.UID), podToWorkload(pod))
 
 	log.Debug("calling CreateInpodRules")
 	if err := s.netnsRunner(openNetns, func() error {
-		return s.podIptables.CreateInpodRules(log, podCfg)
+		return s.podRules.CreateInpodRules(log, podCfg)
 	}); err != nil {
 		// We currently treat any failure to create inpod rules as non-retryable/catastrophic,
 		// and return a NonRetryableError in this case.
 		log.Errorf("failed to update POD inpod: %s/%s %v", pod.Namespace, pod.Name, err)
 		s.currentPodSnapshot.Take(string(pod.UID))
+		s.podRulesMu.Unlock()
 		return NewErrNonRetryableAdd(err)
 	}
+	s.podRulesMu.Unlock()
 
 	// For *any* other failures after a successful `CreateInpodRules` call, we must return
 	// the error as-is.
@@ -158,7 +180,10 @@ func (s *NetServer) RemovePodFromMesh(ctx context.Context, pod *corev1.Pod, isDe
 	log.WithLabels("delete", isDelete).Debugf("removing pod from the mesh")
 
 	// Whether pod is already deleted or not, we need to let go of our netns ref.
+	// Once it is taken, the pod is no longer checked, so that its rules are not re-applied.
+	s.podRulesMu.Lock()
 	openNetns := s.currentPodSnapshot.Take(string(pod.UID))
+	s.podRulesMu.Unlock()
 	if openNetns == nil {
 		log.Debug("failed to find pod netns during removal")
 	}
@@ -168,7 +193,7 @@ func (s *NetServer) RemovePodFromMesh(ctx context.Context, pod *corev1.Pod, isDe
 		if openNetns != nil {
 			// pod is removed from the mesh, but is still running. remove iptables rules
 			log.Debugf("calling DeleteInpodRules")
-			if err := s.netnsRunner(openNetns, func() error { return s.podIptables.DeleteInpodRules(log) }); err != nil {
+			if err := s.netnsRunner(openNetns, func() error { return s.podRules.DeleteInpodRules(log) }); err != nil {
 				return fmt.Errorf("failed to delete inpod rules: %w", err)
 			}
 		} else {
@@ -184,13 +209,14 @@ func (s *NetServer) RemovePodFromMesh(ctx context.Context, pod *corev1.Pod, isDe
 	return nil
 }
 
-func newNetServer(ztunnelServer ZtunnelServer, podNsMap *podNetnsCache, podIptables *iptables.IptablesConfigurator, podNs PodNetnsFinder) *NetServer {
+func newNetServer(ztunnelServer ZtunnelServer, podNsMap *podNetnsCache, podRules podRuleConfigurator, podNs PodNetnsFinder) *NetServer {
 	return &NetServer{
 		ztunnelServer:      ztunnelServer,
 		currentPodSnapshot: podNsMap,
 		podNs:              podNs,
-		podIptables:        podIptables,
+		podRules:           podRules,
 		netnsRunner:        NetnsDo,
+		dumpRules:          dumpNetnsRules,
 	}
 }
 
@@ -208,7 +234,7 @@ func (s *NetServer) reconcileExistingPod(pod *corev1.Pod) error {
 	podCfg := getPodLevelTrafficOverrides(pod)
 
 	if err := s.netnsRunner(openNetns, func() error {
-		return s.podIptables.CreateInpodRules(log, podCfg)
+		return s.podRules.CreateInpodRules(log, podCfg)
 	}); err != nil {
 		return err
 	}
@@ -216,6 +242,71 @@ func (s *NetServer) reconcileExistingPod(pod *corev1.Pod) error {
 	return nil
 }
 
+// IsPodEnrolled returns whether the netns of the pod is in the snapshot sent to ztunnel, and ztunnel accepted the
+// pod. Pods whose netns is not known yet are in the snapshot, but are not enrolled.
+func (s *NetServer) IsPodEnrolled(pod *corev1.Pod) bool {
+	uid := string(pod.UID)
+	return s.currentPodSnapshot.Get(uid) != nil && s.ztunnelServer.PodAcked(uid)
+}
+
+// CheckPodRules checks the in-pod rules of an enrolled pod against the expected rules, and re-applies them if
+// they drifted, e.g. if they were flushed by another agent of the node.
+// Returns whether the rules drifted, with the error of the check, or of the re-application if they drifted.
+// If the netns of the pod is not known, ErrPodNotFound is returned.
+func (s *NetServer) CheckPodRules(pod *corev1.Pod) (bool, error) {
+	log := log.WithLabels("ns", pod.Namespace, "name", pod.Name)
+	s.podRulesMu.Lock()
+	defer s.podRulesMu.Unlock()
+	wl := s.currentPodSnapshot.GetWorkload(string(pod.UID))
+	if wl.Netns == nil {
+		return false, ErrPodNotFound
+	}
+
+	podCfg := getPodLevelTrafficOverrides(pod)
+	// ztunnel only learns the capture exclusions of the pod when it is enrolled: keep using the ones it was sent,
+	// rather than the current annotations of the pod, so that the rules and ztunnel do not disagree.
+	setCaptureExclusions(&podCfg, wl.Workload.GetCaptureExclusions())
+
+	var drifted bool
+	err := s.netnsRunner(wl.Netns, func() error {
+		var err error
+		if drifted, err = s.podRules.InpodRulesDrifted(log, podCfg); err != nil || !drifted {
+			return err
+		}
+		log.Warn("inpod rules drifted from the expected rules, re-applying them")
+		return s.podRules.ReconcileInpodRules(log, podCfg)
+	})
+	return drifted, err
+}
+
+// DumpPodRules returns the traffic redirection rules found in the network namespace of a pod, whether it is
+// enrolled in ambient or captured by a sidecar. If the netns of the pod is not found, ErrPodNotFound is returned.
+func (s *NetServer) DumpPodRules(pod *corev1.Pod) (*PodRules, error) {
+	s.podRulesMu.Lock()
+	defer s.podRulesMu.Unlock()
+	openNetns := s.currentPodSnapshot.Get(string(pod.UID))
+	if openNetns == nil {
+		// The pod is not enrolled: find its netns using procfs, without caching it.
+		res, err := s.podNs.FindNetnsForPods(map[types.UID]*corev1.Pod{pod.UID: pod})
+		if err != nil {
+			return nil, err
+		}
+		defer res.Close()
+		wl, found := res[string(pod.UID)]
+		if !found {
+			return nil, ErrPodNotFound
+		}
+		openNetns = wl.Netns
+	}
+
+	var rules *PodRules
+	err := s.netnsRunner(openNetns, func() error {
+		rules = s.dumpRules()
+		return nil
+	})
+	return rules, err
+}
+
 func (s *NetServer) rescanPod(pod *corev1.Pod) error {
 	// this can happen if the pod was dynamically added to the mesh after it was created.
 	// in that case, try finding the netns using procfs.
@@ -298,3 +389,34 @@ func realDependenciesInpod(useScopedLocks bool) *dep.RealDependencies {
 		NetworkNamespace:        "",
 	}
 }
+
+// dumpNetnsRules dumps the iptables and nftables rules of the current network namespace.
+// A pod only uses one of the backends, so the failures to dump the others are reported, rather than returned.
+func dumpNetnsRules() *PodRules {
+	rules := &PodRules{}
+	deps := realDependenciesInpod(UseScopedIptablesLegacyLocking)
+	for _, ipV6 := range []bool{false, true} {
+		iptVer, err := deps.DetectIptablesVersion(ipV6)
+		if err != nil {
+			rules.Errors = append(rules.Errors, err.Error())
+			continue
+		}
+		out, err := deps.Run(log.WithLabels(), true, iptablesconstants.IPTablesSave, &iptVer, nil)
+		if err != nil {
+			rules.Errors = append(rules.Errors, fmt.Sprintf("%s failed: %v", iptVer.DetectedSaveBinary, err))
+			continue
+		}
+		if ipV6 {
+			rules.IP6tables = out.String()
+		} else {
+			rules.Iptables = out.String()
+		}
+	}
+	out, err := exec.Command("nft", "list", "ruleset").Output()
+	if err != nil {
+		rules.Errors = append(rules.Errors, fmt.Sprintf("nft list ruleset failed: %v", err))
+	} else {
+		rules.Nftables = string(out)
+	}
+	return rules
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net/netip"
 	"runtime"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -31,7 +32,9 @@ import (
 
 	"istio.io/api/annotation"
 	"istio.io/istio/cni/pkg/iptables"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/zdsapi"
 	"istio.io/istio/tools/istio-iptables/pkg/dependencies"
 )
 
@@ -593,6 +596,103 @@ var overrideTests = map[string]struct {
 	},
 }
 
+func TestCheckPodRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setupLogging()
+
+	fakeDeps := &dependencies.DependenciesStub{}
+	fixture := getTestFixureWithIptablesConfig(ctx, fakeDeps, nil, nil)
+	netServer := fixture.netServer
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "123"}}
+
+	// The pod is not checked until its netns is known.
+	_, err := netServer.CheckPodRules(pod)
+	assert.Equal(t, errors.Is(err, ErrPodNotFound), true)
+	assert.Equal(t, len(fakeDeps.ExecutedAll), 0)
+
+	assert.NoError(t, netServer.AddPodToMesh(ctx, pod, []netip.Addr{netip.MustParseAddr("99.9.9.9")}, "fakenetns"))
+	*fakeDeps = dependencies.DependenciesStub{}
+
+	// The stub never lists any rules: they are reported as drifted, and re-applied.
+	drifted, err := netServer.CheckPodRules(pod)
+	assert.NoError(t, err)
+	assert.Equal(t, drifted, true)
+	assert.Equal(t, fakeDeps.ExecutedAll[0], "iptables-save")
+	assert.Equal(t, len(fakeDeps.ExecutedStdin) != 0, true)
+
+	// Once removed, the pod is no longer checked.
+	assert.NoError(t, netServer.RemovePodFromMesh(ctx, pod, true))
+	_, err = netServer.CheckPodRules(pod)
+	assert.Equal(t, errors.Is(err, ErrPodNotFound), true)
+}
+
+func TestCheckPodRulesKeepsEnrolledCaptureExclusions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setupLogging()
+
+	fakeDeps := &dependencies.DependenciesStub{}
+	fixture := getTestFixureWithIptablesConfig(ctx, fakeDeps, nil, nil)
+	netServer := fixture.netServer
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "foo",
+		Namespace:   "bar",
+		UID:         "123",
+		Annotations: map[string]string{constants.AmbientExcludeOutboundPorts: "5432"},
+	}}
+	assert.NoError(t, netServer.AddPodToMesh(ctx, pod, []netip.Addr{netip.MustParseAddr("99.9.9.9")}, "fakenetns"))
+	*fakeDeps = dependencies.DependenciesStub{}
+
+	// ztunnel is not told about an update of the annotations, so the re-applied rules keep the exclusions it was sent.
+	updated := pod.DeepCopy()
+	updated.Annotations[constants.AmbientExcludeOutboundPorts] = "6379"
+	drifted, err := netServer.CheckPodRules(updated)
+	assert.NoError(t, err)
+	assert.Equal(t, drifted, true)
+	rules := strings.Join(fakeDeps.ExecutedStdin, "\n")
+	assert.Equal(t, strings.Contains(rules, "--dport 5432"), true)
+	assert.Equal(t, strings.Contains(rules, "--dport 6379"), false)
+	assert.Equal(t, fixture.podNsMap.GetWorkload(string(pod.UID)).Workload.CaptureExclusions,
+		&zdsapi.CaptureExclusions{OutboundPorts: []uint32{5432}})
+
+	// Enrolling the pod again sends the updated exclusions to ztunnel, and the rules follow them.
+	assert.NoError(t, netServer.AddPodToMesh(ctx, updated, []netip.Addr{netip.MustParseAddr("99.9.9.9")}, ""))
+	*fakeDeps = dependencies.DependenciesStub{}
+	_, err = netServer.CheckPodRules(updated)
+	assert.NoError(t, err)
+	rules = strings.Join(fakeDeps.ExecutedStdin, "\n")
+	assert.Equal(t, strings.Contains(rules, "--dport 6379"), true)
+	assert.Equal(t, strings.Contains(rules, "--dport 5432"), false)
+}
+
+func TestIsPodEnrolled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setupLogging()
+
+	fixture := getTestFixure(ctx)
+	netServer := fixture.netServer
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "123"}}
+	assert.Equal(t, netServer.IsPodEnrolled(pod), false)
+
+	// Pods whose netns is not known yet are not enrolled.
+	netServer.currentPodSnapshot.Ensure(string(pod.UID))
+	assert.Equal(t, netServer.IsPodEnrolled(pod), false)
+
+	assert.NoError(t, netServer.AddPodToMesh(ctx, pod, []netip.Addr{netip.MustParseAddr("99.9.9.9")}, "fakenetns"))
+	assert.Equal(t, netServer.IsPodEnrolled(pod), true)
+
+	assert.NoError(t, netServer.RemovePodFromMesh(ctx, pod, true))
+	assert.Equal(t, netServer.IsPodEnrolled(pod), false)
+
+	// Pods sent to ztunnel, but rejected by it, are not enrolled.
+	fixture.ztunnelServer.addError = errors.New("fake error")
+	assert.Error(t, netServer.AddPodToMesh(ctx, pod, []netip.Addr{netip.MustParseAddr("99.9.9.9")}, "fakenetns"))
+	assert.Equal(t, netServer.currentPodSnapshot.Get(string(pod.UID)) != nil, true)
+	assert.Equal(t, netServer.IsPodEnrolled(pod), false)
+}
+
 func TestGetPodLevelOverrides(t *testing.T) {
 	for name, test := range overrideTests {
 		t.Run(name, func(t *testing.T) {
@@ -602,6 +702,38 @@ func TestGetPodLevelOverrides(t *testing.T) {
 	}
 }
 
+func TestGetPodLevelCaptureExclusions(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "test",
+		Namespace: "test",
+		UID:       "12345",
+		Annotations: map[string]string{
+			constants.AmbientExcludeInboundPorts:     "5432, 6379,,notaport,0",
+			constants.AmbientExcludeOutboundPorts:    "3306,70000",
+			constants.AmbientExcludeOutboundIPRanges: "10.10.1.1/16,fd00:10::/64,10.0.0.1",
+		},
+	}}
+	res := getPodLevelTrafficOverrides(pod)
+	assert.Equal(t, res.ExcludeInboundPorts, []uint16{5432, 6379})
+	assert.Equal(t, res.ExcludeOutboundPorts, []uint16{3306})
+	// The invalid CIDRs are ignored, and the valid ones are masked.
+	assert.Equal(t, fmt.Sprint(res.ExcludeOutboundIPRanges), "[10.10.0.0/16 fd00:10::/64]")
+}
+
+func TestPodToWorkloadCaptureExclusions(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test", UID: "12345"}}
+	assert.Equal(t, podToWorkload(pod).CaptureExclusions, nil)
+
+	pod.Annotations = map[string]string{
+		constants.AmbientExcludeInboundPorts:     "5432",
+		constants.AmbientExcludeOutboundIPRanges: "10.10.0.0/16",
+	}
+	assert.Equal(t, podToWorkload(pod).CaptureExclusions, &zdsapi.CaptureExclusions{
+		InboundPorts:     []uint32{5432},
+		OutboundIpRanges: []string{"10.10.0.0/16"},
+	})
+}
+
 // for tests that call `runtime.GC()` - we have no control over when the GC is actually scheduled,
 // and it is flake-prone to check for closure after calling it, this retries for a bit to make
 // sure the netns is closed eventually.
@@ -614,3 +746,33 @@ func assertNSClosed(t *testing.T, closed *atomic.Bool) {
 	}
 	t.Fatal("NS not closed")
 }
+
+func TestDumpPodRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setupLogging()
+
+	fixture := getTestFixure(ctx)
+	netServer := fixture.netServer
+	rules := &PodRules{Nftables: "table inet istio-proxy-nat {\n}\n"}
+	netServer.dumpRules = func() *PodRules {
+		return rules
+	}
+
+	// The netns of the pods which are not enrolled is found using procfs.
+	sidecarPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "863b91d4-4b68-4efa-917f-4b560e3e86aa"}}
+	got, err := netServer.DumpPodRules(sidecarPod)
+	assert.NoError(t, err)
+	assert.Equal(t, got, rules)
+	// It is not cached, as the pod is not enrolled.
+	assert.Equal(t, fixture.podNsMap.Get(string(sidecarPod.UID)), nil)
+
+	unknownPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "123"}}
+	_, err = netServer.DumpPodRules(unknownPod)
+	assert.Equal(t, errors.Is(err, ErrPodNotFound), true)
+
+	assert.NoError(t, netServer.AddPodToMesh(ctx, unknownPod, []netip.Addr{netip.MustParseAddr("99.9.9.9")}, "fakenetns"))
+	got, err = netServer.DumpPodRules(unknownPod)
+	assert.NoError(t, err)
+	assert.Equal(t, got, rules)
+}
@@ -16,6 +16,7 @@ package nodeagent
 
 import (
 	"net/netip"
+	"time"
 
 	"istio.io/istio/cni/pkg/util"
 	"istio.io/istio/pkg/config/constants"
@@ -31,6 +32,9 @@ var (
 	HostProbeSNATIP                = netip.MustParseAddr(env.RegisterStringVar("HOST_PROBE_SNAT_IP", DefaultHostProbeSNATIP, "").Get())
 	HostProbeSNATIPV6              = netip.MustParseAddr(env.RegisterStringVar("HOST_PROBE_SNAT_IPV6", DefaultHostProbeSNATIPV6, "").Get())
 	UseScopedIptablesLegacyLocking = env.RegisterBoolVar("AMBIENT_USE_SCOPED_XTABLES_LOCKING", true, "").Get()
+	PodRulesCheckInterval          = env.Register("AMBIENT_POD_RULES_CHECK_INTERVAL", time.Minute,
+		"The interval at which the inpod traffic redirection rules of the enrolled pods are checked, and re-applied if "+
+			"they drifted from the expected rules. Set to 0 to disable the checks.").Get()
 )
 
 const (
@@ -54,4 +58,8 @@ type AmbientArgs struct {
 	DNSCapture                 bool
 	EnableIPv6                 bool
 	ReconcilePodRulesOnStartup bool
+	// NativeNftables programs the traffic redirection with nftables rules and sets instead of iptables and ipsets.
+	NativeNftables bool
+	// PodRulesCheckInterval is the interval of the checks of the inpod rules of the enrolled pods, or 0 to disable them.
+	PodRulesCheckInterval time.Duration
 }
@@ -102,6 +102,23 @@ func (p *podNetnsCache) Get(uid string) Netns {
 	return nil
 }
 
+// GetWorkload returns the workload info of the pod, as sent to ztunnel, with its netns, if it's in the cache
+func (p *podNetnsCache) GetWorkload(uid string) WorkloadInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.currentPodCache[uid]
+}
+
+// UpdateWorkload replaces the workload info of a pod whose netns is in the cache, e.g. when it is sent to ztunnel again.
+func (p *podNetnsCache) UpdateWorkload(uid string, workload *zdsapi.WorkloadInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if info, ok := p.currentPodCache[uid]; ok && info.Netns != nil {
+		info.Workload = workload
+		p.currentPodCache[uid] = info
+	}
+}
+
 // make sure uid is in the cache, even if we don't have a netns
 func (p *podNetnsCache) Ensure(uid string) {
 	p.mu.Lock()
@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeagent
+
+import (
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/cni/pkg/monitoring"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/kclient"
+)
+
+const (
+	ReasonPodRulesDrifted      = "TrafficRedirectionDrifted"
+	ReasonPodRulesRepairFailed = "TrafficRedirectionRepairFailed"
+)
+
+// podRulesChecker periodically checks the in-pod traffic redirection rules of the pods enrolled on the node,
+// and re-applies them if they drifted from the expected rules. Without this, a pod whose rules were flushed,
+// e.g. by another agent of the node, would silently bypass the mesh.
+type podRulesChecker struct {
+	pods      func() []*corev1.Pod
+	dataplane MeshDataplane
+	events    kclient.EventRecorder
+	interval  time.Duration
+}
+
+func newPodRulesChecker(client kube.Client, pods func() []*corev1.Pod, dataplane MeshDataplane, interval time.Duration) *podRulesChecker {
+	return &podRulesChecker{
+		pods:      pods,
+		dataplane: dataplane,
+		events:    kclient.NewEventRecorder(client, "istio-cni-node"),
+		interval:  interval,
+	}
+}
+
+// Run checks the rules of the enrolled pods every interval, until stop is closed.
+func (c *podRulesChecker) Run(stop <-chan struct{}) {
+	defer c.events.Shutdown()
+	log.Infof("checking the inpod rules of the enrolled pods every %v", c.interval)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, pod := range c.pods() {
+				c.checkPod(pod)
+			}
+		}
+	}
+}
+
+func (c *podRulesChecker) checkPod(pod *corev1.Pod) {
+	log := log.WithLabels("ns", pod.Namespace, "name", pod.Name)
+	drifted, err := c.dataplane.CheckPodRules(pod)
+	switch {
+	case errors.Is(err, ErrPodNotFound):
+		// The pod is being added or removed.
+		log.Debug("pod netns not found, skipping the check of its inpod rules")
+	case !drifted && err != nil:
+		log.Warnf("failed to check inpod rules: %v", err)
+		monitoring.RecordPodRulesCheck(monitoring.PodRulesCheckFailed)
+	case !drifted:
+		monitoring.RecordPodRulesCheck(monitoring.PodRulesIntact)
+	case err != nil:
+		log.Errorf("inpod rules drifted from the expected rules, and could not be re-applied: %v", err)
+		monitoring.RecordPodRulesCheck(monitoring.PodRulesDrifted)
+		monitoring.RecordPodRulesRepair(monitoring.RepairFail)
+		c.events.Write(pod, corev1.EventTypeWarning, ReasonPodRulesRepairFailed,
+			"traffic redirection rules drifted from the expected rules, and could not be re-applied: %v", err)
+	default:
+		monitoring.RecordPodRulesCheck(monitoring.PodRulesDrifted)
+		monitoring.RecordPodRulesRepair(monitoring.RepairSuccess)
+		c.events.Write(pod, corev1.EventTypeWarning, ReasonPodRulesDrifted,
+			"traffic redirection rules drifted from the expected rules, and were re-applied")
+	}
+}
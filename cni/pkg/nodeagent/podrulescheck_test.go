@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestPodRulesChecker(t *testing.T) {
+	setupLogging()
+	mt := monitortest.New(t)
+	client := kube.NewFakeClient()
+	pods := map[string]*corev1.Pod{}
+	for _, name := range []string{"intact", "drifted", "unrepaired", "failed", "unknown"} {
+		pods[name] = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", UID: types.UID(name)}}
+	}
+	server := &fakeServer{}
+	server.On("CheckPodRules", pods["intact"]).Return(false, nil)
+	server.On("CheckPodRules", pods["drifted"]).Return(true, nil)
+	server.On("CheckPodRules", pods["unrepaired"]).Return(true, errors.New("nft failed"))
+	server.On("CheckPodRules", pods["failed"]).Return(false, errors.New("nft failed"))
+	server.On("CheckPodRules", pods["unknown"]).Return(false, ErrPodNotFound)
+
+	checker := newPodRulesChecker(client, func() []*corev1.Pod {
+		return []*corev1.Pod{pods["intact"], pods["drifted"], pods["unrepaired"], pods["failed"], pods["unknown"]}
+	}, server, 10*time.Millisecond)
+	stop := make(chan struct{})
+	go checker.Run(stop)
+	defer close(stop)
+
+	checks := "istio_cni_pod_rules_checks_total"
+	repairs := "istio_cni_pod_rules_repairs_total"
+	mt.Assert(checks, map[string]string{"result": "intact"}, monitortest.AtLeast(2))
+	mt.Assert(checks, map[string]string{"result": "drifted"}, monitortest.AtLeast(4))
+	mt.Assert(checks, map[string]string{"result": "error"}, monitortest.AtLeast(2))
+	mt.Assert(repairs, map[string]string{"result": "success"}, monitortest.AtLeast(2))
+	mt.Assert(repairs, map[string]string{"result": "fail"}, monitortest.AtLeast(2))
+
+	// Warning events are only written for the pods whose rules drifted.
+	retry.UntilSuccessOrFail(t, func() error {
+		events, err := client.Kube().CoreV1().Events("test").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		reasons := sets.New[string]()
+		for _, event := range events.Items {
+			if event.Type != corev1.EventTypeWarning {
+				return fmt.Errorf("unexpected event type %s", event.Type)
+			}
+			reasons.Insert(event.InvolvedObject.Name + "/" + event.Reason)
+		}
+		expected := sets.New("drifted/"+ReasonPodRulesDrifted, "unrepaired/"+ReasonPodRulesRepairFailed)
+		if !reasons.Equals(expected) {
+			return fmt.Errorf("unexpected events %v", sets.SortedList(reasons))
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+}
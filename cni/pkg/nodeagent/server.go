@@ -26,6 +26,7 @@ import (
 	"k8s.io/client-go/rest"
 
 	"istio.io/istio/cni/pkg/scopes"
+	"istio.io/istio/cni/pkg/util"
 	"istio.io/istio/pkg/kube"
 )
 
@@ -40,6 +41,12 @@ type MeshDataplane interface {
 
 	AddPodToMesh(ctx context.Context, pod *corev1.Pod, podIPs []netip.Addr, netNs string) error
 	RemovePodFromMesh(ctx context.Context, pod *corev1.Pod, isDelete bool) error
+	// CheckPodRules checks the in-pod rules of an enrolled pod, and re-applies them if they drifted.
+	CheckPodRules(pod *corev1.Pod) (bool, error)
+	// DumpPodRules returns the traffic redirection rules found in the network namespace of a pod.
+	DumpPodRules(pod *corev1.Pod) (*PodRules, error)
+	// IsPodEnrolled returns whether the netns of a pod is enrolled, that is, sent to ztunnel and accepted by it.
+	IsPodEnrolled(pod *corev1.Pod) bool
 
 	Stop(skipCleanup bool)
 }
@@ -50,6 +57,8 @@ type Server struct {
 
 	handlers  K8sHandlers
 	dataplane MeshDataplane
+	// podRules is nil if the periodic checks of the in-pod rules are disabled.
+	podRules *podRulesChecker
 
 	isReady *atomic.Value
 
@@ -75,6 +84,9 @@ func NewServer(ctx context.Context, ready *atomic.Value, pluginSocket string, ar
 
 	s.NotReady()
 	s.handlers = setupHandlers(s.ctx, s.kubeClient, s.dataplane, args.SystemNamespace, args.EnablementSelector)
+	if args.PodRulesCheckInterval > 0 {
+		s.podRules = newPodRulesChecker(s.kubeClient, s.handlers.GetActiveAmbientPodSnapshot, s.dataplane, args.PodRulesCheckInterval)
+	}
 
 	cniServer := startCniPluginServer(ctx, pluginSocket, s.handlers, s.dataplane)
 	err = cniServer.Start()
@@ -109,11 +121,29 @@ func (s *Server) Start() {
 	// Start accepting ztunnel connections
 	// (and send current snapshot when we get one)
 	s.dataplane.Start(s.ctx)
+	if s.podRules != nil {
+		go s.podRules.Run(s.ctx.Done())
+	}
 	// Everything (informer handlers, snapshot, zt server) ready to go
 	log.Info("CNI ambient server marking ready")
 	s.Ready()
 }
 
+// IsPodEnrolled returns whether the netns of the pod is enrolled, that is, known to ztunnel.
+func (s *Server) IsPodEnrolled(pod *corev1.Pod) bool {
+	return s.dataplane.IsPodEnrolled(pod)
+}
+
+// EnrollPod enrolls a running ambient pod again, finding its netns from the host. It is used by the repair
+// controller, for the pods that are marked as captured but are not enrolled.
+func (s *Server) EnrollPod(pod *corev1.Pod) error {
+	podIPs := util.GetPodIPsIfPresent(pod)
+	if len(podIPs) == 0 {
+		return fmt.Errorf("pod %s/%s has no IP assigned yet", pod.Namespace, pod.Name)
+	}
+	return s.dataplane.AddPodToMesh(s.ctx, pod, podIPs, "")
+}
+
 func (s *Server) Stop(skipCleanup bool) {
 	s.cniServerStopFunc()
 	s.dataplane.Stop(skipCleanup)
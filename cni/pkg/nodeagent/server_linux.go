@@ -20,7 +20,9 @@ import (
 	"path/filepath"
 
 	pconstants "istio.io/istio/cni/pkg/constants"
+	"istio.io/istio/cni/pkg/ipset"
 	"istio.io/istio/cni/pkg/iptables"
+	"istio.io/istio/cni/pkg/nftables"
 	"istio.io/istio/pkg/kube"
 )
 
@@ -41,33 +43,49 @@ func initMeshDataplane(client kube.Client, args AmbientArgs) (*meshDataplane, er
 		Reconcile:              args.ReconcilePodRulesOnStartup,
 	}
 
-	log.Debug("creating ipsets in the node netns")
-	set, err := createHostsideProbeIpset(hostCfg.EnableIPv6)
-	if err != nil {
-		return nil, fmt.Errorf("error initializing hostside probe ipset: %w", err)
-	}
-
 	podNsMap := newPodNetnsCache(openNetnsInRoot(pconstants.HostMountsPath))
 	ztunnelServer, err := newZtunnelServer(args.ServerSocket, podNsMap, defaultZTunnelKeepAliveCheckInterval)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing the ztunnel server: %w", err)
 	}
 
-	hostIptables, podIptables, err := iptables.NewIptablesConfigurator(
-		hostCfg,
-		podCfg,
-		realDependenciesHost(),
-		realDependenciesInpod(UseScopedIptablesLegacyLocking),
-		iptables.RealNlDeps(),
+	var (
+		hostRules hostRuleConfigurator
+		podRules  podRuleConfigurator
+		set       ipset.IPSet
 	)
-	if err != nil {
-		return nil, fmt.Errorf("error configuring iptables: %w", err)
+	if args.NativeNftables {
+		hostNftables, podNftables, err := nftables.NewNftablesConfigurator(hostCfg, podCfg, nil, iptables.RealNlDeps())
+		if err != nil {
+			return nil, fmt.Errorf("error configuring nftables: %w", err)
+		}
+		log.Debug("creating nft sets in the node netns")
+		if set, err = createHostsideProbeIpset(hostCfg.EnableIPv6, hostNftables.ProbeSetDeps()); err != nil {
+			return nil, fmt.Errorf("error initializing hostside probe nft set: %w", err)
+		}
+		hostRules, podRules = hostNftables, podNftables
+	} else {
+		log.Debug("creating ipsets in the node netns")
+		if set, err = createHostsideProbeIpset(hostCfg.EnableIPv6, ipset.RealNlDeps()); err != nil {
+			return nil, fmt.Errorf("error initializing hostside probe ipset: %w", err)
+		}
+		hostIptables, podIptables, err := iptables.NewIptablesConfigurator(
+			hostCfg,
+			podCfg,
+			realDependenciesHost(),
+			realDependenciesInpod(UseScopedIptablesLegacyLocking),
+			iptables.RealNlDeps(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring iptables: %w", err)
+		}
+		hostRules, podRules = hostIptables, podIptables
 	}
 
 	// Create hostprobe rules now, in the host netns
-	hostIptables.DeleteHostRules()
+	hostRules.DeleteHostRules()
 
-	if err := hostIptables.CreateHostRulesForHealthChecks(); err != nil {
+	if err := hostRules.CreateHostRulesForHealthChecks(); err != nil {
 		return nil, fmt.Errorf("error initializing the host rules for health checks: %w", err)
 	}
 
@@ -75,12 +93,12 @@ func initMeshDataplane(client kube.Client, args AmbientArgs) (*meshDataplane, er
 	if err != nil {
 		return nil, err
 	}
-	netServer := newNetServer(ztunnelServer, podNsMap, podIptables, podNetns)
+	netServer := newNetServer(ztunnelServer, podNsMap, podRules, podNetns)
 
 	return &meshDataplane{
 		kubeClient:         client.Kube(),
 		netServer:          netServer,
-		hostIptables:       hostIptables,
+		hostRules:          hostRules,
 		hostsideProbeIPSet: set,
 	}, nil
 }
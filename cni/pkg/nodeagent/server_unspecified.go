@@ -48,6 +48,18 @@ func (*meshDataplane) RemovePodFromMesh(ctx context.Context, pod *corev1.Pod, is
 	return errNotImplemented
 }
 
+func (*meshDataplane) CheckPodRules(pod *corev1.Pod) (bool, error) {
+	return false, errNotImplemented
+}
+
+func (*meshDataplane) DumpPodRules(pod *corev1.Pod) (*PodRules, error) {
+	return nil, errNotImplemented
+}
+
+func (*meshDataplane) IsPodEnrolled(pod *corev1.Pod) bool {
+	return false
+}
+
 func (*meshDataplane) Stop(skipCleanup bool) {
 	// not supported
 	return
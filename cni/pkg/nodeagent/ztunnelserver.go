@@ -30,6 +30,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	"istio.io/istio/pkg/monitoring"
+	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/pkg/zdsapi"
 )
 
@@ -42,6 +43,8 @@ type ZtunnelServer interface {
 	Run(ctx context.Context)
 	PodDeleted(ctx context.Context, uid string) error
 	PodAdded(ctx context.Context, pod *v1.Pod, netns Netns) error
+	// PodAcked returns whether the latest add of the pod was acked by ztunnel without error.
+	PodAcked(uid string) bool
 	Close() error
 }
 
@@ -102,8 +105,6 @@ func (c *connMgr) deleteConn(conn ZtunnelConnection) {
 	ztunnelConnected.RecordInt(int64(len(c.connectionSet)))
 }
 
-// this is used in tests
-// nolint: unused
 func (c *connMgr) len() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -119,10 +120,47 @@ type ztunnelServer struct {
 	conns             *connMgr
 	pods              PodNetnsCache
 	keepaliveInterval time.Duration
+	acked             ackedPods
 }
 
 var _ ZtunnelServer = &ztunnelServer{}
 
+// ackedPods are the uids of the pods ztunnel accepted, i.e. whose add (or keep, in a snapshot) was acked without
+// error. A pod sent to ztunnel, but rejected by it, is not part of it.
+type ackedPods struct {
+	mu   sync.Mutex
+	uids sets.String
+}
+
+func (a *ackedPods) set(uid string, acked bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if acked {
+		if a.uids == nil {
+			a.uids = sets.New[string]()
+		}
+		a.uids.Insert(uid)
+	} else {
+		a.uids.Delete(uid)
+	}
+}
+
+func (a *ackedPods) reset(uids sets.String) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.uids = uids
+}
+
+func (a *ackedPods) contains(uid string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.uids.Contains(uid)
+}
+
+func (z *ztunnelServer) PodAcked(uid string) bool {
+	return z.acked.contains(uid)
+}
+
 func (z *ztunnelServer) Close() error {
 	return z.listener.Close()
 }
@@ -171,7 +209,13 @@ func (z *ztunnelServer) handleConn(ctx context.Context, conn ZtunnelConnection)
 
 	// before doing anything, add the connection to the list of active connections
 	z.conns.addConn(conn)
-	defer z.conns.deleteConn(conn)
+	defer func() {
+		z.conns.deleteConn(conn)
+		// with no ztunnel connected, no pod is proxied by ztunnel anymore.
+		if z.conns.len() == 0 {
+			z.acked.reset(nil)
+		}
+	}()
 
 	log := log.WithLabels("conn_uuid", conn.UUID())
 
@@ -258,11 +302,16 @@ func podToWorkload(pod *v1.Pod) *zdsapi.WorkloadInfo {
 		Namespace:      namespace,
 		Name:           name,
 		ServiceAccount: svcAccount,
+		// Let ztunnel know about the traffic of the workload it never sees.
+		CaptureExclusions: getCaptureExclusions(getPodLevelTrafficOverrides(pod)),
 	}
 }
 
+// sendSnapshot sends the pods of the snapshot to a new ztunnel connection. The pods acked by ztunnel replace the
+// ones acked on the previous connections.
 func (z *ztunnelServer) sendSnapshot(_ context.Context, conn ZtunnelConnection) error {
 	snap := z.pods.ReadCurrentPodSnapshot()
+	acked := sets.New[string]()
 	for uid, wl := range snap {
 		var resp *zdsapi.WorkloadResponse
 		var err error
@@ -299,8 +348,11 @@ func (z *ztunnelServer) sendSnapshot(_ context.Context, conn ZtunnelConnection)
 		}
 		if resp.GetAck().GetError() != "" {
 			log.Errorf("add-workload: got ack error: %s", resp.GetAck().GetError())
+			continue
 		}
+		acked.Insert(uid)
 	}
+	z.acked.reset(acked)
 	resp, err := conn.SendMsgAndWaitForAck(&zdsapi.WorkloadRequest{
 		Payload: &zdsapi.WorkloadRequest_SnapshotSent{
 			SnapshotSent: &zdsapi.SnapshotSent{},
@@ -355,6 +407,7 @@ func (z *ztunnelServer) PodDeleted(ctx context.Context, uid string) error {
 	}
 
 	log.Debugf("sending delete pod to all ztunnels: %s %v", uid, r)
+	z.acked.set(uid, false)
 
 	var delErr []error
 
@@ -270,13 +270,16 @@ func (z *ztunnelServer) PodAdded(ctx context.Context, pod *v1.Pod, netns Netns)
 	fd := int(netns.Fd())
 	resp, err := latestConn.Send(ctx, r, &fd)
 	if err != nil {
+		z.acked.set(uid, false)
 		return err
 	}
 	log.Debug("sent pod add to ztunnel")
 
 	if resp.GetAck().GetError() != "" {
 		log.Errorf("failed to add workload: %s", resp.GetAck().GetError())
+		z.acked.set(uid, false)
 		return fmt.Errorf("got ack error: %s", resp.GetAck().GetError())
 	}
+	z.acked.set(uid, true)
 	return nil
 }
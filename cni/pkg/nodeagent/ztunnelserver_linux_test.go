@@ -651,6 +651,67 @@ func TestZtunnelPodKept(t *testing.T) {
 	mt.Assert(ztunnelConnected.Name(), nil, monitortest.Exactly(0))
 }
 
+func TestZtunnelPodAcked(t *testing.T) {
+	setupLogging()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fixture := connect(ctx)
+	defer fixture.podCloser()
+	ztunClient := fixture.ztunClient
+	ztunnelServer := fixture.ztunServer
+	defer ztunnelServer.Close()
+	uid := fixture.uid
+	// ack the pod of the snapshot
+	readRequest(t, ztunClient)
+	sendAck(ztunClient)
+	readRequest(t, ztunClient)
+	sendAck(ztunClient)
+	assert.EventuallyEqual(t, func() bool { return ztunnelServer.PodAcked(uid) }, true)
+
+	// a pod rejected by ztunnel is not acked
+	errChan := make(chan error)
+	pod2, ns2, tmpFileToClose := podAndNetns()
+	defer tmpFileToClose.Close()
+	go func() {
+		errChan <- ztunnelServer.PodAdded(ctx, pod2, ns2)
+	}()
+	readRequest(t, ztunClient)
+	sendAckError(ztunClient, "failed to add")
+	assert.Error(t, <-errChan)
+	assert.Equal(t, ztunnelServer.PodAcked(string(pod2.UID)), false)
+	assert.Equal(t, ztunnelServer.PodAcked(uid), true)
+
+	// a deleted pod is not acked anymore
+	go func() {
+		errChan <- ztunnelServer.PodDeleted(ctx, uid)
+	}()
+	readRequest(t, ztunClient)
+	sendAck(ztunClient)
+	assert.NoError(t, <-errChan)
+	assert.Equal(t, ztunnelServer.PodAcked(uid), false)
+}
+
+func TestZtunnelPodAckedClearedWhenDisconnected(t *testing.T) {
+	setupLogging()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fixture := connect(ctx)
+	defer fixture.podCloser()
+	ztunClient := fixture.ztunClient
+	defer fixture.ztunServer.Close()
+	readRequest(t, ztunClient)
+	sendAck(ztunClient)
+	readRequest(t, ztunClient)
+	sendAck(ztunClient)
+	assert.EventuallyEqual(t, func() bool { return fixture.ztunServer.PodAcked(fixture.uid) }, true)
+
+	// no pod is proxied by ztunnel once it disconnected
+	ztunClient.Close()
+	assert.EventuallyEqual(t, func() bool { return fixture.ztunServer.PodAcked(fixture.uid) }, false)
+}
+
 // podAndNetns returns a ref to the file - Go will close FDs when the File object is GC'd,
 // so to prevent test glitches, we have to hang onto a reference for as long as we might need
 // the FD to remain valid, or there's a risk the FD will be closed underneath us in test due to a GC.
@@ -809,9 +870,13 @@ func readRequest(t *testing.T, c *net.UnixConn) (*zdsapi.WorkloadRequest, []int)
 }
 
 func sendAck(c *net.UnixConn) {
+	sendAckError(c, "")
+}
+
+func sendAckError(c *net.UnixConn, ackErr string) {
 	ack := &zdsapi.WorkloadResponse{
 		Payload: &zdsapi.WorkloadResponse_Ack{
-			Ack: &zdsapi.Ack{},
+			Ack: &zdsapi.Ack{Error: ackErr},
 		},
 	}
 	data, err := proto.Marshal(ack)
@@ -37,6 +37,7 @@ import (
 	"istio.io/api/annotation"
 	"istio.io/api/label"
 	"istio.io/istio/cni/pkg/constants"
+	"istio.io/istio/cni/pkg/podconfig"
 	"istio.io/istio/cni/pkg/util"
 	"istio.io/istio/pkg/file"
 	"istio.io/istio/pkg/log"
@@ -261,7 +262,7 @@ func doAddRun(args *skel.CmdArgs, conf *Config, kClient kubernetes.Interface, ru
 	}
 	// End ambient plugin logic
 
-	pi := &PodInfo{}
+	pi := &podconfig.PodInfo{}
 	var k8sErr error
 	for attempt := 1; attempt <= podRetrievalMaxRetries; attempt++ {
 		pi, k8sErr = getK8sPodInfo(kClient, podName, podNamespace)
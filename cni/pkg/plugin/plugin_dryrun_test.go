@@ -130,7 +130,7 @@ func TestIPTablesRuleGeneration(t *testing.T) {
 			name: "tproxy",
 			annotations: map[string]string{
 				annotation.SidecarStatus.Name:           "true",
-				annotation.SidecarInterceptionMode.Name: redirectModeTPROXY,
+				annotation.SidecarInterceptionMode.Name: "TPROXY",
 			},
 			proxyEnv: []corev1.EnvVar{},
 			golden:   filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/tproxy.txt.golden"),
@@ -164,7 +164,7 @@ func TestIPTablesRuleGeneration(t *testing.T) {
 			name: "custom-uid-tproxy",
 			annotations: map[string]string{
 				annotation.SidecarStatus.Name:           "true",
-				annotation.SidecarInterceptionMode.Name: redirectModeTPROXY,
+				annotation.SidecarInterceptionMode.Name: "TPROXY",
 			},
 			customUID: &customUID,
 			customGID: &customGID,
@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"reflect"
 	"testing"
 
 	"github.com/containernetworking/cni/pkg/skel"
@@ -428,7 +427,7 @@ func TestCmdAddTwoContainersWithStarInboundPort(t *testing.T) {
 	pod.Spec.Containers[0].Name = "mockContainer"
 	pod.Spec.Containers[1].Name = "istio-proxy"
 	pod.ObjectMeta.Annotations[sidecarStatusKey] = "true"
-	pod.ObjectMeta.Annotations[includeInboundPortsKey] = "*"
+	pod.ObjectMeta.Annotations[annotation.SidecarTrafficIncludeInboundPorts.Name] = "*"
 
 	mockIntercept := testDoAddRun(t, buildMockConf(true), testNSName, pod, ns)
 
@@ -447,7 +446,7 @@ func TestCmdAddTwoContainersWithEmptyInboundPort(t *testing.T) {
 	pod.Spec.Containers[0].Name = "mockContainer"
 	pod.Spec.Containers[1].Name = "istio-proxy"
 	pod.ObjectMeta.Annotations[sidecarStatusKey] = "true"
-	pod.ObjectMeta.Annotations[includeInboundPortsKey] = ""
+	pod.ObjectMeta.Annotations[annotation.SidecarTrafficIncludeInboundPorts.Name] = ""
 
 	mockIntercept := testDoAddRun(t, buildMockConf(true), testNSName, pod, ns)
 
@@ -465,7 +464,7 @@ func TestCmdAddTwoContainersWithEmptyExcludeInboundPort(t *testing.T) {
 	pod.Spec.Containers[0].Name = "mockContainer"
 	pod.Spec.Containers[1].Name = "istio-proxy"
 	pod.ObjectMeta.Annotations[sidecarStatusKey] = "true"
-	pod.ObjectMeta.Annotations[excludeInboundPortsKey] = ""
+	pod.ObjectMeta.Annotations[annotation.SidecarTrafficExcludeInboundPorts.Name] = ""
 
 	mockIntercept := testDoAddRun(t, buildMockConf(true), testNSName, pod, ns)
 
@@ -483,7 +482,7 @@ func TestCmdAddTwoContainersWithExplictExcludeInboundPort(t *testing.T) {
 	pod.Spec.Containers[0].Name = "mockContainer"
 	pod.Spec.Containers[1].Name = "istio-proxy"
 	pod.ObjectMeta.Annotations[sidecarStatusKey] = "true"
-	pod.ObjectMeta.Annotations[excludeInboundPortsKey] = "3306"
+	pod.ObjectMeta.Annotations[annotation.SidecarTrafficExcludeInboundPorts.Name] = "3306"
 
 	mockIntercept := testDoAddRun(t, buildMockConf(true), testNSName, pod, ns)
 
@@ -613,47 +612,3 @@ func TestCmdAddEnableDualStack(t *testing.T) {
 		t.Fatalf("expect dualStack is true, actual %v", r.dualStack)
 	}
 }
-
-func Test_dedupPorts(t *testing.T) {
-	type args struct {
-		ports []string
-	}
-	tests := []struct {
-		name string
-		args args
-		want []string
-	}{
-		{
-			name: "No duplicates",
-			args: args{ports: []string{"1234", "2345"}},
-			want: []string{"1234", "2345"},
-		},
-		{
-			name: "Sequential Duplicates",
-			args: args{ports: []string{"1234", "1234", "2345", "2345"}},
-			want: []string{"1234", "2345"},
-		},
-		{
-			name: "Mixed Duplicates",
-			args: args{ports: []string{"1234", "2345", "1234", "2345"}},
-			want: []string{"1234", "2345"},
-		},
-		{
-			name: "Empty",
-			args: args{ports: []string{}},
-			want: []string{},
-		},
-		{
-			name: "Non-parseable",
-			args: args{ports: []string{"abcd", "2345", "abcd"}},
-			want: []string{"abcd", "2345"},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := dedupPorts(tt.args.ports); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("dedupPorts() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/cni/pkg/util"
+	"istio.io/istio/pkg/kube"
+)
+
+// AmbientEnrollment is the ambient node agent, as seen by the repair controller.
+type AmbientEnrollment interface {
+	// IsPodEnrolled returns whether the netns of the pod is enrolled, that is, known to ztunnel.
+	IsPodEnrolled(pod *corev1.Pod) bool
+	// EnrollPod enrolls a running pod again.
+	EnrollPod(pod *corev1.Pod) error
+}
+
+// ambientCheckInterval is the interval between the checks of the ambient pods. A pod marked as captured must
+// also be unknown to ztunnel for this long to be considered broken: the pods removed
+// from the mesh are unmarked only after their netns is released.
+var ambientCheckInterval = time.Minute
+
+// unenrolledPod is a pod found marked as captured by ambient, while its netns was not enrolled.
+type unenrolledPod struct {
+	uid   types.UID
+	since time.Time
+}
+
+// checkAmbientPods periodically queues the pods marked as captured by ambient, so that they are compared with the
+// pods accepted by ztunnel even if they do not change.
+func (c *Controller) checkAmbientPods(stop <-chan struct{}) {
+	ticker := time.NewTicker(ambientCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, pod := range c.pods.List(metav1.NamespaceAll, klabels.Everything()) {
+				if util.PodFullyEnrolled(pod) || util.PodPartiallyEnrolled(pod) {
+					c.queue.AddObject(pod)
+				}
+			}
+		}
+	}
+}
+
+// ambientPodBroken returns whether the pod is marked as captured by ambient, while its netns has not been enrolled
+// for at least ambientCheckInterval, e.g. because it could not be found when the node agent restarted, or because
+// ztunnel rejected it.
+func (c *Controller) ambientPodBroken(pod *corev1.Pod) bool {
+	key := types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}
+	if c.ambient == nil || kube.CheckPodTerminal(pod) ||
+		!(util.PodFullyEnrolled(pod) || util.PodPartiallyEnrolled(pod)) || c.ambient.IsPodEnrolled(pod) {
+		delete(c.unenrolledPods, key)
+		return false
+	}
+	if p, f := c.unenrolledPods[key]; f && p.uid == pod.UID {
+		return time.Since(p.since) >= ambientCheckInterval
+	}
+	c.unenrolledPods[key] = unenrolledPod{uid: pod.UID, since: time.Now()}
+	return false
+}
+
+// enrollBrokenPod enrolls an ambient pod through the node agent.
+func (c *Controller) enrollBrokenPod(pod *corev1.Pod) error {
+	m := podsRepaired.With(typeLabel.Value(enrollType))
+	repairLog.Infof("Ambient pod detected as not enrolled, enrolling: %s/%s", pod.Namespace, pod.Name)
+	if err := c.ambient.EnrollPod(pod); err != nil {
+		c.events.Write(pod, corev1.EventTypeWarning, ReasonEnrollBrokenPod, "pod detected as not enrolled, but failed to enroll: %v", err)
+		m.With(resultLabel.Value(resultFail)).Increment()
+		return err
+	}
+	delete(c.unenrolledPods, types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace})
+	c.events.Write(pod, corev1.EventTypeNormal, ReasonEnrollBrokenPod, "pod detected as not enrolled, enrolled")
+	m.With(resultLabel.Value(resultSuccess)).Increment()
+	return nil
+}
@@ -23,6 +23,7 @@ var (
 	deleteType = "delete"
 	repairType = "repair"
 	labelType  = "label"
+	enrollType = "enroll"
 
 	resultLabel   = monitoring.CreateLabel("result")
 	resultSuccess = "success"
@@ -24,7 +24,8 @@ import (
 
 var repairLog = scopes.CNIAgent
 
-func StartRepair(ctx context.Context, cfg config.RepairConfig) {
+// StartRepair starts the repair controller. The ambient pods are also checked if the ambient node agent is provided.
+func StartRepair(ctx context.Context, cfg config.RepairConfig, ambient AmbientEnrollment) {
 	if !cfg.Enabled {
 		repairLog.Info("CNI repair controller is disabled")
 		return
@@ -36,7 +37,7 @@ func StartRepair(ctx context.Context, cfg config.RepairConfig) {
 		repairLog.Fatalf("CNI repair could not construct clientSet: %s", err)
 	}
 
-	rc, err := NewRepairController(client, cfg)
+	rc, err := NewRepairController(client, cfg, ambient)
 	if err != nil {
 		repairLog.Fatalf("Fatal error constructing repair controller: %+v", err)
 	}
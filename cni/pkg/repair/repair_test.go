@@ -15,8 +15,10 @@
 package repair
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,13 +26,17 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	klabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 
+	"istio.io/api/annotation"
 	"istio.io/istio/cni/pkg/config"
+	pconstants "istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/monitoring/monitortest"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/tools/istio-iptables/pkg/constants"
 )
 
@@ -287,7 +293,7 @@ func TestLabelPods(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mt := monitortest.New(t)
 			tt.config.LabelPods = true
-			c, err := NewRepairController(tt.client, tt.config)
+			c, err := NewRepairController(tt.client, tt.config, nil)
 			assert.NoError(t, err)
 			t.Cleanup(func() {
 				assert.NoError(t, c.queue.WaitForClose(time.Second))
@@ -351,7 +357,7 @@ func TestDeletePods(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mt := monitortest.New(t)
 			tt.config.DeletePods = true
-			c, err := NewRepairController(tt.client, tt.config)
+			c, err := NewRepairController(tt.client, tt.config, nil)
 			assert.NoError(t, err)
 			t.Cleanup(func() {
 				assert.NoError(t, c.queue.WaitForClose(time.Second))
@@ -374,3 +380,174 @@ func TestDeletePods(t *testing.T) {
 		})
 	}
 }
+
+func TestAmbientPodBroken(t *testing.T) {
+	enrolledPod := makeAmbientPod("enrolled", "enrolled-uid")
+	notEnrolledPod := makeAmbientPod("unenrolled", "unenrolled-uid")
+	c := &Controller{
+		ambient:        &fakeAmbient{enrolled: sets.New[types.UID](enrolledPod.UID)},
+		unenrolledPods: map[types.NamespacedName]unenrolledPod{},
+	}
+
+	assert.Equal(t, c.ambientPodBroken(enrolledPod), false)
+	assert.Equal(t, c.ambientPodBroken(workingPod), false)
+
+	// The pod is only broken once it has not been enrolled for a whole check interval.
+	assert.Equal(t, c.ambientPodBroken(notEnrolledPod), false)
+	key := types.NamespacedName{Name: notEnrolledPod.Name, Namespace: notEnrolledPod.Namespace}
+	c.unenrolledPods[key] = unenrolledPod{uid: notEnrolledPod.UID, since: time.Now().Add(-ambientCheckInterval)}
+	assert.Equal(t, c.ambientPodBroken(notEnrolledPod), true)
+
+	// A new pod with the same name is checked again from scratch.
+	recreatedPod := makeAmbientPod("unenrolled", "recreated-uid")
+	assert.Equal(t, c.ambientPodBroken(recreatedPod), false)
+
+	// Terminated pods are released by the node agent.
+	terminatedPod := notEnrolledPod.DeepCopy()
+	terminatedPod.Status.Phase = corev1.PodSucceeded
+	c.unenrolledPods[key] = unenrolledPod{uid: notEnrolledPod.UID, since: time.Now().Add(-ambientCheckInterval)}
+	assert.Equal(t, c.ambientPodBroken(terminatedPod), false)
+
+	// Ambient pods are not checked if ambient is not enabled.
+	assert.Equal(t, (&Controller{}).ambientPodBroken(notEnrolledPod), false)
+}
+
+func TestEnrollAmbientPods(t *testing.T) {
+	test.SetForTest(t, &ambientCheckInterval, 10*time.Millisecond)
+	mt := monitortest.New(t)
+	unenrolledPod := makeAmbientPod("unenrolled", "unenrolled-uid")
+	client := fakeClient(workingPod, unenrolledPod)
+	ambient := &fakeAmbient{enrolled: sets.New[types.UID]()}
+	c, err := NewRepairController(client, config.RepairConfig{RepairPods: true}, ambient)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.queue.WaitForClose(time.Second))
+	})
+	stop := test.NewStop(t)
+	client.RunAndWait(stop)
+	go c.Run(stop)
+
+	assert.EventuallyEqual(t, func() bool {
+		return ambient.IsPodEnrolled(unenrolledPod)
+	}, true)
+	mt.Assert(podsRepaired.Name(), map[string]string{"result": resultSuccess, "type": enrollType}, monitortest.Exactly(1))
+}
+
+func TestLabelAmbientPods(t *testing.T) {
+	test.SetForTest(t, &ambientCheckInterval, 10*time.Millisecond)
+	unenrolledPod := makeAmbientPod("unenrolled", "unenrolled-uid")
+	client := fakeClient(workingPod, brokenPodWaiting, unenrolledPod)
+	cfg := config.RepairConfig{
+		InitContainerName:  constants.ValidationContainerName,
+		InitExitCode:       126,
+		InitTerminationMsg: "Died for some reason",
+		LabelPods:          true,
+		LabelKey:           "testkey",
+		LabelValue:         "testval",
+	}
+	c, err := NewRepairController(client, cfg, &fakeAmbient{enrolled: sets.New[types.UID]()})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.queue.WaitForClose(time.Second))
+	})
+	stop := test.NewStop(t)
+	client.RunAndWait(stop)
+	go c.Run(stop)
+
+	// The unenrolled ambient pod gets its own label, not the one of the sidecar pods whose init failed.
+	assert.EventuallyEqual(t, func() map[string]string {
+		havePods := c.pods.List(metav1.NamespaceAll, klabels.Everything())
+		slices.SortBy(havePods, func(a *corev1.Pod) string {
+			return a.Name
+		})
+		return makePodLabelMap(havePods)
+	}, map[string]string{
+		workingPod.Name:       "",
+		brokenPodWaiting.Name: "testkey=testval",
+		unenrolledPod.Name:    AmbientLabelKey + "=" + AmbientLabelValue,
+	})
+}
+
+func TestReportOnly(t *testing.T) {
+	test.SetForTest(t, &ambientCheckInterval, 10*time.Millisecond)
+	enrolledPod := makeAmbientPod("enrolled", "enrolled-uid")
+	unenrolledPod := makeAmbientPod("unenrolled", "unenrolled-uid")
+	client := fakeClient(workingPod, workingPodDiedPreviously, brokenPodWaiting, enrolledPod, unenrolledPod)
+	ambient := &fakeAmbient{enrolled: sets.New[types.UID](enrolledPod.UID)}
+	cfg := config.RepairConfig{
+		NodeName:           "test-node",
+		InitContainerName:  constants.ValidationContainerName,
+		InitExitCode:       126,
+		InitTerminationMsg: "Died for some reason",
+		// The pods are only reported, even if the controller is configured to act on them.
+		DeletePods:      true,
+		RepairPods:      true,
+		ReportOnly:      true,
+		ReportNamespace: "istio-system",
+	}
+	c, err := NewRepairController(client, cfg, ambient)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.queue.WaitForClose(time.Second))
+	})
+	stop := test.NewStop(t)
+	client.RunAndWait(stop)
+	go c.Run(stop)
+
+	report := func() map[string]string {
+		cm, err := client.Kube().CoreV1().ConfigMaps("istio-system").Get(context.Background(), "istio-cni-repair-test-node", metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+		return cm.Data
+	}
+	assert.EventuallyEqual(t, report, map[string]string{
+		"node":                       "test-node",
+		"brokenPods":                 "2",
+		"ambientCheck":               ambientCheckDescription,
+		"default.broken-pod-waiting": brokenSidecar,
+		"default.unenrolled":         brokenAmbient,
+	})
+	assert.Equal(t, ambient.IsPodEnrolled(unenrolledPod), false)
+	assert.Equal(t, len(c.pods.List(metav1.NamespaceAll, klabels.Everything())), 5)
+
+	// Deleted pods are removed from the report.
+	assert.NoError(t, client.Kube().CoreV1().Pods("default").Delete(context.Background(), unenrolledPod.Name, metav1.DeleteOptions{}))
+	assert.EventuallyEqual(t, report, map[string]string{
+		"node":                       "test-node",
+		"brokenPods":                 "1",
+		"ambientCheck":               ambientCheckDescription,
+		"default.broken-pod-waiting": brokenSidecar,
+	})
+}
+
+// fakeAmbient is a fake ambient node agent, which enrolls the pods in memory.
+type fakeAmbient struct {
+	mu       sync.Mutex
+	enrolled sets.Set[types.UID]
+}
+
+func (f *fakeAmbient) IsPodEnrolled(pod *corev1.Pod) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.enrolled.Contains(pod.UID)
+}
+
+func (f *fakeAmbient) EnrollPod(pod *corev1.Pod) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enrolled.Insert(pod.UID)
+	return nil
+}
+
+func makeAmbientPod(name string, uid types.UID) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			UID:         uid,
+			Annotations: map[string]string{annotation.AmbientRedirection.Name: pconstants.AmbientRedirectionEnabled},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+	}
+}
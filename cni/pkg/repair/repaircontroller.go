@@ -25,6 +25,7 @@ import (
 
 	"istio.io/istio/cni/pkg/config"
 	"istio.io/istio/cni/pkg/plugin"
+	"istio.io/istio/cni/pkg/podconfig"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
@@ -37,14 +38,25 @@ type Controller struct {
 	cfg          config.RepairConfig
 	events       kclient.EventRecorder
 	repairedPods map[types.NamespacedName]types.UID
+
+	// ambient is nil if ambient is not enabled, in which case only the sidecar pods are checked.
+	ambient        AmbientEnrollment
+	unenrolledPods map[types.NamespacedName]unenrolledPod
+
+	// brokenPods are the reasons for which the pods are broken, in report-only mode.
+	brokenPods    map[types.NamespacedName]string
+	reportWritten bool
 }
 
-func NewRepairController(client kube.Client, cfg config.RepairConfig) (*Controller, error) {
+func NewRepairController(client kube.Client, cfg config.RepairConfig, ambient AmbientEnrollment) (*Controller, error) {
 	c := &Controller{
-		cfg:          cfg,
-		client:       client,
-		events:       kclient.NewEventRecorder(client, "cni-repair"),
-		repairedPods: map[types.NamespacedName]types.UID{},
+		cfg:            cfg,
+		client:         client,
+		events:         kclient.NewEventRecorder(client, "cni-repair"),
+		repairedPods:   map[types.NamespacedName]types.UID{},
+		ambient:        ambient,
+		unenrolledPods: map[types.NamespacedName]unenrolledPod{},
+		brokenPods:     map[types.NamespacedName]string{},
 	}
 	fieldSelectors := []string{}
 	if cfg.FieldSelectors != "" {
@@ -66,6 +78,9 @@ func NewRepairController(client kube.Client, cfg config.RepairConfig) (*Controll
 
 func (c *Controller) Run(stop <-chan struct{}) {
 	kube.WaitForCacheSync("repair controller", stop, c.pods.HasSynced)
+	if c.ambient != nil {
+		go c.checkAmbientPods(stop)
+	}
 	c.queue.Run(stop)
 	c.pods.ShutdownHandlers()
 }
@@ -74,6 +89,10 @@ func (c *Controller) Reconcile(key types.NamespacedName) error {
 	pod := c.pods.Get(key.Name, key.Namespace)
 	if pod == nil {
 		delete(c.repairedPods, key) // Ensure we do not leak
+		delete(c.unenrolledPods, key)
+		if c.cfg.ReportOnly {
+			return c.reportBrokenPod(key, "")
+		}
 		// Pod deleted, nothing to do
 		return nil
 	}
@@ -81,24 +100,40 @@ func (c *Controller) Reconcile(key types.NamespacedName) error {
 }
 
 func (c *Controller) ReconcilePod(pod *corev1.Pod) (err error) {
-	if !c.matchesFilter(pod) {
+	reason := ""
+	if c.matchesFilter(pod) {
+		reason = brokenSidecar
+	} else if c.ambientPodBroken(pod) {
+		reason = brokenAmbient
+	}
+	if c.cfg.ReportOnly {
+		// Only report the broken pods, e.g. to audit them before acting on them.
+		return c.reportBrokenPod(types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, reason)
+	}
+	if reason == "" {
 		return // Skip, pod doesn't need repair
 	}
 	repairLog.Debugf("Reconciling pod %s", pod.Name)
 
 	if c.cfg.RepairPods {
+		if reason == brokenAmbient {
+			return c.enrollBrokenPod(pod)
+		}
 		return c.repairPod(pod)
 	} else if c.cfg.DeletePods {
 		return c.deleteBrokenPod(pod)
 	} else if c.cfg.LabelPods {
-		return c.labelBrokenPod(pod)
+		if reason == brokenAmbient {
+			return c.labelBrokenPod(pod, AmbientLabelKey, AmbientLabelValue)
+		}
+		return c.labelBrokenPod(pod, c.cfg.LabelKey, c.cfg.LabelValue)
 	}
 	return nil
 }
 
 // redirectRunningPod dynamically enters the provided pod, that is already running, and programs it's networking configuration.
 func redirectRunningPod(pod *corev1.Pod, netns string) error {
-	pi := plugin.ExtractPodInfo(pod)
+	pi := podconfig.ExtractPodInfo(pod)
 	redirect, err := plugin.NewRedirect(pi)
 	if err != nil {
 		return fmt.Errorf("setup redirect: %v", err)
@@ -113,7 +148,7 @@ func redirectRunningPod(pod *corev1.Pod, netns string) error {
 // redirectRunningPodNFT dynamically enters the provided pod, that is already running,
 // and programs it's networking configuration using nftables rules.
 func redirectRunningPodNFT(pod *corev1.Pod, netns string) error {
-	pi := plugin.ExtractPodInfo(pod)
+	pi := podconfig.ExtractPodInfo(pod)
 	redirect, err := plugin.NewRedirect(pi)
 	if err != nil {
 		return fmt.Errorf("setup redirect: %v", err)
@@ -128,6 +163,14 @@ func redirectRunningPodNFT(pod *corev1.Pod, netns string) error {
 const (
 	ReasonDeleteBrokenPod = "DeleteBrokenPod"
 	ReasonLabelBrokenPod  = "LabelBrokenPod"
+	ReasonEnrollBrokenPod = "EnrollBrokenPod"
+)
+
+// AmbientLabelKey and AmbientLabelValue label the ambient pods detected as not enrolled, which are broken for another
+// reason than the sidecar pods labeled with the configured label, and are not fixed the same way.
+const (
+	AmbientLabelKey   = "cni.istio.io/ambient-unenrolled"
+	AmbientLabelValue = "true"
 )
 
 func (c *Controller) deleteBrokenPod(pod *corev1.Pod) error {
@@ -152,21 +195,21 @@ func (c *Controller) deleteBrokenPod(pod *corev1.Pod) error {
 	return nil
 }
 
-func (c *Controller) labelBrokenPod(pod *corev1.Pod) error {
+func (c *Controller) labelBrokenPod(pod *corev1.Pod, key, value string) error {
 	// Added for safety, to make sure no healthy pods get labeled.
 	m := podsRepaired.With(typeLabel.Value(labelType))
 	repairLog.Infof("Pod detected as broken, adding label: %s/%s", pod.Namespace, pod.Name)
 
 	labels := pod.GetLabels()
-	if _, ok := labels[c.cfg.LabelKey]; ok {
+	if _, ok := labels[key]; ok {
 		m.With(resultLabel.Value(resultSkip)).Increment()
-		repairLog.Infof("Pod %s/%s already has label with key %s, skipping", pod.Namespace, pod.Name, c.cfg.LabelKey)
+		repairLog.Infof("Pod %s/%s already has label with key %s, skipping", pod.Namespace, pod.Name, key)
 		return nil
 	}
 
-	repairLog.Infof("Labeling pod %s/%s with label %s=%s", pod.Namespace, pod.Name, c.cfg.LabelKey, c.cfg.LabelValue)
+	repairLog.Infof("Labeling pod %s/%s with label %s=%s", pod.Namespace, pod.Name, key, value)
 
-	patchBytes := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, c.cfg.LabelKey, c.cfg.LabelValue)
+	patchBytes := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, key, value)
 	// Both "pods" and "pods/status" can mutate the metadata. However, pods/status is lower privilege, so we use that instead.
 	_, err := c.client.Kube().CoreV1().Pods(pod.Namespace).Patch(context.Background(), pod.Name, types.MergePatchType,
 		[]byte(patchBytes), metav1.PatchOptions{}, "status")
@@ -0,0 +1,110 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pkg/maps"
+)
+
+// Reasons for which a pod is reported as broken.
+const (
+	brokenSidecar = "SidecarInitFailed"
+	brokenAmbient = "AmbientNotEnrolled"
+)
+
+const (
+	// reportNodeKey is the key of the name of the node in the report.
+	reportNodeKey = "node"
+	// reportCountKey is the key of the number of broken pods in the report.
+	reportCountKey = "brokenPods"
+	// reportAmbientCheckKey is the key of the description of the check of the ambient pods in the report.
+	reportAmbientCheckKey = "ambientCheck"
+)
+
+// ambientCheckDescription states what the ambient pods reported as broken are compared with.
+const ambientCheckDescription = "Ambient pods are reported as " + brokenAmbient + " if they are missing from the " +
+	"snapshot of the Istio CNI node agent, or if ztunnel did not acknowledge them without error when they were sent to it."
+
+// reportConfigMapName returns the name of the ConfigMap reporting the broken pods of the node.
+func reportConfigMapName(node string) string {
+	return "istio-cni-repair-" + node
+}
+
+// reportBrokenPod records the reason for which the pod is broken, or removes the pod from the report if the reason
+// is empty, and writes the report if it changed.
+func (c *Controller) reportBrokenPod(key types.NamespacedName, reason string) error {
+	if reason == "" {
+		if _, f := c.brokenPods[key]; !f && c.reportWritten {
+			return nil
+		}
+		delete(c.brokenPods, key)
+	} else {
+		if c.brokenPods[key] == reason && c.reportWritten {
+			return nil
+		}
+		repairLog.Infof("Pod detected as broken (%s), reporting: %s/%s", reason, key.Namespace, key.Name)
+		c.brokenPods[key] = reason
+	}
+	if err := c.writeReport(); err != nil {
+		return fmt.Errorf("failed to write the report of the broken pods: %v", err)
+	}
+	c.reportWritten = true
+	return nil
+}
+
+// writeReport writes the per-node summary ConfigMap. Each broken pod is reported with a `<namespace>.<name>` key,
+// which is unambiguous as namespaces cannot contain dots, and the reason for which it is broken as value.
+func (c *Controller) writeReport() error {
+	data := map[string]string{
+		reportNodeKey:  c.cfg.NodeName,
+		reportCountKey: strconv.Itoa(len(c.brokenPods)),
+	}
+	if c.ambient != nil {
+		data[reportAmbientCheckKey] = ambientCheckDescription
+	}
+	for key, reason := range c.brokenPods {
+		data[key.Namespace+"."+key.Name] = reason
+	}
+
+	configMaps := c.client.Kube().CoreV1().ConfigMaps(c.cfg.ReportNamespace)
+	name := reportConfigMapName(c.cfg.NodeName)
+	cm, err := configMaps.Get(context.Background(), name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err = configMaps.Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.cfg.ReportNamespace},
+			Data:       data,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if maps.Equal(cm.Data, data) {
+		return nil
+	}
+	cm = cm.DeepCopy()
+	cm.Data = data
+	_, err = configMaps.Update(context.Background(), cm, metav1.UpdateOptions{})
+	return err
+}
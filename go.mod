@@ -46,6 +46,7 @@ require (
 	github.com/lestrrat-go/jwx v1.2.31
 	github.com/mattn/go-isatty v0.0.20
 	github.com/miekg/dns v1.1.68
+	github.com/miekg/pkcs11 v1.1.2
 	github.com/mitchellh/copystructure v1.2.0
 	github.com/moby/buildkit v0.23.2
 	github.com/onsi/gomega v1.38.0
@@ -36,6 +36,7 @@ import (
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/analysis"
 	"istio.io/istio/pkg/config/analysis/analyzers"
+	"istio.io/istio/pkg/config/analysis/analyzers/plugin"
 	"istio.io/istio/pkg/config/analysis/diag"
 	"istio.io/istio/pkg/config/analysis/local"
 	"istio.io/istio/pkg/config/analysis/msg"
@@ -44,6 +45,7 @@ import (
 	"istio.io/istio/pkg/kube/multicluster"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/url"
+	"istio.io/istio/pkg/util/sets"
 )
 
 // AnalyzerFoundIssuesError indicates that at least one analyzer found problems.
@@ -82,6 +84,7 @@ var (
 	revisionSpecified string
 	remoteContexts    []string
 	selectedAnalyzers []string
+	analyzerFiles     []string
 
 	fileExtensions = []string{".json", ".yaml", ".yml"}
 )
@@ -113,6 +116,12 @@ func Analyze(ctx cli.Context) *cobra.Command {
   # and suppress MisplacedAnnotation on deployment foobar in namespace default.
   istioctl analyze -S "IST0103=Pod *.testing" -S "IST0107=Deployment foobar.default"
 
+  # Analyze files and report the findings as SARIF, for code review tools to annotate pull requests
+  istioctl analyze --use-kube=false -o sarif a.yaml b.yaml > analyze.sarif
+
+  # Analyze the current live cluster, also running the analyzers defined in a file
+  istioctl analyze --analyzer-file my-analyzers.yaml
+
   # List available analyzers
   istioctl analyze -L
   
@@ -127,8 +136,13 @@ func Analyze(ctx cli.Context) *cobra.Command {
 				}
 			}
 
+			allAnalyzers, customMessageTypes, err := loadAnalyzers(analyzerFiles)
+			if err != nil {
+				return err
+			}
+
 			if listAnalyzers {
-				fmt.Print(AnalyzersAsString(analyzers.All()))
+				fmt.Print(AnalyzersAsString(allAnalyzers))
 				return nil
 			}
 
@@ -172,9 +186,9 @@ func Analyze(ctx cli.Context) *cobra.Command {
 				selectedNamespace = metav1.NamespaceDefault
 			}
 
-			combinedAnalyzers := analyzers.AllCombined()
+			combinedAnalyzers := analysis.Combine("all", allAnalyzers...)
 			if len(selectedAnalyzers) != 0 {
-				combinedAnalyzers = analyzers.NamedCombined(selectedAnalyzers...)
+				combinedAnalyzers = analyzers.SelectedCombined(allAnalyzers, selectedAnalyzers...)
 			}
 
 			sa := local.NewIstiodAnalyzer(combinedAnalyzers,
@@ -191,7 +205,7 @@ func Analyze(ctx cli.Context) *cobra.Command {
 				// Check to see if the supplied code is valid. If not, emit a
 				// warning but continue.
 				codeIsValid := false
-				for _, at := range msg.All() {
+				for _, at := range append(msg.All(), customMessageTypes...) {
 					if at.Code() == parts[0] {
 						codeIsValid = true
 						break
@@ -371,9 +385,37 @@ func Analyze(ctx cli.Context) *cobra.Command {
 	analysisCmd.PersistentFlags().StringArrayVarP(&selectedAnalyzers, "analyzer", "", []string{},
 		"Select specific analyzers to run. Can be repeated. If not specified, all analyzers are run. "+
 			"(e.g. istioctl analyze --analyzer \"gateway.ConflictingGatewayAnalyzer\")")
+	analysisCmd.PersistentFlags().StringArrayVar(&analyzerFiles, "analyzer-file", []string{},
+		"File defining additional analyzers as CEL expressions, which are run along with the built-in analyzers. Can be repeated.")
 	return analysisCmd
 }
 
+// loadAnalyzers returns the built-in analyzers and the analyzers defined in the files, along with the message types
+// reported by the latter.
+func loadAnalyzers(files []string) ([]analysis.Analyzer, []*diag.MessageType, error) {
+	all := analyzers.All()
+	if len(files) == 0 {
+		return all, nil, nil
+	}
+	custom, err := plugin.LoadFiles(files...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load analyzers: %v", err)
+	}
+	builtin := sets.New[string]()
+	for _, a := range all {
+		builtin.Insert(a.Metadata().Name)
+	}
+	messageTypes := make([]*diag.MessageType, 0, len(custom))
+	for _, a := range custom {
+		if builtin.Contains(a.Metadata().Name) {
+			return nil, nil, fmt.Errorf("failed to load analyzers: %s is the name of a built-in analyzer", a.Metadata().Name)
+		}
+		all = append(all, a)
+		messageTypes = append(messageTypes, a.MessageType())
+	}
+	return all, messageTypes, nil
+}
+
 func gatherFiles(cmd *cobra.Command, args []string) ([]local.ReaderSource, error) {
 	var readers []local.ReaderSource
 	for _, f := range args {
@@ -381,7 +423,7 @@ func gatherFiles(cmd *cobra.Command, args []string) ([]local.ReaderSource, error
 
 		// Handle "-" as stdin as a special case.
 		if f == "-" {
-			if isatty.IsTerminal(os.Stdin.Fd()) && !isJSONorYAMLOutputFormat() {
+			if isatty.IsTerminal(os.Stdin.Fd()) && !isStructuredOutputFormat() {
 				fmt.Fprint(cmd.OutOrStdout(), "Reading from stdin:\n")
 			}
 			r = os.Stdin
@@ -515,8 +557,8 @@ func analyzeTargetAsString() string {
 }
 
 // TODO: Refactor output writer so that it is smart enough to know when to output what.
-func isJSONorYAMLOutputFormat() bool {
-	return msgOutputFormat == formatting.JSONFormat || msgOutputFormat == formatting.YAMLFormat
+func isStructuredOutputFormat() bool {
+	return msgOutputFormat != formatting.LogFormat
 }
 
 type Client struct {
@@ -15,6 +15,8 @@
 package analyze
 
 import (
+	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -123,3 +125,81 @@ func TestRunSpecificAnalyzer(t *testing.T) {
 		})
 	}
 }
+
+func TestSARIFOutputLocations(t *testing.T) {
+	g := NewWithT(t)
+
+	var out bytes.Buffer
+	analyze := Analyze(cli.NewFakeContext(&cli.NewFakeContextOption{IstioNamespace: "istio-system"}))
+	analyze.SetArgs(strings.Split("--use-kube=false -o sarif testdata/analyze-file/specific-analyzer.yaml", " "))
+	analyze.SetOut(&out)
+	analyze.SetErr(&bytes.Buffer{})
+	g.Expect(analyze.Execute()).To(Succeed())
+
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	g.Expect(json.Unmarshal(out.Bytes(), &log)).To(Succeed())
+	g.Expect(log.Runs).To(HaveLen(1))
+	g.Expect(log.Runs[0].Results).NotTo(BeEmpty())
+	for _, r := range log.Runs[0].Results {
+		g.Expect(r.Locations).To(HaveLen(1))
+		loc := r.Locations[0].PhysicalLocation
+		g.Expect(loc.ArtifactLocation.URI).To(Equal("testdata/analyze-file/specific-analyzer.yaml"))
+		g.Expect(loc.Region.StartLine).To(BeNumerically(">", 0))
+	}
+}
+
+func TestAnalyzerFile(t *testing.T) {
+	run := func(args string) (string, string, error) {
+		var out, errOut bytes.Buffer
+		analyze := Analyze(cli.NewFakeContext(&cli.NewFakeContextOption{IstioNamespace: "istio-system"}))
+		analyze.SetArgs(strings.Split(args, " "))
+		analyze.SetOut(&out)
+		analyze.SetErr(&errOut)
+		err := analyze.Execute()
+		return out.String(), errOut.String(), err
+	}
+
+	t.Run("reports custom messages", func(t *testing.T) {
+		g := NewWithT(t)
+		out, _, err := run("--use-kube=false -A -o json --analyzer-file testdata/analyzer-file/analyzers.yaml " +
+			"--analyzer org.GatewayWildcardHost testdata/analyze-file/public-gateway.yaml")
+		g.Expect(err).NotTo(HaveOccurred())
+		var messages []map[string]any
+		g.Expect(json.Unmarshal([]byte(out), &messages)).To(Succeed())
+		g.Expect(messages).To(HaveLen(1))
+		g.Expect(messages[0]["code"]).To(Equal("ORG0001"))
+		g.Expect(messages[0]["level"]).To(Equal("Error"))
+		g.Expect(messages[0]["message"]).To(Equal("Gateway public-gateway must not expose wildcard hosts"))
+	})
+
+	t.Run("suppresses custom codes", func(t *testing.T) {
+		g := NewWithT(t)
+		_, errOut, err := run("--use-kube=false -A --analyzer-file testdata/analyzer-file/analyzers.yaml " +
+			"--analyzer org.GatewayWildcardHost -S ORG0001=Gateway* testdata/analyze-file/public-gateway.yaml")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(errOut).NotTo(ContainSubstring("unknown message code"))
+	})
+
+	t.Run("rejects built-in analyzer names", func(t *testing.T) {
+		g := NewWithT(t)
+		_, _, err := run("--use-kube=false --analyzer-file testdata/analyzer-file/builtin-name.yaml " +
+			"testdata/analyze-file/public-gateway.yaml")
+		g.Expect(err).To(MatchError(ContainSubstring("gateway.ConflictingGatewayAnalyzer is the name of a built-in analyzer")))
+	})
+}
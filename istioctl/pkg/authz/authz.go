@@ -37,6 +37,7 @@ var (
 )
 
 func checkCmd(ctx cli.Context) *cobra.Command {
+	req := &requestArgs{}
 	cmd := &cobra.Command{
 		Use:   "check [<type>/]<name>[.<namespace>]",
 		Short: "Check AuthorizationPolicy applied in the pod.",
@@ -45,7 +46,16 @@ the Envoy configuration of the pod. The command is especially useful for inspect
 the policy propagation from Istiod to Envoy and the final AuthorizationPolicy list merged
 from multiple sources (mesh-level, namespace-level and workload-level).
 
-The command also supports reading from a standalone config dump file with flag -f.`,
+The command also supports reading from a standalone config dump file with flag -f.
+
+If any of the request flags (--source-principal, --source-namespace, --source-ip, --method, --path,
+--host, --port, --header or --claims) is set, the command instead evaluates whether the described
+HTTP request to the pod would be allowed. The AuthorizationPolicies applied to the pod are
+converted to the RBAC filters the proxy would enforce, and the matching policy and rule of each
+action is reported. With --policy-file and --labels, the request is evaluated offline, against
+the policies in the files and a workload with the given labels in the namespace set with -n.
+Policies in the rootNamespace of the mesh config apply to every workload; offline, the mesh config
+is read from --meshConfigFile.`,
 		Example: `  # Check AuthorizationPolicy applied to pod httpbin-88ddbcfdd-nt5jb:
   istioctl x authz check httpbin-88ddbcfdd-nt5jb
 
@@ -53,7 +63,14 @@ The command also supports reading from a standalone config dump file with flag -
   istioctl x authz check deployment/productpage-v1
 
   # Check AuthorizationPolicy from Envoy config dump file:
-  istioctl x authz check -f httpbin_config_dump.json`,
+  istioctl x authz check -f httpbin_config_dump.json
+
+  # Check whether a GET request from the sleep service account would be allowed by pod httpbin-88ddbcfdd-nt5jb:
+  istioctl x authz check httpbin-88ddbcfdd-nt5jb --source-principal cluster.local/ns/default/sa/sleep --method GET --path /headers
+
+  # Check a request with a JWT against the policies in a file, for a workload labelled app=httpbin in namespace foo:
+  istioctl x authz check --policy-file policies.yaml --labels app=httpbin -n foo \
+    --method POST --path /admin --claims '{"iss":"https://issuer.example.com","sub":"alice","groups":["admin"]}'`,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) > 1 {
 				cmd.Println(cmd.UsageString())
@@ -62,6 +79,12 @@ The command also supports reading from a standalone config dump file with flag -
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if req.isSet(cmd) {
+				if configDumpFile != "" {
+					return fmt.Errorf("evaluating a request requires AuthorizationPolicies, use --policy-file instead of --file")
+				}
+				return req.run(ctx, cmd, args)
+			}
 			kubeClient, err := ctx.CLIClient()
 			if err != nil {
 				return fmt.Errorf("failed to create k8s client: %w", err)
@@ -97,6 +120,7 @@ The command also supports reading from a standalone config dump file with flag -
 	cmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
 		"The json file with Envoy config dump to be checked")
 	cmd.PersistentFlags().IntVar(&proxyAdminPort, "proxy-admin-port", util.DefaultProxyAdminPort, "Envoy proxy admin port")
+	req.addFlags(cmd)
 	return cmd
 }
 
@@ -0,0 +1,581 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbachttp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	uri_template "github.com/envoyproxy/go-control-plane/envoy/extensions/path/match/uri_template/v3"
+	matcherpb "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/authz/builder"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// Request describes the attributes of a request, as seen by the RBAC filter of the destination workload.
+type Request struct {
+	// Principal is the peer identity of the source, e.g. cluster.local/ns/default/sa/sleep.
+	// It is empty for plaintext requests.
+	Principal string
+	// SourceIP is both the direct remote IP and the remote IP of the request.
+	SourceIP        string
+	DestinationIP   string
+	DestinationPort uint32
+	Method          string
+	// Path of the request, including any query string.
+	Path string
+	Host string
+	// Headers are keyed by lower case header name. Repeated headers are joined with ",".
+	Headers map[string]string
+	// Claims is the payload of a validated JWT. It is nil if the request has no JWT.
+	Claims map[string]any
+}
+
+// header returns the value of a request header, including the pseudo headers the RBAC filter matches on.
+func (r *Request) header(name string) (string, bool) {
+	switch name = strings.ToLower(name); name {
+	case ":method":
+		return r.Method, r.Method != ""
+	case ":path":
+		return r.Path, r.Path != ""
+	case ":authority", "host":
+		return r.Host, r.Host != ""
+	}
+	v, ok := r.Headers[name]
+	return v, ok
+}
+
+// urlPath returns the path without query string and fragment, which is what the RBAC filter matches paths against.
+func (r *Request) urlPath() string {
+	p, _, _ := strings.Cut(r.Path, "?")
+	p, _, _ = strings.Cut(p, "#")
+	return p
+}
+
+func (r *Request) principalName() string {
+	if r.Principal == "" {
+		return ""
+	}
+	if strings.HasPrefix(r.Principal, spiffe.URIPrefix) {
+		return r.Principal
+	}
+	return spiffe.URIPrefix + r.Principal
+}
+
+// RuleResult is the result of evaluating a single rule of an AuthorizationPolicy against a request.
+type RuleResult struct {
+	Action policyAction `json:"action"`
+	// Policy is the name of the policy, as <name>.<namespace>.
+	Policy string `json:"policy"`
+	Rule   string `json:"rule"`
+	// DryRun is set for policies with the istio.io/dry-run annotation, which never affect the decision.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Provider is the extension provider of a CUSTOM policy.
+	Provider string `json:"provider,omitempty"`
+	Matched  bool   `json:"matched"`
+}
+
+// Decision is the result of evaluating a request against the AuthorizationPolicies applied to a workload.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// Providers are the extension providers the request is sent to by matching CUSTOM policies. If set, the request
+	// is only allowed if all the providers allow it.
+	Providers []string `json:"providers,omitempty"`
+	// Rules are all the evaluated rules, in the order Envoy evaluates them.
+	Rules []RuleResult `json:"rules"`
+}
+
+// Evaluate evaluates a request against the HTTP RBAC filters built for the given policies, following the order Envoy
+// enforces them in: CUSTOM, AUDIT, DENY and then ALLOW.
+func Evaluate(trustDomain trustdomain.Bundle, policies model.AuthorizationPoliciesResult, r *Request) (*Decision, error) {
+	var rbacFilters []*rbachttp.RBAC
+	// The CUSTOM rules are built as DENY rules: this generates the same rules as the CUSTOM builder, without requiring
+	// the extension provider to be resolvable offline.
+	custom := builder.New(trustDomain, nil, model.AuthorizationPoliciesResult{Deny: policies.Custom}, builder.Option{})
+	others := builder.New(trustDomain, nil, model.AuthorizationPoliciesResult{
+		Deny:  policies.Deny,
+		Allow: policies.Allow,
+		Audit: policies.Audit,
+	}, builder.Option{})
+	customFilters := 0
+	for i, b := range []*builder.Builder{custom, others} {
+		if b == nil {
+			continue
+		}
+		for _, f := range b.BuildHTTP() {
+			rbac := &rbachttp.RBAC{}
+			if err := getHTTPFilterConfig(f, rbac); err != nil {
+				return nil, fmt.Errorf("failed to parse RBAC filter: %v", err)
+			}
+			rbacFilters = append(rbacFilters, rbac)
+			if i == 0 {
+				customFilters++
+			}
+		}
+	}
+
+	providers := map[string]string{}
+	for _, p := range policies.Custom {
+		providers[fmt.Sprintf("%s.%s", p.Name, p.Namespace)] = p.Spec.GetProvider().GetName()
+	}
+
+	d := &Decision{}
+	var denied, allowed *RuleResult
+	hasAllow := false
+	for i, f := range rbacFilters {
+		for _, rules := range []*rbacpb.RBAC{f.GetRules(), f.GetShadowRules()} {
+			if rules == nil {
+				continue
+			}
+			dryRun := rules == f.GetShadowRules()
+			action := policyAction(rules.GetAction().String())
+			switch {
+			case i < customFilters:
+				action = policyActionCustom
+			case rules.GetAction() == rbacpb.RBAC_LOG:
+				action = policyActionAudit
+			case rules.GetAction() == rbacpb.RBAC_ALLOW && !dryRun:
+				hasAllow = true
+			}
+			results, err := evaluateRules(rules, action, dryRun, r)
+			if err != nil {
+				return nil, err
+			}
+			for i := range results {
+				res := &results[i]
+				if action == policyActionCustom {
+					res.Provider = providers[res.Policy]
+				}
+				if !res.Matched || res.DryRun {
+					continue
+				}
+				switch action {
+				case policyActionCustom:
+					d.Providers = append(d.Providers, res.Provider)
+				case policyActionDeny:
+					if denied == nil {
+						denied = res
+					}
+				case policyActionAllow:
+					if allowed == nil {
+						allowed = res
+					}
+				}
+			}
+			d.Rules = append(d.Rules, results...)
+		}
+	}
+
+	switch {
+	case denied != nil:
+		d.Reason = fmt.Sprintf("denied by DENY policy %s rule %s", denied.Policy, denied.Rule)
+	case allowed != nil:
+		d.Allowed = true
+		d.Reason = fmt.Sprintf("allowed by ALLOW policy %s rule %s", allowed.Policy, allowed.Rule)
+	case hasAllow:
+		d.Reason = "denied as no ALLOW policy matched"
+	default:
+		d.Allowed = true
+		d.Reason = "allowed as no ALLOW policy applies to the workload"
+	}
+	if len(d.Providers) > 0 {
+		d.Providers = sets.SortedList(sets.New(d.Providers...))
+		if d.Allowed {
+			d.Reason += fmt.Sprintf(", if allowed by CUSTOM provider %s", strings.Join(d.Providers, ", "))
+		}
+	}
+	return d, nil
+}
+
+// evaluateRules evaluates each policy of the RBAC rules, ordered by AuthorizationPolicy and rule index.
+func evaluateRules(rules *rbacpb.RBAC, action policyAction, dryRun bool, r *Request) ([]RuleResult, error) {
+	var results []RuleResult
+	for _, name := range maps.Keys(rules.GetPolicies()) {
+		policy, rule := extractName(name)
+		if policy == "" {
+			policy, rule = name, ""
+		}
+		matched, err := matchPolicy(rules.GetPolicies()[name], r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate %s: %v", name, err)
+		}
+		results = append(results, RuleResult{
+			Action:  action,
+			Policy:  policy,
+			Rule:    rule,
+			DryRun:  dryRun,
+			Matched: matched,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Policy != results[j].Policy {
+			return results[i].Policy < results[j].Policy
+		}
+		ri, _ := strconv.Atoi(results[i].Rule)
+		rj, _ := strconv.Atoi(results[j].Rule)
+		return ri < rj
+	})
+	return results, nil
+}
+
+// Print prints the evaluated rules and the decision.
+func (d *Decision) Print(writer io.Writer) {
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tAuthorizationPolicy\tRULE\tMATCHED")
+	for _, r := range d.Rules {
+		action := string(r.Action)
+		if r.DryRun {
+			action += " (dry-run)"
+		}
+		matched := "no"
+		if r.Matched {
+			matched = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", action, r.Policy, r.Rule, matched)
+	}
+	_ = w.Flush()
+	result := "DENIED"
+	if d.Allowed {
+		result = "ALLOWED"
+	}
+	fmt.Fprintf(writer, "\n%s: %s\n", result, d.Reason)
+}
+
+// matchPolicy returns true if the request matches any of the permissions and any of the principals of the policy,
+// which is how Envoy matches an RBAC policy.
+func matchPolicy(p *rbacpb.Policy, r *Request) (bool, error) {
+	permission, err := matchAnyPermission(p.GetPermissions(), r)
+	if err != nil || !permission {
+		return false, err
+	}
+	return matchAnyPrincipal(p.GetPrincipals(), r)
+}
+
+func matchAnyPermission(ps []*rbacpb.Permission, r *Request) (bool, error) {
+	for _, p := range ps {
+		m, err := matchPermission(p, r)
+		if err != nil || m {
+			return m, err
+		}
+	}
+	return false, nil
+}
+
+func matchAllPermissions(ps []*rbacpb.Permission, r *Request) (bool, error) {
+	for _, p := range ps {
+		m, err := matchPermission(p, r)
+		if err != nil || !m {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchPermission(p *rbacpb.Permission, r *Request) (bool, error) {
+	switch rule := p.GetRule().(type) {
+	case *rbacpb.Permission_Any:
+		return rule.Any, nil
+	case *rbacpb.Permission_AndRules:
+		return matchAllPermissions(rule.AndRules.GetRules(), r)
+	case *rbacpb.Permission_OrRules:
+		return matchAnyPermission(rule.OrRules.GetRules(), r)
+	case *rbacpb.Permission_NotRule:
+		m, err := matchPermission(rule.NotRule, r)
+		return !m, err
+	case *rbacpb.Permission_Header:
+		return matchHeader(rule.Header, r)
+	case *rbacpb.Permission_UrlPath:
+		return matchPath(rule.UrlPath, r.urlPath())
+	case *rbacpb.Permission_UriTemplate:
+		return matchURITemplate(rule.UriTemplate, r.urlPath())
+	case *rbacpb.Permission_DestinationIp:
+		return matchCidr(rule.DestinationIp, r.DestinationIP), nil
+	case *rbacpb.Permission_DestinationPort:
+		return r.DestinationPort != 0 && rule.DestinationPort == r.DestinationPort, nil
+	case *rbacpb.Permission_DestinationPortRange:
+		return r.DestinationPort != 0 && int64(r.DestinationPort) >= int64(rule.DestinationPortRange.GetStart()) &&
+			int64(r.DestinationPort) < int64(rule.DestinationPortRange.GetEnd()), nil
+	case *rbacpb.Permission_RequestedServerName:
+		// Requests are evaluated as plain HTTP requests on an inbound listener, which have no SNI.
+		return matchString(rule.RequestedServerName, "")
+	case *rbacpb.Permission_Metadata:
+		return matchMetadata(rule.Metadata, r)
+	default:
+		return false, fmt.Errorf("unsupported permission %T", rule)
+	}
+}
+
+func matchAnyPrincipal(ps []*rbacpb.Principal, r *Request) (bool, error) {
+	for _, p := range ps {
+		m, err := matchPrincipal(p, r)
+		if err != nil || m {
+			return m, err
+		}
+	}
+	return false, nil
+}
+
+func matchAllPrincipals(ps []*rbacpb.Principal, r *Request) (bool, error) {
+	for _, p := range ps {
+		m, err := matchPrincipal(p, r)
+		if err != nil || !m {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchPrincipal(p *rbacpb.Principal, r *Request) (bool, error) {
+	switch id := p.GetIdentifier().(type) {
+	case *rbacpb.Principal_Any:
+		return id.Any, nil
+	case *rbacpb.Principal_AndIds:
+		return matchAllPrincipals(id.AndIds.GetIds(), r)
+	case *rbacpb.Principal_OrIds:
+		return matchAnyPrincipal(id.OrIds.GetIds(), r)
+	case *rbacpb.Principal_NotId:
+		m, err := matchPrincipal(id.NotId, r)
+		return !m, err
+	case *rbacpb.Principal_Authenticated_:
+		if r.Principal == "" {
+			return false, nil
+		}
+		if id.Authenticated.GetPrincipalName() == nil {
+			return true, nil
+		}
+		return matchString(id.Authenticated.GetPrincipalName(), r.principalName())
+	case *rbacpb.Principal_FilterState:
+		if id.FilterState.GetKey() != "io.istio.peer_principal" || r.Principal == "" {
+			return false, nil
+		}
+		return matchString(id.FilterState.GetStringMatch(), r.principalName())
+	case *rbacpb.Principal_DirectRemoteIp:
+		return matchCidr(id.DirectRemoteIp, r.SourceIP), nil
+	case *rbacpb.Principal_RemoteIp:
+		return matchCidr(id.RemoteIp, r.SourceIP), nil
+	case *rbacpb.Principal_SourceIp: // nolint: staticcheck
+		return matchCidr(id.SourceIp, r.SourceIP), nil
+	case *rbacpb.Principal_Header:
+		return matchHeader(id.Header, r)
+	case *rbacpb.Principal_Metadata:
+		return matchMetadata(id.Metadata, r)
+	default:
+		return false, fmt.Errorf("unsupported principal %T", id)
+	}
+}
+
+func matchHeader(h *routepb.HeaderMatcher, r *Request) (bool, error) {
+	v, found := r.header(h.GetName())
+	if !found && h.GetTreatMissingHeaderAsEmpty() {
+		v, found = "", true
+	}
+	var m bool
+	var err error
+	switch spec := h.GetHeaderMatchSpecifier().(type) {
+	case *routepb.HeaderMatcher_PresentMatch:
+		// Unlike the other specifiers, invert_match is applied to presence by the specifier itself.
+		return found == spec.PresentMatch != h.GetInvertMatch(), nil
+	case *routepb.HeaderMatcher_ExactMatch: // nolint: staticcheck
+		m = v == spec.ExactMatch
+	case *routepb.HeaderMatcher_PrefixMatch: // nolint: staticcheck
+		m = strings.HasPrefix(v, spec.PrefixMatch)
+	case *routepb.HeaderMatcher_SuffixMatch: // nolint: staticcheck
+		m = strings.HasSuffix(v, spec.SuffixMatch)
+	case *routepb.HeaderMatcher_ContainsMatch: // nolint: staticcheck
+		m = strings.Contains(v, spec.ContainsMatch)
+	case *routepb.HeaderMatcher_SafeRegexMatch: // nolint: staticcheck
+		m, err = matchRegex(spec.SafeRegexMatch.GetRegex(), v)
+	case *routepb.HeaderMatcher_StringMatch:
+		m, err = matchString(spec.StringMatch, v)
+	case nil:
+		m = true
+	default:
+		return false, fmt.Errorf("unsupported header matcher %T", spec)
+	}
+	if err != nil || !found {
+		// A missing header never matches, even if the match is inverted.
+		return false, err
+	}
+	return m != h.GetInvertMatch(), nil
+}
+
+func matchString(s *matcherpb.StringMatcher, v string) (bool, error) {
+	if s.GetIgnoreCase() {
+		v = strings.ToLower(v)
+	}
+	lower := func(p string) string {
+		if s.GetIgnoreCase() {
+			return strings.ToLower(p)
+		}
+		return p
+	}
+	switch p := s.GetMatchPattern().(type) {
+	case *matcherpb.StringMatcher_Exact:
+		return v == lower(p.Exact), nil
+	case *matcherpb.StringMatcher_Prefix:
+		return strings.HasPrefix(v, lower(p.Prefix)), nil
+	case *matcherpb.StringMatcher_Suffix:
+		return strings.HasSuffix(v, lower(p.Suffix)), nil
+	case *matcherpb.StringMatcher_Contains:
+		return strings.Contains(v, lower(p.Contains)), nil
+	case *matcherpb.StringMatcher_SafeRegex:
+		return matchRegex(p.SafeRegex.GetRegex(), v)
+	default:
+		return false, fmt.Errorf("unsupported string matcher %T", p)
+	}
+}
+
+// matchRegex matches the whole value, as Envoy does for safe_regex.
+func matchRegex(expr, v string) (bool, error) {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return false, fmt.Errorf("invalid regex %q: %v", expr, err)
+	}
+	return re.MatchString(v), nil
+}
+
+func matchPath(p *matcherpb.PathMatcher, path string) (bool, error) {
+	switch rule := p.GetRule().(type) {
+	case *matcherpb.PathMatcher_Path:
+		return matchString(rule.Path, path)
+	default:
+		return false, fmt.Errorf("unsupported path matcher %T", rule)
+	}
+}
+
+func matchURITemplate(c *core.TypedExtensionConfig, path string) (bool, error) {
+	cfg := &uri_template.UriTemplateMatchConfig{}
+	if err := c.GetTypedConfig().UnmarshalTo(cfg); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %v", c.GetName(), err)
+	}
+	segments := strings.Split(cfg.PathTemplate, "/")
+	for i, s := range segments {
+		switch s {
+		case "**":
+			segments[i] = ".*"
+		case "*":
+			segments[i] = "[^/]+"
+		default:
+			segments[i] = regexp.QuoteMeta(s)
+		}
+	}
+	return matchRegex(strings.Join(segments, "/"), path)
+}
+
+func matchCidr(c *core.CidrRange, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	prefix := net.ParseIP(c.GetAddressPrefix())
+	if prefix == nil {
+		return false
+	}
+	bits := 8 * net.IPv6len
+	if prefix.To4() != nil {
+		bits = 8 * net.IPv4len
+		prefix, addr = prefix.To4(), addr.To4()
+		if addr == nil {
+			return false
+		}
+	}
+	length := bits
+	if c.GetPrefixLen() != nil {
+		length = int(c.GetPrefixLen().GetValue())
+	}
+	n := net.IPNet{IP: prefix.Mask(net.CIDRMask(length, bits)), Mask: net.CIDRMask(length, bits)}
+	return n.Contains(addr)
+}
+
+// matchMetadata matches the dynamic metadata of the request. Only the metadata set by the JWT filter, which holds the
+// validated JWT payload, is known offline.
+func matchMetadata(m *matcherpb.MetadataMatcher, r *Request) (bool, error) {
+	path := m.GetPath()
+	if m.GetFilter() != filters.EnvoyJwtFilterName || len(path) == 0 || path[0].GetKey() != filters.EnvoyJwtFilterPayload {
+		return false, fmt.Errorf("unsupported metadata matcher for filter %s", m.GetFilter())
+	}
+	var v any
+	if r.Claims != nil {
+		v = r.Claims
+		for _, p := range path[1:] {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				v = nil
+				break
+			}
+			v = obj[p.GetKey()]
+		}
+	}
+	matched, err := matchValue(m.GetValue(), v)
+	if err != nil {
+		return false, err
+	}
+	return matched != m.GetInvert(), nil
+}
+
+func matchValue(m *matcherpb.ValueMatcher, v any) (bool, error) {
+	switch p := m.GetMatchPattern().(type) {
+	case *matcherpb.ValueMatcher_NullMatch_:
+		return v == nil, nil
+	case *matcherpb.ValueMatcher_PresentMatch:
+		return (v != nil) == p.PresentMatch, nil
+	case *matcherpb.ValueMatcher_BoolMatch:
+		b, ok := v.(bool)
+		return ok && b == p.BoolMatch, nil
+	case *matcherpb.ValueMatcher_StringMatch:
+		s, ok := v.(string)
+		if !ok {
+			return false, nil
+		}
+		return matchString(p.StringMatch, s)
+	case *matcherpb.ValueMatcher_ListMatch:
+		l, ok := v.([]any)
+		if !ok {
+			return false, nil
+		}
+		for _, e := range l {
+			if m, err := matchValue(p.ListMatch.GetOneOf(), e); err != nil || m {
+				return m, err
+			}
+		}
+		return false, nil
+	case *matcherpb.ValueMatcher_OrMatch:
+		for _, vm := range p.OrMatch.GetValueMatchers() {
+			if m, err := matchValue(vm, v); err != nil || m {
+				return m, err
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported value matcher %T", p)
+	}
+}
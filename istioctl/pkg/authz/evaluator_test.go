@@ -0,0 +1,261 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	security "istio.io/api/security/v1beta1"
+	securityclient "istio.io/client-go/pkg/apis/security/v1"
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestEvaluateRequest(t *testing.T) {
+	base := []string{"--policy-file", "testdata/policies.yaml", "--labels", "app=httpbin", "-o", "json"}
+	cases := []struct {
+		name      string
+		args      []string
+		allowed   bool
+		reason    string
+		providers []string
+		matched   []string
+	}{
+		{
+			name:    "allowed from namespace",
+			args:    []string{"--source-namespace", "foo", "--method", "GET", "--path", "/headers?x=1"},
+			allowed: true,
+			reason:  "allowed by ALLOW policy httpbin-viewer.foo rule 0",
+			matched: []string{"AUDIT audit-all.istio-system/0", "ALLOW httpbin-viewer.foo/0"},
+		},
+		{
+			name:    "path template",
+			args:    []string{"--source-principal", "spiffe://cluster.local/ns/foo/sa/sleep", "--method", "GET", "--path", "/status/200"},
+			allowed: true,
+			reason:  "allowed by ALLOW policy httpbin-viewer.foo rule 0",
+			matched: []string{"AUDIT audit-all.istio-system/0", "ALLOW httpbin-viewer.foo/0"},
+		},
+		{
+			name:    "path template matches a single segment",
+			args:    []string{"--source-namespace", "foo", "--method", "GET", "--path", "/status/200/extra"},
+			reason:  "denied as no ALLOW policy matched",
+			matched: []string{"AUDIT audit-all.istio-system/0"},
+		},
+		{
+			name:    "other namespace",
+			args:    []string{"--source-namespace", "bar", "--method", "GET", "--path", "/headers"},
+			reason:  "denied as no ALLOW policy matched",
+			matched: []string{"AUDIT audit-all.istio-system/0"},
+		},
+		{
+			name:    "deny",
+			args:    []string{"--source-namespace", "foo", "--method", "GET", "--path", "/admin/users"},
+			reason:  "denied by DENY policy deny-admin.foo rule 0",
+			matched: []string{"AUDIT audit-all.istio-system/0", "DENY deny-admin.foo/0"},
+		},
+		{
+			name:    "deny exception",
+			args:    []string{"--source-principal", "cluster.local/ns/foo/sa/admin", "--method", "GET", "--path", "/admin"},
+			reason:  "denied as no ALLOW policy matched",
+			matched: []string{"AUDIT audit-all.istio-system/0"},
+		},
+		{
+			name: "jwt claims",
+			args: []string{
+				"--method", "POST", "--path", "/post",
+				"--claims", `{"iss":"https://issuer.example.com","sub":"alice","groups":["dev","admin"]}`,
+			},
+			allowed: true,
+			reason:  "allowed by ALLOW policy httpbin-viewer.foo rule 1",
+			matched: []string{"AUDIT audit-all.istio-system/0", "ALLOW httpbin-viewer.foo/1"},
+		},
+		{
+			name: "jwt claims not matched",
+			args: []string{
+				"--method", "POST", "--path", "/post",
+				"--claims", `{"iss":"https://issuer.example.com","sub":"alice","groups":"dev"}`,
+			},
+			reason:  "denied as no ALLOW policy matched",
+			matched: []string{"AUDIT audit-all.istio-system/0"},
+		},
+		{
+			name:      "custom",
+			args:      []string{"--source-namespace", "foo", "--method", "GET", "--path", "/ext/headers", "--source-ip", "10.1.2.3"},
+			reason:    "denied as no ALLOW policy matched",
+			providers: []string{"my-ext-authz"},
+			matched: []string{
+				"CUSTOM ext-authz.foo/0", "AUDIT audit-all.istio-system/0", "ALLOW (dry-run) allow-internal.foo/0",
+			},
+		},
+		{
+			name: "headers",
+			args: []string{"--source-namespace", "foo", "-H", "X-Test=a", "-H", "x-test=b", "--method", "GET", "--path", "/headers"},
+			// Headers are not used by the policies, but must be accepted.
+			allowed: true,
+			reason:  "allowed by ALLOW policy httpbin-viewer.foo rule 0",
+			matched: []string{"AUDIT audit-all.istio-system/0", "ALLOW httpbin-viewer.foo/0"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cli.NewFakeContext(&cli.NewFakeContextOption{Namespace: "foo", IstioNamespace: "istio-system"})
+			cmd := checkCmd(ctx)
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetArgs(append(append([]string{}, base...), tc.args...))
+			assert.NoError(t, cmd.Execute())
+
+			d := &Decision{}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), d))
+			assert.Equal(t, d.Allowed, tc.allowed)
+			assert.Equal(t, d.Reason, tc.reason)
+			assert.Equal(t, d.Providers, tc.providers)
+			var matched []string
+			for _, r := range d.Rules {
+				if r.Matched {
+					action := string(r.Action)
+					if r.DryRun {
+						action += " (dry-run)"
+					}
+					matched = append(matched, action+" "+r.Policy+"/"+r.Rule)
+				}
+			}
+			assert.Equal(t, matched, tc.matched)
+		})
+	}
+}
+
+func TestEvaluateRequestPrint(t *testing.T) {
+	ctx := cli.NewFakeContext(&cli.NewFakeContextOption{Namespace: "foo", IstioNamespace: "istio-system"})
+	cmd := checkCmd(ctx)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{
+		"--policy-file", "testdata/policies.yaml", "--labels", "app=httpbin",
+		"--source-namespace", "foo", "--method", "GET", "--path", "/ext/status",
+	})
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, out.String(), `ACTION            AuthorizationPolicy      RULE   MATCHED
+CUSTOM            ext-authz.foo            0      yes
+AUDIT             audit-all.istio-system   0      yes
+DENY              deny-admin.foo           0      no
+ALLOW             httpbin-viewer.foo       0      no
+ALLOW             httpbin-viewer.foo       1      no
+ALLOW (dry-run)   allow-internal.foo       0      no
+
+DENIED: denied as no ALLOW policy matched
+`)
+}
+
+func TestEvaluateRequestRootNamespace(t *testing.T) {
+	auditAll := func(ns string) *securityclient.AuthorizationPolicy {
+		return &securityclient.AuthorizationPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "audit-all", Namespace: ns},
+			Spec:       security.AuthorizationPolicy{Action: security.AuthorizationPolicy_AUDIT, Rules: []*security.Rule{{}}},
+		}
+	}
+	meshConfig := func(mesh string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio", Namespace: "istio-system"},
+			Data:       map[string]string{"mesh": mesh},
+		}
+	}
+	request := []string{"--labels", "app=httpbin", "--source-namespace", "foo", "-o", "json"}
+	cases := []struct {
+		name    string
+		args    []string
+		objects []runtime.Object
+		matched []string
+	}{
+		{
+			name:    "policy file without mesh config",
+			args:    []string{"--policy-file", "testdata/policies.yaml"},
+			matched: []string{"audit-all.istio-system"},
+		},
+		{
+			name:    "policy file with mesh config",
+			args:    []string{"--policy-file", "testdata/policies.yaml", "--meshConfigFile", "testdata/meshconfig.yaml"},
+			matched: nil,
+		},
+		{
+			name:    "cluster root namespace",
+			objects: []runtime.Object{meshConfig("rootNamespace: istio-config"), auditAll("istio-config"), auditAll("istio-system")},
+			matched: []string{"audit-all.istio-config"},
+		},
+		{
+			name:    "cluster root namespace unset",
+			objects: []runtime.Object{meshConfig("trustDomain: cluster.local"), auditAll("istio-config"), auditAll("istio-system")},
+			matched: []string{"audit-all.istio-system"},
+		},
+		{
+			name:    "cluster without mesh config",
+			objects: []runtime.Object{auditAll("istio-config"), auditAll("istio-system")},
+			matched: []string{"audit-all.istio-system"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cli.NewFakeContext(&cli.NewFakeContextOption{Namespace: "foo", IstioNamespace: "istio-system", Objects: tc.objects})
+			cmd := checkCmd(ctx)
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetArgs(append(append([]string{}, request...), tc.args...))
+			assert.NoError(t, cmd.Execute())
+
+			d := &Decision{}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), d))
+			var matched []string
+			for _, r := range d.Rules {
+				if r.Matched && r.Action == "AUDIT" {
+					matched = append(matched, r.Policy)
+				}
+			}
+			assert.Equal(t, matched, tc.matched)
+		})
+	}
+}
+
+func TestEvaluateRequestErrors(t *testing.T) {
+	cases := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--method", "GET"}, "expecting pod name or --labels"},
+		{[]string{"--labels", "app=httpbin", "-f", "testdata/configdump.yaml"}, "use --policy-file instead of --file"},
+		{[]string{"--labels", "app=httpbin", "-H", "bad"}, `invalid header "bad"`},
+		{[]string{"--labels", "app=httpbin", "--source-principal", "cluster.local/ns/bar/sa/x", "--source-namespace", "foo"}, "not in namespace foo"},
+		{[]string{"--labels", "app=httpbin", "--claims", "{"}, "invalid claims"},
+	}
+	for _, tc := range cases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			cmd := checkCmd(cli.NewFakeContext(&cli.NewFakeContextOption{Namespace: "foo"}))
+			cmd.SetOut(&bytes.Buffer{})
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs(tc.args)
+			err := cmd.Execute()
+			assert.Error(t, err)
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -134,6 +134,7 @@ const (
 	policyActionDeny   policyAction = "DENY"
 	policyActionLog    policyAction = "LOG"
 	policyActionCustom policyAction = "CUSTOM"
+	policyActionAudit  policyAction = "AUDIT"
 )
 
 // Print prints the AuthorizationPolicy in the listener.
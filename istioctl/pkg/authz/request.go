@@ -0,0 +1,299 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	authpb "istio.io/api/security/v1beta1"
+	"istio.io/istio/istioctl/pkg/cli"
+	istioctlutil "istio.io/istio/istioctl/pkg/util"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// requestArgs are the flags describing a request to evaluate against the AuthorizationPolicies of a workload.
+type requestArgs struct {
+	sourcePrincipal string
+	sourceNamespace string
+	sourceIP        string
+	method          string
+	path            string
+	host            string
+	port            uint32
+	headers         []string
+	claims          string
+
+	trustDomain    string
+	policyFiles    []string
+	meshConfigFile string
+	labels         map[string]string
+	outputFormat   string
+}
+
+// requestFlags are the flags which switch the check command to evaluating a request.
+var requestFlags = []string{
+	"source-principal", "source-namespace", "source-ip", "method", "path", "host", "port", "header", "claims",
+	"policy-file", "labels",
+}
+
+func (a *requestArgs) addFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&a.sourcePrincipal, "source-principal", "",
+		"Peer identity of the request source, e.g. cluster.local/ns/default/sa/sleep. Unset for plaintext requests")
+	flags.StringVar(&a.sourceNamespace, "source-namespace", "",
+		"Namespace of the request source. Without --source-principal, the source is the default service account of the namespace")
+	flags.StringVar(&a.sourceIP, "source-ip", "", "IP address of the request source")
+	flags.StringVar(&a.method, "method", "", "Method of the request")
+	flags.StringVar(&a.path, "path", "", "Path of the request, which may include a query string")
+	flags.StringVar(&a.host, "host", "", "Host header of the request")
+	flags.Uint32Var(&a.port, "port", 0, "Destination port of the request")
+	flags.StringArrayVarP(&a.headers, "header", "H", nil, "Request header, in the form <name>=<value>. May be repeated")
+	flags.StringVar(&a.claims, "claims", "",
+		"Claims of the validated JWT of the request, as a JSON object. The request principal is <iss>/<sub>")
+	flags.StringVar(&a.trustDomain, "trust-domain", constants.DefaultClusterLocalDomain, "Trust domain of the mesh")
+	flags.StringSliceVar(&a.policyFiles, "policy-file", nil,
+		"Evaluate the request against the AuthorizationPolicies in these files, instead of those in the cluster")
+	flags.StringVar(&a.meshConfigFile, "meshConfigFile", "",
+		"Mesh config to read the root namespace from, instead of the mesh config of the cluster. "+
+			"With --policy-file and no mesh config, the root namespace is the Istio namespace")
+	flags.StringToStringVar(&a.labels, "labels", nil,
+		"Labels of the destination workload, when not checking a pod, e.g. app=httpbin,version=v1")
+	flags.StringVarP(&a.outputFormat, "output", "o", "", "Output format of the request evaluation: one of json or empty for a table")
+}
+
+func (a *requestArgs) isSet(cmd *cobra.Command) bool {
+	for _, f := range requestFlags {
+		if cmd.Flags().Changed(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *requestArgs) request() (*Request, error) {
+	r := &Request{
+		Principal:       strings.TrimPrefix(a.sourcePrincipal, spiffe.URIPrefix),
+		SourceIP:        a.sourceIP,
+		DestinationPort: a.port,
+		Method:          a.method,
+		Path:            a.path,
+		Host:            a.host,
+		Headers:         map[string]string{},
+	}
+	if a.sourceNamespace != "" {
+		if r.Principal == "" {
+			id := spiffe.Identity{TrustDomain: a.trustDomain, Namespace: a.sourceNamespace, ServiceAccount: "default"}
+			r.Principal = strings.TrimPrefix(id.String(), spiffe.URIPrefix)
+		} else if !strings.Contains(r.Principal+"/", "/ns/"+a.sourceNamespace+"/") {
+			return nil, fmt.Errorf("source principal %s is not in namespace %s", r.Principal, a.sourceNamespace)
+		}
+	}
+	for _, h := range a.headers {
+		k, v, ok := strings.Cut(h, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q, expected <name>=<value>", h)
+		}
+		k = strings.ToLower(k)
+		if prev, f := r.Headers[k]; f {
+			v = prev + "," + v
+		}
+		r.Headers[k] = v
+	}
+	if a.claims != "" {
+		if err := json.Unmarshal([]byte(a.claims), &r.Claims); err != nil {
+			return nil, fmt.Errorf("invalid claims: %v", err)
+		}
+	}
+	return r, nil
+}
+
+func (a *requestArgs) run(ctx cli.Context, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && a.labels == nil {
+		return fmt.Errorf("expecting pod name or --labels of the destination workload")
+	}
+	if len(args) == 1 && a.labels != nil {
+		return fmt.Errorf("--labels can not be used with a pod name")
+	}
+	if a.outputFormat != "" && a.outputFormat != "json" {
+		return fmt.Errorf("unknown output format %q, expected json", a.outputFormat)
+	}
+	r, err := a.request()
+	if err != nil {
+		return err
+	}
+
+	namespace := ctx.NamespaceOrDefault(ctx.Namespace())
+	workloadLabels := labels.Instance(a.labels)
+	if len(args) == 1 {
+		kubeClient, err := ctx.CLIClient()
+		if err != nil {
+			return fmt.Errorf("failed to create k8s client: %w", err)
+		}
+		podName, podNamespace, err := ctx.InferPodInfoFromTypedResource(args[0], ctx.Namespace())
+		if err != nil {
+			return err
+		}
+		pod, err := kubeClient.Kube().CoreV1().Pods(podNamespace).Get(context.TODO(), podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get pod %s.%s: %v", podName, podNamespace, err)
+		}
+		namespace, workloadLabels = pod.Namespace, pod.Labels
+		if r.DestinationIP == "" {
+			r.DestinationIP = pod.Status.PodIP
+		}
+	}
+
+	policies, err := a.policies(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	matcher := model.PolicyMatcherFor(namespace, workloadLabels, false).WithRootNamespace(policies.RootNamespace)
+	decision, err := Evaluate(trustdomain.NewBundle(a.trustDomain, nil), policies.ListAuthorizationPolicies(matcher), r)
+	if err != nil {
+		return err
+	}
+	if a.outputFormat == "json" {
+		out, err := json.MarshalIndent(decision, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		return err
+	}
+	decision.Print(cmd.OutOrStdout())
+	return nil
+}
+
+// policies returns the AuthorizationPolicies of the root namespace and the workload namespace, either from the
+// policy files or from the cluster.
+func (a *requestArgs) policies(ctx cli.Context, namespace string) (*model.AuthorizationPolicies, error) {
+	rootNamespace, err := a.rootNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies := &model.AuthorizationPolicies{
+		NamespaceToPolicies: map[string][]model.AuthorizationPolicy{},
+		RootNamespace:       rootNamespace,
+	}
+	if len(a.policyFiles) > 0 {
+		for _, f := range a.policyFiles {
+			content, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			configs, _, err := crd.ParseInputs(string(content))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", f, err)
+			}
+			for _, c := range configs {
+				if c.GroupVersionKind != gvk.AuthorizationPolicy {
+					continue
+				}
+				if c.Namespace == "" {
+					c.Namespace = namespace
+				}
+				policies.NamespaceToPolicies[c.Namespace] = append(policies.NamespaceToPolicies[c.Namespace], model.AuthorizationPolicy{
+					Name:        c.Name,
+					Namespace:   c.Namespace,
+					Annotations: c.Annotations,
+					Spec:        c.Spec.(*authpb.AuthorizationPolicy),
+				})
+			}
+		}
+		return policies, nil
+	}
+
+	kubeClient, err := ctx.CLIClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	for _, ns := range []string{policies.RootNamespace, namespace} {
+		if _, f := policies.NamespaceToPolicies[ns]; f {
+			continue
+		}
+		list, err := kubeClient.Istio().SecurityV1().AuthorizationPolicies(ns).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list AuthorizationPolicies in %s: %v", ns, err)
+		}
+		policies.NamespaceToPolicies[ns] = []model.AuthorizationPolicy{}
+		for _, p := range list.Items {
+			policies.NamespaceToPolicies[ns] = append(policies.NamespaceToPolicies[ns], model.AuthorizationPolicy{
+				Name:        p.Name,
+				Namespace:   p.Namespace,
+				Annotations: p.Annotations,
+				Spec:        &p.Spec,
+			})
+		}
+	}
+	return policies, nil
+}
+
+// rootNamespace returns the rootNamespace of the mesh config, falling back to the Istio namespace when it is unset.
+// The mesh config is read from --meshConfigFile if given, or else from the cluster unless the policies come from files.
+func (a *requestArgs) rootNamespace(ctx cli.Context) (string, error) {
+	var meshConfigYaml string
+	switch {
+	case a.meshConfigFile != "":
+		content, err := os.ReadFile(a.meshConfigFile)
+		if err != nil {
+			return "", err
+		}
+		meshConfigYaml = string(content)
+	case len(a.policyFiles) > 0:
+		return ctx.IstioNamespace(), nil
+	default:
+		kubeClient, err := ctx.CLIClient()
+		if err != nil {
+			return "", fmt.Errorf("failed to create k8s client: %w", err)
+		}
+		meshConfigMapName := istioctlutil.DefaultMeshConfigMapName
+		if rev := kubeClient.Revision(); rev != "default" && rev != "" {
+			meshConfigMapName = fmt.Sprintf("%s-%s", istioctlutil.DefaultMeshConfigMapName, rev)
+		}
+		cm, err := kubeClient.Kube().CoreV1().ConfigMaps(ctx.IstioNamespace()).Get(context.TODO(), meshConfigMapName, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return ctx.IstioNamespace(), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not read configmap %q from namespace %q: %v", meshConfigMapName, ctx.IstioNamespace(), err)
+		}
+		meshConfigYaml = cm.Data[istioctlutil.ConfigMapKey]
+	}
+	// Decode onto an empty mesh config rather than the defaults, which would always set a root namespace.
+	meshCfg := &meshconfig.MeshConfig{}
+	if err := protomarshal.ApplyYAML(meshConfigYaml, meshCfg); err != nil {
+		return "", fmt.Errorf("error parsing mesh config: %v", err)
+	}
+	if meshCfg.RootNamespace == "" {
+		return ctx.IstioNamespace(), nil
+	}
+	return meshCfg.RootNamespace, nil
+}
@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/security"
+)
+
+const (
+	// istiodHTTPSPort is the default HTTPS port of istiod, serving the CA management endpoints.
+	istiodHTTPSPort = 15017
+	// adminTokenExpiration is the lifetime in seconds of the token authenticating istioctl to istiod.
+	adminTokenExpiration = 600
+)
+
+// adminFlags are the flags of the commands calling the CA management endpoints of istiod, which are served on its
+// HTTPS port to the PILOT_CA_ADMIN_IDENTITIES.
+type adminFlags struct {
+	// serviceAccount is the namespace/name of the service account authenticating istioctl, whose identity must be one
+	// of the PILOT_CA_ADMIN_IDENTITIES.
+	serviceAccount string
+	port           int
+}
+
+func (f *adminFlags) attach(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.serviceAccount, "service-account", "",
+		"The service account authenticating to istiod, as namespace/name or name in the Istio namespace. "+
+			"Its identity must be listed in the PILOT_CA_ADMIN_IDENTITIES of istiod")
+	cmd.Flags().IntVar(&f.port, "istiod-port", istiodHTTPSPort, "The HTTPS port of istiod")
+}
+
+// allIstiodsDo makes a request to the CA management endpoint of each istiod replica, authenticated with a token of
+// the service account, and verifying istiod with the root certificate of the mesh.
+func (f *adminFlags) allIstiodsDo(ctx cli.Context, client kube.CLIClient, path string) (map[string][]byte, error) {
+	if f.serviceAccount == "" {
+		return nil, fmt.Errorf("--service-account is required to authenticate to istiod")
+	}
+	namespace, name := ctx.IstioNamespace(), f.serviceAccount
+	if ns, sa, ok := strings.Cut(f.serviceAccount, "/"); ok {
+		namespace, name = ns, sa
+	}
+	expiration := int64(adminTokenExpiration)
+	token, err := client.Kube().CoreV1().ServiceAccounts(namespace).CreateToken(context.Background(), name,
+		&authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				Audiences:         security.TokenAudiences,
+				ExpirationSeconds: &expiration,
+			},
+		}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a token for service account %s/%s: %v", namespace, name, err)
+	}
+	rootCert, err := client.Kube().CoreV1().ConfigMaps(ctx.IstioNamespace()).Get(context.Background(),
+		controller.CACertNamespaceConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the root certificate of the mesh: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(rootCert.Data[constants.CACertNamespaceConfigMapDataName])) {
+		return nil, fmt.Errorf("no root certificate in configmap %s/%s", ctx.IstioNamespace(), controller.CACertNamespaceConfigMap)
+	}
+	header := http.Header{"Authorization": []string{"Bearer " + token.Status.Token}}
+	return client.AllDiscoveryDoWithTLS(context.Background(), ctx.IstioNamespace(), path, f.port,
+		&tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}, header)
+}
@@ -0,0 +1,37 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ca implements the commands managing the Istio CA.
+package ca
+
+import (
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/cli"
+)
+
+func Cmd(ctx cli.Context) *cobra.Command {
+	caCmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Commands to manage the Istio CA",
+		Example: `  # Rotate the root of the Istio CA
+  istioctl x ca rotate
+
+  # List the certificates issued by the Istio CA
+  istioctl x ca certs list`,
+	}
+	caCmd.AddCommand(rotateCommand(ctx))
+	caCmd.AddCommand(certsCommand(ctx))
+	return caCmd
+}
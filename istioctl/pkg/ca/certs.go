@@ -0,0 +1,187 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	caserver "istio.io/istio/security/pkg/server/ca"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+	yamlOutput  = "yaml"
+)
+
+// certificate is a certificate of the inventory of an istiod replica.
+type certificate struct {
+	caserver.IssuedCertificate
+	Istiod string `json:"istiod"`
+}
+
+func certsCommand(ctx cli.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Commands to inspect the certificates issued by the Istio CA",
+	}
+	cmd.AddCommand(certsListCommand(ctx))
+	return cmd
+}
+
+func certsListCommand(ctx cli.Context) *cobra.Command {
+	var filter caserver.CertificateFilter
+	var output string
+	var admin adminFlags
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the certificates issued by the Istio CA",
+		Long: `Lists the certificates issued by the istiod replicas, ordered by expiry, from their certificate inventory.
+A certificate is renewed if a later certificate was issued for the same identity and pod, by any replica.
+
+Requires the PILOT_CA_CERT_INVENTORY_SIZE environment variable set in istiod. The inventory of a replica is lost when
+it restarts, and only holds its latest certificates once full.
+
+The inventory is served on the HTTPS port of istiod to the PILOT_CA_ADMIN_IDENTITIES: istioctl authenticates with a
+token of the --service-account, whose identity must be one of them.`,
+		Example: `  # List the certificates of the workloads of the foo namespace
+  istioctl x ca certs list -n foo --service-account istio-system/ca-admin
+
+  # List the certificates expiring within the hour which were not renewed
+  istioctl x ca certs list --expiring-within 1h --not-renewed --service-account ca-admin
+
+  # List the certificates of a service account, in JSON
+  istioctl x ca certs list --identity spiffe://cluster.local/ns/foo/sa/bar -o json --service-account ca-admin`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			switch output {
+			case tableOutput, jsonOutput, yamlOutput:
+			default:
+				return fmt.Errorf("unknown output format %q, must be one of %s, %s or %s", output, tableOutput, jsonOutput, yamlOutput)
+			}
+			filter.Namespace = ctx.Namespace()
+			client, err := ctx.CLIClient()
+			if err != nil {
+				return err
+			}
+			// The renewal of a certificate may be issued by another replica: only the filters selecting whole
+			// pods are applied by the replicas, the others once the inventories are merged.
+			query := caserver.CertificateFilter{Identity: filter.Identity, Namespace: filter.Namespace, Node: filter.Node}.Query()
+			path := strings.TrimPrefix(caserver.CertificatesPath, "/")
+			if len(query) > 0 {
+				path += "?" + query.Encode()
+			}
+			res, err := admin.allIstiodsDo(ctx, client, path)
+			if err != nil {
+				return err
+			}
+			certs, err := mergeCertificates(res, filter, time.Now())
+			if err != nil {
+				return err
+			}
+			return printCertificates(cmd.OutOrStdout(), certs, output, time.Now())
+		},
+	}
+	cmd.Flags().StringVar(&filter.Identity, "identity", "",
+		"Only list the certificates of the identity. The identity may have a '*' wildcard as prefix or suffix")
+	cmd.Flags().StringVar(&filter.Node, "node", "", "Only list the certificates requested by the pods of the node")
+	cmd.Flags().DurationVar(&filter.ExpiringWithin, "expiring-within", 0,
+		"Only list the certificates expiring within the duration, including the expired ones")
+	cmd.Flags().BoolVar(&filter.NotRenewed, "not-renewed", false, "Only list the certificates which were not renewed")
+	cmd.Flags().StringVarP(&output, "output", "o", tableOutput, "Output format: one of table|json|yaml")
+	admin.attach(cmd)
+	return cmd
+}
+
+// mergeCertificates merges the inventories of the istiod replicas, and applies the filter.
+func mergeCertificates(inventories map[string][]byte, filter caserver.CertificateFilter, now time.Time) ([]certificate, error) {
+	istiods := map[string]string{}
+	var all []caserver.IssuedCertificate
+	for _, istiod := range slices.Sort(maps.Keys(inventories)) {
+		var certs []caserver.IssuedCertificate
+		if err := json.Unmarshal(inventories[istiod], &certs); err != nil {
+			return nil, fmt.Errorf("invalid certificate inventory of %s: %v", istiod, err)
+		}
+		for _, cert := range certs {
+			istiods[cert.SerialNumber] = istiod
+		}
+		all = append(all, certs...)
+	}
+	slices.SortStableFunc(all, func(a, b caserver.IssuedCertificate) int {
+		return a.IssuedAt.Compare(b.IssuedAt)
+	})
+	caserver.MarkRenewed(all)
+	all = filter.Apply(all, now)
+	caserver.SortByExpiry(all)
+	return slices.Map(all, func(c caserver.IssuedCertificate) certificate {
+		return certificate{IssuedCertificate: c, Istiod: istiods[c.SerialNumber]}
+	}), nil
+}
+
+func printCertificates(w io.Writer, certs []certificate, output string, now time.Time) error {
+	switch output {
+	case jsonOutput:
+		b, err := json.MarshalIndent(certs, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case yamlOutput:
+		b, err := yaml.Marshal(certs)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	if len(certs) == 0 {
+		_, err := fmt.Fprintln(w, "No certificates found.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "SERIAL NUMBER\tIDENTITY\tPOD\tNODE\tEXPIRES\tRENEWED\tISTIOD")
+	for _, cert := range certs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%s\n", cert.SerialNumber, strings.Join(cert.Identities, ","),
+			orNone(cert.Pod), orNone(cert.Node), expiry(cert.ExpiresAt, now), cert.Renewed, cert.Istiod)
+	}
+	return tw.Flush()
+}
+
+func expiry(expiresAt, now time.Time) string {
+	ttl := expiresAt.Sub(now).Round(time.Second)
+	if ttl <= 0 {
+		return fmt.Sprintf("expired %s ago", -ttl)
+	}
+	return fmt.Sprintf("in %s", ttl)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/security/pkg/pki/util"
+	caserver "istio.io/istio/security/pkg/server/ca"
+)
+
+func inventory(t *testing.T, certs ...caserver.IssuedCertificate) []byte {
+	t.Helper()
+	b, err := json.Marshal(certs)
+	assert.NoError(t, err)
+	return b
+}
+
+func TestMergeCertificates(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	identity := []string{"spiffe://cluster.local/ns/foo/sa/foo"}
+	inventories := map[string][]byte{
+		"istiod-a": inventory(t,
+			caserver.IssuedCertificate{SerialNumber: "1", Identities: identity, Pod: "foo/pod-1", IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+			caserver.IssuedCertificate{SerialNumber: "2", Identities: identity, Pod: "foo/pod-2", IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		),
+		// The certificate of pod-1 was renewed by another replica.
+		"istiod-b": inventory(t,
+			caserver.IssuedCertificate{
+				SerialNumber: "3", Identities: identity, Pod: "foo/pod-1", Node: "node",
+				IssuedAt: now.Add(30 * time.Minute), ExpiresAt: now.Add(25 * time.Hour),
+			},
+		),
+	}
+
+	certs, err := mergeCertificates(inventories, caserver.CertificateFilter{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, slices.Map(certs, func(c certificate) string { return c.SerialNumber + "@" + c.Istiod }),
+		[]string{"1@istiod-a", "2@istiod-a", "3@istiod-b"})
+	assert.Equal(t, slices.Map(certs, func(c certificate) bool { return c.Renewed }), []bool{true, false, false})
+
+	certs, err = mergeCertificates(inventories, caserver.CertificateFilter{ExpiringWithin: 2 * time.Hour, NotRenewed: true}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, slices.Map(certs, func(c certificate) string { return c.SerialNumber }), []string{"2"})
+
+	var out bytes.Buffer
+	assert.NoError(t, printCertificates(&out, certs, tableOutput, now.Add(2*time.Hour)))
+	assert.Equal(t, out.String(),
+		`SERIAL NUMBER IDENTITY                             POD       NODE EXPIRES            RENEWED ISTIOD
+2             spiffe://cluster.local/ns/foo/sa/foo foo/pod-2 -    expired 1h0m0s ago false   istiod-a
+`)
+
+	_, err = mergeCertificates(map[string][]byte{"istiod-a": []byte("not found")}, caserver.CertificateFilter{}, now)
+	assert.Error(t, err)
+}
+
+func TestCertsListCommand(t *testing.T) {
+	now := time.Now()
+	rootCert, _, err := util.GenCertKeyFromOptions(util.CertOptions{Org: "cluster.local", IsCA: true, IsSelfSigned: true, TTL: time.Hour, RSAKeySize: 2048})
+	assert.NoError(t, err)
+	ctx := cli.NewFakeContext(&cli.NewFakeContextOption{
+		IstioNamespace: "istio-system",
+		Objects: []runtime.Object{
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "istio-ca-root-cert", Namespace: "istio-system"},
+				Data:       map[string]string{"root-cert.pem": string(rootCert)},
+			},
+		},
+		Results: map[string][]byte{
+			"istiod-a": inventory(t, caserver.IssuedCertificate{
+				SerialNumber: "1", Identities: []string{"spiffe://cluster.local/ns/foo/sa/foo"},
+				IssuedAt: now, ExpiresAt: now.Add(time.Hour),
+			}),
+		},
+	})
+	client, err := ctx.CLIClient()
+	assert.NoError(t, err)
+	client.Kube().(*fake.Clientset).PrependReactor("create", "serviceaccounts",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			create := action.(clienttesting.CreateActionImpl)
+			tokenReq, ok := create.GetObject().(*authenticationv1.TokenRequest)
+			if !ok || create.Name != "ca-admin" {
+				return true, nil, fmt.Errorf("unexpected token request %v", action)
+			}
+			tokenReq = tokenReq.DeepCopy()
+			tokenReq.Status.Token = "token"
+			return true, tokenReq, nil
+		})
+	cmd := Cmd(ctx)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"certs", "list", "-o", "json", "--service-account", "ca-admin"})
+	assert.NoError(t, cmd.Execute())
+	var certs []certificate
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &certs))
+	assert.Equal(t, slices.Map(certs, func(c certificate) string { return c.SerialNumber + "@" + c.Istiod }), []string{"1@istiod-a"})
+
+	cmd.SetArgs([]string{"certs", "list", "-o", "xml", "--service-account", "ca-admin"})
+	assert.Error(t, cmd.Execute())
+
+	// The inventory is only served to admin identities.
+	cmd.SetArgs([]string{"certs", "list", "-o", "json", "--service-account", ""})
+	assert.Error(t, cmd.Execute())
+}
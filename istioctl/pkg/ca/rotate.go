@@ -0,0 +1,292 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// watchInterval is the interval at which a watched rotation is polled.
+var watchInterval = 2 * time.Second
+
+type rotateArgs struct {
+	caCert      string
+	caKey       string
+	certChain   string
+	rootCert    string
+	gracePeriod time.Duration
+	watch       bool
+}
+
+func rotateCommand(ctx cli.Context) *cobra.Command {
+	args := &rotateArgs{}
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotates the root of the Istio CA",
+		Long: `Starts a staged rotation of the root of the Istio CA, driven by istiod:
+  1. the new root is added to the trust bundle, until all the proxies acked it,
+  2. the CA signs with the new CA, during the grace period of the old root,
+  3. the old root is removed from the trust bundle, until all the proxies acked it.
+
+Proxies ack the trust bundle once Envoy applied it over SDS. The proxies which cannot ack it, e.g. ztunnel, pick
+up the roots with the certificates they renew: while any of them is connected, steps 1 and 3 wait for the grace
+period.
+
+A self-signed CA generates a new root, while the new CA must be provided for a plugged-in CA. The new CA is
+persisted in the secret of the CA once the rotation completes. A rotation cannot be aborted once started.
+
+Requires the PILOT_ENABLE_CA_ROOT_ROTATION and ISTIO_MULTIROOT_MESH environment variables set in istiod.`,
+		Example: `  # Rotate the root of a self-signed CA, and watch the rotation
+  istioctl x ca rotate --watch
+
+  # Rotate the root of a plugged-in CA, trusting the old root for 48 hours once the new CA signs
+  istioctl x ca rotate --ca-cert ca-cert.pem --ca-key ca-key.pem --cert-chain cert-chain.pem \
+    --root-cert root-cert.pem --grace-period 48h
+
+  # Watch the rotation in progress
+  istioctl x ca rotate status --watch`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			newCA, err := args.readNewCA()
+			if err != nil {
+				return err
+			}
+			client, err := ctx.CLIClient()
+			if err != nil {
+				return err
+			}
+			spec := ca.RootRotationSpec{GracePeriod: metav1.Duration{Duration: args.gracePeriod}}
+			if err := startRotation(client.Kube(), ctx.IstioNamespace(), spec, newCA); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Started the root rotation of the Istio CA in namespace %s.\n", ctx.IstioNamespace())
+			if !args.watch {
+				return nil
+			}
+			return watchRotation(cmd.Context(), cmd.OutOrStdout(), client.Kube(), ctx.IstioNamespace())
+		},
+	}
+	cmd.Flags().StringVar(&args.caCert, "ca-cert", "", "The certificate of the new plugged-in CA")
+	cmd.Flags().StringVar(&args.caKey, "ca-key", "", "The private key of the new plugged-in CA")
+	cmd.Flags().StringVar(&args.certChain, "cert-chain", "", "The certificate chain of the new plugged-in CA")
+	cmd.Flags().StringVar(&args.rootCert, "root-cert", "", "The roots of the new plugged-in CA")
+	cmd.Flags().DurationVar(&args.gracePeriod, "grace-period", 0,
+		"How long the old root is trusted once the new CA signs, for the workload certificates issued by the old CA to be "+
+			"renewed. Defaults to the workload certificate TTL")
+	cmd.Flags().BoolVarP(&args.watch, "watch", "w", false, "Watch the rotation until it is over")
+	cmd.AddCommand(rotateStatusCommand(ctx))
+	return cmd
+}
+
+func rotateStatusCommand(ctx cli.Context) *cobra.Command {
+	var watch bool
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows the status of the root rotation of the Istio CA",
+		Example: `  # Show the status of the last root rotation
+  istioctl x ca rotate status`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			client, err := ctx.CLIClient()
+			if err != nil {
+				return err
+			}
+			if watch {
+				return watchRotation(cmd.Context(), cmd.OutOrStdout(), client.Kube(), ctx.IstioNamespace())
+			}
+			rotation, err := getRotation(client.Kube(), ctx.IstioNamespace())
+			if err != nil {
+				return err
+			}
+			printRotation(cmd.OutOrStdout(), rotation)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch the rotation until it is over")
+	return cmd
+}
+
+// readNewCA returns the files of the new plugged-in CA, keyed like in the rotation secret, after checking them.
+func (a *rotateArgs) readNewCA() (map[string][]byte, error) {
+	files := map[string]string{
+		ca.CACertFile:       a.caCert,
+		ca.CAPrivateKeyFile: a.caKey,
+		ca.CertChainFile:    a.certChain,
+		ca.RootCertFile:     a.rootCert,
+	}
+	data := map[string][]byte{}
+	for key, file := range files {
+		if file == "" {
+			continue
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		data[key] = b
+	}
+	if len(data) == 0 {
+		return data, nil
+	}
+	if len(data) != len(files) {
+		return nil, fmt.Errorf("--ca-cert, --ca-key, --cert-chain and --root-cert are all required for a plugged-in CA")
+	}
+	if _, err := util.NewVerifiedKeyCertBundleFromPem(data[ca.CACertFile], data[ca.CAPrivateKeyFile],
+		data[ca.CertChainFile], data[ca.RootCertFile], nil); err != nil {
+		return nil, fmt.Errorf("invalid new CA: %v", err)
+	}
+	return data, nil
+}
+
+// startRotation creates the rotation secret, replacing the one of a finished rotation.
+func startRotation(client kubernetes.Interface, namespace string, spec ca.RootRotationSpec, data map[string][]byte) error {
+	secrets := client.CoreV1().Secrets(namespace)
+	if rotation, err := getRotation(client, namespace); err == nil {
+		if !rotation.Done() {
+			return fmt.Errorf("a root rotation is in progress, in phase %s", phase(rotation))
+		}
+		if err := secrets.Delete(context.TODO(), ca.RootRotationSecret, metav1.DeleteOptions{}); err != nil {
+			return err
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	rotation, err := json.Marshal(ca.RootRotation{Spec: spec})
+	if err != nil {
+		return err
+	}
+	data[ca.RootRotationFile] = rotation
+	_, err = secrets.Create(context.TODO(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: ca.RootRotationSecret, Namespace: namespace},
+		Data:       data,
+	}, metav1.CreateOptions{})
+	return err
+}
+
+func getRotation(client kubernetes.Interface, namespace string) (*ca.RootRotation, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), ca.RootRotationSecret, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("no root rotation found in namespace %s: %w", namespace, err)
+		}
+		return nil, err
+	}
+	rotation := &ca.RootRotation{}
+	if data := secret.Data[ca.RootRotationFile]; len(data) > 0 {
+		if err := json.Unmarshal(data, rotation); err != nil {
+			return nil, fmt.Errorf("invalid root rotation: %v", err)
+		}
+	}
+	return rotation, nil
+}
+
+// watchRotation prints the rotation whenever it changes, until it is over.
+func watchRotation(ctx context.Context, w io.Writer, client kubernetes.Interface, namespace string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	last := ""
+	for {
+		rotation, err := getRotation(client, namespace)
+		if err != nil {
+			return err
+		}
+		var b bytes.Buffer
+		printRotation(&b, rotation)
+		if b.String() != last {
+			if last != "" {
+				fmt.Fprintln(w)
+			}
+			last = b.String()
+			_, _ = w.Write(b.Bytes())
+		}
+		if rotation.Done() {
+			if rotation.Status.Phase == ca.RootRotationFailed {
+				return fmt.Errorf("root rotation failed: %s", rotation.Status.Message)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(watchInterval):
+		}
+	}
+}
+
+func printRotation(w io.Writer, rotation *ca.RootRotation) {
+	status := rotation.Status
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "Phase:\t%s\n", phase(rotation))
+	if !status.TransitionTime.IsZero() {
+		fmt.Fprintf(tw, "Since:\t%s\n", status.TransitionTime.UTC().Format(time.RFC3339))
+	}
+	if status.Message != "" {
+		fmt.Fprintf(tw, "Message:\t%s\n", status.Message)
+	}
+	if status.GracePeriod.Duration > 0 {
+		fmt.Fprintf(tw, "Grace period:\t%s\n", status.GracePeriod.Duration)
+	}
+	if status.Phase == ca.RootRotationSwitchingSigning {
+		fmt.Fprintf(tw, "Old roots removed after:\t%s\n", status.TransitionTime.Add(status.GracePeriod.Duration).UTC().Format(time.RFC3339))
+	}
+	if len(status.OldRoots) > 0 {
+		fmt.Fprintf(tw, "Old roots:\t%s\n", strings.Join(status.OldRoots, "\n\t"))
+	}
+	if len(status.NewRoots) > 0 {
+		fmt.Fprintf(tw, "New roots:\t%s\n", strings.Join(status.NewRoots, "\n\t"))
+	}
+	_ = tw.Flush()
+	if len(status.Replicas) == 0 || rotation.Done() {
+		return
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "REPLICA\tPHASE\tACKED PROXIES\tWITHOUT ACKS")
+	for _, name := range slices.Sort(maps.Keys(status.Replicas)) {
+		r := status.Replicas[name]
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%d\n", name, r.Phase, r.Acked, r.Proxies, r.WithoutAcks)
+	}
+	_ = tw.Flush()
+}
+
+func phase(rotation *ca.RootRotation) ca.RootRotationPhase {
+	if rotation.Status.Phase == "" {
+		return ca.RootRotationPending
+	}
+	return rotation.Status.Phase
+}
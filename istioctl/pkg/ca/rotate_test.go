@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+const namespace = "istio-system"
+
+func setRotation(t *testing.T, client kubernetes.Interface, rotation ca.RootRotation) {
+	t.Helper()
+	data, err := json.Marshal(rotation)
+	assert.NoError(t, err)
+	secrets := client.CoreV1().Secrets(namespace)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: ca.RootRotationSecret, Namespace: namespace},
+		Data:       map[string][]byte{ca.RootRotationFile: data},
+	}
+	if _, err := secrets.Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		_, err = secrets.Create(context.Background(), secret, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+}
+
+func TestReadNewCA(t *testing.T) {
+	certs := filepath.Join(env.IstioSrc, "samples/certs")
+	full := &rotateArgs{
+		caCert:    filepath.Join(certs, "ca-cert.pem"),
+		caKey:     filepath.Join(certs, "ca-key.pem"),
+		certChain: filepath.Join(certs, "cert-chain.pem"),
+		rootCert:  filepath.Join(certs, "root-cert.pem"),
+	}
+	cases := []struct {
+		name    string
+		args    *rotateArgs
+		keys    int
+		wantErr string
+	}{
+		{name: "self-signed", args: &rotateArgs{}},
+		{name: "plugged-in", args: full, keys: 4},
+		{
+			name:    "partial",
+			args:    &rotateArgs{caCert: full.caCert, caKey: full.caKey},
+			wantErr: "--ca-cert, --ca-key, --cert-chain and --root-cert are all required for a plugged-in CA",
+		},
+		{
+			name: "mismatched key",
+			args: &rotateArgs{
+				caCert:    full.caCert,
+				caKey:     filepath.Join(certs, "ca-key-alt.pem"),
+				certChain: full.certChain,
+				rootCert:  full.rootCert,
+			},
+			wantErr: "invalid new CA",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.args.readNewCA()
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, len(data), tt.keys)
+		})
+	}
+}
+
+func TestStartRotation(t *testing.T) {
+	client := fake.NewClientset()
+	spec := ca.RootRotationSpec{GracePeriod: metav1.Duration{Duration: time.Hour}}
+	assert.NoError(t, startRotation(client, namespace, spec, map[string][]byte{}))
+	rotation, err := getRotation(client, namespace)
+	assert.NoError(t, err)
+	assert.Equal(t, rotation.Spec, spec)
+	assert.Equal(t, phase(rotation), ca.RootRotationPending)
+
+	// A rotation in progress is not replaced.
+	setRotation(t, client, ca.RootRotation{Status: ca.RootRotationStatus{Phase: ca.RootRotationAddingRoot}})
+	err = startRotation(client, namespace, spec, map[string][]byte{})
+	assert.Error(t, err)
+	assert.Equal(t, err.Error(), "a root rotation is in progress, in phase AddingRoot")
+
+	// A finished rotation is.
+	setRotation(t, client, ca.RootRotation{Status: ca.RootRotationStatus{Phase: ca.RootRotationCompleted}})
+	assert.NoError(t, startRotation(client, namespace, ca.RootRotationSpec{}, map[string][]byte{ca.CACertFile: []byte("cert")}))
+	secret, err := client.CoreV1().Secrets(namespace).Get(context.Background(), ca.RootRotationSecret, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, secret.Data[ca.CACertFile], []byte("cert"))
+	rotation, err = getRotation(client, namespace)
+	assert.NoError(t, err)
+	assert.Equal(t, phase(rotation), ca.RootRotationPending)
+}
+
+func TestPrintRotation(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotation := &ca.RootRotation{Status: ca.RootRotationStatus{
+		Phase:          ca.RootRotationSwitchingSigning,
+		TransitionTime: metav1.NewTime(since),
+		GracePeriod:    metav1.Duration{Duration: time.Hour},
+		OldRoots:       []string{"O=old (1)"},
+		NewRoots:       []string{"O=new (2)"},
+		Replicas: map[string]*ca.RootRotationReplica{
+			"istiod-b": {Phase: ca.RootRotationAddingRoot, Proxies: 3, Acked: 1, WithoutAcks: 1},
+			"istiod-a": {Phase: ca.RootRotationSwitchingSigning, Proxies: 2, Acked: 2},
+		},
+	}}
+	var out bytes.Buffer
+	printRotation(&out, rotation)
+	assert.Equal(t, out.String(), `Phase:                   SwitchingSigning
+Since:                   2024-01-01T00:00:00Z
+Grace period:            1h0m0s
+Old roots removed after: 2024-01-01T01:00:00Z
+Old roots:               O=old (1)
+New roots:               O=new (2)
+
+REPLICA  PHASE            ACKED PROXIES WITHOUT ACKS
+istiod-a SwitchingSigning 2/2           0
+istiod-b AddingRoot       1/3           1
+`)
+}
+
+func TestWatchRotation(t *testing.T) {
+	test.SetForTest(t, &watchInterval, time.Millisecond)
+	client := fake.NewClientset()
+
+	_, err := getRotation(client, namespace)
+	assert.Error(t, err)
+	assert.Error(t, watchRotation(context.Background(), &bytes.Buffer{}, client, namespace))
+
+	setRotation(t, client, ca.RootRotation{Status: ca.RootRotationStatus{Phase: ca.RootRotationCompleted}})
+	var out bytes.Buffer
+	assert.NoError(t, watchRotation(context.Background(), &out, client, namespace))
+	assert.Equal(t, out.String(), "Phase: Completed\n")
+
+	setRotation(t, client, ca.RootRotation{Status: ca.RootRotationStatus{Phase: ca.RootRotationFailed, Message: "boom"}})
+	err = watchRotation(context.Background(), &bytes.Buffer{}, client, namespace)
+	assert.Error(t, err)
+	assert.Equal(t, err.Error(), "root rotation failed: boom")
+
+	// A rotation in progress is watched until the context is done.
+	setRotation(t, client, ca.RootRotation{Status: ca.RootRotationStatus{Phase: ca.RootRotationAddingRoot}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, watchRotation(ctx, &bytes.Buffer{}, client, namespace), context.DeadlineExceeded)
+}
@@ -0,0 +1,158 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cniconstants "istio.io/istio/cni/pkg/constants"
+	"istio.io/istio/cni/pkg/podconfig"
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/completion"
+	ambientutil "istio.io/istio/istioctl/pkg/util/ambient"
+	"istio.io/istio/istioctl/pkg/ztunnelconfig"
+	"istio.io/istio/pkg/kube"
+)
+
+const (
+	autoBackend     = "auto"
+	iptablesBackend = "iptables"
+	nftablesBackend = "nftables"
+
+	cniNodeDaemonSet = "istio-cni-node"
+)
+
+// Cmd returns the `capture` command, which troubleshoots the traffic capture of the pods.
+func Cmd(ctx cli.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capture",
+		Short: "Troubleshoot the traffic capture of pods",
+	}
+	cmd.AddCommand(explainCmd(ctx))
+	return cmd
+}
+
+func explainCmd(ctx cli.Context) *cobra.Command {
+	var backend string
+
+	cmd := &cobra.Command{
+		Use:   "explain <pod-name>[.<namespace>]",
+		Short: "Explain the traffic redirection rules of a sidecar pod",
+		Long: `Explain computes the traffic redirection rules of a sidecar pod from its annotations and ProxyConfig, like the
+istio-cni plugin does, and explains which ports, CIDRs, users and groups are intercepted.
+
+The actual rules of the pod are fetched from the istio-cni node agent running on its node, and compared with the
+expected rules. Rules are compared after normalization, but the backends may still print some rules differently from
+how they were programmed: such rules show up as both removed (-) and added (+).`,
+		Example: `  # Explain the traffic capture of a pod
+  istioctl x capture explain productpage-v1-c7765c886-7zzd4.default
+
+  # Explain the traffic capture of a pod captured with the native nftables backend
+  istioctl x capture explain productpage-v1-c7765c886-7zzd4 -n default --backend nftables`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("explain requires a pod name")
+			}
+			switch backend {
+			case autoBackend, iptablesBackend, nftablesBackend:
+			default:
+				return fmt.Errorf("unknown backend %q, must be one of %s, %s or %s", backend, autoBackend, iptablesBackend, nftablesBackend)
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClient()
+			if err != nil {
+				return err
+			}
+			podName, ns, err := ctx.InferPodInfoFromTypedResource(args[0], ctx.Namespace())
+			if err != nil {
+				return err
+			}
+			pod, err := kubeClient.Kube().CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if ambientutil.InAmbient(pod) {
+				return fmt.Errorf("pod %s.%s is captured by ambient: its rules are programmed by the istio-cni node agent", podName, ns)
+			}
+			if !hasSidecar(pod) {
+				return fmt.Errorf("pod %s.%s does not have a sidecar, its traffic is not captured", podName, ns)
+			}
+
+			expected, cfg, err := expectedRules(pod)
+			if err != nil {
+				return fmt.Errorf("failed to compute the expected rules of pod %s.%s: %v", podName, ns, err)
+			}
+			actual, actualErr := fetchActualRules(kubeClient, pod, ctx.IstioNamespace())
+			return explain(c.OutOrStdout(), pod, cfg, expected, actual, actualErr, backend)
+		},
+		ValidArgsFunction: completion.ValidPodsNameArgs(ctx),
+	}
+	cmd.PersistentFlags().StringVar(&backend, "backend", autoBackend,
+		"The backend capturing the traffic of the pod: iptables or nftables. "+
+			"If auto, the backend is detected from the actual rules of the pod, and defaults to iptables.")
+	return cmd
+}
+
+func hasSidecar(pod *corev1.Pod) bool {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			if c.Name == podconfig.ProxyContainerName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// podRules are the rules found in the network namespace of a pod, as dumped by the istio-cni node agent.
+type podRules struct {
+	Iptables  string   `json:"iptables,omitempty"`
+	IP6tables string   `json:"ip6tables,omitempty"`
+	Nftables  string   `json:"nftables,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// fetchActualRules fetches the rules of the pod from the debug endpoint of the istio-cni node agent of its node.
+func fetchActualRules(kubeClient kube.CLIClient, pod *corev1.Pod, istioNamespace string) (*podRules, error) {
+	cniPod, err := ztunnelconfig.PodOnNodeFromDaemonset(pod.Spec.NodeName, cniNodeDaemonSet, istioNamespace, kubeClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the istio-cni node agent of node %q: %v", pod.Spec.NodeName, err)
+	}
+	query := url.Values{}
+	query.Set("name", pod.Name)
+	query.Set("namespace", pod.Namespace)
+	query.Set("uid", string(pod.UID))
+	path := strings.TrimPrefix(cniconstants.PodRulesDebugEndpoint, "/") + "?" + query.Encode()
+	out, err := kubeClient.EnvoyDoWithPort(context.TODO(), cniPod.Name, cniPod.Namespace, "GET", path, cniconstants.DefaultMonitoringPort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the rules from the istio-cni node agent %s: %v", cniPod.Name, err)
+	}
+	rules := &podRules{}
+	if err := json.Unmarshal(out, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse the rules from the istio-cni node agent %s: %v", cniPod.Name, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,220 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/api/annotation"
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pilot/test/util"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+const cniPodName = "istio-cni-node-7xk2p"
+
+func sidecarPod(annotations map[string]string, ips ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "productpage-v1",
+			Namespace:   "default",
+			UID:         "863b91d4-4b68-4efa-917f-4b560e3e86aa",
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{
+				{Name: "productpage"},
+				{
+					Name: "istio-proxy",
+					Args: []string{"proxy", "sidecar"},
+					Env:  []corev1.EnvVar{{Name: "ISTIO_META_DNS_CAPTURE", Value: "true"}},
+					SecurityContext: &corev1.SecurityContext{
+						RunAsUser:  ptr.Of[int64](1337),
+						RunAsGroup: ptr.Of[int64](1337),
+					},
+				},
+			},
+		},
+	}
+	for _, ip := range ips {
+		pod.Status.PodIPs = append(pod.Status.PodIPs, corev1.PodIP{IP: ip})
+	}
+	return pod
+}
+
+func cniNode() []runtime.Object {
+	labels := map[string]string{"k8s-app": "istio-cni-node"}
+	return []runtime.Object{
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio-cni-node", Namespace: "istio-system"},
+			Spec:       appsv1.DaemonSetSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: cniPodName, Namespace: "istio-system", Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+		},
+	}
+}
+
+// saved returns the rules of an iptables-restore input, as printed by iptables-save.
+func saved(restore string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(restore, "* ", "*"), "-p tcp", "-p tcp -m tcp")
+}
+
+// listed returns the rules added by an nftables transaction, as printed by `nft list ruleset`.
+func listed(tx string) string {
+	out := &bytes.Buffer{}
+	var table, chain string
+	for _, r := range parseNftTransaction(tx) {
+		fields := strings.SplitN(r.text, " ", 3)
+		if fields[0] != table {
+			if table != "" {
+				fmt.Fprint(out, "\t}\n}\n")
+			}
+			table, chain = fields[0], ""
+			fmt.Fprintf(out, "table inet %s {\n", table)
+		}
+		if fields[1] != chain {
+			if chain != "" {
+				fmt.Fprint(out, "\t}\n")
+			}
+			chain = fields[1]
+			fmt.Fprintf(out, "\tchain %s {\n", chain)
+		}
+		fmt.Fprintf(out, "\t\t%s\n", strings.ReplaceAll(fields[2], "counter", "counter packets 0 bytes 0"))
+	}
+	fmt.Fprint(out, "\t}\n}\n")
+	return out.String()
+}
+
+func TestExplain(t *testing.T) {
+	pod := sidecarPod(map[string]string{
+		annotation.SidecarTrafficExcludeOutboundIPRanges.Name: "10.96.0.0/12",
+		annotation.SidecarTrafficExcludeInboundPorts.Name:     "9090",
+	}, "10.244.0.5")
+	expected, _, err := expectedRules(pod)
+	assert.NoError(t, err)
+
+	dualStackPod := sidecarPod(nil, "10.244.0.5", "fd00:10:244::5")
+	dualStackPod.Spec.Containers[1].Env = append(dualStackPod.Spec.Containers[1].Env, corev1.EnvVar{Name: "ISTIO_DUAL_STACK", Value: "true"})
+
+	drifted := saved(expected.Iptables)
+	drifted = strings.Replace(drifted, "-A ISTIO_OUTPUT -d 10.96.0.0/12 -j RETURN\n", "", 1)
+	drifted = strings.Replace(drifted, "COMMIT", "-A PREROUTING -p udp -j ACCEPT\nCOMMIT", 1)
+
+	cases := []struct {
+		name    string
+		args    []string
+		objects []runtime.Object
+		actual  *podRules
+	}{
+		{
+			name:    "iptables",
+			objects: append(cniNode(), pod),
+			actual:  &podRules{Iptables: saved(expected.Iptables), Errors: []string{"nft list ruleset failed: exit status 1"}},
+		},
+		{
+			name:    "iptables-drifted",
+			objects: append(cniNode(), pod),
+			actual:  &podRules{Iptables: drifted},
+		},
+		{
+			name:    "nftables",
+			objects: append(cniNode(), pod),
+			actual:  &podRules{Nftables: listed(expected.Nftables)},
+		},
+		{
+			name:    "dual-stack-missing-rules",
+			objects: append(cniNode(), dualStackPod),
+			args:    []string{"--backend", "iptables"},
+			actual:  &podRules{},
+		},
+		{
+			name:    "no-cni-node",
+			objects: []runtime.Object{pod},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			results := map[string][]byte{}
+			if tt.actual != nil {
+				res, err := json.Marshal(tt.actual)
+				assert.NoError(t, err)
+				results[cniPodName] = res
+			}
+			ctx := cli.NewFakeContext(&cli.NewFakeContextOption{
+				Namespace:      "default",
+				IstioNamespace: "istio-system",
+				Objects:        tt.objects,
+				Results:        results,
+			})
+			out := &bytes.Buffer{}
+			cmd := Cmd(ctx)
+			cmd.SetArgs(append([]string{"explain", "productpage-v1"}, tt.args...))
+			cmd.SetOut(out)
+			cmd.SetErr(out)
+			assert.NoError(t, cmd.Execute())
+			util.CompareContent(t, out.Bytes(), "testdata/"+tt.name+".golden")
+		})
+	}
+}
+
+func TestExplainUncapturedPods(t *testing.T) {
+	ambientPod := sidecarPod(map[string]string{annotation.AmbientRedirection.Name: constants.AmbientRedirectionEnabled}, "10.244.0.5")
+	ambientPod.Spec.Containers = ambientPod.Spec.Containers[:1]
+	noSidecarPod := sidecarPod(nil, "10.244.0.5")
+	noSidecarPod.Spec.Containers = noSidecarPod.Spec.Containers[:1]
+
+	for name, tt := range map[string]struct {
+		pod *corev1.Pod
+		err string
+	}{
+		"ambient":    {ambientPod, "is captured by ambient"},
+		"no sidecar": {noSidecarPod, "does not have a sidecar"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := cli.NewFakeContext(&cli.NewFakeContextOption{Namespace: "default", Objects: []runtime.Object{tt.pod}})
+			cmd := Cmd(ctx)
+			cmd.SetArgs([]string{"explain", "productpage-v1"})
+			cmd.SetOut(&bytes.Buffer{})
+			cmd.SetErr(&bytes.Buffer{})
+			err := cmd.Execute()
+			assert.Error(t, err)
+			assert.Equal(t, strings.Contains(err.Error(), tt.err), true)
+		})
+	}
+}
+
+func TestNormalizeIptablesRule(t *testing.T) {
+	assert.Equal(t,
+		normalizeIptablesRule("-A ISTIO_OUTPUT -o lo ! -d 127.0.0.1/32 -p tcp ! --dport 15008 -m owner --uid-owner 1337 -j ISTIO_IN_REDIRECT"),
+		normalizeIptablesRule("-A ISTIO_OUTPUT ! -d 127.0.0.1 -o lo -p tcp -m tcp ! --dport 15008 -m owner --uid-owner 1337 -j ISTIO_IN_REDIRECT"))
+	assert.Equal(t,
+		normalizeIptablesRule("-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN") !=
+			normalizeIptablesRule("-A ISTIO_OUTPUT -m owner ! --uid-owner 1337 -j RETURN"),
+		true)
+}
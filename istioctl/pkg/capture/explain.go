@@ -0,0 +1,351 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pmezard/go-difflib/difflib"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/knftables"
+
+	"istio.io/istio/cni/pkg/podconfig"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/tools/common/config"
+	iptablescapture "istio.io/istio/tools/istio-iptables/pkg/capture"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+	nftablescapture "istio.io/istio/tools/istio-nftables/pkg/capture"
+)
+
+// captureConfig returns the capture configuration the istio-cni plugin programs for the pod, from its annotations
+// and the environment of its proxy, which carries its ProxyConfig.
+func captureConfig(pod *corev1.Pod) (*config.Config, error) {
+	redirect, err := podconfig.NewRedirect(podconfig.ExtractPodInfo(pod))
+	if err != nil {
+		return nil, err
+	}
+	cfg := redirect.CaptureConfig()
+
+	// Like config.FillConfigFromEnvironment does inside the pod network namespace, from the IPs of the pod.
+	var podIPs []netip.Addr
+	for _, ip := range pod.Status.PodIPs {
+		if addr, err := netip.ParseAddr(ip.IP); err == nil {
+			podIPs = append(podIPs, addr)
+		}
+	}
+	if len(podIPs) == 0 {
+		return nil, fmt.Errorf("the pod has no IP")
+	}
+	cfg.HostIP = podIPs[0]
+	cfg.EnableIPv6 = podIPs[0].Is6()
+	if cfg.DualStack {
+		cfg.EnableIPv6 = slices.FindFunc(podIPs, netip.Addr.Is6) != nil
+	}
+	return cfg, nil
+}
+
+// restoreRecorder runs the iptables capture in dry-run mode, recording the rules it would restore.
+type restoreRecorder struct {
+	dep.DependenciesStub
+	restored map[string]string
+}
+
+func (r *restoreRecorder) Run(_ *log.Scope, _ bool, cmd constants.IptablesCmd, iptVer *dep.IptablesVersion,
+	stdin io.ReadSeeker, _ ...string,
+) (*bytes.Buffer, error) {
+	if cmd == constants.IPTablesRestore && stdin != nil {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+		r.restored[iptVer.DetectedRestoreBinary] += string(data)
+	}
+	return &bytes.Buffer{}, nil
+}
+
+// expectedRules renders the rules the istio-cni plugin programs for the pod, with both backends.
+func expectedRules(pod *corev1.Pod) (*podRules, *config.Config, error) {
+	cfg, err := captureConfig(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recorder := &restoreRecorder{restored: map[string]string{}}
+	iptConfigurator, err := iptablescapture.NewIptablesConfigurator(cfg, recorder)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := iptConfigurator.Run(); err != nil {
+		return nil, nil, err
+	}
+	iptV4, _ := recorder.DetectIptablesVersion(false)
+	iptV6, _ := recorder.DetectIptablesVersion(true)
+
+	nftConfigurator, err := nftablescapture.NewNftablesConfigurator(cfg, func(family knftables.Family, table string) (nftablescapture.NftablesAPI, error) {
+		return nftablescapture.NewMockNftables(family, table), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := nftConfigurator.Run()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &podRules{
+		Iptables:  recorder.restored[iptV4.DetectedRestoreBinary],
+		IP6tables: recorder.restored[iptV6.DetectedRestoreBinary],
+		Nftables:  tx.String(),
+	}, cfg, nil
+}
+
+// explain writes the explanation of the capture configuration of the pod, and the diff of its expected and actual
+// rules. If the actual rules could not be fetched, the expected rules are written instead.
+func explain(w io.Writer, pod *corev1.Pod, cfg *config.Config, expected, actual *podRules, actualErr error, backend string) error {
+	if backend == autoBackend {
+		backend = iptablesBackend
+		if actual != nil && len(parseNftRuleset(actual.Nftables)) > 0 {
+			backend = nftablesBackend
+		}
+	}
+	fmt.Fprintf(w, "Traffic capture of pod %s.%s, with %s:\n\n", pod.Name, pod.Namespace, backend)
+	if err := explainConfig(w, cfg); err != nil {
+		return err
+	}
+
+	if actualErr != nil {
+		fmt.Fprintf(w, "\nThe actual rules could not be fetched: %v\n", actualErr)
+		actual = &podRules{}
+	} else {
+		for _, err := range actual.Errors {
+			fmt.Fprintf(w, "\nWarning: %s\n", err)
+		}
+	}
+
+	type section struct {
+		title            string
+		expected, actual []rule
+	}
+	var sections []section
+	if backend == nftablesBackend {
+		sections = append(sections, section{"nftables", parseNftTransaction(expected.Nftables), parseNftRuleset(actual.Nftables)})
+	} else {
+		sections = append(sections, section{"iptables", parseIptables(expected.Iptables), parseIptables(actual.Iptables)})
+		if actualV6 := parseIptables(actual.IP6tables); cfg.EnableIPv6 || len(actualV6) > 0 {
+			sections = append(sections, section{"ip6tables", parseIptables(expected.IP6tables), actualV6})
+		}
+	}
+	for _, s := range sections {
+		if actualErr != nil {
+			fmt.Fprintf(w, "\nExpected %s rules:\n", s.title)
+			for _, r := range s.expected {
+				fmt.Fprintf(w, "  %s\n", r.text)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "\n%s rules (-: expected but missing, +: present but unexpected):\n", s.title)
+		if !writeRuleDiff(w, s.expected, s.actual) {
+			fmt.Fprintf(w, "The actual %s rules match the expected rules.\n", s.title)
+		}
+	}
+	return nil
+}
+
+func explainConfig(w io.Writer, cfg *config.Config) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	inboundMode := "REDIRECT"
+	if cfg.InboundInterceptionMode == "TPROXY" {
+		inboundMode = "TPROXY"
+	}
+	fmt.Fprintf(tw, "Inbound, redirected to port %s with %s:\n", cfg.InboundCapturePort, inboundMode)
+	fmt.Fprintf(tw, "  Captured ports:\t%s\n", listOrAll(cfg.InboundPortsInclude))
+	fmt.Fprintf(tw, "  Excluded ports:\t%s\n", listOrAll(cfg.InboundPortsExclude))
+	fmt.Fprintf(tw, "Outbound, redirected to port %s:\n", cfg.ProxyPort)
+	fmt.Fprintf(tw, "  Captured CIDRs:\t%s\n", listOrAll(cfg.OutboundIPRangesInclude))
+	fmt.Fprintf(tw, "  Excluded CIDRs:\t%s\n", listOrAll(cfg.OutboundIPRangesExclude))
+	fmt.Fprintf(tw, "  Captured ports to any destination:\t%s\n", listOrAll(cfg.OutboundPortsInclude))
+	fmt.Fprintf(tw, "  Excluded ports:\t%s\n", listOrAll(cfg.OutboundPortsExclude))
+	fmt.Fprintf(tw, "Never captured:\n")
+	fmt.Fprintf(tw, "  Traffic of the proxy UID:\t%s\n", cfg.ProxyUID)
+	fmt.Fprintf(tw, "  Traffic of the proxy GID:\t%s\n", cfg.ProxyGID)
+	fmt.Fprintf(tw, "  Inbound tunnel port:\t%s\n", cfg.InboundTunnelPort)
+	fmt.Fprintf(tw, "  Interfaces:\t%s\n", listOrAll(cfg.ExcludeInterfaces))
+	dns := "not captured"
+	if cfg.RedirectDNS {
+		dns = "redirected to port " + constants.IstioAgentDNSListenerPort
+	}
+	fmt.Fprintf(tw, "DNS:\t%s\n", dns)
+	fmt.Fprintf(tw, "Rerouted virtual interfaces:\t%s\n", listOrAll(cfg.RerouteVirtualInterfaces))
+	fmt.Fprintf(tw, "Invalid packets dropped:\t%t\n", cfg.DropInvalid)
+	fmt.Fprintf(tw, "IPv6:\t%t\n", cfg.EnableIPv6)
+	return tw.Flush()
+}
+
+func listOrAll(list string) string {
+	switch strings.TrimSpace(list) {
+	case "*":
+		return "all"
+	case "":
+		return "none"
+	}
+	return strings.Join(slices.Map(strings.Split(list, ","), strings.TrimSpace), ", ")
+}
+
+// rule is a rule as printed by a backend, and the key it is compared with.
+type rule struct {
+	key  string
+	text string
+}
+
+// parseIptables returns the rules of an iptables-save or iptables-restore input, prefixed with their table.
+func parseIptables(save string) []rule {
+	var rules []rule
+	var table string
+	for _, line := range strings.Split(save, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		case strings.HasPrefix(line, "-A "):
+			rules = append(rules, rule{key: table + " " + normalizeIptablesRule(line), text: table + " " + line})
+		}
+	}
+	return rules
+}
+
+// normalizeIptablesRule returns the options of the rule in a stable order, without the matches loaded explicitly,
+// since iptables-save adds and reorders them.
+func normalizeIptablesRule(line string) string {
+	var options []string
+	var option []string
+	flush := func() {
+		if len(option) > 0 && option[0] != "-m" {
+			options = append(options, strings.Join(option, " "))
+		}
+		option = nil
+	}
+	for _, field := range strings.Fields(strings.ReplaceAll(line, `"`, "")) {
+		negated := len(option) == 1 && option[0] == "!"
+		if field == "!" || (strings.HasPrefix(field, "-") && !negated) {
+			flush()
+		}
+		option = append(option, trimHostPrefixLength(field))
+	}
+	flush()
+	sort.Strings(options)
+	return strings.Join(options, " ")
+}
+
+// parseNftTransaction returns the rules added by an nftables transaction, prefixed with their table and chain.
+func parseNftTransaction(tx string) []rule {
+	var rules []rule
+	for _, line := range strings.Split(tx, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[1] != "rule" || (fields[0] != "add" && fields[0] != "insert") {
+			continue
+		}
+		prefix := fields[3] + " " + fields[4] + " "
+		rules = append(rules, rule{key: prefix + normalizeNftRule(fields[5:]), text: prefix + strings.Join(fields[5:], " ")})
+	}
+	return rules
+}
+
+// parseNftRuleset returns the rules of the istio tables of an `nft list ruleset` output, prefixed with their table
+// and chain.
+func parseNftRuleset(ruleset string) []rule {
+	var rules []rule
+	var table, chain string
+	// depth of the blocks other than tables and chains, like sets
+	otherBlocks := 0
+	for _, line := range strings.Split(ruleset, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case otherBlocks > 0 && fields[len(fields)-1] == "{":
+			otherBlocks++
+		case otherBlocks > 0 && fields[0] == "}":
+			otherBlocks--
+		case otherBlocks > 0:
+		case fields[0] == "table" && len(fields) >= 3:
+			table = fields[2]
+		case fields[0] == "chain" && len(fields) >= 2 && table != "":
+			chain = fields[1]
+		case fields[0] == "}" && chain != "":
+			chain = ""
+		case fields[0] == "}":
+			table = ""
+		case fields[len(fields)-1] == "{":
+			otherBlocks++
+		case chain == "" || !strings.HasPrefix(table, "istio-"):
+		case fields[0] == "type" || fields[0] == "policy":
+			// the hook of the chain
+		default:
+			prefix := table + " " + chain + " "
+			rules = append(rules, rule{key: prefix + normalizeNftRule(fields), text: prefix + strings.Join(fields, " ")})
+		}
+	}
+	return rules
+}
+
+// normalizeNftRule returns the rule without the quotes and counter values added by `nft list`.
+func normalizeNftRule(fields []string) string {
+	var normalized []string
+	for i := 0; i < len(fields); i++ {
+		if (fields[i] == "packets" || fields[i] == "bytes") && i+1 < len(fields) {
+			i++
+			continue
+		}
+		normalized = append(normalized, trimHostPrefixLength(strings.ReplaceAll(fields[i], `"`, "")))
+	}
+	return strings.Join(normalized, " ")
+}
+
+// trimHostPrefixLength trims the prefix length of host addresses, which some backends omit when printing them.
+func trimHostPrefixLength(field string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(field, "/32"), "/128")
+}
+
+// writeRuleDiff writes the expected rules, with the missing ones prefixed with '-', and the unexpected actual rules
+// prefixed with '+'. Returns whether the rules differ.
+func writeRuleDiff(w io.Writer, expected, actual []rule) bool {
+	keys := func(rules []rule) []string {
+		return slices.Map(rules, func(r rule) string { return r.key })
+	}
+	differ := false
+	for _, op := range difflib.NewMatcher(keys(expected), keys(actual)).GetOpCodes() {
+		if op.Tag == 'e' {
+			for _, r := range expected[op.I1:op.I2] {
+				fmt.Fprintf(w, "  %s\n", r.text)
+			}
+			continue
+		}
+		differ = true
+		for _, r := range expected[op.I1:op.I2] {
+			fmt.Fprintf(w, "- %s\n", r.text)
+		}
+		for _, r := range actual[op.J1:op.J2] {
+			fmt.Fprintf(w, "+ %s\n", r.text)
+		}
+	}
+	return differ
+}
@@ -16,7 +16,9 @@ package cli
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net/http"
 
 	"google.golang.org/grpc/credentials"
 
@@ -59,6 +61,10 @@ func (c MockClient) AllDiscoveryDo(_ context.Context, _, _ string) (map[string][
 	return c.Results, nil
 }
 
+func (c MockClient) AllDiscoveryDoWithTLS(_ context.Context, _, _ string, _ int, _ *tls.Config, _ http.Header) (map[string][]byte, error) {
+	return c.Results, nil
+}
+
 func (c MockClient) EnvoyDoWithPort(ctx context.Context, podName, podNamespace, method, path string, port int) ([]byte, error) {
 	results, ok := c.Results[podName]
 	if !ok {
@@ -0,0 +1,376 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package describe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"istio.io/api/annotation"
+	"istio.io/api/label"
+	clientnetworking "istio.io/client-go/pkg/apis/networking/v1"
+	securityclient "istio.io/client-go/pkg/apis/security/v1"
+	istioclient "istio.io/client-go/pkg/clientset/versioned"
+	ztunnelDump "istio.io/istio/istioctl/pkg/writer/ztunnel/configdump"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller/ambient"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+)
+
+const ztunnelAdminPort = 15000
+
+func isAmbient(pod *corev1.Pod) bool {
+	return pod.Annotations[annotation.AmbientRedirection.Name] == constants.AmbientRedirectionEnabled
+}
+
+// waypointInfo is the waypoint resolved for a workload or service.
+type waypointInfo struct {
+	name      string
+	namespace string
+	// problem explains why the waypoint configured for the object is not used, if it is not.
+	problem string
+}
+
+func (w *waypointInfo) String() string {
+	if w == nil {
+		return "none"
+	}
+	res := w.name + "." + w.namespace
+	if w.problem != "" {
+		res += " (not used: " + w.problem + ")"
+	}
+	return res
+}
+
+func (w *waypointInfo) used() bool {
+	return w != nil && w.problem == ""
+}
+
+// resolveWaypoint finds the waypoint serving traffic of trafficType for an object, following the istio.io/use-waypoint
+// label on the object and then on its namespace, the same way istiod does.
+func resolveWaypoint(kubeClient kube.CLIClient, meta metav1.ObjectMeta, ns *corev1.Namespace, trafficType string) *waypointInfo {
+	name, namespace, isNone := useWaypoint(meta, meta.Namespace)
+	if isNone {
+		return nil
+	}
+	if name == "" && ns != nil {
+		name, namespace, _ = useWaypoint(ns.ObjectMeta, meta.Namespace)
+	}
+	if name == "" {
+		return nil
+	}
+	w := &waypointInfo{name: name, namespace: namespace}
+	gw, err := kubeClient.GatewayAPI().GatewayV1().Gateways(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			w.problem = "waypoint does not exist"
+		} else {
+			w.problem = err.Error()
+		}
+		return w
+	}
+	if len(gw.Status.Addresses) == 0 {
+		w.problem = "waypoint is not ready"
+		return w
+	}
+	if supported := waypointTrafficType(kubeClient, gw); supported != trafficType && supported != constants.AllTraffic {
+		w.problem = fmt.Sprintf("waypoint is for %s traffic", supported)
+	}
+	return w
+}
+
+func useWaypoint(meta metav1.ObjectMeta, defaultNamespace string) (name, namespace string, isNone bool) {
+	name, ok := meta.Labels[label.IoIstioUseWaypoint.Name]
+	if !ok {
+		return "", "", false
+	}
+	if name == "none" {
+		return "", "", true
+	}
+	namespace = defaultNamespace
+	if override, f := meta.Labels[label.IoIstioUseWaypointNamespace.Name]; f {
+		namespace = override
+	}
+	return name, namespace, false
+}
+
+func waypointTrafficType(kubeClient kube.CLIClient, gw *gatewayv1.Gateway) string {
+	if tt, f := gw.Labels[label.IoIstioWaypointFor.Name]; f {
+		return tt
+	}
+	gc, err := kubeClient.GatewayAPI().GatewayV1().GatewayClasses().Get(context.TODO(), string(gw.Spec.GatewayClassName), metav1.GetOptions{})
+	if err == nil {
+		if tt, f := gc.Labels[label.IoIstioWaypointFor.Name]; f {
+			return tt
+		}
+	}
+	return constants.ServiceTraffic
+}
+
+// describeAmbientPod explains how traffic to a pod captured by ztunnel is handled: the waypoints serving the pod and
+// its services, the routing configuration applied by those waypoints, where the AuthorizationPolicies applying to it are
+// enforced, and the pod's state in ztunnel.
+func describeAmbientPod(writer io.Writer, kubeClient kube.CLIClient, configClient istioclient.Interface, pod *corev1.Pod,
+	matchingServices []corev1.Service, podsLabels []klabels.Set, istioNamespace string,
+) error {
+	ns, err := kubeClient.Kube().CoreV1().Namespaces().Get(context.TODO(), pod.Namespace, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	if err != nil {
+		ns = nil
+	}
+	meshCfg, err := getMeshConfig(kubeClient, istioNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to fetch mesh config: %v", err)
+	}
+
+	workloadWaypoint := resolveWaypoint(kubeClient, pod.ObjectMeta, ns, constants.WorkloadTraffic)
+	serviceWaypoints := map[string]*waypointInfo{}
+	for row, svc := range matchingServices {
+		if row != 0 {
+			fmt.Fprintf(writer, "--------------------\n")
+		}
+		printService(writer, svc, pod)
+		w := resolveWaypoint(kubeClient, svc.ObjectMeta, ns, constants.ServiceTraffic)
+		serviceWaypoints[svc.Name] = w
+		fmt.Fprintf(writer, "   Waypoint: %s\n", w)
+		if err := describeAmbientServiceRouting(writer, configClient, svc, w, podsLabels, meshCfg.RootNamespace); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(writer, "--------------------\n")
+	fmt.Fprintf(writer, "Ambient mode: traffic is captured by ztunnel\n")
+	fmt.Fprintf(writer, "   Workload waypoint: %s\n", workloadWaypoint)
+
+	if err := describeAmbientAuthorizationPolicies(writer, kubeClient, pod, workloadWaypoint, serviceWaypoints, meshCfg.RootNamespace); err != nil {
+		return err
+	}
+	describeZtunnelWorkload(writer, kubeClient, pod, workloadWaypoint)
+	return nil
+}
+
+// describeAmbientServiceRouting prints the DestinationRule and VirtualService for the service. There is no sidecar
+// to read the applied configuration from, so they are looked up by host in the namespaces visible to the waypoint, in
+// the order istiod prefers them. Only a waypoint applies a VirtualService; without one it is reported as ignored.
+func describeAmbientServiceRouting(writer io.Writer, configClient istioclient.Interface, svc corev1.Service, w *waypointInfo,
+	podsLabels []klabels.Set, rootNamespace string,
+) error {
+	svcHost := host.Name(extendFQDN(svc.Name + "." + svc.Namespace))
+	namespaces := []string{svc.Namespace}
+	if w != nil {
+		namespaces = append(namespaces, w.namespace)
+	}
+	namespaces = slices.FilterDuplicates(append(namespaces, rootNamespace))
+
+	var dr *clientnetworking.DestinationRule
+	var vs *clientnetworking.VirtualService
+	for _, ns := range namespaces {
+		if dr == nil {
+			drs, err := configClient.NetworkingV1().DestinationRules(ns).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to fetch DestinationRules in %s: %v", ns, err)
+			}
+			for _, d := range drs.Items {
+				if hostMatchesService(svcHost, d.Spec.Host, d.Namespace) {
+					dr = d
+					break
+				}
+			}
+		}
+		if vs == nil {
+			vss, err := configClient.NetworkingV1().VirtualServices(ns).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to fetch VirtualServices in %s: %v", ns, err)
+			}
+			for _, v := range vss.Items {
+				if len(v.Spec.Gateways) > 0 && !slices.Contains(v.Spec.Gateways, constants.IstioMeshGateway) {
+					continue
+				}
+				if slices.IndexFunc(v.Spec.Hosts, func(h string) bool { return hostMatchesService(svcHost, h, v.Namespace) }) >= 0 {
+					vs = v
+					break
+				}
+			}
+		}
+	}
+
+	var matchingSubsets, nonmatchingSubsets []string
+	if dr != nil {
+		printDestinationRule(writer, printLevel0, dr, podsLabels)
+		matchingSubsets, nonmatchingSubsets = getDestRuleSubsets(dr.Spec.Subsets, podsLabels)
+	}
+	if vs != nil {
+		if w.used() {
+			printVirtualService(writer, printLevel0, vs, svc, matchingSubsets, nonmatchingSubsets, dr)
+		} else {
+			fmt.Fprintf(writer, "WARNING: VirtualService %s is ignored; the service has no waypoint to apply it\n", kname(vs.ObjectMeta))
+		}
+	}
+	return nil
+}
+
+// hostMatchesService checks whether a host of a config in namespace ns applies to the service host.
+func hostMatchesService(svcHost host.Name, h string, ns string) bool {
+	return svcHost.SubsetOf(model.ResolveShortnameToFQDN(h, config.Meta{Namespace: ns, Domain: constants.DefaultClusterLocalDomain}))
+}
+
+// describeAmbientAuthorizationPolicies lists the AuthorizationPolicies applying to the pod, grouped by where they are
+// enforced. Policies selecting the pod are enforced by ztunnel, while policies targeting a waypoint, or a service using
+// a waypoint, are enforced by that waypoint.
+func describeAmbientAuthorizationPolicies(writer io.Writer, kubeClient kube.CLIClient, pod *corev1.Pod,
+	workloadWaypoint *waypointInfo, serviceWaypoints map[string]*waypointInfo, rootNamespace string,
+) error {
+	// waypoints holds the waypoints used by the pod, keyed by their name.namespace. Policies attached to a waypoint live
+	// in its namespace, which may differ from the pod's.
+	waypoints := map[string]bool{}
+	var waypointNamespaces []string
+	for _, w := range append(maps.Values(serviceWaypoints), workloadWaypoint) {
+		if w.used() {
+			waypoints[w.name+"."+w.namespace] = true
+			waypointNamespaces = append(waypointNamespaces, w.namespace)
+		}
+	}
+
+	var policies []*securityclient.AuthorizationPolicy
+	for _, ns := range slices.FilterDuplicates(append([]string{rootNamespace, pod.Namespace}, slices.Sort(waypointNamespaces)...)) {
+		l, err := kubeClient.Istio().SecurityV1().AuthorizationPolicies(ns).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to fetch AuthorizationPolicies in %s: %v", ns, err)
+		}
+		policies = append(policies, l.Items...)
+	}
+
+	var ztunnel, warnings []string
+	atWaypoint := map[string][]string{}
+	for _, p := range policies {
+		name := p.Name + "." + p.Namespace
+		targetRefs := model.GetTargetRefs(&p.Spec)
+		if len(targetRefs) == 0 {
+			// Selector policies only apply to the workloads of their namespace, or of every namespace from the root
+			// namespace; the ones of the waypoint namespaces do not apply to the pod.
+			if p.Namespace != pod.Namespace && p.Namespace != rootNamespace {
+				continue
+			}
+			if !klabels.SelectorFromSet(p.Spec.GetSelector().GetMatchLabels()).Matches(klabels.Set(pod.Labels)) {
+				continue
+			}
+			ztunnel = append(ztunnel, name)
+			if w := ambient.ZtunnelAuthorizationPolicyWarning(rootNamespace, p); w != "" {
+				warnings = append(warnings, fmt.Sprintf("%s: %s", name, w))
+			}
+			continue
+		}
+		for _, ref := range targetRefs {
+			switch {
+			case ref.Kind == gvk.KubernetesGateway.Kind && waypoints[ref.Name+"."+p.Namespace]:
+				atWaypoint[ref.Name+"."+p.Namespace] = append(atWaypoint[ref.Name+"."+p.Namespace], name)
+			case ref.Kind == gvk.GatewayClass.Kind && ref.Name == constants.WaypointGatewayClassName && p.Namespace == rootNamespace:
+				for w := range waypoints {
+					atWaypoint[w] = append(atWaypoint[w], name)
+				}
+			case ref.Kind == gvk.Service.Kind && p.Namespace == pod.Namespace:
+				if w := serviceWaypoints[ref.Name]; w.used() {
+					key := w.name + "." + w.namespace
+					atWaypoint[key] = append(atWaypoint[key], fmt.Sprintf("%s (service %s)", name, ref.Name))
+				} else if _, f := serviceWaypoints[ref.Name]; f {
+					warnings = append(warnings, fmt.Sprintf("%s: targets service %s, which has no waypoint; it is not enforced", name, ref.Name))
+				}
+			}
+		}
+	}
+
+	if len(ztunnel) > 0 {
+		fmt.Fprintf(writer, "AuthorizationPolicies enforced by ztunnel:\n")
+		fmt.Fprintf(writer, "   %s\n", strings.Join(ztunnel, ", "))
+	}
+	for _, w := range slices.Sort(maps.Keys(atWaypoint)) {
+		fmt.Fprintf(writer, "AuthorizationPolicies enforced by waypoint %s:\n", w)
+		fmt.Fprintf(writer, "   %s\n", strings.Join(slices.FilterDuplicates(atWaypoint[w]), ", "))
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(writer, "WARNING: %s\n", w)
+	}
+	return nil
+}
+
+// describeZtunnelWorkload reports the pod's workload as seen by the ztunnel on its node.
+func describeZtunnelWorkload(writer io.Writer, kubeClient kube.CLIClient, pod *corev1.Pod, workloadWaypoint *waypointInfo) {
+	ztunnel, err := ztunnelForNode(kubeClient, pod.Spec.NodeName)
+	if err != nil {
+		fmt.Fprintf(writer, "WARNING: could not find ztunnel for node %q: %v\n", pod.Spec.NodeName, err)
+		return
+	}
+	fmt.Fprintf(writer, "Ztunnel: %s\n", kname(ztunnel.ObjectMeta))
+	b, err := kubeClient.EnvoyDoWithPort(context.TODO(), ztunnel.Name, ztunnel.Namespace, "GET", "config_dump", ztunnelAdminPort)
+	if err != nil {
+		fmt.Fprintf(writer, "   WARNING: failed to fetch ztunnel config dump: %v\n", err)
+		return
+	}
+	cw := &ztunnelDump.ConfigWriter{}
+	if err := cw.Prime(b); err != nil {
+		fmt.Fprintf(writer, "   WARNING: %v\n", err)
+		return
+	}
+	var wl *ztunnelDump.ZtunnelWorkload
+	for _, w := range cw.Dump().Workloads {
+		if w.Name == pod.Name && w.Namespace == pod.Namespace {
+			wl = w
+			break
+		}
+	}
+	if wl == nil {
+		fmt.Fprintf(writer, "   WARNING: workload %s is not in the ztunnel configuration; it may not be synced yet\n", kname(pod.ObjectMeta))
+		return
+	}
+	fmt.Fprintf(writer, "   Workload: %s, protocol %s\n", wl.Status, wl.Protocol)
+	if wl.Waypoint != nil {
+		fmt.Fprintf(writer, "   Waypoint: %s\n", wl.Waypoint.Destination)
+	} else if workloadWaypoint.used() {
+		fmt.Fprintf(writer, "   WARNING: ztunnel has no waypoint for the workload, expected %s\n", workloadWaypoint)
+	}
+	if len(wl.AuthorizationPolicies) > 0 {
+		fmt.Fprintf(writer, "   Policies: %s\n", strings.Join(wl.AuthorizationPolicies, ", "))
+	}
+}
+
+func ztunnelForNode(kubeClient kube.CLIClient, node string) (*corev1.Pod, error) {
+	pods, err := kubeClient.Kube().CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app=ztunnel",
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range pods.Items {
+		if p.Spec.NodeName == node && p.Status.Phase == corev1.PodRunning {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no running ztunnel pod")
+}
@@ -108,7 +108,11 @@ func podDescribeCmd(ctx cli.Context) *cobra.Command {
 		Aliases: []string{"po"},
 		Short:   "Describe pods and their Istio configuration [kube-only]",
 		Long: `Analyzes pod, its Services, DestinationRules, and VirtualServices and reports
-the configuration objects that affect that pod.`,
+the configuration objects that affect that pod.
+
+For pods in ambient mode, it reports the waypoints serving the pod and its Services, whether each
+AuthorizationPolicy applying to the pod is enforced by ztunnel or by a waypoint, and the state of the
+pod in the configuration of the ztunnel on its node.`,
 		Example: `  # Pod query with inferred namespace (current context's namespace)
   istioctl experimental describe pod helloworld-v1-676yyy3y5r-d8hdl
 
@@ -172,7 +176,11 @@ the configuration objects that affect that pod.`,
 
 			podsLabels := []klabels.Set{klabels.Set(pod.ObjectMeta.Labels)}
 			fmt.Fprintf(writer, "--------------------\n")
-			err = describePodServices(writer, kubeClient, configClient, pod, matchingServices, podsLabels, proxyAdminPort)
+			if isAmbient(pod) && !isMeshed(pod) {
+				err = describeAmbientPod(writer, kubeClient, configClient, pod, matchingServices, podsLabels, ctx.IstioNamespace())
+			} else {
+				err = describePodServices(writer, kubeClient, configClient, pod, matchingServices, podsLabels, proxyAdminPort)
+			}
 			if err != nil {
 				return err
 			}
@@ -540,7 +548,11 @@ func printPod(writer io.Writer, pod *corev1.Pod, revision string) {
 	}
 
 	if !isMeshed(pod) {
-		fmt.Fprintf(writer, "WARNING: %s is not part of mesh; no Istio sidecar\n", kname(pod.ObjectMeta))
+		if isAmbient(pod) {
+			fmt.Fprintf(writer, "   Pod is in ambient mode\n")
+		} else {
+			fmt.Fprintf(writer, "WARNING: %s is not part of mesh; no Istio sidecar\n", kname(pod.ObjectMeta))
+		}
 		return
 	}
 
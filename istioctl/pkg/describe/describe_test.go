@@ -28,10 +28,14 @@ import (
 	klabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	apiannotation "istio.io/api/annotation"
 	networking "istio.io/api/networking/v1alpha3"
+	security "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
 	clientnetworking "istio.io/client-go/pkg/apis/networking/v1"
+	securityclient "istio.io/client-go/pkg/apis/security/v1"
 	"istio.io/istio/istioctl/pkg/cli"
 	"istio.io/istio/istioctl/pkg/util/configdump"
 	"istio.io/istio/pilot/test/util"
@@ -1203,3 +1207,153 @@ func TestGetIstioVirtualServicePathForSvcFromRoute(t *testing.T) {
 		})
 	}
 }
+
+func TestDescribeAmbientPod(t *testing.T) {
+	ztunnelDump := []byte(`{"workloads":{"Kubernetes//Pod/default/productpage-v1":{"uid":"Kubernetes//Pod/default/productpage-v1",` +
+		`"name":"productpage-v1","namespace":"default","protocol":"HBONE","status":"Healthy",` +
+		`"authorizationPolicies":["default/allow-nothing","default/deny-admin"]}}}`)
+	ctx := cli.NewFakeContext(&cli.NewFakeContextOption{
+		Namespace:      "default",
+		IstioNamespace: "istio-system",
+		Results:        map[string][]byte{"ztunnel-abcde": ztunnelDump},
+	})
+	client, err := ctx.CLIClient()
+	assert.NoError(t, err)
+	create := func(obj any, err error) {
+		t.Helper()
+		assert.NoError(t, err)
+	}
+	kc := client.Kube().CoreV1()
+	create(kc.ConfigMaps("istio-system").Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio", Namespace: "istio-system"},
+		Data:       map[string]string{"mesh": "rootNamespace: istio-system"},
+	}, metav1.CreateOptions{}))
+	create(kc.Namespaces().Create(context.TODO(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"istio.io/use-waypoint": "waypoint"}},
+	}, metav1.CreateOptions{}))
+	// The service uses a waypoint shared from another namespace
+	create(kc.Services("default").Create(context.TODO(), &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "productpage", Namespace: "default", Labels: map[string]string{
+			"istio.io/use-waypoint":           "shared",
+			"istio.io/use-waypoint-namespace": "waypoints",
+		}},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "productpage"},
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 9080, TargetPort: intstr.FromInt32(9080), Protocol: corev1.ProtocolTCP}},
+		},
+	}, metav1.CreateOptions{}))
+	create(kc.Pods("default").Create(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "productpage-v1",
+			Namespace:   "default",
+			Labels:      map[string]string{"app": "productpage", "version": "v1"},
+			Annotations: map[string]string{apiannotation.AmbientRedirection.Name: "enabled"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "productpage", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9080}}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}, metav1.CreateOptions{}))
+	create(kc.Pods("istio-system").Create(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "ztunnel-abcde", Namespace: "istio-system", Labels: map[string]string{"app": "ztunnel"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}, metav1.CreateOptions{}))
+	// The waypoint only accepts service traffic, so it is not used for traffic addressed to the workload
+	create(client.GatewayAPI().GatewayV1().Gateways("default").Create(context.TODO(), &gatewayv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "waypoint", Namespace: "default"},
+		Spec:       gatewayv1.GatewaySpec{GatewayClassName: "istio-waypoint"},
+		Status: gatewayv1.GatewayStatus{Addresses: []gatewayv1.GatewayStatusAddress{{
+			Value: "10.96.0.10",
+		}}},
+	}, metav1.CreateOptions{}))
+	create(client.GatewayAPI().GatewayV1().Gateways("waypoints").Create(context.TODO(), &gatewayv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "waypoints"},
+		Spec:       gatewayv1.GatewaySpec{GatewayClassName: "istio-waypoint"},
+		Status: gatewayv1.GatewayStatus{Addresses: []gatewayv1.GatewayStatusAddress{{
+			Value: "10.96.0.11",
+		}}},
+	}, metav1.CreateOptions{}))
+	create(client.Istio().NetworkingV1().DestinationRules("default").Create(context.TODO(), &clientnetworking.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "productpage", Namespace: "default"},
+		Spec: networking.DestinationRule{
+			Host: "productpage",
+			Subsets: []*networking.Subset{
+				{Name: "v1", Labels: map[string]string{"version": "v1"}},
+				{Name: "v2", Labels: map[string]string{"version": "v2"}},
+			},
+		},
+	}, metav1.CreateOptions{}))
+	create(client.Istio().NetworkingV1().VirtualServices("default").Create(context.TODO(), &clientnetworking.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "productpage", Namespace: "default"},
+		Spec: networking.VirtualService{
+			Hosts: []string{"productpage"},
+			Http: []*networking.HTTPRoute{{
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "productpage", Subset: "v1"}}},
+			}},
+		},
+	}, metav1.CreateOptions{}))
+	policies := []*securityclient.AuthorizationPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-nothing", Namespace: "default"},
+			Spec:       security.AuthorizationPolicy{},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "deny-admin", Namespace: "default"},
+			Spec: security.AuthorizationPolicy{
+				Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "productpage"}},
+				Action:   security.AuthorizationPolicy_DENY,
+				Rules:    []*security.Rule{{To: []*security.Rule_To{{Operation: &security.Operation{Paths: []string{"/admin"}}}}}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-app", Namespace: "default"},
+			Spec: security.AuthorizationPolicy{
+				Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "reviews"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "productpage-viewer", Namespace: "default"},
+			Spec: security.AuthorizationPolicy{
+				TargetRefs: []*typev1beta1.PolicyTargetReference{{Kind: "Service", Name: "productpage"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "waypoint-policy", Namespace: "default"},
+			Spec: security.AuthorizationPolicy{
+				TargetRefs: []*typev1beta1.PolicyTargetReference{{Group: "gateway.networking.k8s.io", Kind: "Gateway", Name: "waypoint"}},
+			},
+		},
+		{
+			// Selector policies of the waypoint namespace do not apply to the pod
+			ObjectMeta: metav1.ObjectMeta{Name: "waypoints-selector", Namespace: "waypoints"},
+			Spec: security.AuthorizationPolicy{
+				Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "productpage"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "waypoints-namespace", Namespace: "waypoints"},
+			Spec:       security.AuthorizationPolicy{},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-policy", Namespace: "waypoints"},
+			Spec: security.AuthorizationPolicy{
+				TargetRefs: []*typev1beta1.PolicyTargetReference{{Group: "gateway.networking.k8s.io", Kind: "Gateway", Name: "shared"}},
+			},
+		},
+	}
+	for _, p := range policies {
+		create(client.Istio().SecurityV1().AuthorizationPolicies(p.Namespace).Create(context.TODO(), p, metav1.CreateOptions{}))
+	}
+
+	var out bytes.Buffer
+	cmd := Cmd(ctx)
+	cmd.SetArgs([]string{"pod", "productpage-v1"})
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	describeNamespace = "default"
+	assert.NoError(t, cmd.Execute())
+
+	util.CompareContent(t, out.Bytes(), "testdata/describe/ambient.golden")
+}
@@ -0,0 +1,181 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impact
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/multixds"
+	"istio.io/istio/istioctl/pkg/util"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+const (
+	jsonOutput    = "json"
+	yamlOutput    = "yaml"
+	summaryOutput = "short"
+)
+
+// Result is the impact reported by each istiod, keyed by istiod ID. Each istiod only knows about the
+// proxies connected to it.
+type Result map[string]*xds.ConfigImpact
+
+// Cmd returns the `impact` command, which reports the proxies a config change would push, without applying it.
+func Cmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var centralOpts clioptions.CentralControlPlaneOptions
+	var files []string
+	var deleted bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "impact",
+		Short: "Preview which proxies would be pushed if a configuration change were applied",
+		Long: `Impact sends the given configuration to each Istiod, which reports the connected proxies, and their XDS types,
+that would be pushed if the configuration were applied. The configuration is not applied.
+
+Only configuration read by the push context is supported: AuthorizationPolicy, DestinationRule, EnvoyFilter, Gateway,
+PeerAuthentication, ProxyConfig, RequestAuthentication, Sidecar, Telemetry, VirtualService and WasmPlugin.`,
+		Example: `  # Which proxies would be pushed if this VirtualService were applied?
+  istioctl x impact -f reviews-vs.yaml
+
+  # Which proxies would be pushed if this DestinationRule were deleted?
+  istioctl x impact -f reviews-dr.yaml --delete`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			if len(files) == 0 {
+				return util.CommandParseError{Err: fmt.Errorf("at least one file or directory must be specified with --filename")}
+			}
+			switch output {
+			case jsonOutput, yamlOutput, summaryOutput:
+			default:
+				return util.CommandParseError{Err: fmt.Errorf("unknown output format %q, expected one of json|yaml|short", output)}
+			}
+			content, err := util.ReadConfigFiles(files)
+			if err != nil {
+				return err
+			}
+			kubeClient, err := ctx.CLIClientWithRevision(ctx.RevisionOrDefault(opts.Revision))
+			if err != nil {
+				return err
+			}
+			xdsRequest := discovery.DiscoveryRequest{
+				ResourceNames: []string{debugResource(content, deleted)},
+				Node: &core.Node{
+					Id: "debug~0.0.0.0~istioctl~cluster.local",
+				},
+				TypeUrl: v3.DebugType,
+			}
+			xdsResponses, err := multixds.AllRequestAndProcessXds(&xdsRequest, centralOpts, ctx.IstioNamespace(),
+				"", "", kubeClient, multixds.DefaultOptions)
+			if err != nil {
+				return err
+			}
+			res, err := parseResponses(xdsResponses)
+			if err != nil {
+				return err
+			}
+			return printResult(c.OutOrStdout(), res, output)
+		},
+	}
+	opts.AttachControlPlaneFlags(cmd)
+	centralOpts.AttachControlPlaneFlags(cmd)
+	cmd.Long += "\n\n" + util.ExperimentalMsg
+	cmd.PersistentFlags().StringSliceVarP(&files, "filename", "f", nil,
+		"Files or directories containing the configuration to preview")
+	cmd.PersistentFlags().BoolVar(&deleted, "delete", false,
+		"Preview deleting the configuration, rather than applying it")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", summaryOutput, "Output format: one of json|yaml|short")
+	return cmd
+}
+
+// debugResource returns the debug resource name for the impactz request. Debug requests sent over XDS
+// have no body, so the configuration is passed in the query.
+func debugResource(content string, deleted bool) string {
+	name := "impactz?config=" + base64.RawURLEncoding.EncodeToString([]byte(content))
+	if deleted {
+		name += "&delete=true"
+	}
+	return name
+}
+
+func parseResponses(responses map[string]*discovery.DiscoveryResponse) (Result, error) {
+	res := Result{}
+	for id, dr := range responses {
+		for _, resource := range dr.Resources {
+			impact := &xds.ConfigImpact{}
+			if err := json.Unmarshal(resource.Value, impact); err != nil {
+				// Errors, such as invalid configuration, are returned as plain text.
+				return nil, fmt.Errorf("istiod %v: %v", id, strings.TrimSpace(string(resource.Value)))
+			}
+			res[id] = impact
+		}
+	}
+	return res, nil
+}
+
+func printResult(w io.Writer, res Result, format string) error {
+	switch format {
+	case jsonOutput:
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case yamlOutput:
+		b, err := yaml.Marshal(res)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(w, string(b))
+		return err
+	}
+	ids := make([]string, 0, len(res))
+	for id := range res {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	pushed, unaffected := 0, 0
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PROXY\tTYPE\tXDS TYPES\tISTIOD")
+	for _, id := range ids {
+		impact := res[id]
+		for _, p := range impact.Proxies {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.ProxyID, p.ProxyType, strings.Join(p.Types, ","), id)
+		}
+		pushed += len(impact.Proxies)
+		unaffected += impact.Unaffected
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d of %d connected proxies would be pushed\n", pushed, pushed+unaffected)
+	return err
+}
@@ -0,0 +1,67 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func response(body string) *discovery.DiscoveryResponse {
+	return &discovery.DiscoveryResponse{Resources: []*anypb.Any{{Value: []byte(body)}}}
+}
+
+func TestDebugResource(t *testing.T) {
+	name := debugResource("kind: VirtualService", true)
+	assert.Equal(t, strings.HasPrefix(name, "impactz?config="), true)
+	assert.Equal(t, strings.HasSuffix(name, "&delete=true"), true)
+	encoded := strings.TrimSuffix(strings.TrimPrefix(name, "impactz?config="), "&delete=true")
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, string(decoded), "kind: VirtualService")
+}
+
+func TestPrintResult(t *testing.T) {
+	res, err := parseResponses(map[string]*discovery.DiscoveryResponse{
+		"istiod-1": response(`{"configs":["VirtualService/default/reviews"],` +
+			`"proxies":[{"proxy":"productpage.default-1","proxyType":"sidecar","types":["CDS","LDS"]}],"unaffected":2}`),
+		"istiod-2": response(`{"configs":["VirtualService/default/reviews"],` +
+			`"proxies":[{"proxy":"gateway.istio-system-3","proxyType":"router","types":["RDS"]}],"unaffected":1}`),
+	})
+	assert.NoError(t, err)
+	out := &bytes.Buffer{}
+	assert.NoError(t, printResult(out, res, summaryOutput))
+	assert.Equal(t, out.String(), `PROXY                   TYPE     XDS TYPES  ISTIOD
+productpage.default-1   sidecar  CDS,LDS    istiod-1
+gateway.istio-system-3  router   RDS        istiod-2
+
+2 of 5 connected proxies would be pushed
+`)
+}
+
+func TestParseResponsesError(t *testing.T) {
+	_, err := parseResponses(map[string]*discovery.DiscoveryResponse{
+		"istiod-1": response("invalid configs: unsupported kind ServiceEntry\n"),
+	})
+	assert.Error(t, err)
+	assert.Equal(t, strings.Contains(err.Error(), "unsupported kind ServiceEntry"), true)
+}
@@ -0,0 +1,212 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulate
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	metadatafake "k8s.io/client-go/metadata/fake"
+
+	"istio.io/istio/pilot/pkg/config/kube/gateway"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pilot/pkg/simulation"
+	"istio.io/istio/pkg/activenotifier"
+	istiocluster "istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/mesh/meshwatcher"
+	"istio.io/istio/pkg/config/schema/gvr"
+	kubelib "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/kube/krt"
+	"istio.io/istio/pkg/kube/namespace"
+	"istio.io/istio/pkg/version"
+)
+
+const (
+	clusterID = istiocluster.ID(constants.DefaultClusterName)
+	// syncTimeout bounds the time waiting for the in-memory registries to process the input.
+	syncTimeout = 30 * time.Second
+)
+
+// gatewayAPICRDs are the CRDs the Gateway API controller waits for before watching its resources.
+var gatewayAPICRDs = []schema.GroupVersionResource{
+	gvr.GatewayClass,
+	gvr.KubernetesGateway,
+	gvr.HTTPRoute,
+	gvr.GRPCRoute,
+	gvr.TCPRoute,
+	gvr.TLSRoute,
+	gvr.ReferenceGrant,
+	gvr.ServiceEntry,
+}
+
+// environment builds the configuration of proxies from local resources. It adds a Kubernetes registry and the Gateway
+// API controller, backed by an in-memory Kubernetes client instead of a cluster, to the offline config generator.
+type environment struct {
+	*core.OfflineConfigGen
+	client kubelib.Client
+	stop   chan struct{}
+}
+
+func newEnvironment(configs []config.Config, objects []runtime.Object) (*environment, error) {
+	e := &environment{
+		client: kubelib.NewFakeClient(objects...),
+		stop:   make(chan struct{}),
+	}
+	if err := e.build(configs); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *environment) build(configs []config.Config) error {
+	meshWatcher := meshwatcher.ConfigAdapter(krt.NewStatic(&meshwatcher.MeshConfigResource{MeshConfig: mesh.DefaultMeshConfig()}, true))
+	networksWatcher := meshwatcher.NetworksAdapter(krt.NewStatic(&meshwatcher.MeshNetworksResource{}, true))
+	env := model.NewEnvironment()
+	xdsUpdater := model.NewEndpointIndexUpdater(env.EndpointIndex)
+
+	if err := installCRDs(e.client, gatewayAPICRDs); err != nil {
+		return err
+	}
+	kubelib.SetObjectFilter(e.client, namespace.NewDiscoveryNamespacesFilter(kclient.New[*corev1.Namespace](e.client), meshWatcher, e.stop))
+
+	options := kubecontroller.Options{
+		DomainSuffix:        constants.DefaultClusterLocalDomain,
+		XDSUpdater:          xdsUpdater,
+		Metrics:             env,
+		MeshNetworksWatcher: networksWatcher,
+		MeshWatcher:         meshWatcher,
+		ClusterID:           clusterID,
+		MeshServiceController: aggregate.NewController(aggregate.Options{
+			MeshHolder:      meshWatcher,
+			ConfigClusterID: clusterID,
+		}),
+		ConfigCluster:        true,
+		SystemNamespace:      constants.IstioSystemNamespace,
+		StatusWritingEnabled: activenotifier.New(false),
+		KrtDebugger:          new(krt.DebugHandler),
+	}
+	kubeRegistry := kubecontroller.NewController(e.client, options)
+
+	var gatewayController *gateway.Controller
+	cg, err := core.NewOfflineConfigGen(core.TestOptions{
+		Configs:           configs,
+		NetworksWatcher:   networksWatcher,
+		ServiceRegistries: []serviceregistry.Instance{kubeRegistry},
+		CreateConfigStore: func(model.ConfigStoreController) model.ConfigStoreController {
+			gatewayController = gateway.NewController(e.client, func(schema.GroupVersionResource, <-chan struct{}) bool {
+				return true
+			}, options, xdsUpdater)
+			return gatewayController
+		},
+		ClusterID:   clusterID,
+		XDSUpdater:  xdsUpdater,
+		Environment: env,
+		SkipRun:     true,
+	}, e.stop)
+	if err != nil {
+		return err
+	}
+	e.OfflineConfigGen = cg
+	env.GatewayAPIController = gatewayController
+	// This closely matches what we do in serviceregistry/kube/controller/multicluster.go
+	cg.ServiceEntryRegistry.AppendWorkloadHandler(kubeRegistry.WorkloadInstanceHandler)
+	kubeRegistry.AppendWorkloadHandler(cg.ServiceEntryRegistry.WorkloadInstanceHandler)
+
+	e.client.RunAndWait(e.stop)
+	if err := cg.Run(); err != nil {
+		return err
+	}
+	timeout := make(chan struct{})
+	timer := time.AfterFunc(syncTimeout, func() { close(timeout) })
+	defer timer.Stop()
+	if !kubelib.WaitForCacheSync("simulate", timeout, cg.HasSynced) {
+		return fmt.Errorf("timed out processing the input resources")
+	}
+	cg.ServiceEntryRegistry.ResyncEDS()
+	return cg.InitPushContext()
+}
+
+// installCRDs marks the CRDs as installed in the fake metadata client, which is where CRD presence is checked.
+func installCRDs(client kubelib.Client, crds []schema.GroupVersionResource) error {
+	fmc, ok := client.Metadata().(*metadatafake.FakeMetadataClient)
+	if !ok {
+		return nil
+	}
+	crdClient, ok := fmc.Resource(gvr.CustomResourceDefinition).(metadatafake.MetadataClient)
+	if !ok {
+		return nil
+	}
+	for _, crd := range crds {
+		obj := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: crd.Resource + "." + crd.Group}}
+		if _, err := crdClient.CreateFake(obj, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to install CRD %v: %v", obj.Name, err)
+		}
+	}
+	return nil
+}
+
+// proxyVersion is the version of the simulated proxies, which are assumed to be of the release of istioctl.
+// Development builds have no release version, and simulate the latest proxies.
+func proxyVersion() string {
+	if model.ParseIstioVersion(version.Info.Version) == model.MaxIstioVersion {
+		return model.MaxIstioVersion.String()
+	}
+	return version.Info.Version
+}
+
+// setupProxy initializes the proxy for the environment, simulating a proxy of the release of istioctl.
+func (e *environment) setupProxy(p *model.Proxy) *model.Proxy {
+	if p.Metadata == nil {
+		p.Metadata = &model.NodeMetadata{}
+	}
+	if p.Metadata.IstioVersion == "" {
+		p.Metadata.IstioVersion = proxyVersion()
+	}
+	return e.SetupProxy(p)
+}
+
+// simulation generates the listeners, clusters and routes of the proxy.
+func (e *environment) simulation(p *model.Proxy) (*simulation.Simulation, error) {
+	listeners := e.Listeners(p)
+	clusters, err := e.BuildClusters(p)
+	if err != nil {
+		return nil, err
+	}
+	return &simulation.Simulation{
+		Listeners:    listeners,
+		Clusters:     clusters,
+		Routes:       e.RoutesFromListeners(p, listeners),
+		MatchHeaders: true,
+	}, nil
+}
+
+// Close stops the registries and the in-memory client.
+func (e *environment) Close() {
+	close(e.stop)
+	e.client.Shutdown()
+}
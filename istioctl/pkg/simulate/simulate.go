@@ -0,0 +1,455 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/istioctl/pkg/util"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/simulation"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
+	kubelib "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	jsonOutput    = "json"
+	yamlOutput    = "yaml"
+	summaryOutput = "short"
+)
+
+type options struct {
+	files     []string
+	workload  string
+	proxyType string
+	mode      string
+
+	address  string
+	port     int
+	host     string
+	path     string
+	headers  []string
+	protocol string
+	tls      string
+	sni      string
+
+	expectCluster string
+	output        string
+}
+
+// Cmd returns the `simulate` command, which evaluates a synthetic request against configuration
+// generated from local files, without connecting to a cluster.
+func Cmd() *cobra.Command {
+	o := &options{}
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Simulate where a request is routed by a proxy, using only local configuration files",
+		Long: `Simulate loads Istio, Gateway API and Kubernetes (Service, Pod, Namespace, EndpointSlice) resources from local
+files, generates the configuration a proxy would receive, and reports which listener, filter chain, route and cluster
+a request with the given attributes matches. No cluster is required.
+
+If EndpointSlices are not provided, they are derived from the Services and Pods in the input.`,
+		Example: `  # Where does a request to reviews:9080/v1 go from the productpage pod?
+  istioctl x simulate -f ./config --from productpage-v1-abc.default --host reviews.default.svc.cluster.local --port 9080 --path /v1
+
+  # Send an HTTPS request through an ingress gateway pod
+  istioctl x simulate -f ./config --from istio-ingressgateway-xyz.istio-system --type router \
+    --host example.com --port 443 --tls tls
+
+  # Fail if the request is not routed to the expected cluster, for use in CI
+  istioctl x simulate -f ./config --host reviews --port 9080 --expect-cluster "outbound|9080||reviews.default.svc.cluster.local"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := o.validate(); err != nil {
+				return err
+			}
+			content, err := util.ReadConfigFiles(o.files)
+			if err != nil {
+				return err
+			}
+			out, err := run(content, o)
+			if err != nil {
+				return err
+			}
+			if err := printResult(cmd.OutOrStdout(), out, o.output); err != nil {
+				return err
+			}
+			if o.expectCluster != "" && out.Cluster != o.expectCluster {
+				return fmt.Errorf("expected cluster %q, got %q", o.expectCluster, out.Cluster)
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringSliceVarP(&o.files, "filename", "f", nil,
+		"Files or directories containing the configuration to simulate against")
+	cmd.PersistentFlags().StringVar(&o.workload, "from", "",
+		"Pod (<name>.<namespace>) from the input files sending the request. If unset, a sidecar with no labels in the default namespace is used")
+	cmd.PersistentFlags().StringVar(&o.proxyType, "type", string(model.SidecarProxy),
+		"Type of the proxy sending the request: one of sidecar|router")
+	cmd.PersistentFlags().StringVar(&o.mode, "mode", "",
+		"How the request reaches the proxy: one of outbound|inbound|gateway. Defaults to outbound for sidecars and gateway for routers")
+	cmd.PersistentFlags().StringVar(&o.address, "address", "",
+		"Destination IP address of the request. Defaults to the cluster IP of the Service matching --host, if any")
+	cmd.PersistentFlags().IntVar(&o.port, "port", 80, "Destination port of the request")
+	cmd.PersistentFlags().StringVar(&o.host, "host", "", "Host header (and SNI, for TLS requests) of the request")
+	cmd.PersistentFlags().StringVar(&o.path, "path", "/", "Path of the request")
+	cmd.PersistentFlags().StringArrayVarP(&o.headers, "header", "H", nil, "Request header, in the form <name>=<value>. May be repeated")
+	cmd.PersistentFlags().StringVar(&o.protocol, "protocol", string(simulation.HTTP), "Protocol of the request: one of http|http2|tcp")
+	cmd.PersistentFlags().StringVar(&o.tls, "tls", string(simulation.Plaintext), "TLS mode of the request: one of plaintext|tls|mtls")
+	cmd.PersistentFlags().StringVar(&o.sni, "sni", "", "SNI of the request. Defaults to --host for TLS requests")
+	cmd.PersistentFlags().StringVar(&o.expectCluster, "expect-cluster", "",
+		"If set, the command fails unless the request is routed to this cluster")
+	cmd.PersistentFlags().StringVarP(&o.output, "output", "o", summaryOutput, "Output format: one of json|yaml|short")
+	return cmd
+}
+
+func (o *options) validate() error {
+	if len(o.files) == 0 {
+		return fmt.Errorf("at least one file or directory must be specified with --filename")
+	}
+	switch model.NodeType(o.proxyType) {
+	case model.SidecarProxy, model.Router:
+	default:
+		return fmt.Errorf("unsupported proxy type %q, expected one of sidecar|router", o.proxyType)
+	}
+	switch simulation.CallMode(o.mode) {
+	case "", simulation.CallModeOutbound, simulation.CallModeInbound, simulation.CallModeGateway:
+	default:
+		return fmt.Errorf("unsupported mode %q, expected one of outbound|inbound|gateway", o.mode)
+	}
+	switch simulation.Protocol(o.protocol) {
+	case simulation.HTTP, simulation.HTTP2, simulation.TCP:
+	default:
+		return fmt.Errorf("unsupported protocol %q, expected one of http|http2|tcp", o.protocol)
+	}
+	switch simulation.TLSMode(o.tls) {
+	case simulation.Plaintext, simulation.TLS, simulation.MTLS:
+	default:
+		return fmt.Errorf("unsupported tls mode %q, expected one of plaintext|tls|mtls", o.tls)
+	}
+	switch o.output {
+	case summaryOutput, jsonOutput, yamlOutput:
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of json|yaml|short", o.output)
+	}
+	for _, h := range o.headers {
+		if !strings.Contains(h, "=") {
+			return fmt.Errorf("invalid header %q, expected <name>=<value>", h)
+		}
+	}
+	return nil
+}
+
+// Output describes where a simulated request was routed.
+type Output struct {
+	Proxy       string `json:"proxy"`
+	Listener    string `json:"listener,omitempty"`
+	FilterChain string `json:"filterChain,omitempty"`
+	RouteConfig string `json:"routeConfig,omitempty"`
+	VirtualHost string `json:"virtualHost,omitempty"`
+	Route       string `json:"route,omitempty"`
+	Cluster     string `json:"cluster,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// kubeObjectKinds are the Kubernetes resources, other than Gateway API, passed to the fake Kubernetes registry.
+var kubeObjectKinds = sets.New(gvk.Service, gvk.Pod, gvk.Namespace, gvk.EndpointSlice)
+
+// parseKubernetesObjects decodes the Kubernetes resources relevant to config generation, ignoring all others.
+func parseKubernetesObjects(content string) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	decode := kubelib.IstioCodec.UniversalDeserializer().Decode
+	reader := kubeyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(content)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read resource: %v", err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		meta := &metav1.TypeMeta{}
+		if err := yaml.Unmarshal(doc, meta); err != nil {
+			return nil, fmt.Errorf("failed to parse resource: %v", err)
+		}
+		group := meta.GroupVersionKind().Group
+		if !kubeObjectKinds.Contains(config.FromKubernetesGVK(meta.GroupVersionKind())) && group != gvk.KubernetesGateway.Group {
+			continue
+		}
+		o, _, err := decode(doc, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %v: %v", meta.Kind, err)
+		}
+		if svc, ok := o.(*corev1.Service); ok {
+			// Mirror the API server defaulting, which would otherwise leave the target port unset.
+			for i, p := range svc.Spec.Ports {
+				if p.TargetPort.IntVal == 0 && p.TargetPort.StrVal == "" {
+					svc.Spec.Ports[i].TargetPort = intstr.FromInt32(p.Port)
+				}
+			}
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// endpointSlicesFor derives an EndpointSlice for every selector-based Service with no EndpointSlice in the input,
+// listing all Pods matched by the selector.
+func endpointSlicesFor(objects []runtime.Object) []runtime.Object {
+	var services []*corev1.Service
+	var pods []*corev1.Pod
+	hasSlice := sets.New[string]()
+	for _, o := range objects {
+		switch t := o.(type) {
+		case *corev1.Service:
+			services = append(services, t)
+		case *corev1.Pod:
+			pods = append(pods, t)
+		case *discovery.EndpointSlice:
+			hasSlice.Insert(t.Namespace + "/" + t.Labels[discovery.LabelServiceName])
+		}
+	}
+	var slices []runtime.Object
+	for _, svc := range services {
+		if len(svc.Spec.Selector) == 0 || hasSlice.Contains(svc.Namespace+"/"+svc.Name) {
+			continue
+		}
+		selector := klabels.SelectorFromSet(svc.Spec.Selector)
+		var endpoints []discovery.Endpoint
+		for _, pod := range pods {
+			if pod.Namespace != svc.Namespace || pod.Status.PodIP == "" || !selector.Matches(klabels.Set(pod.Labels)) {
+				continue
+			}
+			endpoints = append(endpoints, discovery.Endpoint{
+				Addresses:  []string{pod.Status.PodIP},
+				Conditions: discovery.EndpointConditions{Ready: ptr.Of(true)},
+				TargetRef: &corev1.ObjectReference{
+					Kind:      gvk.Pod.Kind,
+					Name:      pod.Name,
+					Namespace: pod.Namespace,
+				},
+			})
+		}
+		ports := make([]discovery.EndpointPort, 0, len(svc.Spec.Ports))
+		for _, p := range svc.Spec.Ports {
+			port := p.TargetPort.IntVal
+			if port == 0 {
+				// Named target ports are resolved per pod; fall back to the service port.
+				port = p.Port
+			}
+			ports = append(ports, discovery.EndpointPort{
+				Name:        ptr.Of(p.Name),
+				Port:        ptr.Of(port),
+				Protocol:    ptr.Of(p.Protocol),
+				AppProtocol: p.AppProtocol,
+			})
+		}
+		slices = append(slices, &discovery.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      svc.Name + "-simulated",
+				Namespace: svc.Namespace,
+				Labels:    map[string]string{discovery.LabelServiceName: svc.Name},
+			},
+			AddressType: discovery.AddressTypeIPv4,
+			Endpoints:   endpoints,
+			Ports:       ports,
+		})
+	}
+	return slices
+}
+
+// buildProxy returns the proxy sending the request, based on the pod selected with --from.
+func buildProxy(objects []runtime.Object, o *options) (*model.Proxy, error) {
+	proxy := &model.Proxy{Type: model.NodeType(o.proxyType)}
+	if o.workload == "" {
+		return proxy, nil
+	}
+	name, ns, _ := strings.Cut(o.workload, ".")
+	if ns == "" {
+		ns = metav1.NamespaceDefault
+	}
+	for _, obj := range objects {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || pod.Name != name || pod.Namespace != ns {
+			continue
+		}
+		proxy.ID = pod.Name + "." + pod.Namespace
+		proxy.ConfigNamespace = pod.Namespace
+		proxy.Labels = pod.Labels
+		proxy.Metadata = &model.NodeMetadata{
+			Labels:    pod.Labels,
+			Namespace: pod.Namespace,
+		}
+		if pod.Status.PodIP != "" {
+			proxy.IPAddresses = []string{pod.Status.PodIP}
+		}
+		return proxy, nil
+	}
+	return nil, fmt.Errorf("pod %s.%s not found in the input files", name, ns)
+}
+
+// serviceAddress returns the cluster IP of the Service matching the host, if any.
+func serviceAddress(objects []runtime.Object, host string) string {
+	for _, obj := range objects {
+		svc, ok := obj.(*corev1.Service)
+		if !ok || svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			continue
+		}
+		short := svc.Name + "." + svc.Namespace
+		if host == svc.Name || host == short || strings.HasPrefix(host, short+".svc") {
+			return svc.Spec.ClusterIP
+		}
+	}
+	return ""
+}
+
+func (o *options) call(objects []runtime.Object) simulation.Call {
+	headers := http.Header{}
+	for _, h := range o.headers {
+		k, v, _ := strings.Cut(h, "=")
+		headers.Add(k, v)
+	}
+	mode := simulation.CallMode(o.mode)
+	if mode == "" {
+		mode = simulation.CallModeOutbound
+		if model.NodeType(o.proxyType) == model.Router {
+			mode = simulation.CallModeGateway
+		}
+	}
+	address := o.address
+	if address == "" && mode == simulation.CallModeOutbound {
+		address = serviceAddress(objects, o.host)
+	}
+	return simulation.Call{
+		Address:    address,
+		Port:       o.port,
+		Path:       o.path,
+		Protocol:   simulation.Protocol(o.protocol),
+		TLS:        simulation.TLSMode(o.tls),
+		HostHeader: o.host,
+		Headers:    headers,
+		Sni:        o.sni,
+		CallMode:   mode,
+	}
+}
+
+// run generates configuration for the selected proxy from content and simulates the request against it.
+func run(content string, o *options) (*Output, error) {
+	configs, _, err := crd.ParseInputs(content)
+	if err != nil {
+		return nil, err
+	}
+	for i := range configs {
+		// Short hostnames are resolved relative to the domain, as the Kubernetes config controller would.
+		configs[i].Domain = constants.DefaultClusterLocalDomain
+		if configs[i].Namespace == "" {
+			configs[i].Namespace = metav1.NamespaceDefault
+		}
+	}
+	objects, err := parseKubernetesObjects(content)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, endpointSlicesFor(objects)...)
+	proxy, err := buildProxy(objects, o)
+	if err != nil {
+		return nil, err
+	}
+	call := o.call(objects)
+
+	env, err := newEnvironment(configs, objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate configuration: %v", err)
+	}
+	defer env.Close()
+	proxy = env.setupProxy(proxy)
+	sim, err := env.simulation(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate configuration: %v", err)
+	}
+	res := sim.Run(call)
+	out := &Output{
+		Proxy:       proxy.ID,
+		Listener:    res.ListenerMatched,
+		FilterChain: res.FilterChainMatched,
+		RouteConfig: res.RouteConfigMatched,
+		VirtualHost: res.VirtualHostMatched,
+		Route:       res.RouteMatched,
+		Cluster:     res.ClusterMatched,
+	}
+	if res.Error != nil {
+		out.Error = res.Error.Error()
+	}
+	return out, nil
+}
+
+func printResult(w io.Writer, out *Output, format string) error {
+	switch format {
+	case jsonOutput:
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case yamlOutput:
+		b, err := yaml.Marshal(out)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(w, string(b))
+		return err
+	}
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 1, ' ', 0)
+	row := func(k, v string) {
+		if v != "" {
+			_, _ = fmt.Fprintf(tw, "%s:\t%s\n", k, v)
+		}
+	}
+	row("Proxy", out.Proxy)
+	row("Listener", out.Listener)
+	row("Filter Chain", out.FilterChain)
+	row("Route Config", out.RouteConfig)
+	row("Virtual Host", out.VirtualHost)
+	row("Route", out.Route)
+	row("Cluster", out.Cluster)
+	row("Error", out.Error)
+	return tw.Flush()
+}
@@ -0,0 +1,120 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulate
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestSimulate(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		want    Output
+		wantErr string
+	}{
+		{
+			name: "default route",
+			args: []string{"--from", "productpage.default", "--host", "reviews", "--port", "9080"},
+			want: Output{
+				Proxy:       "productpage.default",
+				Listener:    "0.0.0.0_9080",
+				RouteConfig: "9080",
+				VirtualHost: "reviews.default.svc.cluster.local:9080",
+				Route:       "default",
+				Cluster:     "outbound|9080||reviews.default.svc.cluster.local",
+			},
+		},
+		{
+			name: "path match",
+			args: []string{"--from", "productpage.default", "--host", "reviews", "--port", "9080", "--path", "/v1/ratings"},
+			want: Output{
+				Proxy:       "productpage.default",
+				Listener:    "0.0.0.0_9080",
+				RouteConfig: "9080",
+				VirtualHost: "reviews.default.svc.cluster.local:9080",
+				Route:       "v1-only",
+				Cluster:     "outbound|9080|v1|reviews.default.svc.cluster.local",
+			},
+		},
+		{
+			name: "header match",
+			args: []string{"--from", "productpage.default", "--host", "reviews", "--port", "9080", "-H", "end-user=jason"},
+			want: Output{
+				Proxy:       "productpage.default",
+				Listener:    "0.0.0.0_9080",
+				RouteConfig: "9080",
+				VirtualHost: "reviews.default.svc.cluster.local:9080",
+				Route:       "canary",
+				Cluster:     "outbound|9080|v2|reviews.default.svc.cluster.local",
+			},
+		},
+		{
+			name: "inbound",
+			args: []string{"--from", "reviews-v1.default", "--mode", "inbound", "--address", "10.1.0.2", "--port", "9080"},
+			want: Output{
+				Proxy:       "reviews-v1.default",
+				Listener:    "virtualInbound",
+				FilterChain: "0.0.0.0_9080",
+				VirtualHost: "inbound|http|9080",
+				Route:       "default",
+				Cluster:     "inbound|9080||",
+			},
+		},
+		{
+			name: "invalid address",
+			args: []string{"--address", "reviews", "--port", "9080"},
+			want: Output{
+				Proxy: "app.test",
+				Error: `invalid call, address: ParseAddr("reviews"): unable to parse IP`,
+			},
+		},
+		{
+			name:    "unexpected cluster",
+			args:    []string{"--host", "reviews", "--port", "9080", "--expect-cluster", "outbound|9080|v2|reviews.default.svc.cluster.local"},
+			wantErr: `expected cluster "outbound|9080|v2|reviews.default.svc.cluster.local"`,
+		},
+		{
+			name:    "unknown pod",
+			args:    []string{"--from", "details.default"},
+			wantErr: "pod details.default not found",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := Cmd()
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetErr(&out)
+			cmd.SetArgs(append([]string{"-f", "testdata", "-o", "json"}, tt.args...))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			var got Output
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &got))
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
@@ -17,11 +17,14 @@ package util
 import (
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	binversion "istio.io/istio/operator/version"
+	"istio.io/istio/pkg/util/sets"
 )
 
 var NeverMatch = &metav1.LabelSelector{
@@ -59,3 +62,31 @@ func Confirm(msg string, writer io.Writer) bool {
 		}
 	}
 }
+
+var configFileExtensions = sets.New(".json", ".yaml", ".yml")
+
+// ReadConfigFiles reads all files, and recursively all files in directories, with a JSON or YAML extension into a
+// single multi-document YAML string.
+func ReadConfigFiles(paths []string) (string, error) {
+	var docs []string
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !configFileExtensions.Contains(filepath.Ext(path)) {
+				return nil
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, string(b))
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(docs, "\n---\n"), nil
+}
@@ -15,11 +15,15 @@
 package formatting
 
 import (
+	"encoding/json"
 	"testing"
 
 	. "github.com/onsi/gomega"
 
 	"istio.io/istio/pkg/config/analysis/diag"
+	"istio.io/istio/pkg/config/analysis/legacy/source/kube"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/url"
 )
 
@@ -138,6 +142,125 @@ func TestFormatter_PrintYAML(t *testing.T) {
 	g.Expect(output).To(Equal(expectedOutput))
 }
 
+// fileMessages returns messages about resources read from a file, and about a resource without a file.
+func fileMessages() diag.Messages {
+	fileResource := &resource.Instance{
+		Metadata: resource.Metadata{FullName: resource.NewFullName("default", "reviews")},
+		Origin: &kube.Origin{
+			Type:     gvk.VirtualService,
+			FullName: resource.NewFullName("default", "reviews"),
+			Ref:      &kube.Position{Filename: "testdata/reviews.yaml", Line: 3},
+		},
+	}
+	firstMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Error, "B1", "Explosion accident: %v"),
+		fileResource,
+		"the bubble is too big",
+	)
+	// The line of the field the message is about takes precedence over the line of the resource.
+	firstMsg.Line = 12
+	secondMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Warning, "C1", "Collapse danger: %v"),
+		fileResource,
+		"the castle is too old",
+	)
+	thirdMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Info, "B1", "Explosion accident: %v"),
+		diag.MockResource("SoapBubble"),
+		"the bubble is small",
+	)
+	return diag.Messages{firstMsg, secondMsg, thirdMsg}
+}
+
+func TestFormatter_PrintSARIF(t *testing.T) {
+	g := NewWithT(t)
+
+	output, err := Print(fileMessages(), SARIFFormat, false)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	log := sarifLog{}
+	g.Expect(json.Unmarshal([]byte(output), &log)).To(Succeed())
+	g.Expect(log.Version).To(Equal("2.1.0"))
+	g.Expect(log.Runs).To(HaveLen(1))
+	run := log.Runs[0]
+	g.Expect(run.Tool.Driver.Name).To(Equal("istioctl"))
+	g.Expect(run.Tool.Driver.Rules).To(Equal([]sarifRule{
+		{ID: "B1", HelpURI: url.ConfigAnalysis + "/b1/", DefaultConfiguration: sarifConfiguration{Level: "error"}},
+		{ID: "C1", HelpURI: url.ConfigAnalysis + "/c1/", DefaultConfiguration: sarifConfiguration{Level: "warning"}},
+	}))
+	logical := []sarifLogicalLocation{{FullyQualifiedName: "VirtualService default/reviews", Kind: "resource"}}
+	g.Expect(run.Results).To(Equal([]sarifResult{
+		{
+			RuleID:  "B1",
+			Level:   "error",
+			Message: sarifMessage{Text: "Explosion accident: the bubble is too big"},
+			Locations: []sarifLocation{{
+				PhysicalLocation: &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: "testdata/reviews.yaml"},
+					Region:           &sarifRegion{StartLine: 12},
+				},
+				LogicalLocations: logical,
+			}},
+		},
+		{
+			RuleID:    "C1",
+			RuleIndex: 1,
+			Level:     "warning",
+			Message:   sarifMessage{Text: "Collapse danger: the castle is too old"},
+			Locations: []sarifLocation{{
+				PhysicalLocation: &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: "testdata/reviews.yaml"},
+					Region:           &sarifRegion{StartLine: 3},
+				},
+				LogicalLocations: logical,
+			}},
+		},
+		{
+			RuleID:    "B1",
+			Level:     "note",
+			Message:   sarifMessage{Text: "Explosion accident: the bubble is small"},
+			Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: "SoapBubble", Kind: "resource"}}}},
+		},
+	}))
+}
+
+func TestFormatter_PrintJUnit(t *testing.T) {
+	g := NewWithT(t)
+
+	output, err := Print(fileMessages(), JUnitFormat, false)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(output).To(Equal(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="istioctl analyze" tests="3" failures="2">
+	<testsuite name="istioctl analyze" tests="3" failures="2">
+		<testcase name="B1 VirtualService default/reviews" classname="VirtualService default/reviews" file="testdata/reviews.yaml" line="12">
+			<failure message="Explosion accident: the bubble is too big" type="Error">Error [B1] (VirtualService default/reviews testdata/reviews.yaml:12) Explosion accident: the bubble is too big</failure>
+		</testcase>
+		<testcase name="C1 VirtualService default/reviews" classname="VirtualService default/reviews" file="testdata/reviews.yaml" line="3">
+			<failure message="Collapse danger: the castle is too old" type="Warning">Warning [C1] (VirtualService default/reviews testdata/reviews.yaml:3) Collapse danger: the castle is too old</failure>
+		</testcase>
+		<testcase name="B1 SoapBubble" classname="SoapBubble">
+			<system-out>Info [B1] (SoapBubble) Explosion accident: the bubble is small</system-out>
+		</testcase>
+	</testsuite>
+</testsuites>`))
+}
+
+func TestFormatter_PrintWithoutOrigin(t *testing.T) {
+	g := NewWithT(t)
+
+	msgs := diag.Messages{diag.NewMessage(diag.NewMessageType(diag.Error, "B1", "Explosion accident: %v"), &resource.Instance{}, "boom")}
+
+	for _, format := range []string{LogFormat, JSONFormat, YAMLFormat, SARIFFormat, JUnitFormat} {
+		_, err := Print(msgs, format, false)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	junitOutput, _ := Print(msgs, JUnitFormat, false)
+	g.Expect(junitOutput).To(ContainSubstring(`<testcase name="B1" classname="istioctl analyze">`))
+	sarifOutput, _ := Print(msgs, SARIFFormat, false)
+	g.Expect(sarifOutput).NotTo(ContainSubstring(`"locations"`))
+}
+
 func TestFormatter_PrintEmpty(t *testing.T) {
 	g := NewWithT(t)
 
@@ -151,6 +274,12 @@ func TestFormatter_PrintEmpty(t *testing.T) {
 
 	yamlOutput, _ := Print(msgs, YAMLFormat, false)
 	g.Expect(yamlOutput).To(Equal("[]\n"))
+
+	sarifOutput, _ := Print(msgs, SARIFFormat, false)
+	g.Expect(sarifOutput).To(ContainSubstring(`"results": []`))
+
+	junitOutput, _ := Print(msgs, JUnitFormat, false)
+	g.Expect(junitOutput).To(ContainSubstring(`<testsuite name="istioctl analyze" tests="0" failures="0"></testsuite>`))
 }
 
 func TestFormatter_PintLogForMultiCluster(t *testing.T) {
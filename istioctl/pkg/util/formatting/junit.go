@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatting
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"istio.io/istio/pkg/config/analysis/diag"
+)
+
+const junitSuiteName = "istioctl analyze"
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// printJUnit prints the messages as a JUnit XML report, with a test case for each message. Error and Warning
+// messages are reported as failures; Info messages as passing test cases, with the message as output.
+func printJUnit(ms diag.Messages) (string, error) {
+	suite := junitTestSuite{Name: junitSuiteName, Cases: []junitTestCase{}}
+	for _, m := range ms {
+		text := fmt.Sprintf(m.Type.Template(), m.Parameters...)
+		tc := junitTestCase{
+			Name:      m.Type.Code(),
+			ClassName: junitSuiteName,
+		}
+		if name := resourceName(m); name != "" {
+			tc.Name = fmt.Sprintf("%s %s", m.Type.Code(), name)
+			tc.ClassName = name
+		}
+		tc.File, tc.Line = location(m)
+		if m.Type.Level() == diag.Info {
+			tc.SystemOut = m.String()
+		} else {
+			tc.Failure = &junitFailure{Message: text, Type: m.Type.Level().String(), Text: m.String()}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+	out, err := xml.MarshalIndent(junitTestSuites{
+		Name:     junitSuiteName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(out), nil
+}
@@ -68,6 +68,11 @@ func (c *ConfigWriter) Prime(b []byte) error {
 	return nil
 }
 
+// Dump returns the config dump loaded by Prime.
+func (c *ConfigWriter) Dump() *ZtunnelDump {
+	return c.ztunnelDump
+}
+
 func unmarshalListOrMap[T any](input json.RawMessage, i *[]T) error {
 	if len(input) == 0 {
 		return nil
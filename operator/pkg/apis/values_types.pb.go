@@ -800,8 +800,11 @@ type CNIRepairConfig struct {
 	BrokenPodLabelValue string `protobuf:"bytes,9,opt,name=brokenPodLabelValue,proto3" json:"brokenPodLabelValue,omitempty"`
 	// The name of the init container to use for the repairPods mode.
 	InitContainerName string `protobuf:"bytes,10,opt,name=initContainerName,proto3" json:"initContainerName,omitempty"`
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+	// If reportOnly is true, the controller only reports the broken pods, instead of taking the action of its mode.
+	// Each node writes a summary of its broken pods to the `istio-cni-repair-<node>` ConfigMap, in the namespace of the DaemonSet.
+	ReportOnly    bool `protobuf:"varint,12,opt,name=reportOnly,proto3" json:"reportOnly,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CNIRepairConfig) Reset() {
@@ -912,6 +915,13 @@ func (x *CNIRepairConfig) GetInitContainerName() string {
 	return ""
 }
 
+func (x *CNIRepairConfig) GetReportOnly() bool {
+	if x != nil {
+		return x.ReportOnly
+	}
+	return false
+}
+
 // Configuration for the resource quotas for the CNI DaemonSet.
 type ResourceQuotas struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -5482,7 +5492,7 @@ const file_pkg_apis_values_types_proto_rawDesc = "" +
 	"dnsCapture\x18\x05 \x01(\v2\x1a.google.protobuf.BoolValueR\n" +
 	"dnsCapture\x12.\n" +
 	"\x04ipv6\x18\a \x01(\v2\x1a.google.protobuf.BoolValueR\x04ipv6\x12Z\n" +
-	"\x1areconcileIptablesOnStartup\x18\t \x01(\v2\x1a.google.protobuf.BoolValueR\x1areconcileIptablesOnStartup\"\xad\x03\n" +
+	"\x1areconcileIptablesOnStartup\x18\t \x01(\v2\x1a.google.protobuf.BoolValueR\x1areconcileIptablesOnStartup\"\xcd\x03\n" +
 	"\x0fCNIRepairConfig\x124\n" +
 	"\aenabled\x18\x01 \x01(\v2\x1a.google.protobuf.BoolValueR\aenabled\x12\x10\n" +
 	"\x03hub\x18\x02 \x01(\tR\x03hub\x12(\n" +
@@ -5499,7 +5509,10 @@ const file_pkg_apis_values_types_proto_rawDesc = "" +
 	"\x11brokenPodLabelKey\x18\b \x01(\tR\x11brokenPodLabelKey\x120\n" +
 	"\x13brokenPodLabelValue\x18\t \x01(\tR\x13brokenPodLabelValue\x12,\n" +
 	"\x11initContainerName\x18\n" +
-	" \x01(\tR\x11initContainerName\"Z\n" +
+	" \x01(\tR\x11initContainerName\x12\x1e\n" +
+	"\n" +
+	"reportOnly\x18\f \x01(\bR\n" +
+	"reportOnly\"Z\n" +
 	"\x0eResourceQuotas\x124\n" +
 	"\aenabled\x18\x01 \x01(\v2\x1a.google.protobuf.BoolValueR\aenabled\x12\x12\n" +
 	"\x04pods\x18\x02 \x01(\x03R\x04pods\"U\n" +
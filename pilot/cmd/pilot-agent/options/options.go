@@ -84,6 +84,13 @@ var (
 	secretRotationGracePeriodRatioJitterEnv = env.Register("SECRET_GRACE_PERIOD_RATIO_JITTER", .01,
 		"Randomize the grace period ratio up or down by this amount to stagger cert renewals, by default .01 (~15 minutes over 24 hours).").Get()
 
+	secretRenewalBackoffInitialIntervalEnv = env.Register("SECRET_RENEWAL_BACKOFF_INITIAL_INTERVAL", time.Second,
+		"The initial interval of the exponential backoff retrying the renewal of the workload certificate when "+
+			"the CA is unreachable. The current certificate is served until it expires in the meantime.").Get()
+
+	secretRenewalBackoffMaxIntervalEnv = env.Register("SECRET_RENEWAL_BACKOFF_MAX_INTERVAL", time.Minute,
+		"The max interval of the exponential backoff retrying the renewal of the workload certificate.").Get()
+
 	workloadRSAKeySizeEnv = env.Register("WORKLOAD_RSA_KEY_SIZE", 2048,
 		"Specify the RSA key size to use for workload certificates.").Get()
 	pkcs8KeysEnv = env.Register("PKCS8_KEY", false,
@@ -92,9 +99,35 @@ var (
 	eccCurvEnv          = env.Register("ECC_CURVE", "P256", "The elliptic curve to use when ECC_SIGNATURE_ALGORITHM is set to ECDSA").Get()
 	fileMountedCertsEnv = env.Register("FILE_MOUNTED_CERTS", false, "").Get()
 	credFetcherTypeEnv  = env.Register("CREDENTIAL_FETCHER_TYPE", security.JWT,
-		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine").Get()
+		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine, TokenExchange and RolesAnywhere").Get()
 	credIdentityProvider = env.Register("CREDENTIAL_IDENTITY_PROVIDER", "GoogleComputeEngine",
 		"The identity provider for credential. Currently default supported identity provider is GoogleComputeEngine").Get()
+	tokenExchangeEndpoint = env.Register("TOKEN_EXCHANGE_ENDPOINT", "",
+		"The URL of the OAuth 2.0 token exchange (RFC 8693) endpoint of the TokenExchange credential fetcher.").Get()
+	tokenExchangeSubjectTokenPath = env.Register("TOKEN_EXCHANGE_SUBJECT_TOKEN_PATH", "",
+		"The path of the local token exchanged by the TokenExchange credential fetcher.").Get()
+	tokenExchangeSubjectTokenType = env.Register("TOKEN_EXCHANGE_SUBJECT_TOKEN_TYPE", "urn:ietf:params:oauth:token-type:jwt",
+		"The RFC 8693 type of the local token exchanged by the TokenExchange credential fetcher.").Get()
+	tokenExchangeAudience = env.Register("TOKEN_EXCHANGE_AUDIENCE", "",
+		"The audience of the token requested by the TokenExchange credential fetcher. Defaults to the trust domain.").Get()
+	tokenExchangeScope = env.Register("TOKEN_EXCHANGE_SCOPE", "",
+		"The scope of the token requested by the TokenExchange credential fetcher.").Get()
+	rolesAnywhereEndpoint = env.Register("ROLES_ANYWHERE_ENDPOINT", "",
+		"The URL of the IAM Roles Anywhere style session service of the RolesAnywhere credential fetcher.").Get()
+	rolesAnywhereRegion = env.Register("ROLES_ANYWHERE_REGION", "",
+		"The region of the session service of the RolesAnywhere credential fetcher.").Get()
+	rolesAnywhereCertPath = env.Register("ROLES_ANYWHERE_CERT_PATH", "",
+		"The path of the certificate, and its intermediates, authenticating the RolesAnywhere credential fetcher.").Get()
+	rolesAnywhereKeyPath = env.Register("ROLES_ANYWHERE_KEY_PATH", "",
+		"The path of the private key of the certificate of the RolesAnywhere credential fetcher.").Get()
+	rolesAnywhereTrustAnchorARN = env.Register("ROLES_ANYWHERE_TRUST_ANCHOR_ARN", "",
+		"The trust anchor of the sessions created by the RolesAnywhere credential fetcher.").Get()
+	rolesAnywhereProfileARN = env.Register("ROLES_ANYWHERE_PROFILE_ARN", "",
+		"The profile of the sessions created by the RolesAnywhere credential fetcher.").Get()
+	rolesAnywhereRoleARN = env.Register("ROLES_ANYWHERE_ROLE_ARN", "",
+		"The role of the sessions created by the RolesAnywhere credential fetcher.").Get()
+	rolesAnywhereSessionDuration = env.Register("ROLES_ANYWHERE_SESSION_DURATION", time.Hour,
+		"The duration of the sessions created by the RolesAnywhere credential fetcher.").Get()
 	// DNSCaptureByAgent is a copy of the env var in the init code.
 	DNSCaptureByAgent = env.Register("ISTIO_META_DNS_CAPTURE", false,
 		"If set to true, enable the capture of outgoing DNS packets on port 53, redirecting to istio-agent on :15053")
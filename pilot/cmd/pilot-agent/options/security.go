@@ -17,15 +17,21 @@ package options
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/bootstrap"
 	"istio.io/istio/pkg/config/constants"
+	commonFeatures "istio.io/istio/pkg/features"
 	"istio.io/istio/pkg/jwt"
 	"istio.io/istio/pkg/log"
+	pm "istio.io/istio/pkg/model"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/security/pkg/credentialfetcher"
+	"istio.io/istio/security/pkg/credentialfetcher/plugin"
 	"istio.io/istio/security/pkg/nodeagent/cafile"
 )
 
@@ -33,6 +39,11 @@ import (
 const caHeaderPrefix = "CA_HEADER_"
 
 func NewSecurityOptions(proxyConfig *meshconfig.ProxyConfig, stsPort int, tokenManagerPlugin string) (*security.Options, error) {
+	// The crypto profile of the compliance policy is enforced on the xDS and CA connections of the agent, so fail early
+	// on a typo.
+	if _, err := pm.GetCryptoProfile(commonFeatures.CompliancePolicy); err != nil {
+		return nil, err
+	}
 	o := &security.Options{
 		CAEndpoint:                           caEndpointEnv,
 		CAProviderName:                       caProviderEnv,
@@ -53,6 +64,8 @@ func NewSecurityOptions(proxyConfig *meshconfig.ProxyConfig, stsPort int, tokenM
 		FileDebounceDuration:                 fileDebounceDuration,
 		SecretRotationGracePeriodRatio:       secretRotationGracePeriodRatioEnv,
 		SecretRotationGracePeriodRatioJitter: secretRotationGracePeriodRatioJitterEnv,
+		SecretRenewalBackoffInitialInterval:  secretRenewalBackoffInitialIntervalEnv,
+		SecretRenewalBackoffMaxInterval:      secretRenewalBackoffMaxIntervalEnv,
 		STSPort:                              stsPort,
 		CertSigner:                           certSigner.Get(),
 		CARootPath:                           cafile.CACertFilePath,
@@ -70,7 +83,47 @@ func NewSecurityOptions(proxyConfig *meshconfig.ProxyConfig, stsPort int, tokenM
 
 	extractCAHeadersFromEnv(o)
 
-	return o, err
+	annotations, err := bootstrap.ReadPodAnnotations("")
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("failed to read pod annotations: %v", err)
+	}
+	applyCertAnnotations(o, annotations)
+
+	return o, nil
+}
+
+// applyCertAnnotations overrides the TTL and renewal policy of the workload certificate with the annotations of the
+// pod, e.g. to use short-lived certificates for some workloads. Invalid annotations are ignored.
+func applyCertAnnotations(o *security.Options, annotations map[string]string) {
+	if v, f := annotations[constants.CertTTLAnnotation]; f {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			log.Warnf("ignoring invalid annotation %s=%q: the TTL must be a positive duration", constants.CertTTLAnnotation, v)
+		} else {
+			o.SecretTTL = ttl
+		}
+	}
+	if v, f := annotations[constants.CertGracePeriodRatioAnnotation]; f {
+		if ratio, ok := parseRatio(constants.CertGracePeriodRatioAnnotation, v); ok {
+			o.SecretRotationGracePeriodRatio = ratio
+		}
+	}
+	if v, f := annotations[constants.CertGracePeriodRatioJitterAnnotation]; f {
+		if jitter, ok := parseRatio(constants.CertGracePeriodRatioJitterAnnotation, v); ok {
+			o.SecretRotationGracePeriodRatioJitter = jitter
+		}
+	}
+	log.Infof("workload certificate TTL %v, renewed with a grace period ratio of %v (jitter %v)",
+		o.SecretTTL, o.SecretRotationGracePeriodRatio, o.SecretRotationGracePeriodRatioJitter)
+}
+
+func parseRatio(name, v string) (float64, bool) {
+	ratio, err := strconv.ParseFloat(v, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		log.Warnf("ignoring invalid annotation %s=%q: the value must be between 0 and 1", name, v)
+		return 0, false
+	}
+	return ratio, true
 }
 
 func SetupSecurityOptions(proxyConfig *meshconfig.ProxyConfig, secOpt *security.Options, jwtPolicy,
@@ -97,7 +150,8 @@ func SetupSecurityOptions(proxyConfig *meshconfig.ProxyConfig, secOpt *security.
 	}
 
 	o.CredIdentityProvider = credIdentityProvider
-	credFetcher, err := credentialfetcher.NewCredFetcher(credFetcherTypeEnv, o.TrustDomain, jwtPath, o.CredIdentityProvider)
+	credFetcher, err := credentialfetcher.NewCredFetcher(credFetcherTypeEnv, o.TrustDomain, jwtPath, o.CredIdentityProvider,
+		credFetcherConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create credential fetcher: %v", err)
 	}
@@ -147,3 +201,26 @@ func extractCAHeadersFromEnv(o *security.Options) {
 		o.CAHeaders[parts[0][len(caHeaderPrefix):]] = parts[1]
 	}
 }
+
+// credFetcherConfig returns the configuration of the credential fetchers exchanging a local credential.
+func credFetcherConfig() credentialfetcher.Config {
+	return credentialfetcher.Config{
+		TokenExchange: plugin.TokenExchangeConfig{
+			Endpoint:         tokenExchangeEndpoint,
+			SubjectTokenPath: tokenExchangeSubjectTokenPath,
+			SubjectTokenType: tokenExchangeSubjectTokenType,
+			Audience:         tokenExchangeAudience,
+			Scope:            tokenExchangeScope,
+		},
+		RolesAnywhere: plugin.RolesAnywhereConfig{
+			Endpoint:        rolesAnywhereEndpoint,
+			Region:          rolesAnywhereRegion,
+			CertificatePath: rolesAnywhereCertPath,
+			PrivateKeyPath:  rolesAnywhereKeyPath,
+			TrustAnchorARN:  rolesAnywhereTrustAnchorARN,
+			ProfileARN:      rolesAnywhereProfileARN,
+			RoleARN:         rolesAnywhereRoleARN,
+			SessionDuration: rolesAnywhereSessionDuration,
+		},
+	}
+}
@@ -17,8 +17,11 @@ package options
 import (
 	"os"
 	"testing"
+	"time"
 
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/util/assert"
 )
 
 func TestCheckGkeWorkloadCertificate(t *testing.T) {
@@ -137,3 +140,41 @@ func TestExtractCAHeadersFromEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyCertAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        security.Options
+	}{
+		{
+			name: "no annotations",
+			want: security.Options{SecretTTL: 24 * time.Hour, SecretRotationGracePeriodRatio: 0.5, SecretRotationGracePeriodRatioJitter: 0.01},
+		},
+		{
+			name: "short-lived certificates",
+			annotations: map[string]string{
+				constants.CertTTLAnnotation:                    "1h",
+				constants.CertGracePeriodRatioAnnotation:       "0.3",
+				constants.CertGracePeriodRatioJitterAnnotation: "0.05",
+			},
+			want: security.Options{SecretTTL: time.Hour, SecretRotationGracePeriodRatio: 0.3, SecretRotationGracePeriodRatioJitter: 0.05},
+		},
+		{
+			name: "invalid annotations",
+			annotations: map[string]string{
+				constants.CertTTLAnnotation:                    "-1h",
+				constants.CertGracePeriodRatioAnnotation:       "2",
+				constants.CertGracePeriodRatioJitterAnnotation: "a lot",
+			},
+			want: security.Options{SecretTTL: 24 * time.Hour, SecretRotationGracePeriodRatio: 0.5, SecretRotationGracePeriodRatioJitter: 0.01},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := security.Options{SecretTTL: 24 * time.Hour, SecretRotationGracePeriodRatio: 0.5, SecretRotationGracePeriodRatioJitter: 0.01}
+			applyCertAnnotations(&o, tt.annotations)
+			assert.Equal(t, o, tt.want)
+		})
+	}
+}
@@ -45,6 +45,7 @@ import (
 	"istio.io/istio/pilot/pkg/leaderelection"
 	"istio.io/istio/pilot/pkg/leaderelection/k8sleaderelection/k8sresourcelock"
 	"istio.io/istio/pilot/pkg/model"
+	sec_model "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pkg/activenotifier"
 	"istio.io/istio/pkg/adsc"
 	"istio.io/istio/pkg/config/analysis/incluster"
@@ -408,23 +409,28 @@ func (s *Server) getTransportCredentials(args *PilotArgs, tlsSettings *v1alpha3.
 			tlsSettings.CaCrl = string(rootCert.CRL)
 		}
 		if tlsSettings.GetInsecureSkipVerify().GetValue() || len(tlsSettings.GetCaCertificates()) == 0 {
-			return credentials.NewTLS(&tls.Config{
+			cfg := &tls.Config{
 				ServerName:         tlsSettings.GetSni(),
 				InsecureSkipVerify: tlsSettings.GetInsecureSkipVerify().GetValue(), //nolint
-			}), nil
+			}
+			sec_model.EnforceGoCompliance(cfg)
+			return credentials.NewTLS(cfg), nil
 		}
 		certPool := x509.NewCertPool()
 		if !certPool.AppendCertsFromPEM([]byte(tlsSettings.GetCaCertificates())) {
 			return nil, fmt.Errorf("failed to add ca certificate from configSource.tlsSettings to pool")
 		}
-		return credentials.NewTLS(&tls.Config{
+		cfg := &tls.Config{
 			ServerName:         tlsSettings.GetSni(),
 			InsecureSkipVerify: tlsSettings.GetInsecureSkipVerify().GetValue(), //nolint
 			RootCAs:            certPool,
 			VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
 				return s.verifyCert(rawCerts, tlsSettings)
 			},
-		}), nil
+		}
+		// Compliance for config source connections.
+		sec_model.EnforceGoCompliance(cfg)
+		return credentials.NewTLS(cfg), nil
 	default:
 		return insecure.NewCredentials(), nil
 	}
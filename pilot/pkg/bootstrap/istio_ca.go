@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"strings"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -33,12 +35,16 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	securityModel "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/mesh/kubemesh"
 	"istio.io/istio/pkg/env"
+	"istio.io/istio/pkg/kube/watcher/configmapwatcher"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/security/pkg/cmd"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/ra"
+	"istio.io/istio/security/pkg/pki/signer"
 	caserver "istio.io/istio/security/pkg/server/ca"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	"istio.io/istio/security/pkg/util"
@@ -50,7 +56,9 @@ type caOptions struct {
 	// domain to use in SPIFFE identity URLs
 	TrustDomain      string
 	Namespace        string
+	PodName          string
 	Authenticators   []security.Authenticator
+	Authorizers      []caserver.CSRAuthorizer
 	CertSignerDomain string
 }
 
@@ -124,9 +132,50 @@ var (
 	audience = env.Register("AUDIENCE", "",
 		"Expected audience in the tokens. ")
 
+	caRevocationRefreshInterval = env.Register("CA_REVOCATION_REFRESH_INTERVAL", time.Hour,
+		"The interval at which the CA reloads the revocation list shared by the istiod replicas, "+
+			"and regenerates the CRL of the revoked certificates.")
+
+	caCRLValidity = env.Register("CA_CRL_VALIDITY", 7*24*time.Hour,
+		"The validity of the CRL of the certificates revoked by the CA. Proxies reject the certificates of the CA "+
+			"once the CRL expires, so it must be much longer than CA_REVOCATION_REFRESH_INTERVAL.")
+
 	caRSAKeySize = env.Register("CITADEL_SELF_SIGNED_CA_RSA_KEY_SIZE", 2048,
 		"Specify the RSA key size to use for self-signed Istio CA certificates.")
 
+	caSigningKeyBackend = env.Register("CA_SIGNING_KEY_BACKEND", "",
+		"If set, the signing key of the plugged-in CA is kept by this backend rather than read from ca-key.pem, "+
+			"so it is never in the memory of istiod. Permitted values are pkcs11 and remote. The certificates "+
+			"of the CA are still read from the cacerts secret. The pkcs11 backend requires istiod to be built with "+
+			"cgo (CGO_ENABLED=1), which the released images are not: istiod fails to start if it is selected otherwise.")
+
+	caSigningKeyPKCS11Module = env.Register("CA_SIGNING_KEY_PKCS11_MODULE", "",
+		"The path of the PKCS#11 module of the pkcs11 CA signing key backend.")
+
+	caSigningKeyPKCS11Token = env.Register("CA_SIGNING_KEY_PKCS11_TOKEN", "",
+		"The label of the PKCS#11 token keeping the CA signing key.")
+
+	caSigningKeyPKCS11KeyLabel = env.Register("CA_SIGNING_KEY_PKCS11_KEY_LABEL", "",
+		"The label of the CA signing key pair in the PKCS#11 token.")
+
+	caSigningKeyPKCS11PINFile = env.Register("CA_SIGNING_KEY_PKCS11_PIN_FILE", "",
+		"The file containing the PIN of the user of the PKCS#11 token.")
+
+	caSigningKeyRemoteAddress = env.Register("CA_SIGNING_KEY_REMOTE_ADDRESS", "",
+		"The address of the remote signer of the remote CA signing key backend, e.g. unix:///var/run/kms/signer.sock.")
+
+	caSigningKeyRemoteKeyName = env.Register("CA_SIGNING_KEY_REMOTE_KEY_NAME", "",
+		"The name of the CA signing key in the remote signer.")
+
+	caSigningKeyRemoteRootCert = env.Register("CA_SIGNING_KEY_REMOTE_ROOT_CERT", "",
+		"The file of the root certificates verifying the TLS certificate of the remote signer. "+
+			"The system roots are used if unset. Unused when the remote signer listens on a unix socket.")
+
+	caSigningKeyReloadInterval = env.Register("CA_SIGNING_KEY_RELOAD_INTERVAL", time.Minute,
+		"The interval at which the CA signing key backend is checked for a new version of the key. "+
+			"The CA switches to the new version once the certificate of the CA matches it. "+
+			"Setting this interval to zero disables the check.")
+
 	// TODO: Likely to be removed and added to mesh config
 	externalCaType = env.Register("EXTERNAL_CA", "",
 		"External CA Integration Type. Permitted value is ISTIOD_RA_KUBERNETES_API.").Get()
@@ -144,7 +193,55 @@ func (s *Server) initCAServer(ca caserver.CertificateAuthority, opts *caOptions)
 	if startErr != nil {
 		log.Fatalf("failed to create istio ca server: %v", startErr)
 	}
+	caServer.Authorizers = opts.Authorizers
+	caServer.DefaultCertTTL = workloadCertTTL.Get()
+	caServer.AdminIdentities = sets.New(features.CAAdminIdentities...)
 	s.caServer = caServer
+	if istioCA, ok := ca.(caserver.CertificateRevoker); ok && features.EnableCARevocation {
+		s.handleCAAdmin(caserver.RevocationPath, caServer.RevocationHandler(istioCA))
+	}
+	if istioCA, ok := ca.(caserver.RootRotator); ok && features.EnableCARootRotation {
+		s.handleCAAdmin(caserver.RootRotationPath, caServer.RootRotationHandler(istioCA))
+	}
+	if features.CACertInventorySize > 0 {
+		caServer.Inventory = caserver.NewCertInventory(features.CACertInventorySize)
+		s.handleCAAdmin(caserver.CertificatesPath, caServer.CertificatesHandler(caServer.Inventory))
+		s.addStartFunc("ca certificate inventory", func(stop <-chan struct{}) error {
+			go caServer.Inventory.Run(stop)
+			return nil
+		})
+	}
+}
+
+// handleCAAdmin serves a CA management endpoint on the HTTPS server of istiod, for callers to be authenticated over
+// TLS. The endpoint is not served if the HTTPS server is disabled.
+func (s *Server) handleCAAdmin(path string, handler http.Handler) {
+	if s.httpsServer == nil {
+		log.Warnf("CA management endpoint %s is not served, as it requires the HTTPS server of istiod", path)
+		return
+	}
+	s.httpsMux.Handle(path, handler)
+}
+
+// initCSRAuthorizer builds the authorizer enforcing the CSR authorization policy of the CA, read from the
+// csrAuthorization key of the mesh config, and reloaded on changes.
+func (s *Server) initCSRAuthorizer(args *PilotArgs) *caserver.PolicyAuthorizer {
+	authorizer := caserver.NewPolicyAuthorizer(nil)
+	s.watchMeshConfigKey(args, kubemesh.CSRAuthorizationKey, "CSRAuthorizationPolicy", func(policyYAML *string) {
+		if policyYAML == nil || strings.TrimSpace(*policyYAML) == "" {
+			log.Infof("no CSR authorization policy, all the CSRs of authenticated callers are authorized")
+			authorizer.SetPolicy(nil)
+			return
+		}
+		policy, err := caserver.ParseCSRAuthorizationPolicy(*policyYAML)
+		if err != nil {
+			log.Warnf("invalid CSR authorization policy, using last known state: %v", err)
+			return
+		}
+		log.Infof("CSR authorization policy updated, with %d rules", len(policy.Rules))
+		authorizer.SetPolicy(policy)
+	})
+	return authorizer
 }
 
 // RunCA will start the cert signing GRPC service on an existing server.
@@ -326,7 +423,13 @@ func (s *Server) loadCACerts(caOpts *caOptions, dir string) error {
 // similarly, if crl file is created/modified,
 // it updates the crl file in keycertbundle and notifies the watcher
 // to replicate the new crl data.
-func handleEvent(s *Server) {
+// The update is deferred during a root rotation, which stages the roots of the CA: it returns false in that case, for
+// the update to be retried.
+func handleEvent(s *Server) bool {
+	if s.CA.RootRotationInProgress() {
+		log.Info("Root rotation of the CA in progress, deferring the update of Istiod cacerts")
+		return false
+	}
 	log.Info("Update Istiod cacerts")
 
 	var newCABundle []byte
@@ -336,14 +439,14 @@ func handleEvent(s *Server) {
 	fileBundle, err := detectSigningCABundleAndCRL()
 	if err != nil {
 		log.Errorf("unable to determine signing file format %v", err)
-		return
+		return true
 	}
 
 	// check if CA bundle is updated
 	newCABundle, err = os.ReadFile(fileBundle.RootCertFile)
 	if err != nil {
 		log.Errorf("failed reading root-cert.pem: %v", err)
-		return
+		return true
 	}
 
 	currentCABundle := s.CA.GetCAKeyCertBundle().GetRootCertPem()
@@ -352,7 +455,7 @@ func handleEvent(s *Server) {
 	if !bytes.Equal(currentCABundle, newCABundle) {
 		if !features.MultiRootMesh {
 			log.Warn("Multi root is disabled, updating new ROOT-CA not supported")
-			return
+			return true
 		}
 
 		// in order to support root ca rotation, or we are removing the old ca,
@@ -362,7 +465,7 @@ func handleEvent(s *Server) {
 			log.Info("Updating new ROOT-CA")
 		} else {
 			log.Warn("Updating new ROOT-CA not supported")
-			return
+			return true
 		}
 	}
 
@@ -386,16 +489,10 @@ func handleEvent(s *Server) {
 	}
 
 	// process updated root cert or crl file
-	err = s.CA.GetCAKeyCertBundle().UpdateVerifiedKeyCertBundleFromFile(
-		fileBundle.SigningCertFile,
-		fileBundle.SigningKeyFile,
-		fileBundle.CertChainFiles,
-		fileBundle.RootCertFile,
-		fileBundle.CRLFile,
-	)
+	err = s.CA.UpdateKeyCertBundleFromFile(fileBundle)
 	if err != nil {
 		log.Errorf("Failed to update new Plug-in CA certs: %v", err)
-		return
+		return true
 	}
 	if len(s.CA.GetCAKeyCertBundle().GetRootCertPem()) != 0 {
 		caserver.RecordCertsExpiry(s.CA.GetCAKeyCertBundle())
@@ -403,19 +500,23 @@ func handleEvent(s *Server) {
 
 	// notify watcher to replicate new or updated crl data
 	if updateCRL {
-		s.istiodCertBundleWatcher.SetAndNotifyCACRL(s.CA.GetCAKeyCertBundle().GetCRLPem())
+		s.publishCACRL(s.CA)
 		log.Infof("Istiod has detected the newly added CRL file and updated its CRL accordingly")
 	}
 
 	err = s.updateRootCertAndGenKeyCert()
 	if err != nil {
 		log.Errorf("Failed generating plugged-in istiod key cert: %v", err)
-		return
+		return true
 	}
 
 	log.Info("Istiod has detected the newly added intermediate CA and updated its key and certs accordingly")
+	return true
 }
 
+// caCertsRetryInterval is the interval at which a deferred update of the cacerts files is retried.
+const caCertsRetryInterval = 10 * time.Second
+
 // handleCACertsFileWatch handles the events on cacerts files
 func (s *Server) handleCACertsFileWatch() {
 	var timerC <-chan time.Time
@@ -423,7 +524,9 @@ func (s *Server) handleCACertsFileWatch() {
 		select {
 		case <-timerC:
 			timerC = nil
-			handleEvent(s)
+			if !handleEvent(s) {
+				timerC = time.After(caCertsRetryInterval)
+			}
 
 		case event, ok := <-s.cacertsWatcher.Events:
 			if !ok {
@@ -488,17 +591,38 @@ func (s *Server) initCACertsAndCRLWatcher() {
 //	which may contain multiple roots. A 'cert-chain.pem' file has the full cert chain.
 func (s *Server) createIstioCA(opts *caOptions) (*ca.IstioCA, error) {
 	var caOpts *ca.IstioCAOptions
+	var istioCA *ca.IstioCA
 	var signingCABundleComplete bool
 	var istioGenerated bool
 	var err error
 
+	if caSigningKeyBackend.Get() == signer.TypePKCS11 && !signer.PKCS11Supported {
+		return nil, fmt.Errorf("failed to create an istiod CA: CA_SIGNING_KEY_BACKEND=%s requires istiod to be built with cgo "+
+			"(CGO_ENABLED=1), and this build is not", signer.TypePKCS11)
+	}
+
 	fileBundle, err := detectSigningCABundleAndCRL()
 	if err != nil {
 		return nil, fmt.Errorf("unable to determine signing file format %v", err)
 	}
 
+	var signingKeyBackend signer.Backend
+	signingKeyFile := fileBundle.SigningKeyFile
+	if caSigningKeyBackend.Get() != "" {
+		// The signing key is kept by the backend: only the certificates of the CA are plugged in.
+		signingKeyFile = ""
+		signingKeyBackend, err = newCASigningKeyBackend()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
+		}
+		go func() {
+			<-s.internalStop
+			_ = signingKeyBackend.Close()
+		}()
+	}
+
 	signingCABundleComplete, bundleExists, err := checkCABundleCompleteness(
-		fileBundle.SigningKeyFile,
+		signingKeyFile,
 		fileBundle.SigningCertFile,
 		fileBundle.RootCertFile,
 		fileBundle.CertChainFiles,
@@ -516,6 +640,11 @@ func (s *Server) createIstioCA(opts *caOptions) (*ca.IstioCA, error) {
 		}
 	}
 
+	if signingKeyBackend != nil && (!signingCABundleComplete || istioGenerated) {
+		return nil, fmt.Errorf("failed to create an istiod CA: the %s signing key backend requires the plugged-in CA certificates",
+			caSigningKeyBackend.Get())
+	}
+
 	useSelfSignedCA := !signingCABundleComplete || (features.UseCacertsForSelfSignedCA && istioGenerated)
 	if useSelfSignedCA {
 		if features.UseCacertsForSelfSignedCA && istioGenerated {
@@ -539,7 +668,17 @@ func (s *Server) createIstioCA(opts *caOptions) (*ca.IstioCA, error) {
 		// The secret is mounted and the "istio-generated" key is not used.
 		log.Info("Use local CA certificate")
 
-		caOpts, err = ca.NewPluggedCertIstioCAOptions(fileBundle, workloadCertTTL.Get(), maxWorkloadCertTTL.Get(), caRSAKeySize.Get())
+		if signingKeyBackend != nil {
+			log.Infof("Use the %s backend for the CA signing key", caSigningKeyBackend.Get())
+			caOpts, err = ca.NewPluggedSignerIstioCAOptions(fileBundle, ca.SigningKeyOptions{
+				Backend:        signingKeyBackend,
+				ReloadInterval: caSigningKeyReloadInterval.Get(),
+				// The certificates of the new key are reloaded as for any update of the cacerts files.
+				OnKeyChange: func() { handleEvent(s) },
+			}, workloadCertTTL.Get(), maxWorkloadCertTTL.Get(), caRSAKeySize.Get())
+		} else {
+			caOpts, err = ca.NewPluggedCertIstioCAOptions(fileBundle, workloadCertTTL.Get(), maxWorkloadCertTTL.Get(), caRSAKeySize.Get())
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
 		}
@@ -561,16 +700,131 @@ func (s *Server) createIstioCA(opts *caOptions) (*ca.IstioCA, error) {
 
 		s.initCACertsAndCRLWatcher()
 	}
-	istioCA, err := ca.NewIstioCA(caOpts)
+	if features.EnableCARevocation && features.EnableCACRL {
+		if useSelfSignedCA || len(fileBundle.CRLFile) > 0 {
+			caOpts.Revocation = s.caRevocationOptions(opts, func() { s.publishCACRL(istioCA) })
+		} else {
+			log.Warnf("certificate revocation requires a %s file with a plugged-in CA, as proxies require a CRL for every CA of the chain",
+				ca.CACRLFile)
+		}
+	}
+	if features.EnableCARootRotation {
+		if signingKeyBackend != nil {
+			log.Warnf("root rotation of the CA is not supported with the %s signing key backend", caSigningKeyBackend.Get())
+		} else if s.kubeClient != nil && features.MultiRootMesh {
+			caOpts.RootRotation = &ca.RootRotationOptions{
+				Client:           s.kubeClient.Kube().CoreV1(),
+				Namespace:        opts.Namespace,
+				Replica:          opts.PodName,
+				ProxyAcks:        s.XDSServer.TrustBundleAcks,
+				Replicas:         func() ([]string, error) { return s.istiodReplicas(opts.Namespace) },
+				OnRootCertUpdate: s.updateRootCertAndGenKeyCert,
+			}
+		} else {
+			log.Warnf("root rotation of the CA requires ISTIO_MULTIROOT_MESH and a Kubernetes cluster")
+		}
+	}
+	istioCA, err = ca.NewIstioCA(caOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
 	}
+	if caOpts.Revocation != nil && s.kubeClient != nil {
+		s.watchCARevocations(istioCA, opts.Namespace)
+	}
 
 	// Start root cert rotator in a separate goroutine.
 	istioCA.Run(s.internalStop)
 	return istioCA, nil
 }
 
+// newCASigningKeyBackend creates the backend of the CA signing key selected by CA_SIGNING_KEY_BACKEND.
+func newCASigningKeyBackend() (signer.Backend, error) {
+	opts := signer.Options{
+		Type: caSigningKeyBackend.Get(),
+		PKCS11: signer.PKCS11Options{
+			Module:     caSigningKeyPKCS11Module.Get(),
+			TokenLabel: caSigningKeyPKCS11Token.Get(),
+			KeyLabel:   caSigningKeyPKCS11KeyLabel.Get(),
+		},
+		Remote: signer.RemoteOptions{
+			Address:      caSigningKeyRemoteAddress.Get(),
+			KeyName:      caSigningKeyRemoteKeyName.Get(),
+			RootCertFile: caSigningKeyRemoteRootCert.Get(),
+		},
+	}
+	if opts.Type == signer.TypePKCS11 && caSigningKeyPKCS11PINFile.Get() != "" {
+		pin, err := os.ReadFile(caSigningKeyPKCS11PINFile.Get())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the PKCS#11 PIN: %v", err)
+		}
+		opts.PKCS11.PIN = strings.TrimSpace(string(pin))
+	}
+	return signer.NewBackend(opts)
+}
+
+// istiodReplicas returns the names of the running istiod pods of all the revisions in the namespace, which share the
+// root rotation of the CA.
+func (s *Server) istiodReplicas(namespace string) ([]string, error) {
+	pods, err := s.kubeClient.Kube().CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: "app=istiod"})
+	if err != nil {
+		return nil, err
+	}
+	var replicas []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			replicas = append(replicas, pod.Name)
+		}
+	}
+	return replicas, nil
+}
+
+// caRevocationOptions returns the options of the revocation of the certificates issued by the CA. The revocation list
+// and the record of the issued certificates are persisted in the istiod namespace, to be shared by the replicas.
+func (s *Server) caRevocationOptions(opts *caOptions, onCRLUpdate func()) *ca.RevocationOptions {
+	store := ca.NewMemoryRevocationStore()
+	if s.kubeClient != nil {
+		store = ca.NewConfigMapRevocationStore(s.kubeClient.Kube().CoreV1(), opts.Namespace)
+	} else {
+		log.Warnf("revocation list of the CA is not persisted, as there is no Kubernetes client")
+	}
+	return &ca.RevocationOptions{
+		Store:           store,
+		RefreshInterval: caRevocationRefreshInterval.Get(),
+		CRLValidity:     caCRLValidity.Get(),
+		OnCRLUpdate:     onCRLUpdate,
+	}
+}
+
+// watchCARevocations refreshes the revocation list of the CA when the ConfigMap persisting it changes, so that
+// revocations made through another replica take effect without waiting for the refresh interval.
+func (s *Server) watchCARevocations(istioCA *ca.IstioCA, namespace string) {
+	watcher := configmapwatcher.NewController(s.kubeClient, namespace, ca.RevocationConfigMap, func(*corev1.ConfigMap) {
+		if err := istioCA.RefreshRevocations(); err != nil {
+			log.Errorf("failed to refresh the revocation list of the CA: %v", err)
+		}
+	})
+	s.addStartFunc("ca revocation watcher", func(stop <-chan struct{}) error {
+		go watcher.Run(stop)
+		return nil
+	})
+}
+
+// publishCACRL notifies the watcher to replicate the CRL of the plugged-in CA, along with the CRL of the certificates
+// revoked by the CA, to the namespaces for proxies to consume.
+func (s *Server) publishCACRL(istioCA *ca.IstioCA) {
+	if istioCA == nil {
+		return
+	}
+	crl := istioCA.GetCAKeyCertBundle().GetCRLPem()
+	if revoked := istioCA.GetCRLPem(); len(revoked) > 0 {
+		if len(crl) > 0 && !bytes.HasSuffix(crl, []byte("\n")) {
+			crl = append(crl, '\n')
+		}
+		crl = append(crl, revoked...)
+	}
+	s.istiodCertBundleWatcher.SetAndNotifyCACRL(crl)
+}
+
 func (s *Server) createSelfSignedCACertificateOptions(fileBundle *ca.SigningCAFileBundle, opts *caOptions) (*ca.IstioCAOptions, error) {
 	var caOpts *ca.IstioCAOptions
 	var err error
@@ -661,6 +915,7 @@ func (s *Server) createIstioRA(opts *caOptions) (ra.RegistrationAuthority, error
 
 // checkCABundleCompleteness checks if all required CA certificate files exist
 // this function may return bundleExists as false even when some files exist in case of an error
+// signingKeyFile is empty when the signing key is kept by a backend, and is then not required
 func checkCABundleCompleteness(
 	signingKeyFile, signingCertFile, rootCertFile string,
 	chainFiles []string,
@@ -690,7 +945,7 @@ func checkCABundleCompleteness(
 	}
 
 	bundleExists = signingKeyExists || signingCertExists || rootCertExists || chainFilesExist
-	signingCABundleComplete = signingKeyExists && signingCertExists && rootCertExists && chainFilesExist
+	signingCABundleComplete = (signingKeyExists || signingKeyFile == "") && signingCertExists && rootCertExists && chainFilesExist
 
 	return signingCABundleComplete, bundleExists, nil
 }
@@ -15,19 +15,29 @@
 package bootstrap
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/keycertbundle"
+	"istio.io/istio/pilot/pkg/server"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/kclient/clienttest"
+	"istio.io/istio/pkg/security"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/env"
 	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+	caserver "istio.io/istio/security/pkg/server/ca"
 )
 
 const testNamespace = "istio-system"
@@ -69,6 +79,17 @@ func TestCheckCABundleCompleteness(t *testing.T) {
 	g.Expect(signingCABundleComplete).Should(Equal(false))
 	g.Expect(bundleExists).Should(Equal(true))
 
+	// The signing key file is not required when the signing key is kept by a backend
+	signingCABundleComplete, bundleExists, err = checkCABundleCompleteness(
+		"",
+		path.Join(dir, "ca-cert.pem"),
+		path.Join(dir, "root-cert.pem"),
+		[]string{path.Join(dir, "cert-chain.pem")},
+	)
+	g.Expect(err).Should(BeNil())
+	g.Expect(signingCABundleComplete).Should(Equal(true))
+	g.Expect(bundleExists).Should(Equal(true))
+
 	// Add missing key file to complete the bundle
 	caKey, err := readSampleCertFromFile("ca-key.pem")
 	g.Expect(err).Should(BeNil())
@@ -221,3 +242,114 @@ func createCASecret(t test.Failer, client kube.Client) {
 func readSampleCertFromFile(f string) ([]byte, error) {
 	return os.ReadFile(path.Join(env.IstioSrc, "samples/certs", f))
 }
+
+func TestPublishCACRL(t *testing.T) {
+	g := NewWithT(t)
+
+	caOpts, err := ca.NewSelfSignedDebugIstioCAOptions("", time.Hour, time.Hour, time.Hour, "cluster.local", 2048)
+	g.Expect(err).Should(BeNil())
+	s := Server{
+		istiodCertBundleWatcher: keycertbundle.NewWatcher(),
+	}
+	var istioCA *ca.IstioCA
+	caOpts.Revocation = s.caRevocationOptions(&caOptions{Namespace: testNamespace}, func() { s.publishCACRL(istioCA) })
+	istioCA, err = ca.NewIstioCA(caOpts)
+	g.Expect(err).Should(BeNil())
+
+	// Nothing is published until a certificate is revoked.
+	s.publishCACRL(istioCA)
+	g.Expect(s.istiodCertBundleWatcher.GetCRL()).Should(BeEmpty())
+
+	csr, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/default", RSAKeySize: 2048})
+	g.Expect(err).Should(BeNil())
+	certPEM, err := istioCA.Sign(csr, ca.CertOpts{SubjectIDs: []string{"spiffe://cluster.local/ns/default/sa/default"}, TTL: time.Hour})
+	g.Expect(err).Should(BeNil())
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	g.Expect(err).Should(BeNil())
+
+	_, err = istioCA.RevokeCertificate(cert.SerialNumber.Text(16), 1)
+	g.Expect(err).Should(BeNil())
+	g.Expect(s.istiodCertBundleWatcher.GetCRL()).Should(Equal(istioCA.GetCRLPem()))
+	g.Expect(string(s.istiodCertBundleWatcher.GetCRL())).Should(HavePrefix("-----BEGIN X509 CRL-----"))
+}
+
+func TestCSRAuthorizer(t *testing.T) {
+	g := NewWithT(t)
+	stop := test.NewStop(t)
+	s := Server{
+		kubeClient:   kube.NewFakeClient(),
+		internalStop: stop,
+	}
+	configMaps := clienttest.NewWriter[*v1.ConfigMap](t, s.kubeClient)
+	setPolicy := func(policy string) {
+		configMaps.CreateOrUpdate(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultMeshConfigMapName, Namespace: testNamespace},
+			Data:       map[string]string{"mesh": "", "csrAuthorization": policy},
+		})
+	}
+	setPolicy("defaultAction: DENY")
+	authorizer := s.initCSRAuthorizer(&PilotArgs{Namespace: testNamespace, MeshConfigFile: path.Join(t.TempDir(), "mesh")})
+	s.kubeClient.RunAndWait(stop)
+	request := &caserver.CSRRequest{Caller: &security.Caller{Identities: []string{"spiffe://cluster.local/ns/a/sa/a"}}}
+	authorize := func() error {
+		return authorizer.AuthorizeCSR(context.Background(), request)
+	}
+	g.Expect(authorize()).Should(HaveOccurred())
+
+	// An invalid policy is ignored.
+	setPolicy("defaultAction: REJECT")
+	g.Consistently(authorize).WithTimeout(100 * time.Millisecond).Should(HaveOccurred())
+
+	setPolicy("")
+	g.Eventually(authorize).Should(Succeed())
+}
+
+// identityAuthenticator authenticates callers with the identity of the x-identity header.
+type identityAuthenticator struct{}
+
+func (identityAuthenticator) Authenticate(ctx security.AuthContext) (*security.Caller, error) {
+	return &security.Caller{Identities: ctx.Header("x-identity")}, nil
+}
+
+func (identityAuthenticator) AuthenticatorType() string {
+	return "identity"
+}
+
+func TestCAAdminEndpoints(t *testing.T) {
+	g := NewWithT(t)
+	test.SetForTest(t, &features.CACertInventorySize, 10)
+	test.SetForTest(t, &features.CAAdminIdentities, []string{"spiffe://cluster.local/ns/istio-system/sa/admin"})
+
+	caOpts, err := ca.NewSelfSignedDebugIstioCAOptions("", time.Hour, time.Hour, time.Hour, "cluster.local", 2048)
+	g.Expect(err).Should(BeNil())
+	istioCA, err := ca.NewIstioCA(caOpts)
+	g.Expect(err).Should(BeNil())
+	s := Server{
+		monitoringMux: http.NewServeMux(),
+		httpsMux:      http.NewServeMux(),
+		httpsServer:   &http.Server{},
+		server:        server.New(),
+	}
+	s.initCAServer(istioCA, &caOptions{Namespace: testNamespace, Authenticators: []security.Authenticator{identityAuthenticator{}}})
+
+	get := func(handler http.Handler, tls bool, identity string) int {
+		var srv *httptest.Server
+		if tls {
+			srv = httptest.NewTLSServer(handler)
+		} else {
+			srv = httptest.NewServer(handler)
+		}
+		defer srv.Close()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+caserver.CertificatesPath, nil)
+		g.Expect(err).Should(BeNil())
+		req.Header.Set("x-identity", identity)
+		res, err := srv.Client().Do(req)
+		g.Expect(err).Should(BeNil())
+		res.Body.Close()
+		return res.StatusCode
+	}
+	g.Expect(get(s.httpsMux, true, "spiffe://cluster.local/ns/istio-system/sa/admin")).Should(Equal(http.StatusOK))
+	g.Expect(get(s.httpsMux, true, "spiffe://cluster.local/ns/default/sa/default")).Should(Equal(http.StatusUnauthorized))
+	// The inventory is not served on the plaintext monitoring port.
+	g.Expect(get(s.monitoringMux, false, "spiffe://cluster.local/ns/istio-system/sa/admin")).Should(Equal(http.StatusNotFound))
+}
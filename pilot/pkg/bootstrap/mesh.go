@@ -16,6 +16,7 @@ package bootstrap
 
 import (
 	"os"
+	"path/filepath"
 
 	"sigs.k8s.io/yaml"
 
@@ -127,6 +128,39 @@ func (s *Server) getConfigurationSources(args *PilotArgs, fileWatcher filewatche
 	return toSources(primary, userMeshConfig)
 }
 
+// watchMeshConfigKey calls the handler with the value of an additional key of the mesh config, read from the file
+// of the key next to the mesh config file or from the mesh config ConfigMap, and on its changes. The value is nil if
+// the key is unset. The handler is first called before watchMeshConfigKey returns.
+func (s *Server) watchMeshConfigKey(args *PilotArgs, key string, name string, handler func(*string)) {
+	file := filepath.Join(filepath.Dir(args.MeshConfigFile), key)
+	sources := s.getConfigurationSources(args, s.fileWatcher, file, key)
+	if len(sources) == 0 {
+		return
+	}
+	opts := krt.NewOptionsBuilder(s.internalStop, "", args.KrtDebugger)
+	values := krt.NewSingleton(func(ctx krt.HandlerContext) *string {
+		for _, source := range sources {
+			if value := krt.FetchOne(ctx, source.AsCollection()); value != nil {
+				return value
+			}
+		}
+		return nil
+	}, opts.WithName(name)...)
+	// A change of the value is an addition of the new value and a deletion of the old one, as the value is its own
+	// key: the current value is handled on any event.
+	var current *string
+	reg := values.Register(func(krt.Event[string]) {
+		value := values.Get()
+		if current != nil && value != nil && *current == *value {
+			return
+		}
+		current = value
+		handler(value)
+	})
+	values.AsCollection().WaitUntilSynced(s.internalStop)
+	reg.WaitUntilSynced(s.internalStop)
+}
+
 func toSources(base meshwatcher.MeshConfigSource, user *meshwatcher.MeshConfigSource) []meshwatcher.MeshConfigSource {
 	if user != nil {
 		// User configuration is applied first
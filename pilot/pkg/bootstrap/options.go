@@ -23,8 +23,10 @@ import (
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/ctrlz"
 	"istio.io/istio/pkg/env"
+	commonFeatures "istio.io/istio/pkg/features"
 	"istio.io/istio/pkg/keepalive"
 	"istio.io/istio/pkg/kube/krt"
+	pm "istio.io/istio/pkg/model"
 )
 
 // RegistryOptions provide configuration options for the configuration controller. If FileDir is set, that directory will
@@ -152,6 +154,10 @@ func (p *PilotArgs) Complete() error {
 		return err
 	}
 	p.ServerOptions.TLSOptions.CipherSuites = cipherSuites
+	// The crypto profile of the compliance policy is enforced on the xDS, CA and webhook endpoints, so fail early on a typo.
+	if _, err := pm.GetCryptoProfile(commonFeatures.CompliancePolicy); err != nil {
+		return err
+	}
 	return nil
 }
 
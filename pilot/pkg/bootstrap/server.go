@@ -61,6 +61,7 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/mesh/kubemesh"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/schema/kind"
@@ -312,6 +313,7 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	caOpts := &caOptions{
 		TrustDomain:      s.environment.Mesh().TrustDomain,
 		Namespace:        args.Namespace,
+		PodName:          args.PodName,
 		ExternalCAType:   ra.CaExternalType(externalCaType),
 		CertSignerDomain: features.CertSignerDomain,
 	}
@@ -407,6 +409,9 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 		s.XDSServer.Authenticators = authenticators
 	}
 	caOpts.Authenticators = authenticators
+	if s.CA != nil || s.RA != nil {
+		caOpts.Authorizers = []caserver.CSRAuthorizer{s.initCSRAuthorizer(args)}
+	}
 
 	// Start CA or RA server. This should be called after CA and Istiod certs have been created.
 	s.startCA(caOpts)
@@ -471,6 +476,9 @@ func (s *Server) Start(stop <-chan struct{}) error {
 	if !s.waitForCacheSync(stop) {
 		return fmt.Errorf("failed to sync cache")
 	}
+	if features.XDSCacheSnapshotPath != "" {
+		s.XDSServer.RestoreCacheSnapshot(features.XDSCacheSnapshotPath)
+	}
 	// Inform Discovery Server so that it can start accepting connections.
 	s.XDSServer.CachesSynced()
 
@@ -1345,6 +1353,8 @@ func (s *Server) initWorkloadTrustBundle(args *PilotArgs) error {
 		_ = s.workloadTrustBundle.AddMeshConfigUpdate(s.environment.Mesh())
 	})
 
+	s.initSpiffeFederation(args)
+
 	err = s.addIstioCAToTrustBundle(args)
 	if err != nil {
 		return err
@@ -1367,6 +1377,29 @@ func (s *Server) initWorkloadTrustBundle(args *PilotArgs) error {
 	return nil
 }
 
+// initSpiffeFederation polls the bundles of the trust domains federated with the mesh, configured in the
+// spiffeFederation key of the mesh config, and adds them to the workload trust bundle.
+func (s *Server) initSpiffeFederation(args *PilotArgs) {
+	federation := tb.NewFederation(s.workloadTrustBundle)
+	s.watchMeshConfigKey(args, kubemesh.SpiffeFederationKey, "SpiffeFederation", func(cfgYAML *string) {
+		if cfgYAML == nil || strings.TrimSpace(*cfgYAML) == "" {
+			federation.SetConfig(nil)
+			return
+		}
+		cfg, err := tb.ParseFederationConfig(*cfgYAML)
+		if err != nil {
+			log.Warnf("invalid SPIFFE federation configuration, using last known state: %v", err)
+			return
+		}
+		log.Infof("SPIFFE federation updated, with %d trust domains", len(cfg.TrustDomains))
+		federation.SetConfig(cfg)
+	})
+	s.addStartFunc("spiffe federation", func(stop <-chan struct{}) error {
+		go federation.Run(stop)
+		return nil
+	})
+}
+
 // isK8SSigning returns whether K8S (as a RA) is used to sign certs instead of private keys known by Istiod
 func (s *Server) isK8SSigning() bool {
 	return s.RA != nil && strings.HasPrefix(features.PilotCertProvider, constants.CertProviderKubernetesSignerPrefix)
@@ -90,6 +90,34 @@ var (
 
 	CertSignerDomain = env.Register("CERT_SIGNER_DOMAIN", "", "The cert signer domain info").Get()
 
+	CAAdminIdentities = func() []string {
+		identities := env.Register("PILOT_CA_ADMIN_IDENTITIES", "",
+			"Comma separated list of the SPIFFE identities allowed to call the CA management endpoints, served on the "+
+				"HTTPS port of istiod. Callers are authenticated like CSRs. If empty, all the requests are denied.").Get()
+		if identities == "" {
+			return nil
+		}
+		return strings.Split(identities, ",")
+	}()
+
+	EnableCARevocation = env.Register("PILOT_ENABLE_CA_REVOCATION", false,
+		"If enabled, the workload certificates issued by the Istio CA can be revoked by PILOT_CA_ADMIN_IDENTITIES "+
+			"through the /ca/revocations endpoint of the HTTPS port, and a CRL of the revoked certificates is distributed "+
+			"to proxies along with the CRL of the plugged-in CA. Requires PILOT_ENABLE_CA_CRL. With a plugged-in "+
+			"CA, a ca-crl.pem file must be provided, as proxies require a CRL for every CA of the chain.").Get()
+
+	EnableCARootRotation = env.Register("PILOT_ENABLE_CA_ROOT_ROTATION", false,
+		"If enabled, the root of the Istio CA can be rotated in stages by creating the istio-ca-root-rotation secret, "+
+			"e.g. with istioctl x ca rotate, and the status of the rotation is served to PILOT_CA_ADMIN_IDENTITIES by the "+
+			"/ca/rotation endpoint of the HTTPS port. Requires ISTIO_MULTIROOT_MESH, for proxies to receive and ack the trust bundle. "+
+			"Each replica reports the acks of its own proxies, and the rotation waits for all the running istiod pods of the "+
+			"namespace, of all the revisions, which must all enable it.").Get()
+
+	CACertInventorySize = env.Register("PILOT_CA_CERT_INVENTORY_SIZE", 0,
+		"The maximum number of certificates issued by this replica recorded in the certificate inventory of the CA, "+
+			"served to PILOT_CA_ADMIN_IDENTITIES by the /ca/certificates endpoint of the HTTPS port and summarized by the "+
+			"citadel_server_issued_certs metric. The oldest certificates are evicted once full. 0 disables the inventory.").Get()
+
 	UseCacertsForSelfSignedCA = env.Register("USE_CACERTS_FOR_SELF_SIGNED_CA", false,
 		"If enabled, istiod will use a secret named cacerts to store its self-signed istio-"+
 			"generated root certificate.").Get()
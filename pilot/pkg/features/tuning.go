@@ -55,6 +55,34 @@ var (
 		return min(15+5*procs, 100)
 	}()
 
+	EnablePushPriority = env.Register(
+		"PILOT_ENABLE_PUSH_PRIORITY",
+		false,
+		"If enabled, pushes are scheduled by priority rather than in order: gateways and proxies affected by a config change "+
+			"are pushed first, and proxies not affected by it last. Each priority below the highest is limited to a share of "+
+			"PILOT_PUSH_THROTTLE, so a large push cannot delay the others indefinitely.",
+	).Get()
+
+	PushThrottleNormalPriority = env.Register(
+		"PILOT_PUSH_THROTTLE_NORMAL_PRIORITY",
+		max(PushThrottle*3/4, 1),
+		"Limits the number of concurrent normal priority pushes, if PILOT_ENABLE_PUSH_PRIORITY is enabled.",
+	).Get()
+
+	PushThrottleLowPriority = env.Register(
+		"PILOT_PUSH_THROTTLE_LOW_PRIORITY",
+		max(PushThrottle/4, 1),
+		"Limits the number of concurrent low priority pushes, if PILOT_ENABLE_PUSH_PRIORITY is enabled.",
+	).Get()
+
+	PushPriorityMaxDelay = env.Register(
+		"PILOT_PUSH_PRIORITY_MAX_DELAY",
+		10*time.Second,
+		"If PILOT_ENABLE_PUSH_PRIORITY is enabled, a push that has been queued for longer than this is sent ahead of "+
+			"higher priority pushes, and even if its priority has used up its share of PILOT_PUSH_THROTTLE, so that low "+
+			"priority proxies are not starved during config storms.",
+	).Get()
+
 	RequestLimit = func() float64 {
 		v := env.Register(
 			"PILOT_MAX_REQUESTS_PER_SECOND",
@@ -118,4 +146,12 @@ var (
 
 	XDSCacheIndexClearInterval = env.Register("PILOT_XDS_CACHE_INDEX_CLEAR_INTERVAL", 5*time.Second,
 		"The interval for xds cache index clearing.").Get()
+
+	XDSCacheSnapshotPath = env.Register("PILOT_XDS_CACHE_SNAPSHOT_PATH", "",
+		"If set, Pilot periodically writes a snapshot of the CDS and RDS cache to this file, and restores it on startup "+
+			"so that a restarted Pilot can serve cached resources immediately. Entries are only restored if the configs "+
+			"and services they were generated from are unchanged.").Get()
+
+	XDSCacheSnapshotInterval = env.Register("PILOT_XDS_CACHE_SNAPSHOT_INTERVAL", 5*time.Minute,
+		"The interval at which the xds cache snapshot is written, if PILOT_XDS_CACHE_SNAPSHOT_PATH is set.").Get()
 )
@@ -223,6 +223,27 @@ func (e *Environment) Init() {
 	e.clusterLocalServices = NewClusterLocalProvider(e)
 }
 
+// WithConfigStore returns a copy of the environment reading configs from store, sharing everything else.
+// It has no push context, and does not cache XDS resources. This allows computing a push context for
+// a hypothetical set of configs without affecting the environment.
+func (e *Environment) WithConfigStore(store ConfigStore) *Environment {
+	return &Environment{
+		ServiceDiscovery:      e.ServiceDiscovery,
+		ConfigStore:           store,
+		Watcher:               e.Watcher,
+		NetworksWatcher:       e.NetworksWatcher,
+		NetworkManager:        e.NetworkManager,
+		pushContext:           NewPushContext(),
+		DomainSuffix:          e.DomainSuffix,
+		TrustBundle:           e.TrustBundle,
+		clusterLocalServices:  e.clusterLocalServices,
+		CredentialsController: e.CredentialsController,
+		GatewayAPIController:  e.GatewayAPIController,
+		EndpointIndex:         e.EndpointIndex,
+		Cache:                 DisabledCache{},
+	}
+}
+
 func (e *Environment) InitNetworksManager(updater XDSUpdater) (err error) {
 	e.NetworkManager, err = NewNetworkManager(e, updater)
 	return
@@ -359,6 +359,51 @@ func (l *lruCache[K]) Snapshot() []*discovery.Resource {
 	return res
 }
 
+// export returns all entries in the cache, from least to most recently used.
+func (l *lruCache[K]) export() []cacheEntry[K] {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	keys := l.store.Keys()
+	res := make([]cacheEntry[K], 0, len(keys))
+	for _, k := range keys {
+		v, ok := l.store.Peek(k)
+		if !ok || v.value == nil {
+			continue
+		}
+		res = append(res, cacheEntry[K]{key: k, value: v.value, dependentConfigs: v.dependentConfigs})
+	}
+	return res
+}
+
+// restore adds entries generated before since to the cache. Restored entries have the lowest possible
+// token, so any entry generated by this process replaces them. Nothing is restored if the cache has been
+// cleared after since, as the entries may depend on configs that have changed in the meantime.
+// It returns the number of entries restored.
+func (l *lruCache[K]) restore(entries []cacheEntry[K], since time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token > CacheToken(since.UnixNano()) {
+		return 0
+	}
+	restored := 0
+	for _, e := range entries {
+		if l.store.Contains(e.key) {
+			continue
+		}
+		l.store.Add(e.key, cacheValue{value: e.value, token: 1, dependentConfigs: e.dependentConfigs})
+		l.updateConfigIndex(e.key, e.dependentConfigs)
+		restored++
+	}
+	size(l.store.Len())
+	return restored
+}
+
+type cacheEntry[K comparable] struct {
+	key              K
+	value            *discovery.Resource
+	dependentConfigs []ConfigHash
+}
+
 func (l *lruCache[K]) indexLength() int {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -182,6 +182,20 @@ func (x XdsCacheImpl) ClearAll() {
 	x.sds.ClearAll()
 }
 
+// typed returns the cache for the given uint64 keyed type.
+func (x XdsCacheImpl) typed(t string) typedXdsCache[uint64] {
+	switch t {
+	case CDSType:
+		return x.cds
+	case EDSType:
+		return x.eds
+	case RDSType:
+		return x.rds
+	default:
+		return nil
+	}
+}
+
 func (x XdsCacheImpl) Keys(t string) []any {
 	switch t {
 	case CDSType:
@@ -0,0 +1,220 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/file"
+	"istio.io/istio/pkg/util/hash"
+	"istio.io/istio/pkg/version"
+)
+
+// XdsCacheSnapshot is a persisted copy of the XDS cache, used to warm the cache of a restarted istiod.
+//
+// Only CDS and RDS entries are persisted. SDS entries hold private keys and must never be written to
+// disk, and EDS entries depend on endpoints, which are not versioned and change far too often for a
+// persisted copy to be useful.
+//
+// Cache keys only identify the configs an entry was generated from, not their content, so each entry
+// is stored with the resource versions of its dependent configs at the time the snapshot was written.
+// On restore, an entry is only used if all of its dependent configs still have the same version.
+type XdsCacheSnapshot struct {
+	// IstioVersion is the version of istiod that wrote the snapshot. Generated config may change between
+	// versions, so a snapshot is only restored by the same version.
+	IstioVersion string `json:"istioVersion"`
+	// MeshHash is a hash of the mesh config, mesh networks and domain suffix. These are not tracked as
+	// dependent configs, so a change to any of them invalidates the whole snapshot.
+	MeshHash uint64 `json:"meshHash"`
+	// Fingerprints holds the resource version of each dependent config of the entries. A config that
+	// did not exist when the snapshot was written is not present.
+	Fingerprints map[ConfigHash]string   `json:"fingerprints"`
+	Entries      []XdsCacheSnapshotEntry `json:"entries"`
+}
+
+// XdsCacheSnapshotEntry is a single entry of an XdsCacheSnapshot.
+type XdsCacheSnapshotEntry struct {
+	// Type is the cache type, CDSType or RDSType.
+	Type             string       `json:"type"`
+	Key              uint64       `json:"key"`
+	DependentConfigs []ConfigHash `json:"dependentConfigs,omitempty"`
+	// Resource is the binary encoded discovery.Resource.
+	Resource []byte `json:"resource"`
+}
+
+// ConfigFingerprints returns the resource version of every config and service known to env, keyed by
+// the ConfigKey hash used for cache dependencies. Services are keyed as ServiceEntry, like the dependent
+// configs of cache entries. Objects without a resource version are present with an empty version, which
+// never matches.
+func ConfigFingerprints(env *Environment) map[ConfigHash]string {
+	res := map[ConfigHash]string{}
+	add := func(key ConfigKey, version string) {
+		hc := key.HashCode()
+		if cur, f := res[hc]; f && cur != version {
+			// A service and a ServiceEntry may share a key; treat it as unversioned rather than picking one.
+			version = ""
+		}
+		res[hc] = version
+	}
+	if env.ConfigStore != nil {
+		for _, s := range env.ConfigStore.Schemas().All() {
+			k := gvk.MustToKind(s.GroupVersionKind())
+			for _, c := range env.ConfigStore.List(s.GroupVersionKind(), "") {
+				add(ConfigKey{Kind: k, Name: c.Name, Namespace: c.Namespace}, c.ResourceVersion)
+			}
+		}
+	}
+	if env.ServiceDiscovery != nil {
+		for _, svc := range env.ServiceDiscovery.Services() {
+			add(ConfigKey{Kind: kind.ServiceEntry, Name: string(svc.Hostname), Namespace: svc.Attributes.Namespace}, svc.ResourceVersion)
+		}
+	}
+	return res
+}
+
+// meshHash returns the hash stored in XdsCacheSnapshot.MeshHash.
+func meshHash(env *Environment) uint64 {
+	h := hash.New()
+	opts := proto.MarshalOptions{Deterministic: true}
+	if m := env.Mesh(); m != nil {
+		b, _ := opts.Marshal(m)
+		h.Write(b)
+	}
+	h.Write([]byte{'/'})
+	if n := env.MeshNetworks(); n != nil {
+		b, _ := opts.Marshal(n)
+		h.Write(b)
+	}
+	h.Write([]byte{'/'})
+	h.WriteString(env.DomainSuffix)
+	return h.Sum64()
+}
+
+// NewXdsCacheSnapshot returns a snapshot of the CDS and RDS entries of cache. fingerprints must reflect
+// the configs the cache entries were generated from; see ConfigFingerprints.
+func NewXdsCacheSnapshot(env *Environment, cache XdsCache, fingerprints map[ConfigHash]string) (*XdsCacheSnapshot, error) {
+	snap := &XdsCacheSnapshot{
+		IstioVersion: version.Info.String(),
+		MeshHash:     meshHash(env),
+		Fingerprints: map[ConfigHash]string{},
+	}
+	x, ok := cache.(XdsCacheImpl)
+	if !ok {
+		return snap, nil
+	}
+	for _, t := range []string{CDSType, RDSType} {
+		l, ok := x.typed(t).(*lruCache[uint64])
+		if !ok {
+			// The cache for this type is disabled
+			continue
+		}
+		for _, e := range l.export() {
+			b, err := proto.Marshal(e.value)
+			if err != nil {
+				return nil, fmt.Errorf("marshal %v entry %v: %v", t, e.key, err)
+			}
+			for _, d := range e.dependentConfigs {
+				if v, f := fingerprints[d]; f {
+					snap.Fingerprints[d] = v
+				}
+			}
+			snap.Entries = append(snap.Entries, XdsCacheSnapshotEntry{
+				Type:             t,
+				Key:              e.key,
+				DependentConfigs: e.dependentConfigs,
+				Resource:         b,
+			})
+		}
+	}
+	return snap, nil
+}
+
+// Restore adds the entries of the snapshot that are still valid for env to cache. since must be a time
+// before the configs of env were read; if the cache has been cleared after it, nothing is restored.
+// It returns the number of entries restored.
+func (s *XdsCacheSnapshot) Restore(env *Environment, cache XdsCache, since time.Time) (int, error) {
+	x, ok := cache.(XdsCacheImpl)
+	if !ok {
+		return 0, nil
+	}
+	if s.IstioVersion != version.Info.String() {
+		return 0, fmt.Errorf("snapshot was written by istiod %v", s.IstioVersion)
+	}
+	if s.MeshHash != meshHash(env) {
+		return 0, fmt.Errorf("mesh config changed since the snapshot was written")
+	}
+	current := ConfigFingerprints(env)
+	valid := func(e XdsCacheSnapshotEntry) bool {
+		if e.Type != CDSType && e.Type != RDSType {
+			return false
+		}
+		for _, d := range e.DependentConfigs {
+			was, existed := s.Fingerprints[d]
+			now, exists := current[d]
+			if existed != exists || was != now || (exists && now == "") {
+				return false
+			}
+		}
+		return true
+	}
+	entries := map[string][]cacheEntry[uint64]{}
+	for _, e := range s.Entries {
+		if !valid(e) {
+			continue
+		}
+		res := &discovery.Resource{}
+		if err := proto.Unmarshal(e.Resource, res); err != nil {
+			return 0, fmt.Errorf("invalid %v entry %v: %v", e.Type, e.Key, err)
+		}
+		entries[e.Type] = append(entries[e.Type], cacheEntry[uint64]{key: e.Key, value: res, dependentConfigs: e.DependentConfigs})
+	}
+	restored := 0
+	for t, es := range entries {
+		if l, ok := x.typed(t).(*lruCache[uint64]); ok {
+			restored += l.restore(es, since)
+		}
+	}
+	return restored, nil
+}
+
+// WriteFile atomically writes the snapshot to path.
+func (s *XdsCacheSnapshot) WriteFile(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return file.AtomicWrite(path, b, 0o600)
+}
+
+// ReadXdsCacheSnapshot reads a snapshot written by XdsCacheSnapshot.WriteFile.
+func ReadXdsCacheSnapshot(path string) (*XdsCacheSnapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snap := &XdsCacheSnapshot{}
+	if err := json.Unmarshal(b, snap); err != nil {
+		return nil, fmt.Errorf("invalid xds cache snapshot %v: %v", path, err)
+	}
+	return snap, nil
+}
@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/mesh/meshwatcher"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+type snapshotTestEntry struct {
+	entry
+	typ string
+}
+
+func (e snapshotTestEntry) Type() string {
+	return e.typ
+}
+
+func (e snapshotTestEntry) Key() any {
+	return e.entry.Key()
+}
+
+func (e snapshotTestEntry) Cacheable() bool {
+	return true
+}
+
+func TestXdsCacheSnapshot(t *testing.T) {
+	dr := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.DestinationRule,
+			Name:             "reviews",
+			Namespace:        "default",
+			ResourceVersion:  "1",
+		},
+		Spec: &networking.DestinationRule{Host: "reviews"},
+	}
+	svc := &Service{
+		Hostname:        "reviews.default.svc.cluster.local",
+		Attributes:      ServiceAttributes{Namespace: "default"},
+		ResourceVersion: "10",
+	}
+	unversioned := &Service{
+		Hostname:   "ratings.default.svc.cluster.local",
+		Attributes: ServiceAttributes{Namespace: "default"},
+	}
+	newEnv := func() *Environment {
+		env := NewEnvironment()
+		store := NewFakeStore()
+		_, _ = store.Create(dr)
+		env.ConfigStore = store
+		env.ServiceDiscovery = &localServiceDiscovery{services: []*Service{svc, unversioned}}
+		env.Watcher = meshwatcher.NewTestWatcher(mesh.DefaultMeshConfig())
+		return env
+	}
+	drKey := ConfigKey{Kind: kind.DestinationRule, Name: "reviews", Namespace: "default"}.HashCode()
+	svcKey := ConfigKey{Kind: kind.ServiceEntry, Name: string(svc.Hostname), Namespace: "default"}.HashCode()
+	vsKey := ConfigKey{Kind: kind.VirtualService, Name: "reviews", Namespace: "default"}.HashCode()
+	unversionedKey := ConfigKey{Kind: kind.ServiceEntry, Name: string(unversioned.Hostname), Namespace: "default"}.HashCode()
+
+	cluster := snapshotTestEntry{entry: entry{key: "cluster", dependentConfigs: []ConfigHash{svcKey, drKey}}, typ: CDSType}
+	route := snapshotTestEntry{entry: entry{key: "route", dependentConfigs: []ConfigHash{svcKey, vsKey}}, typ: RDSType}
+	unversionedCluster := snapshotTestEntry{entry: entry{key: "unversioned", dependentConfigs: []ConfigHash{unversionedKey}}, typ: CDSType}
+	endpoints := snapshotTestEntry{entry: entry{key: "endpoints", dependentConfigs: []ConfigHash{svcKey}}, typ: EDSType}
+	entries := []snapshotTestEntry{cluster, route, unversionedCluster, endpoints}
+
+	// Write a snapshot from a populated cache
+	env := newEnv()
+	cache := NewXdsCache()
+	req := &PushRequest{Start: time.Now()}
+	for _, e := range entries {
+		cache.Add(e, req, &discovery.Resource{Name: e.key})
+	}
+	snap, err := NewXdsCacheSnapshot(env, cache, ConfigFingerprints(env))
+	assert.NoError(t, err)
+	// EDS is never persisted
+	assert.Equal(t, len(snap.Entries), 3)
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	assert.NoError(t, snap.WriteFile(path))
+	snap, err = ReadXdsCacheSnapshot(path)
+	assert.NoError(t, err)
+
+	restore := func(t *testing.T, env *Environment) XdsCache {
+		t.Helper()
+		cache := NewXdsCache()
+		_, err := snap.Restore(env, cache, time.Now())
+		assert.NoError(t, err)
+		return cache
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		cache := restore(t, newEnv())
+		assert.Equal(t, cache.Get(cluster).GetName(), "cluster")
+		assert.Equal(t, cache.Get(route).GetName(), "route")
+		assert.Equal(t, cache.Get(unversionedCluster) == nil, true)
+		assert.Equal(t, cache.Get(endpoints) == nil, true)
+	})
+	t.Run("restored entries are invalidated", func(t *testing.T) {
+		cache := restore(t, newEnv())
+		cache.Clear(map[ConfigKey]struct{}{{Kind: kind.DestinationRule, Name: "reviews", Namespace: "default"}: {}})
+		assert.Equal(t, cache.Get(cluster) == nil, true)
+		assert.Equal(t, cache.Get(route).GetName(), "route")
+	})
+	t.Run("restored entries are replaced", func(t *testing.T) {
+		cache := restore(t, newEnv())
+		cache.Add(cluster, &PushRequest{Start: time.Now()}, &discovery.Resource{Name: "regenerated"})
+		assert.Equal(t, cache.Get(cluster).GetName(), "regenerated")
+	})
+	t.Run("config changed", func(t *testing.T) {
+		env := newEnv()
+		updated := dr.DeepCopy()
+		updated.ResourceVersion = "2"
+		_, _ = env.ConfigStore.Update(updated)
+		cache := restore(t, env)
+		assert.Equal(t, cache.Get(cluster) == nil, true)
+		assert.Equal(t, cache.Get(route).GetName(), "route")
+	})
+	t.Run("config created", func(t *testing.T) {
+		env := newEnv()
+		_, _ = env.ConfigStore.Create(config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.VirtualService,
+				Name:             "reviews",
+				Namespace:        "default",
+				ResourceVersion:  "3",
+			},
+			Spec: &networking.VirtualService{},
+		})
+		cache := restore(t, env)
+		assert.Equal(t, cache.Get(cluster).GetName(), "cluster")
+		assert.Equal(t, cache.Get(route) == nil, true)
+	})
+	t.Run("service changed", func(t *testing.T) {
+		env := newEnv()
+		changed := svc.DeepCopy()
+		changed.ResourceVersion = "11"
+		env.ServiceDiscovery = &localServiceDiscovery{services: []*Service{changed}}
+		cache := restore(t, env)
+		assert.Equal(t, len(cache.Keys(CDSType))+len(cache.Keys(RDSType)), 0)
+	})
+	t.Run("mesh config changed", func(t *testing.T) {
+		env := newEnv()
+		m := mesh.DefaultMeshConfig()
+		m.EnableTracing = !m.EnableTracing
+		env.Watcher = meshwatcher.NewTestWatcher(m)
+		_, err := snap.Restore(env, NewXdsCache(), time.Now())
+		assert.Error(t, err)
+	})
+	t.Run("cleared during restore", func(t *testing.T) {
+		cache := NewXdsCache()
+		since := time.Now()
+		cache.ClearAll()
+		restored, err := snap.Restore(newEnv(), cache, since)
+		assert.NoError(t, err)
+		assert.Equal(t, restored, 0)
+	})
+}
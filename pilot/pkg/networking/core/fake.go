@@ -19,6 +19,7 @@ package core
 
 import (
 	"bytes"
+	"fmt"
 	"text/template"
 	"time"
 
@@ -88,6 +89,9 @@ type TestOptions struct {
 
 	// XDSUpdater to use. Otherwise, our own will be used
 	XDSUpdater model.XDSUpdater
+
+	// Environment to populate. Otherwise, a new one will be created
+	Environment *model.Environment
 }
 
 func (to TestOptions) FuzzValidate() bool {
@@ -104,22 +108,28 @@ func (to TestOptions) FuzzValidate() bool {
 	return true
 }
 
-type ConfigGenTest struct {
-	t                    test.Failer
+// OfflineConfigGen generates the configuration of proxies from in-memory config stores and service registries,
+// outside of a running istiod. It backs ConfigGenTest, and tools generating configuration from local files.
+type OfflineConfigGen struct {
 	store                model.ConfigStoreController
 	env                  *model.Environment
+	xdsUpdater           model.XDSUpdater
 	ConfigGen            *ConfigGeneratorImpl
 	MemRegistry          *memregistry.ServiceDiscovery
 	ServiceEntryRegistry *serviceentry.Controller
 	Registry             model.Controller
 	initialConfigs       []config.Config
-	stop                 chan struct{}
+	stop                 <-chan struct{}
 	MemServiceRegistry   serviceregistry.Simple
 }
 
-func NewConfigGenTest(t test.Failer, opts TestOptions) *ConfigGenTest {
-	t.Helper()
-	configs := getConfigs(t, opts)
+// NewOfflineConfigGen wires the config stores and service registries of opts. Run must be called before
+// generating configuration, unless opts.SkipRun is set.
+func NewOfflineConfigGen(opts TestOptions, stop <-chan struct{}) (*OfflineConfigGen, error) {
+	configs, err := parseConfigs(opts)
+	if err != nil {
+		return nil, err
+	}
 	cc := opts.ConfigController
 	if cc == nil {
 		cc = memory.NewSyncController(memory.MakeSkipValidation(collections.PilotGatewayAPI()))
@@ -129,14 +139,20 @@ func NewConfigGenTest(t test.Failer, opts TestOptions) *ConfigGenTest {
 		controllers = append(controllers, opts.CreateConfigStore(cc))
 	}
 	controllers = append(controllers, opts.ConfigStoreCaches...)
-	configController, _ := configaggregate.MakeWriteableCache(controllers, cc)
+	configController, err := configaggregate.MakeWriteableCache(controllers, cc)
+	if err != nil {
+		return nil, err
+	}
 
 	m := opts.MeshConfig
 	if m == nil {
 		m = mesh.DefaultMeshConfig()
 	}
 
-	env := model.NewEnvironment()
+	env := opts.Environment
+	if env == nil {
+		env = model.NewEnvironment()
+	}
 	env.Watcher = meshwatcher.NewTestWatcher(m)
 
 	xdsUpdater := opts.XDSUpdater
@@ -178,49 +194,50 @@ func NewConfigGenTest(t test.Failer, opts TestOptions) *ConfigGenTest {
 	env.NetworksWatcher = opts.NetworksWatcher
 	env.Init()
 
-	fake := &ConfigGenTest{
-		t:                    t,
+	return &OfflineConfigGen{
 		store:                configController,
 		env:                  env,
+		xdsUpdater:           xdsUpdater,
 		initialConfigs:       configs,
-		stop:                 test.NewStop(t),
+		stop:                 stop,
 		ConfigGen:            NewConfigGenerator(&model.DisabledCache{}),
 		MemRegistry:          msd,
 		MemServiceRegistry:   memserviceRegistry,
 		Registry:             serviceDiscovery,
 		ServiceEntryRegistry: se,
-	}
-	if !opts.SkipRun {
-		fake.Run()
-		if err := env.InitNetworksManager(xdsUpdater); err != nil {
-			t.Fatal(err)
-		}
-		env.PushContext().InitContext(env, nil, nil)
-	}
-	return fake
+	}, nil
 }
 
-func (f *ConfigGenTest) Run() {
+// Run starts the service registries and the config store, and creates the initial configs.
+func (f *OfflineConfigGen) Run() error {
 	go f.Registry.Run(f.stop)
 	go f.store.Run(f.stop)
 	// Setup configuration. This should be done after registries are added so they can process events.
 	for _, cfg := range f.initialConfigs {
 		if _, err := f.store.Create(cfg); err != nil {
-			f.t.Fatalf("failed to create config %v: %v", cfg.Name, err)
+			return fmt.Errorf("failed to create config %v: %v", cfg.Name, err)
 		}
 	}
+	return nil
+}
 
-	// TODO allow passing event handlers for controller
-
-	retry.UntilOrFail(f.t, f.store.HasSynced, retry.Delay(time.Millisecond))
-	retry.UntilOrFail(f.t, f.Registry.HasSynced, retry.Delay(time.Millisecond))
+// HasSynced returns true once the config store and the service registries have processed the initial state.
+func (f *OfflineConfigGen) HasSynced() bool {
+	return f.store.HasSynced() && f.Registry.HasSynced()
+}
 
-	f.ServiceEntryRegistry.ResyncEDS()
+// InitPushContext initializes the networks and the push context. It should be called once synced.
+func (f *OfflineConfigGen) InitPushContext() error {
+	if err := f.env.InitNetworksManager(f.xdsUpdater); err != nil {
+		return err
+	}
+	f.env.PushContext().InitContext(f.env, nil, nil)
+	return nil
 }
 
 // SetupProxy initializes a proxy for the current environment. This should generally be used when creating
 // any proxy. For example, `p := SetupProxy(&model.Proxy{...})`.
-func (f *ConfigGenTest) SetupProxy(p *model.Proxy) *model.Proxy {
+func (f *OfflineConfigGen) SetupProxy(p *model.Proxy) *model.Proxy {
 	// Setup defaults
 	if p == nil {
 		p = &model.Proxy{}
@@ -228,9 +245,6 @@ func (f *ConfigGenTest) SetupProxy(p *model.Proxy) *model.Proxy {
 	if p.Metadata == nil {
 		p.Metadata = &model.NodeMetadata{}
 	}
-	if p.Metadata.IstioVersion == "" {
-		p.Metadata.IstioVersion = "1.23.0"
-	}
 	if p.IstioVersion == nil {
 		p.IstioVersion = model.ParseIstioVersion(p.Metadata.IstioVersion)
 	}
@@ -262,44 +276,25 @@ func (f *ConfigGenTest) SetupProxy(p *model.Proxy) *model.Proxy {
 	return p
 }
 
-func (f *ConfigGenTest) Listeners(p *model.Proxy) []*listener.Listener {
+func (f *OfflineConfigGen) Listeners(p *model.Proxy) []*listener.Listener {
 	return f.ConfigGen.BuildListeners(p, f.PushContext())
 }
 
-func (f *ConfigGenTest) Clusters(p *model.Proxy) []*cluster.Cluster {
+// BuildClusters returns the clusters of the proxy.
+func (f *OfflineConfigGen) BuildClusters(p *model.Proxy) ([]*cluster.Cluster, error) {
 	raw, _ := f.ConfigGen.BuildClusters(p, &model.PushRequest{Push: f.PushContext()})
 	res := make([]*cluster.Cluster, 0, len(raw))
 	for _, r := range raw {
 		c := &cluster.Cluster{}
 		if err := r.Resource.UnmarshalTo(c); err != nil {
-			f.t.Fatal(err)
+			return nil, err
 		}
 		res = append(res, c)
 	}
-	return res
+	return res, nil
 }
 
-func (f *ConfigGenTest) DeltaClusters(
-	p *model.Proxy,
-	configUpdated sets.Set[model.ConfigKey],
-	watched *model.WatchedResource,
-) ([]*cluster.Cluster, []string, bool) {
-	raw, removed, _, delta := f.ConfigGen.BuildDeltaClusters(p,
-		&model.PushRequest{
-			Push: f.PushContext(), ConfigsUpdated: configUpdated,
-		}, watched)
-	res := make([]*cluster.Cluster, 0, len(raw))
-	for _, r := range raw {
-		c := &cluster.Cluster{}
-		if err := r.Resource.UnmarshalTo(c); err != nil {
-			f.t.Fatal(err)
-		}
-		res = append(res, c)
-	}
-	return res, removed, delta
-}
-
-func (f *ConfigGenTest) RoutesFromListeners(p *model.Proxy, l []*listener.Listener) []*route.RouteConfiguration {
+func (f *OfflineConfigGen) RoutesFromListeners(p *model.Proxy, l []*listener.Listener) []*route.RouteConfiguration {
 	resources, _ := f.ConfigGen.BuildHTTPRoutes(p, &model.PushRequest{Push: f.PushContext()}, ExtractRoutesFromListeners(l))
 	out := make([]*route.RouteConfiguration, 0, len(resources))
 	for _, resource := range resources {
@@ -310,23 +305,102 @@ func (f *ConfigGenTest) RoutesFromListeners(p *model.Proxy, l []*listener.Listen
 	return out
 }
 
-func (f *ConfigGenTest) Routes(p *model.Proxy) []*route.RouteConfiguration {
+func (f *OfflineConfigGen) Routes(p *model.Proxy) []*route.RouteConfiguration {
 	return f.RoutesFromListeners(p, f.Listeners(p))
 }
 
-func (f *ConfigGenTest) PushContext() *model.PushContext {
+func (f *OfflineConfigGen) PushContext() *model.PushContext {
 	return f.env.PushContext()
 }
 
-func (f *ConfigGenTest) Env() *model.Environment {
+func (f *OfflineConfigGen) Env() *model.Environment {
 	return f.env
 }
 
-func (f *ConfigGenTest) Store() model.ConfigStoreController {
+func (f *OfflineConfigGen) Store() model.ConfigStoreController {
 	return f.store
 }
 
-func getConfigs(t test.Failer, opts TestOptions) []config.Config {
+type ConfigGenTest struct {
+	*OfflineConfigGen
+	t test.Failer
+}
+
+func NewConfigGenTest(t test.Failer, opts TestOptions) *ConfigGenTest {
+	t.Helper()
+	cg, err := NewOfflineConfigGen(opts, test.NewStop(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &ConfigGenTest{
+		OfflineConfigGen: cg,
+		t:                t,
+	}
+	if !opts.SkipRun {
+		fake.Run()
+		if err := cg.InitPushContext(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fake
+}
+
+func (f *ConfigGenTest) Run() {
+	if err := f.OfflineConfigGen.Run(); err != nil {
+		f.t.Fatal(err)
+	}
+
+	// TODO allow passing event handlers for controller
+
+	retry.UntilOrFail(f.t, f.store.HasSynced, retry.Delay(time.Millisecond))
+	retry.UntilOrFail(f.t, f.Registry.HasSynced, retry.Delay(time.Millisecond))
+
+	f.ServiceEntryRegistry.ResyncEDS()
+}
+
+// SetupProxy initializes a proxy for the current environment, defaulting to a sidecar of Istio 1.23.
+func (f *ConfigGenTest) SetupProxy(p *model.Proxy) *model.Proxy {
+	if p == nil {
+		p = &model.Proxy{}
+	}
+	if p.Metadata == nil {
+		p.Metadata = &model.NodeMetadata{}
+	}
+	if p.Metadata.IstioVersion == "" {
+		p.Metadata.IstioVersion = "1.23.0"
+	}
+	return f.OfflineConfigGen.SetupProxy(p)
+}
+
+func (f *ConfigGenTest) Clusters(p *model.Proxy) []*cluster.Cluster {
+	res, err := f.BuildClusters(p)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return res
+}
+
+func (f *ConfigGenTest) DeltaClusters(
+	p *model.Proxy,
+	configUpdated sets.Set[model.ConfigKey],
+	watched *model.WatchedResource,
+) ([]*cluster.Cluster, []string, bool) {
+	raw, removed, _, delta := f.ConfigGen.BuildDeltaClusters(p,
+		&model.PushRequest{
+			Push: f.PushContext(), ConfigsUpdated: configUpdated,
+		}, watched)
+	res := make([]*cluster.Cluster, 0, len(raw))
+	for _, r := range raw {
+		c := &cluster.Cluster{}
+		if err := r.Resource.UnmarshalTo(c); err != nil {
+			f.t.Fatal(err)
+		}
+		res = append(res, c)
+	}
+	return res, removed, delta
+}
+
+func parseConfigs(opts TestOptions) ([]config.Config, error) {
 	for _, p := range opts.ConfigPointers {
 		if p != nil {
 			opts.Configs = append(opts.Configs, *p)
@@ -337,7 +411,7 @@ func getConfigs(t test.Failer, opts TestOptions) []config.Config {
 		tmpl := template.Must(template.New("").Funcs(sprig.TxtFuncMap()).Parse(opts.ConfigString))
 		var buf bytes.Buffer
 		if err := tmpl.Execute(&buf, opts.ConfigTemplateInput); err != nil {
-			t.Fatalf("failed to execute template: %v", err)
+			return nil, fmt.Errorf("failed to execute template: %v", err)
 		}
 		configStr = buf.String()
 	}
@@ -346,7 +420,7 @@ func getConfigs(t test.Failer, opts TestOptions) []config.Config {
 		t0 := time.Now()
 		configs, _, err := crd.ParseInputs(configStr)
 		if err != nil {
-			t.Fatalf("failed to read config: %v: %v", err, configStr)
+			return nil, fmt.Errorf("failed to read config: %v: %v", err, configStr)
 		}
 		// setup default namespace if not defined
 		for _, c := range configs {
@@ -361,7 +435,7 @@ func getConfigs(t test.Failer, opts TestOptions) []config.Config {
 			cfgs = append(cfgs, c)
 		}
 	}
-	return cfgs
+	return cfgs, nil
 }
 
 // copied from xdstest to avoid import issues
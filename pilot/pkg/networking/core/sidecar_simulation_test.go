@@ -2623,7 +2623,7 @@ spec:
 						Configs:                istio,
 						KubernetesObjectString: cfg,
 					})
-					sim := simulation.NewSimulation(t, s, s.SetupProxy(tt.proxy))
+					sim := simulation.NewSimulationFromConfigGen(t, s.ConfigGenTest, s.SetupProxy(tt.proxy))
 					xdstest.ValidateListeners(t, sim.Listeners)
 					xdstest.ValidateRouteConfigurations(t, sim.Routes)
 					r := xdstest.ExtractRouteConfigurations(sim.Routes)
@@ -456,6 +456,17 @@ func convertAuthorizationPolicy(rootns string, obj *securityclient.Authorization
 	return opol, nil
 }
 
+// ZtunnelAuthorizationPolicyWarning returns the warning reported in the status of an AuthorizationPolicy
+// which ztunnel can only partially enforce, or can not enforce at all. It returns an empty string for
+// policies ztunnel fully enforces, and for policies with target references, which are not enforced by ztunnel.
+func ZtunnelAuthorizationPolicyWarning(rootns string, obj *securityclient.AuthorizationPolicy) string {
+	_, msg := convertAuthorizationPolicy(rootns, obj)
+	if msg == nil {
+		return ""
+	}
+	return msg.Message
+}
+
 const (
 	httpRuleFmt string = "ztunnel does not support HTTP attributes (found: %s). " +
 		"In ambient mode you must use a waypoint proxy to enforce HTTP rules. %s"
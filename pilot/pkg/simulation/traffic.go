@@ -30,7 +30,10 @@ import (
 	envoycore "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -38,14 +41,13 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
-	"istio.io/istio/pilot/test/xds"
-	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config/host"
 	istiolog "istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/wellknown"
 )
 
 var log = istiolog.RegisterScope("simulation", "")
@@ -231,11 +233,15 @@ func (r Result) Matches(t *testing.T, want Result) {
 	}
 }
 
+// Simulation evaluates calls against the configuration of a proxy. Outside of tests, it can be built directly from
+// the Listeners, Clusters and Routes generated for the proxy; only RunExpectations requires t.
 type Simulation struct {
 	t         *testing.T
 	Listeners []*listener.Listener
 	Clusters  []*cluster.Cluster
 	Routes    []*route.RouteConfiguration
+	// MatchHeaders makes routes match on the request headers, in addition to the path.
+	MatchHeaders bool
 }
 
 func NewSimulationFromConfigGen(t *testing.T, s *core.ConfigGenTest, proxy *model.Proxy) *Simulation {
@@ -249,10 +255,6 @@ func NewSimulationFromConfigGen(t *testing.T, s *core.ConfigGenTest, proxy *mode
 	return sim
 }
 
-func NewSimulation(t *testing.T, s *xds.FakeDiscoveryServer, proxy *model.Proxy) *Simulation {
-	return NewSimulationFromConfigGen(t, s.ConfigGenTest, proxy)
-}
-
 // withT swaps out the testing struct. This allows executing sub tests.
 func (sim *Simulation) withT(t *testing.T) *Simulation {
 	cpy := *sim
@@ -268,15 +270,40 @@ func (sim *Simulation) RunExpectations(es []Expect) {
 	}
 }
 
-func hasFilterOnPort(l *listener.Listener, filter string, port int) bool {
-	got, f := xdstest.ExtractListenerFilters(l)[filter]
-	if !f {
-		return false
+func hasFilterOnPort(l *listener.Listener, filter string, port int) (bool, error) {
+	for _, lf := range l.ListenerFilters {
+		if lf.Name != filter {
+			continue
+		}
+		if lf.FilterDisabled == nil {
+			return true, nil
+		}
+		disabled, err := evaluateListenerFilterPredicates(lf.FilterDisabled, port)
+		return !disabled, err
 	}
-	if got.FilterDisabled == nil {
-		return true
+	return false, nil
+}
+
+func evaluateListenerFilterPredicates(predicate *listener.ListenerFilterChainMatchPredicate, port int) (bool, error) {
+	if predicate == nil {
+		return true, nil
+	}
+	switch r := predicate.Rule.(type) {
+	case *listener.ListenerFilterChainMatchPredicate_NotMatch:
+		matches, err := evaluateListenerFilterPredicates(r.NotMatch, port)
+		return !matches, err
+	case *listener.ListenerFilterChainMatchPredicate_OrMatch:
+		for _, r := range r.OrMatch.Rules {
+			if matches, err := evaluateListenerFilterPredicates(r, port); err != nil || matches {
+				return matches, err
+			}
+		}
+		return false, nil
+	case *listener.ListenerFilterChainMatchPredicate_DestinationPortRange:
+		return int32(port) >= r.DestinationPortRange.GetStart() && int32(port) < r.DestinationPortRange.GetEnd(), nil
+	default:
+		return false, fmt.Errorf("unsupported listener filter predicate %T", r)
 	}
-	return !xdstest.EvaluateListenerFilterPredicates(got.FilterDisabled, port)
 }
 
 func (sim *Simulation) Run(input Call) (result Result) {
@@ -286,6 +313,10 @@ func (sim *Simulation) Run(input Call) (result Result) {
 		result.Error = fmt.Errorf("invalid call, ALPN can only be sent in TLS requests")
 		return result
 	}
+	if _, err := netip.ParseAddr(input.Address); err != nil {
+		result.Error = fmt.Errorf("invalid call, address: %v", err)
+		return result
+	}
 
 	// First we will match a listener
 	l := matchListener(sim.Listeners, input)
@@ -295,7 +326,11 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 	result.ListenerMatched = l.Name
 
-	hasTLSInspector := hasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
+	hasTLSInspector, err := hasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
+	if err != nil {
+		result.Error = err
+		return
+	}
 	if !hasTLSInspector {
 		// Without tls inspector, Envoy would not read the ALPN in the TLS handshake
 		// HTTP inspector still may set it though
@@ -303,7 +338,12 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 
 	// Apply listener filters
-	if hasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port) {
+	hasHTTPInspector, err := hasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port)
+	if err != nil {
+		result.Error = err
+		return
+	}
+	if hasHTTPInspector {
 		if alpn := protocolToAlpn(input.Protocol); alpn != "" && input.TLS == Plaintext {
 			input.Alpn = alpn
 		}
@@ -327,7 +367,12 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 
 	// mTLS listener will only accept mTLS traffic
-	if fc.TransportSocket != nil && sim.requiresMTLS(fc, mTLSSecretConfigName) != (input.TLS == MTLS) {
+	mtls, err := requiresMTLS(fc, mTLSSecretConfigName)
+	if err != nil {
+		result.Error = err
+		return
+	}
+	if fc.TransportSocket != nil && mtls != (input.TLS == MTLS) {
 		// If there is no tls inspector, then
 		result.Error = ErrMTLSError
 		return
@@ -341,7 +386,12 @@ func (sim *Simulation) Run(input Call) (result Result) {
 		}
 	}
 
-	if hcm := xdstest.ExtractHTTPConnectionManager(sim.t, fc); hcm != nil {
+	hcm, tcp, err := extractNetworkFilter(fc)
+	if err != nil {
+		result.Error = err
+		return
+	}
+	if hcm != nil {
 		// We matched HCM and didn't terminate TLS, but we are sending TLS traffic - decoding will fail
 		if input.TLS != Plaintext && fc.TransportSocket == nil {
 			result.Error = ErrProtocolError
@@ -359,7 +409,12 @@ func (sim *Simulation) Run(input Call) (result Result) {
 			// If not set, fallback to RDS
 			routeName := hcm.GetRds().RouteConfigName
 			result.RouteConfigMatched = routeName
-			rc = xdstest.ExtractRouteConfigurations(sim.Routes)[routeName]
+			for _, r := range sim.Routes {
+				if r.Name == routeName {
+					rc = r
+					break
+				}
+			}
 		}
 		hostHeader := ""
 		if len(input.Headers["Host"]) > 0 {
@@ -376,7 +431,11 @@ func (sim *Simulation) Run(input Call) (result Result) {
 			return
 		}
 
-		r := sim.matchRoute(vh, input)
+		r, err := matchRoute(vh, input, sim.MatchHeaders)
+		if err != nil {
+			result.Error = err
+			return
+		}
 		if r == nil {
 			result.Error = ErrNoRoute
 			return
@@ -386,35 +445,60 @@ func (sim *Simulation) Run(input Call) (result Result) {
 		case *route.Route_Route:
 			result.ClusterMatched = t.Route.GetCluster()
 		}
-	} else if tcp := xdstest.ExtractTCPProxy(sim.t, fc); tcp != nil {
+	} else if tcp != nil {
 		result.ClusterMatched = tcp.GetCluster()
 	}
 	return
 }
 
-func (sim *Simulation) requiresMTLS(fc *listener.FilterChain, mTLSSecretConfigName string) bool {
+// extractNetworkFilter returns the HTTP connection manager or TCP proxy of the filter chain, if any.
+func extractNetworkFilter(fc *listener.FilterChain) (*hcm.HttpConnectionManager, *tcpproxy.TcpProxy, error) {
+	for _, f := range fc.Filters {
+		switch f.Name {
+		case wellknown.HTTPConnectionManager:
+			h := &hcm.HttpConnectionManager{}
+			if f.GetTypedConfig() != nil {
+				if err := f.GetTypedConfig().UnmarshalTo(h); err != nil {
+					return nil, nil, fmt.Errorf("failed to unmarshal hcm: %v", err)
+				}
+			}
+			return h, nil, nil
+		case wellknown.TCPProxy:
+			tcp := &tcpproxy.TcpProxy{}
+			if f.GetTypedConfig() != nil {
+				if err := f.GetTypedConfig().UnmarshalTo(tcp); err != nil {
+					return nil, nil, fmt.Errorf("failed to unmarshal tcp proxy: %v", err)
+				}
+			}
+			return nil, tcp, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func requiresMTLS(fc *listener.FilterChain, mTLSSecretConfigName string) (bool, error) {
 	if fc.TransportSocket == nil {
-		return false
+		return false, nil
 	}
 	t := &tls.DownstreamTlsContext{}
 	if err := fc.GetTransportSocket().GetTypedConfig().UnmarshalTo(t); err != nil {
-		sim.t.Fatal(err)
+		return false, err
 	}
 
 	if len(t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()) == 0 {
-		return false
+		return false, nil
 	}
 	// This is a lazy heuristic, we could check for explicit default resource or spiffe if it becomes necessary
 	if t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()[0].Name != mTLSSecretConfigName {
-		return false
+		return false, nil
 	}
 	if !t.RequireClientCertificate.Value {
-		return false
+		return false, nil
 	}
-	return true
+	return true, nil
 }
 
-func (sim *Simulation) matchRoute(vh *route.VirtualHost, input Call) *route.Route {
+func matchRoute(vh *route.VirtualHost, input Call, headers bool) (*route.Route, error) {
 	for _, r := range vh.Routes {
 		// check path
 		switch pt := r.Match.GetPathSpecifier().(type) {
@@ -433,20 +517,91 @@ func (sim *Simulation) matchRoute(vh *route.VirtualHost, input Call) *route.Rout
 		case *route.RouteMatch_SafeRegex:
 			r, err := regexp.Compile(pt.SafeRegex.GetRegex())
 			if err != nil {
-				sim.t.Fatalf("invalid regex %v: %v", pt.SafeRegex.GetRegex(), err)
+				return nil, fmt.Errorf("invalid regex %v: %v", pt.SafeRegex.GetRegex(), err)
 			}
 			if !r.MatchString(input.Path) {
 				continue
 			}
 		default:
-			sim.t.Fatalf("unknown route path type %T", pt)
+			return nil, fmt.Errorf("unknown route path type %T", pt)
 		}
 
-		// TODO this only handles path - we need to add headers, query params, etc to be complete.
+		if headers {
+			matched, err := matchHeaders(r.Match.GetHeaders(), input)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		// TODO this only handles path and, with MatchHeaders, headers - we need to add query params, etc to be complete.
 
-		return r
+		return r, nil
+	}
+	return nil, nil
+}
+
+func matchHeaders(matchers []*route.HeaderMatcher, input Call) (bool, error) {
+	for _, hm := range matchers {
+		name := hm.GetName()
+		if name == ":authority" {
+			name = "Host"
+		}
+		values := input.Headers.Values(name)
+		var matched bool
+		switch hs := hm.GetHeaderMatchSpecifier().(type) {
+		case *route.HeaderMatcher_PresentMatch:
+			matched = (len(values) > 0) == hs.PresentMatch
+		case *route.HeaderMatcher_StringMatch:
+			if len(values) > 0 {
+				var err error
+				if matched, err = matchString(hs.StringMatch, values[0]); err != nil {
+					return false, err
+				}
+			}
+		default:
+			return false, fmt.Errorf("unknown header match type %T", hs)
+		}
+		if hm.GetInvertMatch() {
+			matched = !matched
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func matchString(m *matcher.StringMatcher, value string) (bool, error) {
+	if m.GetIgnoreCase() {
+		value = strings.ToLower(value)
+	}
+	lower := func(s string) string {
+		if m.GetIgnoreCase() {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	switch ms := m.GetMatchPattern().(type) {
+	case *matcher.StringMatcher_Exact:
+		return value == lower(ms.Exact), nil
+	case *matcher.StringMatcher_Prefix:
+		return strings.HasPrefix(value, lower(ms.Prefix)), nil
+	case *matcher.StringMatcher_Suffix:
+		return strings.HasSuffix(value, lower(ms.Suffix)), nil
+	case *matcher.StringMatcher_Contains:
+		return strings.Contains(value, lower(ms.Contains)), nil
+	case *matcher.StringMatcher_SafeRegex:
+		r, err := regexp.Compile(ms.SafeRegex.GetRegex())
+		if err != nil {
+			return false, fmt.Errorf("invalid regex %v: %v", ms.SafeRegex.GetRegex(), err)
+		}
+		return r.MatchString(value), nil
+	default:
+		return false, fmt.Errorf("unknown string match type %T", ms)
 	}
-	return nil
 }
 
 func (sim *Simulation) matchVirtualHost(rc *route.RouteConfiguration, host string) *route.VirtualHost {
@@ -516,6 +671,7 @@ func (sim *Simulation) matchVirtualHost(rc *route.RouteConfiguration, host strin
 func (sim *Simulation) matchFilterChain(chains []*listener.FilterChain, defaultChain *listener.FilterChain,
 	input Call, hasTLSInspector bool,
 ) (*listener.FilterChain, error) {
+	var cidrErr error
 	chains = filter("DestinationPort", chains, (*listener.FilterChainMatch).GetDestinationPort, func(port *wrapperspb.UInt32Value) bool {
 		return int(port.GetValue()) == input.Port
 	})
@@ -525,7 +681,8 @@ func (sim *Simulation) matchFilterChain(chains []*listener.FilterChain, defaultC
 			s := fmt.Sprintf("%s/%d", a.AddressPrefix, a.GetPrefixLen().GetValue())
 			cidr, err := netip.ParsePrefix(s)
 			if err != nil {
-				sim.t.Fatalf("failed to parse cidr %v: %v", s, err)
+				cidrErr = fmt.Errorf("failed to parse cidr %v: %v", s, err)
+				continue
 			}
 			if cidr.Contains(netip.MustParseAddr(input.Address)) {
 				// Rank by how exact of a match it is. A /32 should match before a /8 even if they both match.
@@ -565,6 +722,9 @@ func (sim *Simulation) matchFilterChain(chains []*listener.FilterChain, defaultC
 		return sets.New(appProtocols...).Contains(input.Alpn)
 	})
 	// We do not implement the "source" based filters as we do not use them
+	if cidrErr != nil {
+		return nil, cidrErr
+	}
 
 	if len(chains) > 1 {
 		for _, c := range chains {
@@ -674,7 +834,12 @@ func protocolToAlpn(s Protocol) string {
 
 func matchListener(listeners []*listener.Listener, input Call) *listener.Listener {
 	if input.CallMode == CallModeInbound {
-		return xdstest.ExtractListener(model.VirtualInboundListenerName, listeners)
+		for _, l := range listeners {
+			if l.Name == model.VirtualInboundListenerName {
+				return l
+			}
+		}
+		return nil
 	}
 	// First find exact match for the IP/Port, then fallback to wildcard IP/Port
 	// There is no wildcard port
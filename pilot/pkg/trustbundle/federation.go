@@ -0,0 +1,342 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustbundle
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// FederationDefaultRefreshInterval is the interval at which the bundles of the federated trust domains are polled,
+	// unless the bundles carry a refresh hint.
+	FederationDefaultRefreshInterval = 5 * time.Minute
+	// federationMinRefreshInterval bounds the refresh hints of the bundles.
+	federationMinRefreshInterval = 30 * time.Second
+	// federationRetryInterval is the maximum interval before polling a bundle endpoint again after a failure.
+	federationRetryInterval = time.Minute
+)
+
+// FederationConfig is the configuration of the SPIFFE federation of the mesh with other trust domains.
+type FederationConfig struct {
+	TrustDomains []FederatedTrustDomain `json:"trustDomains,omitempty"`
+}
+
+// FederatedTrustDomain is a trust domain federated with the mesh, whose bundle is polled from its SPIFFE bundle
+// endpoint.
+type FederatedTrustDomain struct {
+	TrustDomain       string `json:"trustDomain"`
+	BundleEndpointURL string `json:"bundleEndpointURL"`
+	// BundleEndpointProfile is either https_web or https_spiffe.
+	BundleEndpointProfile string `json:"bundleEndpointProfile"`
+	// EndpointSPIFFEID is the SPIFFE ID of an https_spiffe bundle endpoint.
+	EndpointSPIFFEID string `json:"endpointSPIFFEID,omitempty"`
+	// EndpointBundle is the PEM encoded bundle of the trust domain of EndpointSPIFFEID, authenticating an
+	// https_spiffe bundle endpoint until a bundle of that trust domain is fetched.
+	EndpointBundle string `json:"endpointBundle,omitempty"`
+	// RefreshInterval overrides the default polling interval, for bundles without a refresh hint.
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// ParseFederationConfig parses and validates a federation configuration in YAML.
+func ParseFederationConfig(config string) (*FederationConfig, error) {
+	cfg := &FederationConfig{}
+	if err := yaml.UnmarshalStrict([]byte(config), cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate validates the federation configuration.
+func (c *FederationConfig) Validate() error {
+	seen := sets.New[string]()
+	for n, td := range c.TrustDomains {
+		if td.TrustDomain == "" || strings.ContainsAny(td.TrustDomain, "/:") {
+			return fmt.Errorf("trust domain %d: invalid trust domain %q", n, td.TrustDomain)
+		}
+		if seen.InsertContains(td.TrustDomain) {
+			return fmt.Errorf("trust domain %d: duplicate trust domain %q", n, td.TrustDomain)
+		}
+		if u, err := url.Parse(td.BundleEndpointURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("trust domain %s: invalid bundle endpoint URL %q, must be an https URL", td.TrustDomain, td.BundleEndpointURL)
+		}
+		switch td.BundleEndpointProfile {
+		case spiffe.BundleEndpointProfileWeb:
+			if td.EndpointSPIFFEID != "" || td.EndpointBundle != "" {
+				return fmt.Errorf("trust domain %s: endpointSPIFFEID and endpointBundle require the %s profile",
+					td.TrustDomain, spiffe.BundleEndpointProfileSPIFFE)
+			}
+		case spiffe.BundleEndpointProfileSPIFFE:
+			if _, err := td.endpointTrustDomain(); err != nil {
+				return fmt.Errorf("trust domain %s: invalid endpointSPIFFEID: %v", td.TrustDomain, err)
+			}
+			if td.EndpointBundle != "" {
+				if _, err := parseCertificates(td.EndpointBundle); err != nil {
+					return fmt.Errorf("trust domain %s: invalid endpointBundle: %v", td.TrustDomain, err)
+				}
+			}
+		default:
+			return fmt.Errorf("trust domain %s: invalid bundle endpoint profile %q, must be %s or %s", td.TrustDomain,
+				td.BundleEndpointProfile, spiffe.BundleEndpointProfileWeb, spiffe.BundleEndpointProfileSPIFFE)
+		}
+		if td.RefreshInterval != nil && td.RefreshInterval.Duration < federationMinRefreshInterval {
+			return fmt.Errorf("trust domain %s: refreshInterval must be at least %v", td.TrustDomain, federationMinRefreshInterval)
+		}
+	}
+	return nil
+}
+
+// endpointTrustDomain returns the trust domain of the SPIFFE ID of an https_spiffe bundle endpoint.
+func (td *FederatedTrustDomain) endpointTrustDomain() (string, error) {
+	u, err := url.Parse(td.EndpointSPIFFEID)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != spiffe.Scheme || u.Host == "" {
+		return "", fmt.Errorf("%q is not a SPIFFE ID", td.EndpointSPIFFEID)
+	}
+	return u.Host, nil
+}
+
+func (td *FederatedTrustDomain) refreshInterval(bundle *spiffe.Bundle) time.Duration {
+	if bundle != nil && bundle.RefreshHint > 0 {
+		return max(bundle.RefreshHint, federationMinRefreshInterval)
+	}
+	if td.RefreshInterval != nil {
+		return td.RefreshInterval.Duration
+	}
+	return FederationDefaultRefreshInterval
+}
+
+// Federation polls the bundles of the federated trust domains from their SPIFFE bundle endpoints, and adds their
+// X.509 authorities to the trust bundle, bound to their trust domain. The last bundle of a trust domain is kept while its endpoint is unavailable.
+type Federation struct {
+	trustBundle *TrustBundle
+	now         func() time.Time
+	fetch       func(ctx context.Context, trustDomain string, endpoint spiffe.BundleEndpoint, roots *x509.CertPool) (*spiffe.Bundle, error)
+	updateChan  chan struct{}
+
+	mu          sync.Mutex
+	config      *FederationConfig
+	bundles     map[string]*spiffe.Bundle
+	nextRefresh map[string]time.Time
+}
+
+// NewFederation returns a federation adding the federated bundles to the trust bundle.
+func NewFederation(tb *TrustBundle) *Federation {
+	return &Federation{
+		trustBundle: tb,
+		now:         time.Now,
+		fetch:       spiffe.FetchBundle,
+		updateChan:  make(chan struct{}, 1),
+		config:      &FederationConfig{},
+		bundles:     map[string]*spiffe.Bundle{},
+		nextRefresh: map[string]time.Time{},
+	}
+}
+
+// SetConfig updates the federated trust domains. The bundles of the trust domains no longer federated are removed
+// from the trust bundle, and the others are polled immediately.
+func (f *Federation) SetConfig(cfg *FederationConfig) {
+	if cfg == nil {
+		cfg = &FederationConfig{}
+	}
+	f.mu.Lock()
+	f.config = cfg
+	federated := sets.New(slices.Map(cfg.TrustDomains, func(td FederatedTrustDomain) string { return td.TrustDomain })...)
+	for td := range f.bundles {
+		if !federated.Contains(td) {
+			delete(f.bundles, td)
+		}
+	}
+	clear(f.nextRefresh)
+	f.mu.Unlock()
+	f.updateTrustBundle()
+	select {
+	case f.updateChan <- struct{}{}:
+	default:
+	}
+}
+
+// Bundles returns the last fetched bundle of each federated trust domain.
+func (f *Federation) Bundles() map[string]*spiffe.Bundle {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return maps.Clone(f.bundles)
+}
+
+// Run polls the bundle endpoints until the stop channel is closed.
+func (f *Federation) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			trustBundleLog.Infof("stop polling the SPIFFE bundle endpoints")
+			return
+		case <-f.updateChan:
+		case <-timer.C:
+		}
+		wait := f.refresh(ctx)
+		timer.Stop()
+		timer.Reset(wait)
+	}
+}
+
+// refresh polls the bundle endpoints due for a refresh, and returns the time until the next refresh.
+func (f *Federation) refresh(ctx context.Context) time.Duration {
+	f.mu.Lock()
+	config := f.config
+	f.mu.Unlock()
+
+	updated := false
+	for _, td := range config.TrustDomains {
+		f.mu.Lock()
+		due := !f.now().Before(f.nextRefresh[td.TrustDomain])
+		current := f.bundles[td.TrustDomain]
+		var roots *x509.CertPool
+		var err error
+		if due {
+			roots, err = f.endpointRoots(td)
+		}
+		f.mu.Unlock()
+		if !due {
+			continue
+		}
+		var fetched *spiffe.Bundle
+		if err == nil {
+			fetched, err = f.fetch(ctx, td.TrustDomain, spiffe.BundleEndpoint{
+				URL:      td.BundleEndpointURL,
+				Profile:  td.BundleEndpointProfile,
+				SPIFFEID: td.EndpointSPIFFEID,
+			}, roots)
+		}
+		if err == nil && current != nil && fetched.Sequence > 0 && fetched.Sequence < current.Sequence {
+			err = fmt.Errorf("bundle sequence %d is older than the current sequence %d", fetched.Sequence, current.Sequence)
+		}
+		f.mu.Lock()
+		if err != nil {
+			trustBundleLog.Warnf("failed to refresh the bundle of federated trust domain %s: %v", td.TrustDomain, err)
+			f.nextRefresh[td.TrustDomain] = f.now().Add(min(td.refreshInterval(current), federationRetryInterval))
+		} else {
+			if current == nil || !slices.Equal(fetched.PEM(), current.PEM()) {
+				trustBundleLog.Infof("updated the bundle of federated trust domain %s, with %d X.509 authorities",
+					td.TrustDomain, len(fetched.X509Authorities))
+				updated = true
+			}
+			f.bundles[td.TrustDomain] = fetched
+			f.nextRefresh[td.TrustDomain] = f.now().Add(td.refreshInterval(fetched))
+		}
+		f.mu.Unlock()
+	}
+	if updated {
+		f.updateTrustBundle()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	wait := FederationDefaultRefreshInterval
+	for _, next := range f.nextRefresh {
+		wait = min(wait, next.Sub(f.now()))
+	}
+	return max(wait, 0)
+}
+
+// endpointRoots returns the roots authenticating the bundle endpoint of a trust domain: the system roots for
+// https_web, and the bundle of the trust domain of the endpoint for https_spiffe, falling back to the configured
+// endpoint bundle. Must be called with the lock held.
+func (f *Federation) endpointRoots(td FederatedTrustDomain) (*x509.CertPool, error) {
+	if td.BundleEndpointProfile != spiffe.BundleEndpointProfileSPIFFE {
+		return f.trustBundle.remoteCaCertPool, nil
+	}
+	endpointTrustDomain, err := td.endpointTrustDomain()
+	if err != nil {
+		return nil, err
+	}
+	if bundle := f.bundles[endpointTrustDomain]; bundle != nil {
+		return bundle.CertPool(), nil
+	}
+	if td.EndpointBundle == "" {
+		return nil, fmt.Errorf("no bundle of trust domain %s to authenticate the bundle endpoint", endpointTrustDomain)
+	}
+	certs, err := parseCertificates(td.EndpointBundle)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// parseCertificates parses PEM encoded certificates.
+func parseCertificates(certs string) ([]*x509.Certificate, error) {
+	var res []*x509.Certificate
+	rest := []byte(certs)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse X.509 certificate: %v", err)
+		}
+		res = append(res, cert)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("failed to decode pem certificates")
+	}
+	return res, nil
+}
+
+// updateTrustBundle sets the X.509 authorities of the federated trust domains in the trust bundle, each bound to its
+// trust domain.
+func (f *Federation) updateTrustBundle() {
+	f.mu.Lock()
+	certs := make(map[string][]string, len(f.bundles))
+	for td, bundle := range f.bundles {
+		certs[td] = bundle.PEM()
+	}
+	f.mu.Unlock()
+	if err := f.trustBundle.UpdateFederatedTrustAnchors(certs); err != nil {
+		trustBundleLog.Errorf("failed to update the trust anchors of the federated trust domains: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustbundle
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/config/mesh/meshwatcher"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+const federationConfig = `
+trustDomains:
+- trustDomain: partner.example.org
+  bundleEndpointURL: https://spire.partner.example.org/bundle
+  bundleEndpointProfile: https_web
+- trustDomain: spire.example.org
+  bundleEndpointURL: https://spire.example.org:8443
+  bundleEndpointProfile: https_spiffe
+  endpointSPIFFEID: spiffe://spire.example.org/spire/server
+  refreshInterval: 1m
+`
+
+func TestParseFederationConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "empty", config: ""},
+		{name: "valid", config: federationConfig},
+		{name: "unknown field", config: "trustDomains:\n- domain: a", wantErr: `unknown field "domain"`},
+		{
+			name:    "invalid trust domain",
+			config:  "trustDomains:\n- trustDomain: spiffe://a\n  bundleEndpointURL: https://a\n  bundleEndpointProfile: https_web",
+			wantErr: "invalid trust domain",
+		},
+		{
+			name: "duplicate trust domain",
+			config: "trustDomains:\n- trustDomain: a\n  bundleEndpointURL: https://a\n  bundleEndpointProfile: https_web\n" +
+				"- trustDomain: a\n  bundleEndpointURL: https://b\n  bundleEndpointProfile: https_web",
+			wantErr: "duplicate trust domain",
+		},
+		{
+			name:    "http endpoint",
+			config:  "trustDomains:\n- trustDomain: a\n  bundleEndpointURL: http://a\n  bundleEndpointProfile: https_web",
+			wantErr: "must be an https URL",
+		},
+		{
+			name:    "unknown profile",
+			config:  "trustDomains:\n- trustDomain: a\n  bundleEndpointURL: https://a\n  bundleEndpointProfile: https",
+			wantErr: "invalid bundle endpoint profile",
+		},
+		{
+			name:    "https_spiffe without ID",
+			config:  "trustDomains:\n- trustDomain: a\n  bundleEndpointURL: https://a\n  bundleEndpointProfile: https_spiffe",
+			wantErr: "invalid endpointSPIFFEID",
+		},
+		{
+			name: "https_web with ID",
+			config: "trustDomains:\n- trustDomain: a\n  bundleEndpointURL: https://a\n  bundleEndpointProfile: https_web\n" +
+				"  endpointSPIFFEID: spiffe://a/server",
+			wantErr: "require the https_spiffe profile",
+		},
+		{
+			name: "invalid endpoint bundle",
+			config: "trustDomains:\n- trustDomain: a\n  bundleEndpointURL: https://a\n  bundleEndpointProfile: https_spiffe\n" +
+				"  endpointSPIFFEID: spiffe://a/server\n  endpointBundle: invalid",
+			wantErr: "invalid endpointBundle",
+		},
+		{
+			name: "short refresh interval",
+			config: "trustDomains:\n- trustDomain: a\n  bundleEndpointURL: https://a\n  bundleEndpointProfile: https_web\n" +
+				"  refreshInterval: 1s",
+			wantErr: "refreshInterval must be at least",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFederationConfig(tt.config)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func parseBundle(t *testing.T, trustDomain string, sequence uint64, refreshHint time.Duration, certs ...string) *spiffe.Bundle {
+	t.Helper()
+	bundle := &spiffe.Bundle{TrustDomain: trustDomain, Sequence: sequence, RefreshHint: refreshHint}
+	for _, cert := range certs {
+		parsed, err := parseCertificates(cert)
+		assert.NoError(t, err)
+		bundle.X509Authorities = append(bundle.X509Authorities, parsed...)
+	}
+	return bundle
+}
+
+func TestFederation(t *testing.T) {
+	now := time.Now()
+	tb := NewTrustBundle(nil, nil)
+	federation := NewFederation(tb)
+	federation.now = func() time.Time { return now }
+
+	type fetched struct {
+		bundle *spiffe.Bundle
+		err    error
+	}
+	responses := map[string]fetched{}
+	var calls []string
+	var spireRoots *x509.CertPool
+	federation.fetch = func(_ context.Context, trustDomain string, endpoint spiffe.BundleEndpoint, roots *x509.CertPool) (*spiffe.Bundle, error) {
+		calls = append(calls, trustDomain)
+		if endpoint.Profile == spiffe.BundleEndpointProfileSPIFFE {
+			spireRoots = roots
+		}
+		return responses[trustDomain].bundle, responses[trustDomain].err
+	}
+
+	cfg, err := ParseFederationConfig(federationConfig)
+	assert.NoError(t, err)
+	federation.SetConfig(cfg)
+
+	// The https_spiffe endpoint cannot be authenticated before the bundle of its trust domain is known.
+	responses["partner.example.org"] = fetched{bundle: parseBundle(t, "partner.example.org", 1, 0, rootCACert)}
+	assert.Equal(t, federation.refresh(context.Background()), federationRetryInterval)
+	assert.Equal(t, calls, []string{"partner.example.org"})
+	assert.Equal(t, federatedCerts(tb), map[string][]string{"partner.example.org": {rootCACert}})
+	// The federated bundles are not merged in the trust bundle of the mesh.
+	assert.Equal(t, tb.GetTrustBundle(), []string{})
+
+	// The endpoint bundle authenticates the endpoint until the bundle of the trust domain is fetched.
+	cfg.TrustDomains[1].EndpointBundle = intermediateCACert
+	federation.SetConfig(cfg)
+	calls = nil
+	responses["spire.example.org"] = fetched{bundle: parseBundle(t, "spire.example.org", 5, 0, intermediateCACert)}
+	assert.Equal(t, federation.refresh(context.Background()), time.Minute)
+	assert.Equal(t, calls, []string{"partner.example.org", "spire.example.org"})
+	assert.Equal(t, spireRoots != nil, true)
+	assert.Equal(t, len(federatedCerts(tb)), 2)
+	assert.Equal(t, len(federation.Bundles()), 2)
+
+	// Nothing is due until the refresh interval of spire.example.org.
+	calls = nil
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, federation.refresh(context.Background()), 30*time.Second)
+	assert.Equal(t, len(calls), 0)
+
+	// The last bundle is kept when the endpoint fails, or serves an older bundle.
+	now = now.Add(30 * time.Second)
+	responses["spire.example.org"] = fetched{err: fmt.Errorf("unavailable")}
+	federation.refresh(context.Background())
+	assert.Equal(t, federation.Bundles()["spire.example.org"].Sequence, uint64(5))
+	now = now.Add(time.Minute)
+	responses["spire.example.org"] = fetched{bundle: parseBundle(t, "spire.example.org", 4, 0, rootCACert)}
+	federation.refresh(context.Background())
+	assert.Equal(t, federation.Bundles()["spire.example.org"].Sequence, uint64(5))
+
+	// The refresh hint of a bundle overrides the refresh interval.
+	now = now.Add(time.Minute)
+	responses["spire.example.org"] = fetched{bundle: parseBundle(t, "spire.example.org", 6, 10*time.Minute, intermediateCACert)}
+	federation.refresh(context.Background())
+	assert.Equal(t, federation.nextRefresh["spire.example.org"], now.Add(10*time.Minute))
+
+	// The bundles of the trust domains no longer federated are removed from the trust bundle.
+	cfg.TrustDomains = cfg.TrustDomains[:1]
+	federation.SetConfig(cfg)
+	assert.Equal(t, federatedCerts(tb), map[string][]string{"partner.example.org": {rootCACert}})
+	federation.SetConfig(nil)
+	assert.Equal(t, len(tb.GetFederatedTrustBundle()), 0)
+}
+
+func TestFederatedTrustBundleBoundToTrustDomain(t *testing.T) {
+	tb := NewTrustBundle(nil, meshwatcher.NewTestWatcher(&meshconfig.MeshConfig{
+		TrustDomain:        "cluster.local",
+		TrustDomainAliases: []string{"old.local"},
+	}))
+	assert.NoError(t, tb.UpdateTrustAnchor(&TrustAnchorUpdate{
+		TrustAnchorConfig: TrustAnchorConfig{Certs: []string{rootCACert}},
+		Source:            SourceIstioCA,
+	}))
+	federation := NewFederation(tb)
+	federation.bundles = map[string]*spiffe.Bundle{
+		"partner.example.org": parseBundle(t, "partner.example.org", 0, 0, intermediateCACert),
+		// A federated bundle of a trust domain of the mesh would validate the identities of the mesh.
+		"cluster.local": parseBundle(t, "cluster.local", 0, 0, intermediateCACert),
+	}
+	federation.updateTrustBundle()
+
+	assert.Equal(t, tb.GetTrustBundle(), []string{rootCACert})
+	// The federated CA only validates the identities of its trust domain, and the aliases of the mesh are still
+	// validated by the CA of the mesh.
+	assert.Equal(t, federatedCerts(tb), map[string][]string{
+		"old.local":           {rootCACert},
+		"partner.example.org": {intermediateCACert},
+	})
+}
+
+func federatedCerts(tb *TrustBundle) map[string][]string {
+	unbound, certs := spiffe.SplitTrustDomainCerts(tb.GetFederatedTrustBundle())
+	if len(unbound) != 0 {
+		panic(fmt.Sprintf("federated certificates not bound to a trust domain: %v", unbound))
+	}
+	return certs
+}
@@ -26,6 +26,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/util/sets"
@@ -68,9 +69,12 @@ type TrustAnchorUpdate struct {
 }
 
 type TrustBundle struct {
-	sourceConfig       map[Source]TrustAnchorConfig
-	mutex              sync.RWMutex
-	mergedCerts        []string
+	sourceConfig map[Source]TrustAnchorConfig
+	mutex        sync.RWMutex
+	mergedCerts  []string
+	// federatedCerts are the trust anchors of the trust domains federated with the mesh, keyed by trust domain.
+	// They are not merged, as they only validate the identities of their own trust domain.
+	federatedCerts     map[string][]string
 	updatecb           func()
 	endpointMutex      sync.RWMutex
 	endpoints          []string
@@ -124,6 +128,66 @@ func (tb *TrustBundle) GetTrustBundle() []string {
 	return trustedCerts
 }
 
+// GetFederatedTrustBundle returns the trust anchors of the federated trust domains, bound to their trust domain with
+// spiffe.EncodeTrustDomainCerts. The trust anchors of the mesh are also bound to each of its trust domain aliases, so
+// that these identities are still validated once peers are validated against the bundle of their trust domain.
+// Federated bundles of the trust domain of the mesh or of its aliases are ignored.
+func (tb *TrustBundle) GetFederatedTrustBundle() []string {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	if len(tb.federatedCerts) == 0 {
+		return nil
+	}
+	var trustDomain string
+	var aliases []string
+	if tb.meshConfig != nil {
+		trustDomain = tb.meshConfig.Mesh().GetTrustDomain()
+		aliases = tb.meshConfig.Mesh().GetTrustDomainAliases()
+	}
+	var res []string
+	for _, alias := range aliases {
+		if alias != trustDomain {
+			res = append(res, spiffe.EncodeTrustDomainCerts(alias, tb.mergedCerts)...)
+		}
+	}
+	for _, td := range slices.Sort(maps.Keys(tb.federatedCerts)) {
+		if td == trustDomain || slices.Contains(aliases, td) {
+			trustBundleLog.Warnf("ignoring the federated bundle of trust domain %s, which is a trust domain of the mesh", td)
+			continue
+		}
+		res = append(res, spiffe.EncodeTrustDomainCerts(td, tb.federatedCerts[td])...)
+	}
+	return res
+}
+
+// UpdateFederatedTrustAnchors sets the trust anchors of the trust domains federated with the mesh, keyed by trust
+// domain.
+func (tb *TrustBundle) UpdateFederatedTrustAnchors(certs map[string][]string) error {
+	tb.mutex.RLock()
+	unchanged := maps.EqualFunc(certs, tb.federatedCerts, slices.Equal[string])
+	tb.mutex.RUnlock()
+	if unchanged {
+		trustBundleLog.Debugf("no change to the federated trustAnchors after recent update")
+		return nil
+	}
+	for _, tdCerts := range certs {
+		for _, cert := range tdCerts {
+			if err := verifyTrustAnchor(cert); err != nil {
+				return err
+			}
+		}
+	}
+	tb.mutex.Lock()
+	tb.federatedCerts = certs
+	tb.mutex.Unlock()
+	trustBundleLog.Infof("updating the federated trustAnchors of trust domains %v", slices.Sort(maps.Keys(certs)))
+
+	if tb.updatecb != nil {
+		tb.updatecb()
+	}
+	return nil
+}
+
 func verifyTrustAnchor(trustAnchor string) error {
 	block, _ := pem.Decode([]byte(trustAnchor))
 	if block == nil {
@@ -84,6 +84,18 @@ type Connection struct {
 
 	s   *DiscoveryServer
 	ids []string
+
+	// needsPush holds the *needsPushResult of ProxyNeedsPush computed when prioritizing a push, to be reused when it
+	// is sent.
+	needsPush atomic.Value
+}
+
+// needsPushResult is the result of ProxyNeedsPush for a push request, and the sidecar scope it was computed against.
+type needsPushResult struct {
+	request   *model.PushRequest
+	scope     *model.SidecarScope
+	filtered  *model.PushRequest
+	needsPush bool
 }
 
 func (conn *Connection) XdsConnection() *xds.Connection {
@@ -476,7 +488,7 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 		s.computeProxyState(con.proxy, pushRequest)
 	}
 
-	pushRequest, needsPush := s.ProxyNeedsPush(con.proxy, pushRequest)
+	pushRequest, needsPush := s.proxyNeedsPush(con, pushRequest)
 	if !needsPush {
 		log.Debugf("Skipping push to %v, no updates required", con.ID())
 		return nil
@@ -587,9 +599,62 @@ func (s *DiscoveryServer) StartPush(req *model.PushRequest) {
 		}
 	}
 	req.Start = time.Now()
-	for _, p := range s.AllClients() {
-		s.pushQueue.Enqueue(p, req)
+	clients := s.AllClients()
+	for _, p := range clients {
+		s.pushQueue.EnqueueWithPriority(p, req, initialPushPriority(p))
+	}
+	if features.EnablePushPriority && !req.Forced {
+		// Determining which proxies are affected by the push is O(proxies), so it is done after they are all queued,
+		// rather than holding up the push. Pushes sent in the meantime are sent in their initial priority order.
+		// Forced pushes affect every proxy alike, and keep their initial priority.
+		go s.prioritizePush(clients, req)
+	}
+}
+
+// initialPushPriority is the priority of pushing to a connection before the push is prioritized. Without
+// PILOT_ENABLE_PUSH_PRIORITY, all pushes have the same priority.
+func initialPushPriority(con *Connection) PushPriority {
+	if features.EnablePushPriority && con.proxy.Type == model.Router {
+		return PushPriorityHigh
+	}
+	return PushPriorityNormal
+}
+
+// prioritizePush updates the priority of the push of req to each connection still waiting for it.
+func (s *DiscoveryServer) prioritizePush(clients []*Connection, req *model.PushRequest) {
+	for _, con := range clients {
+		s.pushQueue.Reprioritize(con, req, s.pushPriority(con, req))
+	}
+}
+
+// pushPriority determines the priority of pushing req to a connection. The result of ProxyNeedsPush is kept on
+// the connection, so that it is not computed again when the push is sent.
+func (s *DiscoveryServer) pushPriority(con *Connection, req *model.PushRequest) PushPriority {
+	proxy := con.proxy
+	// The proxy state may be recomputed concurrently by a push in progress.
+	proxy.RLock()
+	defer proxy.RUnlock()
+	if proxy.SidecarScope == nil {
+		// The connection is not yet initialized; it will get a full push once it is.
+		return PushPriorityNormal
+	}
+	filtered, needsPush := s.ProxyNeedsPush(proxy, req)
+	con.needsPush.Store(&needsPushResult{request: req, scope: proxy.SidecarScope, filtered: filtered, needsPush: needsPush})
+	if !needsPush {
+		return PushPriorityLow
+	}
+	return PushPriorityHigh
+}
+
+// proxyNeedsPush returns the result of ProxyNeedsPush for pushing req to a connection. The result computed when the
+// push was prioritized is reused, unless the push recomputed the sidecar scope, which changes whenever a push
+// changes the proxy state that result depends on.
+func (s *DiscoveryServer) proxyNeedsPush(con *Connection, req *model.PushRequest) (*model.PushRequest, bool) {
+	r, _ := con.needsPush.Swap((*needsPushResult)(nil)).(*needsPushResult)
+	if r != nil && r.request == req && r.scope == con.proxy.SidecarScope {
+		return r.filtered, r.needsPush
 	}
+	return s.ProxyNeedsPush(con.proxy, req)
 }
 
 func (s *DiscoveryServer) addCon(conID string, con *Connection) {
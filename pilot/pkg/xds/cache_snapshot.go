@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+var errUpdatesPending = errors.New("config updates are pending")
+
+// RestoreCacheSnapshot warms the XDS cache from the snapshot at path, if there is one. It should be called
+// once the initial push context is ready, and before the server starts accepting connections.
+func (s *DiscoveryServer) RestoreCacheSnapshot(path string) {
+	t0 := time.Now()
+	snap, err := model.ReadXdsCacheSnapshot(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("failed to read xds cache snapshot: %v", err)
+		}
+		return
+	}
+	restored, err := snap.Restore(s.Env, s.Cache, t0)
+	if err != nil {
+		log.Infof("discarding xds cache snapshot: %v", err)
+		return
+	}
+	log.Infof("restored %d/%d xds cache entries from snapshot in %v", restored, len(snap.Entries), time.Since(t0))
+}
+
+// WriteCacheSnapshot writes a snapshot of the XDS cache to path.
+func (s *DiscoveryServer) WriteCacheSnapshot(path string) error {
+	// Cache entries are only cleared once a push for the config change starts, so while updates are
+	// pending the cache may hold entries generated from configs older than those in the config store.
+	// Such a snapshot would record the wrong versions for them, so skip it and try again later.
+	pending := func() bool {
+		return s.InboundUpdates.Load() != s.CommittedUpdates.Load()
+	}
+	if pending() {
+		return errUpdatesPending
+	}
+	fingerprints := model.ConfigFingerprints(s.Env)
+	snap, err := model.NewXdsCacheSnapshot(s.Env, s.Cache, fingerprints)
+	if err != nil {
+		return err
+	}
+	if pending() {
+		return errUpdatesPending
+	}
+	if err := snap.WriteFile(path); err != nil {
+		return fmt.Errorf("write %v: %v", path, err)
+	}
+	log.Debugf("wrote %d xds cache entries to snapshot", len(snap.Entries))
+	return nil
+}
+
+// periodicCacheSnapshot writes a snapshot of the XDS cache every interval, and once more on shutdown.
+func (s *DiscoveryServer) periodicCacheSnapshot(stopCh <-chan struct{}) {
+	path := features.XDSCacheSnapshotPath
+	write := func() {
+		if err := s.WriteCacheSnapshot(path); err != nil {
+			if errors.Is(err, errUpdatesPending) {
+				log.Debugf("skipping xds cache snapshot: %v", err)
+				return
+			}
+			log.Warnf("failed to write xds cache snapshot: %v", err)
+		}
+	}
+	ticker := time.NewTicker(features.XDSCacheSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			write()
+		case <-stopCh:
+			write()
+			return
+		}
+	}
+}
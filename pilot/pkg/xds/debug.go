@@ -209,6 +209,7 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 
 	s.addDebugHandler(mux, internalMux, "/debug/authorizationz", "Internal authorization policies", s.authorizationz)
 	s.addDebugHandler(mux, internalMux, "/debug/telemetryz", "Debug Telemetry configuration", s.telemetryz)
+	s.addDebugHandler(mux, internalMux, "/debug/impactz", "Preview which proxies a config change would push", s.Impactz)
 	s.addDebugHandler(mux, internalMux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
 	s.addDebugHandler(mux, internalMux, "/debug/push_status", "Last PushContext Details", s.pushStatusHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/pushcontext", "Debug support for current push context", s.pushContextHandler)
@@ -1030,8 +1031,24 @@ func (s *DiscoveryServer) ambientz(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, res, req)
 }
 
+// krtz dumps the state of krt collections. With ?snapshot=true, a krt.Snapshot is returned instead, which can be
+// compared with snapshots from other instances; ?collection=<name> limits it to the collections with the given names.
 func (s *DiscoveryServer) krtz(w http.ResponseWriter, req *http.Request) {
-	writeJSON(w, s.krtDebugger, req)
+	if req.URL.Query().Get("snapshot") != "true" {
+		writeJSON(w, s.krtDebugger, req)
+		return
+	}
+	var filter func(string) bool
+	if names := req.URL.Query()["collection"]; len(names) > 0 {
+		filter = sets.New(names...).Contains
+	}
+	snap, err := s.krtDebugger.Snapshot(filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	writeJSON(w, snap, req)
 }
 
 func (s *DiscoveryServer) networkz(w http.ResponseWriter, req *http.Request) {
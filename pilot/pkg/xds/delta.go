@@ -156,7 +156,7 @@ func (s *DiscoveryServer) pushConnectionDelta(con *Connection, pushEv *Event) er
 		s.computeProxyState(con.proxy, pushRequest)
 	}
 
-	pushRequest, needsPush := s.ProxyNeedsPush(con.proxy, pushRequest)
+	pushRequest, needsPush := s.proxyNeedsPush(con, pushRequest)
 	if !needsPush {
 		deltaLog.Debugf("Skipping push to %v, no updates required", con.ID())
 		return nil
@@ -148,7 +148,7 @@ func NewDiscoveryServer(env *model.Environment, clusterAliases map[string]string
 		InboundUpdates:      atomic.NewInt64(0),
 		CommittedUpdates:    atomic.NewInt64(0),
 		pushChannel:         make(chan *model.PushRequest, 10),
-		pushQueue:           NewPushQueue(),
+		pushQueue:           newPushQueue(),
 		debugHandlers:       map[string]string{},
 		adsClients:          map[string]*Connection{},
 		krtDebugger:         debugger,
@@ -171,6 +171,17 @@ func NewDiscoveryServer(env *model.Environment, clusterAliases map[string]string
 	return out
 }
 
+// newPushQueue returns the push queue configured by features.
+func newPushQueue() *PushQueue {
+	if !features.EnablePushPriority {
+		return NewPushQueue()
+	}
+	return NewPriorityPushQueue(map[PushPriority]int{
+		PushPriorityNormal: features.PushThrottleNormalPriority,
+		PushPriorityLow:    features.PushThrottleLowPriority,
+	}, features.PushPriorityMaxDelay)
+}
+
 // initJwkResolver initializes the JWT key resolver to be used.
 func (s *DiscoveryServer) initJwksResolver() {
 	if s.JwtKeyResolver != nil {
@@ -217,6 +228,9 @@ func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.periodicRefreshMetrics(stopCh)
 	go s.sendPushes(stopCh)
 	go s.Cache.Run(stopCh)
+	if features.XDSCacheSnapshotPath != "" {
+		go s.periodicCacheSnapshot(stopCh)
+	}
 }
 
 // Push metrics are updated periodically (10s default)
@@ -0,0 +1,303 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// ConfigImpact reports which connected proxies would be pushed if a set of configs were applied.
+type ConfigImpact struct {
+	// Configs are the configs that would be updated, as Kind/Namespace/Name.
+	Configs []string `json:"configs"`
+	// Proxies are the proxies that would be pushed.
+	Proxies []ProxyImpact `json:"proxies"`
+	// Unaffected is the number of connected proxies that would not be pushed.
+	Unaffected int `json:"unaffected"`
+}
+
+// ProxyImpact describes the push a single proxy would get.
+type ProxyImpact struct {
+	ProxyID   string `json:"proxy"`
+	ProxyType string `json:"proxyType"`
+	// Types are the short names of the XDS types that would be pushed, such as "CDS" or "LDS".
+	Types []string `json:"types"`
+}
+
+// impactKinds are the kinds that can be previewed. These are read by the push context from the config
+// store; kinds that feed into service registries, such as ServiceEntry, are not supported.
+var impactKinds = sets.New(
+	kind.AuthorizationPolicy,
+	kind.DestinationRule,
+	kind.EnvoyFilter,
+	kind.Gateway,
+	kind.PeerAuthentication,
+	kind.ProxyConfig,
+	kind.RequestAuthentication,
+	kind.Sidecar,
+	kind.Telemetry,
+	kind.VirtualService,
+	kind.WasmPlugin,
+)
+
+// Impactz previews the impact of applying, or with ?delete=true deleting, a set of configs. The configs
+// are read as YAML from the request body, or from the base64 encoded "config" query parameter, which is
+// how they are passed when the request is made over XDS.
+func (s *DiscoveryServer) Impactz(w http.ResponseWriter, req *http.Request) {
+	input, err := impactInput(w, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if tooLarge := (&http.MaxBytesError{}); errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	configs, others, err := crd.ParseInputs(input)
+	if err == nil && len(others) > 0 {
+		err = unsupportedKindError(others[0].Kind)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "invalid configs: %v\n", err)
+		return
+	}
+	impact, err := s.ConfigImpact(configs, req.URL.Query().Get("delete") == "true")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	writeJSON(w, impact, req)
+}
+
+func unsupportedKindError(k string) error {
+	return fmt.Errorf("unsupported kind %v; supported kinds are %v", k, slices.Sort(slices.Map(impactKinds.UnsortedList(), kind.Kind.String)))
+}
+
+// impactInput reads the configs of the request, which are bounded by the size of the XDS messages received by istiod,
+// like the configs passed over XDS.
+func impactInput(w http.ResponseWriter, req *http.Request) (string, error) {
+	limit := int64(features.MaxRecvMsgSize)
+	if req.Method == http.MethodPost {
+		b, err := io.ReadAll(http.MaxBytesReader(w, req.Body, limit))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	encoded := req.URL.Query().Get("config")
+	if encoded == "" {
+		return "", errors.New("configs must be provided in the request body, or base64 encoded in the config query parameter")
+	}
+	if int64(base64.RawURLEncoding.DecodedLen(len(encoded))) > limit {
+		return "", &http.MaxBytesError{Limit: limit}
+	}
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid config query parameter: %v", err)
+	}
+	return string(b), nil
+}
+
+// ConfigImpact computes which connected proxies, and which of their XDS types, would be pushed if configs
+// were applied (or deleted, if delete is set). This follows the same steps as an actual push: a push
+// context is built with the configs applied, the state of each proxy is recomputed against it, and the
+// proxy and per type dependency checks are run. Nothing is pushed, and the current state is unchanged.
+func (s *DiscoveryServer) ConfigImpact(configs []config.Config, deleted bool) (*ConfigImpact, error) {
+	store := &overlayConfigStore{
+		ConfigStore: s.Env.ConfigStore,
+		changes:     map[config.GroupVersionKind]map[types.NamespacedName]*config.Config{},
+	}
+	updated := sets.New[model.ConfigKey]()
+	for _, c := range configs {
+		k := gvk.MustToKind(c.GroupVersionKind)
+		if !impactKinds.Contains(k) {
+			return nil, unsupportedKindError(c.GroupVersionKind.Kind)
+		}
+		if c.Namespace == "" {
+			c.Namespace = "default"
+		}
+		if c.Domain == "" {
+			c.Domain = s.Env.DomainSuffix
+		}
+		store.set(c, deleted)
+		updated.Insert(model.ConfigKey{Kind: k, Name: c.Name, Namespace: c.Namespace})
+	}
+
+	current := s.globalPushContext()
+	req := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: updated,
+		Reason:         model.NewReasonStats(model.ConfigUpdate),
+	}
+	push := model.NewPushContext()
+	push.PushVersion = current.PushVersion
+	push.JwtKeyResolver = s.JwtKeyResolver
+	push.InitContext(s.Env.WithConfigStore(store), current, req)
+	req.Push = push
+
+	impact := &ConfigImpact{
+		Configs: slices.Sort(slices.Map(updated.UnsortedList(), model.ConfigKey.String)),
+		Proxies: []ProxyImpact{},
+	}
+	for _, con := range s.Clients() {
+		proxy := cloneProxy(con.proxy)
+		s.computeProxyState(proxy, req)
+		proxyReq, needsPush := s.ProxyNeedsPush(proxy, req)
+		var pushed []string
+		if needsPush {
+			for _, w := range proxy.ShallowCloneWatchedResources() {
+				if typeNeedsPush(w.TypeUrl, proxy, proxyReq) {
+					pushed = append(pushed, v3.GetShortType(w.TypeUrl))
+				}
+			}
+		}
+		if len(pushed) == 0 {
+			impact.Unaffected++
+			continue
+		}
+		impact.Proxies = append(impact.Proxies, ProxyImpact{
+			ProxyID:   con.ID(),
+			ProxyType: string(proxy.Type),
+			Types:     slices.Sort(pushed),
+		})
+	}
+	sort.Slice(impact.Proxies, func(i, j int) bool {
+		return impact.Proxies[i].ProxyID < impact.Proxies[j].ProxyID
+	})
+	return impact, nil
+}
+
+// typeNeedsPush runs the dependency check the generator for typeURL would run before generating. Types
+// without such a check are assumed to be pushed.
+func typeNeedsPush(typeURL string, proxy *model.Proxy, req *model.PushRequest) bool {
+	switch typeURL {
+	case v3.ClusterType:
+		_, needsPush := cdsNeedsPush(req, proxy)
+		return needsPush
+	case v3.ListenerType:
+		return ldsNeedsPush(proxy, req)
+	case v3.RouteType:
+		return rdsNeedsPush(req, proxy)
+	case v3.EndpointType:
+		return edsNeedsPush(req, proxy)
+	case v3.SecretType:
+		return sdsNeedsPush(req.Forced, req.ConfigsUpdated)
+	case v3.ExtensionConfigurationType:
+		return ecdsNeedsPush(req, proxy)
+	case v3.NameTableType:
+		return ndsNeedsPush(req, proxy)
+	case v3.ProxyConfigType:
+		return pcdsNeedsPush(req)
+	default:
+		return true
+	}
+}
+
+// overlayConfigStore is a read only ConfigStore applying a set of changes on top of another store.
+type overlayConfigStore struct {
+	model.ConfigStore
+	// changes holds the configs replacing those of the underlying store. A nil config is deleted.
+	changes map[config.GroupVersionKind]map[types.NamespacedName]*config.Config
+}
+
+var _ model.ConfigStore = &overlayConfigStore{}
+
+var errReadOnly = errors.New("config store is read only")
+
+func (o *overlayConfigStore) set(c config.Config, deleted bool) {
+	nn := types.NamespacedName{Namespace: c.Namespace, Name: c.Name}
+	if o.changes[c.GroupVersionKind] == nil {
+		o.changes[c.GroupVersionKind] = map[types.NamespacedName]*config.Config{}
+	}
+	if deleted {
+		o.changes[c.GroupVersionKind][nn] = nil
+		return
+	}
+	if existing := o.ConfigStore.Get(c.GroupVersionKind, c.Name, c.Namespace); existing != nil {
+		// Precedence between configs depends on their creation time, so an update keeps it.
+		c.CreationTimestamp = existing.CreationTimestamp
+	} else if c.CreationTimestamp.IsZero() {
+		c.CreationTimestamp = time.Now()
+	}
+	o.changes[c.GroupVersionKind][nn] = &c
+}
+
+func (o *overlayConfigStore) Get(typ config.GroupVersionKind, name, namespace string) *config.Config {
+	if c, f := o.changes[typ][types.NamespacedName{Namespace: namespace, Name: name}]; f {
+		return c
+	}
+	return o.ConfigStore.Get(typ, name, namespace)
+}
+
+func (o *overlayConfigStore) List(typ config.GroupVersionKind, namespace string) []config.Config {
+	changes := o.changes[typ]
+	base := o.ConfigStore.List(typ, namespace)
+	if len(changes) == 0 {
+		return base
+	}
+	res := make([]config.Config, 0, len(base)+len(changes))
+	for _, c := range base {
+		if _, f := changes[types.NamespacedName{Namespace: c.Namespace, Name: c.Name}]; !f {
+			res = append(res, c)
+		}
+	}
+	for _, c := range changes {
+		if c != nil && (namespace == model.NamespaceAll || c.Namespace == namespace) {
+			res = append(res, *c)
+		}
+	}
+	return res
+}
+
+func (o *overlayConfigStore) Create(config.Config) (string, error) {
+	return "", errReadOnly
+}
+
+func (o *overlayConfigStore) Update(config.Config) (string, error) {
+	return "", errReadOnly
+}
+
+func (o *overlayConfigStore) UpdateStatus(config.Config) (string, error) {
+	return "", errReadOnly
+}
+
+func (o *overlayConfigStore) Patch(config.Config, config.PatchFunc) (string, error) {
+	return "", errReadOnly
+}
+
+func (o *overlayConfigStore) Delete(config.GroupVersionKind, string, string, *string) error {
+	return errReadOnly
+}
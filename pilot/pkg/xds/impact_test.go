@@ -0,0 +1,170 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	xdsfake "istio.io/istio/pilot/test/xds"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const impactBaseConfig = `
+apiVersion: networking.istio.io/v1
+kind: Sidecar
+metadata:
+  name: default
+  namespace: b
+spec:
+  egress:
+  - hosts:
+    - "./*"
+---
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: reviews
+  namespace: b
+spec:
+  hosts: ["reviews.b.svc.cluster.local"]
+  exportTo: ["."]
+  http:
+  - route:
+    - destination:
+        host: reviews.b.svc.cluster.local
+`
+
+func TestConfigImpact(t *testing.T) {
+	s := xdsfake.NewFakeDiscoveryServer(t, xdsfake.FakeOptions{ConfigString: impactBaseConfig})
+	watch := []string{v3.ClusterType, v3.ListenerType}
+	s.Connect(&model.Proxy{ConfigNamespace: "a", IPAddresses: []string{"10.0.0.1"}}, watch, watch)
+	s.Connect(&model.Proxy{ConfigNamespace: "b", IPAddresses: []string{"10.0.0.2"}}, watch, watch)
+	retry.UntilOrFail(t, func() bool {
+		return len(s.Discovery.AllClients()) == 2
+	})
+
+	impactz := func(t *testing.T, query string, body string) (int, *xds.ConfigImpact) {
+		t.Helper()
+		method := http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+		req := httptest.NewRequest(method, "/debug/impactz"+query, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.Discovery.Impactz).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			return rr.Code, nil
+		}
+		impact := &xds.ConfigImpact{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), impact))
+		return rr.Code, impact
+	}
+	namespaces := func(impact *xds.ConfigImpact) []string {
+		var res []string
+		for _, p := range impact.Proxies {
+			// Connection IDs are <name>.<namespace>-<counter>
+			id := p.ProxyID[:strings.LastIndex(p.ProxyID, "-")]
+			res = append(res, id[strings.LastIndex(id, ".")+1:])
+		}
+		return res
+	}
+
+	t.Run("virtual service not visible to sidecar", func(t *testing.T) {
+		_, impact := impactz(t, "", `
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: ratings
+  namespace: a
+spec:
+  hosts: ["ratings.a.svc.cluster.local"]
+  http:
+  - route:
+    - destination:
+        host: ratings.a.svc.cluster.local
+`)
+		assert.Equal(t, impact.Configs, []string{"VirtualService/a/ratings"})
+		assert.Equal(t, namespaces(impact), []string{"a"})
+		assert.Equal(t, impact.Unaffected, 1)
+		assert.Equal(t, impact.Proxies[0].ProxyType, string(model.SidecarProxy))
+		assert.Equal(t, impact.Proxies[0].Types, []string{"CDS", "LDS"})
+	})
+	t.Run("sidecar change", func(t *testing.T) {
+		sidecar := `
+apiVersion: networking.istio.io/v1
+kind: Sidecar
+metadata:
+  name: default
+  namespace: b
+spec:
+  egress:
+  - hosts:
+    - "*/*"
+`
+		_, impact := impactz(t, "?config="+base64.RawURLEncoding.EncodeToString([]byte(sidecar)), "")
+		assert.Equal(t, namespaces(impact), []string{"b"})
+	})
+	t.Run("delete", func(t *testing.T) {
+		vs := `
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: reviews
+  namespace: b
+spec:
+  hosts: ["reviews.b.svc.cluster.local"]
+  http:
+  - route:
+    - destination:
+        host: reviews.b.svc.cluster.local
+`
+		_, impact := impactz(t, "?delete=true", vs)
+		assert.Equal(t, namespaces(impact), []string{"b"})
+		// The preview does not change the actual config
+		assert.Equal(t, s.Discovery.Env.ConfigStore.Get(gvk.VirtualService, "reviews", "b") != nil, true)
+	})
+	t.Run("unsupported kind", func(t *testing.T) {
+		code, _ := impactz(t, "", `
+apiVersion: networking.istio.io/v1
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: a
+spec:
+  hosts: ["example.com"]
+  resolution: DNS
+`)
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+	t.Run("too large", func(t *testing.T) {
+		code, _ := impactz(t, "", strings.Repeat("#", features.MaxRecvMsgSize+1))
+		assert.Equal(t, code, http.StatusRequestEntityTooLarge)
+	})
+	t.Run("no config", func(t *testing.T) {
+		code, _ := impactz(t, "", "")
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+}
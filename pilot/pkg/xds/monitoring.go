@@ -26,8 +26,9 @@ import (
 )
 
 var (
-	typeTag    = monitoring.CreateLabel("type")
-	versionTag = monitoring.CreateLabel("version")
+	typeTag     = monitoring.CreateLabel("type")
+	versionTag  = monitoring.CreateLabel("version")
+	priorityTag = monitoring.CreateLabel("priority")
 
 	monServices = monitoring.NewGauge(
 		"pilot_services",
@@ -78,6 +79,21 @@ var (
 		[]float64{.1, .5, 1, 3, 5, 10, 20, 30},
 	)
 
+	pushQueuePending = monitoring.NewGauge(
+		"pilot_push_queue_pending",
+		"Number of proxies waiting in the push queue, labeled by push priority.",
+	)
+
+	pushQueueInflight = monitoring.NewGauge(
+		"pilot_push_queue_inflight",
+		"Number of pushes dequeued and not yet completed, labeled by push priority.",
+	)
+
+	pushQueuePromotions = monitoring.NewSum(
+		"pilot_push_queue_promotions",
+		"Total number of pushes sent ahead of higher priority pushes because they were queued for too long, labeled by push priority.",
+	)
+
 	pushTriggers = monitoring.NewSum(
 		"pilot_push_triggers",
 		"Total number of times a push was triggered, labeled by reason for the push.",
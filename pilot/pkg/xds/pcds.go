@@ -15,6 +15,8 @@
 package xds
 
 import (
+	"time"
+
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 	mesh "istio.io/api/mesh/v1alpha1"
@@ -22,6 +24,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	tb "istio.io/istio/pilot/pkg/trustbundle"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 )
 
 // PcdsGenerator generates proxy configuration for proxies to consume
@@ -31,6 +34,13 @@ type PcdsGenerator struct {
 
 var _ model.XdsResourceGenerator = &PcdsGenerator{}
 
+// federatedTrustBundleMinVersion is the first version of the proxies binding the trust anchors of the federated trust
+// domains to their trust domain.
+var federatedTrustBundleMinVersion = &model.IstioVersion{Major: 1, Minor: 28, Patch: -1}
+
+// trustBundleAckMinVersion is the first version of the agents acking the trust bundle once Envoy applied it.
+var trustBundleAckMinVersion = &model.IstioVersion{Major: 1, Minor: 28, Patch: -1}
+
 func pcdsNeedsPush(req *model.PushRequest) bool {
 	if !features.MultiRootMesh {
 		return false
@@ -61,8 +71,37 @@ func (e *PcdsGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, r
 		return nil, model.DefaultXdsLogDetails, nil
 	}
 	// TODO: For now, only TrustBundle updates are pushed. Eventually, this should push entire Proxy Configuration
+	certs := e.TrustBundle.GetTrustBundle()
+	// Older proxies would merge the federated trust anchors in the trust bundle of the mesh, letting the federated
+	// trust domains validate identities of the mesh: they are only sent to the proxies which bind them to their trust
+	// domain.
+	if proxy.IstioVersion != nil && proxy.VersionGreaterOrEqual(federatedTrustBundleMinVersion) {
+		certs = append(certs, e.TrustBundle.GetFederatedTrustBundle()...)
+	}
 	pc := &mesh.ProxyConfig{
-		CaCertificatesPem: e.TrustBundle.GetTrustBundle(),
+		CaCertificatesPem: certs,
 	}
 	return model.Resources{&discovery.Resource{Resource: protoconv.MessageToAny(pc)}}, model.DefaultXdsLogDetails, nil
 }
+
+// TrustBundleAcks returns the number of proxies connected to this replica, how many of them acked a trust bundle sent
+// after since, and how many of them cannot ack the trust bundle. The agents ack the proxy configuration carrying the
+// trust bundle once Envoy acked it over SDS. Ztunnel, and the proxies not watching the proxy configuration or whose
+// agent acks it right away, only pick up the roots with their certificates. The proxies of the other replicas are not
+// accounted for.
+func (s *DiscoveryServer) TrustBundleAcks(since time.Time) (acked, total, withoutAcks int) {
+	for _, con := range s.Clients() {
+		total++
+		con.proxy.RLock()
+		w := con.proxy.WatchedResources[v3.ProxyConfigType]
+		switch {
+		case w == nil || con.proxy.IsZTunnel() || con.proxy.IstioVersion == nil ||
+			!con.proxy.VersionGreaterOrEqual(trustBundleAckMinVersion):
+			withoutAcks++
+		case w.NonceSent != "" && w.NonceAcked == w.NonceSent && !w.LastSendTime.Before(since):
+			acked++
+		}
+		con.proxy.RUnlock()
+	}
+	return acked, total, withoutAcks
+}
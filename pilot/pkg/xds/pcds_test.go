@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mesh "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/trustbundle"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	xdsfake "istio.io/istio/pilot/test/xds"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/file"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestPcdsFederatedTrustBundle(t *testing.T) {
+	test.SetForTest(t, &features.MultiRootMesh, true)
+	meshRoot := file.AsStringOrFail(t, filepath.Join(env.IstioSrc, "security/pkg/pki/testdata/spiffe-root-cert-1.pem"))
+	partnerRoot := file.AsStringOrFail(t, filepath.Join(env.IstioSrc, "security/pkg/pki/testdata/spiffe-root-cert-2.pem"))
+	tb := trustbundle.NewTrustBundle(nil, nil)
+	assert.NoError(t, tb.UpdateTrustAnchor(&trustbundle.TrustAnchorUpdate{
+		TrustAnchorConfig: trustbundle.TrustAnchorConfig{Certs: []string{meshRoot}},
+		Source:            trustbundle.SourceIstioCA,
+	}))
+	assert.NoError(t, tb.UpdateFederatedTrustAnchors(map[string][]string{"partner.example.org": {partnerRoot}}))
+	gen := &xds.PcdsGenerator{TrustBundle: tb}
+
+	certs := func(version string) ([]string, map[string][]string) {
+		res, _, err := gen.Generate(&model.Proxy{IstioVersion: model.ParseIstioVersion(version)}, nil,
+			&model.PushRequest{Full: true, Forced: true})
+		assert.NoError(t, err)
+		pc := &mesh.ProxyConfig{}
+		assert.NoError(t, res[0].Resource.UnmarshalTo(pc))
+		return spiffe.SplitTrustDomainCerts(pc.CaCertificatesPem)
+	}
+
+	// The federated roots are bound to their trust domain, and not merged in the roots of the mesh.
+	meshRoots, federated := certs("1.28.0")
+	assert.Equal(t, meshRoots, []string{meshRoot})
+	assert.Equal(t, federated, map[string][]string{"partner.example.org": {partnerRoot}})
+
+	// Older proxies would merge the federated roots in the roots of the mesh.
+	meshRoots, federated = certs("1.27.3")
+	assert.Equal(t, meshRoots, []string{meshRoot})
+	assert.Equal(t, len(federated), 0)
+}
+
+func TestTrustBundleAcks(t *testing.T) {
+	test.SetForTest(t, &features.MultiRootMesh, true)
+	s := xdsfake.NewFakeDiscoveryServer(t, xdsfake.FakeOptions{})
+	s.Discovery.Generators[v3.ProxyConfigType] = &xds.PcdsGenerator{TrustBundle: trustbundle.NewTrustBundle(nil, nil)}
+
+	before := time.Now()
+	acks := func(since time.Time) string {
+		acked, total, withoutAcks := s.Discovery.TrustBundleAcks(since)
+		return fmt.Sprintf("%d/%d, %d without acks", acked, total, withoutAcks)
+	}
+	if got := acks(before); got != "0/0, 0 without acks" {
+		t.Fatalf("got %s without proxy", got)
+	}
+
+	// A proxy not watching the proxy configuration, and a proxy whose agent acks it before Envoy applied the trust
+	// bundle, cannot ack the trust bundle.
+	s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+	s.ConnectADS().WithType(v3.ProxyConfigType).WithMetadata(model.NodeMetadata{IstioVersion: "1.27.0"}).RequestResponseAck(t, nil)
+	s.ConnectADS().WithType(v3.ProxyConfigType).RequestResponseAck(t, nil)
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := acks(before); got != "1/3, 2 without acks" {
+			return fmt.Errorf("got %s, want 1/3, 2 without acks", got)
+		}
+		return nil
+	})
+	if got := acks(time.Now()); got != "0/3, 2 without acks" {
+		t.Fatalf("got %s for a later trust bundle, want 0/3, 2 without acks", got)
+	}
+}
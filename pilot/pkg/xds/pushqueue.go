@@ -16,39 +16,117 @@ package xds
 
 import (
 	"sync"
+	"time"
 
 	"istio.io/istio/pilot/pkg/model"
 )
 
+// PushPriority determines the order in which a PushQueue sends pushes. Higher priorities are sent first.
+type PushPriority int
+
+const (
+	// PushPriorityLow is used for proxies that are not affected by the push.
+	PushPriorityLow PushPriority = iota
+	// PushPriorityNormal is used for pushes that affect all proxies alike, and is the priority of Enqueue.
+	PushPriorityNormal
+	// PushPriorityHigh is used for gateways, and for proxies that depend on the configs updated by the push.
+	PushPriorityHigh
+
+	numPushPriorities = int(PushPriorityHigh) + 1
+)
+
+func (p PushPriority) String() string {
+	switch p {
+	case PushPriorityLow:
+		return "low"
+	case PushPriorityNormal:
+		return "normal"
+	case PushPriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
 type PushQueue struct {
 	cond *sync.Cond
 
 	// pending stores all connections in the queue. If the same connection is enqueued again,
 	// the PushRequest will be merged.
-	pending map[*Connection]*model.PushRequest
+	pending map[*Connection]*queuedPush
 
-	// queue maintains ordering of the queue
-	queue []*Connection
+	// queues maintain ordering of the queue, one per priority. When a pending push is raised to a
+	// higher priority, it is appended to the higher priority queue, and its entry in the lower priority
+	// queue is skipped when reached.
+	queues [numPushPriorities][]*Connection
 
 	// processing stores all connections that have been Dequeue(), but not MarkDone().
-	// The value stored will be initially be nil, but may be populated if the connection is Enqueue().
+	// The request stored will be initially be nil, but may be populated if the connection is Enqueue().
 	// If model.PushRequest is not nil, it will be Enqueued again once MarkDone has been called.
-	processing map[*Connection]*model.PushRequest
+	processing map[*Connection]*processingPush
+
+	// budgets limits the number of pushes of each priority that may be processed concurrently.
+	// A budget of zero is unlimited.
+	budgets [numPushPriorities]int
+	// queued and inflight count the pushes of each priority pending and being processed.
+	queued   [numPushPriorities]int
+	inflight [numPushPriorities]int
+	// maxDelay, if set, is the time after which a queued push is sent ahead of higher priority pushes, and
+	// regardless of the budget of its priority.
+	maxDelay time.Duration
+	// wakeup wakes up Dequeue once the oldest pending push reaches maxDelay, as it may then be sent.
+	wakeup *time.Timer
+	// prioritized is set if the queue was created with NewPriorityPushQueue, and enables metrics.
+	prioritized bool
 
 	shuttingDown bool
 }
 
+type queuedPush struct {
+	request  *model.PushRequest
+	priority PushPriority
+	// enqueued is the time the connection was first enqueued, which is kept when requests are merged.
+	enqueued time.Time
+}
+
+type processingPush struct {
+	priority PushPriority
+	// next is the request to enqueue once the connection is marked done, if any.
+	next         *model.PushRequest
+	nextPriority PushPriority
+}
+
+// NewPushQueue returns a queue sending pushes in the order they were enqueued.
 func NewPushQueue() *PushQueue {
 	return &PushQueue{
-		pending:    make(map[*Connection]*model.PushRequest),
-		processing: make(map[*Connection]*model.PushRequest),
+		pending:    make(map[*Connection]*queuedPush),
+		processing: make(map[*Connection]*processingPush),
 		cond:       sync.NewCond(&sync.Mutex{}),
 	}
 }
 
-// Enqueue will mark a proxy as pending a push. If it is already pending, pushInfo will be merged.
+// NewPriorityPushQueue returns a queue sending higher priority pushes first. At most budgets[p] pushes of
+// priority p are processed at once, where zero is unlimited. A push queued for longer than maxDelay is
+// sent ahead of higher priority pushes, even if its budget is used up; zero disables this.
+func NewPriorityPushQueue(budgets map[PushPriority]int, maxDelay time.Duration) *PushQueue {
+	p := NewPushQueue()
+	for priority, budget := range budgets {
+		p.budgets[priority] = budget
+	}
+	p.maxDelay = maxDelay
+	p.prioritized = true
+	return p
+}
+
+// Enqueue will mark a proxy as pending a push with normal priority. If it is already pending, pushInfo will be merged.
 // ServiceEntry updates will be added together, and full will be set if either were full
 func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
+	p.EnqueueWithPriority(con, pushRequest, PushPriorityNormal)
+}
+
+// EnqueueWithPriority is like Enqueue, with the given priority. If the proxy is already pending, it keeps
+// the highest of both priorities.
+func (p *PushQueue) EnqueueWithPriority(con *Connection, pushRequest *model.PushRequest, priority PushPriority) {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 
@@ -57,18 +135,57 @@ func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
 	}
 
 	// If its already in progress, merge the info and return
-	if request, f := p.processing[con]; f {
-		p.processing[con] = request.CopyMerge(pushRequest)
+	if inProgress, f := p.processing[con]; f {
+		if inProgress.next == nil {
+			inProgress.nextPriority = priority
+		} else {
+			inProgress.nextPriority = max(inProgress.nextPriority, priority)
+		}
+		inProgress.next = inProgress.next.CopyMerge(pushRequest)
 		return
 	}
 
-	if request, f := p.pending[con]; f {
-		p.pending[con] = request.CopyMerge(pushRequest)
+	if queued, f := p.pending[con]; f {
+		queued.request = queued.request.CopyMerge(pushRequest)
+		if priority > queued.priority {
+			p.queued[queued.priority]--
+			p.queued[priority]++
+			queued.priority = priority
+			p.queues[priority] = append(p.queues[priority], con)
+			p.recordMetrics()
+			p.cond.Signal()
+		}
 		return
 	}
 
-	p.pending[con] = pushRequest
-	p.queue = append(p.queue, con)
+	p.push(con, pushRequest, priority, time.Now())
+}
+
+// Reprioritize changes the priority of the push of pushRequest to con, if it is still pending. It is ignored if
+// pushRequest was merged with another request since, as the priority of the merged push depends on both.
+func (p *PushQueue) Reprioritize(con *Connection, pushRequest *model.PushRequest, priority PushPriority) {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+
+	queued, f := p.pending[con]
+	if !f || queued.request != pushRequest || queued.priority == priority {
+		return
+	}
+	p.queued[queued.priority]--
+	p.queued[priority]++
+	queued.priority = priority
+	// The entry in the queue of the previous priority is skipped when reached.
+	p.queues[priority] = append(p.queues[priority], con)
+	p.recordMetrics()
+	p.cond.Signal()
+}
+
+// push adds a connection to the queue. It must be called with the lock held.
+func (p *PushQueue) push(con *Connection, request *model.PushRequest, priority PushPriority, enqueued time.Time) {
+	p.pending[con] = &queuedPush{request: request, priority: priority, enqueued: enqueued}
+	p.queued[priority]++
+	p.queues[priority] = append(p.queues[priority], con)
+	p.recordMetrics()
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
@@ -78,51 +195,149 @@ func (p *PushQueue) Dequeue() (con *Connection, request *model.PushRequest, shut
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 
-	// Block until there is one to remove. Enqueue will signal when one is added.
-	for len(p.queue) == 0 && !p.shuttingDown {
+	// Block until there is one to remove. Enqueue will signal when one is added, and MarkDone when
+	// a push completes and frees up budget.
+	for {
+		priority, found := p.next()
+		if found {
+			con = p.pop(priority)
+			break
+		}
+		if p.shuttingDown && len(p.pending) == 0 {
+			// We must be shutting down.
+			return nil, nil, true
+		}
+		p.scheduleWakeup()
 		p.cond.Wait()
 	}
 
-	if len(p.queue) == 0 {
-		// We must be shutting down.
-		return nil, nil, true
+	queued := p.pending[con]
+	delete(p.pending, con)
+
+	// Mark the connection as in progress
+	p.processing[con] = &processingPush{priority: queued.priority}
+	p.queued[queued.priority]--
+	p.inflight[queued.priority]++
+	p.recordMetrics()
+
+	return con, queued.request, false
+}
+
+// next returns the priority of the queue to serve next, if there is any eligible.
+// It must be called with the lock held.
+func (p *PushQueue) next() (PushPriority, bool) {
+	if p.maxDelay > 0 {
+		// Serve the oldest push that has waited too long, regardless of its priority and budget.
+		if oldest := p.oldest(); oldest != nil && time.Since(oldest.enqueued) >= p.maxDelay {
+			for i := int(oldest.priority) + 1; i < numPushPriorities; i++ {
+				if p.head(PushPriority(i)) != nil {
+					pushQueuePromotions.With(priorityTag.Value(oldest.priority.String())).Increment()
+					break
+				}
+			}
+			return oldest.priority, true
+		}
+	}
+	for i := numPushPriorities - 1; i >= 0; i-- {
+		priority := PushPriority(i)
+		if p.head(priority) != nil && p.hasBudget(priority) {
+			return priority, true
+		}
+	}
+	return 0, false
+}
+
+// oldest returns the pending push queued first. It must be called with the lock held.
+func (p *PushQueue) oldest() *queuedPush {
+	var oldest *queuedPush
+	for i := range numPushPriorities {
+		if head := p.head(PushPriority(i)); head != nil && (oldest == nil || head.enqueued.Before(oldest.enqueued)) {
+			oldest = head
+		}
 	}
+	return oldest
+}
 
-	con = p.queue[0]
-	// The underlying array will still exist, despite the slice changing, so the object may not GC without this
-	// See https://github.com/grpc/grpc-go/issues/4758
-	p.queue[0] = nil
-	p.queue = p.queue[1:]
+// scheduleWakeup schedules a wakeup of Dequeue for when the oldest pending push reaches maxDelay, so that a push
+// waiting for budget does not wait for the next Enqueue or MarkDone. It must be called with the lock held.
+func (p *PushQueue) scheduleWakeup() {
+	if p.maxDelay <= 0 {
+		return
+	}
+	oldest := p.oldest()
+	if oldest == nil {
+		return
+	}
+	d := time.Until(oldest.enqueued.Add(p.maxDelay))
+	if p.wakeup == nil {
+		p.wakeup = time.AfterFunc(d, func() {
+			p.cond.L.Lock()
+			defer p.cond.L.Unlock()
+			p.cond.Broadcast()
+		})
+		return
+	}
+	p.wakeup.Reset(d)
+}
 
-	request = p.pending[con]
-	delete(p.pending, con)
+// head returns the first pending push of the given priority, dropping entries of pushes that were moved
+// to a higher priority. It must be called with the lock held.
+func (p *PushQueue) head(priority PushPriority) *queuedPush {
+	q := p.queues[priority]
+	for len(q) > 0 {
+		if queued, f := p.pending[q[0]]; f && queued.priority == priority {
+			p.queues[priority] = q
+			return queued
+		}
+		// The underlying array will still exist, despite the slice changing, so the object may not GC without this
+		// See https://github.com/grpc/grpc-go/issues/4758
+		q[0] = nil
+		q = q[1:]
+	}
+	p.queues[priority] = q
+	return nil
+}
 
-	// Mark the connection as in progress
-	p.processing[con] = nil
+// pop removes the first connection of the given priority, which must have been returned by head.
+// It must be called with the lock held.
+func (p *PushQueue) pop(priority PushPriority) *Connection {
+	con := p.queues[priority][0]
+	p.queues[priority][0] = nil
+	p.queues[priority] = p.queues[priority][1:]
+	return con
+}
 
-	return con, request, false
+func (p *PushQueue) hasBudget(priority PushPriority) bool {
+	budget := p.budgets[priority]
+	return budget <= 0 || p.inflight[priority] < budget
 }
 
 func (p *PushQueue) MarkDone(con *Connection) {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
-	request := p.processing[con]
+	inProgress, f := p.processing[con]
+	if !f {
+		return
+	}
 	delete(p.processing, con)
+	p.inflight[inProgress.priority]--
 
 	// If the info is present, that means Enqueue was called while connection was not yet marked done.
 	// This means we need to add it back to the queue.
-	if request != nil {
-		p.pending[con] = request
-		p.queue = append(p.queue, con)
-		p.cond.Signal()
+	if inProgress.next != nil {
+		p.push(con, inProgress.next, inProgress.nextPriority, time.Now())
+		return
 	}
+	p.recordMetrics()
+	// The push may have freed up budget for a waiting push.
+	p.cond.Signal()
 }
 
 // Get number of pending proxies
 func (p *PushQueue) Pending() int {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
-	return len(p.queue)
+	return len(p.pending)
 }
 
 // ShutDown will cause queue to ignore all new items added to it. As soon as the
@@ -132,5 +347,21 @@ func (p *PushQueue) ShutDown() {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 	p.shuttingDown = true
+	if p.wakeup != nil {
+		p.wakeup.Stop()
+	}
 	p.cond.Broadcast()
 }
+
+// recordMetrics records the number of pending and in progress pushes of each priority.
+// It must be called with the lock held.
+func (p *PushQueue) recordMetrics() {
+	if !p.prioritized {
+		return
+	}
+	for i := range numPushPriorities {
+		tag := priorityTag.Value(PushPriority(i).String())
+		pushQueuePending.With(tag).Record(float64(p.queued[i]))
+		pushQueueInflight.With(tag).Record(float64(p.inflight[i]))
+	}
+}
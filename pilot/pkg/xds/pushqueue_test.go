@@ -25,6 +25,7 @@ import (
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -373,3 +374,152 @@ func TestProxyQueue(t *testing.T) {
 		}
 	})
 }
+
+func TestPriorityPushQueue(t *testing.T) {
+	proxies := make([]*Connection, 0, 4)
+	for p := 0; p < 4; p++ {
+		conn := newConnection("", nil)
+		conn.SetID(fmt.Sprintf("proxy-%d", p))
+		proxies = append(proxies, conn)
+	}
+
+	t.Run("higher priority first", func(t *testing.T) {
+		p := NewPriorityPushQueue(nil, 0)
+		defer p.ShutDown()
+		p.EnqueueWithPriority(proxies[0], &model.PushRequest{}, PushPriorityLow)
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{}, PushPriorityNormal)
+		p.EnqueueWithPriority(proxies[2], &model.PushRequest{}, PushPriorityHigh)
+		p.EnqueueWithPriority(proxies[3], &model.PushRequest{}, PushPriorityNormal)
+
+		ExpectDequeue(t, p, proxies[2])
+		ExpectDequeue(t, p, proxies[1])
+		ExpectDequeue(t, p, proxies[3])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("merge raises priority", func(t *testing.T) {
+		p := NewPriorityPushQueue(nil, 0)
+		defer p.ShutDown()
+		p.EnqueueWithPriority(proxies[0], &model.PushRequest{}, PushPriorityNormal)
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{}, PushPriorityLow)
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{Full: true}, PushPriorityHigh)
+		// Merging never lowers the priority
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{}, PushPriorityLow)
+		assert.Equal(t, p.Pending(), 2)
+
+		con, req, _ := p.Dequeue()
+		if con != proxies[1] {
+			t.Fatalf("Expected proxy %v, got %v", proxies[1], con)
+		}
+		assert.Equal(t, req.Full, true)
+		ExpectDequeue(t, p, proxies[0])
+		// The stale low priority entry is skipped
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		p := NewPriorityPushQueue(map[PushPriority]int{PushPriorityLow: 1}, 0)
+		defer p.ShutDown()
+		p.EnqueueWithPriority(proxies[0], &model.PushRequest{}, PushPriorityLow)
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{}, PushPriorityLow)
+		ExpectDequeue(t, p, proxies[0])
+		// The low priority budget is used up, but other priorities are not affected
+		p.EnqueueWithPriority(proxies[2], &model.PushRequest{}, PushPriorityNormal)
+		ExpectDequeue(t, p, proxies[2])
+		// Checked without Dequeue, which would take the push once the budget is freed up
+		p.cond.L.Lock()
+		_, eligible := p.next()
+		p.cond.L.Unlock()
+		assert.Equal(t, eligible, false)
+		p.MarkDone(proxies[0])
+		ExpectDequeue(t, p, proxies[1])
+	})
+
+	t.Run("requeue keeps priority", func(t *testing.T) {
+		p := NewPriorityPushQueue(nil, 0)
+		defer p.ShutDown()
+		p.EnqueueWithPriority(proxies[0], &model.PushRequest{}, PushPriorityHigh)
+		ExpectDequeue(t, p, proxies[0])
+		p.EnqueueWithPriority(proxies[0], &model.PushRequest{}, PushPriorityHigh)
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{}, PushPriorityNormal)
+		p.MarkDone(proxies[0])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectDequeue(t, p, proxies[1])
+	})
+
+	t.Run("max delay", func(t *testing.T) {
+		p := NewPriorityPushQueue(nil, time.Millisecond*50)
+		defer p.ShutDown()
+		p.EnqueueWithPriority(proxies[0], &model.PushRequest{}, PushPriorityLow)
+		time.Sleep(time.Millisecond * 100)
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{}, PushPriorityHigh)
+		ExpectDequeue(t, p, proxies[0])
+		ExpectDequeue(t, p, proxies[1])
+	})
+
+	t.Run("max delay bypasses budget", func(t *testing.T) {
+		p := NewPriorityPushQueue(map[PushPriority]int{PushPriorityLow: 1}, time.Millisecond*100)
+		defer p.ShutDown()
+		p.EnqueueWithPriority(proxies[0], &model.PushRequest{}, PushPriorityLow)
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{}, PushPriorityLow)
+		ExpectDequeue(t, p, proxies[0])
+		// The low priority budget is used up, but the waiting push is sent once it reaches the max delay,
+		// without any other Enqueue or MarkDone.
+		ExpectDequeue(t, p, proxies[1])
+	})
+
+	t.Run("reprioritize", func(t *testing.T) {
+		p := NewPriorityPushQueue(nil, 0)
+		defer p.ShutDown()
+		requests := make([]*model.PushRequest, 0, 3)
+		for _, con := range proxies[:3] {
+			req := &model.PushRequest{}
+			requests = append(requests, req)
+			p.EnqueueWithPriority(con, req, PushPriorityNormal)
+		}
+		p.Reprioritize(proxies[0], requests[0], PushPriorityLow)
+		p.Reprioritize(proxies[2], requests[2], PushPriorityHigh)
+		// A push merged with another request is not reprioritized
+		p.EnqueueWithPriority(proxies[1], &model.PushRequest{Full: true}, PushPriorityNormal)
+		p.Reprioritize(proxies[1], requests[1], PushPriorityLow)
+		// A push no longer pending is not reprioritized
+		p.Reprioritize(proxies[3], requests[0], PushPriorityHigh)
+		assert.Equal(t, p.Pending(), 3)
+
+		ExpectDequeue(t, p, proxies[2])
+		ExpectDequeue(t, p, proxies[1])
+		ExpectDequeue(t, p, proxies[0])
+	})
+}
+
+func TestPushPriorityReusesProxyNeedsPush(t *testing.T) {
+	calls := 0
+	s := &DiscoveryServer{
+		ProxyNeedsPush: func(proxy *model.Proxy, req *model.PushRequest) (*model.PushRequest, bool) {
+			calls++
+			return req, len(req.ConfigsUpdated) > 0
+		},
+	}
+	con := &Connection{proxy: &model.Proxy{Type: model.SidecarProxy, SidecarScope: &model.SidecarScope{}}}
+	req := &model.PushRequest{Full: true, ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.AuthorizationPolicy, Name: "a"})}
+
+	assert.Equal(t, s.pushPriority(con, req), PushPriorityHigh)
+	_, needsPush := s.proxyNeedsPush(con, req)
+	assert.Equal(t, needsPush, true)
+	assert.Equal(t, calls, 1)
+
+	// The result is only reused for the push it was computed for.
+	_, needsPush = s.proxyNeedsPush(con, req)
+	assert.Equal(t, needsPush, true)
+	assert.Equal(t, calls, 2)
+
+	// A push recomputing the sidecar scope computes it again.
+	assert.Equal(t, s.pushPriority(con, req), PushPriorityHigh)
+	con.proxy.SidecarScope = &model.SidecarScope{}
+	s.proxyNeedsPush(con, req)
+	assert.Equal(t, calls, 4)
+
+	unaffected := &model.PushRequest{Full: true, ConfigsUpdated: sets.New[model.ConfigKey]()}
+	assert.Equal(t, s.pushPriority(con, unaffected), PushPriorityLow)
+}
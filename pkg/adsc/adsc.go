@@ -117,6 +117,9 @@ type Config struct {
 	BackoffPolicy backoff.BackOff
 
 	GrpcOpts []grpc.DialOption
+
+	// Recorder, if set, records every response received from the XDS server.
+	Recorder *Recorder
 }
 
 // ADSConfig for the ADS connection.
@@ -480,6 +483,11 @@ func (a *ADSC) handleRecv() {
 		resourceGvk, isMCP := convertTypeURLToMCPGVK(msg.TypeUrl)
 
 		adscLog.WithLabels("type", msg.TypeUrl, "count", len(msg.Resources), "nonce", msg.Nonce).Info("Received")
+		if a.cfg.Recorder != nil {
+			if err := a.cfg.Recorder.Record(msg); err != nil {
+				adscLog.Warnf("failed to record response: %v", err)
+			}
+		}
 		if a.cfg.ResponseHandler != nil {
 			a.cfg.ResponseHandler.HandleResponse(a, msg)
 		}
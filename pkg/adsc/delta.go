@@ -422,6 +422,11 @@ func (c *Client) handleRecv() error {
 			return err
 		}
 		c.log.WithLabels("type", msg.TypeUrl, "size", len(msg.Resources), "removes", len(msg.RemovedResources)).Infof("received response")
+		if c.cfg.Recorder != nil {
+			if err := c.cfg.Recorder.RecordDelta(msg); err != nil {
+				c.log.Warnf("failed to record response: %v", err)
+			}
+		}
 		if err := c.handleDeltaResponse(msg); err != nil {
 			c.log.WithLabels("type", msg.TypeUrl).Infof("handle response failed: %v", err)
 			if err2 := c.xdsClient.CloseSend(); err2 != nil {
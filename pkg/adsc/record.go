@@ -0,0 +1,180 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adsc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// Record is a single response received from an XDS server, as stored in a recording.
+// Recordings are stored as JSON lines, one Record per line, in the order they were received.
+type Record struct {
+	// Timestamp is the time the response was received.
+	Timestamp time.Time `json:"timestamp"`
+	TypeURL   string    `json:"typeUrl"`
+	Version   string    `json:"version,omitempty"`
+	Nonce     string    `json:"nonce,omitempty"`
+	// Delta is set if Response holds a DeltaDiscoveryResponse rather than a DiscoveryResponse.
+	Delta bool `json:"delta,omitempty"`
+	// Response is the binary encoded response. It is stored in binary form, rather than JSON,
+	// so resources of types unknown to the reader are preserved exactly.
+	Response []byte `json:"response"`
+}
+
+// DiscoveryResponse decodes the recorded state of the world response.
+func (r *Record) DiscoveryResponse() (*discovery.DiscoveryResponse, error) {
+	if r.Delta {
+		return nil, fmt.Errorf("record for %v is a delta response", r.TypeURL)
+	}
+	res := &discovery.DiscoveryResponse{}
+	if err := proto.Unmarshal(r.Response, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeltaDiscoveryResponse decodes the recorded delta response.
+func (r *Record) DeltaDiscoveryResponse() (*discovery.DeltaDiscoveryResponse, error) {
+	if !r.Delta {
+		return nil, fmt.Errorf("record for %v is not a delta response", r.TypeURL)
+	}
+	res := &discovery.DeltaDiscoveryResponse{}
+	if err := proto.Unmarshal(r.Response, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Recorder writes every response received by a client to a recording, which can later be
+// served by a ReplayServer.
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+	// closer is set if the Recorder owns the underlying writer.
+	closer io.Closer
+	// now is used to timestamp records; it is overridden in tests.
+	now func() time.Time
+}
+
+// NewRecorder returns a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w:   w,
+		enc: json.NewEncoder(w),
+		now: time.Now,
+	}
+}
+
+// NewFileRecorder returns a Recorder writing to a new file at path. If the file exists, it is truncated.
+func NewFileRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRecorder(f)
+	r.closer = f
+	return r, nil
+}
+
+// Record stores a state of the world response.
+func (r *Recorder) Record(res *discovery.DiscoveryResponse) error {
+	b, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return r.write(&Record{
+		TypeURL:  res.TypeUrl,
+		Version:  res.VersionInfo,
+		Nonce:    res.Nonce,
+		Response: b,
+	})
+}
+
+// RecordDelta stores a delta response.
+func (r *Recorder) RecordDelta(res *discovery.DeltaDiscoveryResponse) error {
+	b, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return r.write(&Record{
+		TypeURL:  res.TypeUrl,
+		Version:  res.SystemVersionInfo,
+		Nonce:    res.Nonce,
+		Delta:    true,
+		Response: b,
+	})
+}
+
+func (r *Recorder) write(rec *Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec.Timestamp = r.now()
+	return r.enc.Encode(rec)
+}
+
+// Close closes the underlying file, if the Recorder was created with NewFileRecorder.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// ReadRecording reads all records from a recording.
+func ReadRecording(reader io.Reader) ([]*Record, error) {
+	var records []*Record
+	scanner := bufio.NewScanner(reader)
+	// Responses can be very large; do not limit the line size to the default 64k.
+	scanner.Buffer(nil, maxRecordSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return nil, fmt.Errorf("invalid record %d: %v", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// LoadRecording reads all records from the recording file at path.
+func LoadRecording(path string) ([]*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRecording(f)
+}
+
+// maxRecordSize is the largest record ReadRecording accepts.
+const maxRecordSize = defaultClientMaxReceiveMessageSize
@@ -0,0 +1,169 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adsc
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/wellknown"
+)
+
+func startReplayServer(t *testing.T, records []*Record) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	NewReplayServer(records).Register(gs)
+	go func() {
+		_ = gs.Serve(l)
+	}()
+	t.Cleanup(gs.Stop)
+	return l.Addr().String()
+}
+
+func TestRecorderRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := NewRecorder(buf)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	sotw := &discovery.DiscoveryResponse{
+		TypeUrl:     v3.ClusterType,
+		VersionInfo: "v1",
+		Nonce:       "n1",
+		Resources:   []*anypb.Any{protoconv.MessageToAny(&cluster.Cluster{Name: "c1"})},
+	}
+	delta := &discovery.DeltaDiscoveryResponse{
+		TypeUrl:           v3.ListenerType,
+		SystemVersionInfo: "v2",
+		Nonce:             "n2",
+		RemovedResources:  []string{"l1"},
+	}
+	assert.NoError(t, r.Record(sotw))
+	assert.NoError(t, r.RecordDelta(delta))
+
+	records, err := ReadRecording(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, len(records), 2)
+	assert.Equal(t, records[0].Timestamp, now)
+	assert.Equal(t, records[0].Nonce, "n1")
+	assert.Equal(t, records[0].Version, "v1")
+	gotSotw, err := records[0].DiscoveryResponse()
+	assert.NoError(t, err)
+	assert.Equal(t, gotSotw, sotw)
+	_, err = records[0].DeltaDiscoveryResponse()
+	assert.Error(t, err)
+
+	assert.Equal(t, records[1].Delta, true)
+	gotDelta, err := records[1].DeltaDiscoveryResponse()
+	assert.NoError(t, err)
+	assert.Equal(t, gotDelta, delta)
+}
+
+func TestReplayServer(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := NewRecorder(buf)
+	// Listeners are recorded first, but must not be sent until the client requests them.
+	assert.NoError(t, r.Record(&discovery.DiscoveryResponse{
+		TypeUrl: v3.ListenerType,
+		Nonce:   "lds",
+		Resources: []*anypb.Any{protoconv.MessageToAny(&listener.Listener{
+			Name: "l1",
+			FilterChains: []*listener.FilterChain{{
+				Filters: []*listener.Filter{{
+					Name:       wellknown.TCPProxy,
+					ConfigType: &listener.Filter_TypedConfig{TypedConfig: protoconv.MessageToAny(&tcp.TcpProxy{})},
+				}},
+			}},
+		})},
+	}))
+	assert.NoError(t, r.Record(&discovery.DiscoveryResponse{
+		TypeUrl: v3.ClusterType,
+		Nonce:   "cds",
+		Resources: []*anypb.Any{protoconv.MessageToAny(&cluster.Cluster{
+			Name:                 "c1",
+			ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_STATIC},
+		})},
+	}))
+	assert.NoError(t, r.RecordDelta(&discovery.DeltaDiscoveryResponse{
+		TypeUrl: v3.ClusterType,
+		Nonce:   "delta-cds",
+	}))
+	records, err := ReadRecording(buf)
+	assert.NoError(t, err)
+	addr := startReplayServer(t, records)
+
+	t.Run("sotw", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "recording.jsonl")
+		recorder, err := NewFileRecorder(file)
+		assert.NoError(t, err)
+		client, err := New(addr, &ADSConfig{
+			Config: Config{
+				Recorder: recorder,
+			},
+			InitialDiscoveryRequests: []*discovery.DiscoveryRequest{{TypeUrl: v3.ClusterType}},
+		})
+		assert.NoError(t, err)
+		t.Cleanup(client.Close)
+		assert.NoError(t, client.Run())
+		// Clusters are requested first, but were recorded after listeners: both are held back until
+		// listeners are requested, and are then sent in the recorded order.
+		assert.NoError(t, client.Send(&discovery.DiscoveryRequest{TypeUrl: v3.ListenerType}))
+		retry.UntilOrFail(t, func() bool {
+			return len(client.GetClusters()) == 1 && len(client.GetTCPListeners())+len(client.GetHTTPListeners()) == 1
+		}, retry.Timeout(time.Second*5))
+
+		assert.NoError(t, recorder.Close())
+		recorded, err := LoadRecording(file)
+		assert.NoError(t, err)
+		nonces := []string{}
+		for _, r := range recorded {
+			nonces = append(nonces, r.Nonce)
+		}
+		assert.Equal(t, nonces, []string{"lds", "cds"})
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).DeltaAggregatedResources(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType}))
+		res, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, res.Nonce, "delta-cds")
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adsc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/sleep"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+var replayLog = log.RegisterScope("adscreplay", "adsc replay server debugging")
+
+// ReplayServer is an ADS server serving a recording made by a Recorder. Each client stream is sent
+// the recorded responses in their original order: state of the world records on ADS streams, and
+// delta records on Delta ADS streams.
+//
+// A response is held back until the client has requested its type, as the original server would
+// not have sent it earlier, along with all responses recorded after it. Requests and ACKs are
+// otherwise ignored.
+type ReplayServer struct {
+	records []*Record
+
+	// PreserveTiming, if set, delays each response by the time elapsed between it and the
+	// previous response in the recording.
+	PreserveTiming bool
+}
+
+var _ discovery.AggregatedDiscoveryServiceServer = &ReplayServer{}
+
+// NewReplayServer returns a server replaying records.
+func NewReplayServer(records []*Record) *ReplayServer {
+	return &ReplayServer{records: records}
+}
+
+// Register registers the server with a gRPC server.
+func (s *ReplayServer) Register(gs *grpc.Server) {
+	discovery.RegisterAggregatedDiscoveryServiceServer(gs, s)
+}
+
+func (s *ReplayServer) StreamAggregatedResources(stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	records := slices.Filter(s.records, func(r *Record) bool {
+		return !r.Delta
+	})
+	return s.replay(stream.Context(), records, func() (string, error) {
+		req, err := stream.Recv()
+		return req.GetTypeUrl(), err
+	}, func(r *Record) error {
+		res, err := r.DiscoveryResponse()
+		if err != nil {
+			return err
+		}
+		return stream.Send(res)
+	})
+}
+
+func (s *ReplayServer) DeltaAggregatedResources(stream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	records := slices.Filter(s.records, func(r *Record) bool {
+		return r.Delta
+	})
+	return s.replay(stream.Context(), records, func() (string, error) {
+		req, err := stream.Recv()
+		return req.GetTypeUrl(), err
+	}, func(r *Record) error {
+		res, err := r.DeltaDiscoveryResponse()
+		if err != nil {
+			return err
+		}
+		return stream.Send(res)
+	})
+}
+
+// replay sends records in order, stopping at the first record of a type the client has not requested
+// yet until it is requested.
+// recv returns the type URL of the next request. Once all records are sent, the stream is held open
+// until the client closes it.
+func (s *ReplayServer) replay(ctx context.Context, records []*Record, recv func() (string, error), send func(r *Record) error) error {
+	requests := make(chan string)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			typeURL, err := recv()
+			if err != nil {
+				recvErr <- err
+				close(requests)
+				return
+			}
+			select {
+			case requests <- typeURL:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	requested := sets.New[string]()
+	var last *Record
+	for next := 0; next < len(records); {
+		// Records are sent strictly in order: a record of a type not requested yet holds back all later records.
+		for ; next < len(records) && requested.Contains(records[next].TypeURL); next++ {
+			r := records[next]
+			if s.PreserveTiming && last != nil {
+				if !sleep.UntilContext(ctx, r.Timestamp.Sub(last.Timestamp)) {
+					return nil
+				}
+			}
+			replayLog.WithLabels("type", r.TypeURL, "nonce", r.Nonce).Debugf("replaying record %d/%d", next+1, len(records))
+			if err := send(r); err != nil {
+				return err
+			}
+			last = r
+		}
+		if next == len(records) {
+			break
+		}
+		select {
+		case t, ok := <-requests:
+			if !ok {
+				return ignoreEOF(<-recvErr)
+			}
+			requested.Insert(t)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	// Everything is sent; keep the stream open until the client is done with it.
+	for {
+		select {
+		case _, ok := <-requests:
+			if !ok {
+				return ignoreEOF(<-recvErr)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
@@ -18,6 +18,7 @@ import (
 	"istio.io/istio/pkg/config/analysis"
 	"istio.io/istio/pkg/config/analysis/analyzers/annotations"
 	"istio.io/istio/pkg/config/analysis/analyzers/authz"
+	"istio.io/istio/pkg/config/analysis/analyzers/compliance"
 	"istio.io/istio/pkg/config/analysis/analyzers/conditions"
 	"istio.io/istio/pkg/config/analysis/analyzers/deployment"
 	"istio.io/istio/pkg/config/analysis/analyzers/deprecation"
@@ -44,6 +45,7 @@ func All() []analysis.Analyzer {
 		// Please keep this list sorted alphabetically by pkg.name for convenience
 		&annotations.K8sAnalyzer{},
 		&authz.AuthorizationPoliciesAnalyzer{},
+		&compliance.CryptoProfileAnalyzer{},
 		&conditions.ConditionAnalyzer{},
 		&deployment.ServiceAssociationAnalyzer{},
 		&deployment.ApplicationUIDAnalyzer{},
@@ -100,16 +102,21 @@ func AllMultiClusterCombined() analysis.CombinedAnalyzer {
 }
 
 func NamedCombined(names ...string) analysis.CombinedAnalyzer {
-	selected := make([]analysis.Analyzer, 0, len(All()))
+	return SelectedCombined(All(), names...)
+}
+
+// SelectedCombined returns the analyzers with the given names combined as one, or all analyzers if none match.
+func SelectedCombined(all []analysis.Analyzer, names ...string) analysis.CombinedAnalyzer {
+	selected := make([]analysis.Analyzer, 0, len(all))
 	nameSet := sets.New(names...)
-	for _, a := range All() {
+	for _, a := range all {
 		if nameSet.Contains(a.Metadata().Name) {
 			selected = append(selected, a)
 		}
 	}
 
 	if len(selected) == 0 {
-		return AllCombined()
+		return analysis.Combine("all", all...)
 	}
 
 	return analysis.Combine("named", selected...)
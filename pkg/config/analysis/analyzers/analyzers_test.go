@@ -27,6 +27,7 @@ import (
 	"istio.io/istio/pkg/config/analysis"
 	"istio.io/istio/pkg/config/analysis/analyzers/annotations"
 	"istio.io/istio/pkg/config/analysis/analyzers/authz"
+	"istio.io/istio/pkg/config/analysis/analyzers/compliance"
 	"istio.io/istio/pkg/config/analysis/analyzers/conditions"
 	"istio.io/istio/pkg/config/analysis/analyzers/deployment"
 	"istio.io/istio/pkg/config/analysis/analyzers/deprecation"
@@ -49,6 +50,7 @@ import (
 	"istio.io/istio/pkg/config/analysis/local"
 	"istio.io/istio/pkg/config/analysis/msg"
 	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/features"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -989,6 +991,55 @@ var testGrid = []testCase{
 			{msg.ServiceEntryAddressesRequired, "ServiceEntry address-missing-uppercase"},
 		},
 	},
+	{
+		name:           "Crypto profile",
+		inputFiles:     []string{"testdata/compliance-cryptoprofile.yaml"},
+		meshConfigFile: "testdata/compliance-cryptoprofile-meshconfig.yaml",
+		analyzer:       &compliance.CryptoProfileAnalyzer{Policy: features.PQC},
+		expected: []message{
+			{msg.CryptoProfileDeviation, "MeshConfig istio-system/meshconfig"},
+			{msg.CryptoProfileDeviation, "MeshConfig istio-system/meshconfig"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+		},
+	},
+	{
+		name: "Crypto profile of istiod",
+		inputFiles: []string{
+			"testdata/compliance-cryptoprofile.yaml",
+			"testdata/compliance-cryptoprofile-istiod.yaml",
+		},
+		meshConfigFile: "testdata/compliance-cryptoprofile-meshconfig.yaml",
+		analyzer:       &compliance.CryptoProfileAnalyzer{},
+		expected: []message{
+			{msg.CryptoProfileDeviation, "MeshConfig istio-system/meshconfig"},
+			{msg.CryptoProfileDeviation, "MeshConfig istio-system/meshconfig"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+		},
+	},
+	{
+		name:           "Crypto profile without policy",
+		inputFiles:     []string{"testdata/compliance-cryptoprofile.yaml"},
+		meshConfigFile: "testdata/compliance-cryptoprofile-meshconfig.yaml",
+		analyzer:       &compliance.CryptoProfileAnalyzer{},
+		expected:       []message{},
+	},
+	{
+		name:           "Crypto profile fips",
+		inputFiles:     []string{"testdata/compliance-cryptoprofile.yaml"},
+		meshConfigFile: "testdata/compliance-cryptoprofile-meshconfig.yaml",
+		analyzer:       &compliance.CryptoProfileAnalyzer{Policy: features.FIPS_140_2},
+		expected: []message{
+			{msg.CryptoProfileDeviation, "MeshConfig istio-system/meshconfig"},
+			{msg.CryptoProfileDeviation, "MeshConfig istio-system/meshconfig"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/compliant"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+			{msg.CryptoProfileDeviation, "Gateway istio-system/legacy"},
+		},
+	},
 	{
 		name:       "Condition Analyzer",
 		inputFiles: []string{"testdata/condition-analyzer.yaml"},
@@ -0,0 +1,188 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"fmt"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	appsv1 "k8s.io/api/apps/v1"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/model"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// CryptoProfileAnalyzer checks the TLS settings of the gateways, and the mesh TLS defaults applied to all the proxies,
+// comply with the crypto profile of the compliance policy (COMPLIANCE_POLICY) of istiod. Deviating settings are
+// silently overridden by the profile, which is likely not what their author expects.
+type CryptoProfileAnalyzer struct {
+	// Policy is the compliance policy whose crypto profile is checked. If empty, the COMPLIANCE_POLICY of the istiod
+	// deployments is used.
+	Policy string
+}
+
+const (
+	istiodAppLabel         = "istiod"
+	istiodContainerName    = "discovery"
+	compliancePolicyEnvVar = "COMPLIANCE_POLICY"
+)
+
+var _ analysis.Analyzer = &CryptoProfileAnalyzer{}
+
+func (a *CryptoProfileAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "compliance.CryptoProfileAnalyzer",
+		Description: "Checks TLS settings comply with the crypto profile of the compliance policy",
+		Inputs: []config.GroupVersionKind{
+			gvk.Deployment,
+			gvk.Gateway,
+			gvk.MeshConfig,
+		},
+	}
+}
+
+func (a *CryptoProfileAnalyzer) Analyze(c analysis.Context) {
+	policies := []string{a.Policy}
+	if a.Policy == "" {
+		policies = istiodCompliancePolicies(c)
+	}
+	for _, policy := range policies {
+		profile, err := model.GetCryptoProfile(policy)
+		if err != nil || profile == nil {
+			continue
+		}
+		analyzeProfile(c, profile)
+	}
+}
+
+// istiodCompliancePolicies returns the compliance policies set on the istiod deployments, which may differ between
+// revisions. The policy of istio-agent is set from the one of istiod by the injection.
+func istiodCompliancePolicies(c analysis.Context) []string {
+	policies := sets.New[string]()
+	c.ForEach(gvk.Deployment, func(r *resource.Instance) bool {
+		if r.Metadata.Labels["app"] != istiodAppLabel {
+			return true
+		}
+		for _, container := range r.Message.(*appsv1.DeploymentSpec).Template.Spec.Containers {
+			if container.Name != istiodContainerName {
+				continue
+			}
+			for _, env := range container.Env {
+				if env.Name == compliancePolicyEnvVar && env.Value != "" {
+					policies.Insert(env.Value)
+				}
+			}
+		}
+		return true
+	})
+	return sets.SortedList(policies)
+}
+
+func analyzeProfile(c analysis.Context, profile *model.CryptoProfile) {
+	var mesh *resource.Instance
+	c.ForEach(gvk.MeshConfig, func(r *resource.Instance) bool {
+		mesh = r
+		return r.Metadata.FullName.Name != util.MeshConfigName
+	})
+	if mesh != nil {
+		analyzeMeshTLSDefaults(c, mesh, profile)
+	}
+	c.ForEach(gvk.Gateway, func(r *resource.Instance) bool {
+		analyzeGateway(c, r, profile)
+		return true
+	})
+}
+
+func analyzeMeshTLSDefaults(c analysis.Context, r *resource.Instance, profile *model.CryptoProfile) {
+	defaults := r.Message.(*meshconfig.MeshConfig).GetTlsDefaults()
+	if defaults == nil {
+		return
+	}
+	report := func(setting, deviation string) {
+		c.Report(gvk.MeshConfig, msg.NewCryptoProfileDeviation(r, "tlsDefaults."+setting, profile.Name, deviation))
+	}
+	minVersion := tls.TlsParameters_TLS_AUTO
+	switch defaults.GetMinProtocolVersion() {
+	case meshconfig.MeshConfig_TLSConfig_TLSV1_2:
+		minVersion = tls.TlsParameters_TLSv1_2
+	case meshconfig.MeshConfig_TLSConfig_TLSV1_3:
+		minVersion = tls.TlsParameters_TLSv1_3
+	}
+	if deviation := versionDeviation(minVersion, profile); deviation != "" {
+		report("minProtocolVersion", deviation)
+	}
+	for _, curve := range defaults.GetEcdhCurves() {
+		if !profile.AllowsEnvoyCurve(curve) {
+			report("ecdhCurves", fmt.Sprintf("%s is not allowed", curve))
+		}
+	}
+	for _, cipher := range defaults.GetCipherSuites() {
+		if !profile.AllowsEnvoyCipherSuite(cipher) {
+			report("cipherSuites", fmt.Sprintf("%s is not allowed", cipher))
+		}
+	}
+}
+
+func analyzeGateway(c analysis.Context, r *resource.Instance, profile *model.CryptoProfile) {
+	gw := r.Message.(*v1alpha3.Gateway)
+	for i, server := range gw.GetServers() {
+		settings := server.GetTls()
+		// Passthrough servers do not terminate TLS.
+		if settings == nil || settings.GetMode() == v1alpha3.ServerTLSSettings_PASSTHROUGH ||
+			settings.GetMode() == v1alpha3.ServerTLSSettings_AUTO_PASSTHROUGH {
+			continue
+		}
+		report := func(setting, deviation string) {
+			m := msg.NewCryptoProfileDeviation(r, fmt.Sprintf("servers[%d].tls.%s", i, setting), profile.Name, deviation)
+			if line, ok := util.ErrorLine(r, fmt.Sprintf(util.GatewayServerTLS, i, setting)); ok {
+				m.Line = line
+			}
+			c.Report(gvk.Gateway, m)
+		}
+		// The enum of the Gateway TLS versions maps one-to-one to the Envoy one.
+		if deviation := versionDeviation(tls.TlsParameters_TlsProtocol(settings.GetMinProtocolVersion()), profile); deviation != "" {
+			report("minProtocolVersion", deviation)
+		}
+		if deviation := versionDeviation(tls.TlsParameters_TlsProtocol(settings.GetMaxProtocolVersion()), profile); deviation != "" {
+			report("maxProtocolVersion", deviation)
+		}
+		for j, cipher := range settings.GetCipherSuites() {
+			if !profile.AllowsEnvoyCipherSuite(cipher) {
+				report(fmt.Sprintf("cipherSuites[%d]", j), fmt.Sprintf("%s is not allowed", cipher))
+			}
+		}
+	}
+}
+
+// versionDeviation describes why a minimum or maximum TLS version is out of the range of the profile, if it is.
+func versionDeviation(v tls.TlsParameters_TlsProtocol, profile *model.CryptoProfile) string {
+	switch {
+	case v == tls.TlsParameters_TLS_AUTO:
+		return ""
+	case v < profile.EnvoyMinVersion():
+		return fmt.Sprintf("%s is lower than %s", v, profile.EnvoyMinVersion())
+	case profile.EnvoyMaxVersion() != tls.TlsParameters_TLS_AUTO && v > profile.EnvoyMaxVersion():
+		return fmt.Sprintf("%s is higher than %s", v, profile.EnvoyMaxVersion())
+	}
+	return ""
+}
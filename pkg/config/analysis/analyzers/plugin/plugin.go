@@ -0,0 +1,302 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin provides analyzers defined outside of Istio, as CEL expressions evaluated against each resource of a
+// kind. This allows organizations to enforce their own rules on configuration with the same tooling.
+//
+// Analyzers are defined in YAML files:
+//
+//	analyzers:
+//	- name: org.VirtualServiceTimeout
+//	  description: Checks every HTTP route of a VirtualService sets a timeout
+//	  kind: VirtualService
+//	  code: ORG0001
+//	  level: Warning
+//	  condition: 'has(object.spec.http) && object.spec.http.exists(r, !has(r.timeout))'
+//	  messageExpression: '"HTTP routes of " + object.metadata.name + " must set a timeout"'
+//
+// The condition is evaluated with the resource bound to `object`, which has the same structure as the YAML
+// representation of the resource: apiVersion, kind, metadata (name, namespace, labels and annotations), spec and status.
+// A message is reported for each resource for which the condition is true.
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/diag"
+	"istio.io/istio/pkg/config/analysis/scope"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// builtinCodePrefix is the prefix of the codes of Istio messages, which plugin analyzers may not use.
+const builtinCodePrefix = "IST"
+
+// File is the content of an analyzer file.
+type File struct {
+	Analyzers []Definition `json:"analyzers"`
+}
+
+// Definition defines an analyzer.
+type Definition struct {
+	// Name of the analyzer, as used by `istioctl analyze --analyzer`.
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Kind of the analyzed resources. Group is only required if the kind is ambiguous, e.g. for Gateway.
+	Kind  string `json:"kind"`
+	Group string `json:"group,omitempty"`
+	// Code of the reported messages. Codes starting with IST are reserved for Istio.
+	Code string `json:"code"`
+	// Level of the reported messages: one of Error, Warning or Info.
+	Level string `json:"level"`
+	// Condition is a CEL expression, the resource is reported if it evaluates to true.
+	Condition string `json:"condition"`
+	// Message of the reported messages. If MessageExpression is set, it is a CEL expression evaluated to a string instead.
+	Message           string `json:"message,omitempty"`
+	MessageExpression string `json:"messageExpression,omitempty"`
+}
+
+// Analyzer is an analyzer loaded from a Definition.
+type Analyzer struct {
+	metadata          analysis.Metadata
+	input             config.GroupVersionKind
+	messageType       *diag.MessageType
+	condition         cel.Program
+	message           string
+	messageExpression cel.Program
+}
+
+var _ analysis.Analyzer = &Analyzer{}
+
+// Metadata implements Analyzer
+func (a *Analyzer) Metadata() analysis.Metadata {
+	return a.metadata
+}
+
+// MessageType returns the type of the messages reported by the analyzer.
+func (a *Analyzer) MessageType() *diag.MessageType {
+	return a.messageType
+}
+
+// Analyze implements Analyzer
+func (a *Analyzer) Analyze(c analysis.Context) {
+	c.ForEach(a.input, func(r *resource.Instance) bool {
+		obj, err := object(r)
+		if err != nil {
+			scope.Analysis.Warnf("analyzer %s: failed to convert %s: %v", a.metadata.Name, r.Metadata.FullName, err)
+			return true
+		}
+		vars := map[string]any{"object": obj}
+		out, _, err := a.condition.Eval(vars)
+		if err != nil {
+			scope.Analysis.Warnf("analyzer %s: failed to evaluate condition for %s: %v", a.metadata.Name, r.Metadata.FullName, err)
+			return true
+		}
+		if out != types.True {
+			return true
+		}
+		text := a.message
+		if a.messageExpression != nil {
+			out, _, err := a.messageExpression.Eval(vars)
+			if err != nil {
+				scope.Analysis.Warnf("analyzer %s: failed to evaluate message for %s: %v", a.metadata.Name, r.Metadata.FullName, err)
+				return true
+			}
+			text = fmt.Sprint(out.Value())
+		}
+		c.Report(a.input, diag.NewMessage(a.messageType, r, text))
+		return true
+	})
+}
+
+// object returns the resource in the structure of its YAML representation.
+func object(r *resource.Instance) (map[string]any, error) {
+	spec, err := config.ToMap(r.Message)
+	if err != nil {
+		return nil, err
+	}
+	labels, annotations := map[string]any{}, map[string]any{}
+	for k, v := range r.Metadata.Labels {
+		labels[k] = v
+	}
+	for k, v := range r.Metadata.Annotations {
+		annotations[k] = v
+	}
+	obj := map[string]any{
+		"metadata": map[string]any{
+			"name":        r.Metadata.FullName.Name.String(),
+			"namespace":   r.Metadata.FullName.Namespace.String(),
+			"labels":      labels,
+			"annotations": annotations,
+		},
+		"spec": spec,
+	}
+	if s := r.Metadata.Schema; s != nil {
+		obj["apiVersion"] = s.APIVersion()
+		obj["kind"] = s.Kind()
+	}
+	if r.Status != nil {
+		status, err := config.ToMap(r.Status)
+		if err != nil {
+			return nil, err
+		}
+		obj["status"] = status
+	}
+	return obj, nil
+}
+
+var env = func() *cel.Env {
+	e, err := cel.NewEnv(cel.Variable("object", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		panic(err)
+	}
+	return e
+}()
+
+// LoadFiles loads the analyzers defined in the given files.
+func LoadFiles(paths ...string) ([]*Analyzer, error) {
+	var analyzers []*Analyzer
+	names := sets.New[string]()
+	for _, p := range paths {
+		if filepath.Ext(p) == ".rego" {
+			return nil, fmt.Errorf("%s: Rego analyzers are not supported, analyzers must be defined with CEL", p)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		as, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+		for _, a := range as {
+			if names.InsertContains(a.metadata.Name) {
+				return nil, fmt.Errorf("%s: duplicate analyzer %s", p, a.metadata.Name)
+			}
+		}
+		analyzers = append(analyzers, as...)
+	}
+	return analyzers, nil
+}
+
+// Parse parses the analyzers defined in the content of an analyzer file.
+func Parse(data []byte) ([]*Analyzer, error) {
+	f := &File{}
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, err
+	}
+	analyzers := make([]*Analyzer, 0, len(f.Analyzers))
+	for _, d := range f.Analyzers {
+		a, err := New(d)
+		if err != nil {
+			return nil, fmt.Errorf("analyzer %q: %v", d.Name, err)
+		}
+		analyzers = append(analyzers, a)
+	}
+	return analyzers, nil
+}
+
+// New returns the analyzer for a Definition.
+func New(d Definition) (*Analyzer, error) {
+	if d.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if d.Code == "" {
+		return nil, fmt.Errorf("code is required")
+	}
+	if strings.HasPrefix(strings.ToUpper(d.Code), builtinCodePrefix) {
+		return nil, fmt.Errorf("code %s is reserved, codes starting with %s are used by Istio", d.Code, builtinCodePrefix)
+	}
+	level, ok := diag.GetUppercaseStringToLevelMap()[strings.ToUpper(d.Level)]
+	if !ok {
+		return nil, fmt.Errorf("invalid level %q, expected one of %v", d.Level, diag.GetAllLevelStrings())
+	}
+	input, err := resolveKind(d.Group, d.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if (d.Message == "") == (d.MessageExpression == "") {
+		return nil, fmt.Errorf("exactly one of message and messageExpression is required")
+	}
+
+	a := &Analyzer{
+		metadata: analysis.Metadata{
+			Name:        d.Name,
+			Description: d.Description,
+			Inputs:      []config.GroupVersionKind{input},
+		},
+		input:       input,
+		messageType: diag.NewMessageType(level, d.Code, "%s"),
+		message:     d.Message,
+	}
+	if a.condition, err = compile(d.Condition, cel.BoolType); err != nil {
+		return nil, fmt.Errorf("condition: %v", err)
+	}
+	if d.MessageExpression != "" {
+		if a.messageExpression, err = compile(d.MessageExpression, cel.StringType); err != nil {
+			return nil, fmt.Errorf("messageExpression: %v", err)
+		}
+	}
+	return a, nil
+}
+
+func compile(expr string, want *cel.Type) (cel.Program, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+	ast, issues := env.Compile(expr)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if t := ast.OutputType(); !t.IsExactType(want) && !t.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must evaluate to %s, got %s", want, t)
+	}
+	return env.Program(ast)
+}
+
+// resolveKind returns the GroupVersionKind used by the analysis for resources of a kind.
+func resolveKind(group, kind string) (config.GroupVersionKind, error) {
+	if kind == "" {
+		return config.GroupVersionKind{}, fmt.Errorf("kind is required")
+	}
+	var groups []string
+	var found config.GroupVersionKind
+	for _, s := range collections.All.All() {
+		if s.Kind() != kind || (group != "" && s.Group() != group) {
+			continue
+		}
+		if len(groups) == 0 || !sets.New(groups...).Contains(s.Group()) {
+			groups = append(groups, s.Group())
+			found = s.GroupVersionKind()
+		}
+	}
+	switch len(groups) {
+	case 0:
+		return config.GroupVersionKind{}, fmt.Errorf("unknown kind %s", kind)
+	case 1:
+		return found, nil
+	default:
+		return config.GroupVersionKind{}, fmt.Errorf("kind %s is ambiguous, set the group to one of %v", kind, groups)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/diag"
+	"istio.io/istio/pkg/config/analysis/local"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestLoadFiles(t *testing.T) {
+	analyzers, err := LoadFiles("testdata/analyzers.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, len(analyzers), 3)
+
+	a := analyzers[1]
+	assert.Equal(t, a.Metadata().Name, "org.GatewayWildcardHost")
+	assert.Equal(t, a.Metadata().Inputs[0], gvk.Gateway)
+	assert.Equal(t, a.MessageType().Code(), "ORG0002")
+	assert.Equal(t, a.MessageType().Level().String(), diag.Error.String())
+
+	_, err = LoadFiles("testdata/analyzers.yaml", "testdata/analyzers.yaml")
+	assert.Error(t, err)
+	_, err = LoadFiles("policy.rego")
+	assert.Error(t, err)
+}
+
+func TestNewErrors(t *testing.T) {
+	valid := Definition{
+		Name:      "org.Test",
+		Kind:      "VirtualService",
+		Code:      "ORG0001",
+		Level:     "Warning",
+		Condition: "true",
+		Message:   "message",
+	}
+	_, err := New(valid)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		modify func(d *Definition)
+	}{
+		{"no name", func(d *Definition) { d.Name = "" }},
+		{"no code", func(d *Definition) { d.Code = "" }},
+		{"reserved code", func(d *Definition) { d.Code = "IST0101" }},
+		{"invalid level", func(d *Definition) { d.Level = "Fatal" }},
+		{"unknown kind", func(d *Definition) { d.Kind = "Unknown" }},
+		{"ambiguous kind", func(d *Definition) { d.Kind = "Gateway" }},
+		{"no message", func(d *Definition) { d.Message = "" }},
+		{"message and expression", func(d *Definition) { d.MessageExpression = `"message"` }},
+		{"no condition", func(d *Definition) { d.Condition = "" }},
+		{"invalid condition", func(d *Definition) { d.Condition = "object.spec.(" }},
+		{"non boolean condition", func(d *Definition) { d.Condition = `"true"` }},
+		{"non string message", func(d *Definition) { d.Message, d.MessageExpression = "", "1" }},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := valid
+			tt.modify(&d)
+			_, err := New(d)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseUnknownField(t *testing.T) {
+	_, err := Parse([]byte("analyzers:\n- name: org.Test\n  kinds: VirtualService\n"))
+	assert.Error(t, err)
+}
+
+func TestAnalyze(t *testing.T) {
+	analyzers, err := LoadFiles("testdata/analyzers.yaml")
+	assert.NoError(t, err)
+	combined := make([]analysis.Analyzer, 0, len(analyzers))
+	for _, a := range analyzers {
+		combined = append(combined, a)
+	}
+
+	sa := local.NewSourceAnalyzer(analysis.Combine("plugin", combined...), "", "istio-system", nil)
+	f, err := os.Open(filepath.Join("testdata", "resources.yaml"))
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, sa.AddTestReaderKubeSource([]local.ReaderSource{{Name: f.Name(), Reader: f}}))
+	result, err := sa.Analyze(make(chan struct{}))
+	assert.NoError(t, err)
+
+	got := []string{}
+	for _, m := range result.Messages {
+		got = append(got, m.String())
+	}
+	sort.Strings(got)
+	assert.Equal(t, got, []string{
+		"Error [ORG0002] (Gateway default/public testdata/resources.yaml:30) Gateways must not expose wildcard hosts",
+		"Info [ORG0003] (VirtualService default/ratings testdata/resources.yaml:17) VirtualService has no owner label",
+		"Warning [ORG0001] (VirtualService default/ratings testdata/resources.yaml:17) HTTP routes of ratings must set a timeout",
+	})
+}
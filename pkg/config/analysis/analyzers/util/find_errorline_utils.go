@@ -94,6 +94,10 @@ const (
 	// Required parameters: server index.
 	CredentialName = "{.spec.servers[%d].tls.credentialName}"
 
+	// Path for a TLS setting of a Gateway server.
+	// Required parameters: server index, TLS setting name.
+	GatewayServerTLS = "{.spec.servers[%d].tls.%s}"
+
 	// Path for Port in ServiceEntry.
 	// Required parameters: port index.
 	ServiceEntryPort = "{.spec.ports[%d].name}"
@@ -267,6 +267,10 @@ var (
 	// UnknownDestinationRuleHost defines a diag.MessageType for message "UnknownDestinationRuleHost".
 	// Description: Host defined in destination rule does not match any services in the mesh.
 	UnknownDestinationRuleHost = diag.NewMessageType(diag.Warning, "IST0174", "The host %s defined in the DestinationRule does not match any services in the mesh.")
+
+	// CryptoProfileDeviation defines a diag.MessageType for message "CryptoProfileDeviation".
+	// Description: TLS settings deviate from the crypto profile of the compliance policy, which overrides them.
+	CryptoProfileDeviation = diag.NewMessageType(diag.Warning, "IST0175", "The TLS setting %s does not comply with the %q crypto profile and is overridden: %s.")
 )
 
 // All returns a list of all known message types.
@@ -337,6 +341,7 @@ func All() []*diag.MessageType {
 		NegativeConditionStatus,
 		DestinationRuleSubsetNotSelectPods,
 		UnknownDestinationRuleHost,
+		CryptoProfileDeviation,
 	}
 }
 
@@ -978,3 +983,14 @@ func NewUnknownDestinationRuleHost(r *resource.Instance, host string) diag.Messa
 		host,
 	)
 }
+
+// NewCryptoProfileDeviation returns a new diag.Message based on CryptoProfileDeviation.
+func NewCryptoProfileDeviation(r *resource.Instance, setting string, profile string, deviation string) diag.Message {
+	return diag.NewMessage(
+		CryptoProfileDeviation,
+		r,
+		setting,
+		profile,
+		deviation,
+	)
+}
@@ -100,6 +100,17 @@ const (
 	// This is typically set by the downward API
 	PodInfoAnnotationsPath = "./etc/istio/pod/annotations"
 
+	// CertTTLAnnotation on a pod overrides the TTL of the workload certificate requested by its proxy, e.g. "1h".
+	CertTTLAnnotation = "security.istio.io/cert-ttl"
+
+	// CertGracePeriodRatioAnnotation on a pod overrides the ratio of the lifetime of the workload certificate left
+	// when its proxy renews it, e.g. "0.5".
+	CertGracePeriodRatioAnnotation = "security.istio.io/cert-grace-period-ratio"
+
+	// CertGracePeriodRatioJitterAnnotation on a pod overrides the randomness added to the grace period ratio of the
+	// workload certificate of its proxy, to stagger the renewals, e.g. "0.05".
+	CertGracePeriodRatioJitterAnnotation = "security.istio.io/cert-grace-period-ratio-jitter"
+
 	// DefaultServiceAccountName is the default service account to use for remote cluster access.
 	DefaultServiceAccountName = "istio-reader-service-account"
 
@@ -200,6 +211,20 @@ const (
 	// Pods in this state will not egress/ingress traffic until an active ztunnel begins proxying them.
 	AmbientRedirectionPending = "pending"
 
+	// AmbientExcludeInboundPorts on an ambient pod is a comma separated list of inbound TCP ports that are not
+	// redirected to ztunnel, e.g. "5432,6379". This is the ambient equivalent of the sidecar
+	// `traffic.sidecar.istio.io/excludeInboundPorts` annotation. Like the other exclusion annotations, it is read when
+	// the pod is added to the mesh.
+	AmbientExcludeInboundPorts = "ambient.istio.io/exclude-inbound-ports"
+
+	// AmbientExcludeOutboundPorts on an ambient pod is a comma separated list of outbound TCP ports that are not
+	// redirected to ztunnel.
+	AmbientExcludeOutboundPorts = "ambient.istio.io/exclude-outbound-ports"
+
+	// AmbientExcludeOutboundIPRanges on an ambient pod is a comma separated list of destination CIDRs whose
+	// outbound traffic is not redirected to ztunnel, e.g. "10.10.0.0/16".
+	AmbientExcludeOutboundIPRanges = "ambient.istio.io/exclude-outbound-ip-ranges"
+
 	// ServiceTraffic indicates that service traffic should go through the intended waypoint.
 	ServiceTraffic = "service"
 	// WorkloadTraffic indicates that workload traffic should go through the intended waypoint.
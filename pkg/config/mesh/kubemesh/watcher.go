@@ -32,6 +32,10 @@ import (
 const (
 	MeshConfigKey   = "mesh"
 	MeshNetworksKey = "meshNetworks"
+	// CSRAuthorizationKey is the key of the CSR authorization policy of the Istio CA.
+	CSRAuthorizationKey = "csrAuthorization"
+	// SpiffeFederationKey is the key of the trust domains federated with the mesh.
+	SpiffeFederationKey = "spiffeFederation"
 )
 
 // NewConfigMapSource builds a MeshConfigSource reading from ConfigMap "name" with key "key".
@@ -41,6 +41,12 @@ and cipher suites TLS_AES_128_GCM_SHA256 and TLS_AES_256_GCM_SHA384 overriding
 any user preferences or defaults for all runtime components, including Envoy,
 gRPC Go SDK, and gRPC C++ SDK. This policy is experimental.
 
+The crypto profile of the policy applies to the TLS settings generated for
+Envoy, to the xDS, CA and webhook servers of istiod and its config source
+clients, and to the xDS and CA clients of istio-agent. Other Go TLS clients,
+such as the health probes of istio-agent to the application, are not
+restricted. Istiod and istio-agent fail to start with an unknown policy.
+
 WARNING: Setting compliance policy in the control plane is a necessary but
 not a sufficient requirement to achieve compliance. There are additional
 steps necessary to claim compliance, including using the validated
@@ -21,6 +21,7 @@ import (
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/security"
 	citadel "istio.io/istio/security/pkg/nodeagent/caclient/providers/citadel"
+	"istio.io/istio/security/pkg/nodeagent/caclient/providers/est"
 )
 
 // WARNING WARNING WARNING
@@ -65,8 +66,27 @@ func createCitadel(opts *security.Options, a RootCertProvider) (security.Client,
 	return citadel.NewCitadelClient(opts, tlsOpts)
 }
 
+func createEST(opts *security.Options, a RootCertProvider) (security.Client, error) {
+	// The EST server is always reached with TLS, and authenticates the agent with its provisioning
+	// certificate, if any, or the token of the credential fetcher.
+	tlsOpts := &est.TLSOptions{}
+	var err error
+	tlsOpts.RootCert, err = a.FindRootCAForCA()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find root CA cert for CA: %v", err)
+	}
+	if tlsOpts.RootCert == "" {
+		log.Infof("Using EST server %s cert with system certs", opts.CAEndpoint)
+	} else {
+		log.Infof("Using EST server %s cert with certs: %s", opts.CAEndpoint, tlsOpts.RootCert)
+	}
+	tlsOpts.Key, tlsOpts.Cert = a.GetKeyCertsForCA()
+	return est.NewESTClient(opts, tlsOpts)
+}
+
 func init() {
 	providers["Citadel"] = createCitadel
+	providers[security.ESTProvider] = createEST
 }
 
 func createCAClient(opts *security.Options, a RootCertProvider) (security.Client, error) {
@@ -43,6 +43,7 @@ import (
 	istiokeepalive "istio.io/istio/pkg/keepalive"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/model"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/uds"
 	"istio.io/istio/pkg/wasm"
 	xdspkg "istio.io/istio/pkg/xds"
@@ -68,6 +69,12 @@ var connectionNumber = atomic.NewUint32(0)
 // resource.
 type ResponseHandler func(resp *anypb.Any) error
 
+// rootCertAckWaiter is implemented by the SDS servers which report when Envoy applied the trust bundle.
+type rootCertAckWaiter interface {
+	// WaitForRootCertAck waits until Envoy acked the root certificate pushed last. It returns false if stopped before.
+	WaitForRootCertAck(stop <-chan struct{}) bool
+}
+
 // XdsProxy proxies all XDS requests from envoy to istiod, in addition to allowing
 // subsystems inside the agent to also communicate with either istiod/envoy (eg dns, sds, etc).
 // The goal here is to consolidate all xds related connections to istiod/envoy into a
@@ -84,11 +91,14 @@ type XdsProxy struct {
 	optsMutex            sync.RWMutex
 	dialOptions          []grpc.DialOption
 	handlers             map[string]ResponseHandler
-	healthChecker        *health.WorkloadHealthChecker
-	xdsHeaders           map[string]string
-	xdsUdsPath           string
-	proxyAddresses       []string
-	ia                   *Agent
+	// ackWaiters delay the ACK of the responses handled by the agent until they return, e.g. until Envoy applied
+	// them. They return false if the connection stopped before.
+	ackWaiters     map[string]func(stop <-chan struct{}) bool
+	healthChecker  *health.WorkloadHealthChecker
+	xdsHeaders     map[string]string
+	xdsUdsPath     string
+	proxyAddresses []string
+	ia             *Agent
 
 	// connected stores the active gRPC stream. The proxy will only have 1 connection at a time
 	connected                 *ProxyConnection
@@ -138,6 +148,7 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 		istiodSAN:             ia.cfg.IstiodSAN,
 		clusterID:             ia.secOpts.ClusterID,
 		handlers:              map[string]ResponseHandler{},
+		ackWaiters:            map[string]func(stop <-chan struct{}) bool{},
 		stopChan:              make(chan struct{}),
 		healthChecker:         health.NewWorkloadHealthChecker(ia.proxyConfig.ReadinessProbe, envoyProbe, ia.cfg.ProxyIPAddresses, ia.cfg.IsIPv6),
 		xdsHeaders:            ia.cfg.XDSHeaders,
@@ -166,14 +177,31 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 				log.Errorf("failed to unmarshal proxy config: %v", err)
 				return err
 			}
-			caCerts := pc.GetCaCertificatesPem()
+			caCerts, federatedCerts := spiffe.SplitTrustDomainCerts(pc.GetCaCertificatesPem())
 			log.Debugf("received new certificates to add to mesh trust domain: %v", caCerts)
 			trustBundle := []byte{}
 			for _, cert := range caCerts {
 				trustBundle = util.AppendCertByte(trustBundle, []byte(cert))
 			}
+			// The certificates of the federated trust domains only validate the identities of their trust domain.
+			federatedTrustBundles := make(map[string][]byte, len(federatedCerts))
+			for td, certs := range federatedCerts {
+				for _, cert := range certs {
+					federatedTrustBundles[td] = util.AppendCertByte(federatedTrustBundles[td], []byte(cert))
+				}
+			}
+			if err := ia.secretCache.UpdateFederatedTrustBundles(federatedTrustBundles); err != nil {
+				return err
+			}
 			return ia.secretCache.UpdateConfigTrustBundle(trustBundle)
 		}
+		// The trust bundle is acked once Envoy applied it, for istiod to know which proxies trust its roots.
+		proxy.ackWaiters[model.ProxyConfigType] = func(stop <-chan struct{}) bool {
+			if w, ok := ia.sdsServer.(rootCertAckWaiter); ok {
+				return w.WaitForRootCertAck(stop)
+			}
+			return true
+		}
 	}
 
 	proxyLog.Infof("Initializing with upstream address %q and cluster %q", proxy.istiodAddress, proxy.clusterID)
@@ -498,12 +526,21 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 					}
 				}
 				// Send ACK/NACK
-				con.sendRequest(&discovery.DiscoveryRequest{
+				ack := &discovery.DiscoveryRequest{
 					VersionInfo:   resp.VersionInfo,
 					TypeUrl:       resp.TypeUrl,
 					ResponseNonce: resp.Nonce,
 					ErrorDetail:   errorResp,
-				})
+				}
+				if wait := p.ackWaiters[resp.TypeUrl]; wait != nil && err == nil {
+					go func() {
+						if wait(con.stopChan) {
+							con.sendRequest(ack)
+						}
+					}()
+					continue
+				}
+				con.sendRequest(ack)
 				continue
 			}
 			switch resp.TypeUrl {
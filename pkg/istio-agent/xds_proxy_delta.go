@@ -232,11 +232,20 @@ func (p *XdsProxy) handleUpstreamDeltaResponse(con *ProxyConnection) {
 					}
 				}
 				// Send ACK/NACK
-				con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
+				ack := &discovery.DeltaDiscoveryRequest{
 					TypeUrl:       resp.TypeUrl,
 					ResponseNonce: resp.Nonce,
 					ErrorDetail:   errorResp,
-				})
+				}
+				if wait := p.ackWaiters[resp.TypeUrl]; wait != nil && err == nil {
+					go func() {
+						if wait(con.stopChan) {
+							con.sendDeltaRequest(ack)
+						}
+					}()
+					continue
+				}
+				con.sendDeltaRequest(ack)
 				continue
 			}
 			switch resp.TypeUrl {
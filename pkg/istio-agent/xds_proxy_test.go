@@ -37,12 +37,15 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pilot/pkg/trustbundle"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	xdsserver "istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pilot/test/xds"
 	"istio.io/istio/pkg/config"
@@ -70,6 +73,55 @@ func TestXdsProxyBasicFlow(t *testing.T) {
 	})
 }
 
+// Validates the trust bundle is only acked to istiod once applied.
+func TestXdsProxyDelaysTrustBundleAck(t *testing.T) {
+	test.SetForTest(t, &features.MultiRootMesh, true)
+	proxy := setupXdsProxy(t)
+	f := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	f.Discovery.Generators[v3.ProxyConfigType] = &xdsserver.PcdsGenerator{TrustBundle: trustbundle.NewTrustBundle(nil, nil)}
+	setDialOptions(proxy, f.BufListener)
+	applied := make(chan struct{})
+	proxy.handlers[v3.ProxyConfigType] = func(*anypb.Any) error {
+		return nil
+	}
+	proxy.ackWaiters[v3.ProxyConfigType] = func(stop <-chan struct{}) bool {
+		select {
+		case <-applied:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	conn := setupDownstreamConnection(t, proxy)
+	downstream := stream(t, conn)
+	sendDownstreamWithNode(t, downstream, model.NodeMetadata{
+		Namespace:   "default",
+		InstanceIPs: []string{"1.1.1.1"},
+	})
+
+	expectAcks := func(want string) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			acked, total, _ := f.Discovery.TrustBundleAcks(time.Time{})
+			if got := fmt.Sprintf("%d/%d", acked, total); got != want {
+				return fmt.Errorf("got %s trust bundle acks, want %s", got, want)
+			}
+			return nil
+		}, retry.Timeout(5*time.Second))
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		for _, con := range f.Discovery.AllClients() {
+			if w := con.Proxy().GetWatchedResource(v3.ProxyConfigType); w != nil && w.NonceSent != "" {
+				return nil
+			}
+		}
+		return fmt.Errorf("trust bundle not sent")
+	}, retry.Timeout(5*time.Second))
+	expectAcks("0/1")
+	close(applied)
+	expectAcks("1/1")
+}
+
 // Validates the proxy health checking updates
 func TestXdsProxyHealthCheck(t *testing.T) {
 	// TODO: allow fake XDS to be "authenticated"
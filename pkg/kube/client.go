@@ -17,6 +17,7 @@ package kube
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -176,6 +177,12 @@ type CLIClient interface {
 	// AllDiscoveryDo makes a http request to each Istio discovery instance.
 	AllDiscoveryDo(ctx context.Context, namespace, path string) (map[string][]byte, error)
 
+	// AllDiscoveryDoWithTLS makes a https request with the given headers to the given port of each Istio discovery
+	// instance. If the server name of the TLS config is empty, the name of the istiod service of the revision of the
+	// instance is verified.
+	AllDiscoveryDoWithTLS(ctx context.Context, namespace, path string, port int, tlsConfig *tls.Config,
+		header http.Header) (map[string][]byte, error)
+
 	// GetIstioVersions gets the version for each Istio control plane component.
 	GetIstioVersions(ctx context.Context, namespace string) (*version.MeshInfo, error)
 
@@ -922,11 +929,65 @@ func (c *client) AllDiscoveryDo(ctx context.Context, istiodNamespace, path strin
 	return nil, nil
 }
 
+func (c *client) AllDiscoveryDoWithTLS(ctx context.Context, istiodNamespace, path string, port int, tlsConfig *tls.Config,
+	header http.Header,
+) (map[string][]byte, error) {
+	istiods, err := c.GetIstioPods(ctx, istiodNamespace, metav1.ListOptions{
+		LabelSelector: "app=istiod",
+		FieldSelector: RunningStatus,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(istiods) == 0 {
+		return nil, errors.New("unable to find any Istiod instances")
+	}
+
+	result := map[string][]byte{}
+	for _, istiod := range istiods {
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = istiodServiceHost(&istiod)
+		}
+		httpClient := &http.Client{
+			Timeout:   c.http.Timeout,
+			Transport: &http.Transport{TLSClientConfig: config},
+		}
+		res, err := c.portForwardDo(ctx, httpClient, "https", istiod.Name, istiod.Namespace, http.MethodGet, path, port, header)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) > 0 {
+			result[istiod.Name] = res
+		}
+	}
+	if len(result) > 0 {
+		return result, nil
+	}
+	return nil, nil
+}
+
+// istiodServiceHost returns the host of the istiod service of the revision of the istiod pod, which is one of the
+// DNS names of the certificate of istiod.
+func istiodServiceHost(pod *v1.Pod) string {
+	name := "istiod"
+	if rev := pod.GetLabels()[label.IoIstioRev.Name]; rev != "" && rev != "default" {
+		name += "-" + rev
+	}
+	return fmt.Sprintf("%s.%s.svc", name, pod.Namespace)
+}
+
 func (c *client) EnvoyDoWithPort(ctx context.Context, podName, podNamespace, method, path string, port int) ([]byte, error) {
 	return c.portForwardRequest(ctx, podName, podNamespace, method, path, port)
 }
 
 func (c *client) portForwardRequest(ctx context.Context, podName, podNamespace, method, path string, port int) ([]byte, error) {
+	return c.portForwardDo(ctx, c.http, "http", podName, podNamespace, method, path, port, nil)
+}
+
+func (c *client) portForwardDo(ctx context.Context, httpClient *http.Client, scheme, podName, podNamespace, method, path string,
+	port int, header http.Header,
+) ([]byte, error) {
 	formatError := func(err error) error {
 		return fmt.Errorf("failure running port forward process: %v", err)
 	}
@@ -939,11 +1000,14 @@ func (c *client) portForwardRequest(ctx context.Context, podName, podNamespace,
 		return nil, formatError(err)
 	}
 	defer fw.Close()
-	req, err := http.NewRequest(method, fmt.Sprintf("http://%s/%s", fw.Address(), path), nil)
+	req, err := http.NewRequest(method, fmt.Sprintf("%s://%s/%s", scheme, fw.Address(), path), nil)
 	if err != nil {
 		return nil, formatError(err)
 	}
-	resp, err := c.http.Do(req.WithContext(ctx))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, formatError(err)
 	}
@@ -346,9 +346,18 @@ func (h *manyCollection[I, O]) dump() CollectionDump {
 		inputs[string(k)] = cur
 	}
 
+	var indexes map[string]map[string][]string
+	if len(h.indexes) > 0 {
+		indexes = make(map[string]map[string][]string, len(h.indexes))
+		for name, idx := range h.indexes {
+			indexes[name] = dumpIndex(idx.index)
+		}
+	}
+
 	return CollectionDump{
 		Outputs:         eraseMap(h.collectionState.outputs),
 		Inputs:          inputs,
+		Indexes:         indexes,
 		InputCollection: h.parent.(internalCollection[I]).name(),
 		Synced:          h.HasSynced(),
 	}
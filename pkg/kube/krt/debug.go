@@ -17,6 +17,9 @@ package krt
 import (
 	"encoding/json"
 	"sync"
+
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
 )
 
 // DebugHandler allows attaching a variety of collections to it and then dumping them
@@ -40,6 +43,8 @@ type CollectionDump struct {
 	InputCollection string `json:"inputCollection,omitempty"`
 	// Map of input key -> info
 	Inputs map[string]InputDump `json:"inputs,omitempty"`
+	// Map of index name -> index key -> output keys, for the indexes built on the collection.
+	Indexes map[string]map[string][]string `json:"indexes,omitempty"`
 	// Synced returns whether the collection is synced or not
 	Synced bool `json:"synced"`
 }
@@ -76,6 +81,63 @@ func maybeRegisterCollectionForDebugging[T any](c Collection[T], handler *DebugH
 	})
 }
 
+// dumpIndex returns the output keys for each key of an index.
+// nolint: unused // (not true, its used by collection dumps)
+func dumpIndex[K ~string](index map[string]sets.Set[K]) map[string][]string {
+	res := make(map[string][]string, len(index))
+	for k, v := range index {
+		keys := make([]string, 0, len(v))
+		for kk := range v {
+			keys = append(keys, string(kk))
+		}
+		slices.Sort(keys)
+		res[k] = keys
+	}
+	return res
+}
+
+// indexExtractors records the indexes built on a collection that does not keep their state itself, such as an
+// informer, whose indexes are kept by the underlying informer, so that they can be dumped.
+// nolint: unused // (not true, its used by collection dumps)
+type indexExtractors[T any] struct {
+	mu       sync.RWMutex
+	extracts map[string]func(o T) []string
+}
+
+// add records an index. Indexes sharing a name are the same index, so only the first is kept.
+// nolint: unused // (not true, its used by collection dumps)
+func (e *indexExtractors[T]) add(name string, extract func(o T) []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.extracts == nil {
+		e.extracts = map[string]func(o T) []string{}
+	}
+	if _, f := e.extracts[name]; !f {
+		e.extracts[name] = extract
+	}
+}
+
+// dump returns the output keys for each key of each recorded index, over the given objects.
+// nolint: unused // (not true, its used by collection dumps)
+func (e *indexExtractors[T]) dump(objects []T) map[string]map[string][]string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.extracts) == 0 {
+		return nil
+	}
+	res := make(map[string]map[string][]string, len(e.extracts))
+	for name, extract := range e.extracts {
+		index := map[string]sets.Set[Key[T]]{}
+		for _, o := range objects {
+			for _, k := range extract(o) {
+				sets.InsertOrNew(index, k, getTypedKey(o))
+			}
+		}
+		res[name] = dumpIndex(index)
+	}
+	return res
+}
+
 // nolint: unused // (not true, not sure why it thinks it is!)
 func eraseMap[T any](l map[Key[T]]T) map[string]any {
 	nm := make(map[string]any, len(l))
@@ -41,6 +41,8 @@ type informer[I controllers.ComparableObject] struct {
 	synced        chan struct{}
 	baseSyncer    Syncer
 	metadata      Metadata
+	// indexes records the indexes built on the informer, which keeps their state, for dumps.
+	indexes indexExtractors[I]
 }
 
 // nolint: unused // (not true, its to implement an interface)
@@ -73,8 +75,10 @@ func (i *informer[I]) WaitUntilSynced(stop <-chan struct{}) bool {
 
 // nolint: unused // (not true, its to implement an interface)
 func (i *informer[I]) dump() CollectionDump {
+	objects := i.inf.List(metav1.NamespaceAll, klabels.Everything())
 	return CollectionDump{
-		Outputs: eraseMap(slices.GroupUnique(i.inf.List(metav1.NamespaceAll, klabels.Everything()), getTypedKey)),
+		Outputs: eraseMap(slices.GroupUnique(objects, getTypedKey)),
+		Indexes: i.indexes.dump(objects),
 		Synced:  i.HasSynced(),
 	}
 }
@@ -159,6 +163,7 @@ func (ii *informerIndex[I]) Lookup(key string) []I {
 // nolint: unused // (not true)
 func (i *informer[I]) index(name string, extract func(o I) []string) indexer[I] {
 	idx := i.inf.Index(name, extract)
+	i.indexes.add(name, extract)
 	return &informerIndex[I]{
 		idx: idx,
 	}
@@ -31,6 +31,8 @@ type join[T any] struct {
 	uncheckedOverlap bool
 	syncer           Syncer
 	metadata         Metadata
+	// indexes records the indexes built on the join, whose state is kept by the joined collections, for dumps.
+	indexes indexExtractors[T]
 }
 
 func (j *join[T]) GetKey(k string) *T {
@@ -129,8 +131,11 @@ func (j *join[T]) uid() collectionUID { return j.id }
 // nolint: unused // (not true, its to implement an interface)
 func (j *join[I]) dump() CollectionDump {
 	// Dump should not be used on join; instead its preferred to enroll each individual collection. Maybe reconsider
-	// in the future if there is a need
-	return CollectionDump{}
+	// in the future if there is a need.
+	// Indexes built on the join span the joined collections, so they are only dumped here.
+	return CollectionDump{
+		Indexes: j.indexes.dump(j.List()),
+	}
 }
 
 // nolint: unused // (not true)
@@ -157,6 +162,7 @@ func (j joinIndexer[T]) Lookup(key string) []T {
 
 // nolint: unused // (not true, its to implement an interface)
 func (j *join[T]) index(name string, extract func(o T) []string) indexer[T] {
+	j.indexes.add(name, extract)
 	ji := joinIndexer[T]{indexers: make([]indexer[T], 0, len(j.collections))}
 	for _, c := range j.collections {
 		ji.indexers = append(ji.indexers, c.index(name, extract))
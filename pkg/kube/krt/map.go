@@ -30,6 +30,8 @@ type mapCollection[T any, U any] struct {
 	collection     internalCollection[T]
 	mapFunc        func(T) U
 	metadata       Metadata
+	// indexes records the indexes built on the collection, whose state is kept by the mapped collection, for dumps.
+	indexes indexExtractors[U]
 }
 
 // nolint: unused // (not true, used in func declared to implement an interface)
@@ -116,8 +118,10 @@ func (m *mapCollection[T, U]) uid() collectionUID { return m.id }
 
 // nolint: unused // (not true, its to implement an interface)
 func (m *mapCollection[T, U]) dump() CollectionDump {
+	objects := m.List()
 	return CollectionDump{
-		Outputs:         eraseMap(slices.GroupUnique(m.List(), getTypedKey)),
+		Outputs:         eraseMap(slices.GroupUnique(objects, getTypedKey)),
+		Indexes:         m.indexes.dump(objects),
 		Synced:          m.HasSynced(),
 		InputCollection: m.collection.name(),
 	}
@@ -129,6 +133,7 @@ func (m *mapCollection[T, U]) index(name string, extract func(o U) []string) ind
 		return extract(m.mapFunc(o))
 	}
 	idxs := m.collection.index(name, t)
+	m.indexes.add(name, extract)
 	return &mappedIndexer[T, U]{
 		indexer: idxs,
 		mapFunc: m.mapFunc,
@@ -143,6 +143,21 @@ func (j *mergejoin[T]) index(name string, extract func(o T) []string) indexer[T]
 	return idx
 }
 
+// dumpIndexes returns the state of the indexes of the collection.
+// nolint: unused // (not true, its used by collection dumps)
+func (j *mergejoin[T]) dumpIndexes() map[string]map[string][]string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if len(j.indexes) == 0 {
+		return nil
+	}
+	indexes := make(map[string]map[string][]string, len(j.indexes))
+	for name, idx := range j.indexes {
+		indexes[name] = dumpIndex(idx.index)
+	}
+	return indexes
+}
+
 func (j *mergejoin[T]) HasSynced() bool {
 	return j.syncer.HasSynced()
 }
@@ -81,6 +81,7 @@ func (j *nestedjoinmerge[T]) dump() CollectionDump {
 		Outputs: eraseMap(slices.GroupUnique(j.List(), getTypedKey)),
 		Synced:  j.HasSynced(),
 		Inputs:  dumpsByCollectionUID,
+		Indexes: j.dumpIndexes(),
 	}
 }
 
@@ -0,0 +1,205 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package krt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// Snapshot is a point in time copy of the collections registered with a DebugHandler.
+// Unlike the live debug dump, every object is already encoded, so a Snapshot can be persisted and compared with a
+// snapshot taken at another time or by another process with DiffSnapshots.
+//
+// Each collection is copied under its own lock; the snapshot is not atomic across collections.
+type Snapshot struct {
+	Time        time.Time            `json:"time"`
+	Collections []CollectionSnapshot `json:"collections"`
+}
+
+// CollectionSnapshot is the state of a single collection in a Snapshot.
+type CollectionSnapshot struct {
+	// Name of the collection. Collection names are not guaranteed to be unique, so collections sharing a name
+	// are suffixed with #<n>, in registration order.
+	Name            string `json:"name"`
+	InputCollection string `json:"inputCollection,omitempty"`
+	Synced          bool   `json:"synced"`
+	// Map of output key -> encoded output
+	Outputs map[string]json.RawMessage `json:"outputs,omitempty"`
+	// Map of input key -> info
+	Inputs map[string]InputDump `json:"inputs,omitempty"`
+	// Map of index name -> index key -> output keys
+	Indexes map[string]map[string][]string `json:"indexes,omitempty"`
+}
+
+// Snapshot returns a snapshot of the registered collections. If filter is set, only collections whose name it
+// accepts are included.
+func (p *DebugHandler) Snapshot(filter func(name string) bool) (*Snapshot, error) {
+	p.mu.RLock()
+	collections := slices.Clone(p.debugCollections)
+	p.mu.RUnlock()
+
+	snap := &Snapshot{Time: time.Now()}
+	seen := map[string]int{}
+	for _, c := range collections {
+		name := c.name
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = fmt.Sprintf("%s#%d", name, n)
+		}
+		if filter != nil && !filter(name) {
+			continue
+		}
+		d := c.dump()
+		outputs := make(map[string]json.RawMessage, len(d.Outputs))
+		for k, v := range d.Outputs {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("collection %v: encode %v: %v", name, k, err)
+			}
+			outputs[k] = b
+		}
+		snap.Collections = append(snap.Collections, CollectionSnapshot{
+			Name:            name,
+			InputCollection: d.InputCollection,
+			Synced:          d.Synced,
+			Outputs:         outputs,
+			Inputs:          d.Inputs,
+			Indexes:         d.Indexes,
+		})
+	}
+	return snap, nil
+}
+
+// SnapshotDiff is the difference between two snapshots, as returned by DiffSnapshots.
+type SnapshotDiff struct {
+	// Added are the names of collections only present in the second snapshot.
+	Added []string `json:"added,omitempty"`
+	// Removed are the names of collections only present in the first snapshot.
+	Removed []string `json:"removed,omitempty"`
+	// Changed are the collections present in both snapshots, with different state.
+	Changed []CollectionDiff `json:"changed,omitempty"`
+}
+
+// Empty returns true if the snapshots were equivalent.
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// CollectionDiff is the difference between two snapshots of the same collection.
+type CollectionDiff struct {
+	Name string `json:"name"`
+	// Synced is set if the sync state differs, and holds the state in the second snapshot.
+	Synced *bool `json:"synced,omitempty"`
+	// Outputs are the outputs which were added, removed or changed.
+	Outputs []OutputDiff `json:"outputs,omitempty"`
+	// Inputs are the keys of inputs whose outputs or dependencies differ, or which are only present in one snapshot.
+	Inputs []string `json:"inputs,omitempty"`
+	// Indexes are the index entries which differ, as <index name>/<index key>.
+	Indexes []string `json:"indexes,omitempty"`
+}
+
+// OutputDiff is a changed output of a collection.
+type OutputDiff struct {
+	Key string `json:"key"`
+	// Old is the output in the first snapshot, unset if it was added.
+	Old json.RawMessage `json:"old,omitempty"`
+	// New is the output in the second snapshot, unset if it was removed.
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// DiffSnapshots compares two snapshots, which may come from different processes. Collections are matched by name.
+func DiffSnapshots(a, b *Snapshot) SnapshotDiff {
+	as := slices.GroupUnique(a.Collections, func(c CollectionSnapshot) string { return c.Name })
+	bs := slices.GroupUnique(b.Collections, func(c CollectionSnapshot) string { return c.Name })
+	diff := SnapshotDiff{}
+	for _, name := range sets.SortedList(sets.New(maps.Keys(as)...).Union(sets.New(maps.Keys(bs)...))) {
+		ac, inA := as[name]
+		bc, inB := bs[name]
+		switch {
+		case !inA:
+			diff.Added = append(diff.Added, name)
+		case !inB:
+			diff.Removed = append(diff.Removed, name)
+		default:
+			if cd := diffCollection(ac, bc); cd != nil {
+				diff.Changed = append(diff.Changed, *cd)
+			}
+		}
+	}
+	return diff
+}
+
+func diffCollection(a, b CollectionSnapshot) *CollectionDiff {
+	d := &CollectionDiff{Name: a.Name}
+	if a.Synced != b.Synced {
+		d.Synced = &b.Synced
+	}
+	for _, k := range unionKeys(a.Outputs, b.Outputs) {
+		av, bv := a.Outputs[k], b.Outputs[k]
+		if !jsonEqual(av, bv) {
+			d.Outputs = append(d.Outputs, OutputDiff{Key: k, Old: av, New: bv})
+		}
+	}
+	for _, k := range unionKeys(a.Inputs, b.Inputs) {
+		ai, inA := a.Inputs[k]
+		bi, inB := b.Inputs[k]
+		if inA != inB || !inputsEqual(ai, bi) {
+			d.Inputs = append(d.Inputs, k)
+		}
+	}
+	for _, name := range unionKeys(a.Indexes, b.Indexes) {
+		ai, bi := a.Indexes[name], b.Indexes[name]
+		for _, k := range unionKeys(ai, bi) {
+			av, inA := ai[k]
+			bv, inB := bi[k]
+			if inA != inB || !slices.Equal(av, bv) {
+				d.Indexes = append(d.Indexes, name+"/"+k)
+			}
+		}
+	}
+	if d.Synced == nil && len(d.Outputs) == 0 && len(d.Inputs) == 0 && len(d.Indexes) == 0 {
+		return nil
+	}
+	return d
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	return sets.SortedList(sets.New(maps.Keys(a)...).Union(sets.New(maps.Keys(b)...)))
+}
+
+func inputsEqual(a, b InputDump) bool {
+	// Ordering is not meaningful, and some collections do not sort these.
+	return sets.New(a.Outputs...).Equals(sets.New(b.Outputs...)) &&
+		sets.New(a.Dependencies...).Equals(sets.New(b.Dependencies...))
+}
+
+// jsonEqual compares encoded values, ignoring formatting. Snapshots may be indented when persisted.
+func jsonEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var ab, bb bytes.Buffer
+	if json.Compact(&ab, a) != nil || json.Compact(&bb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ab.Bytes(), bb.Bytes())
+}
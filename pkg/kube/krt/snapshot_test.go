@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package krt_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/krt"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestSnapshot(t *testing.T) {
+	debugger := new(krt.DebugHandler)
+	opts := krt.NewOptionsBuilder(test.NewStop(t), "test", debugger)
+	names := krt.NewStaticCollection[Named](nil, []Named{{"ns", "a"}, {"ns", "b"}}, opts.WithName("names")...)
+	krt.NewNamespaceIndex(names)
+	// Collections are matched by name, so one sharing a name must still be distinguishable
+	krt.NewStaticCollection[Named](nil, nil, opts.WithName("names")...)
+	upper := krt.NewCollection(names, func(ctx krt.HandlerContext, n Named) *Named {
+		return &Named{Namespace: n.Namespace, Name: n.Name + "-upper"}
+	}, opts.WithName("upper")...)
+	upper.WaitUntilSynced(test.NewStop(t))
+
+	snapshot := func(filter func(string) bool) *krt.Snapshot {
+		t.Helper()
+		snap, err := debugger.Snapshot(filter)
+		assert.NoError(t, err)
+		// Round trip, as snapshots are compared after being persisted or fetched from another process
+		b, err := json.MarshalIndent(snap, "", "  ")
+		assert.NoError(t, err)
+		res := &krt.Snapshot{}
+		assert.NoError(t, json.Unmarshal(b, res))
+		return res
+	}
+
+	before := snapshot(nil)
+	assert.Equal(t, len(before.Collections), 3)
+	assert.Equal(t, before.Collections[0].Name, "test/names")
+	assert.Equal(t, before.Collections[0].Indexes["namespace"]["ns"], []string{"ns/a", "ns/b"})
+	assert.Equal(t, before.Collections[1].Name, "test/names#2")
+	assert.Equal(t, before.Collections[2].Inputs["ns/a"].Outputs, []string{"ns/a-upper"})
+	assert.Equal(t, krt.DiffSnapshots(before, snapshot(nil)).Empty(), true)
+
+	names.DeleteObject("ns/b")
+	names.UpdateObject(Named{"other", "c"})
+	var after *krt.Snapshot
+	retry.UntilOrFail(t, func() bool {
+		after = snapshot(nil)
+		return len(after.Collections[2].Outputs) == 2 && after.Collections[2].Outputs["other/c-upper"] != nil
+	})
+	diff := krt.DiffSnapshots(before, after)
+	assert.Equal(t, len(diff.Changed), 2)
+	assert.Equal(t, diff.Changed[0].Name, "test/names")
+	assert.Equal(t, len(diff.Changed[0].Outputs), 2)
+	assert.Equal(t, diff.Changed[0].Outputs[0].Key, "ns/b")
+	assert.Equal(t, diff.Changed[0].Outputs[0].New == nil, true)
+	assert.Equal(t, diff.Changed[0].Outputs[1].Key, "other/c")
+	assert.Equal(t, diff.Changed[0].Outputs[1].Old == nil, true)
+	assert.Equal(t, diff.Changed[0].Indexes, []string{"namespace/ns", "namespace/other"})
+	assert.Equal(t, diff.Changed[1].Name, "test/upper")
+	assert.Equal(t, diff.Changed[1].Inputs, []string{"ns/b", "other/c"})
+
+	filtered := snapshot(func(name string) bool { return name == "test/upper" })
+	assert.Equal(t, len(filtered.Collections), 1)
+	diff = krt.DiffSnapshots(before, filtered)
+	assert.Equal(t, diff.Removed, []string{"test/names", "test/names#2"})
+	assert.Equal(t, diff.Changed[0].Name, "test/upper")
+}
+
+func TestSnapshotInformerIndexes(t *testing.T) {
+	stop := test.NewStop(t)
+	debugger := new(krt.DebugHandler)
+	opts := krt.NewOptionsBuilder(stop, "test", debugger)
+	c := kube.NewFakeClient(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "ns"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "other"}},
+	)
+	configMaps := krt.NewInformer[*corev1.ConfigMap](c, opts.WithName("ConfigMaps")...)
+	krt.NewNamespaceIndex(configMaps)
+	c.RunAndWait(stop)
+	configMaps.WaitUntilSynced(stop)
+
+	snap, err := debugger.Snapshot(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, len(snap.Collections), 1)
+	assert.Equal(t, snap.Collections[0].Indexes, map[string]map[string][]string{
+		"namespace": {
+			"ns":    {"ns/a"},
+			"other": {"other/b"},
+		},
+	})
+}
@@ -230,8 +230,18 @@ func (s *staticList[T]) uid() collectionUID {
 
 // nolint: unused // (not true, its to implement an interface)
 func (s *staticList[T]) dump() CollectionDump {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var indexes map[string]map[string][]string
+	if len(s.indexes) > 0 {
+		indexes = make(map[string]map[string][]string, len(s.indexes))
+		for name, idx := range s.indexes {
+			indexes[name] = dumpIndex(idx.index)
+		}
+	}
 	return CollectionDump{
-		Outputs: eraseMap(slices.GroupUnique(s.List(), getTypedKey)),
+		Outputs: eraseMap(slices.GroupUnique(maps.Values(s.vals), getTypedKey)),
+		Indexes: indexes,
 		Synced:  s.HasSynced(),
 	}
 }
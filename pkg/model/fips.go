@@ -16,11 +16,13 @@ package model
 
 import (
 	gotls "crypto/tls"
+	"fmt"
 
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 
 	common_features "istio.io/istio/pkg/features"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/slices"
 )
 
 var fipsCiphers = []string{
@@ -45,68 +47,169 @@ func index(ciphers []string) map[string]struct{} {
 	return out
 }
 
-var fipsCipherIndex = index(fipsCiphers)
+// CryptoProfile is a named set of TLS restrictions. The profile selected by the compliance policy is the single source
+// of truth for the TLS settings of Envoy, and of the Go servers and clients of istiod and istio-agent.
+type CryptoProfile struct {
+	// Name of the profile, as set in COMPLIANCE_POLICY.
+	Name string
+	// MinVersion and MaxVersion are the Go TLS versions. An unset MaxVersion does not restrict the maximum version.
+	MinVersion uint16
+	MaxVersion uint16
+	// CipherSuites are the allowed Go cipher suites.
+	CipherSuites []uint16
+	// EnvoyCipherSuites are the allowed Envoy (BoringSSL/OpenSSL) cipher suites.
+	EnvoyCipherSuites []string
+	// OverrideEnvoyCipherSuites replaces the Envoy cipher suites instead of only filtering them when set.
+	OverrideEnvoyCipherSuites bool
+	// Curves are the Go key exchange mechanisms, in order of preference.
+	Curves []gotls.CurveID
+	// EnvoyCurves are the Envoy key exchange mechanisms. If empty, the Envoy default is used.
+	EnvoyCurves []string
+
+	envoyCipherIndex map[string]struct{}
+}
+
+var cryptoProfiles = map[string]*CryptoProfile{
+	common_features.FIPS_140_2: {
+		Name:              common_features.FIPS_140_2,
+		MinVersion:        gotls.VersionTLS12,
+		MaxVersion:        gotls.VersionTLS12,
+		CipherSuites:      fipsGoCiphers,
+		EnvoyCipherSuites: fipsCiphers,
+		Curves:            []gotls.CurveID{gotls.CurveP256},
+		// Default (unset) is P-256
+		EnvoyCurves:      nil,
+		envoyCipherIndex: index(fipsCiphers),
+	},
+	common_features.PQC: {
+		Name:         common_features.PQC,
+		MinVersion:   gotls.VersionTLS13,
+		CipherSuites: []uint16{gotls.TLS_AES_128_GCM_SHA256, gotls.TLS_AES_256_GCM_SHA384},
+		// Explicit cipher suites for TLS v1.3 are required by Envoy OpenSSL
+		// to ensure that only TLS_AES_128_GCM_SHA256 and TLS_AES_256_GCM_SHA384 are used.
+		EnvoyCipherSuites:         fipsCiphers,
+		OverrideEnvoyCipherSuites: true,
+		Curves:                    []gotls.CurveID{gotls.X25519MLKEM768},
+		EnvoyCurves:               []string{"X25519MLKEM768"},
+		envoyCipherIndex:          index(fipsCiphers),
+	},
+}
+
+// GetCryptoProfile returns the crypto profile of a compliance policy. The empty policy has no profile.
+func GetCryptoProfile(name string) (*CryptoProfile, error) {
+	if name == "" {
+		return nil, nil
+	}
+	p, ok := cryptoProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compliance policy: %q", name)
+	}
+	return p, nil
+}
+
+// ActiveCryptoProfile returns the crypto profile of the configured compliance policy, if any.
+func ActiveCryptoProfile() *CryptoProfile {
+	p, err := GetCryptoProfile(common_features.CompliancePolicy)
+	if err != nil {
+		log.Warn(err)
+	}
+	return p
+}
+
+// AllowsEnvoyCipherSuite returns whether the Envoy cipher suite is allowed by the profile.
+func (p *CryptoProfile) AllowsEnvoyCipherSuite(cipher string) bool {
+	_, ok := p.envoyCipherIndex[cipher]
+	return ok
+}
+
+// envoyCurveNames are the Envoy names of the Go key exchange mechanisms.
+var envoyCurveNames = map[gotls.CurveID]string{
+	gotls.CurveP256:      "P-256",
+	gotls.CurveP384:      "P-384",
+	gotls.CurveP521:      "P-521",
+	gotls.X25519:         "X25519",
+	gotls.X25519MLKEM768: "X25519MLKEM768",
+}
+
+// AllowsEnvoyCurve returns whether the Envoy key exchange mechanism is allowed by the profile.
+func (p *CryptoProfile) AllowsEnvoyCurve(curve string) bool {
+	if len(p.EnvoyCurves) > 0 {
+		return slices.Contains(p.EnvoyCurves, curve)
+	}
+	for _, c := range p.Curves {
+		if envoyCurveNames[c] == curve {
+			return true
+		}
+	}
+	return false
+}
+
+// EnvoyMinVersion returns the minimum TLS version of the profile in Envoy.
+func (p *CryptoProfile) EnvoyMinVersion() tls.TlsParameters_TlsProtocol {
+	return envoyTLSVersion(p.MinVersion)
+}
+
+// EnvoyMaxVersion returns the maximum TLS version of the profile in Envoy, TLS_AUTO if unrestricted.
+func (p *CryptoProfile) EnvoyMaxVersion() tls.TlsParameters_TlsProtocol {
+	return envoyTLSVersion(p.MaxVersion)
+}
+
+func envoyTLSVersion(v uint16) tls.TlsParameters_TlsProtocol {
+	switch v {
+	case gotls.VersionTLS10:
+		return tls.TlsParameters_TLSv1_0
+	case gotls.VersionTLS11:
+		return tls.TlsParameters_TLSv1_1
+	case gotls.VersionTLS12:
+		return tls.TlsParameters_TLSv1_2
+	case gotls.VersionTLS13:
+		return tls.TlsParameters_TLSv1_3
+	default:
+		return tls.TlsParameters_TLS_AUTO
+	}
+}
 
 // EnforceGoCompliance limits the TLS settings to the compliant values.
 // This should be called as the last policy.
 func EnforceGoCompliance(ctx *gotls.Config) {
-	switch common_features.CompliancePolicy {
-	case "":
-		return
-	case common_features.FIPS_140_2:
-		ctx.MinVersion = gotls.VersionTLS12
-		ctx.MaxVersion = gotls.VersionTLS12
-		ctx.CipherSuites = fipsGoCiphers
-		ctx.CurvePreferences = []gotls.CurveID{gotls.CurveP256}
-		return
-	case common_features.PQC:
-		ctx.MinVersion = gotls.VersionTLS13
-		ctx.CipherSuites = []uint16{gotls.TLS_AES_128_GCM_SHA256, gotls.TLS_AES_256_GCM_SHA384}
-		ctx.CurvePreferences = []gotls.CurveID{gotls.X25519MLKEM768}
-	default:
-		log.Warnf("unknown compliance policy: %q", common_features.CompliancePolicy)
+	p := ActiveCryptoProfile()
+	if p == nil {
 		return
 	}
+	ctx.MinVersion = p.MinVersion
+	if p.MaxVersion != 0 {
+		ctx.MaxVersion = p.MaxVersion
+	}
+	ctx.CipherSuites = p.CipherSuites
+	ctx.CurvePreferences = p.Curves
 }
 
 // EnforceCompliance limits the TLS settings to the compliant values.
 // This should be called as the last policy.
 func EnforceCompliance(ctx *tls.CommonTlsContext) {
-	switch common_features.CompliancePolicy {
-	case "":
+	p := ActiveCryptoProfile()
+	if p == nil {
 		return
-	case common_features.FIPS_140_2:
-		if ctx.TlsParams == nil {
-			ctx.TlsParams = &tls.TlsParameters{}
-		}
-		ctx.TlsParams.TlsMinimumProtocolVersion = tls.TlsParameters_TLSv1_2
-		ctx.TlsParams.TlsMaximumProtocolVersion = tls.TlsParameters_TLSv1_2
+	}
+	if ctx.TlsParams == nil {
+		ctx.TlsParams = &tls.TlsParameters{}
+	}
+	ctx.TlsParams.TlsMinimumProtocolVersion = p.EnvoyMinVersion()
+	if p.MaxVersion != 0 {
+		ctx.TlsParams.TlsMaximumProtocolVersion = p.EnvoyMaxVersion()
+	}
+	if p.OverrideEnvoyCipherSuites {
+		ctx.TlsParams.CipherSuites = p.EnvoyCipherSuites
+	} else if len(ctx.TlsParams.CipherSuites) > 0 {
 		// Default (unset) cipher suites field in the FIPS build of Envoy uses only the FIPS ciphers.
 		// Therefore, we only filter this field when it is set.
-		if len(ctx.TlsParams.CipherSuites) > 0 {
-			ciphers := []string{}
-			for _, cipher := range ctx.TlsParams.CipherSuites {
-				if _, ok := fipsCipherIndex[cipher]; ok {
-					ciphers = append(ciphers, cipher)
-				}
+		ciphers := []string{}
+		for _, cipher := range ctx.TlsParams.CipherSuites {
+			if p.AllowsEnvoyCipherSuite(cipher) {
+				ciphers = append(ciphers, cipher)
 			}
-			ctx.TlsParams.CipherSuites = ciphers
-		}
-		// Default (unset) is P-256
-		ctx.TlsParams.EcdhCurves = nil
-		return
-	case common_features.PQC:
-		if ctx.TlsParams == nil {
-			ctx.TlsParams = &tls.TlsParameters{}
 		}
-		ctx.TlsParams.TlsMinimumProtocolVersion = tls.TlsParameters_TLSv1_3
-		// Explicit cipher suites for TLS v1.3 are required by Envoy OpenSSL
-		// to ensure that only TLS_AES_128_GCM_SHA256 and TLS_AES_256_GCM_SHA384 are used.
-		ctx.TlsParams.CipherSuites = fipsCiphers
-		ctx.TlsParams.EcdhCurves = []string{"X25519MLKEM768"}
-		return
-	default:
-		log.Warnf("unknown compliance policy: %q", common_features.CompliancePolicy)
-		return
+		ctx.TlsParams.CipherSuites = ciphers
 	}
+	ctx.TlsParams.EcdhCurves = p.EnvoyCurves
 }
@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model_test
+
+import (
+	gotls "crypto/tls"
+	"testing"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	"istio.io/istio/pkg/features"
+	"istio.io/istio/pkg/model"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestGetCryptoProfile(t *testing.T) {
+	p, err := model.GetCryptoProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, p, nil)
+
+	p, err = model.GetCryptoProfile(features.PQC)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Name, features.PQC)
+	assert.Equal(t, p.EnvoyMinVersion(), tls.TlsParameters_TLSv1_3)
+	assert.Equal(t, p.EnvoyMaxVersion(), tls.TlsParameters_TLS_AUTO)
+	assert.Equal(t, p.AllowsEnvoyCurve("X25519MLKEM768"), true)
+	assert.Equal(t, p.AllowsEnvoyCurve("X25519"), false)
+
+	p, err = model.GetCryptoProfile(features.FIPS_140_2)
+	assert.NoError(t, err)
+	assert.Equal(t, p.AllowsEnvoyCurve("P-256"), true)
+	assert.Equal(t, p.AllowsEnvoyCipherSuite("ECDHE-RSA-AES128-GCM-SHA256"), true)
+	assert.Equal(t, p.AllowsEnvoyCipherSuite("AES256-SHA"), false)
+
+	_, err = model.GetCryptoProfile("fips")
+	assert.Error(t, err)
+}
+
+func TestEnforceGoCompliance(t *testing.T) {
+	cases := []struct {
+		policy string
+		want   *gotls.Config
+	}{
+		{
+			policy: "",
+			want:   &gotls.Config{MinVersion: gotls.VersionTLS12, CipherSuites: []uint16{gotls.TLS_RSA_WITH_AES_128_CBC_SHA}},
+		},
+		{
+			policy: features.FIPS_140_2,
+			want: &gotls.Config{
+				MinVersion: gotls.VersionTLS12,
+				MaxVersion: gotls.VersionTLS12,
+				CipherSuites: []uint16{
+					gotls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+					gotls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+					gotls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+					gotls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+				},
+				CurvePreferences: []gotls.CurveID{gotls.CurveP256},
+			},
+		},
+		{
+			policy: features.PQC,
+			want: &gotls.Config{
+				MinVersion:       gotls.VersionTLS13,
+				CipherSuites:     []uint16{gotls.TLS_AES_128_GCM_SHA256, gotls.TLS_AES_256_GCM_SHA384},
+				CurvePreferences: []gotls.CurveID{gotls.X25519MLKEM768},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.policy, func(t *testing.T) {
+			test.SetForTest(t, &features.CompliancePolicy, tt.policy)
+			cfg := &gotls.Config{MinVersion: gotls.VersionTLS12, CipherSuites: []uint16{gotls.TLS_RSA_WITH_AES_128_CBC_SHA}}
+			model.EnforceGoCompliance(cfg)
+			assert.Equal(t, cfg.MinVersion, tt.want.MinVersion)
+			assert.Equal(t, cfg.MaxVersion, tt.want.MaxVersion)
+			assert.Equal(t, cfg.CipherSuites, tt.want.CipherSuites)
+			assert.Equal(t, cfg.CurvePreferences, tt.want.CurvePreferences)
+		})
+	}
+}
+
+func TestEnforceCompliance(t *testing.T) {
+	userParams := func() *tls.TlsParameters {
+		return &tls.TlsParameters{
+			TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_0,
+			CipherSuites:              []string{"ECDHE-RSA-AES128-GCM-SHA256", "AES256-SHA"},
+			EcdhCurves:                []string{"X25519"},
+		}
+	}
+	cases := []struct {
+		policy string
+		want   *tls.TlsParameters
+	}{
+		{
+			policy: "",
+			want:   userParams(),
+		},
+		{
+			policy: features.FIPS_140_2,
+			want: &tls.TlsParameters{
+				TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_2,
+				TlsMaximumProtocolVersion: tls.TlsParameters_TLSv1_2,
+				CipherSuites:              []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+			},
+		},
+		{
+			policy: features.PQC,
+			want: &tls.TlsParameters{
+				TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_3,
+				CipherSuites: []string{
+					"ECDHE-ECDSA-AES128-GCM-SHA256",
+					"ECDHE-RSA-AES128-GCM-SHA256",
+					"ECDHE-ECDSA-AES256-GCM-SHA384",
+					"ECDHE-RSA-AES256-GCM-SHA384",
+				},
+				EcdhCurves: []string{"X25519MLKEM768"},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.policy, func(t *testing.T) {
+			test.SetForTest(t, &features.CompliancePolicy, tt.policy)
+			ctx := &tls.CommonTlsContext{TlsParams: userParams()}
+			model.EnforceCompliance(ctx)
+			assert.Equal(t, ctx.TlsParams, tt.want)
+		})
+	}
+}
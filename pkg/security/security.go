@@ -111,6 +111,14 @@ const (
 	// JWT is a Credential fetcher type that reads from a JWT token file
 	JWT = "JWT"
 
+	// TokenExchange is a Credential fetcher type that exchanges a local token with an OAuth 2.0 token exchange (RFC 8693)
+	// service
+	TokenExchange = "TokenExchange"
+
+	// RolesAnywhere is a Credential fetcher type that creates sessions with an IAM Roles Anywhere style service,
+	// authenticated by a X.509 certificate
+	RolesAnywhere = "RolesAnywhere"
+
 	// Mock is Credential fetcher type of mock plugin
 	Mock = "Mock" // testing only
 
@@ -123,6 +131,9 @@ const (
 	// GkeWorkloadCertificateProvider uses the GKE workload certificates
 	GkeWorkloadCertificateProvider = "GkeWorkloadCertificate"
 
+	// ESTProvider uses an Enrollment over Secure Transport (RFC 7030) server for workload certificate signing
+	ESTProvider = "EST"
+
 	// FileRootSystemCACert is a unique resource name signaling that the system CA certificate should be used
 	FileRootSystemCACert = "file-root:system"
 
@@ -235,6 +246,13 @@ type Options struct {
 	// their certs simultaneously.
 	SecretRotationGracePeriodRatioJitter float64
 
+	// The initial interval of the exponential backoff retrying the renewal of the workload certificate after a
+	// failure. The current certificate is served until it expires in the meantime.
+	SecretRenewalBackoffInitialInterval time.Duration
+
+	// The max interval of the exponential backoff retrying the renewal of the workload certificate.
+	SecretRenewalBackoffMaxInterval time.Duration
+
 	// STS port
 	STSPort int
 
@@ -303,6 +321,10 @@ type SecretItem struct {
 
 	RootCert []byte
 
+	// TrustDomainRootCerts are the root certificates of each trust domain, set only when the mesh is federated with
+	// other trust domains: the peers are then validated against the root certificates of their own trust domain.
+	TrustDomainRootCerts map[string][]byte
+
 	// ResourceName passed from envoy SDS discovery request.
 	// "ROOTCA" for root cert request, "default" for key/cert request.
 	ResourceName string
@@ -379,6 +401,8 @@ type KubernetesInfo struct {
 	PodNamespace      string
 	PodUID            string
 	PodServiceAccount string
+	// NodeName is the node the pod runs on, if known.
+	NodeName string
 }
 
 func (k KubernetesInfo) String() string {
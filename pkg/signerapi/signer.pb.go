@@ -0,0 +1,472 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: signerapi/signer.proto
+
+// GRPC package - part of the URL. Service is added.
+// URL: /PACKAGE.SERVICE/METHOD
+
+package signerapi
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type HashAlgorithm int32
+
+const (
+	HashAlgorithm_HASH_ALGORITHM_UNSPECIFIED HashAlgorithm = 0
+	HashAlgorithm_SHA256                     HashAlgorithm = 1
+	HashAlgorithm_SHA384                     HashAlgorithm = 2
+	HashAlgorithm_SHA512                     HashAlgorithm = 3
+)
+
+// Enum value maps for HashAlgorithm.
+var (
+	HashAlgorithm_name = map[int32]string{
+		0: "HASH_ALGORITHM_UNSPECIFIED",
+		1: "SHA256",
+		2: "SHA384",
+		3: "SHA512",
+	}
+	HashAlgorithm_value = map[string]int32{
+		"HASH_ALGORITHM_UNSPECIFIED": 0,
+		"SHA256":                     1,
+		"SHA384":                     2,
+		"SHA512":                     3,
+	}
+)
+
+func (x HashAlgorithm) Enum() *HashAlgorithm {
+	p := new(HashAlgorithm)
+	*p = x
+	return p
+}
+
+func (x HashAlgorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HashAlgorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_signerapi_signer_proto_enumTypes[0].Descriptor()
+}
+
+func (HashAlgorithm) Type() protoreflect.EnumType {
+	return &file_signerapi_signer_proto_enumTypes[0]
+}
+
+func (x HashAlgorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HashAlgorithm.Descriptor instead.
+func (HashAlgorithm) EnumDescriptor() ([]byte, []int) {
+	return file_signerapi_signer_proto_rawDescGZIP(), []int{0}
+}
+
+// The padding of the signatures of RSA keys, ignored for ECDSA keys.
+type RSAPadding int32
+
+const (
+	RSAPadding_PKCS1V15 RSAPadding = 0
+	RSAPadding_PSS      RSAPadding = 1
+)
+
+// Enum value maps for RSAPadding.
+var (
+	RSAPadding_name = map[int32]string{
+		0: "PKCS1V15",
+		1: "PSS",
+	}
+	RSAPadding_value = map[string]int32{
+		"PKCS1V15": 0,
+		"PSS":      1,
+	}
+)
+
+func (x RSAPadding) Enum() *RSAPadding {
+	p := new(RSAPadding)
+	*p = x
+	return p
+}
+
+func (x RSAPadding) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RSAPadding) Descriptor() protoreflect.EnumDescriptor {
+	return file_signerapi_signer_proto_enumTypes[1].Descriptor()
+}
+
+func (RSAPadding) Type() protoreflect.EnumType {
+	return &file_signerapi_signer_proto_enumTypes[1]
+}
+
+func (x RSAPadding) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RSAPadding.Descriptor instead.
+func (RSAPadding) EnumDescriptor() ([]byte, []int) {
+	return file_signerapi_signer_proto_rawDescGZIP(), []int{1}
+}
+
+type GetPublicKeyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the key.
+	KeyName       string `protobuf:"bytes,1,opt,name=key_name,json=keyName,proto3" json:"key_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPublicKeyRequest) Reset() {
+	*x = GetPublicKeyRequest{}
+	mi := &file_signerapi_signer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPublicKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPublicKeyRequest) ProtoMessage() {}
+
+func (x *GetPublicKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_signerapi_signer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPublicKeyRequest.ProtoReflect.Descriptor instead.
+func (*GetPublicKeyRequest) Descriptor() ([]byte, []int) {
+	return file_signerapi_signer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetPublicKeyRequest) GetKeyName() string {
+	if x != nil {
+		return x.KeyName
+	}
+	return ""
+}
+
+type GetPublicKeyResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The DER encoded PKIX public key.
+	PublicKey []byte `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// The version of the key, which changes when the key is rotated.
+	KeyVersion    string `protobuf:"bytes,2,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPublicKeyResponse) Reset() {
+	*x = GetPublicKeyResponse{}
+	mi := &file_signerapi_signer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPublicKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPublicKeyResponse) ProtoMessage() {}
+
+func (x *GetPublicKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_signerapi_signer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPublicKeyResponse.ProtoReflect.Descriptor instead.
+func (*GetPublicKeyResponse) Descriptor() ([]byte, []int) {
+	return file_signerapi_signer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetPublicKeyResponse) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *GetPublicKeyResponse) GetKeyVersion() string {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return ""
+}
+
+type SignRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the key.
+	KeyName string `protobuf:"bytes,1,opt,name=key_name,json=keyName,proto3" json:"key_name,omitempty"`
+	// The version of the key returned by GetPublicKey. The previous versions of a key must remain
+	// usable for some time after a rotation, until the certificate of the CA is updated.
+	KeyVersion string `protobuf:"bytes,2,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	// The digest to sign.
+	Digest []byte `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+	// The hash algorithm of the digest.
+	Hash HashAlgorithm `protobuf:"varint,4,opt,name=hash,proto3,enum=istio.security.signer.v1alpha1.HashAlgorithm" json:"hash,omitempty"`
+	// The padding of the signatures of RSA keys.
+	Padding RSAPadding `protobuf:"varint,5,opt,name=padding,proto3,enum=istio.security.signer.v1alpha1.RSAPadding" json:"padding,omitempty"`
+	// The salt length of PSS signatures. The length of the digest is used when unset.
+	PssSaltLength int32 `protobuf:"varint,6,opt,name=pss_salt_length,json=pssSaltLength,proto3" json:"pss_salt_length,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignRequest) Reset() {
+	*x = SignRequest{}
+	mi := &file_signerapi_signer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignRequest) ProtoMessage() {}
+
+func (x *SignRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_signerapi_signer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignRequest.ProtoReflect.Descriptor instead.
+func (*SignRequest) Descriptor() ([]byte, []int) {
+	return file_signerapi_signer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SignRequest) GetKeyName() string {
+	if x != nil {
+		return x.KeyName
+	}
+	return ""
+}
+
+func (x *SignRequest) GetKeyVersion() string {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return ""
+}
+
+func (x *SignRequest) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *SignRequest) GetHash() HashAlgorithm {
+	if x != nil {
+		return x.Hash
+	}
+	return HashAlgorithm_HASH_ALGORITHM_UNSPECIFIED
+}
+
+func (x *SignRequest) GetPadding() RSAPadding {
+	if x != nil {
+		return x.Padding
+	}
+	return RSAPadding_PKCS1V15
+}
+
+func (x *SignRequest) GetPssSaltLength() int32 {
+	if x != nil {
+		return x.PssSaltLength
+	}
+	return 0
+}
+
+type SignResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The signature: an ASN.1 DER encoded signature for ECDSA keys, the raw signature for RSA keys.
+	Signature     []byte `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignResponse) Reset() {
+	*x = SignResponse{}
+	mi := &file_signerapi_signer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignResponse) ProtoMessage() {}
+
+func (x *SignResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_signerapi_signer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignResponse.ProtoReflect.Descriptor instead.
+func (*SignResponse) Descriptor() ([]byte, []int) {
+	return file_signerapi_signer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SignResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+var File_signerapi_signer_proto protoreflect.FileDescriptor
+
+const file_signerapi_signer_proto_rawDesc = "" +
+	"\n" +
+	"\x16signerapi/signer.proto\x12\x1eistio.security.signer.v1alpha1\"0\n" +
+	"\x13GetPublicKeyRequest\x12\x19\n" +
+	"\bkey_name\x18\x01 \x01(\tR\akeyName\"V\n" +
+	"\x14GetPublicKeyResponse\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x01 \x01(\fR\tpublicKey\x12\x1f\n" +
+	"\vkey_version\x18\x02 \x01(\tR\n" +
+	"keyVersion\"\x92\x02\n" +
+	"\vSignRequest\x12\x19\n" +
+	"\bkey_name\x18\x01 \x01(\tR\akeyName\x12\x1f\n" +
+	"\vkey_version\x18\x02 \x01(\tR\n" +
+	"keyVersion\x12\x16\n" +
+	"\x06digest\x18\x03 \x01(\fR\x06digest\x12A\n" +
+	"\x04hash\x18\x04 \x01(\x0e2-.istio.security.signer.v1alpha1.HashAlgorithmR\x04hash\x12D\n" +
+	"\apadding\x18\x05 \x01(\x0e2*.istio.security.signer.v1alpha1.RSAPaddingR\apadding\x12&\n" +
+	"\x0fpss_salt_length\x18\x06 \x01(\x05R\rpssSaltLength\",\n" +
+	"\fSignResponse\x12\x1c\n" +
+	"\tsignature\x18\x01 \x01(\fR\tsignature*S\n" +
+	"\rHashAlgorithm\x12\x1e\n" +
+	"\x1aHASH_ALGORITHM_UNSPECIFIED\x10\x00\x12\n" +
+	"\n" +
+	"\x06SHA256\x10\x01\x12\n" +
+	"\n" +
+	"\x06SHA384\x10\x02\x12\n" +
+	"\n" +
+	"\x06SHA512\x10\x03*#\n" +
+	"\n" +
+	"RSAPadding\x12\f\n" +
+	"\bPKCS1V15\x10\x00\x12\a\n" +
+	"\x03PSS\x10\x012\xed\x01\n" +
+	"\tKeySigner\x12{\n" +
+	"\fGetPublicKey\x123.istio.security.signer.v1alpha1.GetPublicKeyRequest\x1a4.istio.security.signer.v1alpha1.GetPublicKeyResponse\"\x00\x12c\n" +
+	"\x04Sign\x12+.istio.security.signer.v1alpha1.SignRequest\x1a,.istio.security.signer.v1alpha1.SignResponse\"\x00B\x0fZ\rpkg/signerapib\x06proto3"
+
+var (
+	file_signerapi_signer_proto_rawDescOnce sync.Once
+	file_signerapi_signer_proto_rawDescData []byte
+)
+
+func file_signerapi_signer_proto_rawDescGZIP() []byte {
+	file_signerapi_signer_proto_rawDescOnce.Do(func() {
+		file_signerapi_signer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_signerapi_signer_proto_rawDesc), len(file_signerapi_signer_proto_rawDesc)))
+	})
+	return file_signerapi_signer_proto_rawDescData
+}
+
+var file_signerapi_signer_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_signerapi_signer_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_signerapi_signer_proto_goTypes = []any{
+	(HashAlgorithm)(0),           // 0: istio.security.signer.v1alpha1.HashAlgorithm
+	(RSAPadding)(0),              // 1: istio.security.signer.v1alpha1.RSAPadding
+	(*GetPublicKeyRequest)(nil),  // 2: istio.security.signer.v1alpha1.GetPublicKeyRequest
+	(*GetPublicKeyResponse)(nil), // 3: istio.security.signer.v1alpha1.GetPublicKeyResponse
+	(*SignRequest)(nil),          // 4: istio.security.signer.v1alpha1.SignRequest
+	(*SignResponse)(nil),         // 5: istio.security.signer.v1alpha1.SignResponse
+}
+var file_signerapi_signer_proto_depIdxs = []int32{
+	0, // 0: istio.security.signer.v1alpha1.SignRequest.hash:type_name -> istio.security.signer.v1alpha1.HashAlgorithm
+	1, // 1: istio.security.signer.v1alpha1.SignRequest.padding:type_name -> istio.security.signer.v1alpha1.RSAPadding
+	2, // 2: istio.security.signer.v1alpha1.KeySigner.GetPublicKey:input_type -> istio.security.signer.v1alpha1.GetPublicKeyRequest
+	4, // 3: istio.security.signer.v1alpha1.KeySigner.Sign:input_type -> istio.security.signer.v1alpha1.SignRequest
+	3, // 4: istio.security.signer.v1alpha1.KeySigner.GetPublicKey:output_type -> istio.security.signer.v1alpha1.GetPublicKeyResponse
+	5, // 5: istio.security.signer.v1alpha1.KeySigner.Sign:output_type -> istio.security.signer.v1alpha1.SignResponse
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_signerapi_signer_proto_init() }
+func file_signerapi_signer_proto_init() {
+	if File_signerapi_signer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_signerapi_signer_proto_rawDesc), len(file_signerapi_signer_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_signerapi_signer_proto_goTypes,
+		DependencyIndexes: file_signerapi_signer_proto_depIdxs,
+		EnumInfos:         file_signerapi_signer_proto_enumTypes,
+		MessageInfos:      file_signerapi_signer_proto_msgTypes,
+	}.Build()
+	File_signerapi_signer_proto = out.File
+	file_signerapi_signer_proto_goTypes = nil
+	file_signerapi_signer_proto_depIdxs = nil
+}
@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// BundleEndpointProfileWeb is the SPIFFE bundle endpoint profile authenticating the endpoint with Web PKI.
+	BundleEndpointProfileWeb = "https_web"
+	// BundleEndpointProfileSPIFFE is the SPIFFE bundle endpoint profile authenticating the endpoint with its SPIFFE ID,
+	// using the bundle of the trust domain of the ID.
+	BundleEndpointProfileSPIFFE = "https_spiffe"
+
+	// maxBundleSize bounds the size of the bundles fetched from bundle endpoints.
+	maxBundleSize = 1 << 20
+
+	// TrustDomainPEMHeader is the PEM header of a CA certificate of the trust bundle which only validates the
+	// identities of one trust domain, e.g. of a federated trust domain.
+	TrustDomainPEMHeader = "Trust-Domain"
+)
+
+// Bundle is the SPIFFE bundle of a trust domain. Only its X.509 authorities are kept.
+type Bundle struct {
+	TrustDomain     string
+	X509Authorities []*x509.Certificate
+	// Sequence is the spiffe_sequence of the bundle, or 0 if unset.
+	Sequence uint64
+	// RefreshHint is the spiffe_refresh_hint of the bundle, or 0 if unset.
+	RefreshHint time.Duration
+}
+
+// PEM returns the X.509 authorities of the bundle in PEM format.
+func (b *Bundle) PEM() []string {
+	certs := make([]string, 0, len(b.X509Authorities))
+	for _, cert := range b.X509Authorities {
+		certs = append(certs, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	}
+	return certs
+}
+
+// CertPool returns a pool of the X.509 authorities of the bundle.
+func (b *Bundle) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range b.X509Authorities {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+// ParseBundle parses the SPIFFE bundle of a trust domain, a JWKS document. The keys of unknown use are ignored, as
+// required by the SPIFFE trust domain and bundle specification.
+func ParseBundle(trustDomain string, data []byte) (*Bundle, error) {
+	doc := new(bundleDoc)
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("trust domain [%s] failed to decode bundle: %v", trustDomain, err)
+	}
+	bundle := &Bundle{
+		TrustDomain: trustDomain,
+		Sequence:    doc.Sequence,
+		RefreshHint: time.Duration(doc.RefreshHint) * time.Second,
+	}
+	for i, key := range doc.Keys {
+		switch key.Use {
+		case "x509-svid":
+			if len(key.Certificates) != 1 {
+				return nil, fmt.Errorf("trust domain [%s] expected 1 certificate in x509-svid entry %d; got %d",
+					trustDomain, i, len(key.Certificates))
+			}
+			if !key.Certificates[0].IsCA {
+				return nil, fmt.Errorf("trust domain [%s] x509-svid entry %d is not a CA certificate", trustDomain, i)
+			}
+			bundle.X509Authorities = append(bundle.X509Authorities, key.Certificates[0])
+		case "jwt-svid":
+			if key.KeyID == "" {
+				return nil, fmt.Errorf("trust domain [%s] jwt-svid entry %d has no key ID", trustDomain, i)
+			}
+		}
+	}
+	if len(bundle.X509Authorities) == 0 {
+		return nil, fmt.Errorf("trust domain [%s] bundle does not provide a X509 SVID authority", trustDomain)
+	}
+	return bundle, nil
+}
+
+// BundleEndpoint is the SPIFFE bundle endpoint serving the bundle of a trust domain.
+type BundleEndpoint struct {
+	URL string
+	// Profile is either BundleEndpointProfileWeb or BundleEndpointProfileSPIFFE.
+	Profile string
+	// SPIFFEID is the SPIFFE ID of the endpoint, for the https_spiffe profile.
+	SPIFFEID string
+}
+
+// FetchBundle fetches the bundle of a trust domain from its bundle endpoint. With the https_web profile, the endpoint
+// is authenticated by the roots, or by the system roots if nil. With the https_spiffe profile, the roots are the
+// X.509 authorities of the trust domain of the SPIFFE ID of the endpoint, and the endpoint must present that ID.
+func FetchBundle(ctx context.Context, trustDomain string, endpoint BundleEndpoint, roots *x509.CertPool) (*Bundle, error) {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid bundle endpoint URL %q: must be an https URL", endpoint.URL)
+	}
+	config := &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+	switch endpoint.Profile {
+	case BundleEndpointProfileWeb:
+	case BundleEndpointProfileSPIFFE:
+		if roots == nil {
+			return nil, fmt.Errorf("no bundle to authenticate the endpoint %s", endpoint.SPIFFEID)
+		}
+		// The endpoint is authenticated by its SPIFFE ID rather than its host name, in VerifyConnection.
+		config.InsecureSkipVerify = true // nolint: gosec
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyEndpointSPIFFEID(cs.PeerCertificates, roots, endpoint.SPIFFEID)
+		}
+	default:
+		return nil, fmt.Errorf("unknown bundle endpoint profile %q", endpoint.Profile)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     config,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the bundle of trust domain [%s] from %s: %v", trustDomain, endpoint.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBundleSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the bundle of trust domain [%s] from %s: %v", trustDomain, endpoint.URL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the bundle of trust domain [%s] from %s: unexpected status %d",
+			trustDomain, endpoint.URL, resp.StatusCode)
+	}
+	return ParseBundle(trustDomain, body)
+}
+
+// verifyEndpointSPIFFEID verifies the chain presented by an https_spiffe bundle endpoint, and its SPIFFE ID.
+func verifyEndpointSPIFFEID(chain []*x509.Certificate, roots *x509.CertPool, id string) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("bundle endpoint did not present a certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return err
+	}
+	if len(chain[0].URIs) != 1 || chain[0].URIs[0].String() != id {
+		return fmt.Errorf("bundle endpoint does not present the SPIFFE ID %s", id)
+	}
+	return nil
+}
+
+// EncodeTrustDomainCerts returns PEM certificates bound to a trust domain, with the trust domain in their
+// TrustDomainPEMHeader.
+func EncodeTrustDomainCerts(trustDomain string, certs []string) []string {
+	res := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		block, _ := pem.Decode([]byte(cert))
+		if block == nil {
+			continue
+		}
+		res = append(res, string(pem.EncodeToMemory(&pem.Block{
+			Type:    block.Type,
+			Headers: map[string]string{TrustDomainPEMHeader: trustDomain},
+			Bytes:   block.Bytes,
+		})))
+	}
+	return res
+}
+
+// SplitTrustDomainCerts splits PEM certificates into the ones which are not bound to a trust domain, and the ones
+// bound to a trust domain by EncodeTrustDomainCerts, keyed by trust domain and without their header.
+func SplitTrustDomainCerts(certs []string) ([]string, map[string][]string) {
+	var unbound []string
+	bound := map[string][]string{}
+	for _, cert := range certs {
+		block, _ := pem.Decode([]byte(cert))
+		if block == nil || block.Headers[TrustDomainPEMHeader] == "" {
+			unbound = append(unbound, cert)
+			continue
+		}
+		td := block.Headers[TrustDomainPEMHeader]
+		bound[td] = append(bound[td], string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes})))
+	}
+	return unbound, bound
+}
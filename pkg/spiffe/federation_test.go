@@ -0,0 +1,217 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+
+	"istio.io/istio/pilot/test/util"
+)
+
+func readCert(t *testing.T, file string) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(util.ReadFile(t, file))
+	if block == nil {
+		t.Fatalf("failed to decode %s", file)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func genBundle(t *testing.T, sequence uint64, refreshHint int, keys ...jose.JSONWebKey) []byte {
+	t.Helper()
+	b, err := json.Marshal(bundleDoc{JSONWebKeySet: jose.JSONWebKeySet{Keys: keys}, Sequence: sequence, RefreshHint: refreshHint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func x509Key(cert *x509.Certificate) jose.JSONWebKey {
+	return jose.JSONWebKey{Key: cert.PublicKey, Certificates: []*x509.Certificate{cert}, Use: "x509-svid"}
+}
+
+func TestParseBundle(t *testing.T) {
+	root := readCert(t, validRootCertFile1)
+	workload := readCert(t, validWorkloadCertFile)
+	jwtKey := jose.JSONWebKey{Key: root.PublicKey, KeyID: "jwt", Use: "jwt-svid"}
+
+	bundle, err := ParseBundle("foo.domain.com", genBundle(t, 3, 300, x509Key(root), jwtKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.TrustDomain != "foo.domain.com" || bundle.Sequence != 3 || bundle.RefreshHint != 5*time.Minute {
+		t.Errorf("unexpected bundle %+v", bundle)
+	}
+	if len(bundle.X509Authorities) != 1 || !bundle.X509Authorities[0].Equal(root) {
+		t.Errorf("unexpected X.509 authorities %v", bundle.X509Authorities)
+	}
+	if pems := bundle.PEM(); len(pems) != 1 || !strings.HasPrefix(pems[0], "-----BEGIN CERTIFICATE-----") {
+		t.Errorf("unexpected PEM %v", pems)
+	}
+
+	cases := []struct {
+		name    string
+		bundle  []byte
+		wantErr string
+	}{
+		{name: "invalid json", bundle: []byte("{"), wantErr: "failed to decode bundle"},
+		{name: "no x509 authority", bundle: genBundle(t, 0, 0, jwtKey), wantErr: "does not provide a X509 SVID authority"},
+		{name: "not a CA", bundle: genBundle(t, 0, 0, x509Key(workload)), wantErr: "is not a CA certificate"},
+		{
+			name:    "jwt key without ID",
+			bundle:  genBundle(t, 0, 0, x509Key(root), jose.JSONWebKey{Key: root.PublicKey, Use: "jwt-svid"}),
+			wantErr: "has no key ID",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBundle("foo.domain.com", tt.bundle)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchBundle(t *testing.T) {
+	root := readCert(t, validRootCertFile1)
+	body := genBundle(t, 1, 0, x509Key(root))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write(body)
+	})
+
+	// https_web: the endpoint is authenticated with its host name.
+	web := httptest.NewTLSServer(handler)
+	defer web.Close()
+	webRoots := x509.NewCertPool()
+	webRoots.AddCert(web.Certificate())
+
+	// https_spiffe: the endpoint is authenticated with its SPIFFE ID.
+	keyBlock, _ := pem.Decode(util.ReadFile(t, validWorkloadKeyFile))
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffeServer := httptest.NewUnstartedServer(handler)
+	spiffeServer.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{readCert(t, validWorkloadCertFile).Raw, readCert(t, validIntCertFile).Raw},
+			PrivateKey:  key,
+		}},
+		MinVersion: tls.VersionTLS12,
+	}
+	spiffeServer.StartTLS()
+	defer spiffeServer.Close()
+	spiffeRoots := x509.NewCertPool()
+	spiffeRoots.AddCert(root)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(readCert(t, validRootCertFile2))
+
+	endpointID := "spiffe://foo.domain.com/ns/foo/sa/default"
+	cases := []struct {
+		name     string
+		endpoint BundleEndpoint
+		roots    *x509.CertPool
+		wantErr  string
+	}{
+		{
+			name:     "https_web",
+			endpoint: BundleEndpoint{URL: web.URL, Profile: BundleEndpointProfileWeb},
+			roots:    webRoots,
+		},
+		{
+			name:     "https_web untrusted",
+			endpoint: BundleEndpoint{URL: web.URL, Profile: BundleEndpointProfileWeb},
+			roots:    spiffeRoots,
+			wantErr:  "certificate signed by unknown authority",
+		},
+		{
+			name:     "https_spiffe",
+			endpoint: BundleEndpoint{URL: spiffeServer.URL, Profile: BundleEndpointProfileSPIFFE, SPIFFEID: endpointID},
+			roots:    spiffeRoots,
+		},
+		{
+			name:     "https_spiffe unexpected ID",
+			endpoint: BundleEndpoint{URL: spiffeServer.URL, Profile: BundleEndpointProfileSPIFFE, SPIFFEID: "spiffe://foo.domain.com/spire/server"},
+			roots:    spiffeRoots,
+			wantErr:  "does not present the SPIFFE ID",
+		},
+		{
+			name:     "https_spiffe untrusted",
+			endpoint: BundleEndpoint{URL: spiffeServer.URL, Profile: BundleEndpointProfileSPIFFE, SPIFFEID: endpointID},
+			roots:    otherRoots,
+			wantErr:  "certificate signed by unknown authority",
+		},
+		{
+			name:     "http",
+			endpoint: BundleEndpoint{URL: strings.Replace(web.URL, "https", "http", 1), Profile: BundleEndpointProfileWeb},
+			wantErr:  "must be an https URL",
+		},
+		{
+			name:     "unknown profile",
+			endpoint: BundleEndpoint{URL: web.URL, Profile: "https"},
+			wantErr:  "unknown bundle endpoint profile",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle, err := FetchBundle(context.Background(), "foo.domain.com", tt.endpoint, tt.roots)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bundle.Sequence != 1 || len(bundle.X509Authorities) != 1 || !bundle.X509Authorities[0].Equal(root) {
+				t.Errorf("unexpected bundle %+v", bundle)
+			}
+		})
+	}
+}
+
+func TestSplitTrustDomainCerts(t *testing.T) {
+	root1 := (&Bundle{X509Authorities: []*x509.Certificate{readCert(t, validRootCertFile1)}}).PEM()
+	root2 := (&Bundle{X509Authorities: []*x509.Certificate{readCert(t, validRootCertFile2)}}).PEM()
+
+	bound := EncodeTrustDomainCerts("foo.domain.com", root2)
+	if len(bound) != 1 || !strings.Contains(bound[0], TrustDomainPEMHeader+": foo.domain.com") {
+		t.Fatalf("unexpected bound certificates %v", bound)
+	}
+	unbound, byTrustDomain := SplitTrustDomainCerts(append(root1, bound...))
+	if len(unbound) != 1 || unbound[0] != root1[0] {
+		t.Errorf("unexpected unbound certificates %v", unbound)
+	}
+	if len(byTrustDomain) != 1 || len(byTrustDomain["foo.domain.com"]) != 1 || byTrustDomain["foo.domain.com"][0] != root2[0] {
+		t.Errorf("unexpected certificates by trust domain %v", byTrustDomain)
+	}
+}
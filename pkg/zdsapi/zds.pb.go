@@ -133,8 +133,10 @@ type WorkloadInfo struct {
 	Name           string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Namespace      string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	ServiceAccount string                 `protobuf:"bytes,3,opt,name=service_account,json=serviceAccount,proto3" json:"service_account,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	// The traffic of the workload excluded from the capture, which never reaches ztunnel.
+	CaptureExclusions *CaptureExclusions `protobuf:"bytes,5,opt,name=capture_exclusions,json=captureExclusions,proto3" json:"capture_exclusions,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *WorkloadInfo) Reset() {
@@ -188,6 +190,77 @@ func (x *WorkloadInfo) GetServiceAccount() string {
 	return ""
 }
 
+func (x *WorkloadInfo) GetCaptureExclusions() *CaptureExclusions {
+	if x != nil {
+		return x.CaptureExclusions
+	}
+	return nil
+}
+
+// The traffic of a workload excluded from the capture by its annotations.
+type CaptureExclusions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Inbound TCP ports that are not redirected to ztunnel.
+	InboundPorts []uint32 `protobuf:"varint,1,rep,packed,name=inbound_ports,json=inboundPorts,proto3" json:"inbound_ports,omitempty"`
+	// Outbound TCP ports that are not redirected to ztunnel.
+	OutboundPorts []uint32 `protobuf:"varint,2,rep,packed,name=outbound_ports,json=outboundPorts,proto3" json:"outbound_ports,omitempty"`
+	// Outbound destination CIDRs that are not redirected to ztunnel.
+	OutboundIpRanges []string `protobuf:"bytes,3,rep,name=outbound_ip_ranges,json=outboundIpRanges,proto3" json:"outbound_ip_ranges,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *CaptureExclusions) Reset() {
+	*x = CaptureExclusions{}
+	mi := &file_zdsapi_zds_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CaptureExclusions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CaptureExclusions) ProtoMessage() {}
+
+func (x *CaptureExclusions) ProtoReflect() protoreflect.Message {
+	mi := &file_zdsapi_zds_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CaptureExclusions.ProtoReflect.Descriptor instead.
+func (*CaptureExclusions) Descriptor() ([]byte, []int) {
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CaptureExclusions) GetInboundPorts() []uint32 {
+	if x != nil {
+		return x.InboundPorts
+	}
+	return nil
+}
+
+func (x *CaptureExclusions) GetOutboundPorts() []uint32 {
+	if x != nil {
+		return x.OutboundPorts
+	}
+	return nil
+}
+
+func (x *CaptureExclusions) GetOutboundIpRanges() []string {
+	if x != nil {
+		return x.OutboundIpRanges
+	}
+	return nil
+}
+
 // Add a workload to the ztunnel. this will be accompanied by ancillary data containing
 // the workload's netns file descriptor.
 type AddWorkload struct {
@@ -200,7 +273,7 @@ type AddWorkload struct {
 
 func (x *AddWorkload) Reset() {
 	*x = AddWorkload{}
-	mi := &file_zdsapi_zds_proto_msgTypes[2]
+	mi := &file_zdsapi_zds_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -212,7 +285,7 @@ func (x *AddWorkload) String() string {
 func (*AddWorkload) ProtoMessage() {}
 
 func (x *AddWorkload) ProtoReflect() protoreflect.Message {
-	mi := &file_zdsapi_zds_proto_msgTypes[2]
+	mi := &file_zdsapi_zds_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -225,7 +298,7 @@ func (x *AddWorkload) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddWorkload.ProtoReflect.Descriptor instead.
 func (*AddWorkload) Descriptor() ([]byte, []int) {
-	return file_zdsapi_zds_proto_rawDescGZIP(), []int{2}
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *AddWorkload) GetUid() string {
@@ -253,7 +326,7 @@ type KeepWorkload struct {
 
 func (x *KeepWorkload) Reset() {
 	*x = KeepWorkload{}
-	mi := &file_zdsapi_zds_proto_msgTypes[3]
+	mi := &file_zdsapi_zds_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -265,7 +338,7 @@ func (x *KeepWorkload) String() string {
 func (*KeepWorkload) ProtoMessage() {}
 
 func (x *KeepWorkload) ProtoReflect() protoreflect.Message {
-	mi := &file_zdsapi_zds_proto_msgTypes[3]
+	mi := &file_zdsapi_zds_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -278,7 +351,7 @@ func (x *KeepWorkload) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeepWorkload.ProtoReflect.Descriptor instead.
 func (*KeepWorkload) Descriptor() ([]byte, []int) {
-	return file_zdsapi_zds_proto_rawDescGZIP(), []int{3}
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *KeepWorkload) GetUid() string {
@@ -298,7 +371,7 @@ type DelWorkload struct {
 
 func (x *DelWorkload) Reset() {
 	*x = DelWorkload{}
-	mi := &file_zdsapi_zds_proto_msgTypes[4]
+	mi := &file_zdsapi_zds_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -310,7 +383,7 @@ func (x *DelWorkload) String() string {
 func (*DelWorkload) ProtoMessage() {}
 
 func (x *DelWorkload) ProtoReflect() protoreflect.Message {
-	mi := &file_zdsapi_zds_proto_msgTypes[4]
+	mi := &file_zdsapi_zds_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -323,7 +396,7 @@ func (x *DelWorkload) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DelWorkload.ProtoReflect.Descriptor instead.
 func (*DelWorkload) Descriptor() ([]byte, []int) {
-	return file_zdsapi_zds_proto_rawDescGZIP(), []int{4}
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *DelWorkload) GetUid() string {
@@ -343,7 +416,7 @@ type SnapshotSent struct {
 
 func (x *SnapshotSent) Reset() {
 	*x = SnapshotSent{}
-	mi := &file_zdsapi_zds_proto_msgTypes[5]
+	mi := &file_zdsapi_zds_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -355,7 +428,7 @@ func (x *SnapshotSent) String() string {
 func (*SnapshotSent) ProtoMessage() {}
 
 func (x *SnapshotSent) ProtoReflect() protoreflect.Message {
-	mi := &file_zdsapi_zds_proto_msgTypes[5]
+	mi := &file_zdsapi_zds_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -368,7 +441,7 @@ func (x *SnapshotSent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SnapshotSent.ProtoReflect.Descriptor instead.
 func (*SnapshotSent) Descriptor() ([]byte, []int) {
-	return file_zdsapi_zds_proto_rawDescGZIP(), []int{5}
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{6}
 }
 
 // Ztunnel ack message. If error is not empty, this is an error message.
@@ -381,7 +454,7 @@ type Ack struct {
 
 func (x *Ack) Reset() {
 	*x = Ack{}
-	mi := &file_zdsapi_zds_proto_msgTypes[6]
+	mi := &file_zdsapi_zds_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -393,7 +466,7 @@ func (x *Ack) String() string {
 func (*Ack) ProtoMessage() {}
 
 func (x *Ack) ProtoReflect() protoreflect.Message {
-	mi := &file_zdsapi_zds_proto_msgTypes[6]
+	mi := &file_zdsapi_zds_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -406,7 +479,7 @@ func (x *Ack) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Ack.ProtoReflect.Descriptor instead.
 func (*Ack) Descriptor() ([]byte, []int) {
-	return file_zdsapi_zds_proto_rawDescGZIP(), []int{6}
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *Ack) GetError() string {
@@ -432,7 +505,7 @@ type WorkloadRequest struct {
 
 func (x *WorkloadRequest) Reset() {
 	*x = WorkloadRequest{}
-	mi := &file_zdsapi_zds_proto_msgTypes[7]
+	mi := &file_zdsapi_zds_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -444,7 +517,7 @@ func (x *WorkloadRequest) String() string {
 func (*WorkloadRequest) ProtoMessage() {}
 
 func (x *WorkloadRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_zdsapi_zds_proto_msgTypes[7]
+	mi := &file_zdsapi_zds_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -457,7 +530,7 @@ func (x *WorkloadRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WorkloadRequest.ProtoReflect.Descriptor instead.
 func (*WorkloadRequest) Descriptor() ([]byte, []int) {
-	return file_zdsapi_zds_proto_rawDescGZIP(), []int{7}
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *WorkloadRequest) GetPayload() isWorkloadRequest_Payload {
@@ -544,7 +617,7 @@ type WorkloadResponse struct {
 
 func (x *WorkloadResponse) Reset() {
 	*x = WorkloadResponse{}
-	mi := &file_zdsapi_zds_proto_msgTypes[8]
+	mi := &file_zdsapi_zds_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -556,7 +629,7 @@ func (x *WorkloadResponse) String() string {
 func (*WorkloadResponse) ProtoMessage() {}
 
 func (x *WorkloadResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_zdsapi_zds_proto_msgTypes[8]
+	mi := &file_zdsapi_zds_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -569,7 +642,7 @@ func (x *WorkloadResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WorkloadResponse.ProtoReflect.Descriptor instead.
 func (*WorkloadResponse) Descriptor() ([]byte, []int) {
-	return file_zdsapi_zds_proto_rawDescGZIP(), []int{8}
+	return file_zdsapi_zds_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *WorkloadResponse) GetPayload() isWorkloadResponse_Payload {
@@ -604,11 +677,16 @@ const file_zdsapi_zds_proto_rawDesc = "" +
 	"\n" +
 	"\x10zdsapi/zds.proto\x12\x12istio.workload.zds\"A\n" +
 	"\bZdsHello\x125\n" +
-	"\aversion\x18\x01 \x01(\x0e2\x1b.istio.workload.zds.VersionR\aversion\"}\n" +
+	"\aversion\x18\x01 \x01(\x0e2\x1b.istio.workload.zds.VersionR\aversion\"\xd3\x01\n" +
 	"\fWorkloadInfo\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
 	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12'\n" +
-	"\x0fservice_account\x18\x03 \x01(\tR\x0eserviceAccountJ\x04\b\x04\x10\x05R\ftrust_domain\"f\n" +
+	"\x0fservice_account\x18\x03 \x01(\tR\x0eserviceAccount\x12T\n" +
+	"\x12capture_exclusions\x18\x05 \x01(\v2%.istio.workload.zds.CaptureExclusionsR\x11captureExclusionsJ\x04\b\x04\x10\x05R\ftrust_domain\"\x8d\x01\n" +
+	"\x11CaptureExclusions\x12#\n" +
+	"\rinbound_ports\x18\x01 \x03(\rR\finboundPorts\x12%\n" +
+	"\x0eoutbound_ports\x18\x02 \x03(\rR\routboundPorts\x12,\n" +
+	"\x12outbound_ip_ranges\x18\x03 \x03(\tR\x10outboundIpRanges\"f\n" +
 	"\vAddWorkload\x12\x10\n" +
 	"\x03uid\x18\x01 \x01(\tR\x03uid\x12E\n" +
 	"\rworkload_info\x18\x02 \x01(\v2 .istio.workload.zds.WorkloadInfoR\fworkloadInfo\" \n" +
@@ -646,32 +724,34 @@ func file_zdsapi_zds_proto_rawDescGZIP() []byte {
 }
 
 var file_zdsapi_zds_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_zdsapi_zds_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_zdsapi_zds_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_zdsapi_zds_proto_goTypes = []any{
-	(Version)(0),             // 0: istio.workload.zds.Version
-	(*ZdsHello)(nil),         // 1: istio.workload.zds.ZdsHello
-	(*WorkloadInfo)(nil),     // 2: istio.workload.zds.WorkloadInfo
-	(*AddWorkload)(nil),      // 3: istio.workload.zds.AddWorkload
-	(*KeepWorkload)(nil),     // 4: istio.workload.zds.KeepWorkload
-	(*DelWorkload)(nil),      // 5: istio.workload.zds.DelWorkload
-	(*SnapshotSent)(nil),     // 6: istio.workload.zds.SnapshotSent
-	(*Ack)(nil),              // 7: istio.workload.zds.Ack
-	(*WorkloadRequest)(nil),  // 8: istio.workload.zds.WorkloadRequest
-	(*WorkloadResponse)(nil), // 9: istio.workload.zds.WorkloadResponse
+	(Version)(0),              // 0: istio.workload.zds.Version
+	(*ZdsHello)(nil),          // 1: istio.workload.zds.ZdsHello
+	(*WorkloadInfo)(nil),      // 2: istio.workload.zds.WorkloadInfo
+	(*CaptureExclusions)(nil), // 3: istio.workload.zds.CaptureExclusions
+	(*AddWorkload)(nil),       // 4: istio.workload.zds.AddWorkload
+	(*KeepWorkload)(nil),      // 5: istio.workload.zds.KeepWorkload
+	(*DelWorkload)(nil),       // 6: istio.workload.zds.DelWorkload
+	(*SnapshotSent)(nil),      // 7: istio.workload.zds.SnapshotSent
+	(*Ack)(nil),               // 8: istio.workload.zds.Ack
+	(*WorkloadRequest)(nil),   // 9: istio.workload.zds.WorkloadRequest
+	(*WorkloadResponse)(nil),  // 10: istio.workload.zds.WorkloadResponse
 }
 var file_zdsapi_zds_proto_depIdxs = []int32{
 	0, // 0: istio.workload.zds.ZdsHello.version:type_name -> istio.workload.zds.Version
-	2, // 1: istio.workload.zds.AddWorkload.workload_info:type_name -> istio.workload.zds.WorkloadInfo
-	3, // 2: istio.workload.zds.WorkloadRequest.add:type_name -> istio.workload.zds.AddWorkload
-	4, // 3: istio.workload.zds.WorkloadRequest.keep:type_name -> istio.workload.zds.KeepWorkload
-	5, // 4: istio.workload.zds.WorkloadRequest.del:type_name -> istio.workload.zds.DelWorkload
-	6, // 5: istio.workload.zds.WorkloadRequest.snapshot_sent:type_name -> istio.workload.zds.SnapshotSent
-	7, // 6: istio.workload.zds.WorkloadResponse.ack:type_name -> istio.workload.zds.Ack
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	3, // 1: istio.workload.zds.WorkloadInfo.capture_exclusions:type_name -> istio.workload.zds.CaptureExclusions
+	2, // 2: istio.workload.zds.AddWorkload.workload_info:type_name -> istio.workload.zds.WorkloadInfo
+	4, // 3: istio.workload.zds.WorkloadRequest.add:type_name -> istio.workload.zds.AddWorkload
+	5, // 4: istio.workload.zds.WorkloadRequest.keep:type_name -> istio.workload.zds.KeepWorkload
+	6, // 5: istio.workload.zds.WorkloadRequest.del:type_name -> istio.workload.zds.DelWorkload
+	7, // 6: istio.workload.zds.WorkloadRequest.snapshot_sent:type_name -> istio.workload.zds.SnapshotSent
+	8, // 7: istio.workload.zds.WorkloadResponse.ack:type_name -> istio.workload.zds.Ack
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_zdsapi_zds_proto_init() }
@@ -679,13 +759,13 @@ func file_zdsapi_zds_proto_init() {
 	if File_zdsapi_zds_proto != nil {
 		return
 	}
-	file_zdsapi_zds_proto_msgTypes[7].OneofWrappers = []any{
+	file_zdsapi_zds_proto_msgTypes[8].OneofWrappers = []any{
 		(*WorkloadRequest_Add)(nil),
 		(*WorkloadRequest_Keep)(nil),
 		(*WorkloadRequest_Del)(nil),
 		(*WorkloadRequest_SnapshotSent)(nil),
 	}
-	file_zdsapi_zds_proto_msgTypes[8].OneofWrappers = []any{
+	file_zdsapi_zds_proto_msgTypes[9].OneofWrappers = []any{
 		(*WorkloadResponse_Ack)(nil),
 	}
 	type x struct{}
@@ -694,7 +774,7 @@ func file_zdsapi_zds_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_zdsapi_zds_proto_rawDesc), len(file_zdsapi_zds_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   9,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -22,10 +22,32 @@ import (
 	"istio.io/istio/security/pkg/credentialfetcher/plugin"
 )
 
-func NewCredFetcher(credtype, trustdomain, jwtPath, identityProvider string) (security.CredFetcher, error) {
+// Config is the configuration of the plugins exchanging a local credential of the workload.
+type Config struct {
+	TokenExchange plugin.TokenExchangeConfig
+	RolesAnywhere plugin.RolesAnywhereConfig
+}
+
+func NewCredFetcher(credtype, trustdomain, jwtPath, identityProvider string, config Config) (security.CredFetcher, error) {
 	switch credtype {
 	case security.GCE:
 		return plugin.CreateGCEPlugin(trustdomain, jwtPath, identityProvider), nil
+	case security.TokenExchange:
+		// Like for GCE, the token is requested for the trust domain, unless configured otherwise.
+		if config.TokenExchange.Audience == "" {
+			config.TokenExchange.Audience = trustdomain
+		}
+		p, err := plugin.CreateTokenExchangePlugin(config.TokenExchange, jwtPath, identityProvider)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case security.RolesAnywhere:
+		p, err := plugin.CreateRolesAnywherePlugin(config.RolesAnywhere, jwtPath, identityProvider)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
 	case security.JWT, "":
 		// If unset, also default to JWT for backwards compatibility
 		if jwtPath == "" {
@@ -27,6 +27,7 @@ func TestNewCredFetcher(t *testing.T) {
 		trustdomain      string
 		jwtPath          string
 		identityProvider string
+		config           Config
 		expectedErr      string
 		expectedToken    string
 		expectedIdp      string
@@ -49,6 +50,29 @@ func TestNewCredFetcher(t *testing.T) {
 			expectedToken:    "test_token",
 			expectedIdp:      "fakeIDP",
 		},
+		"token exchange test": {
+			fetcherType:      security.TokenExchange,
+			trustdomain:      "cluster.local",
+			identityProvider: "fakeIDP",
+			config: Config{TokenExchange: plugin.TokenExchangeConfig{
+				Endpoint:         "https://sts.example.com/token",
+				SubjectTokenPath: "/var/run/secrets/vm/token",
+			}},
+			expectedIdp: "fakeIDP",
+		},
+		"invalid token exchange test": {
+			fetcherType: security.TokenExchange,
+			config:      Config{TokenExchange: plugin.TokenExchangeConfig{Endpoint: "https://sts.example.com/token"}},
+			expectedErr: "the path of the subject token to exchange is unset",
+		},
+		"invalid roles anywhere test": {
+			fetcherType: security.RolesAnywhere,
+			config: Config{RolesAnywhere: plugin.RolesAnywhereConfig{
+				Endpoint: "https://rolesanywhere.us-east-1.amazonaws.com",
+				Region:   "us-east-1",
+			}},
+			expectedErr: "the paths of the certificate and private key are unset",
+		},
 		"invalid test": {
 			fetcherType:      "foo",
 			trustdomain:      "",
@@ -66,7 +90,7 @@ func TestNewCredFetcher(t *testing.T) {
 		t.Run(id, func(t *testing.T) {
 			t.Parallel()
 			cf, err := NewCredFetcher(
-				tc.fetcherType, tc.trustdomain, tc.jwtPath, tc.identityProvider)
+				tc.fetcherType, tc.trustdomain, tc.jwtPath, tc.identityProvider, tc.config)
 			if cf != nil {
 				defer cf.Stop()
 			}
@@ -0,0 +1,273 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Test only: this is a fake security token service for the token exchange and Roles Anywhere plugins.
+
+package plugin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FakeSTSTokenPath is the path of the token exchange endpoint of the fake STS.
+	FakeSTSTokenPath = "/token"
+
+	fakeSTSTokenPrefix   = "fake-sts-token-"
+	fakeSessionPrefix    = "fake-session-token-"
+	fakeSTSMaxClockSkew  = 5 * time.Minute
+	fakeSTSTokenLifetime = time.Hour
+)
+
+// FakeSTS is a fake security token service. It exchanges a subject token for tokens following RFC 8693, and creates
+// sessions for the callers authenticated by their certificate following the IAM Roles Anywhere API.
+type FakeSTS struct {
+	server       *httptest.Server
+	subjectToken string
+	trustAnchors *x509.CertPool
+
+	mutex         sync.Mutex
+	now           func() time.Time
+	numExchanges  int
+	numSessions   int
+	lastAudience  string
+	lastSessionCN string
+}
+
+// StartFakeSTS starts a fake STS exchanging the subject token, and creating sessions for the certificates issued by
+// the trust anchors.
+func StartFakeSTS(subjectToken string, trustAnchors *x509.CertPool) *FakeSTS {
+	s := &FakeSTS{subjectToken: subjectToken, trustAnchors: trustAnchors, now: time.Now}
+	mux := http.NewServeMux()
+	mux.HandleFunc(FakeSTSTokenPath, s.exchange)
+	mux.HandleFunc(RolesAnywhereSessionsPath, s.createSession)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the URL of the fake STS.
+func (s *FakeSTS) URL() string {
+	return s.server.URL
+}
+
+// SetClock sets the clock of the fake STS, used for the expiry of the tokens and the validation of the signatures.
+func (s *FakeSTS) SetClock(now func() time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.now = now
+}
+
+// NumExchanges returns the number of successful token exchanges.
+func (s *FakeSTS) NumExchanges() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.numExchanges
+}
+
+// NumSessions returns the number of sessions created.
+func (s *FakeSTS) NumSessions() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.numSessions
+}
+
+// LastAudience returns the audience of the last token exchange.
+func (s *FakeSTS) LastAudience() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastAudience
+}
+
+// LastSessionSubject returns the common name of the certificate of the last session.
+func (s *FakeSTS) LastSessionSubject() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastSessionCN
+}
+
+func (s *FakeSTS) Stop() {
+	s.server.Close()
+}
+
+func writeExchangeError(w http.ResponseWriter, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(tokenExchangeError{Error: code, ErrorDescription: description})
+}
+
+func (s *FakeSTS) exchange(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		writeExchangeError(w, "invalid_request", err.Error())
+		return
+	}
+	if req.PostForm.Get("grant_type") != TokenExchangeGrantType {
+		writeExchangeError(w, "unsupported_grant_type", req.PostForm.Get("grant_type"))
+		return
+	}
+	if req.PostForm.Get("subject_token_type") != TokenTypeJWT || req.PostForm.Get("subject_token") != s.subjectToken {
+		writeExchangeError(w, "invalid_grant", "invalid subject token")
+		return
+	}
+	s.mutex.Lock()
+	s.numExchanges++
+	s.lastAudience = req.PostForm.Get("audience")
+	token := fmt.Sprintf("%s%d", fakeSTSTokenPrefix, s.numExchanges)
+	s.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tokenExchangeResponse{
+		AccessToken:     token,
+		IssuedTokenType: TokenTypeJWT,
+		TokenType:       "N_A",
+		ExpiresIn:       int64(fakeSTSTokenLifetime / time.Second),
+	})
+}
+
+func (s *FakeSTS) createSession(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	now := s.now()
+	s.mutex.Unlock()
+	cert, err := s.verifySignature(req, body, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var session createSessionRequest
+	if err := json.Unmarshal(body, &session); err != nil || session.TrustAnchorArn == "" || session.ProfileArn == "" ||
+		session.RoleArn == "" {
+		http.Error(w, "invalid session request", http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	s.numSessions++
+	s.lastSessionCN = cert.Subject.CommonName
+	token := fmt.Sprintf("%s%d", fakeSessionPrefix, s.numSessions)
+	s.mutex.Unlock()
+
+	resp := createSessionResponse{CredentialSet: []sessionCredentialSet{{Credentials: sessionCredentials{
+		AccessKeyID:  "fake-access-key",
+		SessionToken: token,
+		Expiration:   now.Add(time.Duration(session.DurationSeconds) * time.Second).UTC(),
+	}}}}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// verifySignature verifies the Roles Anywhere signature of a request, and returns the certificate of the caller.
+func (s *FakeSTS) verifySignature(req *http.Request, body []byte, now time.Time) (*x509.Certificate, error) {
+	alg, params, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok {
+		return nil, fmt.Errorf("missing authorization")
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(params, ", ") {
+		k, v, _ := strings.Cut(field, "=")
+		fields[k] = v
+	}
+	amzDate := req.Header.Get(headerAmzDate)
+	date, err := time.Parse(rolesAnywhereDateFormat, amzDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date: %v", err)
+	}
+	if date.Sub(now).Abs() > fakeSTSMaxClockSkew {
+		return nil, fmt.Errorf("request expired")
+	}
+	der, err := base64.StdEncoding.DecodeString(req.Header.Get(headerAmzX509))
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	intermediates := x509.NewCertPool()
+	if chain := req.Header.Get(headerAmzX509Chain); chain != "" {
+		for _, c := range strings.Split(chain, ",") {
+			der, err := base64.StdEncoding.DecodeString(c)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate chain: %v", err)
+			}
+			intermediate, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate chain: %v", err)
+			}
+			intermediates.AddCert(intermediate)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         s.trustAnchors,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("untrusted certificate: %v", err)
+	}
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 {
+		return nil, fmt.Errorf("invalid credential %s", fields["Credential"])
+	}
+	scope := credentialScope(amzDate, credential[2])
+	if fields["Credential"] != cert.SerialNumber.String()+"/"+scope {
+		return nil, fmt.Errorf("invalid credential %s", fields["Credential"])
+	}
+	signature, err := hex.DecodeString(fields["Signature"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(stringToSign(req, body, alg, amzDate, scope, strings.Split(fields["SignedHeaders"], ";"))))
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if alg != rolesAnywhereAlgRSA {
+			return nil, fmt.Errorf("unexpected algorithm %s", alg)
+		}
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	case *ecdsa.PublicKey:
+		if alg != rolesAnywhereAlgECDSA || !ecdsa.VerifyASN1(key, digest[:], signature) {
+			err = fmt.Errorf("invalid signature")
+		}
+	default:
+		err = fmt.Errorf("unsupported key type %T", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
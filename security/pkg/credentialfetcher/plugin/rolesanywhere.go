@@ -0,0 +1,308 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is the IAM Roles Anywhere style plugin of credentialfetcher.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// RolesAnywhereSessionsPath is the path of the session endpoint, relative to the endpoint of the service.
+	RolesAnywhereSessionsPath = "/sessions"
+
+	rolesAnywhereService     = "rolesanywhere"
+	rolesAnywhereDateFormat  = "20060102T150405Z"
+	rolesAnywhereAlgRSA      = "AWS4-X509-RSA-SHA256"
+	rolesAnywhereAlgECDSA    = "AWS4-X509-ECDSA-SHA256"
+	headerAmzDate            = "X-Amz-Date"
+	headerAmzX509            = "X-Amz-X509"
+	headerAmzX509Chain       = "X-Amz-X509-Chain"
+	defaultRolesAnywhereTTL  = time.Hour
+	rolesAnywhereContentType = "application/json"
+)
+
+var rolesanywherecredLog = log.RegisterScope("rolesanywherecred", "IAM Roles Anywhere credential fetcher for istio agent")
+
+// RolesAnywhereConfig is the configuration of the Roles Anywhere plugin.
+type RolesAnywhereConfig struct {
+	// Endpoint is the URL of the session service, such as https://rolesanywhere.us-east-1.amazonaws.com.
+	Endpoint string
+	// Region is the region of the session service, part of the signature of the requests.
+	Region string
+	// CertificatePath is the path of the PEM encoded certificate of the VM, optionally followed by its intermediates.
+	CertificatePath string
+	// PrivateKeyPath is the path of the PEM encoded private key of the certificate, RSA or ECDSA.
+	PrivateKeyPath string
+	TrustAnchorARN string
+	ProfileARN     string
+	RoleARN        string
+	// SessionDuration is the requested duration of the sessions. Defaults to an hour.
+	SessionDuration time.Duration
+}
+
+// RolesAnywherePlugin creates sessions with an IAM Roles Anywhere style service, authenticating with the X.509
+// certificate of the VM, and returns the session token. The session token must be a token accepted by istiod, such as
+// the JWTs of the identity brokers implementing the Roles Anywhere session API.
+type RolesAnywherePlugin struct {
+	config           RolesAnywhereConfig
+	jwtPath          string
+	identityProvider string
+	client           *http.Client
+	now              func() time.Time
+
+	mutex sync.Mutex
+	cache exchangedToken
+}
+
+// CreateRolesAnywherePlugin creates a Roles Anywhere credential fetcher plugin, writing the session tokens to jwtPath
+// if set.
+func CreateRolesAnywherePlugin(config RolesAnywhereConfig, jwtPath, identityProvider string) (*RolesAnywherePlugin, error) {
+	if u, err := url.Parse(config.Endpoint); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid Roles Anywhere endpoint %q", config.Endpoint)
+	}
+	switch {
+	case config.Region == "":
+		return nil, fmt.Errorf("the Roles Anywhere region is unset")
+	case config.CertificatePath == "" || config.PrivateKeyPath == "":
+		return nil, fmt.Errorf("the paths of the certificate and private key are unset")
+	case config.TrustAnchorARN == "" || config.ProfileARN == "" || config.RoleARN == "":
+		return nil, fmt.Errorf("the trust anchor, profile and role ARNs are required")
+	}
+	if config.SessionDuration == 0 {
+		config.SessionDuration = defaultRolesAnywhereTTL
+	}
+	return &RolesAnywherePlugin{
+		config:           config,
+		jwtPath:          jwtPath,
+		identityProvider: identityProvider,
+		client:           &http.Client{Timeout: exchangeTimeout},
+		now:              time.Now,
+	}, nil
+}
+
+type createSessionRequest struct {
+	DurationSeconds int64  `json:"durationSeconds"`
+	ProfileArn      string `json:"profileArn"`
+	RoleArn         string `json:"roleArn"`
+	TrustAnchorArn  string `json:"trustAnchorArn"`
+}
+
+type createSessionResponse struct {
+	CredentialSet []sessionCredentialSet `json:"credentialSet"`
+}
+
+type sessionCredentialSet struct {
+	Credentials sessionCredentials `json:"credentials"`
+}
+
+type sessionCredentials struct {
+	AccessKeyID     string    `json:"accessKeyId"`
+	SecretAccessKey string    `json:"secretAccessKey"`
+	SessionToken    string    `json:"sessionToken"`
+	Expiration      time.Time `json:"expiration"`
+}
+
+// GetPlatformCredential returns the session token, creating a new session when the cached one is close to expiry.
+// The certificate and private key are read on each session creation, to follow their rotation.
+func (p *RolesAnywherePlugin) GetPlatformCredential() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	if p.cache.valid(now) {
+		return p.cache.token, nil
+	}
+	certs, key, err := p.loadCredentials()
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(createSessionRequest{
+		DurationSeconds: int64(p.config.SessionDuration / time.Second),
+		ProfileArn:      p.config.ProfileARN,
+		RoleArn:         p.config.RoleARN,
+		TrustAnchorArn:  p.config.TrustAnchorARN,
+	})
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exchangeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(p.config.Endpoint, "/")+RolesAnywhereSessionsPath, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	if err := SignRolesAnywhereRequest(req, body, certs, key, p.config.Region, now); err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("session creation with %s failed: %v", p.config.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the session: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("session creation with %s failed with status %d: %s",
+			p.config.Endpoint, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var session createSessionResponse
+	if err := json.Unmarshal(respBody, &session); err != nil {
+		return "", fmt.Errorf("invalid session: %v", err)
+	}
+	if len(session.CredentialSet) == 0 || session.CredentialSet[0].Credentials.SessionToken == "" {
+		return "", fmt.Errorf("invalid session: no session token")
+	}
+	credentials := session.CredentialSet[0].Credentials
+	p.cache = exchangedToken{token: credentials.SessionToken, issued: now, expiry: credentials.Expiration}
+	rolesanywherecredLog.Debugf("created a session, expiring at %v", credentials.Expiration)
+	if err := writeToken(p.jwtPath, credentials.SessionToken); err != nil {
+		rolesanywherecredLog.Errorf("failed to write the session token: %v", err)
+		return "", err
+	}
+	return credentials.SessionToken, nil
+}
+
+func (p *RolesAnywherePlugin) loadCredentials() ([]*x509.Certificate, crypto.Signer, error) {
+	certPEM, err := os.ReadFile(p.config.CertificatePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the certificate: %v", err)
+	}
+	certs, _, err := util.ParsePemEncodedCertificateChain(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(p.config.PrivateKeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the private key: %v", err)
+	}
+	key, err := util.ParsePemEncodedKey(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return certs, signer, nil
+}
+
+// GetIdentityProvider returns the name of the identity provider that can authenticate the workload credential.
+func (p *RolesAnywherePlugin) GetIdentityProvider() string {
+	return p.identityProvider
+}
+
+func (p *RolesAnywherePlugin) Stop() {}
+
+// SignRolesAnywhereRequest signs a request with the X.509 certificate of the caller, followed by its intermediates,
+// and its private key, following the Signature Version 4 signing process of IAM Roles Anywhere.
+func SignRolesAnywhereRequest(req *http.Request, body []byte, certs []*x509.Certificate, key crypto.Signer,
+	region string, now time.Time,
+) error {
+	var alg string
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		alg = rolesAnywhereAlgRSA
+	case *ecdsa.PublicKey:
+		alg = rolesAnywhereAlgECDSA
+	default:
+		return fmt.Errorf("unsupported private key type %T, must be RSA or ECDSA", key.Public())
+	}
+	amzDate := now.UTC().Format(rolesAnywhereDateFormat)
+	req.Header.Set("Content-Type", rolesAnywhereContentType)
+	req.Header.Set(headerAmzDate, amzDate)
+	req.Header.Set(headerAmzX509, base64.StdEncoding.EncodeToString(certs[0].Raw))
+	if len(certs) > 1 {
+		chain := make([]string, 0, len(certs)-1)
+		for _, cert := range certs[1:] {
+			chain = append(chain, base64.StdEncoding.EncodeToString(cert.Raw))
+		}
+		req.Header.Set(headerAmzX509Chain, strings.Join(chain, ","))
+	}
+	scope := credentialScope(amzDate, region)
+	signedHeaders := []string{"content-type", "host", strings.ToLower(headerAmzDate), strings.ToLower(headerAmzX509)}
+	if len(certs) > 1 {
+		signedHeaders = append(signedHeaders, strings.ToLower(headerAmzX509Chain))
+	}
+	sort.Strings(signedHeaders)
+	digest := sha256.Sum256([]byte(stringToSign(req, body, alg, amzDate, scope, signedHeaders)))
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign the request: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		alg, certs[0].SerialNumber.String(), scope, strings.Join(signedHeaders, ";"), hex.EncodeToString(signature)))
+	return nil
+}
+
+func credentialScope(amzDate, region string) string {
+	return strings.Join([]string{amzDate[:8], region, rolesAnywhereService, "aws4_request"}, "/")
+}
+
+// stringToSign returns the string to sign of a request, from its canonical request.
+func stringToSign(req *http.Request, body []byte, alg, amzDate, scope string, signedHeaders []string) string {
+	canonicalHeaders := make([]string, 0, len(signedHeaders))
+	for _, h := range signedHeaders {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		canonicalHeaders = append(canonicalHeaders, h+":"+strings.TrimSpace(value)+"\n")
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		strings.Join(canonicalHeaders, ""),
+		strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{alg, amzDate, scope, hex.EncodeToString(canonicalHash[:])}, "\n")
+}
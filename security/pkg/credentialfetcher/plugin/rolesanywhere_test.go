@@ -0,0 +1,155 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	pem  []byte
+	key  []byte
+}
+
+func genCA(t *testing.T, name string, signer *testCA) testCA {
+	t.Helper()
+	opts := util.CertOptions{Org: name, TTL: 24 * time.Hour, IsCA: true, RSAKeySize: 2048}
+	if signer == nil {
+		opts.IsSelfSigned = true
+	} else {
+		key, err := util.ParsePemEncodedKey(signer.key)
+		assert.NoError(t, err)
+		opts.SignerCert, opts.SignerPriv = signer.cert, key
+	}
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(opts)
+	assert.NoError(t, err)
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	assert.NoError(t, err)
+	return testCA{cert: cert, pem: certPEM, key: keyPEM}
+}
+
+// genLeaf writes a certificate of the VM issued by the CA, followed by the chain, and its private key.
+func genLeaf(t *testing.T, dir string, ca testCA, ec bool, chain ...[]byte) (string, string) {
+	t.Helper()
+	key, err := util.ParsePemEncodedKey(ca.key)
+	assert.NoError(t, err)
+	opts := util.CertOptions{
+		Host: "vm-1", TTL: time.Hour, IsClient: true, IsDualUse: true, RSAKeySize: 2048,
+		SignerCert: ca.cert, SignerPriv: key,
+	}
+	if ec {
+		opts.ECSigAlg = util.EcdsaSigAlg
+	}
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(opts)
+	assert.NoError(t, err)
+	for _, c := range chain {
+		certPEM = append(certPEM, c...)
+	}
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(certPath, certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(keyPath, keyPEM, 0o600))
+	return certPath, keyPath
+}
+
+func TestRolesAnywherePlugin(t *testing.T) {
+	root := genCA(t, "root", nil)
+	intermediate := genCA(t, "intermediate", &root)
+	trustAnchors := x509.NewCertPool()
+	trustAnchors.AddCert(root.cert)
+	sts := StartFakeSTS("", trustAnchors)
+	defer sts.Stop()
+
+	cases := []struct {
+		name    string
+		ec      bool
+		issuer  testCA
+		chain   [][]byte
+		wantErr string
+	}{
+		{name: "rsa", issuer: root},
+		{name: "ecdsa", ec: true, issuer: root},
+		{name: "intermediate", issuer: intermediate, chain: [][]byte{intermediate.pem}},
+		{name: "untrusted", issuer: genCA(t, "other", nil), wantErr: "untrusted certificate"},
+		{name: "missing intermediate", issuer: intermediate, wantErr: "untrusted certificate"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certPath, keyPath := genLeaf(t, dir, tt.issuer, tt.ec, tt.chain...)
+			jwtPath := filepath.Join(dir, "istio-token")
+			p, err := CreateRolesAnywherePlugin(RolesAnywhereConfig{
+				Endpoint:        sts.URL(),
+				Region:          "us-east-1",
+				CertificatePath: certPath,
+				PrivateKeyPath:  keyPath,
+				TrustAnchorARN:  "arn:aws:rolesanywhere:us-east-1:123456789012:trust-anchor/ta",
+				ProfileARN:      "arn:aws:rolesanywhere:us-east-1:123456789012:profile/p",
+				RoleARN:         "arn:aws:iam::123456789012:role/istio-vm",
+			}, jwtPath, "fakeIDP")
+			assert.NoError(t, err)
+
+			sessions := sts.NumSessions()
+			token, err := p.GetPlatformCredential()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, strings.HasPrefix(token, fakeSessionPrefix), true)
+			assert.Equal(t, sts.LastSessionSubject(), "vm-1")
+			written, err := os.ReadFile(jwtPath)
+			assert.NoError(t, err)
+			assert.Equal(t, string(written), token)
+
+			// The session is reused until it is close to expiry.
+			cached, err := p.GetPlatformCredential()
+			assert.NoError(t, err)
+			assert.Equal(t, cached, token)
+			assert.Equal(t, sts.NumSessions(), sessions+1)
+		})
+	}
+}
+
+func TestRolesAnywhereSignatureExpiry(t *testing.T) {
+	root := genCA(t, "root", nil)
+	trustAnchors := x509.NewCertPool()
+	trustAnchors.AddCert(root.cert)
+	sts := StartFakeSTS("", trustAnchors)
+	defer sts.Stop()
+
+	certPath, keyPath := genLeaf(t, t.TempDir(), root, true)
+	p, err := CreateRolesAnywherePlugin(RolesAnywhereConfig{
+		Endpoint: sts.URL(), Region: "us-east-1", CertificatePath: certPath, PrivateKeyPath: keyPath,
+		TrustAnchorARN: "ta", ProfileARN: "p", RoleARN: "r",
+	}, "", "")
+	assert.NoError(t, err)
+	// Signed requests are only valid for a few minutes.
+	p.now = func() time.Time { return time.Now().Add(-10 * time.Minute) }
+	_, err = p.GetPlatformCredential()
+	if err == nil || !strings.Contains(err.Error(), "request expired") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is the OAuth 2.0 token exchange (RFC 8693) plugin of credentialfetcher.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// TokenExchangeGrantType is the grant type of RFC 8693 token exchange requests.
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// TokenTypeJWT is the RFC 8693 token type of JWTs.
+	TokenTypeJWT = "urn:ietf:params:oauth:token-type:jwt"
+
+	// exchangeTimeout bounds the requests to the token exchange services.
+	exchangeTimeout = 10 * time.Second
+	// maxExchangeGracePeriod bounds the period before the expiry of an exchanged token in which it is renewed.
+	maxExchangeGracePeriod = 5 * time.Minute
+)
+
+var stscredLog = log.RegisterScope("stscred", "Token exchange credential fetcher for istio agent")
+
+// TokenExchangeConfig is the configuration of the token exchange plugin.
+type TokenExchangeConfig struct {
+	// Endpoint is the URL of the token endpoint of the security token service.
+	Endpoint string
+	// SubjectTokenPath is the path of the token exchanged, typically the identity token of the VM written by its
+	// cloud provider or enterprise identity agent.
+	SubjectTokenPath string
+	// SubjectTokenType is the RFC 8693 type of the subject token. Defaults to TokenTypeJWT.
+	SubjectTokenType string
+	// Audience is the audience of the requested token.
+	Audience string
+	// Scope is the optional space separated scope of the requested token.
+	Scope string
+}
+
+// exchangedToken is a token obtained from an exchange, cached until it is close to expiry.
+type exchangedToken struct {
+	token  string
+	issued time.Time
+	expiry time.Time
+}
+
+// valid returns whether the token can be used at the given time, rather than renewed: a token is renewed within half
+// its lifetime of its expiry, bounded by maxExchangeGracePeriod. A token without expiry is renewed on each use.
+func (t *exchangedToken) valid(now time.Time) bool {
+	if t.token == "" || t.expiry.IsZero() {
+		return false
+	}
+	grace := min(t.expiry.Sub(t.issued)/2, maxExchangeGracePeriod)
+	return now.Before(t.expiry.Add(-grace))
+}
+
+// writeToken writes an exchanged token to the path read by the Envoy STS client and istio agent, if set.
+func writeToken(path, token string) error {
+	if path == "" {
+		return nil
+	}
+	return os.WriteFile(path, []byte(token), 0o640)
+}
+
+// TokenExchangePlugin exchanges a local token for a token of the mesh with an RFC 8693 security token service.
+type TokenExchangePlugin struct {
+	config           TokenExchangeConfig
+	jwtPath          string
+	identityProvider string
+	client           *http.Client
+	now              func() time.Time
+
+	mutex sync.Mutex
+	cache exchangedToken
+}
+
+// CreateTokenExchangePlugin creates a token exchange credential fetcher plugin, writing the exchanged tokens to
+// jwtPath if set.
+func CreateTokenExchangePlugin(config TokenExchangeConfig, jwtPath, identityProvider string) (*TokenExchangePlugin, error) {
+	if u, err := url.Parse(config.Endpoint); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid token exchange endpoint %q", config.Endpoint)
+	}
+	if config.SubjectTokenPath == "" {
+		return nil, fmt.Errorf("the path of the subject token to exchange is unset")
+	}
+	if config.SubjectTokenType == "" {
+		config.SubjectTokenType = TokenTypeJWT
+	}
+	return &TokenExchangePlugin{
+		config:           config,
+		jwtPath:          jwtPath,
+		identityProvider: identityProvider,
+		client:           &http.Client{Timeout: exchangeTimeout},
+		now:              time.Now,
+	}, nil
+}
+
+// tokenExchangeResponse is the successful response of RFC 8693 section 2.2.1.
+type tokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in,omitempty"`
+}
+
+// tokenExchangeError is the error response of RFC 6749 section 5.2.
+type tokenExchangeError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// GetPlatformCredential returns the exchanged token, exchanging the subject token again when the cached one is close
+// to expiry.
+func (p *TokenExchangePlugin) GetPlatformCredential() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	if p.cache.valid(now) {
+		return p.cache.token, nil
+	}
+	subjectToken, err := os.ReadFile(p.config.SubjectTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the subject token: %v", err)
+	}
+	form := url.Values{
+		"grant_type":           {TokenExchangeGrantType},
+		"subject_token":        {strings.TrimSpace(string(subjectToken))},
+		"subject_token_type":   {p.config.SubjectTokenType},
+		"requested_token_type": {TokenTypeJWT},
+	}
+	if p.config.Audience != "" {
+		form.Set("audience", p.config.Audience)
+	}
+	if p.config.Scope != "" {
+		form.Set("scope", p.config.Scope)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exchangeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange with %s failed: %v", p.config.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the token exchange response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var exchangeErr tokenExchangeError
+		if json.Unmarshal(body, &exchangeErr) == nil && exchangeErr.Error != "" {
+			return "", fmt.Errorf("token exchange with %s failed: %s: %s", p.config.Endpoint, exchangeErr.Error, exchangeErr.ErrorDescription)
+		}
+		return "", fmt.Errorf("token exchange with %s failed with status %d", p.config.Endpoint, resp.StatusCode)
+	}
+	var exchanged tokenExchangeResponse
+	if err := json.Unmarshal(body, &exchanged); err != nil {
+		return "", fmt.Errorf("invalid token exchange response: %v", err)
+	}
+	if exchanged.AccessToken == "" {
+		return "", fmt.Errorf("invalid token exchange response: no access token")
+	}
+	if exchanged.IssuedTokenType != "" && exchanged.IssuedTokenType != TokenTypeJWT {
+		return "", fmt.Errorf("invalid token exchange response: issued token type %s is not a JWT", exchanged.IssuedTokenType)
+	}
+
+	p.cache = exchangedToken{token: exchanged.AccessToken, issued: now}
+	if exchanged.ExpiresIn > 0 {
+		p.cache.expiry = now.Add(time.Duration(exchanged.ExpiresIn) * time.Second)
+	}
+	stscredLog.Debugf("exchanged the subject token, the token expires at %v", p.cache.expiry)
+	if err := writeToken(p.jwtPath, exchanged.AccessToken); err != nil {
+		stscredLog.Errorf("failed to write the exchanged token: %v", err)
+		return "", err
+	}
+	return exchanged.AccessToken, nil
+}
+
+// GetIdentityProvider returns the name of the identity provider that can authenticate the workload credential.
+func (p *TokenExchangePlugin) GetIdentityProvider() string {
+	return p.identityProvider
+}
+
+func (p *TokenExchangePlugin) Stop() {}
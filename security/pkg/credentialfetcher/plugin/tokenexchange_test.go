@@ -0,0 +1,91 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestTokenExchangePlugin(t *testing.T) {
+	sts := StartFakeSTS("vm-identity-token", nil)
+	defer sts.Stop()
+
+	dir := t.TempDir()
+	subjectTokenPath := filepath.Join(dir, "vm-token")
+	assert.NoError(t, os.WriteFile(subjectTokenPath, []byte("vm-identity-token\n"), 0o600))
+	jwtPath := filepath.Join(dir, "istio-token")
+
+	p, err := CreateTokenExchangePlugin(TokenExchangeConfig{
+		Endpoint:         sts.URL() + FakeSTSTokenPath,
+		SubjectTokenPath: subjectTokenPath,
+		Audience:         "cluster.local",
+	}, jwtPath, "fakeIDP")
+	assert.NoError(t, err)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	assert.Equal(t, p.GetIdentityProvider(), "fakeIDP")
+
+	token, err := p.GetPlatformCredential()
+	assert.NoError(t, err)
+	assert.Equal(t, token, "fake-sts-token-1")
+	assert.Equal(t, sts.LastAudience(), "cluster.local")
+	written, err := os.ReadFile(jwtPath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(written), token)
+
+	// The token is cached until it is close to expiry.
+	now = now.Add(50 * time.Minute)
+	token, err = p.GetPlatformCredential()
+	assert.NoError(t, err)
+	assert.Equal(t, token, "fake-sts-token-1")
+	now = now.Add(6 * time.Minute)
+	token, err = p.GetPlatformCredential()
+	assert.NoError(t, err)
+	assert.Equal(t, token, "fake-sts-token-2")
+	assert.Equal(t, sts.NumExchanges(), 2)
+
+	// The error of the STS is returned.
+	assert.NoError(t, os.WriteFile(subjectTokenPath, []byte("stolen-token"), 0o600))
+	now = now.Add(time.Hour)
+	_, err = p.GetPlatformCredential()
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant: invalid subject token") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCreateTokenExchangePlugin(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  TokenExchangeConfig
+		wantErr string
+	}{
+		{name: "no endpoint", config: TokenExchangeConfig{SubjectTokenPath: "/token"}, wantErr: "invalid token exchange endpoint"},
+		{name: "no subject token", config: TokenExchangeConfig{Endpoint: "https://sts.example.com"}, wantErr: "subject token"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CreateTokenExchangePlugin(tt.config, "", "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -35,6 +35,9 @@ const (
 	// PodUIDKey is the key used in a user's "extra" to specify the pod UID of
 	// the authenticating request.
 	PodUIDKey = "authentication.kubernetes.io/pod-uid"
+	// NodeNameKey is the key used in a user's "extra" to specify the name of the node the pod of the
+	// authenticating request runs on. It is set by Kubernetes 1.30+.
+	NodeNameKey = "authentication.kubernetes.io/node-name"
 )
 
 // ValidateK8sJwt validates a k8s JWT at API server.
@@ -109,6 +112,7 @@ func getTokenReviewResult(tokenReview *k8sauth.TokenReview) (security.Kubernetes
 		PodNamespace:      subStrings[2],
 		PodUID:            extractExtra(tokenReview, PodUIDKey),
 		PodServiceAccount: subStrings[3],
+		NodeName:          extractExtra(tokenReview, NodeNameKey),
 	}, nil
 }
 
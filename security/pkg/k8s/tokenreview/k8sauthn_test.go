@@ -116,8 +116,9 @@ func TestGetTokenReviewResult(t *testing.T) {
 							"system:authenticated",
 						},
 						Extra: map[string]authenticationv1.ExtraValue{
-							PodNameKey: []string{"some-pod"},
-							PodUIDKey:  []string{"12345"},
+							PodNameKey:  []string{"some-pod"},
+							PodUIDKey:   []string{"12345"},
+							NodeNameKey: []string{"some-node"},
 						},
 					},
 				},
@@ -127,6 +128,7 @@ func TestGetTokenReviewResult(t *testing.T) {
 				PodServiceAccount: "example-pod-sa",
 				PodUID:            "12345",
 				PodName:           "some-pod",
+				NodeName:          "some-node",
 			},
 		},
 	}
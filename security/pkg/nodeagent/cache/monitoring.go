@@ -55,4 +55,21 @@ var (
 		"The time remaining, in seconds, before the certificate chain will expire. "+
 			"A negative value indicates the cert is expired.",
 	)
+
+	certRenewalState = monitoring.NewGauge(
+		"cert_renewal_state",
+		"The state of the renewal of the workload certificate: 0 if the renewal is scheduled, 1 if it is due, "+
+			"2 if it failed and is retried after a backoff while the current certificate is served, "+
+			"3 if the certificate expired before it could be renewed.",
+	)
+
+	certRenewalFailures = monitoring.NewGauge(
+		"cert_renewal_consecutive_failures",
+		"The number of consecutive failures to renew the workload certificate.",
+	)
+
+	certRenewalBackoffSeconds = monitoring.NewGauge(
+		"cert_renewal_backoff_seconds",
+		"The delay, in seconds, before the renewal of the workload certificate is retried after a failure.",
+	)
 )
@@ -0,0 +1,129 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"time"
+
+	"istio.io/istio/pkg/backoff"
+	"istio.io/istio/pkg/security"
+)
+
+// renewalState is the state of the renewal of the workload certificate.
+//
+//	scheduled --(rotation time)--> due --(CA signs)--> scheduled
+//	                                due --(CA fails, cert valid)--> backoff --(retry time)--> due
+//	                                due --(CA fails, cert expired)--> expired --(CA signs)--> scheduled
+type renewalState int
+
+const (
+	// renewalScheduled is the state of a valid certificate, whose renewal is scheduled.
+	renewalScheduled renewalState = iota
+	// renewalDue is the state of a certificate due for renewal: the next request of the certificate renews it.
+	renewalDue
+	// renewalBackoff is the state of a certificate which could not be renewed: it is served until the renewal is
+	// retried, after a backoff.
+	renewalBackoff
+	// renewalExpired is the state of a certificate which expired before it could be renewed.
+	renewalExpired
+)
+
+func (s renewalState) String() string {
+	switch s {
+	case renewalScheduled:
+		return "scheduled"
+	case renewalDue:
+		return "due"
+	case renewalBackoff:
+		return "backoff"
+	case renewalExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+func newRenewalBackoff(options *security.Options) backoff.BackOff {
+	o := backoff.DefaultOption()
+	if options.SecretRenewalBackoffInitialInterval > 0 {
+		o.InitialInterval = options.SecretRenewalBackoffInitialInterval
+	}
+	if options.SecretRenewalBackoffMaxInterval > 0 {
+		o.MaxInterval = options.SecretRenewalBackoffMaxInterval
+	}
+	return backoff.NewExponentialBackOff(o)
+}
+
+func (sc *SecretManagerClient) setRenewalState(state renewalState) {
+	sc.cache.SetRenewal(state)
+	certRenewalState.Record(float64(state))
+}
+
+// servable returns whether the cached workload certificate can be served, rather than renewed.
+func (sc *SecretManagerClient) servable(cached *security.SecretItem) bool {
+	switch sc.cache.GetRenewal() {
+	case renewalScheduled:
+		return true
+	case renewalBackoff:
+		return time.Now().Before(cached.ExpireTime)
+	default:
+		return false
+	}
+}
+
+// renewalSucceeded resets the backoff of the renewal of the workload certificate. Called with generateMutex held.
+func (sc *SecretManagerClient) renewalSucceeded() {
+	if sc.renewalFailures > 0 {
+		cacheLog.Infof("renewed the workload certificate after %d failures", sc.renewalFailures)
+	}
+	sc.renewalFailures = 0
+	sc.renewalBackoff.Reset()
+	certRenewalFailures.Record(0)
+	certRenewalBackoffSeconds.Record(0)
+}
+
+// renewalFailed handles a failure to renew the workload certificate, e.g. when the CA is unreachable. The current
+// certificate is returned to keep serving it while it is valid, and the renewal is retried after a backoff. Called
+// with generateMutex held.
+func (sc *SecretManagerClient) renewalFailed(err error) *security.SecretItem {
+	sc.renewalFailures++
+	certRenewalFailures.Record(float64(sc.renewalFailures))
+	cached := sc.cache.GetWorkload()
+	if cached == nil {
+		return nil
+	}
+	remaining := time.Until(cached.ExpireTime)
+	if remaining <= 0 {
+		cacheLog.Errorf("workload certificate expired at %v before it could be renewed: %v", cached.ExpireTime, err)
+		sc.cache.SetWorkload(nil)
+		sc.setRenewalState(renewalExpired)
+		certRenewalBackoffSeconds.Record(0)
+		return nil
+	}
+
+	delay := min(sc.renewalBackoff.NextBackOff(), remaining)
+	cacheLog.Warnf("failed to renew the workload certificate (%d consecutive failures), serving the current certificate "+
+		"which expires in %v, retrying in %v: %v", sc.renewalFailures, remaining.Round(time.Second), delay, err)
+	sc.setRenewalState(renewalBackoff)
+	certRenewalBackoffSeconds.Record(delay.Seconds())
+	sc.queue.PushDelayed(func() error {
+		// The certificate may have been renewed or discarded in the meantime.
+		if c := sc.cache.GetWorkload(); c != nil && c.CreatedTime == cached.CreatedTime && sc.cache.GetRenewal() == renewalBackoff {
+			sc.setRenewalState(renewalDue)
+			sc.OnSecretUpdate(security.WorkloadKeyCertResourceName)
+		}
+		return nil
+	}, delay)
+	return sc.getCachedSecret(security.WorkloadKeyCertResourceName)
+}
@@ -0,0 +1,130 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/security/pkg/nodeagent/caclient/providers/mock"
+)
+
+// unreachableCAClient is a mock CA client which can be made unreachable.
+type unreachableCAClient struct {
+	*mock.CAClient
+	unreachable atomic.Bool
+}
+
+func (c *unreachableCAClient) CSRSign(csrPEM []byte, certValidTTLInSec int64) ([]string, error) {
+	if c.unreachable.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return c.CAClient.CSRSign(csrPEM, certValidTTLInSec)
+}
+
+func newUnreachableCAClient(t *testing.T, certLifetime time.Duration) *unreachableCAClient {
+	t.Helper()
+	caClient, err := mock.NewMockCAClient(certLifetime, false)
+	assert.NoError(t, err)
+	return &unreachableCAClient{CAClient: caClient}
+}
+
+func TestRenewalBackoff(t *testing.T) {
+	mt := monitortest.New(t)
+	// The certificate is due for renewal after 1s, and remains valid for the retries.
+	caClient := newUnreachableCAClient(t, 10*time.Second)
+	u := NewUpdateTracker(t)
+	sc := createCache(t, caClient, u.Callback, security.Options{
+		WorkloadRSAKeySize:                  2048,
+		SecretRotationGracePeriodRatio:      0.9,
+		SecretRenewalBackoffInitialInterval: time.Second,
+		SecretRenewalBackoffMaxInterval:     2 * time.Second,
+	})
+
+	current, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	assert.NoError(t, err)
+	mt.Assert(certRenewalState.Name(), nil, monitortest.Exactly(float64(renewalScheduled)))
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+
+	// The CA is unreachable when the certificate is due for renewal: the current certificate is served, and the
+	// renewal retried after a backoff.
+	caClient.unreachable.Store(true)
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1, security.WorkloadKeyCertResourceName: 1})
+	secret, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	assert.NoError(t, err)
+	assert.Equal(t, secret.CertificateChain, current.CertificateChain)
+	mt.Assert(certRenewalState.Name(), nil, monitortest.Exactly(float64(renewalBackoff)))
+	mt.Assert(certRenewalFailures.Name(), nil, monitortest.Exactly(1))
+	mt.Assert(certRenewalBackoffSeconds.Name(), nil, monitortest.AtLeast(0.5))
+
+	// The certificate is served from the cache until the retry.
+	secret, err = sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	assert.NoError(t, err)
+	assert.Equal(t, secret.CertificateChain, current.CertificateChain)
+	mt.Assert(certRenewalFailures.Name(), nil, monitortest.Exactly(1))
+
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1, security.WorkloadKeyCertResourceName: 2})
+	secret, err = sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	assert.NoError(t, err)
+	assert.Equal(t, secret.CertificateChain, current.CertificateChain)
+	mt.Assert(certRenewalFailures.Name(), nil, monitortest.Exactly(2))
+
+	// The certificate is renewed at the next retry once the CA is reachable.
+	caClient.unreachable.Store(false)
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1, security.WorkloadKeyCertResourceName: 3})
+	secret, err = sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	assert.NoError(t, err)
+	if string(secret.CertificateChain) == string(current.CertificateChain) {
+		t.Fatal("the certificate was not renewed")
+	}
+	mt.Assert(certRenewalState.Name(), nil, monitortest.Exactly(float64(renewalScheduled)))
+	mt.Assert(certRenewalFailures.Name(), nil, monitortest.Exactly(0))
+	mt.Assert(certRenewalBackoffSeconds.Name(), nil, monitortest.Exactly(0))
+}
+
+func TestRenewalExpired(t *testing.T) {
+	mt := monitortest.New(t)
+	caClient := newUnreachableCAClient(t, time.Second)
+	u := NewUpdateTracker(t)
+	sc := createCache(t, caClient, u.Callback, security.Options{
+		WorkloadRSAKeySize:                  2048,
+		SecretRenewalBackoffInitialInterval: 100 * time.Millisecond,
+	})
+
+	_, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	assert.NoError(t, err)
+
+	// The certificate is due for renewal when it expires, with a grace period ratio of 0: it is no longer served.
+	caClient.unreachable.Store(true)
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1, security.WorkloadKeyCertResourceName: 1})
+	_, err = sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	assert.Equal(t, sc.cache.GetWorkload(), nil)
+	mt.Assert(certRenewalState.Name(), nil, monitortest.Exactly(float64(renewalExpired)))
+
+	caClient.unreachable.Store(false)
+	_, err = sc.GenerateSecret(security.WorkloadKeyCertResourceName)
+	assert.NoError(t, err)
+	mt.Assert(certRenewalState.Name(), nil, monitortest.Exactly(float64(renewalScheduled)))
+	mt.Assert(certRenewalFailures.Name(), nil, monitortest.Exactly(0))
+}
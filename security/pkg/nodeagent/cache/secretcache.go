@@ -32,6 +32,7 @@ import (
 	"istio.io/istio/pkg/backoff"
 	"istio.io/istio/pkg/file"
 	istiolog "istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/queue"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/pkg/spiffe"
@@ -112,11 +113,18 @@ type SecretManagerClient struct {
 	configTrustBundleMutex sync.RWMutex
 	// Dynamically configured Trust Bundle
 	configTrustBundle []byte
+	// Dynamically configured trust bundles of the federated trust domains, keyed by trust domain
+	federatedTrustBundles map[string][]byte
 
 	// queue maintains all certificate rotation events that need to be triggered when they are about to expire
 	queue queue.Delayed
 	stop  chan struct{}
 
+	// renewalBackoff is the backoff of the retries of the renewal of the workload certificate, and renewalFailures the
+	// number of consecutive failures. Both are protected by generateMutex.
+	renewalBackoff  backoff.BackOff
+	renewalFailures int
+
 	caRootPath string
 }
 
@@ -124,6 +132,8 @@ type secretCache struct {
 	mu       sync.RWMutex
 	workload *security.SecretItem
 	certRoot []byte
+	// renewal is the state of the renewal of the workload certificate.
+	renewal renewalState
 }
 
 // GetRoot returns cached root cert and cert expiration time. This method is thread safe.
@@ -155,6 +165,18 @@ func (s *secretCache) SetWorkload(value *security.SecretItem) {
 	s.workload = value
 }
 
+func (s *secretCache) GetRenewal() renewalState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.renewal
+}
+
+func (s *secretCache) SetRenewal(state renewalState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renewal = state
+}
+
 var _ security.SecretManager = &SecretManagerClient{}
 
 // FileCert stores a reference to a certificate on disk
@@ -179,10 +201,11 @@ func NewSecretManagerClient(caClient security.Client, options *security.Options)
 			PrivateKeyPath:    options.KeyFilePath,
 			CaCertificatePath: options.RootCertFilePath,
 		},
-		certWatcher: watcher,
-		fileCerts:   make(map[FileCert]struct{}),
-		stop:        make(chan struct{}),
-		caRootPath:  options.CARootPath,
+		certWatcher:    watcher,
+		fileCerts:      make(map[FileCert]struct{}),
+		stop:           make(chan struct{}),
+		caRootPath:     options.CARootPath,
+		renewalBackoff: newRenewalBackoff(options),
 	}
 
 	go ret.queue.Run(ret.stop)
@@ -221,12 +244,16 @@ func (sc *SecretManagerClient) getCachedSecret(resourceName string) (secret *sec
 		if resourceName == security.RootCertReqResourceName {
 			rootCertBundle = sc.mergeTrustAnchorBytes(c.RootCert)
 			ns = &security.SecretItem{
-				ResourceName: resourceName,
-				RootCert:     rootCertBundle,
+				ResourceName:         resourceName,
+				RootCert:             rootCertBundle,
+				TrustDomainRootCerts: sc.trustDomainRootCerts(rootCertBundle),
 			}
 			cacheLog.WithLabels("ttl", time.Until(c.ExpireTime)).Info("returned workload trust anchor from cache")
 
 		} else {
+			if !sc.servable(c) {
+				return nil
+			}
 			ns = &security.SecretItem{
 				ResourceName:     resourceName,
 				CertificateChain: c.CertificateChain,
@@ -297,14 +324,21 @@ func (sc *SecretManagerClient) GenerateSecret(resourceName string) (secret *secu
 	// send request to CA to get new workload certificate
 	ns, err = sc.generateNewSecret(resourceName)
 	if err != nil {
+		if resourceName == security.WorkloadKeyCertResourceName {
+			if cached := sc.renewalFailed(err); cached != nil {
+				return cached, nil
+			}
+		}
 		return nil, fmt.Errorf("failed to generate workload certificate: %v", err)
 	}
+	sc.renewalSucceeded()
 
 	// Store the new secret in the secretCache and trigger the periodic rotation for workload certificate
 	sc.registerSecret(*ns)
 
 	if resourceName == security.RootCertReqResourceName {
 		ns.RootCert = sc.mergeTrustAnchorBytes(ns.RootCert)
+		ns.TrustDomainRootCerts = sc.trustDomainRootCerts(ns.RootCert)
 	} else {
 		// If periodic cert refresh resulted in discovery of a new root, trigger a ROOTCA request to refresh trust anchor
 		oldRoot := sc.cache.GetRoot()
@@ -525,6 +559,7 @@ func (sc *SecretManagerClient) generateFileSecret(resourceName string) (bool, *s
 		if sitem, err = sc.generateRootCertFromExistingFile(cf.CaCertificatePath, resourceName, true); err == nil {
 			// If retrieving workload trustBundle, then merge other configured trustAnchors in ProxyConfig
 			sitem.RootCert = sc.mergeTrustAnchorBytes(sitem.RootCert)
+			sitem.TrustDomainRootCerts = sc.trustDomainRootCerts(sitem.RootCert)
 			sc.addFileWatcher(cf.CaCertificatePath, resourceName)
 		}
 	// Default workload certificate.
@@ -681,11 +716,12 @@ func (sc *SecretManagerClient) registerSecret(item security.SecretItem) {
 	delay := rotateTime(item, sc.configOptions.SecretRotationGracePeriodRatio, sc.configOptions.SecretRotationGracePeriodRatioJitter)
 	item.ResourceName = security.WorkloadKeyCertResourceName
 	// In case there are two calls to GenerateSecret at once, we don't want both to be concurrently registered
-	if sc.cache.GetWorkload() != nil {
+	if sc.cache.GetWorkload() != nil && sc.cache.GetRenewal() == renewalScheduled {
 		resourceLog(item.ResourceName).Infof("skip scheduling certificate rotation, already scheduled")
 		return
 	}
 	sc.cache.SetWorkload(&item)
+	sc.setRenewalState(renewalScheduled)
 	resourceLog(item.ResourceName).Debugf("scheduled certificate for rotation in %v", delay)
 	certExpirySeconds.ValueFrom(func() float64 { return time.Until(item.ExpireTime).Seconds() }, ResourceName.Value(item.ResourceName))
 	sc.queue.PushDelayed(func() error {
@@ -694,8 +730,9 @@ func (sc *SecretManagerClient) registerSecret(item security.SecretItem) {
 		if cached := sc.cache.GetWorkload(); cached != nil {
 			if cached.CreatedTime == item.CreatedTime {
 				resourceLog(item.ResourceName).Debugf("rotating certificate")
-				// Clear the cache so the next call generates a fresh certificate
-				sc.cache.SetWorkload(nil)
+				// The next call generates a fresh certificate. The current certificate is kept to be served while it
+				// is valid if the CA is unreachable.
+				sc.setRenewalState(renewalDue)
 				sc.OnSecretUpdate(item.ResourceName)
 			}
 		}
@@ -798,6 +835,34 @@ func (sc *SecretManagerClient) UpdateConfigTrustBundle(trustBundle []byte) error
 	return nil
 }
 
+// UpdateFederatedTrustBundles updates the trust bundles of the federated trust domains, keyed by trust domain.
+func (sc *SecretManagerClient) UpdateFederatedTrustBundles(trustBundles map[string][]byte) error {
+	sc.configTrustBundleMutex.Lock()
+	if maps.EqualFunc(sc.federatedTrustBundles, trustBundles, bytes.Equal) {
+		cacheLog.Debugf("skip for same federated trust bundles")
+		sc.configTrustBundleMutex.Unlock()
+		return nil
+	}
+	sc.federatedTrustBundles = trustBundles
+	sc.configTrustBundleMutex.Unlock()
+	cacheLog.Debugf("update federated trust bundles")
+	sc.OnSecretUpdate(security.RootCertReqResourceName)
+	return nil
+}
+
+// trustDomainRootCerts returns the root certificates of each trust domain: the trust bundle of the mesh for the
+// trust domain of the workload, and the federated trust bundles. It returns nil if the mesh is not federated.
+func (sc *SecretManagerClient) trustDomainRootCerts(rootCert []byte) map[string][]byte {
+	sc.configTrustBundleMutex.RLock()
+	defer sc.configTrustBundleMutex.RUnlock()
+	if len(sc.federatedTrustBundles) == 0 {
+		return nil
+	}
+	res := maps.Clone(sc.federatedTrustBundles)
+	res[sc.configOptions.TrustDomain] = rootCert
+	return res
+}
+
 // mergeTrustAnchorBytes: Merge cert bytes with the cached TrustAnchors.
 func (sc *SecretManagerClient) mergeTrustAnchorBytes(caCerts []byte) []byte {
 	return sc.mergeConfigTrustBundle(pkiutil.PemCertBytestoString(caCerts))
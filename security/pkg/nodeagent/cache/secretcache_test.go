@@ -32,6 +32,7 @@ import (
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/monitoring/monitortest"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/pkg/testcerts"
@@ -795,9 +796,9 @@ func TestProxyConfigAnchorsTriggerWorkloadCertUpdate(t *testing.T) {
 	u.Expect(map[string]int{security.RootCertReqResourceName: 1, security.WorkloadKeyCertResourceName: 1})
 	u.Reset()
 
-	rotateTime = func(_ security.SecretItem, _ float64, _ float64) time.Duration {
+	test.SetForTest(t, &rotateTime, func(_ security.SecretItem, _ float64, _ float64) time.Duration {
 		return time.Millisecond * 200
-	}
+	})
 	fakeCACli, err = mock.NewMockCAClient(time.Millisecond*200, false)
 	if err != nil {
 		t.Fatalf("Error creating Mock CA client: %v", err)
@@ -908,3 +909,44 @@ func TestTryAddFileWatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestFederatedTrustBundles(t *testing.T) {
+	fakeCACli, err := mock.NewMockCAClient(time.Hour, false)
+	if err != nil {
+		t.Fatalf("Error creating Mock CA client: %v", err)
+	}
+	u := NewUpdateTracker(t)
+	sc := createCache(t, fakeCACli, u.Callback, security.Options{WorkloadRSAKeySize: 2048, TrustDomain: "cluster.local"})
+	if _, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName); err != nil {
+		t.Fatalf("failed to generate certificate: %v", err)
+	}
+	u.Reset()
+	caClientRootCert := []byte(strings.TrimRight(fakeCACli.GeneratedCerts[0][2], "\n"))
+	root, err := sc.GenerateSecret(security.RootCertReqResourceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.TrustDomainRootCerts != nil {
+		t.Fatalf("unexpected trust domain roots without federation: %v", root.TrustDomainRootCerts)
+	}
+
+	partnerCert, err := os.ReadFile(filepath.Join("./testdata", "root-cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A federated bundle of the trust domain of the workload does not replace the roots of the mesh.
+	assert.NoError(t, sc.UpdateFederatedTrustBundles(map[string][]byte{
+		"partner.example.org": partnerCert,
+		"cluster.local":       partnerCert,
+	}))
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	root, err = sc.GenerateSecret(security.RootCertReqResourceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, root.RootCert, caClientRootCert)
+	assert.Equal(t, root.TrustDomainRootCerts, map[string][]byte{
+		"cluster.local":       caClientRootCert,
+		"partner.example.org": partnerCert,
+	})
+}
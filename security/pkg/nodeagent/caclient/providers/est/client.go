@@ -0,0 +1,335 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package est implements a CA client for Enrollment over Secure Transport (RFC 7030) servers.
+package est
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/pkg/log"
+	sec_model "istio.io/istio/pkg/model"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/nodeagent/caclient"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// WellKnownPath is the path prefix of the EST operations. An optional CA label may follow it.
+	WellKnownPath = "/.well-known/est"
+
+	cacertsOperation      = "cacerts"
+	simpleEnrollOperation = "simpleenroll"
+
+	requestTimeout  = 30 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+var estClientLog = log.RegisterScope("estclient", "EST client debugging")
+
+// TLSOptions are the TLS settings of the connection to the EST server.
+type TLSOptions struct {
+	// RootCert is the file of the roots verifying the EST server certificate. The system roots are used if empty.
+	RootCert string
+	// Key and Cert are the files of the client certificate, used for TLS client authentication if both are set.
+	Key  string
+	Cert string
+}
+
+// ESTClient is a CA client requesting workload certificates from an EST server with the simpleenroll operation.
+type ESTClient struct {
+	opts     *security.Options
+	baseURL  *url.URL
+	client   *http.Client
+	provider credentials.PerRPCCredentials
+
+	mu sync.Mutex
+	// roots are the trust anchors returned by the last cacerts operation.
+	roots []string
+}
+
+var _ security.Client = &ESTClient{}
+
+// NewESTClient creates a CA client for the EST server at opts.CAEndpoint, either a host:port or an https URL whose
+// path is the optional CA label.
+func NewESTClient(opts *security.Options, tlsOpts *TLSOptions) (*ESTClient, error) {
+	baseURL, err := parseEndpoint(opts.CAEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := buildTLSConfig(baseURL, opts.CAEndpointSAN, tlsOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TLS config for EST server %s: %v", opts.CAEndpoint, err)
+	}
+	return &ESTClient{
+		opts:     opts,
+		baseURL:  baseURL,
+		provider: caclient.NewDefaultTokenProvider(opts),
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// parseEndpoint returns the base URL of the EST operations for the endpoint.
+func parseEndpoint(endpoint string) (*url.URL, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("EST server endpoint is required")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EST server endpoint %q: %v", endpoint, err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid EST server endpoint %q: only https is supported", endpoint)
+	}
+	label := strings.Trim(strings.TrimPrefix(u.Path, WellKnownPath), "/")
+	if strings.Contains(label, "/") {
+		return nil, fmt.Errorf("invalid EST server endpoint %q: invalid CA label %q", endpoint, label)
+	}
+	u.Path = WellKnownPath
+	if label != "" {
+		u.Path += "/" + label
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return u, nil
+}
+
+func buildTLSConfig(baseURL *url.URL, san string, tlsOpts *TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: baseURL.Hostname(),
+		MinVersion: tls.VersionTLS12,
+	}
+	if san != "" {
+		config.ServerName = san
+	}
+	if tlsOpts == nil {
+		sec_model.EnforceGoCompliance(config)
+		return config, nil
+	}
+	if tlsOpts.RootCert != "" {
+		rootCert, err := os.ReadFile(tlsOpts.RootCert)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(rootCert) {
+			return nil, fmt.Errorf("no valid root certificate in %s", tlsOpts.RootCert)
+		}
+	}
+	if tlsOpts.Key != "" && tlsOpts.Cert != "" {
+		key, cert := tlsOpts.Key, tlsOpts.Cert
+		// Load the client certificate for every handshake, so that it can be rotated.
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			expired, err := util.IsCertExpired(cert)
+			if err != nil || expired {
+				estClientLog.Warnf("client certificate %s is not usable, using token instead: %v", cert, err)
+				return &tls.Certificate{}, nil
+			}
+			certificate, err := tls.LoadX509KeyPair(cert, key)
+			if err != nil {
+				return nil, err
+			}
+			return &certificate, nil
+		}
+	}
+	sec_model.EnforceGoCompliance(config)
+	return config, nil
+}
+
+func (c *ESTClient) Close() {
+	c.client.CloseIdleConnections()
+}
+
+// CSRSign enrolls the CSR with the EST server. EST has no means to request a TTL, so certValidTTLInSec is ignored and
+// the server decides the certificate lifetime. The chain is completed with the intermediates of the cacerts
+// operation, whose trust anchors are then returned by GetRootCertBundle.
+func (c *ESTClient) CSRSign(csrPEM []byte, certValidTTLInSec int64) ([]string, error) {
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+	caCerts, err := c.fetchCACerts()
+	if err != nil {
+		return nil, err
+	}
+
+	body := base64.StdEncoding.EncodeToString(csr.Raw)
+	certs, err := c.do(http.MethodPost, simpleEnrollOperation, "application/pkcs10", []byte(body))
+	if err != nil {
+		return nil, err
+	}
+	estClientLog.Debugf("enrolled certificate with EST server %s (requested TTL of %ds is not supported)",
+		c.baseURL.Host, certValidTTLInSec)
+
+	chain, err := buildChain(csr, certs, caCerts)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.roots = trustAnchors(caCerts)
+	c.mu.Unlock()
+	return chain, nil
+}
+
+// GetRootCertBundle returns the trust anchors of the EST server.
+func (c *ESTClient) GetRootCertBundle() ([]string, error) {
+	c.mu.Lock()
+	roots := c.roots
+	c.mu.Unlock()
+	if roots != nil {
+		return roots, nil
+	}
+	caCerts, err := c.fetchCACerts()
+	if err != nil {
+		return nil, err
+	}
+	return trustAnchors(caCerts), nil
+}
+
+// trustAnchors returns the self-signed certificates of the cacerts operation or, if there is none, all of them.
+func trustAnchors(caCerts []*x509.Certificate) []string {
+	roots := []string{}
+	for _, cert := range caCerts {
+		if isSelfSigned(cert) {
+			roots = append(roots, encodePem(cert))
+		}
+	}
+	if len(roots) == 0 {
+		for _, cert := range caCerts {
+			roots = append(roots, encodePem(cert))
+		}
+	}
+	return roots
+}
+
+func (c *ESTClient) fetchCACerts() ([]*x509.Certificate, error) {
+	return c.do(http.MethodGet, cacertsOperation, "", nil)
+}
+
+// do runs an EST operation, whose response is a base64 encoded certs-only PKCS#7 message.
+func (c *ESTClient) do(method, operation, contentType string, body []byte) ([]*x509.Certificate, error) {
+	u := *c.baseURL
+	u.Path += "/" + operation
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Content-Transfer-Encoding", "base64")
+	}
+	md, err := c.provider.GetRequestMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range md {
+		req.Header.Set(k, v)
+	}
+	for k, v := range c.opts.CAHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("EST %s: %v", operation, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("EST %s: failed to read response: %v", operation, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusAccepted:
+		// Manual approval of the request, which will be retried with the rotation backoff.
+		return nil, fmt.Errorf("EST %s: request is pending, retry after %q", operation, resp.Header.Get("Retry-After"))
+	default:
+		return nil, fmt.Errorf("EST %s: unexpected status %d: %s", operation, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("EST %s: invalid base64 response: %v", operation, err)
+	}
+	certs, err := parseCertsOnly(der)
+	if err != nil {
+		return nil, fmt.Errorf("EST %s: %v", operation, err)
+	}
+	return certs, nil
+}
+
+// buildChain returns the PEM chain of the certificate issued for the CSR, from the leaf up to, but excluding, the
+// self-signed root.
+func buildChain(csr *x509.CertificateRequest, issued, caCerts []*x509.Certificate) ([]string, error) {
+	var leaf *x509.Certificate
+	for _, cert := range issued {
+		if pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(csr.PublicKey) {
+			leaf = cert
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("EST server returned no certificate for the CSR public key")
+	}
+	candidates := append(append([]*x509.Certificate{}, issued...), caCerts...)
+	chain := []string{encodePem(leaf)}
+	for cert := leaf; !isSelfSigned(cert) && len(chain) <= len(candidates); {
+		issuer := findIssuer(cert, candidates)
+		if issuer == nil || isSelfSigned(issuer) {
+			break
+		}
+		chain = append(chain, encodePem(issuer))
+		cert = issuer
+	}
+	return chain, nil
+}
+
+func findIssuer(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for _, c := range candidates {
+		if !c.Equal(cert) && bytes.Equal(c.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func encodePem(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
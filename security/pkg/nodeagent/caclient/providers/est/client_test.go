@@ -0,0 +1,251 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package est
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/security/pkg/credentialfetcher/plugin"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// marshalCertsOnly returns a DER encoded certs-only PKCS#7 message with the certificates.
+func marshalCertsOnly(t *testing.T, certs ...*x509.Certificate) []byte {
+	t.Helper()
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.Raw...)
+	}
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo:      contentInfo{ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:      asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+	})
+	assert.NoError(t, err)
+	der, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+	assert.NoError(t, err)
+	return der
+}
+
+// estServer is an in-process EST server signing with the sample intermediate CA.
+type estServer struct {
+	*httptest.Server
+	bundle    *util.KeyCertBundle
+	token     string
+	pending   bool
+	requests  []*http.Request
+	rootCAPem string
+}
+
+func newESTServer(t *testing.T) *estServer {
+	certs := filepath.Join(env.IstioSrc, "samples/certs")
+	bundle, err := util.NewVerifiedKeyCertBundleFromFile(filepath.Join(certs, "ca-cert.pem"), filepath.Join(certs, "ca-key.pem"),
+		[]string{filepath.Join(certs, "cert-chain.pem")}, filepath.Join(certs, "root-cert.pem"), "")
+	assert.NoError(t, err)
+	s := &estServer{bundle: bundle, token: "est-token"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(WellKnownPath+"/istio/cacerts", func(w http.ResponseWriter, req *http.Request) {
+		s.requests = append(s.requests, req)
+		signingCert, _, _, rootCert := s.bundle.GetAll()
+		root, err := util.ParsePemEncodedCertificate(rootCert)
+		assert.NoError(t, err)
+		s.write(t, w, signingCert, root)
+	})
+	mux.HandleFunc(WellKnownPath+"/istio/simpleenroll", func(w http.ResponseWriter, req *http.Request) {
+		s.requests = append(s.requests, req)
+		if req.Header.Get("Authorization") != "Bearer "+s.token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if s.pending {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		der, err := base64.StdEncoding.DecodeString(string(body))
+		assert.NoError(t, err)
+		csr, err := x509.ParseCertificateRequest(der)
+		assert.NoError(t, err)
+		signingCert, signingKey, _, _ := s.bundle.GetAll()
+		certDER, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey,
+			[]string{"spiffe://cluster.local/ns/default/sa/sleep"}, time.Hour, false)
+		assert.NoError(t, err)
+		cert, err := x509.ParseCertificate(certDER)
+		assert.NoError(t, err)
+		s.write(t, w, cert)
+	})
+	s.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	s.rootCAPem = filepath.Join(t.TempDir(), "root-cert.pem")
+	assert.NoError(t, os.WriteFile(s.rootCAPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0o600))
+	return s
+}
+
+func (s *estServer) write(t *testing.T, w http.ResponseWriter, certs ...*x509.Certificate) {
+	w.Header().Set("Content-Type", "application/pkcs7-mime; smime-type=certs-only")
+	w.Header().Set("Content-Transfer-Encoding", "base64")
+	// Servers may wrap the base64 response.
+	b64 := base64.StdEncoding.EncodeToString(marshalCertsOnly(t, certs...))
+	for len(b64) > 64 {
+		_, _ = w.Write([]byte(b64[:64] + "\r\n"))
+		b64 = b64[64:]
+	}
+	_, _ = w.Write([]byte(b64))
+}
+
+func newClient(t *testing.T, s *estServer) *ESTClient {
+	opts := &security.Options{
+		CAEndpoint:  s.URL + "/istio",
+		CredFetcher: plugin.CreateMockPlugin(s.token),
+		CAHeaders:   map[string]string{"X-Tenant": "mesh"},
+	}
+	c, err := NewESTClient(opts, &TLSOptions{RootCert: s.rootCAPem})
+	assert.NoError(t, err)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestCSRSign(t *testing.T) {
+	s := newESTServer(t)
+	c := newClient(t, s)
+
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/sleep", RSAKeySize: 2048})
+	assert.NoError(t, err)
+	chain, err := c.CSRSign(csrPEM, 3600)
+	assert.NoError(t, err)
+
+	// The leaf certificate followed by the intermediate CA.
+	assert.Equal(t, len(chain), 2)
+	signingCert, _, _, rootCert := s.bundle.GetAll()
+	assert.Equal(t, chain[1], encodePem(signingCert))
+	_, err = util.NewVerifiedKeyCertBundleFromPem([]byte(strings.Join(chain, "")), keyPEM, []byte(chain[1]), rootCert, nil)
+	assert.NoError(t, err)
+
+	roots, err := c.GetRootCertBundle()
+	assert.NoError(t, err)
+	assert.Equal(t, roots, []string{string(rootCert)})
+
+	assert.Equal(t, len(s.requests), 2)
+	enroll := s.requests[1]
+	assert.Equal(t, enroll.Method, http.MethodPost)
+	assert.Equal(t, enroll.Header.Get("Content-Type"), "application/pkcs10")
+	assert.Equal(t, enroll.Header.Get("X-Tenant"), "mesh")
+}
+
+func TestCSRSignErrors(t *testing.T) {
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/sleep", RSAKeySize: 2048})
+	assert.NoError(t, err)
+
+	t.Run("pending", func(t *testing.T) {
+		s := newESTServer(t)
+		s.pending = true
+		_, err := newClient(t, s).CSRSign(csrPEM, 3600)
+		assert.Error(t, err)
+		assert.Equal(t, strings.Contains(err.Error(), "pending"), true)
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		s := newESTServer(t)
+		c := newClient(t, s)
+		s.token = "other-token"
+		_, err := c.CSRSign(csrPEM, 3600)
+		assert.Error(t, err)
+		assert.Equal(t, strings.Contains(err.Error(), "401"), true)
+	})
+	t.Run("untrusted server", func(t *testing.T) {
+		s := newESTServer(t)
+		c, err := NewESTClient(&security.Options{CAEndpoint: s.URL}, &TLSOptions{
+			RootCert: filepath.Join(env.IstioSrc, "samples/certs/root-cert.pem"),
+		})
+		assert.NoError(t, err)
+		defer c.Close()
+		_, err = c.CSRSign(csrPEM, 3600)
+		assert.Error(t, err)
+	})
+	t.Run("invalid CSR", func(t *testing.T) {
+		_, err := newClient(t, newESTServer(t)).CSRSign([]byte("csr"), 3600)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseEndpoint(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		want     string
+	}{
+		{"est.example.com:8443", "https://est.example.com:8443/.well-known/est"},
+		{"https://est.example.com", "https://est.example.com/.well-known/est"},
+		{"https://est.example.com/istio", "https://est.example.com/.well-known/est/istio"},
+		{"https://est.example.com/.well-known/est/istio/", "https://est.example.com/.well-known/est/istio"},
+		{"http://est.example.com", ""},
+		{"https://est.example.com/a/b", ""},
+		{"", ""},
+	}
+	for _, tt := range cases {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			u, err := parseEndpoint(tt.endpoint)
+			if tt.want == "" {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, u.String(), tt.want)
+		})
+	}
+}
+
+func TestParseCertsOnly(t *testing.T) {
+	certs := filepath.Join(env.IstioSrc, "samples/certs")
+	pemBytes, err := os.ReadFile(filepath.Join(certs, "cert-chain.pem"))
+	assert.NoError(t, err)
+	var chain []*x509.Certificate
+	for block, rest := pem.Decode(pemBytes); block != nil; block, rest = pem.Decode(rest) {
+		c, err := x509.ParseCertificate(block.Bytes)
+		assert.NoError(t, err)
+		chain = append(chain, c)
+	}
+
+	got, err := parseCertsOnly(marshalCertsOnly(t, chain...))
+	assert.NoError(t, err)
+	assert.Equal(t, len(got), len(chain))
+	for i := range got {
+		assert.Equal(t, got[i].Equal(chain[i]), true)
+	}
+
+	_, err = parseCertsOnly(marshalCertsOnly(t))
+	assert.Error(t, err)
+	_, err = parseCertsOnly(chain[0].Raw)
+	assert.Error(t, err)
+}
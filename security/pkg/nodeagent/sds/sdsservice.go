@@ -42,8 +42,10 @@ import (
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	"istio.io/istio/pkg/backoff"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/model"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/pkg/xds"
 )
@@ -57,6 +59,9 @@ type sdsservice struct {
 	rootCaPath string
 	pkpConf    *mesh.PrivateKeyProvider
 
+	// rootGeneration is incremented on every push of the root certificate, to track which one Envoy applied.
+	rootGeneration uberatomic.Uint64
+
 	sync.Mutex
 	clients map[string]*Context
 }
@@ -70,6 +75,11 @@ type Context struct {
 type Watch struct {
 	sync.Mutex
 	watch *xds.WatchedResource
+	// rootNonce is the nonce of the last response with the root certificate, of generation rootNonceGeneration.
+	// rootAcked is the generation of the last root certificate acked by Envoy.
+	rootNonce           string
+	rootNonceGeneration uint64
+	rootAcked           uint64
 }
 
 // newSDSService creates Secret Discovery Service which implements envoy SDS API.
@@ -160,6 +170,9 @@ func (s *sdsservice) register(rpcs *grpc.Server) {
 }
 
 func (s *sdsservice) push(secretName string) {
+	if secretName == security.RootCertReqResourceName {
+		s.rootGeneration.Inc()
+	}
 	s.Lock()
 	defer s.Unlock()
 	for _, client := range s.clients {
@@ -172,6 +185,35 @@ func (s *sdsservice) push(secretName string) {
 	}
 }
 
+// rootCertAcked returns whether all the clients watching the root certificate acked it since its generation.
+func (s *sdsservice) rootCertAcked(generation uint64) bool {
+	s.Lock()
+	defer s.Unlock()
+	for _, client := range s.clients {
+		if client.w.requested(security.RootCertReqResourceName) && client.w.rootAckedGeneration() < generation {
+			return false
+		}
+	}
+	return true
+}
+
+// waitForRootCertAck waits until Envoy acked the root certificate pushed last. It returns false if stopped before.
+func (s *sdsservice) waitForRootCertAck(stop <-chan struct{}) bool {
+	generation := s.rootGeneration.Load()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !s.rootCertAcked(generation) {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return false
+		case <-s.stop:
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Context) XdsConnection() *xds.Connection {
 	return &c.BaseConnection
 }
@@ -230,6 +272,28 @@ func (w *Watch) GetID() string {
 	return ""
 }
 
+// sentRoot records a response with the root certificate of the generation.
+func (w *Watch) sentRoot(nonce string, generation uint64) {
+	w.Lock()
+	defer w.Unlock()
+	w.rootNonce, w.rootNonceGeneration = nonce, generation
+}
+
+// acked records the ACK of a response.
+func (w *Watch) acked(nonce string) {
+	w.Lock()
+	defer w.Unlock()
+	if nonce == w.rootNonce {
+		w.rootAcked = w.rootNonceGeneration
+	}
+}
+
+func (w *Watch) rootAckedGeneration() uint64 {
+	w.Lock()
+	defer w.Unlock()
+	return w.rootAcked
+}
+
 func (w *Watch) requested(secretName string) bool {
 	w.Lock()
 	defer w.Unlock()
@@ -240,6 +304,9 @@ func (w *Watch) requested(secretName string) bool {
 }
 
 func (c *Context) Process(req *discovery.DiscoveryRequest) error {
+	if req.ErrorDetail == nil && req.ResponseNonce != "" {
+		c.w.acked(req.ResponseNonce)
+	}
 	shouldRespond, delta := xds.ShouldRespond(c.Watcher(), c.XdsConnection().ID(), req)
 	if !shouldRespond {
 		return nil
@@ -248,11 +315,7 @@ func (c *Context) Process(req *discovery.DiscoveryRequest) error {
 	if !delta.IsEmpty() {
 		resources = delta.Subscribed.UnsortedList()
 	}
-	res, err := c.s.generate(resources)
-	if err != nil {
-		return err
-	}
-	return xds.Send(c, res)
+	return c.send(resources)
 }
 
 func (c *Context) Push(ev any) error {
@@ -260,11 +323,24 @@ func (c *Context) Push(ev any) error {
 	if !c.w.requested(secretName) {
 		return nil
 	}
-	res, err := c.s.generate([]string{secretName})
+	return c.send([]string{secretName})
+}
+
+// send generates and sends the secrets, recording the generation of the root certificate sent.
+func (c *Context) send(resourceNames []string) error {
+	// Read before generating the secrets, which are at least as recent.
+	generation := c.s.rootGeneration.Load()
+	res, err := c.s.generate(resourceNames)
 	if err != nil {
 		return err
 	}
-	return xds.Send(c, res)
+	if err := xds.Send(c, res); err != nil {
+		return err
+	}
+	if slices.Contains(resourceNames, security.RootCertReqResourceName) {
+		c.w.sentRoot(res.Nonce, generation)
+	}
+	return nil
 }
 
 // StreamSecrets serves SDS discovery requests and SDS push requests
@@ -288,6 +364,26 @@ func (s *sdsservice) Close() {
 	close(s.stop)
 }
 
+// spiffeValidatorConfig returns the config of the SPIFFE certificate validator of Envoy, validating the peers against
+// the root certificates of the trust domain of their SPIFFE ID.
+func spiffeValidatorConfig(rootCerts map[string][]byte) *core.TypedExtensionConfig {
+	config := &tls.SPIFFECertValidatorConfig{}
+	for _, td := range slices.Sort(maps.Keys(rootCerts)) {
+		config.TrustDomains = append(config.TrustDomains, &tls.SPIFFECertValidatorConfig_TrustDomain{
+			Name: td,
+			TrustBundle: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{
+					InlineBytes: rootCerts[td],
+				},
+			},
+		})
+	}
+	return &core.TypedExtensionConfig{
+		Name:        "envoy.tls.cert_validator.spiffe",
+		TypedConfig: protoconv.MessageToAny(config),
+	}
+}
+
 // toEnvoySecret converts a security.SecretItem to an Envoy tls.Secret
 func toEnvoySecret(s *security.SecretItem, caRootPath string, pkpConf *mesh.PrivateKeyProvider) *tls.Secret {
 	secret := &tls.Secret{
@@ -310,6 +406,11 @@ func toEnvoySecret(s *security.SecretItem, caRootPath string, pkpConf *mesh.Priv
 				},
 			},
 		}
+		if len(s.TrustDomainRootCerts) > 0 {
+			// With federated trust domains, each peer is only validated against the roots of its own trust domain.
+			secretValidationContext.ValidationContext.TrustedCa = nil
+			secretValidationContext.ValidationContext.CustomValidatorConfig = spiffeValidatorConfig(s.TrustDomainRootCerts)
+		}
 
 		if features.EnableCACRL {
 			// Check if the plugged-in CA CRL file is present and update the secretValidationContext accordingly.
@@ -15,16 +15,19 @@ package sds
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	cryptomb "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/private_key_providers/cryptomb/v3alpha"
 	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/types/known/durationpb"
@@ -35,6 +38,9 @@ import (
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/log"
 	ca2 "istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test/util/assert"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
 )
 
 var (
@@ -272,6 +278,53 @@ func TestSDS(t *testing.T) {
 		// No need to push a new root if just the cert changes
 		root.ExpectNoResponse(t)
 	})
+	t.Run("root cert acks", func(t *testing.T) {
+		s := setupSDS(t)
+		root := s.Connect()
+		s.Verify(root.RequestResponseAck(t, &discovery.DiscoveryRequest{ResourceNames: []string{rootResourceName}}), expectRoot)
+
+		wait := func(stop chan struct{}) chan bool {
+			acked := make(chan bool, 1)
+			go func() {
+				acked <- s.server.WaitForRootCertAck(stop)
+			}()
+			return acked
+		}
+		expectNoAck := func(acked chan bool) {
+			t.Helper()
+			select {
+			case <-acked:
+				t.Fatal("root cert acked before Envoy acked it")
+			case <-time.After(300 * time.Millisecond):
+			}
+		}
+
+		// The root cert is acked once Envoy acked the push.
+		s.UpdateSecret(rootResourceName, &ca2.SecretItem{RootCert: []byte{0o5}, ResourceName: rootResourceName})
+		acked := wait(make(chan struct{}))
+		resp := root.ExpectResponse(t)
+		expectNoAck(acked)
+		root.Request(t, &discovery.DiscoveryRequest{
+			ResourceNames: []string{rootResourceName},
+			ResponseNonce: resp.Nonce,
+			VersionInfo:   resp.VersionInfo,
+		})
+		assert.Equal(t, <-acked, true)
+
+		// A rejected root cert is not acked.
+		s.UpdateSecret(rootResourceName, &ca2.SecretItem{RootCert: []byte{0o6}, ResourceName: rootResourceName})
+		stop := make(chan struct{})
+		acked = wait(stop)
+		resp = root.ExpectResponse(t)
+		root.Request(t, &discovery.DiscoveryRequest{
+			ResourceNames: []string{rootResourceName},
+			ResponseNonce: resp.Nonce,
+			ErrorDetail:   &status.Status{Message: "rejected"},
+		})
+		expectNoAck(acked)
+		close(stop)
+		assert.Equal(t, <-acked, false)
+	})
 	t.Run("reconnect", func(t *testing.T) {
 		s := setupSDS(t)
 		c := s.Connect()
@@ -348,3 +401,62 @@ func setupConnection(socket string) (*grpc.ClientConn, error) {
 
 	return conn, nil
 }
+
+func TestFederatedRootSecret(t *testing.T) {
+	meshRoot, meshKey := genCert(t, pkiutil.CertOptions{Org: "mesh", IsCA: true, IsSelfSigned: true})
+	partnerRoot, partnerKey := genCert(t, pkiutil.CertOptions{Org: "partner", IsCA: true, IsSelfSigned: true})
+	secret := toEnvoySecret(&ca2.SecretItem{
+		ResourceName: rootResourceName,
+		RootCert:     meshRoot,
+		TrustDomainRootCerts: map[string][]byte{
+			"cluster.local":       meshRoot,
+			"partner.example.org": partnerRoot,
+		},
+	}, "", nil)
+
+	validation := secret.GetValidationContext()
+	assert.Equal(t, validation.GetTrustedCa(), nil)
+	config := &tlsv3.SPIFFECertValidatorConfig{}
+	assert.NoError(t, validation.GetCustomValidatorConfig().GetTypedConfig().UnmarshalTo(config))
+	// Envoy validates the peers against the trust bundle of the trust domain of their SPIFFE ID.
+	verifier := spiffe.NewPeerCertVerifier()
+	for _, td := range config.GetTrustDomains() {
+		assert.NoError(t, verifier.AddMappingFromPEM(td.GetName(), td.GetTrustBundle().GetInlineBytes()))
+	}
+	verify := func(signer, signerKey []byte, id string) error {
+		key, err := pkiutil.ParsePemEncodedKey(signerKey)
+		assert.NoError(t, err)
+		cert, _ := genCert(t, pkiutil.CertOptions{
+			Host:       id,
+			SignerCert: parseCert(t, signer),
+			SignerPriv: key,
+			IsClient:   true,
+			IsServer:   true,
+		})
+		return verifier.VerifyPeerCert([][]byte{parseCert(t, cert).Raw}, nil)
+	}
+	assert.NoError(t, verify(meshRoot, meshKey, "spiffe://cluster.local/ns/foo/sa/bar"))
+	assert.NoError(t, verify(partnerRoot, partnerKey, "spiffe://partner.example.org/ns/foo/sa/bar"))
+	// A federated CA cannot validate the identities of the mesh, nor the mesh CA those of a federated trust domain.
+	assert.Error(t, verify(partnerRoot, partnerKey, "spiffe://cluster.local/ns/foo/sa/bar"))
+	assert.Error(t, verify(meshRoot, meshKey, "spiffe://partner.example.org/ns/foo/sa/bar"))
+
+	// Without federated trust domains, the roots are the trusted CA.
+	secret = toEnvoySecret(&ca2.SecretItem{ResourceName: rootResourceName, RootCert: meshRoot}, "", nil)
+	assert.Equal(t, secret.GetValidationContext().GetTrustedCa().GetInlineBytes(), meshRoot)
+	assert.Equal(t, secret.GetValidationContext().GetCustomValidatorConfig(), nil)
+}
+
+func genCert(t *testing.T, opts pkiutil.CertOptions) ([]byte, []byte) {
+	opts.TTL = time.Hour
+	opts.RSAKeySize = 2048
+	cert, key, err := pkiutil.GenCertKeyFromOptions(opts)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+func parseCert(t *testing.T, cert []byte) *x509.Certificate {
+	c, err := pkiutil.ParsePemEncodedCertificate(cert)
+	assert.NoError(t, err)
+	return c
+}
@@ -58,6 +58,15 @@ func (s *Server) OnSecretUpdate(resourceName string) {
 	s.workloadSds.push(resourceName)
 }
 
+// WaitForRootCertAck waits until Envoy acked the root certificate pushed last, i.e. applied the current trust bundle.
+// It returns false if stopped before.
+func (s *Server) WaitForRootCertAck(stop <-chan struct{}) bool {
+	if s.workloadSds == nil {
+		return true
+	}
+	return s.workloadSds.waitForRootCertAck(stop)
+}
+
 // Stop closes the gRPC server and debug server.
 func (s *Server) Stop() {
 	if s == nil {
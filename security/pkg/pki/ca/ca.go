@@ -119,6 +119,15 @@ type IstioCAOptions struct {
 
 	// OnRootCertUpdate is the cb which can only be called by self-signed root cert rotator
 	OnRootCertUpdate func() error
+
+	// Revocation enables the revocation of the issued workload certificates, if set.
+	Revocation *RevocationOptions
+
+	// RootRotation enables the staged rotation of the root of the CA, if set.
+	RootRotation *RootRotationOptions
+
+	// SigningKey keeps the signing key of a plugged-in CA in a backend, such as an HSM, rather than in memory, if set.
+	SigningKey *SigningKeyOptions
 }
 
 type RootCertUpdateFunc func() error
@@ -133,6 +142,7 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 		CAType:         selfSignedCA,
 		DefaultCertTTL: defaultCertTTL,
 		MaxCertTTL:     maxCertTTL,
+		CARSAKeySize:   caRSAKeySize,
 		RotatorConfig: &SelfSignedCARootCertRotatorConfig{
 			CheckInterval:      rootCertCheckInverval,
 			caCertTTL:          caCertTTL,
@@ -363,6 +373,15 @@ type IstioCA struct {
 	// rootCertRotator periodically rotates self-signed root cert for CA. It is nil
 	// if CA is not self-signed CA.
 	rootCertRotator *SelfSignedCARootCertRotator
+
+	// revocation maintains the revocation list and CRL of the CA. It is nil if revocation is not enabled.
+	revocation *revocationManager
+
+	// rootRotation drives the root rotations of the CA. It is nil if root rotation is not enabled.
+	rootRotation *rootRotationController
+
+	// signingKey reloads the signing key kept by a backend. It is nil if the signing key is in memory.
+	signingKey *signingKeyReloader
 }
 
 // NewIstioCA returns a new IstioCA instance.
@@ -377,6 +396,10 @@ func NewIstioCA(opts *IstioCAOptions) (*IstioCA, error) {
 		ca.rootCertRotator = NewSelfSignedCARootCertRotator(opts.RotatorConfig, ca, opts.OnRootCertUpdate)
 	}
 
+	if opts.Revocation != nil {
+		ca.revocation = newRevocationManager(*opts.Revocation, opts.MaxCertTTL, opts.KeyCertBundle)
+	}
+
 	// if CA cert becomes invalid before workload cert it's going to cause workload cert to be invalid too,
 	// however citatel won't rotate if that happens, this function will prevent that using cert chain TTL as
 	// the workload TTL
@@ -386,6 +409,18 @@ func NewIstioCA(opts *IstioCAOptions) (*IstioCA, error) {
 	}
 	ca.defaultCertTTL = defaultCertTTL
 
+	if opts.RootRotation != nil {
+		var selfSigned *SelfSignedCARootCertRotatorConfig
+		if opts.CAType == selfSignedCA {
+			selfSigned = opts.RotatorConfig
+		}
+		ca.rootRotation = newRootRotationController(*opts.RootRotation, ca, selfSigned)
+	}
+
+	if opts.SigningKey != nil {
+		ca.signingKey = newSigningKeyReloader(*opts.SigningKey, opts.KeyCertBundle)
+	}
+
 	return ca, nil
 }
 
@@ -394,6 +429,18 @@ func (ca *IstioCA) Run(stopChan chan struct{}) {
 		// Start root cert rotator in a separate goroutine.
 		go ca.rootCertRotator.Run(stopChan)
 	}
+	if ca.revocation != nil {
+		// Start the revocation list and CRL refresh in a separate goroutine.
+		go ca.revocation.run(stopChan)
+	}
+	if ca.rootRotation != nil {
+		// Start the root rotation controller in a separate goroutine.
+		go ca.rootRotation.run(stopChan)
+	}
+	if ca.signingKey != nil && ca.signingKey.opts.ReloadInterval > 0 {
+		// Start checking for new versions of the signing key in a separate goroutine.
+		go ca.signingKey.run(stopChan)
+	}
 }
 
 // Sign takes a PEM-encoded CSR and cert opts, and returns a signed certificate.
@@ -419,6 +466,78 @@ func (ca *IstioCA) GetCAKeyCertBundle() *util.KeyCertBundle {
 	return ca.keyCertBundle
 }
 
+// UpdateKeyCertBundleFromFile verifies and updates the key and certificates of a plugged-in CA from the files. The
+// signing key is loaded from its backend rather than from the signing key file, if it is kept by a backend.
+func (ca *IstioCA) UpdateKeyCertBundleFromFile(fileBundle SigningCAFileBundle) error {
+	if ca.signingKey != nil {
+		return ca.signingKey.reload(fileBundle)
+	}
+	return ca.keyCertBundle.UpdateVerifiedKeyCertBundleFromFile(
+		fileBundle.SigningCertFile,
+		fileBundle.SigningKeyFile,
+		fileBundle.CertChainFiles,
+		fileBundle.RootCertFile,
+		fileBundle.CRLFile,
+	)
+}
+
+// RevokeCertificate revokes the workload certificate with the given serial number, in hexadecimal.
+func (ca *IstioCA) RevokeCertificate(serialNumber string, reason int) ([]Revocation, error) {
+	if ca.revocation == nil {
+		return nil, fmt.Errorf("certificate revocation is not enabled")
+	}
+	return ca.revocation.revokeSerialNumber(serialNumber, reason)
+}
+
+// RevokeIdentity revokes all the workload certificates issued for the identity until now, and denies new ones for it
+// until the revocation expires. The certificates are found in the record of the issued certificates persisted by all
+// the replicas, which misses the ones issued by a replica shortly before it stopped, before it persisted them.
+func (ca *IstioCA) RevokeIdentity(identity string, reason int) ([]Revocation, error) {
+	if ca.revocation == nil {
+		return nil, fmt.Errorf("certificate revocation is not enabled")
+	}
+	return ca.revocation.revokeIdentity(identity, reason)
+}
+
+// RefreshRevocations reloads the revocation list from its store and regenerates the CRL, e.g. when the store was
+// updated by another replica.
+func (ca *IstioCA) RefreshRevocations() error {
+	if ca.revocation == nil {
+		return nil
+	}
+	return ca.revocation.refresh()
+}
+
+// Revocations returns the revocation list of the CA.
+func (ca *IstioCA) Revocations() []Revocation {
+	if ca.revocation == nil {
+		return nil
+	}
+	return ca.revocation.getRevocations()
+}
+
+// GetCRLPem returns the PEM encoded CRL of the certificates revoked by the CA, or nil if none is revoked.
+func (ca *IstioCA) GetCRLPem() []byte {
+	if ca.revocation == nil {
+		return nil
+	}
+	return ca.revocation.getCRL()
+}
+
+// RootRotation returns the root rotation in progress or the last one, or nil if there is none.
+func (ca *IstioCA) RootRotation() (*RootRotation, error) {
+	if ca.rootRotation == nil {
+		return nil, fmt.Errorf("root rotation is not enabled")
+	}
+	return ca.rootRotation.getRotation(), nil
+}
+
+// RootRotationInProgress returns whether a root rotation is in progress, during which the roots and signing certificate
+// of the CA must not be updated otherwise.
+func (ca *IstioCA) RootRotationInProgress() bool {
+	return ca.rootRotation != nil && ca.rootRotation.inProgress()
+}
+
 // GenKeyCert generates a certificate signed by the CA,
 // returns the certificate chain and the private key.
 func (ca *IstioCA) GenKeyCert(hostnames []string, certTTL time.Duration, checkLifetime bool) ([]byte, []byte, error) {
@@ -501,10 +620,19 @@ func (ca *IstioCA) sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 			"requested TTL %s is greater than the max allowed TTL %s", requestedLifetime, ca.maxCertTTL))
 	}
 
+	if ca.revocation != nil && !forCA {
+		if err := ca.revocation.checkIdentities(subjectIDs, time.Now()); err != nil {
+			return nil, caerror.NewError(caerror.CSRError, err)
+		}
+	}
+
 	certBytes, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, subjectIDs, lifetime, forCA)
 	if err != nil {
 		return nil, caerror.NewError(caerror.CertGenError, err)
 	}
+	if ca.revocation != nil && !forCA {
+		ca.revocation.recordIssued(certBytes, subjectIDs)
+	}
 
 	block := &pem.Block{
 		Type:  "CERTIFICATE",
@@ -567,7 +567,7 @@ func TestSignCSR(t *testing.T) {
 			maxTTL:       365 * 24 * time.Hour,
 			requestedTTL: 30 * 24 * time.Hour,
 			verifyFields: util.VerifyFields{
-				KeyUsage: x509.KeyUsageCertSign,
+				KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 				IsCA:     true,
 				Host:     subjectID,
 			},
@@ -582,7 +582,7 @@ func TestSignCSR(t *testing.T) {
 			maxTTL:       365 * 24 * time.Hour,
 			requestedTTL: 30 * 24 * time.Hour,
 			verifyFields: util.VerifyFields{
-				KeyUsage: x509.KeyUsageCertSign,
+				KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 				IsCA:     true,
 				Host:     subjectID,
 			},
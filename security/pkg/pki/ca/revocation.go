@@ -0,0 +1,688 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// RevocationConfigMap is the name of the ConfigMap persisting the revocation list of the Istio CA.
+	RevocationConfigMap = "istio-ca-revocations"
+	// revocationsKey is the key of the revocation list in the ConfigMap.
+	revocationsKey = "revocations.json"
+	// IssuedConfigMap is the name of the ConfigMap persisting the record of the certificates issued by the Istio CA.
+	// It is separate from the revocation list, which is watched by the replicas.
+	IssuedConfigMap = "istio-ca-issued-certificates"
+	// issuedKey is the key of the record of the issued certificates in the ConfigMap.
+	issuedKey = "issued.json"
+	// maxIssuedSize bounds the size of the persisted record of the issued certificates, below the size limit of a
+	// ConfigMap. The oldest certificates are dropped from the record beyond it.
+	maxIssuedSize = 900 * 1024
+	// issuedPruneInterval is the minimum interval between the removals of the expired certificates from the record
+	// of the issued certificates.
+	issuedPruneInterval = time.Minute
+	// issuedFlushInterval is the interval at which the certificates issued by a replica are persisted.
+	issuedFlushInterval = 10 * time.Second
+)
+
+// Revocation is an entry of the revocation list of the CA. It either revokes the certificate with a serial number, or
+// all the certificates issued for an identity before the revocation. The CA refuses to sign new certificates for a
+// revoked identity until its entry expires.
+type Revocation struct {
+	// SerialNumber of the revoked certificate, in lowercase hexadecimal.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// Identity of the revoked certificates. For a certificate revoked by serial number, it is informative.
+	Identity string `json:"identity,omitempty"`
+	// Reason is the CRL reason code, as defined in RFC 5280.
+	Reason    int       `json:"reason,omitempty"`
+	RevokedAt time.Time `json:"revokedAt"`
+	// ExpiresAt is the time after which all the revoked certificates are expired, and the entry can be removed.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// revocationReasons are the CRL reason codes defined in RFC 5280, which can be set when revoking a certificate.
+var revocationReasons = map[string]int{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"cACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+	"certificateHold":      6,
+	"privilegeWithdrawn":   9,
+	"aACompromise":         10,
+}
+
+// ParseRevocationReason returns the CRL reason code for a reason name, e.g. keyCompromise.
+// An empty name is the unspecified reason.
+func ParseRevocationReason(name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+	for n, code := range revocationReasons {
+		if strings.EqualFold(n, name) {
+			return code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown revocation reason %q", name)
+}
+
+// ParseSerialNumber returns the normalized form of a certificate serial number, given in hexadecimal with optional
+// colon separators, as printed by `openssl x509 -serial`.
+func ParseSerialNumber(serial string) (string, error) {
+	s := strings.TrimPrefix(strings.ToLower(strings.ReplaceAll(serial, ":", "")), "0x")
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok || n.Sign() <= 0 {
+		return "", fmt.Errorf("invalid serial number %q", serial)
+	}
+	return n.Text(16), nil
+}
+
+// IssuedCertificate is a workload certificate issued by the CA, recorded so that it can be revoked by identity.
+type IssuedCertificate struct {
+	// SerialNumber of the certificate, in lowercase hexadecimal.
+	SerialNumber string    `json:"serialNumber"`
+	Identities   []string  `json:"identities,omitempty"`
+	IssuedAt     time.Time `json:"issuedAt"`
+	NotAfter     time.Time `json:"notAfter"`
+}
+
+// RevocationStore persists the revocation list and the record of the issued certificates, so that they survive
+// restarts and are shared by the CA replicas.
+type RevocationStore interface {
+	// Update persists the revocation list returned by the function applied to the current one, if it changed.
+	// It returns the resulting revocation list.
+	Update(func([]Revocation) ([]Revocation, bool)) ([]Revocation, error)
+	// RecordIssued adds the issued certificates to the persisted record, and removes the ones expired at now.
+	// It returns the resulting record, including the certificates issued by the other replicas.
+	RecordIssued(issued []IssuedCertificate, now time.Time) ([]IssuedCertificate, error)
+}
+
+type memoryRevocationStore struct {
+	mu          sync.Mutex
+	revocations []Revocation
+	issued      []IssuedCertificate
+}
+
+// NewMemoryRevocationStore returns a RevocationStore which does not persist the revocation list.
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{}
+}
+
+func (m *memoryRevocationStore) Update(f func([]Revocation) ([]Revocation, bool)) ([]Revocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if updated, changed := f(append([]Revocation(nil), m.revocations...)); changed {
+		m.revocations = updated
+	}
+	return append([]Revocation(nil), m.revocations...), nil
+}
+
+func (m *memoryRevocationStore) RecordIssued(issued []IssuedCertificate, now time.Time) ([]IssuedCertificate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.issued = mergeIssued(m.issued, issued, now)
+	return append([]IssuedCertificate(nil), m.issued...), nil
+}
+
+// mergeIssued returns the record of the issued certificates with the added ones, without the expired ones, and
+// without the oldest ones beyond maxIssuedSize.
+func mergeIssued(current, added []IssuedCertificate, now time.Time) []IssuedCertificate {
+	bySerial := make(map[string]IssuedCertificate, len(current)+len(added))
+	for _, issued := range append(current, added...) {
+		if issued.NotAfter.After(now) {
+			bySerial[issued.SerialNumber] = issued
+		}
+	}
+	result := make([]IssuedCertificate, 0, len(bySerial))
+	for _, issued := range bySerial {
+		result = append(result, issued)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].IssuedAt.Equal(result[j].IssuedAt) {
+			return result[i].IssuedAt.After(result[j].IssuedAt)
+		}
+		return result[i].SerialNumber < result[j].SerialNumber
+	})
+	size := 0
+	for i, issued := range result {
+		data, _ := json.Marshal(issued)
+		size += len(data) + 1
+		if size > maxIssuedSize {
+			pkiCaLog.Warnf("dropping the %d oldest issued certificates from the record, which is full: "+
+				"revoking their identity does not revoke them", len(result)-i)
+			return result[:i]
+		}
+	}
+	return result
+}
+
+type configMapRevocationStore struct {
+	client    corev1.ConfigMapsGetter
+	namespace string
+}
+
+// NewConfigMapRevocationStore returns a RevocationStore persisting the revocation list in the istio-ca-revocations
+// ConfigMap of the namespace, and the record of the issued certificates in the istio-ca-issued-certificates one.
+func NewConfigMapRevocationStore(client corev1.ConfigMapsGetter, namespace string) RevocationStore {
+	return &configMapRevocationStore{client: client, namespace: namespace}
+}
+
+func (c *configMapRevocationStore) Update(f func([]Revocation) ([]Revocation, bool)) ([]Revocation, error) {
+	var result []Revocation
+	err := c.update(RevocationConfigMap, revocationsKey, func(data string) (string, bool, error) {
+		var revocations []Revocation
+		if data != "" {
+			if err := json.Unmarshal([]byte(data), &revocations); err != nil {
+				return "", false, fmt.Errorf("invalid revocation list in ConfigMap %s/%s: %v", c.namespace, RevocationConfigMap, err)
+			}
+		}
+		updated, changed := f(revocations)
+		result = revocations
+		if !changed {
+			return "", false, nil
+		}
+		res, err := json.Marshal(updated)
+		if err != nil {
+			return "", false, err
+		}
+		result = updated
+		return string(res), true, nil
+	})
+	return result, err
+}
+
+func (c *configMapRevocationStore) RecordIssued(issued []IssuedCertificate, now time.Time) ([]IssuedCertificate, error) {
+	var result []IssuedCertificate
+	err := c.update(IssuedConfigMap, issuedKey, func(data string) (string, bool, error) {
+		var current []IssuedCertificate
+		if data != "" {
+			if err := json.Unmarshal([]byte(data), &current); err != nil {
+				return "", false, fmt.Errorf("invalid issued certificates in ConfigMap %s/%s: %v", c.namespace, IssuedConfigMap, err)
+			}
+		}
+		result = mergeIssued(current, issued, now)
+		if len(issued) == 0 && len(result) == len(current) {
+			return "", false, nil
+		}
+		res, err := json.Marshal(result)
+		if err != nil {
+			return "", false, err
+		}
+		return string(res), true, nil
+	})
+	return result, err
+}
+
+// update applies the function to the value of the key of a ConfigMap, and persists the value it returns if it
+// changed, creating the ConfigMap if needed.
+func (c *configMapRevocationStore) update(name, key string, f func(string) (string, bool, error)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := c.client.ConfigMaps(c.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if apierror.IsNotFound(err) {
+			cm = nil
+		} else if err != nil {
+			return err
+		}
+		var current string
+		if cm != nil {
+			current = cm.Data[key]
+		}
+		data, changed, err := f(current)
+		if err != nil || !changed {
+			return err
+		}
+		if cm == nil {
+			cm = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.namespace},
+				Data:       map[string]string{key: data},
+			}
+			_, err = c.client.ConfigMaps(c.namespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+			if apierror.IsAlreadyExists(err) {
+				// Created concurrently by another replica, retry as a conflict.
+				return apierror.NewConflict(v1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = data
+		_, err = c.client.ConfigMaps(c.namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// RevocationOptions configures the revocation of the certificates issued by the Istio CA.
+type RevocationOptions struct {
+	Store RevocationStore
+	// RefreshInterval is the interval at which the revocation list is reloaded from the store, to pick up the
+	// revocations made through other replicas, and the CRL is regenerated. Stores which can be watched should also
+	// call IstioCA.RefreshRevocations on changes, for revocations to take effect on all the replicas right away.
+	RefreshInterval time.Duration
+	// CRLValidity is the validity of the generated CRLs. It must be longer than RefreshInterval, as proxies reject
+	// the certificates of the CA once its CRL has expired.
+	CRLValidity time.Duration
+	// OnCRLUpdate is called when the CRL has changed.
+	OnCRLUpdate func()
+}
+
+// revocationManager maintains the revocation list and the CRL of the CA.
+type revocationManager struct {
+	opts          RevocationOptions
+	maxCertTTL    time.Duration
+	keyCertBundle *util.KeyCertBundle
+
+	mu sync.RWMutex
+	// issued holds the certificates issued by all the replicas, as last read from the store, and the ones issued
+	// by this replica since.
+	issued map[string]IssuedCertificate
+	// pending holds the certificates issued by this replica which are not persisted yet.
+	pending     []IssuedCertificate
+	lastPruned  time.Time
+	revocations []Revocation
+	crl         []byte
+	crlNumber   int64
+	// crlSigner is the signing certificate the CRL was generated with.
+	crlSigner []byte
+}
+
+func newRevocationManager(opts RevocationOptions, maxCertTTL time.Duration, keyCertBundle *util.KeyCertBundle) *revocationManager {
+	if opts.Store == nil {
+		opts.Store = NewMemoryRevocationStore()
+	}
+	return &revocationManager{
+		opts:          opts,
+		maxCertTTL:    maxCertTTL,
+		keyCertBundle: keyCertBundle,
+		issued:        map[string]IssuedCertificate{},
+	}
+}
+
+// checkIdentities returns an error if one of the identities is revoked.
+func (r *revocationManager) checkIdentities(identities []string, now time.Time) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rev := range r.revocations {
+		if rev.SerialNumber != "" || !rev.ExpiresAt.After(now) {
+			continue
+		}
+		for _, id := range identities {
+			if id == rev.Identity {
+				return fmt.Errorf("identity %s is revoked until %s", id, rev.ExpiresAt.Format(time.RFC3339))
+			}
+		}
+	}
+	return nil
+}
+
+// recordIssued records a workload certificate issued by the CA.
+func (r *revocationManager) recordIssued(certDER []byte, identities []string) {
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		pkiCaLog.Warnf("failed to parse issued certificate: %v", err)
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.lastPruned) >= issuedPruneInterval {
+		r.pruneIssuedLocked(now)
+	}
+	issued := IssuedCertificate{
+		SerialNumber: cert.SerialNumber.Text(16),
+		Identities:   identities,
+		IssuedAt:     now,
+		NotAfter:     cert.NotAfter,
+	}
+	r.issued[issued.SerialNumber] = issued
+	r.pending = append(r.pending, issued)
+}
+
+// flushIssued persists the certificates issued by this replica, and reloads the ones issued by all the replicas.
+func (r *revocationManager) flushIssued(now time.Time) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	persisted, err := r.opts.Store.RecordIssued(pending, now)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.pending = append(pending, r.pending...)
+		return fmt.Errorf("failed to persist the issued certificates: %v", err)
+	}
+	issued := make(map[string]IssuedCertificate, len(persisted)+len(r.pending))
+	for _, cert := range persisted {
+		issued[cert.SerialNumber] = cert
+	}
+	for _, cert := range r.pending {
+		issued[cert.SerialNumber] = cert
+	}
+	r.issued = issued
+	r.lastPruned = now
+	return nil
+}
+
+// pruneIssuedLocked removes the expired certificates from the record of the issued certificates, as they no longer
+// need to be revoked. The lock must be held.
+func (r *revocationManager) pruneIssuedLocked(now time.Time) {
+	for serial, issued := range r.issued {
+		if !issued.NotAfter.After(now) {
+			delete(r.issued, serial)
+		}
+	}
+	r.lastPruned = now
+}
+
+// revoke adds a revocation to the revocation list, regenerates the CRL and returns the resulting entries: the
+// revoked certificate, or the entry of the identity along with the recorded certificates issued for it.
+func (r *revocationManager) revoke(revocation Revocation) ([]Revocation, error) {
+	if _, err := r.opts.Store.Update(func(current []Revocation) ([]Revocation, bool) {
+		for _, rev := range current {
+			if revocation.SerialNumber != "" && rev.SerialNumber == revocation.SerialNumber {
+				// Already revoked.
+				return current, false
+			}
+		}
+		return append(current, revocation), true
+	}); err != nil {
+		return nil, fmt.Errorf("failed to persist the revocation list: %v", err)
+	}
+	if err := r.refresh(); err != nil {
+		return nil, err
+	}
+	var result []Revocation
+	for _, rev := range r.getRevocations() {
+		if revocation.SerialNumber != "" {
+			if rev.SerialNumber == revocation.SerialNumber {
+				result = append(result, rev)
+			}
+		} else if rev.Identity == revocation.Identity && rev.RevokedAt.Equal(revocation.RevokedAt) {
+			result = append(result, rev)
+		}
+	}
+	return result, nil
+}
+
+// revokeSerialNumber revokes the certificate with the serial number.
+func (r *revocationManager) revokeSerialNumber(serial string, reason int) ([]Revocation, error) {
+	serial, err := ParseSerialNumber(serial)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rev := Revocation{
+		SerialNumber: serial,
+		Reason:       reason,
+		RevokedAt:    now,
+		// The certificate may have been issued by another replica, in which case it expires at most after the max TTL.
+		ExpiresAt: now.Add(r.maxCertTTL),
+	}
+	r.mu.RLock()
+	if issued, f := r.issued[serial]; f {
+		rev.ExpiresAt = issued.NotAfter
+		if len(issued.Identities) > 0 {
+			rev.Identity = issued.Identities[0]
+		}
+	}
+	r.mu.RUnlock()
+	return r.revoke(rev)
+}
+
+// revokeIdentity revokes all the certificates issued for the identity until now, and the issuance of new ones until
+// the entry expires.
+func (r *revocationManager) revokeIdentity(identity string, reason int) ([]Revocation, error) {
+	if identity == "" {
+		return nil, fmt.Errorf("identity is required")
+	}
+	now := time.Now()
+	return r.revoke(Revocation{
+		Identity:  identity,
+		Reason:    reason,
+		RevokedAt: now,
+		ExpiresAt: now.Add(r.maxCertTTL),
+	})
+}
+
+// resolve adds to the revocation list the serial numbers of the recorded certificates issued for the revoked
+// identities, and removes the expired entries. It returns whether the revocation list changed.
+func (r *revocationManager) resolve(revocations []Revocation, now time.Time) ([]Revocation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	changed := false
+	revoked := map[string]bool{}
+	result := make([]Revocation, 0, len(revocations))
+	for _, rev := range revocations {
+		if !rev.ExpiresAt.After(now) {
+			changed = true
+			continue
+		}
+		if rev.SerialNumber != "" {
+			revoked[rev.SerialNumber] = true
+		}
+		result = append(result, rev)
+	}
+	for _, rev := range result {
+		if rev.SerialNumber != "" {
+			continue
+		}
+		for serial, issued := range r.issued {
+			if revoked[serial] || issued.IssuedAt.After(rev.RevokedAt) || !issued.NotAfter.After(now) {
+				continue
+			}
+			for _, id := range issued.Identities {
+				if id == rev.Identity {
+					result = append(result, Revocation{
+						SerialNumber: serial,
+						Identity:     rev.Identity,
+						Reason:       rev.Reason,
+						RevokedAt:    rev.RevokedAt,
+						ExpiresAt:    issued.NotAfter,
+					})
+					revoked[serial] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return result, changed
+}
+
+// refresh persists the issued certificates, reloads the revocation list from the store, resolves the revoked
+// identities and regenerates the CRL.
+func (r *revocationManager) refresh() error {
+	now := time.Now()
+	if err := r.flushIssued(now); err != nil {
+		// Resolve the revoked identities with the certificates known to this replica.
+		pkiCaLog.Warnf("%v", err)
+		r.mu.Lock()
+		r.pruneIssuedLocked(now)
+		r.mu.Unlock()
+	}
+
+	revocations, err := r.opts.Store.Update(func(current []Revocation) ([]Revocation, bool) {
+		return r.resolve(current, now)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the revocation list: %v", err)
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		if !revocations[i].RevokedAt.Equal(revocations[j].RevokedAt) {
+			return revocations[i].RevokedAt.Before(revocations[j].RevokedAt)
+		}
+		return revocations[i].SerialNumber < revocations[j].SerialNumber
+	})
+
+	crl, err := r.generateCRL(revocations, now)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.revocations = revocations
+	updated := !bytes.Equal(r.crl, crl)
+	r.crl = crl
+	r.mu.Unlock()
+	if updated && r.opts.OnCRLUpdate != nil {
+		r.opts.OnCRLUpdate()
+	}
+	return nil
+}
+
+// generateCRL returns the PEM encoded CRL of the revoked certificates, signed by the CA signing key. No CRL is
+// generated if no certificate is revoked, as proxies then need no CRL to validate the certificates of the CA.
+// The CRL is regenerated only if the revoked certificates or the signing certificate changed, or if it is halfway to
+// its expiry.
+func (r *revocationManager) generateCRL(revocations []Revocation, now time.Time) ([]byte, error) {
+	entries := make([]x509.RevocationListEntry, 0, len(revocations))
+	for _, rev := range revocations {
+		if rev.SerialNumber == "" {
+			continue
+		}
+		serial, _ := new(big.Int).SetString(rev.SerialNumber, 16)
+		if serial == nil {
+			pkiCaLog.Warnf("ignoring revocation with invalid serial number %q", rev.SerialNumber)
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: rev.RevokedAt,
+			ReasonCode:     rev.Reason,
+		})
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	signingCertPEM, _, _, _ := r.keyCertBundle.GetAllPem()
+	if r.crlUpToDate(entries, signingCertPEM, now) {
+		return r.getCRL(), nil
+	}
+	signingCert, signingKey, _, _ := r.keyCertBundle.GetAll()
+	if signingCert == nil || signingKey == nil {
+		return nil, fmt.Errorf("failed to generate CRL: CA is not ready")
+	}
+	signer, ok := (*signingKey).(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("failed to generate CRL: CA signing key is not a signer")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// CRL numbers must increase, including across replicas and restarts, so they are derived from the time.
+	r.crlNumber = max(r.crlNumber+1, now.Unix())
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(r.crlNumber),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(r.opts.CRLValidity),
+		RevokedCertificateEntries: entries,
+	}, signingCert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CRL: %v", err)
+	}
+	r.crlSigner = signingCertPEM
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+// crlUpToDate returns whether the current CRL has the given entries, is signed by the signing certificate and is not
+// halfway to its expiry.
+func (r *revocationManager) crlUpToDate(entries []x509.RevocationListEntry, signingCertPEM []byte, now time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.crl) == 0 || !bytes.Equal(r.crlSigner, signingCertPEM) {
+		return false
+	}
+	block, _ := pem.Decode(r.crl)
+	if block == nil {
+		return false
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil || now.After(crl.ThisUpdate.Add(crl.NextUpdate.Sub(crl.ThisUpdate)/2)) {
+		return false
+	}
+	if len(crl.RevokedCertificateEntries) != len(entries) {
+		return false
+	}
+	for i, e := range crl.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(entries[i].SerialNumber) != 0 || e.ReasonCode != entries[i].ReasonCode {
+			return false
+		}
+	}
+	return true
+}
+
+// run periodically refreshes the revocation list and the CRL, and persists the issued certificates.
+func (r *revocationManager) run(stop <-chan struct{}) {
+	if err := r.refresh(); err != nil {
+		pkiCaLog.Errorf("failed to refresh the revocation list: %v", err)
+	}
+	ticker := time.NewTicker(r.opts.RefreshInterval)
+	defer ticker.Stop()
+	flushTicker := time.NewTicker(issuedFlushInterval)
+	defer flushTicker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.refresh(); err != nil {
+				pkiCaLog.Errorf("failed to refresh the revocation list: %v", err)
+			}
+		case <-flushTicker.C:
+			r.mu.RLock()
+			pending := len(r.pending) > 0
+			r.mu.RUnlock()
+			if !pending {
+				continue
+			}
+			if err := r.flushIssued(time.Now()); err != nil {
+				pkiCaLog.Warnf("%v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (r *revocationManager) getRevocations() []Revocation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Revocation(nil), r.revocations...)
+}
+
+func (r *revocationManager) getCRL() []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]byte(nil), r.crl...)
+}
@@ -0,0 +1,307 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	identityA = "spiffe://cluster.local/ns/a/sa/a"
+	identityB = "spiffe://cluster.local/ns/b/sa/b"
+)
+
+func createRevocationCA(t *testing.T, store RevocationStore, onCRLUpdate func()) *IstioCA {
+	t.Helper()
+	ca, err := createCA(time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.revocation = newRevocationManager(RevocationOptions{
+		Store:           store,
+		RefreshInterval: time.Minute,
+		CRLValidity:     time.Hour,
+		OnCRLUpdate:     onCRLUpdate,
+	}, time.Hour, ca.keyCertBundle)
+	return ca
+}
+
+// issue returns the serial number of a certificate issued by the CA for the identity.
+func issue(t *testing.T, ca *IstioCA, identity string) string {
+	t.Helper()
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: identity, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, CertOpts{SubjectIDs: []string{identity}, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.SerialNumber.Text(16)
+}
+
+// crlSerials returns the serial numbers in the CRL of the CA, after checking it is signed by the CA.
+func crlSerials(t *testing.T, ca *IstioCA) []string {
+	t.Helper()
+	crlPEM := ca.GetCRLPem()
+	if len(crlPEM) == 0 {
+		return nil
+	}
+	block, _ := pem.Decode(crlPEM)
+	assert.Equal(t, block.Type, "X509 CRL")
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	assert.NoError(t, err)
+	signingCert, _, _, _ := ca.keyCertBundle.GetAll()
+	assert.NoError(t, crl.CheckSignatureFrom(signingCert))
+	serials := []string{}
+	for _, e := range crl.RevokedCertificateEntries {
+		serials = append(serials, e.SerialNumber.Text(16))
+	}
+	return serials
+}
+
+func TestRevokeCertificate(t *testing.T) {
+	updates := 0
+	ca := createRevocationCA(t, NewMemoryRevocationStore(), func() { updates++ })
+	serialA := issue(t, ca, identityA)
+	issue(t, ca, identityB)
+
+	revoked, err := ca.RevokeCertificate(serialA, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, len(revoked), 1)
+	assert.Equal(t, revoked[0].SerialNumber, serialA)
+	assert.Equal(t, revoked[0].Identity, identityA)
+	assert.Equal(t, revoked[0].Reason, 1)
+	assert.Equal(t, crlSerials(t, ca), []string{serialA})
+	assert.Equal(t, updates, 1)
+
+	// Revoking again is a no-op.
+	_, err = ca.RevokeCertificate(serialA, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, len(ca.Revocations()), 1)
+	assert.Equal(t, updates, 1)
+
+	_, err = ca.RevokeCertificate("not-a-serial", 0)
+	assert.Error(t, err)
+}
+
+func TestRevokeIdentity(t *testing.T) {
+	ca := createRevocationCA(t, NewMemoryRevocationStore(), nil)
+	serialA1 := issue(t, ca, identityA)
+	serialA2 := issue(t, ca, identityA)
+	issue(t, ca, identityB)
+
+	revoked, err := ca.RevokeIdentity(identityA, 0)
+	assert.NoError(t, err)
+	// The identity entry, and the two certificates issued for it.
+	assert.Equal(t, len(revoked), 3)
+	assert.Equal(t, len(crlSerials(t, ca)), 2)
+	assert.Equal(t, sets.New(crlSerials(t, ca)...), sets.New(serialA1, serialA2))
+
+	// No certificate is issued for the identity until the revocation expires.
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: identityA, RSAKeySize: 2048})
+	assert.NoError(t, err)
+	_, err = ca.Sign(csrPEM, CertOpts{SubjectIDs: []string{identityA}, TTL: time.Hour})
+	assert.Error(t, err)
+	issue(t, ca, identityB)
+}
+
+func TestRevokeIdentityOtherReplica(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	ca1 := createRevocationCA(t, store, nil)
+	ca2 := createRevocationCA(t, store, nil)
+	serial := issue(t, ca2, identityA)
+
+	_, err := ca1.RevokeIdentity(identityA, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, ca2.RefreshRevocations())
+	assert.Equal(t, crlSerials(t, ca2), []string{serial})
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: identityA, RSAKeySize: 2048})
+	assert.NoError(t, err)
+	_, err = ca2.Sign(csrPEM, CertOpts{SubjectIDs: []string{identityA}, TTL: time.Hour})
+	assert.Error(t, err)
+}
+
+func TestIssuedPruning(t *testing.T) {
+	ca := createRevocationCA(t, NewMemoryRevocationStore(), nil)
+	ca.revocation.issued["1"] = IssuedCertificate{
+		SerialNumber: "1",
+		Identities:   []string{identityA},
+		NotAfter:     time.Now().Add(-time.Minute),
+	}
+	issue(t, ca, identityA)
+	assert.Equal(t, len(ca.revocation.issued), 1)
+	_, expired := ca.revocation.issued["1"]
+	assert.Equal(t, expired, false)
+}
+
+func TestRevokeIdentityAfterRestart(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	ca1 := createRevocationCA(t, store, nil)
+	serial := issue(t, ca1, identityA)
+	assert.NoError(t, ca1.revocation.flushIssued(time.Now()))
+
+	// A new replica, e.g. after a restart, revokes the certificate issued before.
+	ca2 := createRevocationCA(t, store, nil)
+	ca2.keyCertBundle = ca1.keyCertBundle
+	ca2.revocation.keyCertBundle = ca1.keyCertBundle
+	_, err := ca2.RevokeIdentity(identityA, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, crlSerials(t, ca2), []string{serial})
+}
+
+func TestFlushIssuedError(t *testing.T) {
+	store := &failingRevocationStore{RevocationStore: NewMemoryRevocationStore(), fail: true}
+	ca := createRevocationCA(t, store, nil)
+	serial := issue(t, ca, identityA)
+	assert.Error(t, ca.revocation.flushIssued(time.Now()))
+	assert.Equal(t, len(ca.revocation.pending), 1)
+
+	// The certificates are persisted on the next flush.
+	store.fail = false
+	assert.NoError(t, ca.revocation.flushIssued(time.Now()))
+	assert.Equal(t, len(ca.revocation.pending), 0)
+	issued, err := store.RecordIssued(nil, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, len(issued), 1)
+	assert.Equal(t, issued[0].SerialNumber, serial)
+}
+
+type failingRevocationStore struct {
+	RevocationStore
+	fail bool
+}
+
+func (f *failingRevocationStore) RecordIssued(issued []IssuedCertificate, now time.Time) ([]IssuedCertificate, error) {
+	if f.fail {
+		return nil, fmt.Errorf("failed")
+	}
+	return f.RevocationStore.RecordIssued(issued, now)
+}
+
+func TestMergeIssued(t *testing.T) {
+	now := time.Now()
+	current := []IssuedCertificate{
+		{SerialNumber: "1", IssuedAt: now.Add(-2 * time.Hour), NotAfter: now.Add(-time.Hour)},
+		{SerialNumber: "2", IssuedAt: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)},
+	}
+	added := []IssuedCertificate{{SerialNumber: "3", IssuedAt: now, NotAfter: now.Add(2 * time.Hour)}}
+	merged := mergeIssued(current, added, now)
+	assert.Equal(t, len(merged), 2)
+	// Newest first, without the expired certificate.
+	assert.Equal(t, merged[0].SerialNumber, "3")
+	assert.Equal(t, merged[1].SerialNumber, "2")
+}
+
+func TestRevocationExpiry(t *testing.T) {
+	ca := createRevocationCA(t, NewMemoryRevocationStore(), nil)
+	serial := issue(t, ca, identityA)
+	_, err := ca.RevokeCertificate(serial, 0)
+	assert.NoError(t, err)
+
+	revocations, changed := ca.revocation.resolve(ca.Revocations(), time.Now().Add(2*time.Hour))
+	assert.Equal(t, changed, true)
+	assert.Equal(t, len(revocations), 0)
+	crl, err := ca.revocation.generateCRL(revocations, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, len(crl), 0)
+}
+
+func TestRevocationDisabled(t *testing.T) {
+	ca, err := createCA(time.Hour, "")
+	assert.NoError(t, err)
+	_, err = ca.RevokeCertificate("1", 0)
+	assert.Error(t, err)
+	_, err = ca.RevokeIdentity(identityA, 0)
+	assert.Error(t, err)
+	assert.Equal(t, len(ca.GetCRLPem()), 0)
+}
+
+func TestConfigMapRevocationStore(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapRevocationStore(client.CoreV1(), "istio-system")
+	// Two replicas of the CA, sharing the revocation list.
+	ca1 := createRevocationCA(t, store, nil)
+	ca2 := createRevocationCA(t, store, nil)
+	ca2.keyCertBundle = ca1.keyCertBundle
+	ca2.revocation.keyCertBundle = ca1.keyCertBundle
+
+	serial1 := issue(t, ca1, identityA)
+	serial2 := issue(t, ca2, identityA)
+
+	// The certificates issued by the second replica and persisted are revoked by the first one.
+	assert.NoError(t, ca2.revocation.flushIssued(time.Now()))
+	serial3 := issue(t, ca2, identityA)
+	_, err := ca1.RevokeIdentity(identityA, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, sets.New(crlSerials(t, ca1)...), sets.New(serial1, serial2))
+
+	// The second replica adds the certificate it issued for the identity and did not persist yet, which the first
+	// one then picks up.
+	assert.NoError(t, ca2.revocation.refresh())
+	assert.Equal(t, sets.New(crlSerials(t, ca2)...), sets.New(serial1, serial2, serial3))
+	assert.NoError(t, ca1.revocation.refresh())
+	assert.Equal(t, sets.New(crlSerials(t, ca1)...), sets.New(serial1, serial2, serial3))
+
+	cm, err := client.CoreV1().ConfigMaps("istio-system").Get(context.TODO(), RevocationConfigMap, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, cm.Data[revocationsKey] != "", true)
+	cm, err = client.CoreV1().ConfigMaps("istio-system").Get(context.TODO(), IssuedConfigMap, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, cm.Data[issuedKey] != "", true)
+}
+
+func TestParseSerialNumber(t *testing.T) {
+	for in, want := range map[string]string{
+		"0A:1B:2C": "a1b2c",
+		"0x0a1b2c": "a1b2c",
+		"A1B2C":    "a1b2c",
+	} {
+		got, err := ParseSerialNumber(in)
+		assert.NoError(t, err)
+		assert.Equal(t, got, want)
+	}
+	for _, in := range []string{"", "0", "xyz"} {
+		_, err := ParseSerialNumber(in)
+		assert.Error(t, err)
+	}
+}
+
+func TestParseRevocationReason(t *testing.T) {
+	reason, err := ParseRevocationReason("KeyCompromise")
+	assert.NoError(t, err)
+	assert.Equal(t, reason, 1)
+	reason, err = ParseRevocationReason("")
+	assert.NoError(t, err)
+	assert.Equal(t, reason, 0)
+	_, err = ParseRevocationReason("removeFromCRL")
+	assert.Error(t, err)
+}
@@ -0,0 +1,519 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// The root of the CA is rotated in stages, for the workloads to trust the new root before being issued certificates
+// by the new CA, and to keep trusting the old root until the certificates issued by the old CA are renewed:
+//
+//  1. AddingRoot: the new roots are added to the roots of the CA, which are distributed to the proxies. The rotation
+//     waits for all the proxies connected to the istiod replicas to ack the new trust bundle over SDS. Each replica
+//     only knows the acks of its own proxies, so the rotation waits for every running replica, of any revision, to
+//     report them. The proxies which cannot ack the trust bundle, e.g. ztunnel, pick up the roots with the
+//     certificates they renew, so the rotation waits for the grace period if any is connected.
+//  2. SwitchingSigning: the CA signs with the new CA. The rotation waits for the grace period, for the workload
+//     certificates issued by the old CA to be renewed.
+//  3. RemovingRoot: the old roots are removed. The rotation waits for all the proxies to ack the trust bundle again.
+//  4. Completed: the new CA is persisted in the secret of the CA.
+//
+// A rotation is started by creating the RootRotationSecret secret in the istiod namespace, with the new CA for a
+// plugged-in CA; a self-signed CA generates a new root. The istiod replicas share the state of the rotation in this
+// secret, where each of them reports the phase it applied and the acks of its proxies. Any replica moves the rotation
+// to the next phase once all of them are ready.
+
+const (
+	// RootRotationSecret is the secret of the root rotation, in the istiod namespace.
+	RootRotationSecret = "istio-ca-root-rotation"
+	// RootRotationFile is the key of the RootRotation in RootRotationSecret. The new CA is stored along with it,
+	// in the CACertFile, CAPrivateKeyFile, CertChainFile and RootCertFile keys.
+	RootRotationFile = "rotation.json"
+	// OldRootCertFile is the key of the roots of the CA before the rotation in RootRotationSecret.
+	OldRootCertFile = "old-root-cert.pem"
+
+	rootRotationInterval = 10 * time.Second
+	// rootRotationHeartbeat is the interval at which the replicas report their status, even if unchanged. The replicas
+	// which did not report for 3 heartbeats, e.g. deleted pods, are ignored.
+	rootRotationHeartbeat = time.Minute
+)
+
+var rootRotationLog = log.RegisterScope("rootrotation", "Root CA rotation log")
+
+// RootRotationPhase is a phase of a root rotation.
+type RootRotationPhase string
+
+const (
+	RootRotationPending          RootRotationPhase = "Pending"
+	RootRotationAddingRoot       RootRotationPhase = "AddingRoot"
+	RootRotationSwitchingSigning RootRotationPhase = "SwitchingSigning"
+	RootRotationRemovingRoot     RootRotationPhase = "RemovingRoot"
+	RootRotationCompleted        RootRotationPhase = "Completed"
+	RootRotationFailed           RootRotationPhase = "Failed"
+)
+
+// RootRotation is a root rotation, stored in RootRotationSecret.
+type RootRotation struct {
+	Spec   RootRotationSpec   `json:"spec"`
+	Status RootRotationStatus `json:"status"`
+}
+
+// RootRotationSpec is the desired root rotation.
+type RootRotationSpec struct {
+	// GracePeriod is how long the old roots are trusted once the CA signs with the new CA, for the workload
+	// certificates issued by the old CA to be renewed. Defaults to the default workload certificate TTL.
+	GracePeriod metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// RootRotationStatus is the status of a root rotation.
+type RootRotationStatus struct {
+	Phase   RootRotationPhase `json:"phase,omitempty"`
+	Message string            `json:"message,omitempty"`
+	// StartTime is the time the rotation started, and TransitionTime the time it entered its phase.
+	StartTime      metav1.Time `json:"startTime,omitempty"`
+	TransitionTime metav1.Time `json:"transitionTime,omitempty"`
+	// GracePeriod is the grace period of the old roots in effect.
+	GracePeriod metav1.Duration `json:"gracePeriod,omitempty"`
+	// OldRoots and NewRoots describe the roots before and after the rotation.
+	OldRoots []string `json:"oldRoots,omitempty"`
+	NewRoots []string `json:"newRoots,omitempty"`
+	// Replicas is the status of the istiod replicas, by pod name.
+	Replicas map[string]*RootRotationReplica `json:"replicas,omitempty"`
+}
+
+// RootRotationReplica is the status of the root rotation in an istiod replica.
+type RootRotationReplica struct {
+	// Phase is the last phase applied by the replica.
+	Phase RootRotationPhase `json:"phase"`
+	// Proxies is the number of proxies connected to the replica, and Acked the number of them which acked the trust
+	// bundle of the phase. WithoutAcks is the number of proxies which cannot ack the trust bundle: they are counted
+	// as acked once the grace period elapsed since the phase was applied, by when they renewed their certificates.
+	Proxies     int         `json:"proxies"`
+	Acked       int         `json:"acked"`
+	WithoutAcks int         `json:"withoutAcks,omitempty"`
+	Heartbeat   metav1.Time `json:"heartbeat"`
+}
+
+// Done returns whether the rotation is over, either completed or failed.
+func (r *RootRotation) Done() bool {
+	return r.Status.Phase == RootRotationCompleted || r.Status.Phase == RootRotationFailed
+}
+
+// RootRotationOptions are the options of the root rotation of a CA.
+type RootRotationOptions struct {
+	Client    corev1.SecretsGetter
+	Namespace string
+	// Replica is the name of the istiod replica.
+	Replica string
+	// ProxyAcks returns the number of proxies connected to this replica, how many of them acked a trust bundle sent
+	// after since over SDS, and how many of them cannot ack the trust bundle. The acks are per replica: each replica
+	// reports its own in the status.
+	ProxyAcks func(since time.Time) (acked, total, withoutAcks int)
+	// Replicas returns the names of the running istiod replicas of all the revisions, which share the rotation. The
+	// rotation only advances once each of them reported the acks of its proxies. If nil, only the replicas which
+	// reported are waited for.
+	Replicas func() ([]string, error)
+	// OnRootCertUpdate is called when the roots or the signing certificate of the CA are updated.
+	OnRootCertUpdate func() error
+}
+
+// rootRotationController drives the root rotations of a CA.
+type rootRotationController struct {
+	opts          RootRotationOptions
+	keyCertBundle *util.KeyCertBundle
+	// selfSigned is the configuration of the self-signed CA, which generates the new CA. It is nil for a plugged-in CA.
+	selfSigned         *SelfSignedCARootCertRotatorConfig
+	caSecret           string
+	caRSAKeySize       int
+	defaultGracePeriod time.Duration
+	started            time.Time
+	now                func() time.Time
+
+	mu       sync.RWMutex
+	rotation *RootRotation
+
+	// uid and phase identify the phase of the rotation applied to the CA, since appliedAt. They are only accessed by
+	// the reconciliation.
+	uid       types.UID
+	phase     RootRotationPhase
+	appliedAt time.Time
+}
+
+func newRootRotationController(opts RootRotationOptions, ca *IstioCA, selfSigned *SelfSignedCARootCertRotatorConfig) *rootRotationController {
+	c := &rootRotationController{
+		opts:               opts,
+		keyCertBundle:      ca.keyCertBundle,
+		selfSigned:         selfSigned,
+		caSecret:           CACertsSecret,
+		caRSAKeySize:       ca.caRSAKeySize,
+		defaultGracePeriod: ca.defaultCertTTL,
+		started:            time.Now(),
+		now:                time.Now,
+	}
+	if selfSigned != nil {
+		c.caSecret = selfSigned.secretName
+	}
+	if c.caRSAKeySize == 0 {
+		c.caRSAKeySize = rsaKeySize
+	}
+	return c
+}
+
+func (c *rootRotationController) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(rootRotationInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcile(); err != nil {
+			rootRotationLog.Errorf("failed to reconcile the root rotation: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *rootRotationController) getRotation() *RootRotation {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rotation
+}
+
+func (c *rootRotationController) setRotation(rotation *RootRotation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rotation = rotation
+}
+
+// inProgress returns whether a rotation is in progress, during which the CA must not be updated otherwise.
+func (c *rootRotationController) inProgress() bool {
+	rotation := c.getRotation()
+	return rotation != nil && !rotation.Done()
+}
+
+func (c *rootRotationController) reconcile() error {
+	secret, err := c.opts.Client.Secrets(c.opts.Namespace).Get(context.TODO(), RootRotationSecret, metav1.GetOptions{})
+	if apierror.IsNotFound(err) {
+		if c.phase != "" && c.phase != RootRotationCompleted {
+			rootRotationLog.Warnf("secret %s was deleted during phase %s of the root rotation, which stops where it is: "+
+				"the CA keeps its current roots and signing certificate until istiod restarts", RootRotationSecret, c.phase)
+		}
+		c.uid, c.phase = "", ""
+		c.setRotation(nil)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	rotation := &RootRotation{}
+	if data := secret.Data[RootRotationFile]; len(data) > 0 {
+		if err := json.Unmarshal(data, rotation); err != nil {
+			return fmt.Errorf("invalid root rotation in secret %s: %v", RootRotationSecret, err)
+		}
+	}
+	c.setRotation(rotation)
+	switch rotation.Status.Phase {
+	case RootRotationCompleted, RootRotationFailed:
+		return nil
+	case "", RootRotationPending:
+		c.start(secret, rotation)
+		return c.update(secret, rotation)
+	}
+
+	if err := c.apply(secret, rotation.Status.Phase); err != nil {
+		return err
+	}
+	reported := c.report(rotation)
+	advanced, err := c.advance(secret, rotation)
+	if err != nil {
+		return err
+	}
+	if reported || advanced {
+		return c.update(secret, rotation)
+	}
+	return nil
+}
+
+// start validates the new CA, or generates it for a self-signed CA, and starts adding the new roots.
+func (c *rootRotationController) start(secret *v1.Secret, rotation *RootRotation) {
+	now := metav1.NewTime(c.now())
+	status := &rotation.Status
+	status.StartTime, status.TransitionTime = now, now
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	oldRoots := c.keyCertBundle.GetRootCertPem()
+	if err := c.newCA(secret.Data); err != nil {
+		status.Phase, status.Message = RootRotationFailed, err.Error()
+		rootRotationLog.Errorf("failed to start the root rotation: %v", err)
+		return
+	}
+	if bytes.Equal(secret.Data[RootCertFile], oldRoots) {
+		status.Phase, status.Message = RootRotationFailed, "the roots of the new CA are the roots in use"
+		return
+	}
+	secret.Data[OldRootCertFile] = oldRoots
+	status.GracePeriod = rotation.Spec.GracePeriod
+	if status.GracePeriod.Duration <= 0 {
+		status.GracePeriod = metav1.Duration{Duration: c.defaultGracePeriod}
+	}
+	status.OldRoots = describeRoots(oldRoots)
+	status.NewRoots = describeRoots(secret.Data[RootCertFile])
+	status.Phase, status.Message = RootRotationAddingRoot, ""
+	rootRotationLog.Infof("starting the root rotation from %v to %v", status.OldRoots, status.NewRoots)
+}
+
+// newCA checks the new CA in the data of the rotation secret or, if it is not set, generates a new self-signed one.
+func (c *rootRotationController) newCA(data map[string][]byte) error {
+	if len(data[CACertFile]) == 0 {
+		if c.selfSigned == nil {
+			return fmt.Errorf("the %s, %s, %s and %s of the new CA are required for a plugged-in CA",
+				CACertFile, CAPrivateKeyFile, CertChainFile, RootCertFile)
+		}
+		cert, key, err := util.GenCertKeyFromOptions(util.CertOptions{
+			TTL:          c.selfSigned.caCertTTL,
+			Org:          c.selfSigned.org,
+			IsCA:         true,
+			IsSelfSigned: true,
+			RSAKeySize:   c.caRSAKeySize,
+			IsDualUse:    c.selfSigned.dualUse,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate the new root: %v", err)
+		}
+		roots, err := util.AppendRootCerts(cert, c.selfSigned.rootCertFile)
+		if err != nil {
+			return fmt.Errorf("failed to append root certificates: %v", err)
+		}
+		data[CACertFile], data[CAPrivateKeyFile], data[RootCertFile] = cert, key, roots
+		delete(data, CertChainFile)
+	}
+	if _, err := util.NewVerifiedKeyCertBundleFromPem(data[CACertFile], data[CAPrivateKeyFile], data[CertChainFile],
+		data[RootCertFile], nil); err != nil {
+		return fmt.Errorf("invalid new CA: %v", err)
+	}
+	return nil
+}
+
+// apply updates the CA to the roots and signing certificate of the phase.
+func (c *rootRotationController) apply(secret *v1.Secret, phase RootRotationPhase) error {
+	data := secret.Data
+	cert, key, chain, roots := data[CACertFile], data[CAPrivateKeyFile], data[CertChainFile], data[RootCertFile]
+	var crl []byte
+	switch phase {
+	case RootRotationAddingRoot:
+		cert, key, chain, _ = c.keyCertBundle.GetAllPem()
+		roots = joinPem(data[OldRootCertFile], roots)
+		crl = c.keyCertBundle.GetCRLPem()
+	case RootRotationSwitchingSigning:
+		roots = joinPem(data[OldRootCertFile], roots)
+	}
+
+	currentCert, _, _, currentRoots := c.keyCertBundle.GetAllPem()
+	if bytes.Equal(cert, currentCert) && bytes.Equal(roots, currentRoots) {
+		if c.uid != secret.UID {
+			// First reconciliation of this rotation, e.g. after a restart: the CA is in this state since it started.
+			c.uid, c.phase, c.appliedAt = secret.UID, phase, c.started
+		} else if c.phase != phase {
+			c.phase, c.appliedAt = phase, c.now()
+		}
+		return nil
+	}
+	if err := c.keyCertBundle.VerifyAndSetAll(cert, key, chain, roots, crl); err != nil {
+		return fmt.Errorf("failed to apply phase %s of the root rotation: %v", phase, err)
+	}
+	c.uid, c.phase, c.appliedAt = secret.UID, phase, c.now()
+	rootRotationLog.Infof("applied phase %s of the root rotation", phase)
+	if c.opts.OnRootCertUpdate != nil {
+		if err := c.opts.OnRootCertUpdate(); err != nil {
+			rootRotationLog.Errorf("failed to notify the root cert update: %v", err)
+		}
+	}
+	return nil
+}
+
+// report updates the status of the replica in the rotation, and returns whether it changed.
+func (c *rootRotationController) report(rotation *RootRotation) bool {
+	acked, proxies, withoutAcks := 0, 0, 0
+	if c.opts.ProxyAcks != nil {
+		acked, proxies, withoutAcks = c.opts.ProxyAcks(c.appliedAt)
+	}
+	now := c.now()
+	status := &rotation.Status
+	// The proxies which cannot ack the trust bundle pick up the roots with their certificates, renewed within the grace
+	// period.
+	if withoutAcks > 0 && !now.Before(c.appliedAt.Add(status.GracePeriod.Duration)) {
+		acked += withoutAcks
+	}
+	if r := status.Replicas[c.opts.Replica]; r != nil && r.Phase == c.phase && r.Proxies == proxies && r.Acked == acked &&
+		r.WithoutAcks == withoutAcks && now.Sub(r.Heartbeat.Time) < rootRotationHeartbeat {
+		return false
+	}
+	if status.Replicas == nil {
+		status.Replicas = map[string]*RootRotationReplica{}
+	}
+	status.Replicas[c.opts.Replica] = &RootRotationReplica{
+		Phase:       c.phase,
+		Proxies:     proxies,
+		Acked:       acked,
+		WithoutAcks: withoutAcks,
+		Heartbeat:   metav1.NewTime(now),
+	}
+	for name, r := range status.Replicas {
+		if now.Sub(r.Heartbeat.Time) > 3*rootRotationHeartbeat {
+			rootRotationLog.Infof("replica %s did not report its root rotation status since %v, ignoring it", name, r.Heartbeat)
+			delete(status.Replicas, name)
+		}
+	}
+	return true
+}
+
+// advance moves the rotation to its next phase if all the replicas are ready, and returns whether it did.
+func (c *rootRotationController) advance(secret *v1.Secret, rotation *RootRotation) (bool, error) {
+	status := &rotation.Status
+	if c.opts.Replicas != nil {
+		replicas, err := c.opts.Replicas()
+		if err != nil {
+			rootRotationLog.Warnf("failed to list the istiod replicas, the root rotation waits: %v", err)
+			return false, nil
+		}
+		for _, name := range replicas {
+			// The proxies connected to a replica which did not report yet, e.g. just started, are not accounted for.
+			if status.Replicas[name] == nil {
+				rootRotationLog.Debugf("waiting for replica %s to report its root rotation status", name)
+				return false, nil
+			}
+		}
+	}
+	for _, r := range status.Replicas {
+		if r.Phase != status.Phase {
+			return false, nil
+		}
+		// The roots, hence the trust bundle, do not change when switching the signing CA.
+		if status.Phase != RootRotationSwitchingSigning && r.Acked < r.Proxies {
+			return false, nil
+		}
+	}
+
+	now := c.now()
+	var next RootRotationPhase
+	switch status.Phase {
+	case RootRotationAddingRoot:
+		next = RootRotationSwitchingSigning
+	case RootRotationSwitchingSigning:
+		if now.Before(status.TransitionTime.Add(status.GracePeriod.Duration)) {
+			return false, nil
+		}
+		next = RootRotationRemovingRoot
+	case RootRotationRemovingRoot:
+		if err := c.persist(secret.Data); err != nil {
+			return false, err
+		}
+		// The key of the new CA is in the secret of the CA from now on.
+		delete(secret.Data, CAPrivateKeyFile)
+		next = RootRotationCompleted
+	default:
+		return false, nil
+	}
+	rootRotationLog.Infof("root rotation moves from phase %s to %s", status.Phase, next)
+	status.Phase, status.TransitionTime, status.Message = next, metav1.NewTime(now), ""
+	return true, nil
+}
+
+// persist writes the new CA to the secret of the CA, for istiod to load it when it restarts.
+func (c *rootRotationController) persist(data map[string][]byte) error {
+	secrets := c.opts.Client.Secrets(c.opts.Namespace)
+	caSecret, err := secrets.Get(context.TODO(), c.caSecret, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to load CA secret %s: %v", c.caSecret, err)
+	}
+	if caSecret.Data == nil {
+		caSecret.Data = map[string][]byte{}
+	}
+	switch {
+	case c.selfSigned != nil:
+		// The roots of a self-signed CA are its certificate and the roots of the root cert file.
+		caSecret.Data[CACertFile] = data[CACertFile]
+		caSecret.Data[CAPrivateKeyFile] = data[CAPrivateKeyFile]
+	case len(caSecret.Data[TLSSecretCACertFile]) > 0:
+		caSecret.Data[TLSSecretCACertFile] = data[CACertFile]
+		caSecret.Data[TLSSecretCAPrivateKeyFile] = data[CAPrivateKeyFile]
+		caSecret.Data[TLSSecretRootCertFile] = data[RootCertFile]
+	default:
+		for _, key := range []string{CACertFile, CAPrivateKeyFile, CertChainFile, RootCertFile} {
+			caSecret.Data[key] = data[key]
+		}
+	}
+	if _, err := secrets.Update(context.TODO(), caSecret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update CA secret %s: %v", c.caSecret, err)
+	}
+	rootRotationLog.Infof("persisted the new CA in secret %s", c.caSecret)
+	return nil
+}
+
+func (c *rootRotationController) update(secret *v1.Secret, rotation *RootRotation) error {
+	data, err := json.Marshal(rotation)
+	if err != nil {
+		return err
+	}
+	secret.Data[RootRotationFile] = data
+	if _, err := c.opts.Client.Secrets(c.opts.Namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		if apierror.IsConflict(err) {
+			// Updated by another replica in the meantime, the rotation is reconciled again on the next interval.
+			rootRotationLog.Debugf("conflict updating secret %s: %v", RootRotationSecret, err)
+			return nil
+		}
+		return fmt.Errorf("failed to update secret %s: %v", RootRotationSecret, err)
+	}
+	c.setRotation(rotation)
+	return nil
+}
+
+// describeRoots returns the subject and serial number of the certificates of the PEM bundle.
+func describeRoots(pemBundle []byte) []string {
+	certs, _, err := util.ParsePemEncodedCertificateChain(pemBundle)
+	if err != nil {
+		return nil
+	}
+	roots := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		roots = append(roots, fmt.Sprintf("%s (serial %s)", cert.Subject, cert.SerialNumber.Text(16)))
+	}
+	return roots
+}
+
+func joinPem(a, b []byte) []byte {
+	joined := append([]byte{}, a...)
+	if len(joined) > 0 && !bytes.HasSuffix(joined, []byte("\n")) {
+		joined = append(joined, '\n')
+	}
+	return append(joined, b...)
+}
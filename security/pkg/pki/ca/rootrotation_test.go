@@ -0,0 +1,317 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const rotationNamespace = "istio-system"
+
+// rotationReplica is an istiod replica with a self-signed CA.
+type rotationReplica struct {
+	ca                        *IstioCA
+	controller                *rootRotationController
+	acked, total, withoutAcks int
+	replicas                  []string
+	since                     time.Time
+	rootCertCalls             int
+}
+
+func newRotationReplica(t *testing.T, client kubernetes.Interface, name string, now *time.Time) *rotationReplica {
+	t.Helper()
+	caOpts, err := NewSelfSignedIstioCAOptions(context.Background(), 0, time.Hour*24, time.Hour, time.Hour, time.Hour*24,
+		"cluster.local", false, true, rotationNamespace, client.CoreV1(), "", false, 2048)
+	assert.NoError(t, err)
+	r := &rotationReplica{}
+	caOpts.RootRotation = &RootRotationOptions{
+		Client:    client.CoreV1(),
+		Namespace: rotationNamespace,
+		Replica:   name,
+		ProxyAcks: func(since time.Time) (int, int, int) {
+			r.since = since
+			return r.acked, r.total, r.withoutAcks
+		},
+		Replicas: func() ([]string, error) {
+			return r.replicas, nil
+		},
+		OnRootCertUpdate: func() error {
+			r.rootCertCalls++
+			return nil
+		},
+	}
+	r.ca, err = NewIstioCA(caOpts)
+	assert.NoError(t, err)
+	r.controller = r.ca.rootRotation
+	r.controller.now = func() time.Time { return *now }
+	return r
+}
+
+func (r *rotationReplica) reconcile(t *testing.T) {
+	t.Helper()
+	assert.NoError(t, r.controller.reconcile())
+}
+
+func startRotation(t *testing.T, client kubernetes.Interface, data map[string][]byte) {
+	t.Helper()
+	spec, err := json.Marshal(RootRotation{Spec: RootRotationSpec{GracePeriod: metav1.Duration{Duration: time.Hour}}})
+	assert.NoError(t, err)
+	if data == nil {
+		data = map[string][]byte{}
+	}
+	data[RootRotationFile] = spec
+	_, err = client.CoreV1().Secrets(rotationNamespace).Create(context.Background(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: RootRotationSecret, Namespace: rotationNamespace, UID: "rotation"},
+		Data:       data,
+	}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+}
+
+func getRotation(t *testing.T, client kubernetes.Interface) (*v1.Secret, *RootRotation) {
+	t.Helper()
+	secret, err := client.CoreV1().Secrets(rotationNamespace).Get(context.Background(), RootRotationSecret, metav1.GetOptions{})
+	assert.NoError(t, err)
+	rotation := &RootRotation{}
+	assert.NoError(t, json.Unmarshal(secret.Data[RootRotationFile], rotation))
+	return secret, rotation
+}
+
+func assertPhase(t *testing.T, client kubernetes.Interface, want RootRotationPhase) *RootRotation {
+	t.Helper()
+	_, rotation := getRotation(t, client)
+	assert.Equal(t, rotation.Status.Phase, want)
+	return rotation
+}
+
+// verifyIssued checks a workload certificate issued by the CA is verified by the roots.
+func verifyIssued(t *testing.T, ca *IstioCA, roots []byte) {
+	t.Helper()
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/a/sa/a", RSAKeySize: 2048})
+	assert.NoError(t, err)
+	chain, err := ca.SignWithCertChain(csrPEM, CertOpts{SubjectIDs: []string{"spiffe://cluster.local/ns/a/sa/a"}, TTL: time.Hour})
+	assert.NoError(t, err)
+	_, err = util.NewVerifiedKeyCertBundleFromPem([]byte(chain[0]), keyPEM, nil, roots, nil)
+	assert.NoError(t, err)
+}
+
+func TestRootRotationSelfSigned(t *testing.T) {
+	client := fake.NewClientset()
+	now := time.Now()
+	a := newRotationReplica(t, client, "istiod-a", &now)
+	b := newRotationReplica(t, client, "istiod-b", &now)
+	oldRoots := a.ca.GetCAKeyCertBundle().GetRootCertPem()
+	assert.Equal(t, b.ca.GetCAKeyCertBundle().GetRootCertPem(), oldRoots)
+
+	// No rotation.
+	a.reconcile(t)
+	rotation, err := a.ca.RootRotation()
+	assert.NoError(t, err)
+	assert.Equal(t, rotation, nil)
+
+	startRotation(t, client, nil)
+	a.reconcile(t)
+	secret, rotation := getRotation(t, client)
+	assert.Equal(t, rotation.Status.Phase, RootRotationAddingRoot)
+	assert.Equal(t, rotation.Status.GracePeriod.Duration, time.Hour)
+	assert.Equal(t, secret.Data[OldRootCertFile], oldRoots)
+	newRoots := secret.Data[RootCertFile]
+	assert.Equal(t, len(newRoots) > 0, true)
+	assert.Equal(t, len(rotation.Status.NewRoots), 1)
+
+	// Both replicas trust the new root, but only the proxies of b acked it.
+	a.total, b.total, b.acked = 2, 1, 1
+	a.reconcile(t)
+	b.reconcile(t)
+	bothRoots := joinPem(oldRoots, newRoots)
+	assert.Equal(t, a.ca.GetCAKeyCertBundle().GetRootCertPem(), bothRoots)
+	assert.Equal(t, b.ca.GetCAKeyCertBundle().GetRootCertPem(), bothRoots)
+	assert.Equal(t, a.rootCertCalls, 1)
+	rotation = assertPhase(t, client, RootRotationAddingRoot)
+	assert.Equal(t, *rotation.Status.Replicas["istiod-a"], RootRotationReplica{
+		Phase: RootRotationAddingRoot, Proxies: 2, Acked: 0, Heartbeat: rotation.Status.Replicas["istiod-a"].Heartbeat,
+	})
+	assert.Equal(t, a.ca.RootRotationInProgress(), true)
+
+	// The rotation starts signing with the new CA once all the proxies acked.
+	a.acked = 2
+	a.reconcile(t)
+	assertPhase(t, client, RootRotationSwitchingSigning)
+	a.reconcile(t)
+	b.reconcile(t)
+	for _, r := range []*rotationReplica{a, b} {
+		signingCert, _, _, roots := r.ca.GetCAKeyCertBundle().GetAllPem()
+		assert.Equal(t, signingCert, secret.Data[CACertFile])
+		assert.Equal(t, roots, bothRoots)
+		verifyIssued(t, r.ca, newRoots)
+	}
+
+	// The old root is removed after the grace period.
+	now = now.Add(30 * time.Minute)
+	a.reconcile(t)
+	assertPhase(t, client, RootRotationSwitchingSigning)
+	now = now.Add(31 * time.Minute)
+	b.reconcile(t)
+	a.reconcile(t)
+	assertPhase(t, client, RootRotationRemovingRoot)
+	a.acked, b.acked = 0, 0
+	a.reconcile(t)
+	b.reconcile(t)
+	assert.Equal(t, a.ca.GetCAKeyCertBundle().GetRootCertPem(), newRoots)
+	assert.Equal(t, b.ca.GetCAKeyCertBundle().GetRootCertPem(), newRoots)
+	assert.Equal(t, a.since, now)
+	assertPhase(t, client, RootRotationRemovingRoot)
+
+	// The rotation completes once all the proxies acked the removal, and the new CA is persisted.
+	a.acked, b.acked = 2, 1
+	a.reconcile(t)
+	b.reconcile(t)
+	secret, _ = getRotation(t, client)
+	assertPhase(t, client, RootRotationCompleted)
+	assert.Equal(t, len(secret.Data[CAPrivateKeyFile]), 0)
+	caSecret, err := client.CoreV1().Secrets(rotationNamespace).Get(context.Background(), CASecret, metav1.GetOptions{})
+	assert.NoError(t, err)
+	signingCert, signingKey, _, _ := a.ca.GetCAKeyCertBundle().GetAllPem()
+	assert.Equal(t, caSecret.Data[CACertFile], signingCert)
+	assert.Equal(t, caSecret.Data[CAPrivateKeyFile], signingKey)
+	a.reconcile(t)
+	assert.Equal(t, a.ca.RootRotationInProgress(), false)
+
+	// A restarted replica loads the new CA.
+	c := newRotationReplica(t, client, "istiod-c", &now)
+	assert.Equal(t, c.ca.GetCAKeyCertBundle().GetRootCertPem(), newRoots)
+}
+
+func TestRootRotationWaitsForAllReplicas(t *testing.T) {
+	client := fake.NewClientset()
+	now := time.Now()
+	a := newRotationReplica(t, client, "istiod-a", &now)
+	b := newRotationReplica(t, client, "istiod-b", &now)
+	a.replicas = []string{"istiod-a", "istiod-b"}
+	startRotation(t, client, nil)
+	a.reconcile(t)
+
+	// All the proxies of a acked, but b did not report the acks of its own proxies yet.
+	a.acked, a.total = 1, 1
+	a.reconcile(t)
+	a.reconcile(t)
+	assertPhase(t, client, RootRotationAddingRoot)
+
+	b.acked, b.total = 1, 1
+	b.reconcile(t)
+	a.reconcile(t)
+	assertPhase(t, client, RootRotationSwitchingSigning)
+}
+
+func TestRootRotationWaitsForProxiesWithoutAcks(t *testing.T) {
+	client := fake.NewClientset()
+	now := time.Now()
+	a := newRotationReplica(t, client, "istiod-a", &now)
+	startRotation(t, client, nil)
+	a.reconcile(t)
+
+	// A ztunnel cannot ack the trust bundle: it picks up the new root with the certificates it renews within the
+	// grace period.
+	a.acked, a.total, a.withoutAcks = 1, 2, 1
+	a.reconcile(t)
+	a.reconcile(t)
+	rotation := assertPhase(t, client, RootRotationAddingRoot)
+	assert.Equal(t, *rotation.Status.Replicas["istiod-a"], RootRotationReplica{
+		Phase: RootRotationAddingRoot, Proxies: 2, Acked: 1, WithoutAcks: 1, Heartbeat: rotation.Status.Replicas["istiod-a"].Heartbeat,
+	})
+
+	now = now.Add(time.Hour)
+	a.reconcile(t)
+	a.reconcile(t)
+	assertPhase(t, client, RootRotationSwitchingSigning)
+}
+
+func TestRootRotationRestart(t *testing.T) {
+	client := fake.NewClientset()
+	now := time.Now()
+	a := newRotationReplica(t, client, "istiod-a", &now)
+	startRotation(t, client, nil)
+	a.reconcile(t)
+	a.reconcile(t)
+	assert.Equal(t, a.since, now)
+
+	// A replica restarting during the rotation applies the phase, and waits for the acks of the proxies since.
+	now = now.Add(time.Minute)
+	b := newRotationReplica(t, client, "istiod-b", &now)
+	b.controller.started = now.Add(-time.Second)
+	b.reconcile(t)
+	assert.Equal(t, b.since, now)
+	assert.Equal(t, b.rootCertCalls, 1)
+
+	// The CA is already in the state of the phase when reconciled by a new controller, e.g. after a restart of the
+	// controller: the acks are counted since it started.
+	b.controller.uid = ""
+	b.reconcile(t)
+	assert.Equal(t, b.since, b.controller.started)
+	assert.Equal(t, b.rootCertCalls, 1)
+}
+
+func TestRootRotationFailure(t *testing.T) {
+	otherCA, err := createCA(time.Hour, "")
+	assert.NoError(t, err)
+	otherCert, _, otherChain, otherRoots := otherCA.GetCAKeyCertBundle().GetAllPem()
+	_, mismatchedKey, err := util.GenCertKeyFromOptions(util.CertOptions{IsCA: true, IsSelfSigned: true, TTL: time.Hour, RSAKeySize: 2048})
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name    string
+		data    map[string][]byte
+		message string
+	}{
+		{
+			name:    "missing new CA",
+			message: "the ca-cert.pem, ca-key.pem, cert-chain.pem and root-cert.pem of the new CA are required for a plugged-in CA",
+		},
+		{
+			name: "mismatched key",
+			data: map[string][]byte{
+				CACertFile: otherCert, CAPrivateKeyFile: mismatchedKey, CertChainFile: otherChain, RootCertFile: otherRoots,
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset()
+			ca, err := createCA(time.Hour, "")
+			assert.NoError(t, err)
+			roots := ca.GetCAKeyCertBundle().GetRootCertPem()
+			c := newRootRotationController(RootRotationOptions{Client: client.CoreV1(), Namespace: rotationNamespace, Replica: "istiod"}, ca, nil)
+			startRotation(t, client, tt.data)
+			assert.NoError(t, c.reconcile())
+			rotation := assertPhase(t, client, RootRotationFailed)
+			if tt.message != "" {
+				assert.Equal(t, rotation.Status.Message, tt.message)
+			}
+			assert.Equal(t, ca.GetCAKeyCertBundle().GetRootCertPem(), roots)
+			assert.NoError(t, c.reconcile())
+			assertPhase(t, client, RootRotationFailed)
+		})
+	}
+}
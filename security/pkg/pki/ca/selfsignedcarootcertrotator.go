@@ -117,6 +117,10 @@ func (rotator *SelfSignedCARootCertRotator) Run(stopCh chan struct{}) {
 // checkAndRotateRootCert decides whether root cert should be refreshed, and rotates
 // root cert for self-signed Citadel.
 func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCert() {
+	if rotator.ca.RootRotationInProgress() {
+		rootCertRotatorLog.Info("Root rotation is in progress, skip cert rotation job")
+		return
+	}
 	caSecret, scrtErr := rotator.caSecretController.LoadCASecretWithRetry(rotator.config.secretName,
 		rotator.config.caStorageNamespace, rotator.config.retryInterval, rotator.config.retryMax)
 
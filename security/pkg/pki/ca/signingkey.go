@@ -0,0 +1,155 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"fmt"
+	"sync"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/signer"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// SigningKeyOptions are the options of a plugged-in CA whose signing key is kept by a backend, such as an HSM or a
+// remote signer, rather than read from the signing key file: the private key is never in the memory of the CA.
+type SigningKeyOptions struct {
+	// Backend keeps the signing key.
+	Backend signer.Backend
+	// FileBundle are the files of the certificates of the CA. The signing key file is unused.
+	FileBundle SigningCAFileBundle
+	// ReloadInterval is the interval at which the backend is checked for a new version of the signing key.
+	ReloadInterval time.Duration
+	// OnKeyChange is called when a new version of the signing key is found, to reload the certificates of the CA with
+	// UpdateKeyCertBundleFromFile once they match the new key. The certificates are reloaded from FileBundle if unset.
+	OnKeyChange func()
+}
+
+// NewPluggedSignerIstioCAOptions returns a new IstioCAOptions instance using the given certificates, and the signing
+// key kept by the backend.
+func NewPluggedSignerIstioCAOptions(fileBundle SigningCAFileBundle, signingKey SigningKeyOptions,
+	defaultCertTTL, maxCertTTL time.Duration, caRSAKeySize int,
+) (caOpts *IstioCAOptions, err error) {
+	signingKey.FileBundle = fileBundle
+	caOpts = &IstioCAOptions{
+		CAType:         pluggedCertCA,
+		DefaultCertTTL: defaultCertTTL,
+		MaxCertTTL:     maxCertTTL,
+		CARSAKeySize:   caRSAKeySize,
+		SigningKey:     &signingKey,
+	}
+
+	keySigner, err := signingKey.Backend.Signer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the CA signing key: %v", err)
+	}
+	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleWithSignerFromFile(
+		fileBundle.SigningCertFile,
+		keySigner,
+		fileBundle.CertChainFiles,
+		fileBundle.RootCertFile,
+		fileBundle.CRLFile,
+	); err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+
+	// Validate that the passed in signing cert can be used as CA.
+	if cert, _, _, _ := caOpts.KeyCertBundle.GetAll(); !cert.IsCA {
+		return nil, fmt.Errorf("certificate is not authorized to sign other certificates")
+	}
+
+	return caOpts, nil
+}
+
+// signingKeyReloader hot reloads the signing key of the CA kept by a backend, when a new version of the key is found.
+type signingKeyReloader struct {
+	opts          SigningKeyOptions
+	keyCertBundle *util.KeyCertBundle
+
+	// mu serializes the reloads.
+	mu sync.Mutex
+}
+
+func newSigningKeyReloader(opts SigningKeyOptions, keyCertBundle *util.KeyCertBundle) *signingKeyReloader {
+	return &signingKeyReloader{opts: opts, keyCertBundle: keyCertBundle}
+}
+
+// keyChanged returns whether the current version of the key in the backend is not the one the CA signs with.
+func (r *signingKeyReloader) keyChanged() (bool, error) {
+	keySigner, err := r.opts.Backend.Signer()
+	if err != nil {
+		return false, err
+	}
+	_, current, _, _ := r.keyCertBundle.GetAll()
+	if current == nil {
+		return true, nil
+	}
+	currentSigner, ok := (*current).(crypto.Signer)
+	return !ok || !signer.SamePublicKey(currentSigner.Public(), keySigner.Public()), nil
+}
+
+// reload verifies and updates the certificates of the CA from the files, with the current version of the signing key.
+// The CA keeps its current key and certificates if they do not match, e.g. when the key was rotated in the backend
+// but the certificate of the new key is not available yet.
+func (r *signingKeyReloader) reload(fileBundle SigningCAFileBundle) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keySigner, err := r.opts.Backend.Signer()
+	if err != nil {
+		return fmt.Errorf("failed to load the CA signing key: %v", err)
+	}
+	return r.keyCertBundle.UpdateVerifiedKeyCertBundleWithSignerFromFile(
+		fileBundle.SigningCertFile,
+		keySigner,
+		fileBundle.CertChainFiles,
+		fileBundle.RootCertFile,
+		fileBundle.CRLFile,
+	)
+}
+
+func (r *signingKeyReloader) check() {
+	changed, err := r.keyChanged()
+	if err != nil {
+		pkiCaLog.Errorf("failed to check the CA signing key: %v", err)
+		return
+	}
+	if !changed {
+		return
+	}
+	pkiCaLog.Infof("found a new version of the CA signing key")
+	if r.opts.OnKeyChange != nil {
+		r.opts.OnKeyChange()
+		return
+	}
+	if err := r.reload(r.opts.FileBundle); err != nil {
+		pkiCaLog.Warnf("keeping the current CA signing key, as the CA certificate does not match the new version: %v", err)
+		return
+	}
+	pkiCaLog.Infof("switched to the new version of the CA signing key")
+}
+
+func (r *signingKeyReloader) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.opts.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.check()
+		case <-stop:
+			return
+		}
+	}
+}
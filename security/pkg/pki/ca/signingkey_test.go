@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/security/pkg/pki/signer"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type signingKeyTestCA struct {
+	cert *x509.Certificate
+	pem  []byte
+	key  crypto.Signer
+}
+
+func genSigningKeyTestCA(t *testing.T, org string, issuer *signingKeyTestCA) signingKeyTestCA {
+	t.Helper()
+	opts := util.CertOptions{Org: org, TTL: 24 * time.Hour, IsCA: true, ECSigAlg: util.EcdsaSigAlg}
+	if issuer == nil {
+		opts.IsSelfSigned = true
+	} else {
+		opts.SignerCert, opts.SignerPriv = issuer.cert, issuer.key
+	}
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(opts)
+	assert.NoError(t, err)
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	assert.NoError(t, err)
+	key, err := util.ParsePemEncodedKey(keyPEM)
+	assert.NoError(t, err)
+	return signingKeyTestCA{cert: cert, pem: certPEM, key: key.(crypto.Signer)}
+}
+
+// writeCACerts writes the certificates of the intermediate CA, without its key.
+func writeCACerts(t *testing.T, dir string, root, intermediate signingKeyTestCA) SigningCAFileBundle {
+	t.Helper()
+	fileBundle := SigningCAFileBundle{
+		RootCertFile:    filepath.Join(dir, RootCertFile),
+		CertChainFiles:  []string{filepath.Join(dir, CertChainFile)},
+		SigningCertFile: filepath.Join(dir, CACertFile),
+		SigningKeyFile:  filepath.Join(dir, CAPrivateKeyFile),
+	}
+	assert.NoError(t, os.WriteFile(fileBundle.RootCertFile, root.pem, 0o600))
+	assert.NoError(t, os.WriteFile(fileBundle.CertChainFiles[0], append(append([]byte{}, intermediate.pem...), root.pem...), 0o600))
+	assert.NoError(t, os.WriteFile(fileBundle.SigningCertFile, intermediate.pem, 0o600))
+	return fileBundle
+}
+
+// signWorkloadCert signs a workload certificate, and returns the issuer of the certificate.
+func signWorkloadCert(t *testing.T, ca *IstioCA) *x509.Certificate {
+	t.Helper()
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/default", ECSigAlg: util.EcdsaSigAlg})
+	assert.NoError(t, err)
+	certChainPEM, err := ca.SignWithCertChain(csrPEM, CertOpts{SubjectIDs: []string{"spiffe://cluster.local/ns/default/sa/default"}, TTL: time.Hour})
+	assert.NoError(t, err)
+	certs, _, err := util.ParsePemEncodedCertificateChain([]byte(certChainPEM[0]))
+	assert.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.GetCAKeyCertBundle().GetRootCertPem())
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(ca.GetCAKeyCertBundle().GetCertChainPem())
+	_, err = certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	assert.NoError(t, err)
+	issuer, err := util.ParsePemEncodedCertificate(ca.GetCAKeyCertBundle().GetCertChainPem())
+	assert.NoError(t, err)
+	assert.NoError(t, certs[0].CheckSignatureFrom(issuer))
+	return issuer
+}
+
+func TestPluggedSignerCA(t *testing.T) {
+	root := genSigningKeyTestCA(t, "root", nil)
+	intermediate := genSigningKeyTestCA(t, "intermediate", &root)
+	dir := t.TempDir()
+	fileBundle := writeCACerts(t, dir, root, intermediate)
+
+	fake, err := signer.StartFakeRemoteSigner(filepath.Join(dir, "signer.sock"), "istio-ca", intermediate.key)
+	assert.NoError(t, err)
+	defer fake.Stop()
+	backend, err := signer.NewRemoteBackend(signer.RemoteOptions{Address: fake.Address(), KeyName: "istio-ca"})
+	assert.NoError(t, err)
+	defer backend.Close()
+
+	caOpts, err := NewPluggedSignerIstioCAOptions(fileBundle, SigningKeyOptions{Backend: backend}, time.Hour, 24*time.Hour, 2048)
+	assert.NoError(t, err)
+	ca, err := NewIstioCA(caOpts)
+	assert.NoError(t, err)
+
+	// The CA signs with the remote signer, and never has the private key.
+	assert.Equal(t, signWorkloadCert(t, ca).Equal(intermediate.cert), true)
+	assert.Equal(t, fake.NumSignatures(), 1)
+	_, privKey, _, _ := ca.GetCAKeyCertBundle().GetAllPem()
+	assert.Equal(t, len(privKey), 0)
+	_, _, err = ca.GenKeyCert([]string{"istiod.istio-system.svc"}, time.Hour, false)
+	assert.NoError(t, err)
+	assert.Equal(t, fake.NumSignatures(), 2)
+
+	// The key is rotated in the signer: the CA keeps signing with the previous version until its certificate is
+	// updated.
+	newIntermediate := genSigningKeyTestCA(t, "intermediate", &root)
+	fake.RotateKey(newIntermediate.key)
+	changed, err := ca.signingKey.keyChanged()
+	assert.NoError(t, err)
+	assert.Equal(t, changed, true)
+	ca.signingKey.check()
+	assert.Equal(t, signWorkloadCert(t, ca).Equal(intermediate.cert), true)
+
+	writeCACerts(t, dir, root, newIntermediate)
+	ca.signingKey.check()
+	assert.Equal(t, signWorkloadCert(t, ca).Equal(newIntermediate.cert), true)
+	changed, err = ca.signingKey.keyChanged()
+	assert.NoError(t, err)
+	assert.Equal(t, changed, false)
+	fake.DisablePreviousVersions()
+	assert.Equal(t, signWorkloadCert(t, ca).Equal(newIntermediate.cert), true)
+
+	// Certificates not matching the current key are rejected.
+	writeCACerts(t, dir, root, intermediate)
+	err = ca.UpdateKeyCertBundleFromFile(fileBundle)
+	if err == nil || !strings.Contains(err.Error(), "does not match the key of the signer") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	assert.Equal(t, signWorkloadCert(t, ca).Equal(newIntermediate.cert), true)
+}
+
+func TestPluggedSignerCAOnKeyChange(t *testing.T) {
+	root := genSigningKeyTestCA(t, "root", nil)
+	intermediate := genSigningKeyTestCA(t, "intermediate", &root)
+	dir := t.TempDir()
+	fileBundle := writeCACerts(t, dir, root, intermediate)
+	fake, err := signer.StartFakeRemoteSigner(filepath.Join(dir, "signer.sock"), "istio-ca", intermediate.key)
+	assert.NoError(t, err)
+	defer fake.Stop()
+	backend, err := signer.NewRemoteBackend(signer.RemoteOptions{Address: fake.Address(), KeyName: "istio-ca"})
+	assert.NoError(t, err)
+	defer backend.Close()
+
+	keyChanges := make(chan struct{}, 10)
+	caOpts, err := NewPluggedSignerIstioCAOptions(fileBundle, SigningKeyOptions{
+		Backend:        backend,
+		ReloadInterval: 10 * time.Millisecond,
+		OnKeyChange:    func() { keyChanges <- struct{}{} },
+	}, time.Hour, 24*time.Hour, 2048)
+	assert.NoError(t, err)
+	ca, err := NewIstioCA(caOpts)
+	assert.NoError(t, err)
+	stop := make(chan struct{})
+	defer close(stop)
+	ca.Run(stop)
+
+	newIntermediate := genSigningKeyTestCA(t, "intermediate", &root)
+	writeCACerts(t, dir, root, newIntermediate)
+	fake.RotateKey(newIntermediate.key)
+	select {
+	case <-keyChanges:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the key change was not detected")
+	}
+	assert.NoError(t, ca.UpdateKeyCertBundleFromFile(fileBundle))
+	assert.Equal(t, signWorkloadCert(t, ca).Equal(newIntermediate.cert), true)
+}
+
+func TestNewPluggedSignerIstioCAOptions(t *testing.T) {
+	root := genSigningKeyTestCA(t, "root", nil)
+	intermediate := genSigningKeyTestCA(t, "intermediate", &root)
+	other := genSigningKeyTestCA(t, "other", &root)
+	dir := t.TempDir()
+	fileBundle := writeCACerts(t, dir, root, intermediate)
+	fake, err := signer.StartFakeRemoteSigner(filepath.Join(dir, "signer.sock"), "istio-ca", other.key)
+	assert.NoError(t, err)
+	defer fake.Stop()
+	backend, err := signer.NewRemoteBackend(signer.RemoteOptions{Address: fake.Address(), KeyName: "istio-ca"})
+	assert.NoError(t, err)
+	defer backend.Close()
+
+	_, err = NewPluggedSignerIstioCAOptions(fileBundle, SigningKeyOptions{Backend: backend}, time.Hour, 24*time.Hour, 2048)
+	if err == nil || !strings.Contains(err.Error(), "does not match the key of the signer") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
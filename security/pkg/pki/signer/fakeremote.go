@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Test only: this is a fake remote signer, keeping versions of a key in memory.
+
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"net"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pkg/signerapi"
+)
+
+var cryptoHashes = map[signerapi.HashAlgorithm]crypto.Hash{
+	signerapi.HashAlgorithm_SHA256: crypto.SHA256,
+	signerapi.HashAlgorithm_SHA384: crypto.SHA384,
+	signerapi.HashAlgorithm_SHA512: crypto.SHA512,
+}
+
+// FakeRemoteSigner is a fake remote signer serving the KeySigner API on a unix socket.
+type FakeRemoteSigner struct {
+	signerapi.UnimplementedKeySignerServer
+
+	keyName string
+	path    string
+	server  *grpc.Server
+
+	mutex          sync.Mutex
+	versions       []crypto.Signer
+	numSignatures  int
+	disabledBefore int
+}
+
+// StartFakeRemoteSigner starts a fake remote signer listening on the unix socket path, with a first version of the key.
+func StartFakeRemoteSigner(path, keyName string, key crypto.Signer) (*FakeRemoteSigner, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeRemoteSigner{keyName: keyName, path: path, server: grpc.NewServer(), versions: []crypto.Signer{key}}
+	signerapi.RegisterKeySignerServer(s.server, s)
+	go func() {
+		_ = s.server.Serve(l)
+	}()
+	return s, nil
+}
+
+// Address returns the address of the fake remote signer.
+func (s *FakeRemoteSigner) Address() string {
+	return "unix://" + s.path
+}
+
+// RotateKey adds a new version of the key, which becomes the current version.
+func (s *FakeRemoteSigner) RotateKey(key crypto.Signer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.versions = append(s.versions, key)
+}
+
+// DisablePreviousVersions disables all the versions of the key but the current one.
+func (s *FakeRemoteSigner) DisablePreviousVersions() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.disabledBefore = len(s.versions) - 1
+}
+
+// NumSignatures returns the number of signatures made.
+func (s *FakeRemoteSigner) NumSignatures() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.numSignatures
+}
+
+func (s *FakeRemoteSigner) Stop() {
+	s.server.Stop()
+}
+
+func (s *FakeRemoteSigner) GetPublicKey(_ context.Context, req *signerapi.GetPublicKeyRequest) (*signerapi.GetPublicKeyResponse, error) {
+	if req.KeyName != s.keyName {
+		return nil, status.Errorf(codes.NotFound, "key %s not found", req.KeyName)
+	}
+	s.mutex.Lock()
+	version := len(s.versions) - 1
+	key := s.versions[version]
+	s.mutex.Unlock()
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &signerapi.GetPublicKeyResponse{PublicKey: der, KeyVersion: strconv.Itoa(version + 1)}, nil
+}
+
+func (s *FakeRemoteSigner) Sign(_ context.Context, req *signerapi.SignRequest) (*signerapi.SignResponse, error) {
+	if req.KeyName != s.keyName {
+		return nil, status.Errorf(codes.NotFound, "key %s not found", req.KeyName)
+	}
+	hash, ok := cryptoHashes[req.Hash]
+	if !ok || len(req.Digest) != hash.Size() {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid digest")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	version, err := strconv.Atoi(req.KeyVersion)
+	if err != nil || version < 1 || version > len(s.versions) {
+		return nil, status.Errorf(codes.NotFound, "version %s of key %s not found", req.KeyVersion, req.KeyName)
+	}
+	if version <= s.disabledBefore {
+		return nil, status.Errorf(codes.FailedPrecondition, "version %s of key %s is disabled", req.KeyVersion, req.KeyName)
+	}
+	var opts crypto.SignerOpts = hash
+	if req.Padding == signerapi.RSAPadding_PSS {
+		saltLength := int(req.PssSaltLength)
+		if saltLength == 0 {
+			saltLength = rsa.PSSSaltLengthEqualsHash
+		}
+		opts = &rsa.PSSOptions{Hash: hash, SaltLength: saltLength}
+	}
+	signature, err := s.versions[version-1].Sign(rand.Reader, req.Digest, opts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.numSignatures++
+	return &signerapi.SignResponse{Signature: signature}, nil
+}
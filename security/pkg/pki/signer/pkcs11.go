@@ -0,0 +1,294 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo
+
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11Supported is whether the TypePKCS11 backend is supported by this build: loading PKCS#11 modules requires cgo.
+const PKCS11Supported = true
+
+var (
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+	// pkcs1DigestInfoPrefixes are the DER prefixes of the DigestInfo of PKCS #1 v1.5 signatures, as CKM_RSA_PKCS only
+	// pads the data signed.
+	pkcs1DigestInfoPrefixes = map[crypto.Hash][]byte{
+		crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+		crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+		crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+	}
+
+	// pssMechanisms are the hash and mask generation function mechanisms of the PSS signatures.
+	pssMechanisms = map[crypto.Hash][2]uint{
+		crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+		crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+		crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+	}
+)
+
+// pkcs11Backend keeps the signing key in a token of a PKCS#11 module.
+type pkcs11Backend struct {
+	opts PKCS11Options
+	ctx  *pkcs11.Ctx
+	slot uint
+
+	// mutex serializes the operations of the session, which PKCS#11 does not allow to be concurrent.
+	mutex   sync.Mutex
+	session pkcs11.SessionHandle
+}
+
+// NewPKCS11Backend loads the PKCS#11 module and logs in the token keeping the signing key.
+func NewPKCS11Backend(opts PKCS11Options) (Backend, error) {
+	if opts.Module == "" || opts.TokenLabel == "" || opts.KeyLabel == "" {
+		return nil, fmt.Errorf("the PKCS#11 module, token label and key label must be set")
+	}
+	ctx := pkcs11.New(opts.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load the PKCS#11 module %s", opts.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialize the PKCS#11 module %s: %v", opts.Module, err)
+	}
+	b := &pkcs11Backend{opts: opts, ctx: ctx}
+	if err := b.openSession(); err != nil {
+		_ = b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *pkcs11Backend) openSession() error {
+	slots, err := b.ctx.GetSlotList(true)
+	if err != nil {
+		return fmt.Errorf("failed to list the PKCS#11 slots: %v", err)
+	}
+	for _, slot := range slots {
+		info, err := b.ctx.GetTokenInfo(slot)
+		if err != nil || info.Label != b.opts.TokenLabel {
+			continue
+		}
+		b.slot = slot
+		b.session, err = b.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return fmt.Errorf("failed to open a session with the PKCS#11 token %s: %v", b.opts.TokenLabel, err)
+		}
+		if err := b.ctx.Login(b.session, pkcs11.CKU_USER, b.opts.PIN); err != nil &&
+			!errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+			return fmt.Errorf("failed to log in the PKCS#11 token %s: %v", b.opts.TokenLabel, err)
+		}
+		signerLog.Infof("opened a session with the PKCS#11 token %s", b.opts.TokenLabel)
+		return nil
+	}
+	return fmt.Errorf("PKCS#11 token %s not found", b.opts.TokenLabel)
+}
+
+// Signer looks up the key pair with the key label in the token.
+func (b *pkcs11Backend) Signer() (crypto.Signer, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	key, err := b.findObject(pkcs11.CKO_PRIVATE_KEY)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := b.findObject(pkcs11.CKO_PUBLIC_KEY)
+	if err != nil {
+		return nil, err
+	}
+	public, err := b.publicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the public key %s: %v", b.opts.KeyLabel, err)
+	}
+	return &pkcs11Signer{backend: b, key: key, public: public}, nil
+}
+
+func (b *pkcs11Backend) findObject(class uint) (pkcs11.ObjectHandle, error) {
+	if err := b.ctx.FindObjectsInit(b.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, b.opts.KeyLabel),
+	}); err != nil {
+		return 0, fmt.Errorf("failed to look up the key %s: %v", b.opts.KeyLabel, err)
+	}
+	objects, _, err := b.ctx.FindObjects(b.session, 2)
+	_ = b.ctx.FindObjectsFinal(b.session)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up the key %s: %v", b.opts.KeyLabel, err)
+	}
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("key %s not found in the PKCS#11 token %s", b.opts.KeyLabel, b.opts.TokenLabel)
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, fmt.Errorf("multiple keys %s found in the PKCS#11 token %s", b.opts.KeyLabel, b.opts.TokenLabel)
+	}
+}
+
+// publicKey reads the RSA or ECDSA public key object.
+func (b *pkcs11Backend) publicKey(object pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := b.ctx.GetAttributeValue(b.session, object, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch keyType := bytesToUint(attrs[0].Value); keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err = b.ctx.GetAttributeValue(b.session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	case pkcs11.CKK_EC:
+		attrs, err = b.ctx.GetAttributeValue(b.session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		// The point is a DER encoded octet string, and the parameters the DER encoded named curve, which are both
+		// wrapped in a PKIX public key to be parsed.
+		var point []byte
+		if _, err := asn1.Unmarshal(attrs[1].Value, &point); err != nil {
+			return nil, fmt.Errorf("invalid EC point: %v", err)
+		}
+		der, err := asn1.Marshal(struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: attrs[0].Value}},
+			PublicKey: asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return x509.ParsePKIXPublicKey(der)
+	default:
+		return nil, fmt.Errorf("unsupported key type %d", keyType)
+	}
+}
+
+func (b *pkcs11Backend) sign(key pkcs11.ObjectHandle, mechanism *pkcs11.Mechanism, data []byte) ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if err := b.ctx.SignInit(b.session, []*pkcs11.Mechanism{mechanism}, key); err != nil {
+		return nil, err
+	}
+	return b.ctx.Sign(b.session, data)
+}
+
+func (b *pkcs11Backend) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.session != 0 {
+		_ = b.ctx.Logout(b.session)
+		_ = b.ctx.CloseSession(b.session)
+	}
+	err := b.ctx.Finalize()
+	b.ctx.Destroy()
+	return err
+}
+
+// pkcs11Signer signs with a private key object of a PKCS#11 token.
+type pkcs11Signer struct {
+	backend *pkcs11Backend
+	key     pkcs11.ObjectHandle
+	public  crypto.PublicKey
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch s.public.(type) {
+	case *ecdsa.PublicKey:
+		raw, err := s.backend.sign(s.key, pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign with the PKCS#11 key: %v", err)
+		}
+		// The signature is the concatenation of r and s, while Go expects an ASN.1 signature.
+		half := len(raw) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			R: new(big.Int).SetBytes(raw[:half]),
+			S: new(big.Int).SetBytes(raw[half:]),
+		})
+	case *rsa.PublicKey:
+		var mechanism *pkcs11.Mechanism
+		data := digest
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			mechanisms, ok := pssMechanisms[opts.HashFunc()]
+			if !ok {
+				return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+			}
+			saltLength := pss.SaltLength
+			if saltLength == rsa.PSSSaltLengthAuto || saltLength == rsa.PSSSaltLengthEqualsHash {
+				saltLength = opts.HashFunc().Size()
+			}
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS,
+				pkcs11.NewPSSParams(mechanisms[0], mechanisms[1], uint(saltLength)))
+		} else {
+			prefix, ok := pkcs1DigestInfoPrefixes[opts.HashFunc()]
+			if !ok {
+				return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+			}
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+			data = append(append([]byte{}, prefix...), digest...)
+		}
+		signature, err := s.backend.sign(s.key, mechanism, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign with the PKCS#11 key: %v", err)
+		}
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", s.public)
+	}
+}
+
+// bytesToUint decodes a CK_ULONG attribute, which is in the native byte order.
+func bytesToUint(b []byte) uint {
+	switch len(b) {
+	case 4:
+		return uint(binary.NativeEndian.Uint32(b))
+	case 8:
+		return uint(binary.NativeEndian.Uint64(b))
+	default:
+		return ^uint(0)
+	}
+}
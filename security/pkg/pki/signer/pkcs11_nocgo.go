@@ -0,0 +1,27 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !cgo
+
+package signer
+
+import "fmt"
+
+// PKCS11Supported is whether the TypePKCS11 backend is supported by this build: loading PKCS#11 modules requires cgo.
+const PKCS11Supported = false
+
+// NewPKCS11Backend returns an error, as loading PKCS#11 modules requires cgo.
+func NewPKCS11Backend(PKCS11Options) (Backend, error) {
+	return nil, fmt.Errorf("the PKCS#11 signing key backend requires a build with cgo enabled")
+}
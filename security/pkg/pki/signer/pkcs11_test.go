@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo
+
+package signer
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+const (
+	softHSMToken = "istio"
+	softHSMPIN   = "1234"
+)
+
+// oidP256 is the DER encoded named curve P-256.
+var oidP256 = []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}
+
+// softHSMModule returns the path of the SoftHSM module, set by SOFTHSM2_MODULE or installed by the distribution.
+func softHSMModule(t *testing.T) string {
+	candidates := []string{
+		os.Getenv("SOFTHSM2_MODULE"),
+		"/usr/lib/softhsm/libsofthsm2.so",
+		"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+		"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+		"/usr/local/lib/softhsm/libsofthsm2.so",
+	}
+	for _, module := range candidates {
+		if module == "" {
+			continue
+		}
+		if _, err := os.Stat(module); err == nil {
+			return module
+		}
+	}
+	t.Skip("SoftHSM is not installed, set SOFTHSM2_MODULE to run the PKCS#11 tests")
+	return ""
+}
+
+// initSoftHSMToken initializes a SoftHSM token in a temporary directory.
+func initSoftHSMToken(t *testing.T, module string) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	assert.NoError(t, os.WriteFile(conf, []byte("directories.tokendir = "+dir+"\nobjectstore.backend = file\n"), 0o600))
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	ctx := pkcs11.New(module)
+	assert.NoError(t, ctx.Initialize())
+	defer func() {
+		_ = ctx.Finalize()
+		ctx.Destroy()
+	}()
+	slots, err := ctx.GetSlotList(true)
+	assert.NoError(t, err)
+	assert.NoError(t, ctx.InitToken(slots[0], "so-pin", softHSMToken))
+	// SoftHSM assigns a new slot to the initialized token.
+	slots, err = ctx.GetSlotList(true)
+	assert.NoError(t, err)
+	for _, slot := range slots {
+		if info, err := ctx.GetTokenInfo(slot); err != nil || info.Label != softHSMToken {
+			continue
+		}
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		assert.NoError(t, err)
+		assert.NoError(t, ctx.Login(session, pkcs11.CKU_SO, "so-pin"))
+		assert.NoError(t, ctx.InitPIN(session, softHSMPIN))
+		assert.NoError(t, ctx.Logout(session))
+		assert.NoError(t, ctx.CloseSession(session))
+		return
+	}
+	t.Fatalf("token %s not found", softHSMToken)
+}
+
+// generateKeyPair generates a key pair in the token of the backend, replacing the key pair with the same label.
+func generateKeyPair(t *testing.T, b *pkcs11Backend, label string, rsaKey bool) {
+	t.Helper()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	// The session of the backend is read only.
+	session, err := b.ctx.OpenSession(b.slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	assert.NoError(t, err)
+	defer b.ctx.CloseSession(session)
+	for _, class := range []uint{pkcs11.CKO_PRIVATE_KEY, pkcs11.CKO_PUBLIC_KEY} {
+		if object, err := b.findObject(class); err == nil {
+			assert.NoError(t, b.ctx.DestroyObject(session, object))
+		}
+	}
+	mechanism := pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)
+	public := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if rsaKey {
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)
+		public = append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}))
+	} else {
+		public = append(public, pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, oidP256))
+	}
+	_, _, err = b.ctx.GenerateKeyPair(session, []*pkcs11.Mechanism{mechanism}, public, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	assert.NoError(t, err)
+}
+
+func newSoftHSMBackend(t *testing.T) *pkcs11Backend {
+	module := softHSMModule(t)
+	initSoftHSMToken(t, module)
+	b, err := NewPKCS11Backend(PKCS11Options{Module: module, TokenLabel: softHSMToken, PIN: softHSMPIN, KeyLabel: "istio-ca"})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+	return b.(*pkcs11Backend)
+}
+
+func TestPKCS11Backend(t *testing.T) {
+	cases := []struct {
+		name   string
+		rsaKey bool
+		opts   crypto.SignerOpts
+	}{
+		{name: "ecdsa", opts: crypto.SHA256},
+		{name: "rsa", rsaKey: true, opts: crypto.SHA256},
+		{name: "rsa pss", rsaKey: true, opts: &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newSoftHSMBackend(t)
+			if _, err := b.Signer(); err == nil || !strings.Contains(err.Error(), "not found") {
+				t.Fatalf("unexpected error %v", err)
+			}
+			generateKeyPair(t, b, "istio-ca", tt.rsaKey)
+			signer, err := b.Signer()
+			assert.NoError(t, err)
+			verifySignature(t, signer, tt.opts)
+		})
+	}
+}
+
+func TestPKCS11BackendKeyRotation(t *testing.T) {
+	b := newSoftHSMBackend(t)
+	generateKeyPair(t, b, "istio-ca", false)
+	oldSigner, err := b.Signer()
+	assert.NoError(t, err)
+
+	generateKeyPair(t, b, "istio-ca", false)
+	newSigner, err := b.Signer()
+	assert.NoError(t, err)
+	assert.Equal(t, SamePublicKey(oldSigner.Public(), newSigner.Public()), false)
+	verifySignature(t, newSigner, crypto.SHA256)
+}
+
+func TestNewPKCS11Backend(t *testing.T) {
+	cases := []struct {
+		name    string
+		opts    PKCS11Options
+		wantErr string
+	}{
+		{name: "no token", opts: PKCS11Options{Module: "/softhsm.so", KeyLabel: "istio-ca"}, wantErr: "must be set"},
+		{name: "no module", opts: PKCS11Options{Module: "/nonexistent/softhsm.so", TokenLabel: "istio", KeyLabel: "istio-ca"}, wantErr: "failed to load"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPKCS11Backend(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"istio.io/istio/pkg/signerapi"
+)
+
+// remoteTimeout bounds the requests to the remote signer.
+const remoteTimeout = 10 * time.Second
+
+var hashAlgorithms = map[crypto.Hash]signerapi.HashAlgorithm{
+	crypto.SHA256: signerapi.HashAlgorithm_SHA256,
+	crypto.SHA384: signerapi.HashAlgorithm_SHA384,
+	crypto.SHA512: signerapi.HashAlgorithm_SHA512,
+}
+
+// RemoteOptions is the configuration of a signing key kept by a remote signer.
+type RemoteOptions struct {
+	// Address is the address of the remote signer: either a unix socket, e.g. unix:///var/run/signer/socket, or a
+	// host and port reached over TLS.
+	Address string
+	// KeyName is the name of the key in the remote signer.
+	KeyName string
+	// RootCertFile is the file of the root certificates verifying the TLS certificate of the remote signer. The system
+	// roots are used if unset. Unused for unix sockets.
+	RootCertFile string
+}
+
+// remoteBackend keeps the signing key in a remote signer implementing the KeySigner API.
+type remoteBackend struct {
+	opts   RemoteOptions
+	conn   *grpc.ClientConn
+	client signerapi.KeySignerClient
+}
+
+// NewRemoteBackend creates a backend connecting to the remote signer.
+func NewRemoteBackend(opts RemoteOptions) (Backend, error) {
+	if opts.Address == "" || opts.KeyName == "" {
+		return nil, fmt.Errorf("the address of the remote signer and the key name must be set")
+	}
+	creds := insecure.NewCredentials()
+	if !strings.HasPrefix(opts.Address, "unix:") {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if opts.RootCertFile != "" {
+			roots, err := os.ReadFile(opts.RootCertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the root certificates of the remote signer: %v", err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(roots) {
+				return nil, fmt.Errorf("no root certificate found in %s", opts.RootCertFile)
+			}
+		}
+		creds = credentials.NewTLS(config)
+	}
+	conn, err := grpc.NewClient(opts.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the remote signer %s: %v", opts.Address, err)
+	}
+	return &remoteBackend{opts: opts, conn: conn, client: signerapi.NewKeySignerClient(conn)}, nil
+}
+
+// Signer looks up the current version of the key in the remote signer.
+func (b *remoteBackend) Signer() (crypto.Signer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	resp, err := b.client.GetPublicKey(ctx, &signerapi.GetPublicKeyRequest{KeyName: b.opts.KeyName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the public key %s from the remote signer: %v", b.opts.KeyName, err)
+	}
+	public, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %s: %v", b.opts.KeyName, err)
+	}
+	return &remoteSigner{client: b.client, keyName: b.opts.KeyName, version: resp.KeyVersion, public: public}, nil
+}
+
+func (b *remoteBackend) Close() error {
+	return b.conn.Close()
+}
+
+// remoteSigner signs with a version of a key of a remote signer.
+type remoteSigner struct {
+	client  signerapi.KeySignerClient
+	keyName string
+	version string
+	public  crypto.PublicKey
+}
+
+func (s *remoteSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *remoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash, ok := hashAlgorithms[opts.HashFunc()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+	}
+	req := &signerapi.SignRequest{
+		KeyName:    s.keyName,
+		KeyVersion: s.version,
+		Digest:     digest,
+		Hash:       hash,
+	}
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		req.Padding = signerapi.RSAPadding_PSS
+		if pss.SaltLength > 0 {
+			req.PssSaltLength = int32(pss.SaltLength)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	resp, err := s.client.Sign(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with the key %s version %s of the remote signer: %v", s.keyName, s.version, err)
+	}
+	return resp.Signature, nil
+}
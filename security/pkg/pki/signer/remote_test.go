@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+// verifySignature signs a digest with the signer, and verifies the signature with the public key of the signer.
+func verifySignature(t *testing.T, signer crypto.Signer, opts crypto.SignerOpts) {
+	t.Helper()
+	var digest []byte
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		d := sha256.Sum256([]byte("csr"))
+		digest = d[:]
+	case crypto.SHA384:
+		d := sha512.Sum384([]byte("csr"))
+		digest = d[:]
+	}
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	assert.NoError(t, err)
+	switch public := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		assert.Equal(t, ecdsa.VerifyASN1(public, digest, signature), true)
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			assert.NoError(t, rsa.VerifyPSS(public, opts.HashFunc(), digest, signature, pss))
+		} else {
+			assert.NoError(t, rsa.VerifyPKCS1v15(public, opts.HashFunc(), digest, signature))
+		}
+	default:
+		t.Fatalf("unexpected public key %T", public)
+	}
+}
+
+func startFakeRemoteSigner(t *testing.T, key crypto.Signer) *FakeRemoteSigner {
+	t.Helper()
+	s, err := StartFakeRemoteSigner(filepath.Join(t.TempDir(), "signer.sock"), "istio-ca", key)
+	assert.NoError(t, err)
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestRemoteBackend(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name string
+		key  crypto.Signer
+		opts crypto.SignerOpts
+	}{
+		{name: "ecdsa", key: ecKey, opts: crypto.SHA256},
+		{name: "ecdsa sha384", key: ecKey, opts: crypto.SHA384},
+		{name: "rsa", key: rsaKey, opts: crypto.SHA256},
+		{name: "rsa pss", key: rsaKey, opts: &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := startFakeRemoteSigner(t, tt.key)
+			b, err := NewBackend(Options{Type: TypeRemote, Remote: RemoteOptions{Address: fake.Address(), KeyName: "istio-ca"}})
+			assert.NoError(t, err)
+			defer b.Close()
+			signer, err := b.Signer()
+			assert.NoError(t, err)
+			assert.Equal(t, SamePublicKey(signer.Public(), tt.key.Public()), true)
+			verifySignature(t, signer, tt.opts)
+			assert.Equal(t, fake.NumSignatures(), 1)
+		})
+	}
+}
+
+func TestRemoteBackendKeyRotation(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	fake := startFakeRemoteSigner(t, oldKey)
+	b, err := NewRemoteBackend(RemoteOptions{Address: fake.Address(), KeyName: "istio-ca"})
+	assert.NoError(t, err)
+	defer b.Close()
+	oldSigner, err := b.Signer()
+	assert.NoError(t, err)
+
+	// The signer of the new version is returned after the rotation, while the previous signer keeps its version.
+	fake.RotateKey(newKey)
+	newSigner, err := b.Signer()
+	assert.NoError(t, err)
+	assert.Equal(t, SamePublicKey(newSigner.Public(), newKey.Public()), true)
+	verifySignature(t, newSigner, crypto.SHA384)
+	verifySignature(t, oldSigner, crypto.SHA256)
+
+	fake.DisablePreviousVersions()
+	_, err = oldSigner.Sign(rand.Reader, make([]byte, sha256.Size), crypto.SHA256)
+	if err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	cases := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{name: "unknown", opts: Options{Type: "vault"}, wantErr: "unknown signing key backend"},
+		{name: "remote without key", opts: Options{Type: TypeRemote, Remote: RemoteOptions{Address: "unix:///signer.sock"}}, wantErr: "must be set"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBackend(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signer provides backends keeping the signing key of the CA outside of the memory of istiod, such as a
+// PKCS#11 module or a remote signer, behind the crypto.Signer interface.
+package signer
+
+import (
+	"crypto"
+	"fmt"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// TypePKCS11 keeps the signing key in a token of a PKCS#11 module, such as an HSM.
+	TypePKCS11 = "pkcs11"
+	// TypeRemote keeps the signing key in a remote signer implementing the KeySigner gRPC API, such as a KMS plugin.
+	TypeRemote = "remote"
+)
+
+var signerLog = log.RegisterScope("casigner", "CA signing key backends")
+
+// Backend keeps a signing key, which it uses to sign without ever exposing the private key.
+type Backend interface {
+	// Signer returns a signer using the current version of the key. The key is looked up on each call, so the signer
+	// returned changes after the key is rotated in the backend. The signers previously returned remain usable as long
+	// as the backend keeps their version of the key.
+	Signer() (crypto.Signer, error)
+	// Close releases the resources of the backend.
+	Close() error
+}
+
+// Options selects and configures the backend of a signing key.
+type Options struct {
+	// Type is the type of the backend, TypePKCS11 or TypeRemote.
+	Type string
+	// PKCS11 is the configuration of the TypePKCS11 backend.
+	PKCS11 PKCS11Options
+	// Remote is the configuration of the TypeRemote backend.
+	Remote RemoteOptions
+}
+
+// PKCS11Options is the configuration of a signing key kept in a token of a PKCS#11 module.
+type PKCS11Options struct {
+	// Module is the path of the PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so.
+	Module string
+	// TokenLabel is the label of the token keeping the key.
+	TokenLabel string
+	// PIN is the PIN of the user of the token.
+	PIN string
+	// KeyLabel is the label of the private and public key objects of the key pair.
+	KeyLabel string
+}
+
+// NewBackend creates the backend of a signing key selected by the options.
+func NewBackend(opts Options) (Backend, error) {
+	switch opts.Type {
+	case TypePKCS11:
+		return NewPKCS11Backend(opts.PKCS11)
+	case TypeRemote:
+		return NewRemoteBackend(opts.Remote)
+	default:
+		return nil, fmt.Errorf("unknown signing key backend %q, permitted values are %s and %s", opts.Type, TypePKCS11, TypeRemote)
+	}
+}
+
+// SamePublicKey returns whether the public keys are equal, e.g. to check whether a certificate matches a signer.
+func SamePublicKey(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
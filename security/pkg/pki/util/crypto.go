@@ -176,6 +176,15 @@ func GetEllipticCurve(privKey *crypto.PrivateKey) (elliptic.Curve, error) {
 			return key.Curve, nil
 		}
 		return elliptic.P256(), nil
+	case crypto.Signer:
+		// The private key is kept by the signer, e.g. in an HSM.
+		if public, ok := key.Public().(*ecdsa.PublicKey); ok {
+			if public.Curve == elliptic.P384() {
+				return public.Curve, nil
+			}
+			return elliptic.P256(), nil
+		}
+		return nil, fmt.Errorf("private key is not ECDSA based")
 	default:
 		return nil, fmt.Errorf("private key is not ECDSA based")
 	}
@@ -67,6 +67,22 @@ func NewVerifiedKeyCertBundleFromPem(certBytes, privKeyBytes, certChainBytes, ro
 	return bundle, nil
 }
 
+// NewVerifiedKeyCertBundleWithSignerFromFile returns a new KeyCertBundle whose private key is kept by the signer,
+// e.g. in an HSM, or error if the provided certs failed the verification.
+func NewVerifiedKeyCertBundleWithSignerFromFile(
+	certFile string, signer crypto.Signer,
+	certChainFiles []string,
+	rootCertFile, crlFile string,
+) (
+	*KeyCertBundle, error,
+) {
+	bundle := &KeyCertBundle{}
+	if err := bundle.UpdateVerifiedKeyCertBundleWithSignerFromFile(certFile, signer, certChainFiles, rootCertFile, crlFile); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
 // NewVerifiedKeyCertBundleFromFile returns a new KeyCertBundle, or error if the provided certs failed the
 // verification.
 func NewVerifiedKeyCertBundleFromFile(
@@ -188,8 +204,27 @@ func (b *KeyCertBundle) VerifyAndSetAll(certBytes, privKeyBytes, certChainBytes,
 	return nil
 }
 
+// VerifyAndSetAllWithSigner is similar to VerifyAndSetAll, for a private key kept by the signer. The PEM of the
+// private key of the bundle is empty.
+func (b *KeyCertBundle) VerifyAndSetAllWithSigner(certBytes []byte, signer crypto.Signer, certChainBytes, rootCertBytes,
+	crlBytes []byte,
+) error {
+	if err := VerifyWithSigner(certBytes, signer, certChainBytes, rootCertBytes, crlBytes); err != nil {
+		return err
+	}
+	b.setAll(certBytes, nil, signer, certChainBytes, rootCertBytes, crlBytes)
+	return nil
+}
+
 // Setting all values together avoids inconsistency.
 func (b *KeyCertBundle) setAllFromPem(certBytes, privKeyBytes, certChainBytes, rootCertBytes, crlBytes []byte) {
+	privKey, _ := ParsePemEncodedKey(privKeyBytes)
+	b.setAll(certBytes, privKeyBytes, privKey, certChainBytes, rootCertBytes, crlBytes)
+}
+
+func (b *KeyCertBundle) setAll(certBytes, privKeyBytes []byte, privKey crypto.PrivateKey, certChainBytes, rootCertBytes,
+	crlBytes []byte,
+) {
 	b.mutex.Lock()
 	b.certBytes = copyBytes(certBytes)
 	b.privKeyBytes = copyBytes(privKeyBytes)
@@ -204,7 +239,6 @@ func (b *KeyCertBundle) setAllFromPem(certBytes, privKeyBytes, certChainBytes, r
 	// cert and privKey are always reset to point to new addresses. This avoids modifying the pointed structs that
 	// could be still used outside of the class.
 	b.cert, _ = ParsePemEncodedCertificate(certBytes)
-	privKey, _ := ParsePemEncodedKey(privKeyBytes)
 	b.privKey = &privKey
 	b.mutex.Unlock()
 }
@@ -229,7 +263,7 @@ func (b *KeyCertBundle) CertOptions() (*CertOptions, error) {
 		IsDualUse: ids[0] == b.cert.Subject.CommonName,
 	}
 
-	switch (*b.privKey).(type) {
+	switch key := (*b.privKey).(type) {
 	case *rsa.PrivateKey:
 		size, err := GetRSAKeySize(*b.privKey)
 		if err != nil {
@@ -238,6 +272,16 @@ func (b *KeyCertBundle) CertOptions() (*CertOptions, error) {
 		opts.RSAKeySize = size
 	case *ecdsa.PrivateKey:
 		opts.ECSigAlg = EcdsaSigAlg
+	case crypto.Signer:
+		// The private key is kept by the signer, e.g. in an HSM.
+		switch public := key.Public().(type) {
+		case *rsa.PublicKey:
+			opts.RSAKeySize = public.N.BitLen()
+		case *ecdsa.PublicKey:
+			opts.ECSigAlg = EcdsaSigAlg
+		default:
+			return nil, errors.New("unknown private key type")
+		}
 	default:
 		return nil, errors.New("unknown private key type")
 	}
@@ -251,7 +295,7 @@ func (b *KeyCertBundle) UpdateVerifiedKeyCertBundleFromFile(
 	certChainFiles []string,
 	rootCertFile, crlFile string,
 ) error {
-	certBytes, err := os.ReadFile(certFile)
+	certBytes, certChainBytes, rootCertBytes, crlBytes, err := readCertFiles(certFile, certChainFiles, rootCertFile, crlFile)
 	if err != nil {
 		return err
 	}
@@ -259,34 +303,57 @@ func (b *KeyCertBundle) UpdateVerifiedKeyCertBundleFromFile(
 	if err != nil {
 		return err
 	}
-	certChainBytes := []byte{}
-	if len(certChainFiles) != 0 {
-		for _, f := range certChainFiles {
-			var b []byte
-			if b, err = os.ReadFile(f); err != nil {
-				return err
-			}
 
-			certChainBytes = append(certChainBytes, b...)
+	err = b.VerifyAndSetAll(certBytes, privKeyBytes, certChainBytes, rootCertBytes, crlBytes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readCertFiles reads the cert, cert chain, root cert and optional CRL files.
+func readCertFiles(certFile string, certChainFiles []string, rootCertFile, crlFile string) (
+	certBytes, certChainBytes, rootCertBytes, crlBytes []byte, err error,
+) {
+	certBytes, err = os.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	certChainBytes = []byte{}
+	for _, f := range certChainFiles {
+		var b []byte
+		if b, err = os.ReadFile(f); err != nil {
+			return nil, nil, nil, nil, err
 		}
+
+		certChainBytes = append(certChainBytes, b...)
 	}
-	rootCertBytes, err := os.ReadFile(rootCertFile)
+	rootCertBytes, err = os.ReadFile(rootCertFile)
 	if err != nil {
-		return err
+		return nil, nil, nil, nil, err
 	}
 
 	// Read CRL file if provided
-	crlBytes, crlErr := gerCRLBytesFromFile(crlFile)
-	if crlErr != nil {
-		return crlErr
+	crlBytes, err = gerCRLBytesFromFile(crlFile)
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
+	return certBytes, certChainBytes, rootCertBytes, crlBytes, nil
+}
 
-	err = b.VerifyAndSetAll(certBytes, privKeyBytes, certChainBytes, rootCertBytes, crlBytes)
+// UpdateVerifiedKeyCertBundleWithSignerFromFile is similar to UpdateVerifiedKeyCertBundleFromFile, for a private key
+// kept by the signer.
+func (b *KeyCertBundle) UpdateVerifiedKeyCertBundleWithSignerFromFile(
+	certFile string, signer crypto.Signer,
+	certChainFiles []string,
+	rootCertFile, crlFile string,
+) error {
+	certBytes, certChainBytes, rootCertBytes, crlBytes, err := readCertFiles(certFile, certChainFiles, rootCertFile, crlFile)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return b.VerifyAndSetAllWithSigner(certBytes, signer, certChainBytes, rootCertBytes, crlBytes)
 }
 
 // gerCRLBytesFromFile reads the CRL file and returns the content if it exists.
@@ -328,6 +395,32 @@ func TimeBeforeCertExpires(certBytes []byte, now time.Time) (time.Duration, erro
 
 // Verify that the cert chain, root cert and key/cert match.
 func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes, crl []byte) error {
+	return verify(certBytes, func(*x509.Certificate) error {
+		// Verify that the key can be correctly parsed.
+		if _, err := ParsePemEncodedKey(privKeyBytes); err != nil {
+			return fmt.Errorf("failed to parse private key PEM: %v", err)
+		}
+
+		// Verify the cert and key match.
+		if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
+			return fmt.Errorf("the cert does not match the key: %v", err)
+		}
+		return nil
+	}, certChainBytes, rootCertBytes, crl)
+}
+
+// VerifyWithSigner verifies that the cert chain, root cert and the cert and the key of the signer match.
+func VerifyWithSigner(certBytes []byte, signer crypto.Signer, certChainBytes, rootCertBytes, crl []byte) error {
+	return verify(certBytes, func(cert *x509.Certificate) error {
+		if key, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !key.Equal(signer.Public()) {
+			return fmt.Errorf("the cert does not match the key of the signer")
+		}
+		return nil
+	}, certChainBytes, rootCertBytes, crl)
+}
+
+// verify verifies the cert chain, root cert and CRL, and that the cert matches the key with verifyKey.
+func verify(certBytes []byte, verifyKey func(cert *x509.Certificate) error, certChainBytes, rootCertBytes, crl []byte) error {
 	// Verify the cert can be verified from the root cert through the cert chain.
 	rcp := x509.NewCertPool()
 	rcp.AppendCertsFromPEM(rootCertBytes)
@@ -351,14 +444,8 @@ func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes, crl []byte)
 				"pool with error: %v", err)
 	}
 
-	// Verify that the key can be correctly parsed.
-	if _, err = ParsePemEncodedKey(privKeyBytes); err != nil {
-		return fmt.Errorf("failed to parse private key PEM: %v", err)
-	}
-
-	// Verify the cert and key match.
-	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
-		return fmt.Errorf("the cert does not match the key: %v", err)
+	if err := verifyKey(cert); err != nil {
+		return err
 	}
 
 	// verify only if the CRL is provided
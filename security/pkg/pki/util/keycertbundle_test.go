@@ -15,7 +15,9 @@
 package util
 
 import (
+	"crypto"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -391,6 +393,90 @@ func TestNewVerifiedKeyCertBundleFromFileWithCrl(t *testing.T) {
 	}
 }
 
+// opaqueSigner hides the private key of the signer, like an HSM.
+type opaqueSigner struct {
+	crypto.Signer
+}
+
+func loadOpaqueSigner(t *testing.T, keyFile string) crypto.Signer {
+	t.Helper()
+	keyPem, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePemEncodedKey(keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opaqueSigner{key.(crypto.Signer)}
+}
+
+func TestNewVerifiedKeyCertBundleWithSignerFromFile(t *testing.T) {
+	testCases := map[string]struct {
+		caCertFile    string
+		caKeyFile     string
+		certChainFile []string
+		rootCertFile  string
+		expectedErr   string
+	}{
+		"success": {
+			caCertFile:    intCertFile,
+			caKeyFile:     intKeyFile,
+			certChainFile: []string{intCertChainFile},
+			rootCertFile:  rootCertFile,
+		},
+		"ec key": {
+			caCertFile:    ecRootCertFile,
+			caKeyFile:     ecRootKeyFile,
+			certChainFile: nil,
+			rootCertFile:  ecRootCertFile,
+		},
+		"cert not matching the key": {
+			caCertFile:    intCertFile,
+			caKeyFile:     int2KeyFile,
+			certChainFile: []string{intCertChainFile},
+			rootCertFile:  rootCertFile,
+			expectedErr:   "the cert does not match the key of the signer",
+		},
+		"cert not verified by the root": {
+			caCertFile:    intCertFile,
+			caKeyFile:     intKeyFile,
+			certChainFile: []string{intCertChainFile},
+			rootCertFile:  anotherRootCertFile,
+			expectedErr:   "cannot verify the cert with the provided root chain and cert pool",
+		},
+	}
+	for id, tc := range testCases {
+		t.Run(id, func(t *testing.T) {
+			signer := loadOpaqueSigner(t, tc.caKeyFile)
+			bundle, err := NewVerifiedKeyCertBundleWithSignerFromFile(
+				tc.caCertFile, signer, tc.certChainFile, tc.rootCertFile, "")
+			if err != nil {
+				if tc.expectedErr == "" {
+					t.Fatalf("Unexpected error: %v", err)
+				} else if !strings.HasPrefix(err.Error(), tc.expectedErr) {
+					t.Fatalf("Unexpected error: %v VS (expected) %s", err, tc.expectedErr)
+				}
+				return
+			} else if tc.expectedErr != "" {
+				t.Fatalf("Expected error %s but succeeded", tc.expectedErr)
+			}
+			// The bundle signs with the signer, and has no private key PEM.
+			_, key, _, _ := bundle.GetAll()
+			if *key != signer {
+				t.Errorf("Unexpected key %T", *key)
+			}
+			if _, privKey, _, _ := bundle.GetAllPem(); len(privKey) != 0 {
+				t.Errorf("Unexpected private key PEM %s", privKey)
+			}
+			_, err = GetEllipticCurve(key)
+			if ec := id == "ec key"; ec != (err == nil) {
+				t.Errorf("Unexpected elliptic curve error %v", err)
+			}
+		})
+	}
+}
+
 // Test the root cert expiry timestamp can be extracted correctly.
 func TestExtractRootCertExpiryTimestamp(t *testing.T) {
 	testCases := map[string]struct {
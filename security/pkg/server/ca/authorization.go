@@ -0,0 +1,289 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/slices"
+)
+
+// Reasons of the CSR authorization failures, used as label of the metric.
+const (
+	AuthorizationDeniedCaller  = "caller"
+	AuthorizationDeniedSAN     = "san"
+	AuthorizationDeniedTTL     = "ttl"
+	AuthorizationDeniedKeyType = "key_type"
+)
+
+// CSRRequest is a certificate signing request of an authenticated caller, to be authorized.
+type CSRRequest struct {
+	// Caller is the authenticated caller.
+	Caller *security.Caller
+	// SANs are the identities of the certificate. They are the identities of the caller, unless the caller
+	// impersonates another identity.
+	SANs []string
+	// TTL is the effective TTL of the certificate: the requested TTL, or the default TTL of the CA if the request
+	// has none. 0 if the default TTL is unknown.
+	TTL time.Duration
+	// CSR is the parsed certificate signing request.
+	CSR *x509.CertificateRequest
+}
+
+// CSRAuthorizer authorizes the certificate signing requests of authenticated callers, before they are signed.
+type CSRAuthorizer interface {
+	// AuthorizeCSR returns an AuthorizationError if the request is denied.
+	AuthorizeCSR(ctx context.Context, request *CSRRequest) error
+}
+
+// AuthorizationError is returned by a CSRAuthorizer denying a request.
+type AuthorizationError struct {
+	// Reason is one of the AuthorizationDenied* constants.
+	Reason  string
+	Message string
+}
+
+func (e *AuthorizationError) Error() string {
+	return e.Message
+}
+
+func denied(reason string, format string, args ...any) *AuthorizationError {
+	return &AuthorizationError{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// CSRAuthorizationAction is the action applied to the callers which match no rule of a CSRAuthorizationPolicy.
+type CSRAuthorizationAction string
+
+const (
+	CSRAuthorizationAllow CSRAuthorizationAction = "ALLOW"
+	CSRAuthorizationDeny  CSRAuthorizationAction = "DENY"
+)
+
+// CSRAuthorizationPolicy restricts the certificates the callers of the CA may request. For instance, the following
+// policy only allows the workloads of the tenant-a namespace to request certificates for their namespace, with a
+// TTL of at most a day and an ECDSA key, and denies every other caller which is not in the istio-system namespace:
+//
+//	defaultAction: DENY
+//	rules:
+//	- callers: ["spiffe://cluster.local/ns/tenant-a/*"]
+//	  sans: ["spiffe://cluster.local/ns/tenant-a/*"]
+//	  maxTTL: 24h
+//	  keyTypes: ["ECDSA-*"]
+//	- callers: ["spiffe://cluster.local/ns/istio-system/*"]
+//
+// The patterns are exact values, or have a single '*' wildcard as prefix or suffix.
+type CSRAuthorizationPolicy struct {
+	// DefaultAction is applied to the callers which match no rule. Defaults to ALLOW.
+	DefaultAction CSRAuthorizationAction `json:"defaultAction,omitempty"`
+	// Rules are evaluated in order: a request is allowed if it is allowed by any rule matching its caller.
+	Rules []CSRAuthorizationRule `json:"rules,omitempty"`
+}
+
+// CSRAuthorizationRule allows the callers matching the rule to request certificates.
+type CSRAuthorizationRule struct {
+	// Callers are the patterns of the identities of the callers matching the rule. Every caller matches if empty.
+	Callers []string `json:"callers,omitempty"`
+	// SANs are the patterns of the identities the callers may request. Any identity is allowed if empty.
+	SANs []string `json:"sans,omitempty"`
+	// MaxTTL is the maximum TTL the callers may request. Any TTL is allowed if unset. Requests without TTL get the
+	// default TTL of the CA, which must not exceed it either.
+	MaxTTL *metav1.Duration `json:"maxTTL,omitempty"`
+	// KeyTypes are the patterns of the types of keys the callers may request certificates for, such as RSA-2048,
+	// RSA-*, ECDSA-P256 or ED25519. Any key type is allowed if empty.
+	KeyTypes []string `json:"keyTypes,omitempty"`
+}
+
+// ParseCSRAuthorizationPolicy parses and validates a CSRAuthorizationPolicy from YAML.
+func ParseCSRAuthorizationPolicy(policyYAML string) (*CSRAuthorizationPolicy, error) {
+	policy := &CSRAuthorizationPolicy{}
+	if err := yaml.UnmarshalStrict([]byte(policyYAML), policy); err != nil {
+		return nil, fmt.Errorf("failed to parse the CSR authorization policy: %v", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate returns an error if the policy is invalid.
+func (p *CSRAuthorizationPolicy) Validate() error {
+	switch p.DefaultAction {
+	case "", CSRAuthorizationAllow, CSRAuthorizationDeny:
+	default:
+		return fmt.Errorf("invalid default action %q, must be %s or %s", p.DefaultAction, CSRAuthorizationAllow, CSRAuthorizationDeny)
+	}
+	for i, rule := range p.Rules {
+		for _, pattern := range slices.Flatten([][]string{rule.Callers, rule.SANs, rule.KeyTypes}) {
+			if err := validatePattern(pattern); err != nil {
+				return fmt.Errorf("rule %d: %v", i, err)
+			}
+		}
+		if rule.MaxTTL != nil && rule.MaxTTL.Duration <= 0 {
+			return fmt.Errorf("rule %d: maxTTL must be positive", i)
+		}
+	}
+	return nil
+}
+
+// authorize returns an AuthorizationError if the policy denies the request.
+func (p *CSRAuthorizationPolicy) authorize(request *CSRRequest) *AuthorizationError {
+	var denial *AuthorizationError
+	for _, rule := range p.Rules {
+		if !rule.matches(request.Caller) {
+			continue
+		}
+		err := rule.authorize(request)
+		if err == nil {
+			return nil
+		}
+		if denial == nil {
+			denial = err
+		}
+	}
+	if denial != nil {
+		return denial
+	}
+	if p.DefaultAction == CSRAuthorizationDeny {
+		return denied(AuthorizationDeniedCaller, "caller %v matches no rule", request.Caller.Identities)
+	}
+	return nil
+}
+
+func (r *CSRAuthorizationRule) matches(caller *security.Caller) bool {
+	if len(r.Callers) == 0 {
+		return true
+	}
+	for _, id := range caller.Identities {
+		if matchesAny(r.Callers, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CSRAuthorizationRule) authorize(request *CSRRequest) *AuthorizationError {
+	if len(r.SANs) > 0 {
+		for _, san := range request.SANs {
+			if !matchesAny(r.SANs, san) {
+				return denied(AuthorizationDeniedSAN, "caller %v is not allowed to request identity %s", request.Caller.Identities, san)
+			}
+		}
+	}
+	if r.MaxTTL != nil {
+		if request.TTL <= 0 {
+			return denied(AuthorizationDeniedTTL, "caller %v is not allowed to request the default TTL, which is unknown",
+				request.Caller.Identities)
+		}
+		if request.TTL > r.MaxTTL.Duration {
+			return denied(AuthorizationDeniedTTL, "caller %v is not allowed to request a TTL of %s, more than %s",
+				request.Caller.Identities, request.TTL, r.MaxTTL.Duration)
+		}
+	}
+	if len(r.KeyTypes) > 0 {
+		keyType := KeyType(request.CSR)
+		if !matchesAny(r.KeyTypes, keyType) {
+			return denied(AuthorizationDeniedKeyType, "caller %v is not allowed to request a certificate for a %s key",
+				request.Caller.Identities, keyType)
+		}
+	}
+	return nil
+}
+
+// KeyType returns the type of the public key of a CSR, such as RSA-2048, ECDSA-P256 or ED25519.
+func KeyType(csr *x509.CertificateRequest) string {
+	switch key := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + strings.ReplaceAll(key.Curve.Params().Name, "-", "")
+	case ed25519.PublicKey:
+		return "ED25519"
+	default:
+		return strings.ToUpper(csr.PublicKeyAlgorithm.String())
+	}
+}
+
+func validatePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	if strings.Count(pattern, "*") > 1 || (strings.Contains(pattern, "*") &&
+		!strings.HasPrefix(pattern, "*") && !strings.HasSuffix(pattern, "*")) {
+		return fmt.Errorf("invalid pattern %q, the only wildcard must be a prefix or suffix", pattern)
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasSuffix(pattern, "*"):
+			if strings.HasPrefix(value, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case strings.HasPrefix(pattern, "*"):
+			if strings.HasSuffix(value, strings.TrimPrefix(pattern, "*")) {
+				return true
+			}
+		case pattern == value:
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyAuthorizer is a CSRAuthorizer enforcing a CSRAuthorizationPolicy, which can be updated at runtime.
+// It allows every request until a policy is set.
+type PolicyAuthorizer struct {
+	policy atomic.Pointer[CSRAuthorizationPolicy]
+}
+
+var _ CSRAuthorizer = &PolicyAuthorizer{}
+
+func NewPolicyAuthorizer(policy *CSRAuthorizationPolicy) *PolicyAuthorizer {
+	a := &PolicyAuthorizer{}
+	a.SetPolicy(policy)
+	return a
+}
+
+// SetPolicy updates the policy, nil to allow every request.
+func (a *PolicyAuthorizer) SetPolicy(policy *CSRAuthorizationPolicy) {
+	a.policy.Store(policy)
+}
+
+func (a *PolicyAuthorizer) AuthorizeCSR(_ context.Context, request *CSRRequest) error {
+	policy := a.policy.Load()
+	if policy == nil {
+		return nil
+	}
+	if err := policy.authorize(request); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "istio.io/api/security/v1alpha1"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/util/assert"
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const tenantPolicy = `
+defaultAction: DENY
+rules:
+- callers: ["spiffe://cluster.local/ns/tenant-a/*"]
+  sans: ["spiffe://cluster.local/ns/tenant-a/*"]
+  maxTTL: 24h
+  keyTypes: ["ECDSA-*"]
+- callers: ["spiffe://cluster.local/ns/tenant-a/sa/admin"]
+  sans: ["*"]
+- callers: ["*/ns/istio-system/sa/ztunnel"]
+`
+
+func genCSR(t *testing.T, ec bool, rsaKeySize int) string {
+	t.Helper()
+	opts := util.CertOptions{Host: "spiffe://cluster.local/ns/tenant-a/sa/a", RSAKeySize: rsaKeySize}
+	if ec {
+		opts.ECSigAlg = util.EcdsaSigAlg
+	}
+	csr, _, err := util.GenCSR(opts)
+	assert.NoError(t, err)
+	return string(csr)
+}
+
+func TestParseCSRAuthorizationPolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  string
+		wantErr string
+	}{
+		{name: "empty", policy: ""},
+		{name: "tenants", policy: tenantPolicy},
+		{name: "unknown field", policy: "rules:\n- caller: [a]", wantErr: `unknown field "caller"`},
+		{name: "invalid action", policy: "defaultAction: REJECT", wantErr: `invalid default action "REJECT"`},
+		{name: "infix wildcard", policy: "rules:\n- sans: [spiffe://*/ns/a]", wantErr: "rule 0: invalid pattern"},
+		{name: "two wildcards", policy: "rules:\n- callers: ['*a*']", wantErr: "rule 0: invalid pattern"},
+		{name: "empty pattern", policy: "rules:\n- keyTypes: ['']", wantErr: "rule 0: empty pattern"},
+		{name: "negative ttl", policy: "rules:\n- maxTTL: -1h", wantErr: "rule 0: maxTTL must be positive"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCSRAuthorizationPolicy(tt.policy)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyAuthorizer(t *testing.T) {
+	policy, err := ParseCSRAuthorizationPolicy(tenantPolicy)
+	assert.NoError(t, err)
+	ecCSR, err := util.ParsePemEncodedCSR([]byte(genCSR(t, true, 0)))
+	assert.NoError(t, err)
+	rsaCSR, err := util.ParsePemEncodedCSR([]byte(genCSR(t, false, 2048)))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyType(ecCSR), "ECDSA-P256")
+	assert.Equal(t, KeyType(rsaCSR), "RSA-2048")
+
+	tenantA := &security.Caller{Identities: []string{"spiffe://cluster.local/ns/tenant-a/sa/a"}}
+	cases := []struct {
+		name    string
+		request *CSRRequest
+		reason  string
+	}{
+		{
+			name:    "allowed",
+			request: &CSRRequest{Caller: tenantA, SANs: tenantA.Identities, TTL: time.Hour, CSR: ecCSR},
+		},
+		{
+			name:    "unknown default ttl",
+			request: &CSRRequest{Caller: tenantA, SANs: tenantA.Identities, CSR: ecCSR},
+			reason:  AuthorizationDeniedTTL,
+		},
+		{
+			name:    "san outside of the namespace",
+			request: &CSRRequest{Caller: tenantA, SANs: []string{"spiffe://cluster.local/ns/tenant-b/sa/a"}, TTL: time.Hour, CSR: ecCSR},
+			reason:  AuthorizationDeniedSAN,
+		},
+		{
+			name:    "ttl too long",
+			request: &CSRRequest{Caller: tenantA, SANs: tenantA.Identities, TTL: 48 * time.Hour, CSR: ecCSR},
+			reason:  AuthorizationDeniedTTL,
+		},
+		{
+			name:    "rsa key",
+			request: &CSRRequest{Caller: tenantA, SANs: tenantA.Identities, TTL: time.Hour, CSR: rsaCSR},
+			reason:  AuthorizationDeniedKeyType,
+		},
+		{
+			name: "allowed by a later rule",
+			request: &CSRRequest{
+				Caller: &security.Caller{Identities: []string{"spiffe://cluster.local/ns/tenant-a/sa/admin"}},
+				SANs:   []string{"spiffe://cluster.local/ns/tenant-b/sa/a"},
+				TTL:    48 * time.Hour,
+				CSR:    rsaCSR,
+			},
+		},
+		{
+			name: "impersonation by ztunnel",
+			request: &CSRRequest{
+				Caller: &security.Caller{Identities: []string{"spiffe://cluster.local/ns/istio-system/sa/ztunnel"}},
+				SANs:   []string{"spiffe://cluster.local/ns/tenant-b/sa/a"},
+				CSR:    rsaCSR,
+			},
+		},
+		{
+			name: "no matching rule",
+			request: &CSRRequest{
+				Caller: &security.Caller{Identities: []string{"spiffe://cluster.local/ns/tenant-b/sa/a"}},
+				SANs:   []string{"spiffe://cluster.local/ns/tenant-b/sa/a"},
+				CSR:    ecCSR,
+			},
+			reason: AuthorizationDeniedCaller,
+		},
+	}
+	authorizer := NewPolicyAuthorizer(policy)
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizer.AuthorizeCSR(context.Background(), tt.request)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			authzErr, ok := err.(*AuthorizationError)
+			if !ok {
+				t.Fatalf("got error %v, want an AuthorizationError", err)
+			}
+			assert.Equal(t, authzErr.Reason, tt.reason)
+		})
+	}
+
+	// Every request is allowed without policy.
+	authorizer.SetPolicy(nil)
+	assert.NoError(t, authorizer.AuthorizeCSR(context.Background(), cases[len(cases)-1].request))
+}
+
+func TestCreateCertificateAuthorization(t *testing.T) {
+	mt := monitortest.New(t)
+	policy, err := ParseCSRAuthorizationPolicy(tenantPolicy)
+	assert.NoError(t, err)
+	server := &Server{
+		ca: &mockca.FakeCA{
+			SignedCert:    []byte(testCert),
+			KeyCertBundle: util.NewKeyCertBundleFromPem(nil, nil, []byte(testCertChain), []byte(testRootCert), nil),
+		},
+		Authenticators: []security.Authenticator{&mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/tenant-a/sa/a"}}},
+		Authorizers:    []CSRAuthorizer{NewPolicyAuthorizer(policy)},
+		DefaultCertTTL: 48 * time.Hour,
+		monitoring:     newMonitoringMetrics(),
+	}
+	p := &peer.Peer{Addr: &net.IPAddr{IP: net.IPv4(192, 168, 1, 1)}, AuthInfo: credentials.TLSInfo{}}
+	ctx := peer.NewContext(context.Background(), p)
+
+	cases := []struct {
+		name    string
+		request *pb.IstioCertificateRequest
+		code    codes.Code
+	}{
+		{
+			name:    "allowed",
+			request: &pb.IstioCertificateRequest{Csr: genCSR(t, true, 0), ValidityDuration: 3600},
+			code:    codes.OK,
+		},
+		{
+			name:    "denied",
+			request: &pb.IstioCertificateRequest{Csr: genCSR(t, true, 0), ValidityDuration: 3600 * 48},
+			code:    codes.PermissionDenied,
+		},
+		{
+			name:    "default ttl above max",
+			request: &pb.IstioCertificateRequest{Csr: genCSR(t, true, 0)},
+			code:    codes.PermissionDenied,
+		},
+		{
+			name:    "invalid CSR",
+			request: &pb.IstioCertificateRequest{Csr: "dumb CSR"},
+			code:    codes.InvalidArgument,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.CreateCertificate(ctx, tt.request)
+			assert.Equal(t, status.Code(err), tt.code)
+		})
+	}
+	mt.Assert(authzErrorCounts.Name(), map[string]string{"reason": AuthorizationDeniedTTL}, monitortest.Exactly(2))
+}
@@ -0,0 +1,311 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// CertificatesPath is the path of the certificate inventory endpoint of the CA server.
+const CertificatesPath = "/ca/certificates"
+
+const (
+	// inventoryRefreshInterval is the interval at which the expired certificates are pruned from the inventory, and
+	// the expiry metrics are updated.
+	inventoryRefreshInterval = 30 * time.Second
+	// expiredRetention is how long the expired certificates are kept in the inventory, to find the workloads which did
+	// not renew them.
+	expiredRetention = 24 * time.Hour
+)
+
+// expiryBuckets are the upper bounds of the buckets of the time to expiry of the issued certificates, labeling the
+// citadel_server_issued_certs metric.
+var expiryBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"expired", 0},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+const expiryBucketLonger = "longer"
+
+// IssuedCertificate is an entry of the inventory of the certificates issued by the CA server.
+type IssuedCertificate struct {
+	// SerialNumber of the certificate, in lowercase hexadecimal.
+	SerialNumber string   `json:"serialNumber"`
+	Identities   []string `json:"identities"`
+	// Pod is the namespace/name of the pod requesting the certificate, if known.
+	Pod string `json:"pod,omitempty"`
+	// Node is the node of the pod requesting the certificate, if known.
+	Node string `json:"node,omitempty"`
+	// Impersonated is set if the certificate was requested by a node agent on behalf of the workload.
+	Impersonated bool      `json:"impersonated,omitempty"`
+	IssuedAt     time.Time `json:"issuedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	// Renewed is set if a later certificate was issued for the same identities and pod.
+	Renewed bool `json:"renewed,omitempty"`
+}
+
+func (c *IssuedCertificate) renewalKey() string {
+	return strings.Join(c.Identities, ",") + "/" + c.Pod
+}
+
+// CertInventory is a bounded store of the certificates issued by the CA server. Once full, the oldest certificates
+// are evicted.
+type CertInventory struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu sync.RWMutex
+	// certs are ordered by issuance.
+	certs []IssuedCertificate
+}
+
+// NewCertInventory returns an inventory of at most maxEntries certificates.
+func NewCertInventory(maxEntries int) *CertInventory {
+	return &CertInventory{maxEntries: maxEntries, now: time.Now}
+}
+
+// record adds a certificate issued for a caller to the inventory.
+func (i *CertInventory) record(certPEM string, caller *security.Caller, sans []string, impersonated bool) {
+	cert, err := util.ParsePemEncodedCertificate([]byte(certPEM))
+	if err != nil {
+		serverCaLog.Warnf("failed to parse issued certificate: %v", err)
+		return
+	}
+	issued := IssuedCertificate{
+		SerialNumber: cert.SerialNumber.Text(16),
+		Identities:   sans,
+		Node:         caller.KubernetesInfo.NodeName,
+		Impersonated: impersonated,
+		IssuedAt:     i.now(),
+		ExpiresAt:    cert.NotAfter,
+	}
+	if caller.KubernetesInfo.PodName != "" {
+		issued.Pod = caller.KubernetesInfo.PodNamespace + "/" + caller.KubernetesInfo.PodName
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.certs) >= i.maxEntries {
+		// Copy rather than reslice, so that the evicted entries are released.
+		i.certs = append(make([]IssuedCertificate, 0, i.maxEntries), i.certs[len(i.certs)-i.maxEntries+1:]...)
+	}
+	i.certs = append(i.certs, issued)
+}
+
+// List returns the certificates of the inventory, ordered by issuance, with their renewal status.
+func (i *CertInventory) List() []IssuedCertificate {
+	i.mu.RLock()
+	certs := append([]IssuedCertificate(nil), i.certs...)
+	i.mu.RUnlock()
+	MarkRenewed(certs)
+	return certs
+}
+
+// refresh prunes the certificates expired for longer than the retention, and updates the expiry metrics.
+func (i *CertInventory) refresh() {
+	now := i.now()
+	counts := map[string]int{}
+	i.mu.Lock()
+	kept := i.certs[:0]
+	for _, cert := range i.certs {
+		if now.Sub(cert.ExpiresAt) > expiredRetention {
+			continue
+		}
+		kept = append(kept, cert)
+		counts[expiryBucket(cert.ExpiresAt.Sub(now))]++
+	}
+	clear(i.certs[len(kept):])
+	i.certs = kept
+	i.mu.Unlock()
+
+	for _, bucket := range expiryBuckets {
+		issuedCerts.With(expiryTag.Value(bucket.label)).Record(float64(counts[bucket.label]))
+	}
+	issuedCerts.With(expiryTag.Value(expiryBucketLonger)).Record(float64(counts[expiryBucketLonger]))
+}
+
+// Run refreshes the inventory until the stop channel is closed.
+func (i *CertInventory) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(inventoryRefreshInterval)
+	defer ticker.Stop()
+	for {
+		i.refresh()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func expiryBucket(ttl time.Duration) string {
+	for _, bucket := range expiryBuckets {
+		if ttl <= bucket.max {
+			return bucket.label
+		}
+	}
+	return expiryBucketLonger
+}
+
+// MarkRenewed sets the renewal status of certificates ordered by issuance, which may come from several replicas.
+func MarkRenewed(certs []IssuedCertificate) {
+	latest := map[string]time.Time{}
+	for _, cert := range certs {
+		if key := cert.renewalKey(); cert.IssuedAt.After(latest[key]) {
+			latest[key] = cert.IssuedAt
+		}
+	}
+	for n := range certs {
+		certs[n].Renewed = certs[n].IssuedAt.Before(latest[certs[n].renewalKey()])
+	}
+}
+
+// SortByExpiry sorts certificates by expiry, the earliest first.
+func SortByExpiry(certs []IssuedCertificate) {
+	sort.SliceStable(certs, func(a, b int) bool {
+		return certs[a].ExpiresAt.Before(certs[b].ExpiresAt)
+	})
+}
+
+// CertificateFilter selects certificates of the inventory.
+type CertificateFilter struct {
+	// Identity is a pattern of the identities, with a single '*' wildcard as prefix or suffix.
+	Identity  string
+	Namespace string
+	Node      string
+	// ExpiringWithin selects the certificates expiring within the duration, including the expired ones.
+	ExpiringWithin time.Duration
+	// NotRenewed selects the certificates which were not renewed.
+	NotRenewed bool
+}
+
+// ParseCertificateFilter parses a filter from the query parameters identity, namespace, node, expiringWithin and
+// notRenewed.
+func ParseCertificateFilter(query url.Values) (CertificateFilter, error) {
+	filter := CertificateFilter{
+		Identity:  query.Get("identity"),
+		Namespace: query.Get("namespace"),
+		Node:      query.Get("node"),
+	}
+	if filter.Identity != "" {
+		if err := validatePattern(filter.Identity); err != nil {
+			return filter, err
+		}
+	}
+	if v := query.Get("expiringWithin"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid expiringWithin: %v", err)
+		}
+		filter.ExpiringWithin = d
+	}
+	if v := query.Get("notRenewed"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid notRenewed: %v", err)
+		}
+		filter.NotRenewed = b
+	}
+	return filter, nil
+}
+
+// Query returns the query parameters of the filter.
+func (f CertificateFilter) Query() url.Values {
+	query := url.Values{}
+	for k, v := range map[string]string{"identity": f.Identity, "namespace": f.Namespace, "node": f.Node} {
+		if v != "" {
+			query.Set(k, v)
+		}
+	}
+	if f.ExpiringWithin > 0 {
+		query.Set("expiringWithin", f.ExpiringWithin.String())
+	}
+	if f.NotRenewed {
+		query.Set("notRenewed", "true")
+	}
+	return query
+}
+
+// Apply returns the certificates selected by the filter, at the given time. The renewal status must be set.
+func (f CertificateFilter) Apply(certs []IssuedCertificate, now time.Time) []IssuedCertificate {
+	var res []IssuedCertificate
+	for _, cert := range certs {
+		if f.Identity != "" && !slices.ContainsFunc(cert.Identities, func(id string) bool { return matchesAny([]string{f.Identity}, id) }) {
+			continue
+		}
+		if f.Namespace != "" && !strings.HasPrefix(cert.Pod, f.Namespace+"/") {
+			continue
+		}
+		if f.Node != "" && cert.Node != f.Node {
+			continue
+		}
+		if f.ExpiringWithin > 0 && cert.ExpiresAt.Sub(now) > f.ExpiringWithin {
+			continue
+		}
+		if f.NotRenewed && cert.Renewed {
+			continue
+		}
+		res = append(res, cert)
+	}
+	return res
+}
+
+// CertificatesHandler returns the handler of the certificate inventory endpoint, listing the certificates issued by
+// this replica, ordered by expiry, filtered by the query parameters of ParseCertificateFilter. Callers must be
+// authorized by authorizeAdmin.
+func (s *Server) CertificatesHandler(inventory *CertInventory) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := s.authorizeAdmin(req); err != nil {
+			serverCaLog.Warnf("unauthorized certificate inventory request from %s: %v", req.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		filter, err := ParseCertificateFilter(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		certs := filter.Apply(inventory.List(), inventory.now())
+		if certs == nil {
+			certs = []IssuedCertificate{}
+		}
+		SortByExpiry(certs)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(certs); err != nil {
+			serverCaLog.Errorf("failed to write certificates: %v", err)
+		}
+	})
+}
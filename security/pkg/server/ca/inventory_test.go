@@ -0,0 +1,202 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	pb "istio.io/api/security/v1alpha1"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// genCert returns a certificate valid from now for the TTL.
+func genCert(t *testing.T, now time.Time, ttl time.Duration) string {
+	t.Helper()
+	cert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "spiffe://cluster.local/ns/a/sa/a",
+		NotBefore:    now,
+		TTL:          ttl,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	assert.NoError(t, err)
+	return string(cert)
+}
+
+func podCaller(ns, pod, node string) *security.Caller {
+	return &security.Caller{
+		Identities:     []string{"spiffe://cluster.local/ns/" + ns + "/sa/default"},
+		KubernetesInfo: security.KubernetesInfo{PodNamespace: ns, PodName: pod, NodeName: node},
+	}
+}
+
+func serials(certs []IssuedCertificate) []string {
+	return slices.Map(certs, func(c IssuedCertificate) string { return c.SerialNumber })
+}
+
+func TestCertInventory(t *testing.T) {
+	mt := monitortest.New(t)
+	now := time.Now().Truncate(time.Second)
+	inventory := NewCertInventory(3)
+	inventory.now = func() time.Time { return now }
+	record := func(caller *security.Caller, ttl time.Duration) string {
+		inventory.record(genCert(t, now, ttl), caller, caller.Identities, false)
+		certs := inventory.List()
+		return certs[len(certs)-1].SerialNumber
+	}
+
+	a1 := record(podCaller("a", "pod-a", "node-1"), time.Hour)
+	b1 := record(podCaller("b", "pod-b", "node-2"), 2*time.Hour)
+	now = now.Add(time.Minute)
+	a2 := record(podCaller("a", "pod-a", "node-1"), 30*time.Hour)
+	certs := inventory.List()
+	assert.Equal(t, serials(certs), []string{a1, b1, a2})
+	assert.Equal(t, slices.Map(certs, func(c IssuedCertificate) bool { return c.Renewed }), []bool{true, false, false})
+	assert.Equal(t, certs[2], IssuedCertificate{
+		SerialNumber: a2,
+		Identities:   []string{"spiffe://cluster.local/ns/a/sa/default"},
+		Pod:          "a/pod-a",
+		Node:         "node-1",
+		IssuedAt:     now,
+		ExpiresAt:    now.Add(30 * time.Hour),
+	})
+
+	// The oldest certificate is evicted once full.
+	c1 := record(podCaller("c", "pod-c", "node-2"), 10*24*time.Hour)
+	assert.Equal(t, serials(inventory.List()), []string{b1, a2, c1})
+
+	inventory.refresh()
+	for bucket, count := range map[string]float64{"expired": 0, "1h": 0, "6h": 1, "24h": 0, "7d": 1, "longer": 1} {
+		mt.Assert(issuedCerts.Name(), map[string]string{expirylabel: bucket}, monitortest.Exactly(count))
+	}
+
+	// The expired certificates are kept for the retention.
+	now = now.Add(3 * time.Hour)
+	inventory.refresh()
+	assert.Equal(t, serials(inventory.List()), []string{b1, a2, c1})
+	mt.Assert(issuedCerts.Name(), map[string]string{expirylabel: "expired"}, monitortest.Exactly(1))
+	now = now.Add(expiredRetention)
+	inventory.refresh()
+	assert.Equal(t, serials(inventory.List()), []string{a2, c1})
+}
+
+func TestCertificateFilter(t *testing.T) {
+	now := time.Now()
+	certs := []IssuedCertificate{
+		{SerialNumber: "1", Identities: []string{"spiffe://cluster.local/ns/a/sa/a"}, Pod: "a/pod", IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{SerialNumber: "2", Identities: []string{"spiffe://cluster.local/ns/b/sa/b"}, Pod: "b/pod", Node: "node", IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{
+			SerialNumber: "3", Identities: []string{"spiffe://cluster.local/ns/a/sa/a"}, Pod: "a/pod",
+			IssuedAt: now.Add(time.Minute), ExpiresAt: now.Add(24 * time.Hour),
+		},
+	}
+	MarkRenewed(certs)
+	cases := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "all", query: "", want: []string{"1", "2", "3"}},
+		{name: "identity", query: "identity=spiffe://cluster.local/ns/a/*", want: []string{"1", "3"}},
+		{name: "namespace", query: "namespace=b", want: []string{"2"}},
+		{name: "node", query: "node=node", want: []string{"2"}},
+		{name: "expiring", query: "expiringWithin=2h0m0s", want: []string{"1", "2"}},
+		{name: "not renewed", query: "notRenewed=true", want: []string{"2", "3"}},
+		{name: "expiring and not renewed", query: "expiringWithin=2h0m0s&notRenewed=true", want: []string{"2"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			assert.NoError(t, err)
+			filter, err := ParseCertificateFilter(query)
+			assert.NoError(t, err)
+			assert.Equal(t, filter.Query(), query)
+			assert.Equal(t, serials(filter.Apply(certs, now)), tt.want)
+		})
+	}
+
+	for _, query := range []string{"identity=a*b", "expiringWithin=1", "notRenewed=maybe"} {
+		q, _ := url.ParseQuery(query)
+		if _, err := ParseCertificateFilter(q); err == nil {
+			t.Errorf("expected an error for %s", query)
+		}
+	}
+}
+
+func TestCertificatesHandler(t *testing.T) {
+	signed := genCert(t, time.Now(), time.Hour)
+	server := &Server{
+		ca: &mockca.FakeCA{
+			SignedCert:    []byte(signed),
+			KeyCertBundle: util.NewKeyCertBundleFromPem(nil, nil, []byte(testCertChain), []byte(testRootCert), nil),
+		},
+		Authenticators:  []security.Authenticator{&mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/a/sa/a"}}},
+		Inventory:       NewCertInventory(10),
+		AdminIdentities: sets.New("spiffe://cluster.local/ns/a/sa/a"),
+		monitoring:      newMonitoringMetrics(),
+	}
+	p := &peer.Peer{Addr: &net.IPAddr{IP: net.IPv4(192, 168, 1, 1)}, AuthInfo: credentials.TLSInfo{}}
+	_, err := server.CreateCertificate(peer.NewContext(context.Background(), p), &pb.IstioCertificateRequest{Csr: "dumb CSR"})
+	assert.NoError(t, err)
+	cert, err := util.ParsePemEncodedCertificate([]byte(signed))
+	assert.NoError(t, err)
+
+	handler := server.CertificatesHandler(server.Inventory)
+	get := func(target string) (int, []IssuedCertificate) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.TLS = &tls.ConnectionState{}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var certs []IssuedCertificate
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &certs))
+		}
+		return rec.Code, certs
+	}
+	code, certs := get(CertificatesPath)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, serials(certs), []string{cert.SerialNumber.Text(16)})
+	assert.Equal(t, certs[0].Identities, []string{"spiffe://cluster.local/ns/a/sa/a"})
+
+	code, certs = get(CertificatesPath + "?identity=spiffe://cluster.local/ns/b/*")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(certs), 0)
+
+	code, _ = get(CertificatesPath + "?expiringWithin=soon")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// Plaintext callers are not authorized.
+	req := httptest.NewRequest(http.MethodGet, CertificatesPath, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+}
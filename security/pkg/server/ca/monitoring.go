@@ -19,11 +19,15 @@ import (
 )
 
 const (
-	errorlabel = "error"
+	errorlabel  = "error"
+	reasonlabel = "reason"
+	expirylabel = "expires_in"
 )
 
 var (
-	errorTag = monitoring.CreateLabel(errorlabel)
+	errorTag  = monitoring.CreateLabel(errorlabel)
+	reasonTag = monitoring.CreateLabel(reasonlabel)
+	expiryTag = monitoring.CreateLabel(expirylabel)
 
 	csrCounts = monitoring.NewSum(
 		"citadel_server_csr_count",
@@ -35,6 +39,11 @@ var (
 		"The number of authentication failures.",
 	)
 
+	authzErrorCounts = monitoring.NewSum(
+		"citadel_server_csr_authorization_failure_count",
+		"The number of CSRs denied by the CSR authorization policy.",
+	)
+
 	csrParsingErrorCounts = monitoring.NewSum(
 		"citadel_server_csr_parsing_err_count",
 		"The number of errors occurred when parsing the CSR.",
@@ -55,6 +64,16 @@ var (
 		"The number of certificates issuances that have succeeded.",
 	)
 
+	revocationCounts = monitoring.NewSum(
+		"citadel_server_certificate_revocation_count",
+		"The number of certificate revocation requests that have succeeded.",
+	)
+
+	issuedCerts = monitoring.NewGauge(
+		"citadel_server_issued_certs",
+		"The number of certificates in the inventory of issued certificates, by time remaining before they expire.",
+	)
+
 	rootCertExpiryTimestamp = monitoring.NewGauge(
 		"citadel_server_root_cert_expiry_timestamp",
 		"The unix timestamp, in seconds, when the root cert will expire.",
@@ -82,7 +101,9 @@ type monitoringMetrics struct {
 	Success           monitoring.Metric
 	CSRError          monitoring.Metric
 	IDExtractionError monitoring.Metric
+	Revocation        monitoring.Metric
 	certSignErrors    monitoring.Metric
+	authzErrors       monitoring.Metric
 }
 
 // newMonitoringMetrics creates a new monitoringMetrics.
@@ -93,10 +114,16 @@ func newMonitoringMetrics() monitoringMetrics {
 		Success:           successCounts,
 		CSRError:          csrParsingErrorCounts,
 		IDExtractionError: idExtractionErrorCounts,
+		Revocation:        revocationCounts,
 		certSignErrors:    certSignErrorCounts,
+		authzErrors:       authzErrorCounts,
 	}
 }
 
 func (m *monitoringMetrics) GetCertSignError(err string) monitoring.Metric {
 	return m.certSignErrors.With(errorTag.Value(err))
 }
+
+func (m *monitoringMetrics) GetAuthzError(reason string) monitoring.Metric {
+	return m.authzErrors.With(reasonTag.Value(reason))
+}
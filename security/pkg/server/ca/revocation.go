@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+// RevocationPath is the path of the certificate revocation endpoint of the CA server.
+const RevocationPath = "/ca/revocations"
+
+// CertificateRevoker is implemented by the CAs supporting the revocation of the certificates they issued.
+type CertificateRevoker interface {
+	// RevokeCertificate revokes the certificate with the given serial number.
+	RevokeCertificate(serialNumber string, reason int) ([]ca.Revocation, error)
+	// RevokeIdentity revokes all the certificates issued for the identity.
+	RevokeIdentity(identity string, reason int) ([]ca.Revocation, error)
+	// Revocations returns the revocation list.
+	Revocations() []ca.Revocation
+}
+
+// RevocationRequest is the body of a revocation request, which revokes either a certificate by serial number or all
+// the certificates of an identity.
+type RevocationRequest struct {
+	// SerialNumber of the certificate, in hexadecimal.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// Identity is the SPIFFE identity of the certificates.
+	Identity string `json:"identity,omitempty"`
+	// Reason is the name of a CRL reason code, e.g. keyCompromise.
+	Reason string `json:"reason,omitempty"`
+}
+
+// RevocationHandler returns the handler of the revocation endpoint: GET lists the revocation list and POST revokes
+// certificates with a RevocationRequest. Callers must be authorized by authorizeAdmin.
+func (s *Server) RevocationHandler(revoker CertificateRevoker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := s.authorizeAdmin(req); err != nil {
+			serverCaLog.Warnf("unauthorized revocation request from %s: %v", req.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			writeRevocations(w, revoker.Revocations())
+		case http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r := &RevocationRequest{}
+			if err := json.Unmarshal(body, r); err != nil {
+				http.Error(w, fmt.Sprintf("invalid revocation request: %v", err), http.StatusBadRequest)
+				return
+			}
+			revocations, status, err := revoke(revoker, r)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+			s.monitoring.Revocation.Increment()
+			serverCaLog.Infof("revoked certificates for %+v: %d entries", *r, len(revocations))
+			writeRevocations(w, revocations)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func revoke(revoker CertificateRevoker, r *RevocationRequest) ([]ca.Revocation, int, error) {
+	reason, err := ca.ParseRevocationReason(r.Reason)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	var revocations []ca.Revocation
+	switch {
+	case r.SerialNumber != "" && r.Identity != "":
+		return nil, http.StatusBadRequest, fmt.Errorf("only one of serialNumber and identity can be set")
+	case r.SerialNumber != "":
+		if _, err := ca.ParseSerialNumber(r.SerialNumber); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		revocations, err = revoker.RevokeCertificate(r.SerialNumber, reason)
+	case r.Identity != "":
+		if _, err := spiffe.ParseIdentity(r.Identity); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid identity: %v", err)
+		}
+		revocations, err = revoker.RevokeIdentity(r.Identity, reason)
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("one of serialNumber and identity is required")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return revocations, http.StatusOK, nil
+}
+
+// authorizeAdmin checks the caller of the CA management endpoints is connected over TLS, and is authenticated like
+// CSRs with one of the AdminIdentities. No caller is authorized if there is no admin identity.
+func (s *Server) authorizeAdmin(req *http.Request) error {
+	if req.TLS == nil {
+		return fmt.Errorf("TLS is required")
+	}
+	if s.AdminIdentities.IsEmpty() {
+		return fmt.Errorf("no admin identity is configured")
+	}
+	var caller *security.Caller
+	for _, authn := range s.Authenticators {
+		if c, err := authn.Authenticate(security.AuthContext{Request: req}); c != nil && err == nil {
+			caller = c
+			break
+		}
+	}
+	if caller == nil {
+		return fmt.Errorf("authentication failure")
+	}
+	for _, id := range caller.Identities {
+		if s.AdminIdentities.Contains(id) {
+			return nil
+		}
+	}
+	return fmt.Errorf("identities %v are not admin identities", caller.Identities)
+}
+
+func writeRevocations(w http.ResponseWriter, revocations []ca.Revocation) {
+	if revocations == nil {
+		revocations = []ca.Revocation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(revocations); err != nil {
+		serverCaLog.Errorf("failed to write revocations: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+type fakeRevoker struct {
+	revocations []ca.Revocation
+}
+
+func (f *fakeRevoker) RevokeCertificate(serialNumber string, reason int) ([]ca.Revocation, error) {
+	r := ca.Revocation{SerialNumber: serialNumber, Reason: reason, RevokedAt: time.Now()}
+	f.revocations = append(f.revocations, r)
+	return []ca.Revocation{r}, nil
+}
+
+func (f *fakeRevoker) RevokeIdentity(identity string, reason int) ([]ca.Revocation, error) {
+	r := ca.Revocation{Identity: identity, Reason: reason, RevokedAt: time.Now()}
+	f.revocations = append(f.revocations, r)
+	return []ca.Revocation{r}, nil
+}
+
+func (f *fakeRevoker) Revocations() []ca.Revocation {
+	return f.revocations
+}
+
+func TestRevocationHandler(t *testing.T) {
+	admin := &mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/istio-system/sa/istio-ca-admin"}}
+	system := &mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/istio-system/sa/istiod"}}
+	workload := &mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/default/sa/sleep"}}
+	unauthenticated := &mockAuthenticator{errMsg: "no token"}
+
+	cases := []struct {
+		name          string
+		authenticator security.Authenticator
+		plaintext     bool
+		remoteAddr    string
+		method        string
+		body          string
+		status        int
+		revoked       int
+	}{
+		{
+			name:          "revoke serial number",
+			authenticator: admin,
+			method:        http.MethodPost,
+			body:          `{"serialNumber": "0A:1B", "reason": "keyCompromise"}`,
+			status:        http.StatusOK,
+			revoked:       1,
+		},
+		{
+			name:          "revoke identity",
+			authenticator: admin,
+			method:        http.MethodPost,
+			body:          `{"identity": "spiffe://cluster.local/ns/default/sa/sleep"}`,
+			status:        http.StatusOK,
+			revoked:       1,
+		},
+		{
+			name:          "local caller",
+			authenticator: unauthenticated,
+			remoteAddr:    "127.0.0.1:12345",
+			method:        http.MethodPost,
+			body:          `{"serialNumber": "1b"}`,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "plaintext caller",
+			authenticator: admin,
+			plaintext:     true,
+			method:        http.MethodGet,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "system namespace caller",
+			authenticator: system,
+			method:        http.MethodPost,
+			body:          `{"serialNumber": "1b"}`,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "list",
+			authenticator: admin,
+			method:        http.MethodGet,
+			status:        http.StatusOK,
+		},
+		{
+			name:          "workload caller",
+			authenticator: workload,
+			method:        http.MethodPost,
+			body:          `{"serialNumber": "1b"}`,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "unauthenticated caller",
+			authenticator: unauthenticated,
+			method:        http.MethodGet,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "both serial number and identity",
+			authenticator: admin,
+			method:        http.MethodPost,
+			body:          `{"serialNumber": "1b", "identity": "spiffe://cluster.local/ns/default/sa/sleep"}`,
+			status:        http.StatusBadRequest,
+		},
+		{
+			name:          "invalid identity",
+			authenticator: admin,
+			method:        http.MethodPost,
+			body:          `{"identity": "sleep"}`,
+			status:        http.StatusBadRequest,
+		},
+		{
+			name:          "invalid reason",
+			authenticator: admin,
+			method:        http.MethodPost,
+			body:          `{"serialNumber": "1b", "reason": "unknown"}`,
+			status:        http.StatusBadRequest,
+		},
+		{
+			name:          "invalid method",
+			authenticator: admin,
+			method:        http.MethodDelete,
+			status:        http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			revoker := &fakeRevoker{}
+			s := &Server{
+				Authenticators:  []security.Authenticator{tt.authenticator},
+				AdminIdentities: sets.New("spiffe://cluster.local/ns/istio-system/sa/istio-ca-admin"),
+				monitoring:      newMonitoringMetrics(),
+			}
+			req := httptest.NewRequest(tt.method, RevocationPath, strings.NewReader(tt.body))
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if !tt.plaintext {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			s.RevocationHandler(revoker).ServeHTTP(rec, req)
+			assert.Equal(t, rec.Code, tt.status)
+			assert.Equal(t, len(revoker.revocations), tt.revoked)
+			if tt.status == http.StatusOK {
+				var revocations []ca.Revocation
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &revocations))
+				assert.Equal(t, len(revocations), tt.revoked)
+			}
+		})
+	}
+}
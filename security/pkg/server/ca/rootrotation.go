@@ -0,0 +1,61 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+// RootRotationPath is the path of the root rotation status endpoint of the CA server.
+const RootRotationPath = "/ca/rotation"
+
+// RootRotator is implemented by the CAs supporting the staged rotation of their root.
+type RootRotator interface {
+	// RootRotation returns the root rotation in progress or the last one, or nil if there is none.
+	RootRotation() (*ca.RootRotation, error)
+}
+
+// RootRotationHandler returns the handler of the root rotation status endpoint, which returns the root rotation as
+// seen by this replica. Rotations are started through the secret of the rotation, e.g. with istioctl x ca rotate.
+// Callers must be authorized by authorizeAdmin.
+func (s *Server) RootRotationHandler(rotator RootRotator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := s.authorizeAdmin(req); err != nil {
+			serverCaLog.Warnf("unauthorized root rotation request from %s: %v", req.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rotation, err := rotator.RootRotation()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rotation == nil {
+			http.Error(w, "no root rotation", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rotation); err != nil {
+			serverCaLog.Errorf("failed to write root rotation: %v", err)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+type fakeRootRotator struct {
+	rotation *ca.RootRotation
+}
+
+func (f *fakeRootRotator) RootRotation() (*ca.RootRotation, error) {
+	return f.rotation, nil
+}
+
+func TestRootRotationHandler(t *testing.T) {
+	admin := &mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/istio-system/sa/istio-ca-admin"}}
+	workload := &mockAuthenticator{identities: []string{"spiffe://cluster.local/ns/default/sa/sleep"}}
+	rotation := &ca.RootRotation{Status: ca.RootRotationStatus{Phase: ca.RootRotationAddingRoot}}
+
+	cases := []struct {
+		name          string
+		authenticator security.Authenticator
+		method        string
+		rotation      *ca.RootRotation
+		status        int
+	}{
+		{
+			name:          "status",
+			authenticator: admin,
+			method:        http.MethodGet,
+			rotation:      rotation,
+			status:        http.StatusOK,
+		},
+		{
+			name:          "no rotation",
+			authenticator: admin,
+			method:        http.MethodGet,
+			status:        http.StatusNotFound,
+		},
+		{
+			name:          "workload caller",
+			authenticator: workload,
+			method:        http.MethodGet,
+			rotation:      rotation,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "invalid method",
+			authenticator: admin,
+			method:        http.MethodPost,
+			rotation:      rotation,
+			status:        http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Authenticators:  []security.Authenticator{tt.authenticator},
+				AdminIdentities: sets.New("spiffe://cluster.local/ns/istio-system/sa/istio-ca-admin"),
+			}
+			req := httptest.NewRequest(tt.method, RootRotationPath, nil)
+			req.TLS = &tls.ConnectionState{}
+			rec := httptest.NewRecorder()
+			s.RootRotationHandler(&fakeRootRotator{rotation: tt.rotation}).ServeHTTP(rec, req)
+			assert.Equal(t, rec.Code, tt.status)
+			if tt.status == http.StatusOK {
+				got := &ca.RootRotation{}
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), got))
+				assert.Equal(t, got.Status.Phase, ca.RootRotationAddingRoot)
+			}
+		})
+	}
+}
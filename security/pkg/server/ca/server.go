@@ -28,6 +28,7 @@ import (
 	"istio.io/istio/pkg/kube/multicluster"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/security/pkg/pki/ca"
 	caerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
@@ -55,6 +56,16 @@ type Server struct {
 	serverCertTTL  time.Duration
 
 	nodeAuthorizer *MulticlusterNodeAuthorizor
+
+	// Authorizers authorize the CSRs of the authenticated callers, before they are signed.
+	Authorizers []CSRAuthorizer
+	// DefaultCertTTL is the TTL the CA signs the certificates of the requests without TTL with, for the
+	// authorizers to check them against.
+	DefaultCertTTL time.Duration
+	// Inventory records the issued certificates, if set.
+	Inventory *CertInventory
+	// AdminIdentities are the identities allowed to call the CA management endpoints.
+	AdminIdentities sets.String
 }
 
 type SaNode struct {
@@ -108,6 +119,9 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		sans = []string{impersonatedIdentity}
 	}
 	serverCaLog.Debugf("generating a certificate, sans: %v, requested ttl: %s", sans, time.Duration(request.ValidityDuration*int64(time.Second)))
+	if err := s.authorize(ctx, caller, sans, request); err != nil {
+		return nil, err
+	}
 	certSigner := crMetadata[security.CertSigner].GetStringValue()
 	_, _, certChainBytes, rootCertBytes := s.ca.GetCAKeyCertBundle().GetAll()
 	certOpts := ca.CertOpts{
@@ -154,12 +168,50 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		response.CertChain = append(response.CertChain, string(rootCertBytes))
 	}
 
+	if s.Inventory != nil && len(response.CertChain) > 0 {
+		s.Inventory.record(response.CertChain[0], caller, sans, impersonatedIdentity != "")
+	}
+
 	serverCaLog.Debugf("Responding with cert chain, %q", response.CertChain)
 	s.monitoring.Success.Increment()
 	serverCaLog.Debugf("CSR successfully signed, sans %v.", sans)
 	return response, nil
 }
 
+// authorize runs the authorizers on the request, returning the gRPC error to respond if it is denied.
+func (s *Server) authorize(ctx context.Context, caller *security.Caller, sans []string, request *pb.IstioCertificateRequest) error {
+	if len(s.Authorizers) == 0 {
+		return nil
+	}
+	csr, err := util.ParsePemEncodedCSR([]byte(request.Csr))
+	if err != nil {
+		s.monitoring.CSRError.Increment()
+		return status.Errorf(codes.InvalidArgument, "CSR parsing error (%v)", err)
+	}
+	csrRequest := &CSRRequest{
+		Caller: caller,
+		SANs:   sans,
+		TTL:    time.Duration(request.ValidityDuration) * time.Second,
+		CSR:    csr,
+	}
+	if csrRequest.TTL <= 0 {
+		csrRequest.TTL = s.DefaultCertTTL
+	}
+	for _, authorizer := range s.Authorizers {
+		if err := authorizer.AuthorizeCSR(ctx, csrRequest); err != nil {
+			reason := AuthorizationDeniedCaller
+			if authzErr, ok := err.(*AuthorizationError); ok {
+				reason = authzErr.Reason
+			}
+			s.monitoring.GetAuthzError(reason).Increment()
+			// Return an opaque error (for security purposes) but log the full reason
+			serverCaLog.WithLabels("client", security.GetConnectionAddress(ctx)).Warnf("CSR authorization failed: %v", err)
+			return status.Error(codes.PermissionDenied, "request authorization failure")
+		}
+	}
+	return nil
+}
+
 // RecordCertsExpiry updates the certificate-expiration related metrics given a new keycertbundle
 func RecordCertsExpiry(keyCertBundle *util.KeyCertBundle) {
 	// Expiry of the first root cert in trust bundle
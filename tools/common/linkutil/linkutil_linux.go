@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package config
+// Package linkutil provides helpers for netlink links shared by the traffic capture setups.
+package linkutil
 
 import (
 	"errors"
@@ -23,9 +24,9 @@ import (
 // Max number of attempts to netlink api when it returns ErrDumpInterrupted
 const maxAttempts = 5
 
-// LinkByNameWithRetries calls netlink.LinkByName, retrying if necessary on ErrDumpInterrupted.
+// ByNameWithRetries calls netlink.LinkByName, retrying if necessary on ErrDumpInterrupted.
 // For more details, see https://github.com/istio/istio/issues/55707
-func LinkByNameWithRetries(name string) (netlink.Link, error) {
+func ByNameWithRetries(name string) (netlink.Link, error) {
 	var link netlink.Link
 	var err error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
@@ -15,13 +15,13 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package config
+package linkutil
 
 import (
 	"github.com/vishvananda/netlink"
 )
 
-// LinkByNameWithRetries calls netlink.LinkByName. On non-Linux platforms, this is just a direct passthrough API.
-func LinkByNameWithRetries(name string) (netlink.Link, error) {
+// ByNameWithRetries calls netlink.LinkByName. On non-Linux platforms, this is just a direct passthrough API.
+func ByNameWithRetries(name string) (netlink.Link, error) {
 	return netlink.LinkByName(name)
 }
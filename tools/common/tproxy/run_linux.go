@@ -24,6 +24,7 @@ import (
 
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/tools/common/config"
+	"istio.io/istio/tools/common/linkutil"
 )
 
 // configureTProxyRoutes configures ip firewall rules to enable TPROXY support.
@@ -31,7 +32,7 @@ import (
 func configureTProxyRoutes(cfg *config.Config) error {
 	if cfg.InboundPortsInclude != "" {
 		if cfg.InboundInterceptionMode == "TPROXY" {
-			link, err := config.LinkByNameWithRetries("lo")
+			link, err := linkutil.ByNameWithRetries("lo")
 			if err != nil {
 				return fmt.Errorf("failed to find 'lo' link: %v", err)
 			}
@@ -109,7 +110,7 @@ func configureIPv6Addresses(cfg *config.Config) error {
 	if !cfg.EnableIPv6 {
 		return nil
 	}
-	link, err := config.LinkByNameWithRetries("lo")
+	link, err := linkutil.ByNameWithRetries("lo")
 	if err != nil {
 		return fmt.Errorf("failed to find 'lo' link: %v", err)
 	}
@@ -31,7 +31,7 @@ import (
 	nftables "istio.io/istio/tools/istio-nftables/pkg/nft"
 )
 
-const InvalidDropByIptables = "INVALID_DROP"
+const InvalidDropByIptables = constants.InvalidDropByIptables
 
 func handleErrorWithCode(err error, code int) {
 	log.Error(err)
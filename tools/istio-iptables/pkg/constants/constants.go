@@ -124,6 +124,8 @@ const (
 // Constants used in environment variables
 const (
 	EnvoyUser = "ENVOY_USER"
+	// InvalidDropByIptables is the environment variable of the proxy enabling the drop of invalid packets.
+	InvalidDropByIptables = "INVALID_DROP"
 )
 
 // Constants for syscall
@@ -19,12 +19,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 	"syscall"
 
-	netns "github.com/containernetworking/plugins/pkg/ns"
 	"golang.org/x/sys/unix"
 	utilversion "k8s.io/apimachinery/pkg/util/version"
 
@@ -149,17 +149,20 @@ func shouldUseBinaryForCurrentContext(iptablesBin string) (IptablesVersion, erro
 // This puts us in somewhat unconventionally territory.
 func runInSandbox(lockFile string, f func() error) error {
 	chErr := make(chan error, 1)
-	n, nerr := netns.GetCurrentNS()
+	// Open the network namespace of the calling thread directly rather than with the CNI plugins ns package, which
+	// istioctl must not depend on to render the capture rules.
+	n, nerr := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid()))
 	if nerr != nil {
 		return fmt.Errorf("failed to get current namespace: %v", nerr)
 	}
+	defer n.Close()
 	// setupSandbox builds the sandbox.
 	setupSandbox := func() error {
 		// First, unshare the mount namespace. This allows us to create custom mounts without impacting the host
 		if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
 			return fmt.Errorf("failed to unshare to new mount namespace: %v", err)
 		}
-		if err := n.Set(); err != nil {
+		if err := unix.Setns(int(n.Fd()), unix.CLONE_NEWNET); err != nil {
 			return fmt.Errorf("failed to reset network namespace: %v", err)
 		}
 		// Remount / as a private mount so that our mounts do not impact outside the namespace
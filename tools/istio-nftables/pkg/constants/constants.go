@@ -28,8 +28,9 @@ const (
 	IstioAmbientRawTable    = "istio-ambient-raw"
 
 	// Base chains.
-	PreroutingChain = "prerouting"
-	OutputChain     = "output"
+	PreroutingChain  = "prerouting"
+	OutputChain      = "output"
+	PostroutingChain = "postrouting"
 
 	// Regular chains prefixed with "istio" to distinguish them from base chains
 	IstioInboundChain    = "istio-inbound"